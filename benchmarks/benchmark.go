@@ -0,0 +1,241 @@
+// Package benchmarks measures the completion latency and throughput of a client's providers, to
+// compare them on the same prompt.
+package benchmarks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/1broseidon/gollm/client"
+	"github.com/1broseidon/gollm/models"
+)
+
+// DefaultModels are the models benchmarked for the built-in providers, unless WithModel sets
+// another. They are the small, fast models of each provider.
+var DefaultModels = map[string]string{
+	"openai":       "gpt-4o-mini",
+	"anthropic":    "claude-3-5-haiku-latest",
+	"googlegemini": "gemini-1.5-flash",
+	"ollama":       "llama3.2",
+}
+
+// Option configures RunProviderBenchmark
+type Option func(*config)
+
+// config holds the settings of a benchmark run
+type config struct {
+	concurrency int
+	models      map[string]string
+	maxTokens   int
+}
+
+// WithConcurrency sets the number of requests sent to each provider at once. The default is 1,
+// which measures the latency of requests sent one after the other.
+func WithConcurrency(n int) Option {
+	return func(c *config) {
+		c.concurrency = n
+	}
+}
+
+// WithModel sets the model benchmarked for provider. Providers without a model, in
+// DefaultModels or set with WithModel, are skipped.
+func WithModel(provider, model string) Option {
+	return func(c *config) {
+		c.models[provider] = model
+	}
+}
+
+// WithMaxTokens caps the tokens generated by each request. Zero, the default, leaves the
+// provider's default.
+func WithMaxTokens(n int) Option {
+	return func(c *config) {
+		c.maxTokens = n
+	}
+}
+
+// BenchmarkResult is the performance of one provider. Latencies are in milliseconds and only
+// count successful requests.
+type BenchmarkResult struct {
+	Provider      string  `json:"provider"`
+	Model         string  `json:"model"`
+	Requests      int     `json:"requests"`
+	MeanLatencyMs float64 `json:"mean_latency_ms"`
+	P50           float64 `json:"p50_ms"`
+	P95           float64 `json:"p95_ms"`
+	P99           float64 `json:"p99_ms"`
+	// ErrorRate is the fraction of requests that failed, from 0 to 1
+	ErrorRate float64 `json:"error_rate"`
+	// TokensPerSecond is the completion tokens of the successful requests divided by their
+	// total latency
+	TokensPerSecond float64 `json:"tokens_per_second"`
+	// Error is the first error, if any request failed
+	Error string `json:"error,omitempty"`
+}
+
+// BenchmarkReport is the result of RunProviderBenchmark, with one result per provider sorted by
+// provider name
+type BenchmarkReport struct {
+	Prompt      string            `json:"prompt"`
+	Iterations  int               `json:"iterations"`
+	Concurrency int               `json:"concurrency"`
+	Results     []BenchmarkResult `json:"results"`
+}
+
+// RunProviderBenchmark sends iterations identical completions of prompt to each provider
+// registered with c, as "provider/model" with the model of DefaultModels or WithModel. The
+// providers are benchmarked at the same time, each with WithConcurrency requests in flight.
+func RunProviderBenchmark(ctx context.Context, c *client.Client, prompt string, iterations int, opts ...Option) BenchmarkReport {
+	cfg := config{concurrency: 1, models: make(map[string]string)}
+	for provider, model := range DefaultModels {
+		cfg.models[provider] = model
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	cfg.concurrency = max(cfg.concurrency, 1)
+
+	report := BenchmarkReport{Prompt: prompt, Iterations: iterations, Concurrency: cfg.concurrency}
+	var providers []string
+	for _, provider := range c.Providers() {
+		if cfg.models[provider] != "" {
+			providers = append(providers, provider)
+		}
+	}
+	report.Results = make([]BenchmarkResult, len(providers))
+
+	var wg sync.WaitGroup
+	for i, provider := range providers {
+		wg.Add(1)
+		go func(i int, provider string) {
+			defer wg.Done()
+			report.Results[i] = benchmarkProvider(ctx, c, provider, cfg.models[provider], prompt, iterations, cfg)
+		}(i, provider)
+	}
+	wg.Wait()
+	return report
+}
+
+// sample is the outcome of one request
+type sample struct {
+	latency time.Duration
+	tokens  int
+	err     error
+}
+
+// benchmarkProvider sends the requests of one provider and summarizes them
+func benchmarkProvider(ctx context.Context, c *client.Client, provider, model, prompt string, iterations int, cfg config) BenchmarkResult {
+	input := models.CompletionInput{
+		Model:     provider + "/" + model,
+		Messages:  []models.ChatMessage{{Role: models.RoleUser, Content: prompt}},
+		MaxTokens: cfg.maxTokens,
+	}
+
+	samples := make([]sample, iterations)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(cfg.concurrency, iterations); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				start := time.Now()
+				resp, err := c.GenerateCompletion(ctx, input)
+				samples[i] = sample{latency: time.Since(start), err: err}
+				if err == nil && resp.Usage != nil {
+					samples[i].tokens = resp.Usage.CompletionTokens
+				}
+			}
+		}()
+	}
+	for i := 0; i < iterations; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return summarize(provider, model, samples)
+}
+
+// summarize computes the result of a provider from the outcomes of its requests, in the order
+// they were sent
+func summarize(provider, model string, samples []sample) BenchmarkResult {
+	result := BenchmarkResult{Provider: provider, Model: model, Requests: len(samples)}
+	var latencies []float64
+	var total time.Duration
+	tokens, failed := 0, 0
+	for _, s := range samples {
+		if s.err != nil {
+			if failed == 0 {
+				result.Error = s.err.Error()
+			}
+			failed++
+			continue
+		}
+		latencies = append(latencies, float64(s.latency)/float64(time.Millisecond))
+		total += s.latency
+		tokens += s.tokens
+	}
+	if len(samples) > 0 {
+		result.ErrorRate = float64(failed) / float64(len(samples))
+	}
+	if len(latencies) == 0 {
+		return result
+	}
+
+	sort.Float64s(latencies)
+	result.MeanLatencyMs = float64(total) / float64(time.Millisecond) / float64(len(latencies))
+	result.P50 = percentile(latencies, 50)
+	result.P95 = percentile(latencies, 95)
+	result.P99 = percentile(latencies, 99)
+	if total > 0 {
+		result.TokensPerSecond = float64(tokens) / total.Seconds()
+	}
+	return result
+}
+
+// percentile returns the p-th percentile of sorted, a non-empty ascending slice, by the
+// nearest-rank method
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+// markdownHeader is the header of the table written by WriteMarkdown
+const markdownHeader = "| Provider | Model | Mean (ms) | P50 (ms) | P95 (ms) | P99 (ms) | Error rate | Tokens/s |\n" +
+	"|---|---|---:|---:|---:|---:|---:|---:|\n"
+
+// WriteMarkdown writes the results as a Markdown table, one row per provider
+func (r BenchmarkReport) WriteMarkdown(w io.Writer) error {
+	if _, err := io.WriteString(w, markdownHeader); err != nil {
+		return err
+	}
+	for _, result := range r.Results {
+		if _, err := fmt.Fprintf(w, "| %s | %s | %.1f | %.1f | %.1f | %.1f | %.1f%% | %.1f |\n",
+			result.Provider, result.Model, result.MeanLatencyMs, result.P50, result.P95, result.P99,
+			result.ErrorRate*100, result.TokensPerSecond); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalJSON encodes the report with its measurements rounded to two decimal places, as the
+// precision beyond is noise
+func (r BenchmarkReport) MarshalJSON() ([]byte, error) {
+	type report BenchmarkReport // Without the MarshalJSON method
+	rounded := report(r)
+	rounded.Results = make([]BenchmarkResult, len(r.Results))
+	for i, result := range r.Results {
+		for _, value := range []*float64{&result.MeanLatencyMs, &result.P50, &result.P95, &result.P99, &result.ErrorRate, &result.TokensPerSecond} {
+			*value = math.Round(*value*100) / 100
+		}
+		rounded.Results[i] = result
+	}
+	return json.Marshal(rounded)
+}
@@ -0,0 +1,149 @@
+package benchmarks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/1broseidon/gollm/client"
+	"github.com/1broseidon/gollm/models"
+)
+
+// sampleReport is a report with made-up measurements
+var sampleReport = BenchmarkReport{
+	Prompt:      "Say hi",
+	Iterations:  20,
+	Concurrency: 2,
+	Results: []BenchmarkResult{
+		{Provider: "anthropic", Model: "claude-3-5-haiku-latest", Requests: 20, MeanLatencyMs: 812.3456, P50: 790.1, P95: 1020.55, P99: 1190.004, TokensPerSecond: 61.23456},
+		{Provider: "openai", Model: "gpt-4o-mini", Requests: 20, MeanLatencyMs: 431.05, P50: 420, P95: 515.5, P99: 601.25, ErrorRate: 0.05, TokensPerSecond: 112.5, Error: "status code: 429"},
+	},
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	if err := sampleReport.WriteMarkdown(&buf); err != nil {
+		t.Fatalf("WriteMarkdown failed: %v", err)
+	}
+	want := "| Provider | Model | Mean (ms) | P50 (ms) | P95 (ms) | P99 (ms) | Error rate | Tokens/s |\n" +
+		"|---|---|---:|---:|---:|---:|---:|---:|\n" +
+		"| anthropic | claude-3-5-haiku-latest | 812.3 | 790.1 | 1020.5 | 1190.0 | 0.0% | 61.2 |\n" +
+		"| openai | gpt-4o-mini | 431.1 | 420.0 | 515.5 | 601.2 | 5.0% | 112.5 |\n"
+	if buf.String() != want {
+		t.Errorf("Unexpected table:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	data, err := json.Marshal(sampleReport)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := `{"prompt":"Say hi","iterations":20,"concurrency":2,"results":[` +
+		`{"provider":"anthropic","model":"claude-3-5-haiku-latest","requests":20,"mean_latency_ms":812.35,"p50_ms":790.1,"p95_ms":1020.55,"p99_ms":1190,"error_rate":0,"tokens_per_second":61.23},` +
+		`{"provider":"openai","model":"gpt-4o-mini","requests":20,"mean_latency_ms":431.05,"p50_ms":420,"p95_ms":515.5,"p99_ms":601.25,"error_rate":0.05,"tokens_per_second":112.5,"error":"status code: 429"}]}`
+	if string(data) != want {
+		t.Errorf("Unexpected JSON:\n%s\nwant:\n%s", data, want)
+	}
+	if sampleReport.Results[0].MeanLatencyMs != 812.3456 {
+		t.Error("Expected MarshalJSON to leave the report unchanged")
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	var samples []sample
+	for i := 1; i <= 100; i++ {
+		samples = append(samples, sample{latency: time.Duration(i) * time.Millisecond, tokens: 10})
+	}
+	samples = append(samples, sample{err: errors.New("first")}, sample{err: errors.New("second")})
+
+	result := summarize("openai", "gpt-4o-mini", samples)
+	if result.P50 != 50 || result.P95 != 95 || result.P99 != 99 || result.MeanLatencyMs != 50.5 {
+		t.Errorf("Unexpected latencies: %+v", result)
+	}
+	if result.Requests != 102 || result.ErrorRate != 2.0/102 || result.Error != "first" {
+		t.Errorf("Unexpected errors: %+v", result)
+	}
+	// 1000 tokens in 5.05 seconds
+	if want := 1000 / 5.05; result.TokensPerSecond < want-1e-9 || result.TokensPerSecond > want+1e-9 {
+		t.Errorf("Expected %.2f tokens per second, got %.2f", want, result.TokensPerSecond)
+	}
+
+	if failed := summarize("openai", "gpt-4o-mini", []sample{{err: errors.New("down")}}); failed.ErrorRate != 1 || failed.P50 != 0 {
+		t.Errorf("Expected only failures to report no latency, got %+v", failed)
+	}
+}
+
+// delayedProvider answers completions after a delay, failing every failEvery-th request if set
+type delayedProvider struct {
+	calls     atomic.Int32
+	inFlight  atomic.Int32
+	maxFlight atomic.Int32
+	failEvery int32
+}
+
+func (p *delayedProvider) GenerateCompletion(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+	n := p.calls.Add(1)
+	flight := p.inFlight.Add(1)
+	defer p.inFlight.Add(-1)
+	for {
+		highest := p.maxFlight.Load()
+		if flight <= highest || p.maxFlight.CompareAndSwap(highest, flight) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	if p.failEvery > 0 && n%p.failEvery == 0 {
+		return nil, errors.New("rate limited")
+	}
+	return &models.CompletionResponse{Text: "hi", Usage: &models.Usage{CompletionTokens: 5}}, nil
+}
+
+func (p *delayedProvider) GenerateCompletionStream(ctx context.Context, modelName string, input models.CompletionInput) (<-chan models.StreamingCompletionResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (p *delayedProvider) Close() error { return nil }
+
+func TestRunProviderBenchmark(t *testing.T) {
+	for _, name := range []string{"OPENAI_API_KEY", "ANTHROPIC_API_KEY", "GEMINI_API_KEY", "OLLAMA_BASE_URL"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("HOME", t.TempDir())
+	c, err := client.NewClient(context.Background())
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer c.Close()
+
+	fast, flaky, unknown := &delayedProvider{}, &delayedProvider{failEvery: 4}, &delayedProvider{}
+	c.RegisterProvider("openai", fast)
+	c.RegisterProvider("custom", flaky)
+	c.RegisterProvider("unknown", unknown)
+
+	report := RunProviderBenchmark(context.Background(), c, "Say hi", 8, WithConcurrency(2), WithModel("custom", "custom-model"))
+	if len(report.Results) != 2 || report.Results[0].Provider != "custom" || report.Results[1].Provider != "openai" {
+		t.Fatalf("Expected results for custom and openai, sorted, got %+v", report.Results)
+	}
+	if custom := report.Results[0]; custom.Model != "custom-model" || custom.ErrorRate != 0.25 || !strings.Contains(custom.Error, "rate limited") {
+		t.Errorf("Unexpected result for the flaky provider: %+v", custom)
+	}
+	openai := report.Results[1]
+	if openai.Model != DefaultModels["openai"] || openai.Requests != 8 || openai.ErrorRate != 0 || openai.P50 < 5 || openai.TokensPerSecond <= 0 {
+		t.Errorf("Unexpected result for the fast provider: %+v", openai)
+	}
+	if calls := fast.calls.Load(); calls != 8 {
+		t.Errorf("Expected 8 requests, got %d", calls)
+	}
+	if flight := fast.maxFlight.Load(); flight > 2 {
+		t.Errorf("Expected 2 requests in flight at most, got %d", flight)
+	}
+	if unknown.calls.Load() != 0 {
+		t.Error("Expected a provider without a model to be skipped")
+	}
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"strings"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// defaultAdaptiveRetries is how many times a completion is re-issued for its ResponseEvaluator
+// by default
+const defaultAdaptiveRetries = 2
+
+// ResponseEvaluator judges completions for WithAdaptiveSampling. Evaluate returns retry true,
+// with the input to send instead, for a response that should be generated again.
+type ResponseEvaluator interface {
+	Evaluate(resp *models.CompletionResponse, input models.CompletionInput) (adjustedInput models.CompletionInput, retry bool)
+}
+
+// ResponseEvaluatorFunc adapts a function to the ResponseEvaluator interface
+type ResponseEvaluatorFunc func(resp *models.CompletionResponse, input models.CompletionInput) (models.CompletionInput, bool)
+
+// Evaluate calls f(resp, input)
+func (f ResponseEvaluatorFunc) Evaluate(resp *models.CompletionResponse, input models.CompletionInput) (models.CompletionInput, bool) {
+	return f(resp, input)
+}
+
+// lengthEvaluator is the ResponseEvaluator returned by LengthEvaluator
+type lengthEvaluator struct {
+	minWords int
+}
+
+const (
+	// lengthEvaluatorStep is how much LengthEvaluator raises the temperature on each retry
+	lengthEvaluatorStep = 0.25
+	// lengthEvaluatorMaxTemperature is the highest temperature LengthEvaluator asks for, the
+	// highest any provider accepts
+	lengthEvaluatorMaxTemperature = 2
+	// providerDefaultTemperature is assumed for requests without a Temperature
+	providerDefaultTemperature = 1
+)
+
+// LengthEvaluator retries responses of fewer than minWords words with a temperature raised by
+// 0.25, from 1 if it was unset, and gives up at a temperature of 2
+func LengthEvaluator(minWords int) ResponseEvaluator {
+	return lengthEvaluator{minWords: minWords}
+}
+
+// Evaluate implements ResponseEvaluator
+func (e lengthEvaluator) Evaluate(resp *models.CompletionResponse, input models.CompletionInput) (models.CompletionInput, bool) {
+	if len(strings.Fields(resp.Text)) >= e.minWords {
+		return input, false
+	}
+	temperature := float32(providerDefaultTemperature)
+	if input.Temperature != nil {
+		temperature = *input.Temperature
+	}
+	if temperature >= lengthEvaluatorMaxTemperature {
+		return input, false
+	}
+	input.Temperature = models.Float32(min(temperature+lengthEvaluatorStep, lengthEvaluatorMaxTemperature))
+	return input, true
+}
+
+// generateAdaptive generates a completion and, with WithAdaptiveSampling, generates it again
+// with the evaluator's adjusted input while it asks for a retry, up to the configured retries.
+// The last response is returned once the retries are used up.
+func (c *Client) generateAdaptive(ctx context.Context, input models.CompletionInput) (*models.CompletionResponse, error) {
+	resp, err := c.generateCompletion(ctx, input)
+	if err != nil || c.evaluator == nil {
+		return resp, err
+	}
+	for retries := 0; retries < c.adaptiveRetries; retries++ {
+		adjusted, retry := c.evaluator.Evaluate(resp, input)
+		if !retry {
+			break
+		}
+		c.logger.Infof("Response evaluator asked for a retry of %s (%d of %d)", input.Model, retries+1, c.adaptiveRetries)
+		input = adjusted
+		if resp, err = c.generateCompletion(ctx, input); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,116 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// temperatureRecorder returns a mock provider answering text and recording the temperature of
+// each request, nil for an unset one
+func temperatureRecorder(text string, temperatures *[]*float32) *mockProvider {
+	return &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			*temperatures = append(*temperatures, input.Temperature)
+			return &models.CompletionResponse{Text: text}, nil
+		},
+	}
+}
+
+func TestLengthEvaluator(t *testing.T) {
+	tests := []struct {
+		name            string
+		text            string
+		temperature     *float32
+		wantRetry       bool
+		wantTemperature float32
+	}{
+		{"LongEnough", "one two three", nil, false, 0},
+		{"TooShort", "one two", models.Float32(0.5), true, 0.75},
+		{"UnsetTemperature", "one", nil, true, 1.25},
+		{"NearMaximum", "one", models.Float32(1.9), true, 2},
+		{"AtMaximum", "one", models.Float32(2), false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := models.CompletionInput{Model: "mock/model", Temperature: tt.temperature}
+			adjusted, retry := LengthEvaluator(3).Evaluate(&models.CompletionResponse{Text: tt.text}, input)
+			if retry != tt.wantRetry {
+				t.Fatalf("Expected retry %v, got %v", tt.wantRetry, retry)
+			}
+			if retry && *adjusted.Temperature != tt.wantTemperature {
+				t.Errorf("Expected temperature %v, got %v", tt.wantTemperature, *adjusted.Temperature)
+			}
+		})
+	}
+}
+
+func TestAdaptiveSampling(t *testing.T) {
+	var temperatures []*float32
+	c := newMockClient(t, "mock", temperatureRecorder("Too short.", &temperatures), WithAdaptiveSampling(LengthEvaluator(5)))
+
+	resp, err := c.GenerateCompletion(context.Background(), models.CompletionInput{Model: "mock/model", Messages: promptOf(3), Temperature: models.Float32(0.5)})
+	if err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+	if resp.Text != "Too short." {
+		t.Errorf("Expected the last response, got %q", resp.Text)
+	}
+	want := []float32{0.5, 0.75, 1}
+	if len(temperatures) != len(want) {
+		t.Fatalf("Expected %d requests with the default retries, got %d", len(want), len(temperatures))
+	}
+	for i, temperature := range want {
+		if *temperatures[i] != temperature {
+			t.Errorf("Expected request %d at temperature %v, got %v", i+1, temperature, *temperatures[i])
+		}
+	}
+}
+
+func TestAdaptiveRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		retries   int
+		text      string
+		wantCalls int
+	}{
+		{"NoRetries", 0, "short", 1},
+		{"UntilEvaluatorGivesUp", 10, "short", 5},
+		{"GoodResponse", 3, "long enough for the evaluator", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var temperatures []*float32
+			c := newMockClient(t, "mock", temperatureRecorder(tt.text, &temperatures),
+				WithAdaptiveSampling(LengthEvaluator(4)), WithAdaptiveRetries(tt.retries))
+
+			// From the default of 1, the evaluator gives up at 2 after 4 retries
+			if _, err := c.GenerateCompletion(context.Background(), models.CompletionInput{Model: "mock/model", Messages: promptOf(3)}); err != nil {
+				t.Fatalf("GenerateCompletion failed: %v", err)
+			}
+			if len(temperatures) != tt.wantCalls {
+				t.Errorf("Expected %d requests, got %d", tt.wantCalls, len(temperatures))
+			}
+		})
+	}
+}
+
+func TestAdaptiveSamplingCustomEvaluator(t *testing.T) {
+	var temperatures []*float32
+	lowerOnRepeat := ResponseEvaluatorFunc(func(resp *models.CompletionResponse, input models.CompletionInput) (models.CompletionInput, bool) {
+		if input.Temperature != nil && *input.Temperature <= 0.2 {
+			return input, false
+		}
+		input.Temperature = models.Float32(0.2)
+		return input, true
+	})
+	c := newMockClient(t, "mock", temperatureRecorder("rambling", &temperatures), WithAdaptiveSampling(lowerOnRepeat))
+
+	if _, err := c.GenerateCompletion(context.Background(), models.CompletionInput{Model: "mock/model", Messages: promptOf(3)}); err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+	if len(temperatures) != 2 || temperatures[0] != nil || *temperatures[1] != 0.2 {
+		t.Errorf("Expected a retry at temperature 0.2, got %v", temperatures)
+	}
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// ToolExecutor executes the tool calls of an agent step for RunAgent. It returns one result per
+// call; a call that failed is reported with IsError set, so that the model can react to it.
+type ToolExecutor interface {
+	Execute(ctx context.Context, calls []models.ToolCall) ([]models.ToolResult, error)
+}
+
+// RunAgent runs an agent loop: it generates a completion and, while the model calls tools, has
+// executor execute the calls, appends the results and asks again. The response that calls no
+// tools is returned, with the usage of all completions. The loop fails with ErrMaxIterations
+// if the model is still calling tools after maxSteps completions; zero or less means 10.
+//
+// Unlike RunTools, the calls of a step are passed to executor together, which decides how to
+// run them. An error from executor ends the loop.
+func (c *Client) RunAgent(ctx context.Context, input models.CompletionInput, executor ToolExecutor, maxSteps int) (*models.CompletionResponse, error) {
+	if maxSteps <= 0 {
+		maxSteps = defaultMaxIterations
+	}
+	// Copy the messages so the caller's slice is never appended to
+	input.Messages = append([]models.ChatMessage(nil), input.Messages...)
+
+	usage := &models.Usage{}
+	for step := 0; step < maxSteps; step++ {
+		resp, err := c.GenerateCompletion(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		usage.Add(resp.Usage)
+		if len(resp.ToolCalls) == 0 {
+			resp.Usage = usage
+			return resp, nil
+		}
+
+		results, err := executor.Execute(ctx, resp.ToolCalls)
+		if err != nil {
+			return nil, fmt.Errorf("step %d: failed to execute tools: %w", step+1, err)
+		}
+		if len(results) != len(resp.ToolCalls) {
+			return nil, fmt.Errorf("step %d: tool executor returned %d results for %d calls", step+1, len(results), len(resp.ToolCalls))
+		}
+
+		input.Messages = append(input.Messages, models.ToolCallMessage(resp.Text, resp.ToolCalls))
+		for _, result := range results {
+			input.Messages = append(input.Messages, result.Message())
+		}
+		// A choice that forces a tool call applies to the first completion only, as in RunTools
+		input.ToolChoice = nil
+	}
+
+	return nil, fmt.Errorf("%w (%d)", ErrMaxIterations, maxSteps)
+}
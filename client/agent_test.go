@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// executorFunc adapts a function to ToolExecutor
+type executorFunc func(ctx context.Context, calls []models.ToolCall) ([]models.ToolResult, error)
+
+func (f executorFunc) Execute(ctx context.Context, calls []models.ToolCall) ([]models.ToolResult, error) {
+	return f(ctx, calls)
+}
+
+func TestRunAgent(t *testing.T) {
+	calls := []models.ToolCall{
+		{ID: "call_1", Name: "search", Arguments: json.RawMessage(`{"query":"gollm"}`)},
+		{ID: "call_2", Name: "fetch", Arguments: json.RawMessage(`{"url":"https://example.com"}`)},
+	}
+	var inputs []models.CompletionInput
+	provider := &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			inputs = append(inputs, input)
+			usage := &models.Usage{PromptTokens: 20, CompletionTokens: 10, TotalTokens: 30}
+			if len(inputs) == 1 {
+				return &models.CompletionResponse{Text: "Let me look.", ToolCalls: calls, Usage: usage}, nil
+			}
+			return &models.CompletionResponse{Text: "gollm is a Go LLM client.", Usage: usage}, nil
+		},
+	}
+	c := newMockClient(t, "mock", provider)
+
+	var executed []models.ToolCall
+	executor := executorFunc(func(ctx context.Context, calls []models.ToolCall) ([]models.ToolResult, error) {
+		executed = append(executed, calls...)
+		return []models.ToolResult{
+			{ToolCallID: "call_1", Content: "gollm: a unified Go client for LLM providers"},
+			{ToolCallID: "call_2", Content: "connection refused", IsError: true},
+		}, nil
+	})
+	messages := []models.ChatMessage{{Role: models.RoleUser, Content: "What is gollm?"}}
+	input := models.CompletionInput{Model: "mock/model", Messages: messages, ToolChoice: &models.ToolChoice{Type: models.ToolChoiceAny}}
+
+	resp, err := c.RunAgent(context.Background(), input, executor, 5)
+	if err != nil {
+		t.Fatalf("RunAgent failed: %v", err)
+	}
+	if resp.Text != "gollm is a Go LLM client." || resp.Usage.TotalTokens != 60 {
+		t.Errorf("Unexpected final response: %+v", resp)
+	}
+	if !reflect.DeepEqual(executed, calls) {
+		t.Errorf("Expected both calls to be executed, got %+v", executed)
+	}
+
+	want := []models.ChatMessage{
+		messages[0],
+		models.ToolCallMessage("Let me look.", calls),
+		{Role: models.RoleTool, Content: "gollm: a unified Go client for LLM providers", ToolCallID: "call_1"},
+		{Role: models.RoleTool, Content: "connection refused", ToolCallID: "call_2", IsError: true},
+	}
+	if len(inputs) != 2 || !reflect.DeepEqual(inputs[1].Messages, want) {
+		t.Fatalf("Expected the calls and results to be sent back, got %+v", inputs)
+	}
+	if inputs[1].ToolChoice != nil {
+		t.Errorf("Expected the forcing tool choice to apply to the first step only, got %+v", inputs[1].ToolChoice)
+	}
+	if len(messages) != 1 {
+		t.Error("Expected the caller's messages to be left unchanged")
+	}
+}
+
+func TestRunAgentFailures(t *testing.T) {
+	provider := &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			return &models.CompletionResponse{ToolCalls: []models.ToolCall{{ID: "call_1", Name: "search"}}}, nil
+		},
+	}
+	c := newMockClient(t, "mock", provider)
+	input := models.CompletionInput{Model: "mock/model", Messages: []models.ChatMessage{{Role: models.RoleUser, Content: "Loop forever"}}}
+	answer := executorFunc(func(ctx context.Context, calls []models.ToolCall) ([]models.ToolResult, error) {
+		return []models.ToolResult{{ToolCallID: calls[0].ID, Content: "nothing found"}}, nil
+	})
+
+	if _, err := c.RunAgent(context.Background(), input, answer, 3); !errors.Is(err, ErrMaxIterations) {
+		t.Errorf("Expected ErrMaxIterations, got %v", err)
+	}
+
+	failure := errors.New("sandbox crashed")
+	fail := executorFunc(func(ctx context.Context, calls []models.ToolCall) ([]models.ToolResult, error) {
+		return nil, failure
+	})
+	if _, err := c.RunAgent(context.Background(), input, fail, 3); !errors.Is(err, failure) {
+		t.Errorf("Expected the executor's error, got %v", err)
+	}
+
+	missing := executorFunc(func(ctx context.Context, calls []models.ToolCall) ([]models.ToolResult, error) {
+		return nil, nil
+	})
+	if _, err := c.RunAgent(context.Background(), input, missing, 3); err == nil || !strings.Contains(err.Error(), "0 results for 1 calls") {
+		t.Errorf("Expected an error for the missing results, got %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/1broseidon/gollm/models"
+	"github.com/1broseidon/gollm/providers/openai"
+)
+
+func TestGenerateCompletionStreamCancelable(t *testing.T) {
+	dropped := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":\"chunk %d \"}}]}\n\n", i)
+			w.(http.Flusher).Flush()
+		}
+		// Keep the stream open until the client goes away
+		<-r.Context().Done()
+		close(dropped)
+	}))
+	defer server.Close()
+	t.Setenv("OPENAI_API_KEY", "test-key")
+	provider, err := openai.NewOpenAIProvider(openai.WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create OpenAI provider: %v", err)
+	}
+	c := newMockClient(t, "openai", provider)
+
+	ctx := context.Background()
+	stream, cancel, err := c.GenerateCompletionStreamCancelable(ctx, models.CompletionInput{Model: "openai/gpt-4o", Messages: promptOf(3)})
+	if err != nil {
+		t.Fatalf("GenerateCompletionStreamCancelable failed: %v", err)
+	}
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		if chunk := <-stream; chunk.Text != fmt.Sprintf("chunk %d ", i) {
+			t.Fatalf("Expected chunk %d, got %+v", i, chunk)
+		}
+	}
+	cancel()
+
+	select {
+	case <-dropped:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the server to see the connection drop")
+	}
+	var last models.StreamingCompletionResponse
+	timeout := time.After(time.Second)
+	for closed := false; !closed; {
+		select {
+		case chunk, ok := <-stream:
+			if !ok {
+				closed = true
+				break
+			}
+			last = chunk
+		case <-timeout:
+			t.Fatal("Expected the stream to close promptly")
+		}
+	}
+	if !last.Done || !errors.Is(last.Error, context.Canceled) {
+		t.Errorf("Expected a final Done chunk reporting the cancellation, got %+v", last)
+	}
+	if ctx.Err() != nil {
+		t.Error("Cancelling the stream cancelled the caller's context")
+	}
+}
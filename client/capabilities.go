@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// CapabilityReporter is implemented by providers that report what they support. The client
+// only dispatches the operations a provider reports, even if it implements their methods.
+type CapabilityReporter interface {
+	Capabilities() models.Capabilities
+}
+
+// Embedder is implemented by providers that generate embeddings
+type Embedder interface {
+	GenerateEmbedding(ctx context.Context, input string) ([]float32, error)
+}
+
+// ChatProvider is implemented by providers that keep chat sessions themselves. SendChatMessage
+// is only given sessions returned by the same provider's StartChat.
+type ChatProvider interface {
+	StartChat(modelName string) models.ProviderChatSession
+	SendChatMessage(ctx context.Context, session models.ProviderChatSession, message string) (*models.CompletionResponse, error)
+}
+
+// SpeechSynthesizer is implemented by providers that convert text to audio
+type SpeechSynthesizer interface {
+	SynthesizeSpeech(ctx context.Context, input models.SpeechInput) (*models.SpeechResponse, error)
+}
+
+// ImageGenerator is implemented by providers that generate images from a prompt
+type ImageGenerator interface {
+	GenerateImage(ctx context.Context, input models.ImageGenerationInput) (*models.ImageGenerationResponse, error)
+}
+
+// Reranker is implemented by providers that score documents by their relevance to a query,
+// such as Cohere, Jina or Voyage rerank models and OpenAI-compatible rerank servers.
+// input.Model is the model name without the provider prefix.
+type Reranker interface {
+	Rerank(ctx context.Context, input models.RerankInput) (*models.RerankResponse, error)
+}
+
+// capabilitiesOf returns the capabilities of p. Providers written against the original Provider
+// interface, which had to implement every operation, don't report them; their capabilities are
+// the optional interfaces they implement, and they are assumed to stream.
+func capabilitiesOf(p Provider) models.Capabilities {
+	if reporter, ok := p.(CapabilityReporter); ok {
+		return reporter.Capabilities()
+	}
+	_, embeddings := p.(Embedder)
+	_, chat := p.(ChatProvider)
+	_, speech := p.(SpeechSynthesizer)
+	_, images := p.(ImageGenerator)
+	_, rerank := p.(Reranker)
+	return models.Capabilities{
+		Streaming:       true,
+		Embeddings:      embeddings,
+		Chat:            chat,
+		Speech:          speech,
+		ImageGeneration: images,
+		Rerank:          rerank,
+	}
+}
+
+// unsupported returns ErrUnsupportedOperation for an operation provider doesn't support
+func unsupported(provider, operation string) error {
+	return fmt.Errorf("%w: the %s provider does not support %s", ErrUnsupportedOperation, provider, operation)
+}
+
+// Capabilities returns what the named provider supports, initializing it if needed
+func (c *Client) Capabilities(ctx context.Context, provider string) (models.Capabilities, error) {
+	p, err := c.initializeProvider(ctx, provider)
+	if err != nil {
+		return models.Capabilities{}, err
+	}
+	return capabilitiesOf(p), nil
+}
@@ -0,0 +1,179 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+	"github.com/1broseidon/gollm/providers/anthropic"
+	"github.com/1broseidon/gollm/providers/googlegemini"
+	"github.com/1broseidon/gollm/providers/ollama"
+	"github.com/1broseidon/gollm/providers/openai"
+)
+
+// The built-in providers implement the optional interfaces the client looks for
+var (
+	_ ModelLister           = (*openai.OpenAIProvider)(nil)
+	_ ModelLister           = (*anthropic.AnthropicProvider)(nil)
+	_ ModelLister           = (*ollama.OllamaProvider)(nil)
+	_ ContextLengthReporter = (*ollama.OllamaProvider)(nil)
+)
+
+// completionOnlyProvider implements only the Provider interface and reports its capabilities
+type completionOnlyProvider struct {
+	capabilities models.Capabilities
+}
+
+func (p *completionOnlyProvider) GenerateCompletion(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+	return &models.CompletionResponse{Text: "hello"}, nil
+}
+
+func (p *completionOnlyProvider) GenerateCompletionStream(ctx context.Context, modelName string, input models.CompletionInput) (<-chan models.StreamingCompletionResponse, error) {
+	return streamChunks(models.StreamingCompletionResponse{Text: "hello", Done: true})(ctx, modelName, input)
+}
+
+func (p *completionOnlyProvider) Capabilities() models.Capabilities { return p.capabilities }
+
+func (p *completionOnlyProvider) Close() error { return nil }
+
+// imageProvider generates images, and embeddings it doesn't report supporting
+type imageProvider struct {
+	completionOnlyProvider
+}
+
+func (p *imageProvider) GenerateImage(ctx context.Context, input models.ImageGenerationInput) (*models.ImageGenerationResponse, error) {
+	return &models.ImageGenerationResponse{Images: []string{"https://example.com/" + input.Prompt + ".png"}}, nil
+}
+
+func (p *imageProvider) GenerateEmbedding(ctx context.Context, input string) ([]float32, error) {
+	return []float32{1}, nil
+}
+
+func TestCapabilitiesOf(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider Provider
+		want     models.Capabilities
+	}{
+		// Providers written for the original interface implement every operation
+		{"Legacy", &mockProvider{}, models.Capabilities{Streaming: true, Embeddings: true, Chat: true, Speech: true}},
+		{"Reported", &imageProvider{completionOnlyProvider{models.Capabilities{ImageGeneration: true}}}, models.Capabilities{ImageGeneration: true}},
+		{"OpenAI", (*openai.OpenAIProvider)(nil), models.Capabilities{Streaming: true, Tools: true, Speech: true}},
+		{"Anthropic", (*anthropic.AnthropicProvider)(nil), models.Capabilities{Streaming: true, Tools: true, Vision: true}},
+		{"GoogleGemini", (*googlegemini.GoogleGeminiProvider)(nil), models.Capabilities{Streaming: true, Vision: true, Chat: true}},
+		{"Ollama", (*ollama.OllamaProvider)(nil), models.Capabilities{Streaming: true, Embeddings: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := capabilitiesOf(tt.provider)
+			if got != tt.want {
+				t.Errorf("Expected capabilities %+v, got %+v", tt.want, got)
+			}
+
+			// A provider must implement the interface of every operation it reports
+			_, embeddings := tt.provider.(Embedder)
+			_, chat := tt.provider.(ChatProvider)
+			_, speech := tt.provider.(SpeechSynthesizer)
+			_, images := tt.provider.(ImageGenerator)
+			_, rerank := tt.provider.(Reranker)
+			if got.Embeddings && !embeddings || got.Chat && !chat || got.Speech && !speech || got.ImageGeneration && !images || got.Rerank && !rerank {
+				t.Errorf("Capabilities %+v report an operation the provider doesn't implement", got)
+			}
+		})
+	}
+}
+
+func TestUnsupportedOperations(t *testing.T) {
+	ctx := context.Background()
+	c := newMockClient(t, "minimal", &completionOnlyProvider{})
+
+	resp, err := c.GenerateCompletion(ctx, models.CompletionInput{Model: "minimal/model", Messages: promptOf(3)})
+	if err != nil || resp.Text != "hello" {
+		t.Fatalf("Expected a completion from a provider implementing only Provider, got %+v, %v", resp, err)
+	}
+
+	operations := map[string]func() error{
+		"Stream": func() error {
+			_, err := c.GenerateCompletionStream(ctx, models.CompletionInput{Model: "minimal/model", Messages: promptOf(3)})
+			return err
+		},
+		"Embedding": func() error {
+			_, err := c.GenerateEmbedding(ctx, "hello")
+			return err
+		},
+		"Speech": func() error {
+			_, err := c.SynthesizeSpeech(ctx, models.SpeechInput{Model: "minimal/voice", Text: "hi"})
+			return err
+		},
+		"StartChat": func() error {
+			_, err := c.StartChat("model")
+			return err
+		},
+		"SendChatMessage": func() error {
+			_, err := c.SendChatMessage(ctx, nil, "hi")
+			return err
+		},
+		"Image": func() error {
+			_, err := c.GenerateImage(ctx, models.ImageGenerationInput{Prompt: "cat"})
+			return err
+		},
+	}
+	for name, operation := range operations {
+		t.Run(name, func(t *testing.T) {
+			if err := operation(); !errors.Is(err, ErrUnsupportedOperation) {
+				t.Errorf("Expected ErrUnsupportedOperation, got %v", err)
+			}
+		})
+	}
+}
+
+func TestStartChat(t *testing.T) {
+	ctx := context.Background()
+	var sessionModel string
+	provider := &mockProvider{chat: func(ctx context.Context, session models.ProviderChatSession, message string) (*models.CompletionResponse, error) {
+		sessionModel = session.Model()
+		return &models.CompletionResponse{Text: "Paris"}, nil
+	}}
+	c := newMockClient(t, "mock", provider)
+
+	if _, err := c.StartChat(""); err == nil {
+		t.Error("Expected an error starting a chat without a model")
+	}
+	session, err := c.StartChat("chat-model")
+	if err != nil {
+		t.Fatalf("StartChat failed: %v", err)
+	}
+	if _, err := c.SendChatMessage(ctx, session, "Capital of France?"); err != nil || sessionModel != "chat-model" {
+		t.Errorf("Expected the session to use the requested model, got %q, %v", sessionModel, err)
+	}
+	if _, err := c.SendChatMessage(ctx, nil, "Capital of France?"); err == nil {
+		t.Error("Expected an error sending to no session")
+	}
+}
+
+func TestGenerateImage(t *testing.T) {
+	ctx := context.Background()
+	c := newMockClient(t, "images", &imageProvider{completionOnlyProvider{models.Capabilities{ImageGeneration: true}}})
+
+	resp, err := c.GenerateImage(ctx, models.ImageGenerationInput{Prompt: "cat", Provider: "images"})
+	if err != nil {
+		t.Fatalf("GenerateImage failed: %v", err)
+	}
+	if len(resp.Images) != 1 || resp.Images[0] != "https://example.com/cat.png" || resp.Provider != "images" {
+		t.Errorf("Unexpected response %+v", resp)
+	}
+
+	// The provider implements GenerateEmbedding but doesn't report it
+	if _, err := c.GenerateEmbedding(ctx, "hello"); !errors.Is(err, ErrUnsupportedOperation) {
+		t.Errorf("Expected ErrUnsupportedOperation for an unreported capability, got %v", err)
+	}
+
+	capabilities, err := c.Capabilities(ctx, "images")
+	if err != nil || !capabilities.ImageGeneration || capabilities.Embeddings {
+		t.Errorf("Expected the reported capabilities, got %+v, %v", capabilities, err)
+	}
+	if _, err := c.Capabilities(ctx, "unknown"); !errors.Is(err, ErrUnsupportedProvider) {
+		t.Errorf("Expected ErrUnsupportedProvider, got %v", err)
+	}
+}
@@ -0,0 +1,302 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/1broseidon/gollm/internal/utils"
+	"github.com/1broseidon/gollm/models"
+)
+
+// ErrTokenBudgetExceeded is returned by ChatSession.Send once the completion budget set with
+// SetTokenBudget is spent
+var ErrTokenBudgetExceeded = errors.New("token budget exceeded")
+
+// DefaultSummaryPrompt asks the model for the summary that replaces the older messages of a
+// ChatSession nearing its prompt budget
+const DefaultSummaryPrompt = "Summarize the conversation so far in a few sentences, keeping the facts, names and decisions needed to continue it."
+
+// Summarizer shortens the history of a ChatSession nearing its prompt budget, returning the
+// messages that replace it
+type Summarizer func(ctx context.Context, history []models.ChatMessage) ([]models.ChatMessage, error)
+
+// ChatSession is a conversation whose history is kept by the client and sent with each
+// message, so it works with every provider. It is safe for concurrent use; messages are sent
+// one at a time.
+type ChatSession struct {
+	client *Client
+	model  string
+
+	mu         sync.Mutex
+	history    []models.ChatMessage
+	system     int // The leading system messages, which are never summarized
+	summarizer Summarizer
+	options    models.CompletionInput // The options sent with each message
+
+	promptBudget     int
+	completionBudget int
+	usage            models.Usage
+	promptWarned     bool
+	completionWarned bool
+}
+
+// NewChatSession starts a conversation with model, given as "provider/model", after the
+// messages of history, such as a system prompt
+func (c *Client) NewChatSession(model string, history ...models.ChatMessage) *ChatSession {
+	s := &ChatSession{client: c, model: model, history: append([]models.ChatMessage(nil), history...)}
+	for s.system < len(s.history) && s.history[s.system].Role == models.RoleSystem {
+		s.system++
+	}
+	s.summarizer = s.summarizeHistory
+	return s
+}
+
+// SetSummarizer replaces the strategy used to shorten the history when it nears the prompt
+// budget. The default asks the model to summarize all but the latest exchange.
+func (s *ChatSession) SetSummarizer(summarizer Summarizer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.summarizer = summarizer
+}
+
+// SetProviderOptions sets the provider options sent with each message, such as the Anthropic
+// computer use tools driven by ComputerUseLoop
+func (s *ChatSession) SetProviderOptions(options models.ProviderOptions) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.options.ProviderOptions = options
+}
+
+// SetCompletionOptions sets the options sent with each message, such as Temperature, TopP,
+// MaxTokens and ProviderOptions, from options. Its Model, Messages and Stream are ignored.
+// MaxTokens is lowered to what is left of the completion budget set with SetTokenBudget.
+func (s *ChatSession) SetCompletionOptions(options models.CompletionInput) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	options.Model, options.Messages, options.Stream = "", nil, false
+	s.options = options
+}
+
+// SetTokenBudget limits the session's tokens. Once the history sent with a message exceeds
+// 90% of promptBudget it is summarized first. completionBudget caps the completion tokens
+// spent over the whole session; Send returns ErrTokenBudgetExceeded once it is spent. A
+// budget of 0 is unlimited.
+func (s *ChatSession) SetTokenBudget(promptBudget, completionBudget int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.promptBudget = promptBudget
+	s.completionBudget = completionBudget
+}
+
+// TotalTokensUsed returns the tokens spent by the session since it started or since
+// ResetBudget, including those spent on summaries
+func (s *ChatSession) TotalTokensUsed() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.usage.TotalTokens
+}
+
+// ResetBudget clears the tokens spent, keeping the budgets and the history
+func (s *ChatSession) ResetBudget() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.usage = models.Usage{}
+	s.promptWarned = false
+	s.completionWarned = false
+}
+
+// History returns a copy of the messages of the conversation
+func (s *ChatSession) History() []models.ChatMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.ChatMessage(nil), s.history...)
+}
+
+// Send sends message with the history and adds it and the reply to the history. A reply
+// calling tools is added with its calls, which ComputerUseLoop answers.
+func (s *ChatSession) Send(ctx context.Context, message string) (*models.CompletionResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkCompletionBudget(); err != nil {
+		return nil, err
+	}
+
+	messages := s.withMessage(message)
+	tokens, err := s.fitPromptBudget(ctx, &messages)
+	if err != nil {
+		return nil, err
+	}
+	return s.complete(ctx, messages, tokens)
+}
+
+// complete generates the reply to messages, of promptTokens tokens, and makes them and the
+// reply the history
+func (s *ChatSession) complete(ctx context.Context, messages []models.ChatMessage, promptTokens int) (*models.CompletionResponse, error) {
+	if err := s.checkCompletionBudget(); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.GenerateCompletion(ctx, s.input(messages))
+	if err != nil {
+		return nil, err
+	}
+	s.record(promptTokens, resp)
+
+	s.history = append(messages, models.ToolCallMessage(resp.Text, resp.ToolCalls))
+	return resp, nil
+}
+
+// SendStream is Send with the reply streamed. The message and the reply are added to the
+// history once the stream ends without an error. Other messages wait until the stream has
+// been read to its end or ctx is done.
+func (s *ChatSession) SendStream(ctx context.Context, message string) (<-chan models.StreamingCompletionResponse, error) {
+	s.mu.Lock()
+	streaming := false
+	defer func() {
+		if !streaming {
+			s.mu.Unlock()
+		}
+	}()
+
+	if err := s.checkCompletionBudget(); err != nil {
+		return nil, err
+	}
+	messages := s.withMessage(message)
+	tokens, err := s.fitPromptBudget(ctx, &messages)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkCompletionBudget(); err != nil {
+		return nil, err
+	}
+
+	input := s.input(messages)
+	input.Stream = true
+	stream, err := s.client.GenerateCompletionStream(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	streaming = true
+	streamChan := utils.NewStream(input.StreamBufferSize)
+	go func() {
+		defer s.mu.Unlock()
+		defer close(streamChan)
+
+		var acc models.StreamAccumulator
+		for chunk := range stream {
+			acc.Add(chunk)
+			if !utils.SendChunk(ctx, streamChan, chunk) {
+				return
+			}
+		}
+		if acc.Err() != nil {
+			return
+		}
+		text, usage, _ := acc.Result()
+		s.record(tokens, &models.CompletionResponse{Text: text, Usage: usage})
+		s.history = append(messages, models.ChatMessage{Role: models.RoleAssistant, Content: text})
+	}()
+	return streamChan, nil
+}
+
+// input returns the completion input sending messages with the session's options
+func (s *ChatSession) input(messages []models.ChatMessage) models.CompletionInput {
+	input := s.options
+	input.Model = s.model
+	input.Messages = messages
+	if s.completionBudget > 0 {
+		// What is left of the budget, which is never more than the budget itself
+		left := s.completionBudget - s.usage.CompletionTokens
+		if input.MaxTokens <= 0 || input.MaxTokens > left {
+			input.MaxTokens = left
+		}
+	}
+	return input
+}
+
+// withMessage returns the history followed by a user message, without changing the history
+func (s *ChatSession) withMessage(message string) []models.ChatMessage {
+	return append(s.history[:len(s.history):len(s.history)], models.ChatMessage{Role: models.RoleUser, Content: message})
+}
+
+// checkCompletionBudget returns ErrTokenBudgetExceeded once the completion budget is spent
+func (s *ChatSession) checkCompletionBudget() error {
+	if s.completionBudget > 0 && s.usage.CompletionTokens >= s.completionBudget {
+		return fmt.Errorf("%w: %d of %d completion tokens used", ErrTokenBudgetExceeded, s.usage.CompletionTokens, s.completionBudget)
+	}
+	return nil
+}
+
+// fitPromptBudget summarizes the history if messages, the history and the new message, exceed
+// 90% of the prompt budget, and returns the tokens of the messages that will be sent
+func (s *ChatSession) fitPromptBudget(ctx context.Context, messages *[]models.ChatMessage) (int, error) {
+	if s.promptBudget <= 0 {
+		return utils.EstimatePromptTokens(*messages), nil
+	}
+
+	tokens, err := s.client.CountTokens(ctx, s.model, *messages)
+	if err != nil {
+		return 0, err
+	}
+	if float64(tokens) > 0.9*float64(s.promptBudget) {
+		s.client.logger.Infof("Chat history is %d tokens of the %d token prompt budget; summarizing it", tokens, s.promptBudget)
+		history, err := s.summarizer(ctx, s.history)
+		if err != nil {
+			return 0, fmt.Errorf("failed to summarize chat history: %w", err)
+		}
+		s.history = history
+		*messages = s.withMessage((*messages)[len(*messages)-1].Content)
+		if tokens, err = s.client.CountTokens(ctx, s.model, *messages); err != nil {
+			return 0, err
+		}
+	}
+
+	if tokens < s.promptBudget*8/10 {
+		s.promptWarned = false
+	} else if !s.promptWarned {
+		s.promptWarned = true
+		s.client.logger.Warnf("Chat prompt is %d tokens, over 80%% of its %d token budget", tokens, s.promptBudget)
+	}
+	return tokens, nil
+}
+
+// record adds the tokens spent by resp to the session's usage. Without usage from the provider
+// they are estimated from the prompt tokens and the reply.
+func (s *ChatSession) record(promptTokens int, resp *models.CompletionResponse) {
+	usage := resp.Usage
+	if usage == nil {
+		completion := utils.EstimateTokens(resp.Text)
+		usage = &models.Usage{PromptTokens: promptTokens, CompletionTokens: completion, TotalTokens: promptTokens + completion}
+	}
+	s.usage.Add(usage)
+
+	if s.completionBudget > 0 && !s.completionWarned && s.usage.CompletionTokens >= s.completionBudget*8/10 {
+		s.completionWarned = true
+		s.client.logger.Warnf("Chat session has used %d of its %d completion tokens", s.usage.CompletionTokens, s.completionBudget)
+	}
+}
+
+// summarizeHistory is the default Summarizer. It asks the model to summarize the messages
+// before the latest exchange and replaces them with a system message holding the summary,
+// keeping the leading system messages.
+func (s *ChatSession) summarizeHistory(ctx context.Context, history []models.ChatMessage) ([]models.ChatMessage, error) {
+	keep := max(s.system, len(history)-2)
+	if keep <= s.system {
+		return history, nil
+	}
+
+	request := append(history[:keep:keep], models.ChatMessage{Role: models.RoleUser, Content: DefaultSummaryPrompt})
+	resp, err := s.client.GenerateCompletion(ctx, models.CompletionInput{Model: s.model, Messages: request})
+	if err != nil {
+		return nil, err
+	}
+	s.record(utils.EstimatePromptTokens(request), resp)
+
+	summarized := append([]models.ChatMessage(nil), history[:s.system]...)
+	summarized = append(summarized, models.ChatMessage{Role: models.RoleSystem, Content: "Summary of the earlier conversation: " + resp.Text})
+	return append(summarized, history[keep:]...), nil
+}
@@ -0,0 +1,191 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/1broseidon/gollm/internal/utils"
+	"github.com/1broseidon/gollm/models"
+)
+
+// countingProvider is a mockProvider that counts 10 tokens for each line of content
+type countingProvider struct {
+	*mockProvider
+}
+
+func (p countingProvider) CountTokens(ctx context.Context, modelName string, content string) (int, error) {
+	return 10 * (strings.Count(content, "\n") + 1), nil
+}
+
+func TestChatSessionSummarizesAtPromptBudget(t *testing.T) {
+	summaries := 0
+	provider := countingProvider{&mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			if input.Messages[len(input.Messages)-1].Content == DefaultSummaryPrompt {
+				summaries++
+				return &models.CompletionResponse{Text: "they said hello"}, nil
+			}
+			return &models.CompletionResponse{Text: "reply"}, nil
+		},
+	}}
+	logger := &recordingLogger{}
+	c := newMockClient(t, "mock", provider, WithLogger(logger))
+
+	session := c.NewChatSession("mock/model", models.ChatMessage{Role: models.RoleSystem, Content: "Be brief."})
+	// 90 tokens trigger a summary and 80 a warning, at 10 tokens a message
+	session.SetTokenBudget(100, 0)
+
+	// The prompt grows by two messages a turn: 20, 40, 60, then 80 tokens
+	for i, message := range []string{"one", "two", "three", "four"} {
+		if _, err := session.Send(context.Background(), message); err != nil {
+			t.Fatalf("Send %d failed: %v", i, err)
+		}
+		if summaries != 0 {
+			t.Fatalf("Expected no summary under 90 tokens, got one at message %d", i)
+		}
+	}
+	if !logger.contains("WARN: ", "80%") {
+		t.Errorf("Expected a warning at 80 tokens, got %v", logger.lines)
+	}
+
+	// 100 tokens are over 90: all but the latest exchange are summarized
+	if _, err := session.Send(context.Background(), "five"); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if summaries != 1 {
+		t.Fatalf("Expected one summary over 90 tokens, got %d", summaries)
+	}
+	history := session.History()
+	want := []string{"Be brief.", "Summary of the earlier conversation: they said hello", "four", "reply", "five", "reply"}
+	if len(history) != len(want) {
+		t.Fatalf("Expected %d messages after the summary, got %+v", len(want), history)
+	}
+	for i, message := range history {
+		if message.Content != want[i] {
+			t.Errorf("Expected message %d to be %q, got %q", i, want[i], message.Content)
+		}
+	}
+	if history[1].Role != models.RoleSystem {
+		t.Errorf("Expected the summary to be a system message, got %q", history[1].Role)
+	}
+}
+
+func TestChatSessionCompletionBudget(t *testing.T) {
+	var maxTokens []int
+	provider := &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			maxTokens = append(maxTokens, input.MaxTokens)
+			return &models.CompletionResponse{Text: "reply", Usage: &models.Usage{PromptTokens: 5, CompletionTokens: 30, TotalTokens: 35}}, nil
+		},
+	}
+	logger := &recordingLogger{}
+	c := newMockClient(t, "mock", provider, WithLogger(logger))
+	session := c.NewChatSession("mock/model")
+	session.SetTokenBudget(0, 100)
+
+	for i := 0; i < 4; i++ {
+		if _, err := session.Send(context.Background(), "hello"); err != nil {
+			t.Fatalf("Send %d failed: %v", i, err)
+		}
+		if i == 1 && logger.contains("WARN: ", "completion tokens") {
+			t.Error("Expected no warning at 60 of 100 completion tokens")
+		}
+	}
+	if _, err := session.Send(context.Background(), "hello"); !errors.Is(err, ErrTokenBudgetExceeded) {
+		t.Errorf("Expected ErrTokenBudgetExceeded after 120 completion tokens, got %v", err)
+	}
+	if !logger.contains("WARN: ", "completion tokens") {
+		t.Errorf("Expected a warning at 90 of 100 completion tokens, got %v", logger.lines)
+	}
+	if want := []int{100, 70, 40, 10}; len(maxTokens) != len(want) || maxTokens[1] != 70 || maxTokens[3] != 10 {
+		t.Errorf("Expected max tokens %v, got %v", want, maxTokens)
+	}
+	if used := session.TotalTokensUsed(); used != 140 {
+		t.Errorf("Expected 140 tokens used, got %d", used)
+	}
+
+	session.ResetBudget()
+	if used := session.TotalTokensUsed(); used != 0 {
+		t.Errorf("Expected no tokens used after ResetBudget, got %d", used)
+	}
+	if _, err := session.Send(context.Background(), "hello"); err != nil {
+		t.Errorf("Expected Send to succeed after ResetBudget, got %v", err)
+	}
+	if maxTokens[len(maxTokens)-1] != 100 {
+		t.Errorf("Expected the whole budget after ResetBudget, got %d", maxTokens[len(maxTokens)-1])
+	}
+	if got := len(session.History()); got != 10 {
+		t.Errorf("Expected ResetBudget to keep the history of 10 messages, got %d", got)
+	}
+}
+
+func TestChatSessionSendStream(t *testing.T) {
+	var inputs []models.CompletionInput
+	failing := false
+	provider := &mockProvider{
+		stream: func(ctx context.Context, modelName string, input models.CompletionInput) (<-chan models.StreamingCompletionResponse, error) {
+			inputs = append(inputs, input)
+			if failing {
+				return streamChunks(models.StreamingCompletionResponse{Text: "par"}, models.StreamingCompletionResponse{Error: errors.New("cut off"), Done: true})(ctx, modelName, input)
+			}
+			return streamChunks(
+				models.StreamingCompletionResponse{Text: "rep"},
+				models.StreamingCompletionResponse{Text: "ly", Usage: &models.Usage{PromptTokens: 5, CompletionTokens: 2, TotalTokens: 7}, Done: true},
+			)(ctx, modelName, input)
+		},
+	}
+	c := newMockClient(t, "mock", provider)
+	session := c.NewChatSession("mock/model")
+	temperature := float32(0.2)
+	session.SetCompletionOptions(models.CompletionInput{Model: "ignored", Temperature: &temperature, MaxTokens: 50})
+	session.SetTokenBudget(0, 20)
+
+	read := func(message string) (string, error) {
+		stream, err := session.SendStream(context.Background(), message)
+		if err != nil {
+			t.Fatalf("SendStream failed: %v", err)
+		}
+		var acc models.StreamAccumulator
+		for chunk := range stream {
+			acc.Add(chunk)
+		}
+		text, _, _ := acc.Result()
+		return text, acc.Err()
+	}
+	if text, err := read("hello"); err != nil || text != "reply" {
+		t.Fatalf("Expected the reply, got %q, %v", text, err)
+	}
+	failing = true
+	if _, err := read("again"); err == nil {
+		t.Fatal("Expected the stream's error")
+	}
+
+	history := session.History()
+	if len(history) != 2 || history[0].Content != "hello" || history[1].Content != "reply" {
+		t.Errorf("Expected only the completed exchange in the history, got %+v", history)
+	}
+	if used := session.TotalTokensUsed(); used != 7 {
+		t.Errorf("Expected the streamed usage to be recorded, got %d tokens", used)
+	}
+	first := inputs[0]
+	if first.Model != "mock/model" || !first.Stream || first.Temperature == nil || *first.Temperature != 0.2 || first.MaxTokens != 20 {
+		t.Errorf("Expected the session's options with max tokens capped by the budget, got %+v", first)
+	}
+	if inputs[1].MaxTokens != 18 {
+		t.Errorf("Expected the budget left after the first reply, got %d", inputs[1].MaxTokens)
+	}
+}
+
+func TestCountTokensEstimate(t *testing.T) {
+	c := newMockClient(t, "mock", &mockProvider{})
+	messages := []models.ChatMessage{{Role: models.RoleUser, Content: "How many tokens is this?"}}
+	tokens, err := c.CountTokens(context.Background(), "mock/model", messages)
+	if err != nil {
+		t.Fatalf("CountTokens failed: %v", err)
+	}
+	if want := utils.EstimatePromptTokens(messages); tokens != want {
+		t.Errorf("Expected the estimate %d for a provider without a tokenizer, got %d", want, tokens)
+	}
+}
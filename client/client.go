@@ -4,11 +4,18 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/1broseidon/gollm/common"
+	"github.com/1broseidon/gollm/embeddings"
+	"github.com/1broseidon/gollm/internal/clock"
+	"github.com/1broseidon/gollm/internal/credentials"
 	"github.com/1broseidon/gollm/internal/logging"
 	"github.com/1broseidon/gollm/models"
 	"github.com/1broseidon/gollm/providers/anthropic"
@@ -17,29 +24,143 @@ import (
 	"github.com/1broseidon/gollm/providers/openai"
 )
 
-// Provider interface defines the methods that each provider must implement
+// Provider interface defines the methods that each provider must implement. Other operations
+// are optional: providers implement Embedder, ChatProvider, SpeechSynthesizer or ImageGenerator
+// for those they support, and report them with CapabilityReporter.
 type Provider interface {
 	GenerateCompletion(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error)
 	GenerateCompletionStream(ctx context.Context, modelName string, input models.CompletionInput) (<-chan models.StreamingCompletionResponse, error)
-	GenerateEmbedding(ctx context.Context, input string) ([]float32, error)
-	StartChat(modelName string) interface{}
-	SendChatMessage(ctx context.Context, session interface{}, message string) (*models.CompletionResponse, error)
 	Close() error
 }
 
 // Client represents the main gollm client
 type Client struct {
-	providers       map[string]Provider
-	defaultProvider string
-	logger          logging.Logger
-	mu              sync.RWMutex
+	providers          map[string]Provider
+	defaultProvider    string
+	requireProvider    bool
+	registrationErrors RegistrationReport
+	hooks              []Hooks
+	transportWrappers  []func(http.RoundTripper) http.RoundTripper
+	logLevel           common.LogLevel
+	requestLogging     bool
+	redactHeaders      []string
+	proxy              string
+	proxyURL           *url.URL
+	requestTimeout     time.Duration
+	transportConfig    TransportConfig
+	ownTransports      bool // each provider gets its own transport
+	transports         []*http.Transport
+	transportMu        sync.Mutex
+	modelPrefixes      map[string]string
+	streamProgressFunc func(chunkIndex int, tokensSoFar int, estimatedFraction float64)
+	guardrails         []Guardrails
+	credentialsFile    string
+	credentials        credentials.File
+	streams            streamTracker
+	limiter            concurrencyLimiter
+	health             healthMonitor
+	models             modelCache
+	closeGracePeriod   time.Duration
+	closeOnce          sync.Once
+	ragEmbedder        string
+	ragTemplate        string
+	limitInputTokens   bool
+	maxInputTokens     int
+	temperaturePolicy  TemperaturePolicy
+	streamChunking     StreamChunking
+	tokenizer          Tokenizer
+	autoTrim           bool
+	maxStreamResumes   int
+	evaluator          ResponseEvaluator
+	adaptiveRetries    int
+	clientSideStop     bool
+	dryRun             bool
+	validateModels     bool
+	defaultMaxTokens   int
+	defaultTemperature *float32
+	clock              clock.Clock
+	logger             logging.Logger
+	mu                 sync.RWMutex
 }
 
-// NewClient creates a new gollm client with automatic provider registration
+// builtinProvider describes a provider the client registers automatically when its environment
+// variable, or the matching entry of the credentials file, is set
+type builtinProvider struct {
+	name    string
+	envVar  string
+	factory func(ctx context.Context, httpClient *http.Client, credential string) (Provider, error)
+}
+
+// builtinProviders lists the providers registered by NewClient, in registration order.
+// httpClient carries the client's shared transport and options; if it is nil the provider
+// uses its own default client. credential is the value of the provider's envVar, read from
+// the environment or the credentials file.
+var builtinProviders = []builtinProvider{
+	{name: "openai", envVar: "OPENAI_API_KEY", factory: func(ctx context.Context, httpClient *http.Client, credential string) (Provider, error) {
+		opts := []openai.OpenAIOption{openai.WithAPIKey(credential)}
+		if httpClient != nil {
+			opts = append(opts, openai.WithHTTPClient(httpClient))
+		}
+		return openai.NewOpenAIProvider(opts...)
+	}},
+	{name: "anthropic", envVar: "ANTHROPIC_API_KEY", factory: func(ctx context.Context, httpClient *http.Client, credential string) (Provider, error) {
+		opts := []anthropic.AnthropicOption{anthropic.WithAPIKey(credential)}
+		if httpClient != nil {
+			opts = append(opts, anthropic.WithHTTPClient(httpClient))
+		}
+		return anthropic.NewAnthropicProvider(opts...)
+	}},
+	{name: "googlegemini", envVar: "GEMINI_API_KEY", factory: func(ctx context.Context, httpClient *http.Client, credential string) (Provider, error) {
+		opts := []googlegemini.GoogleGeminiOption{googlegemini.WithAPIKey(credential)}
+		if httpClient != nil {
+			opts = append(opts, googlegemini.WithHTTPClient(httpClient))
+		}
+		return googlegemini.NewGoogleGeminiProvider(ctx, opts...)
+	}},
+	{name: "ollama", envVar: "OLLAMA_BASE_URL", factory: func(ctx context.Context, httpClient *http.Client, credential string) (Provider, error) {
+		opts := []ollama.OllamaOption{ollama.WithBaseURL(credential)}
+		if httpClient != nil {
+			opts = append(opts, ollama.WithHTTPClient(httpClient))
+		}
+		return ollama.NewOllamaProvider(opts...)
+	}},
+}
+
+// lookupBuiltinProvider returns the built-in provider with the given name
+func lookupBuiltinProvider(name string) (builtinProvider, bool) {
+	for _, bp := range builtinProviders {
+		if bp.name == name {
+			return bp, true
+		}
+	}
+	return builtinProvider{}, false
+}
+
+// providerEnvVarNames returns the environment variables checked during provider registration, sorted by name
+func providerEnvVarNames() []string {
+	names := make([]string, 0, len(builtinProviders))
+	for _, bp := range builtinProviders {
+		names = append(names, bp.envVar)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RegistrationReport maps the name of each provider that failed to register to its error
+type RegistrationReport map[string]error
+
+// NewClient creates a new gollm client with automatic provider registration.
+// A provider that fails to initialize is recorded in RegistrationErrors and skipped;
+// NewClient only fails if no provider could be registered and at least one failed,
+// or if WithRequireProvider is set and any provider failed or none was configured.
 func NewClient(ctx context.Context, options ...ClientOption) (*Client, error) {
 	c := &Client{
-		providers: make(map[string]Provider),
-		logger:    logging.NewDefaultLogger(),
+		providers:          make(map[string]Provider),
+		registrationErrors: make(RegistrationReport),
+		closeGracePeriod:   defaultCloseGracePeriod,
+		adaptiveRetries:    defaultAdaptiveRetries,
+		clock:              clock.Real,
+		logger:             logging.NewDefaultLogger(),
 	}
 
 	// Set default log level to Disabled
@@ -51,86 +172,143 @@ func NewClient(ctx context.Context, options ...ClientOption) (*Client, error) {
 	}
 
 	c.logger.Info("Initializing gollm client")
+	if _, ok := c.tokenizer.(WhitespaceTokenizer); ok {
+		c.logger.Warn("WhitespaceTokenizer counts words, not tokens; token limits and counts may be off by a third or more")
+	}
+
+	// A missing default credentials file is fine, but a file named with WithCredentialsFile must exist
+	credentialsFile := c.credentialsFile
+	if credentialsFile == "" {
+		credentialsFile = credentials.DefaultPath()
+	} else if _, err := os.Stat(credentialsFile); err != nil {
+		return nil, fmt.Errorf("failed to read credentials file: %w", err)
+	}
+	if credentialsFile != "" {
+		file, err := credentials.Load(credentialsFile)
+		if err != nil {
+			return nil, err
+		}
+		c.credentials = file
+	}
+
+	if c.proxy != "" {
+		proxyURL, err := parseProxyURL(c.proxy)
+		if err != nil {
+			return nil, err
+		}
+		c.proxyURL = proxyURL
+	}
 
-	// Register providers
+	// Register providers concurrently; a failure only affects its own provider
 	var wg sync.WaitGroup
-	errChan := make(chan error, 4) // 4 is the number of providers we're registering
+	providers := make([]Provider, len(builtinProviders))
+	errs := make([]error, len(builtinProviders))
+	for i, bp := range builtinProviders {
+		credential := c.credentials.Lookup(bp.envVar)
+		if credential == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, bp builtinProvider) {
+			defer wg.Done()
+			providers[i], errs[i] = bp.factory(ctx, c.httpClient(), credential)
+		}(i, bp)
+	}
+	wg.Wait()
 
-	wg.Add(4)
-	go c.registerOpenAIProvider(&wg, errChan)
-	go c.registerAnthropicProvider(&wg, errChan)
-	go c.registerGoogleGeminiProvider(ctx, &wg, errChan)
-	go c.registerOllamaProvider(&wg, errChan)
+	// Register in a fixed order so the default provider doesn't depend on goroutine scheduling
+	var failures []error
+	for i, bp := range builtinProviders {
+		if errs[i] != nil {
+			c.logger.Warn("Failed to register provider:", bp.name, "error:", errs[i])
+			c.registrationErrors[bp.name] = errs[i]
+			failures = append(failures, fmt.Errorf("%s: %w", bp.name, errs[i]))
+			continue
+		}
+		if providers[i] == nil {
+			continue
+		}
+		c.RegisterProvider(bp.name, providers[i])
+		c.setDefaultProviderIfEmpty(bp.name)
+		c.logger.Info("Registered provider:", bp.name)
+	}
 
-	go func() {
-		wg.Wait()
-		close(errChan)
-	}()
+	if len(failures) > 0 && (c.requireProvider || c.ProviderCount() == 0) {
+		// The client is never returned, so the providers that did register are closed here
+		c.Close()
+		return nil, fmt.Errorf("error during provider registration: %w", errors.Join(failures...))
+	}
 
-	for err := range errChan {
-		if err != nil {
-			return nil, fmt.Errorf("error during provider registration: %w", err)
+	if c.ProviderCount() == 0 {
+		envVars := strings.Join(providerEnvVarNames(), ", ")
+		if c.requireProvider {
+			return nil, fmt.Errorf("%w: set one of %s", ErrNoProvidersConfigured, envVars)
 		}
+		c.logger.Warn("No providers registered; set one of", envVars, "to enable a provider")
 	}
 
 	c.logger.Info("gollm client initialization complete")
 	return c, nil
 }
 
-func (c *Client) registerOpenAIProvider(wg *sync.WaitGroup, errChan chan<- error) {
-	defer wg.Done()
-	if openaiAPIKey := os.Getenv("OPENAI_API_KEY"); openaiAPIKey != "" {
-		openaiProvider, err := openai.NewOpenAIProvider()
-		if err != nil {
-			errChan <- err
-			return
-		}
-		c.RegisterProvider("openai", openaiProvider)
-		c.setDefaultProviderIfEmpty("openai")
-		c.logger.Info("Registered OpenAI provider")
+// debugLogging reports whether the client logs at debug level: set with WithLogLevel, or by a
+// logger given with WithLogger that reports its level with a Level method, as the default
+// logger does
+func (c *Client) debugLogging() bool {
+	if c.logLevel == common.DebugLevel {
+		return true
 	}
+	logger, ok := c.logger.(interface{ Level() common.LogLevel })
+	return ok && logger.Level() == common.DebugLevel
 }
 
-func (c *Client) registerAnthropicProvider(wg *sync.WaitGroup, errChan chan<- error) {
-	defer wg.Done()
-	if anthropicAPIKey := os.Getenv("ANTHROPIC_API_KEY"); anthropicAPIKey != "" {
-		anthropicProvider, err := anthropic.NewAnthropicProvider()
-		if err != nil {
-			errChan <- err
-			return
-		}
-		c.RegisterProvider("anthropic", anthropicProvider)
-		c.setDefaultProviderIfEmpty("anthropic")
-		c.logger.Info("Registered Anthropic provider")
+// httpClient returns the HTTP client handed to the built-in HTTP providers
+func (c *Client) httpClient() *http.Client {
+	var transport http.RoundTripper = c.baseTransport()
+	if c.requestLogging || c.debugLogging() {
+		transport = &RedactingTransport{Base: transport, Logger: c.logger, RedactHeaders: c.redactHeaders}
+	}
+	for _, wrap := range c.transportWrappers {
+		transport = wrap(transport)
+	}
+
+	timeout := c.requestTimeout
+	if timeout == 0 {
+		timeout = defaultRequestTimeout
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
 	}
 }
 
-func (c *Client) registerGoogleGeminiProvider(ctx context.Context, wg *sync.WaitGroup, errChan chan<- error) {
-	defer wg.Done()
-	if geminiAPIKey := os.Getenv("GEMINI_API_KEY"); geminiAPIKey != "" {
-		geminiProvider, err := googlegemini.NewGoogleGeminiProvider(ctx)
-		if err != nil {
-			errChan <- err
-			return
-		}
-		c.RegisterProvider("googlegemini", geminiProvider)
-		c.setDefaultProviderIfEmpty("googlegemini")
-		c.logger.Info("Registered Google Gemini provider")
+// parseProxyURL validates a proxy URL given to WithProxy
+func parseProxyURL(proxy string) (*url.URL, error) {
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid proxy URL %q: unsupported scheme %q", proxy, proxyURL.Scheme)
 	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q: missing host", proxy)
+	}
+	return proxyURL, nil
 }
 
-func (c *Client) registerOllamaProvider(wg *sync.WaitGroup, errChan chan<- error) {
-	defer wg.Done()
-	if ollamaBaseURL := os.Getenv("OLLAMA_BASE_URL"); ollamaBaseURL != "" {
-		ollamaProvider, err := ollama.NewOllamaProvider()
-		if err != nil {
-			errChan <- err
-			return
-		}
-		c.RegisterProvider("ollama", ollamaProvider)
-		c.setDefaultProviderIfEmpty("ollama")
-		c.logger.Info("Registered Ollama provider")
+// RegistrationErrors returns the providers that failed to register during NewClient, keyed by provider name
+func (c *Client) RegistrationErrors() RegistrationReport {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	report := make(RegistrationReport, len(c.registrationErrors))
+	for name, err := range c.registrationErrors {
+		report[name] = err
 	}
+	return report
 }
 
 // setDefaultProviderIfEmpty sets the default provider if it hasn't been set yet
@@ -149,8 +327,54 @@ func (c *Client) RegisterProvider(name string, provider Provider) {
 	c.providers[name] = provider
 }
 
-// Close closes all provider clients
+// ProviderCount returns the number of providers currently registered with the client
+func (c *Client) ProviderCount() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.providers)
+}
+
+// Providers returns the names of the providers currently registered with the client, sorted
+func (c *Client) Providers() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	names := make([]string, 0, len(c.providers))
+	for name := range c.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetProvider returns the provider registered as name, initializing it on first use as
+// GenerateCompletion does. Type-assert it to reach the methods of a specific provider, such as
+// the Files API of *openai.OpenAIProvider.
+func (c *Client) GetProvider(name string) (Provider, error) {
+	return c.initializeProvider(context.Background(), name)
+}
+
+// Stats returns a snapshot of the requests currently in flight to each provider
+func (c *Client) Stats() Stats {
+	return c.limiter.stats()
+}
+
+// Close closes all provider clients. Active streams are cancelled first and given up to
+// the grace period set by WithCloseGracePeriod to finish; their consumers receive a final
+// chunk whose Error is ErrClientClosed. Subsequent calls do nothing and return nil, so
+// providers are never closed twice.
 func (c *Client) Close() error {
+	var err error
+	c.closeOnce.Do(func() { err = c.close() })
+	return err
+}
+
+// close cancels the active streams and closes the providers
+func (c *Client) close() error {
+	c.logger.Debug("Closing active streams")
+	if !c.streams.shutdown(c.closeGracePeriod) {
+		c.logger.Warn("Active streams did not finish within the close grace period")
+	}
+
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
@@ -187,17 +411,34 @@ func (c *Client) Close() error {
 		}
 	}
 
+	c.closeIdleConnections()
+
 	c.logger.Debug("All providers closed")
 	return lastErr
 }
 
 // GenerateCompletion generates a completion using the specified provider and model.
 // It takes a context and a CompletionInput, which should include the provider/model
-// in the format "provider/model" (e.g., "openai/gpt-3.5-turbo").
+// in the format "provider/model" (e.g., "openai/gpt-3.5-turbo"). Model names with a well-known
+// prefix such as "gpt-", "claude-" or "gemini-" may omit the provider.
 // The function returns a CompletionResponse or an error if the generation fails.
 // GenerateCompletion generates a completion based on the provided input.
 // It returns a CompletionResponse and any error encountered during the process.
 func (c *Client) GenerateCompletion(ctx context.Context, input models.CompletionInput) (*models.CompletionResponse, error) {
+	return c.generateAdaptive(ctx, input)
+}
+
+// GenerateText generates a completion for a single user message, as GenerateCompletion does,
+// and returns the whole response with its usage and metadata
+func (c *Client) GenerateText(ctx context.Context, model, prompt string) (*models.CompletionResponse, error) {
+	return c.GenerateCompletion(ctx, models.CompletionInput{
+		Model:    model,
+		Messages: []models.ChatMessage{{Role: models.RoleUser, Content: prompt}},
+	})
+}
+
+// generateCompletion generates a single completion for GenerateCompletion
+func (c *Client) generateCompletion(ctx context.Context, input models.CompletionInput) (*models.CompletionResponse, error) {
 	provider, model, err := c.parseProviderModel(input.Model)
 	if err != nil {
 		c.logger.Error("Failed to parse provider/model", "error", err)
@@ -209,17 +450,72 @@ func (c *Client) GenerateCompletion(ctx context.Context, input models.Completion
 		return nil, err
 	}
 
+	if input.Messages, err = models.NormalizeRoles(input.Messages); err != nil {
+		return nil, err
+	}
+	c.applyDefaults(&input)
+	if err := c.preGuardrails(ctx, &input); err != nil {
+		return nil, err
+	}
+	if err := c.checkTemperature(provider, &input); err != nil {
+		return nil, err
+	}
+	trimmed, err := c.trimHistory(ctx, p, provider, model, &input)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkInputTokens(ctx, p, provider, model, input); err != nil {
+		return nil, err
+	}
+
+	if err := validateToolMessages(p, input.Messages); err != nil {
+		return nil, err
+	}
+	if err := c.checkModel(ctx, p, provider, model); err != nil {
+		return nil, err
+	}
+	c.warnUnsupportedOptions(p, provider, model, input)
+	if c.dryRun {
+		resp := c.dryRunResponse(OperationCompletion, provider, model, input)
+		resp.TrimmedMessages = trimmed
+		return resp, nil
+	}
+
+	timer := newRequestTimer(c.clock)
+	release, err := c.limiter.acquire(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	info := RequestInfo{Operation: OperationCompletion, Provider: provider, Model: model, StartTime: c.clock.Now(), Messages: input.Messages}
+	ctx = c.beforeRequest(ctx, info)
+
 	c.logger.Debugf("Generating completion with provider %s and model %s", provider, model)
-	resp, err := p.GenerateCompletion(ctx, model, input)
+	resp, err := p.GenerateCompletion(timer.start(ctx), model, input)
 	if err != nil {
 		c.logger.Error("Failed to generate completion:", err)
+		c.afterRequest(ctx, info, nil, err)
 		return nil, err
 	}
+	resp.Timing = timer.finish(resp.Timing)
+	resp.Model = servedModel(provider, model, resp.Model)
+	resp.TrimmedMessages = trimmed
+	resp.Usage = c.estimateUsage(resp.Usage, model, input.Messages, resp.Text)
+	if c.clientSideStop {
+		cutAtStop(resp, input.Stop)
+	}
 
+	c.afterRequest(ctx, info, resp.Usage, nil)
+	if err := c.postGuardrails(ctx, resp); err != nil {
+		return nil, err
+	}
 	return resp, nil
 }
 
-// GenerateCompletionStream generates a streaming completion using the specified provider and model
+// GenerateCompletionStream generates a streaming completion using the specified provider and model.
+// Read the stream until it closes, or cancel ctx to abandon it; a stream that is neither read nor
+// cancelled keeps its provider request open until the client is closed.
 func (c *Client) GenerateCompletionStream(ctx context.Context, input models.CompletionInput) (<-chan models.StreamingCompletionResponse, error) {
 	c.logger.Debug("Entering GenerateCompletionStream")
 	provider, model, err := c.parseProviderModel(input.Model)
@@ -235,46 +531,273 @@ func (c *Client) GenerateCompletionStream(ctx context.Context, input models.Comp
 		return nil, err
 	}
 	c.logger.Debug("Provider initialized successfully")
+	if !capabilitiesOf(p).Streaming {
+		return nil, unsupported(provider, "streaming")
+	}
+
+	if input.Messages, err = models.NormalizeRoles(input.Messages); err != nil {
+		return nil, err
+	}
+	c.applyDefaults(&input)
+	if err := c.preGuardrails(ctx, &input); err != nil {
+		return nil, err
+	}
+	if err := c.checkTemperature(provider, &input); err != nil {
+		return nil, err
+	}
+	trimmed, err := c.trimHistory(ctx, p, provider, model, &input)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkInputTokens(ctx, p, provider, model, input); err != nil {
+		return nil, err
+	}
+
+	if err := validateToolMessages(p, input.Messages); err != nil {
+		return nil, err
+	}
+	if err := c.checkModel(ctx, p, provider, model); err != nil {
+		return nil, err
+	}
+	c.warnUnsupportedOptions(p, provider, model, input)
+	if c.dryRun {
+		return c.dryRunStream(provider, model, input), nil
+	}
+
+	timer := newRequestTimer(c.clock)
+	streamCtx, streamDone, err := c.streams.start(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := c.limiter.acquire(streamCtx, provider)
+	if err != nil {
+		streamDone()
+		return nil, err
+	}
+
+	info := RequestInfo{Operation: OperationCompletionStream, Provider: provider, Model: model, StartTime: c.clock.Now(), Messages: input.Messages}
+	streamCtx = c.beforeRequest(streamCtx, info)
 
 	c.logger.Debugf("About to call p.GenerateCompletionStream with provider %s and model %s", provider, model)
-	stream, err := p.GenerateCompletionStream(ctx, model, input)
+	progress := streamProgress{clock: c.clock, maxTokens: input.MaxTokens, start: c.clock.Now()}
+	requestCtx := timer.start(streamCtx)
+	stream, err := p.GenerateCompletionStream(requestCtx, model, input)
 	if err != nil {
 		c.logger.Error("Failed to generate streaming completion:", err)
+		c.afterRequest(streamCtx, info, nil, err)
+		release()
+		streamDone()
 		return nil, fmt.Errorf("failed to generate streaming completion: %w", err)
 	}
 	c.logger.Debug("Streaming completion generated successfully")
 
 	// Add a debug channel to inspect the stream
-	debugStream := make(chan models.StreamingCompletionResponse)
+	debugStream := make(chan models.StreamingCompletionResponse, max(input.StreamBufferSize, 0))
 	go func() {
+		defer streamDone()
+		defer release()
 		defer close(debugStream)
-		for resp := range stream {
-			c.logger.Debugf("Received streaming response: %+v", resp)
-			debugStream <- resp
+
+		var usage *models.Usage
+		var streamErr error
+		defer func() { c.afterRequest(streamCtx, info, usage, streamErr) }()
+
+		abandoned := c.streams.abandoned()
+		streamCancelled := streamCtx.Done()
+		checkResponse := c.hasPostGuardrails()
+		var accumulated streamAccumulator
+		chunker := streamChunker{mode: c.streamChunking}
+		resumer := streamResumer{provider: provider, maxResumes: c.maxStreamResumes}
+		stopper := c.newStopCutter(input.Stop)
+		sentDone := false
+		var partial partialText
+		// generated is the text received from the provider, to estimate the usage it doesn't report
+		var generated strings.Builder
+		for {
+			select {
+			case resp, ok := <-stream:
+				if !ok {
+					if !sentDone && streamCtx.Err() != nil {
+						// The provider stopped sending on cancellation; end the stream with it
+						streamErr = streamCtx.Err()
+						final := models.StreamingCompletionResponse{Error: streamErr, Done: true}
+						partial.add(&final)
+						select {
+						case debugStream <- final:
+						case <-abandoned:
+						}
+					}
+					return
+				}
+				c.logger.Debugf("Received streaming response: %+v", resp)
+				if resp.Error != nil && streamCtx.Err() != nil {
+					// The request failed on cancellation, which the final chunk reports
+					continue
+				}
+				if resp.Error != nil && streamCtx.Err() == nil && resumer.canResume(resp.Error) {
+					resumed, err := p.GenerateCompletionStream(requestCtx, model, resumer.input(input))
+					if err == nil {
+						resumer.resumes++
+						c.logger.Warnf("Stream from %s/%s interrupted, resumed (%d of %d): %v", provider, model, resumer.resumes, resumer.maxResumes, resp.Error)
+						go drainStream(stream)
+						stream = resumed
+						select {
+						case debugStream <- models.StreamingCompletionResponse{Resumed: true, Provider: resp.Provider}:
+						case <-abandoned:
+							go drainStream(stream)
+							return
+						}
+						continue
+					}
+					c.logger.Warn("Failed to resume interrupted stream:", err)
+				}
+				resumer.add(resp)
+				generated.WriteString(resp.Text)
+				if resp.Done && resp.Error == nil {
+					resp.Usage = c.estimateUsage(resp.Usage, model, input.Messages, generated.String())
+				}
+				if resp.Usage != nil {
+					usage = resp.Usage
+				}
+				if resp.Error != nil {
+					streamErr = resp.Error
+				}
+				chunkIndex, tokensSoFar, estimatedFraction := progress.update(resp)
+				if c.streamProgressFunc != nil {
+					c.streamProgressFunc(chunkIndex, tokensSoFar, estimatedFraction)
+				}
+				timer.firstChunk()
+				checked := stopper.add(resp)
+				if checkResponse {
+					var held []models.StreamingCompletionResponse
+					for _, resp := range checked {
+						held = append(held, c.checkStream(streamCtx, &accumulated, resp)...)
+					}
+					checked = held
+				}
+				for _, resp := range checked {
+					if resp.Error != nil {
+						streamErr = resp.Error
+					}
+					if resp.Done {
+						resp.Metrics = progress.metrics()
+						resp.Timing = timer.finish(resp.Timing)
+						resp.Model = servedModel(provider, model, resp.Model)
+						resp.TrimmedMessages = trimmed
+					}
+					for _, chunk := range chunker.add(resp) {
+						partial.add(&chunk)
+						select {
+						case debugStream <- chunk:
+							sentDone = sentDone || chunk.Done
+						case <-abandoned:
+							go drainStream(stream)
+							return
+						}
+					}
+				}
+			case <-streamCancelled:
+				if !errors.Is(context.Cause(streamCtx), ErrClientClosed) {
+					// The caller cancelled; let the provider report it
+					streamCancelled = nil
+					continue
+				}
+				streamErr = ErrClientClosed
+				// Keep the provider's stream draining so Close doesn't close the provider under it
+				drained := make(chan struct{})
+				go func() {
+					drainStream(stream)
+					close(drained)
+				}()
+				final := models.StreamingCompletionResponse{Error: ErrClientClosed, Done: true}
+				partial.add(&final)
+				select {
+				case debugStream <- final:
+				case <-abandoned:
+				}
+				select {
+				case <-drained:
+				case <-abandoned:
+				}
+				return
+			}
 		}
 	}()
 
 	return debugStream, nil
 }
 
+// GenerateCompletionStreamCancelable is GenerateCompletionStream with a cancel function that
+// stops only this stream, leaving ctx to other work. Cancelling aborts the provider's request;
+// the stream then ends with a Done chunk whose Error is context.Canceled, and closes. As with
+// context.WithCancel, call cancel once done with the stream to release its context.
+func (c *Client) GenerateCompletionStreamCancelable(ctx context.Context, input models.CompletionInput) (<-chan models.StreamingCompletionResponse, context.CancelFunc, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	stream, err := c.GenerateCompletionStream(ctx, input)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	return stream, cancel, nil
+}
+
+// drainStream discards the remaining chunks of a provider stream so its goroutine can exit
+func drainStream(stream <-chan models.StreamingCompletionResponse) {
+	for range stream {
+	}
+}
+
 // GenerateEmbedding generates an embedding using the default provider
 func (c *Client) GenerateEmbedding(ctx context.Context, input string) ([]float32, error) {
+	name, provider, err := c.defaultProviderInstance()
+	if err != nil {
+		return nil, err
+	}
+	return c.generateEmbedding(ctx, name, provider, input)
+}
+
+// defaultProviderInstance returns the name of the default provider and the provider. The
+// client's lock is released on return, so that callers don't hold it while waiting on the
+// concurrency limiter or the network.
+func (c *Client) defaultProviderInstance() (string, Provider, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	if c.defaultProvider == "" {
 		c.logger.Error("No default provider set")
-		return nil, errors.New("no default provider set")
+		return "", nil, errors.New("no default provider set")
 	}
-
 	provider, ok := c.providers[c.defaultProvider]
 	if !ok {
 		c.logger.Error("Unsupported default provider:", c.defaultProvider)
-		return nil, ErrUnsupportedProvider
+		return "", nil, ErrUnsupportedProvider
+	}
+	return c.defaultProvider, provider, nil
+}
+
+// generateEmbedding generates an embedding with the named provider
+func (c *Client) generateEmbedding(ctx context.Context, name string, provider Provider, input string) ([]float32, error) {
+	if err := c.checkDryRun(OperationEmbedding); err != nil {
+		return nil, err
+	}
+	embedder, ok := provider.(Embedder)
+	if !ok || !capabilitiesOf(provider).Embeddings {
+		return nil, unsupported(name, "embeddings")
 	}
 
-	c.logger.Debugf("Generating embedding with default provider %s", c.defaultProvider)
-	embedding, err := provider.GenerateEmbedding(ctx, input)
+	release, err := c.limiter.acquire(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	info := RequestInfo{Operation: OperationEmbedding, Provider: name, StartTime: c.clock.Now()}
+	ctx = c.beforeRequest(ctx, info)
+
+	c.logger.Debugf("Generating embedding with provider %s", name)
+	embedding, err := embedder.GenerateEmbedding(ctx, input)
+	c.afterRequest(ctx, info, nil, err)
 	if err != nil {
 		c.logger.Error("Failed to generate embedding:", err)
 		return nil, err
@@ -283,62 +806,351 @@ func (c *Client) GenerateEmbedding(ctx context.Context, input string) ([]float32
 	return embedding, nil
 }
 
-// StartChat starts a new chat session using the default provider
-func (c *Client) StartChat() (interface{}, error) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+// Embed embeds input.Text with the provider named by input.Provider, or the default provider if
+// it is empty, then normalizes and quantizes the embedding as input asks. Providers embed with
+// their own embedding model, such as OLLAMA_EMBED_MODEL, so input.Model is not sent.
+func (c *Client) Embed(ctx context.Context, input models.EmbeddingInput) (*models.EmbeddingResponse, error) {
+	if input.Quantize != "" && input.Quantize != models.QuantizeInt8 && input.Quantize != models.QuantizeBinary {
+		return nil, fmt.Errorf("unsupported quantization %q", input.Quantize)
+	}
 
-	if c.defaultProvider == "" {
-		c.logger.Error("No default provider set")
-		return nil, errors.New("no default provider set")
+	name := input.Provider
+	if name == "" {
+		c.mu.RLock()
+		name = c.defaultProvider
+		c.mu.RUnlock()
+		if name == "" {
+			c.logger.Error("No default provider set")
+			return nil, errors.New("no default provider set")
+		}
+	}
+	p, err := c.initializeProvider(ctx, name)
+	if err != nil {
+		return nil, err
 	}
 
-	provider, ok := c.providers[c.defaultProvider]
-	if !ok {
-		c.logger.Error("Unsupported default provider:", c.defaultProvider)
-		return nil, ErrUnsupportedProvider
+	embedding, err := c.generateEmbedding(ctx, name, p, input.Text)
+	if err != nil {
+		return nil, err
+	}
+	resp := &models.EmbeddingResponse{Embedding: embedding, Provider: name}
+	if input.Normalize {
+		resp.Embedding = embeddings.Normalize(resp.Embedding)
+	}
+	switch input.Quantize {
+	case models.QuantizeInt8:
+		resp.VectorInt8, resp.Scale = embeddings.QuantizeInt8(resp.Embedding)
+	case models.QuantizeBinary:
+		resp.VectorBinary = embeddings.QuantizeBinary(resp.Embedding)
+	}
+	return resp, nil
+}
+
+// SynthesizeSpeech converts text to audio. input.Model selects the provider and model in
+// "provider/model" form (e.g. "openai/tts-1"); a bare provider name such as "openai" uses
+// that provider's default model, and an empty Model uses the default provider.
+func (c *Client) SynthesizeSpeech(ctx context.Context, input models.SpeechInput) (*models.SpeechResponse, error) {
+	provider, model := input.Model, ""
+	if strings.Contains(input.Model, "/") {
+		var err error
+		provider, model, err = c.parseProviderModel(input.Model)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse provider/model: %w", err)
+		}
+	}
+	if provider == "" {
+		c.mu.RLock()
+		provider = c.defaultProvider
+		c.mu.RUnlock()
+		if provider == "" {
+			c.logger.Error("No default provider set")
+			return nil, errors.New("no default provider set")
+		}
 	}
 
-	c.logger.Debugf("Starting chat session with default provider %s", c.defaultProvider)
-	session := provider.StartChat(c.defaultProvider)
-	return session, nil
+	p, err := c.initializeProvider(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+	synthesizer, ok := p.(SpeechSynthesizer)
+	if !ok || !capabilitiesOf(p).Speech {
+		return nil, unsupported(provider, "speech synthesis")
+	}
+	if err := c.checkDryRun("speech"); err != nil {
+		return nil, err
+	}
+
+	release, err := c.limiter.acquire(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	input.Model = model
+	c.logger.Debugf("Synthesizing speech with provider %s", provider)
+	resp, err := synthesizer.SynthesizeSpeech(ctx, input)
+	if err != nil {
+		c.logger.Error("Failed to synthesize speech:", err)
+		return nil, err
+	}
+
+	resp.Provider = provider
+	return resp, nil
 }
 
-// SendChatMessage sends a message to an existing chat session using the default provider
-func (c *Client) SendChatMessage(ctx context.Context, session interface{}, message string) (*models.CompletionResponse, error) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+// GenerateImage generates images from input.Prompt with the provider named by input.Provider, or
+// the default provider if it is empty. None of the built-in providers generate images; it serves
+// registered providers that implement ImageGenerator.
+func (c *Client) GenerateImage(ctx context.Context, input models.ImageGenerationInput) (*models.ImageGenerationResponse, error) {
+	provider := input.Provider
+	if provider == "" {
+		c.mu.RLock()
+		provider = c.defaultProvider
+		c.mu.RUnlock()
+		if provider == "" {
+			c.logger.Error("No default provider set")
+			return nil, errors.New("no default provider set")
+		}
+	}
 
-	if c.defaultProvider == "" {
-		c.logger.Error("No default provider set")
-		return nil, errors.New("no default provider set")
+	p, err := c.initializeProvider(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+	generator, ok := p.(ImageGenerator)
+	if !ok || !capabilitiesOf(p).ImageGeneration {
+		return nil, unsupported(provider, "image generation")
+	}
+	if err := c.checkDryRun("image"); err != nil {
+		return nil, err
 	}
 
-	provider, ok := c.providers[c.defaultProvider]
+	release, err := c.limiter.acquire(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	c.logger.Debugf("Generating images with provider %s", provider)
+	resp, err := generator.GenerateImage(ctx, input)
+	if err != nil {
+		c.logger.Error("Failed to generate images:", err)
+		return nil, err
+	}
+
+	resp.Provider = provider
+	return resp, nil
+}
+
+// Moderator is implemented by providers that can screen text against a content policy
+type Moderator interface {
+	Moderate(ctx context.Context, text string) (*models.ModerationResult, error)
+}
+
+// Moderate screens text with OpenAI's moderation endpoint, e.g. to check user input before
+// sending it in a completion. It requires the openai provider.
+func (c *Client) Moderate(ctx context.Context, text string) (*models.ModerationResult, error) {
+	const provider = "openai"
+	p, err := c.initializeProvider(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+	moderator, ok := p.(Moderator)
 	if !ok {
-		c.logger.Error("Unsupported default provider:", c.defaultProvider)
-		return nil, ErrUnsupportedProvider
+		return nil, fmt.Errorf("%w: the %s provider does not support moderation", ErrUnsupportedOperation, provider)
+	}
+	if err := c.checkDryRun("moderation"); err != nil {
+		return nil, err
+	}
+
+	release, err := c.limiter.acquire(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	c.logger.Debugf("Moderating text with provider %s", provider)
+	result, err := moderator.Moderate(ctx, text)
+	if err != nil {
+		c.logger.Error("Failed to moderate text:", err)
+		return nil, err
+	}
+
+	result.Provider = provider
+	return result, nil
+}
+
+// AnthropicBatch submits inputs to Anthropic's Message Batches API and returns the batch ID.
+// Each input's Model is an Anthropic model, optionally prefixed with "anthropic/". Use the
+// GetBatchStatus and GetBatchResults methods of anthropic.AnthropicProvider to collect the results.
+func (c *Client) AnthropicBatch(ctx context.Context, inputs []models.CompletionInput) (string, error) {
+	p, err := c.initializeProvider(ctx, "anthropic")
+	if err != nil {
+		return "", err
+	}
+	batcher, ok := p.(*anthropic.AnthropicProvider)
+	if !ok {
+		return "", fmt.Errorf("%w: the anthropic provider does not support batches", ErrUnsupportedOperation)
+	}
+	if err := c.checkDryRun("batch"); err != nil {
+		return "", err
+	}
+
+	requests := make([]models.CompletionInput, len(inputs))
+	for i, input := range inputs {
+		input.Model = strings.TrimPrefix(input.Model, "anthropic/")
+		requests[i] = input
 	}
 
-	c.logger.Debugf("Sending chat message with default provider %s", c.defaultProvider)
-	resp, err := provider.SendChatMessage(ctx, session, message)
+	c.logger.Debugf("Creating Anthropic batch with %d requests", len(requests))
+	batchID, err := batcher.CreateBatch(ctx, requests)
+	if err != nil {
+		c.logger.Error("Failed to create batch:", err)
+		return "", err
+	}
+	return batchID, nil
+}
+
+// StartChat starts a new chat session with modelName, a model of the default provider
+func (c *Client) StartChat(modelName string) (models.ProviderChatSession, error) {
+	if modelName == "" {
+		return nil, errors.New("a model is required to start a chat session")
+	}
+	name, provider, err := c.defaultProviderInstance()
+	if err != nil {
+		return nil, err
+	}
+	chat, ok := provider.(ChatProvider)
+	if !ok || !capabilitiesOf(provider).Chat {
+		return nil, unsupported(name, "chat sessions")
+	}
+
+	c.logger.Debugf("Starting chat session with default provider %s and model %s", name, modelName)
+	return chat.StartChat(modelName), nil
+}
+
+// SendChatMessage sends a message to a chat session started with StartChat
+func (c *Client) SendChatMessage(ctx context.Context, session models.ProviderChatSession, message string) (*models.CompletionResponse, error) {
+	name, provider, err := c.defaultProviderInstance()
+	if err != nil {
+		return nil, err
+	}
+	chat, ok := provider.(ChatProvider)
+	if !ok || !capabilitiesOf(provider).Chat {
+		return nil, unsupported(name, "chat sessions")
+	}
+	if session == nil {
+		return nil, errors.New("no chat session given; start one with StartChat")
+	}
+	model := session.Model()
+
+	input := models.CompletionInput{Messages: []models.ChatMessage{{Role: "user", Content: message}}}
+	if err := c.preGuardrails(ctx, &input); err != nil {
+		return nil, err
+	}
+	if len(input.Messages) > 0 {
+		message = input.Messages[len(input.Messages)-1].Content
+	}
+	if c.dryRun {
+		return c.dryRunResponse(OperationChat, name, model, input), nil
+	}
+
+	timer := newRequestTimer(c.clock)
+	release, err := c.limiter.acquire(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	info := RequestInfo{
+		Operation: OperationChat,
+		Provider:  name,
+		Model:     model,
+		StartTime: c.clock.Now(),
+		Messages:  []models.ChatMessage{{Role: "user", Content: message}},
+	}
+	ctx = c.beforeRequest(ctx, info)
+
+	c.logger.Debugf("Sending chat message with default provider %s", name)
+	resp, err := chat.SendChatMessage(timer.start(ctx), session, message)
 	if err != nil {
 		c.logger.Error("Failed to send chat message:", err)
+		c.afterRequest(ctx, info, nil, err)
+		return nil, err
+	}
+	resp.Timing = timer.finish(resp.Timing)
+	resp.Usage = c.estimateUsage(resp.Usage, model, info.Messages, resp.Text)
+	c.afterRequest(ctx, info, resp.Usage, nil)
+
+	if err := c.postGuardrails(ctx, resp); err != nil {
 		return nil, err
 	}
 
 	return resp, nil
 }
 
+// warnUnsupportedOptions logs provider options that the selected model will ignore, and defaults it fills in
+func (c *Client) warnUnsupportedOptions(p Provider, provider, model string, input models.CompletionInput) {
+	capabilities := capabilitiesOf(p)
+	if provider == "googlegemini" && input.ProviderOptions.GoogleGemini.ThinkingBudget != nil && !googlegemini.SupportsThinking(model) {
+		c.logger.Warnf("Thinking mode is only available for gemini-2.0-flash-thinking-exp models; %s will not return thinking text", model)
+	}
+	if !capabilities.Tools && (len(input.Tools) > 0 || input.ToolChoice != nil) {
+		c.logger.Warnf("Tools are not supported by the %s provider; it will not call them", provider)
+	}
+	if provider == "anthropic" && input.MaxTokens == 0 {
+		c.logger.Debugf("MaxTokens is not set; the anthropic provider defaults to %d tokens", anthropic.DefaultMaxTokens)
+	}
+	if !capabilities.Vision && hasContentParts(input.Messages) {
+		c.logger.Warnf("ContentParts are not supported by the %s provider; it will only receive the message text", provider)
+	}
+	if provider != "ollama" && len(input.ProviderOptions.Ollama.Format) > 0 {
+		c.logger.Warnf("Format is only supported by the ollama provider; %s will not constrain its output", provider)
+	}
+}
+
+// validateToolMessages checks the tool round-trips of messages for providers that call tools,
+// which reject malformed ones; the others send tool results as text
+func validateToolMessages(p Provider, messages []models.ChatMessage) error {
+	if !capabilitiesOf(p).Tools {
+		return nil
+	}
+	return models.ValidateToolMessages(messages)
+}
+
+// hasContentParts reports whether any of messages has content parts
+func hasContentParts(messages []models.ChatMessage) bool {
+	for _, message := range messages {
+		if len(message.ContentParts) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// servedModel returns the model that served a request in the "provider/model" form, preferring
+// the model reported by the provider over the requested one
+func servedModel(provider, requested, reported string) string {
+	if reported == "" {
+		reported = requested
+	}
+	return provider + "/" + reported
+}
+
 // parseProviderModel splits the providerModel string into provider and model components.
-// It returns an error if the string is not in the correct "provider/model" format.
+// A bare model name such as "gpt-4o" is assigned a provider by its prefix; otherwise it
+// returns an error if the string is not in the correct "provider/model" format.
 func (c *Client) parseProviderModel(providerModel string) (string, string, error) {
 	parts := strings.SplitN(providerModel, "/", 2)
 	if len(parts) != 2 {
+		if provider, ok := c.inferProvider(providerModel); ok {
+			return provider, providerModel, nil
+		}
 		return "", "", errors.New("invalid provider/model format")
 	}
 	return parts[0], parts[1], nil
 }
+
 // initializeProvider initializes and registers a specific provider
 func (c *Client) initializeProvider(ctx context.Context, providerName string) (Provider, error) {
 	c.mu.Lock()
@@ -351,42 +1163,25 @@ func (c *Client) initializeProvider(ctx context.Context, providerName string) (P
 
 	var provider Provider
 	var err error
-
-	switch providerName {
-	case "openai":
-		if openaiAPIKey := os.Getenv("OPENAI_API_KEY"); openaiAPIKey != "" {
-			provider, err = openai.NewOpenAIProvider()
-		} else {
-			err = errors.New("OPENAI_API_KEY not set")
-		}
-	case "anthropic":
-		if anthropicAPIKey := os.Getenv("ANTHROPIC_API_KEY"); anthropicAPIKey != "" {
-			provider, err = anthropic.NewAnthropicProvider()
-		} else {
-			err = errors.New("ANTHROPIC_API_KEY not set")
-		}
-	case "googlegemini":
-		if geminiAPIKey := os.Getenv("GEMINI_API_KEY"); geminiAPIKey != "" {
-			provider, err = googlegemini.NewGoogleGeminiProvider(ctx)
-		} else {
-			err = errors.New("GEMINI_API_KEY not set")
-		}
-	case "ollama":
-		if ollamaBaseURL := os.Getenv("OLLAMA_BASE_URL"); ollamaBaseURL != "" {
-			provider, err = ollama.NewOllamaProvider()
-		} else {
-			err = errors.New("OLLAMA_BASE_URL not set")
+	if bp, ok := lookupBuiltinProvider(providerName); ok {
+		credential := c.credentials.Lookup(bp.envVar)
+		if credential == "" {
+			return nil, fmt.Errorf("failed to initialize provider %s: %s not set; set it to enable the %s provider", providerName, bp.envVar, providerName)
 		}
-	default:
+		provider, err = bp.factory(ctx, c.httpClient(), credential)
+	} else if factory, ok := lookupProviderFactory(providerName); ok {
+		provider, err = factory(ctx)
+	} else {
 		return nil, ErrUnsupportedProvider
 	}
-
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize provider %s: %w", providerName, err)
 	}
 
 	c.providers[providerName] = provider
-	c.setDefaultProviderIfEmpty(providerName)
+	if c.defaultProvider == "" {
+		c.defaultProvider = providerName
+	}
 	c.logger.Infof("Successfully initialized and registered provider: %s", providerName)
 
 	return provider, nil
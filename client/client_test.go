@@ -0,0 +1,543 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/1broseidon/gollm/common"
+	"github.com/1broseidon/gollm/models"
+	"github.com/1broseidon/gollm/providers/googlegemini"
+)
+
+// recordingLogger captures log output so tests can assert on it
+type recordingLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *recordingLogger) record(prefix string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, prefix+fmt.Sprintln(args...))
+}
+
+func (l *recordingLogger) Debug(args ...interface{}) { l.record("DEBUG: ", args...) }
+func (l *recordingLogger) Debugf(format string, args ...interface{}) {
+	l.record("DEBUG: ", fmt.Sprintf(format, args...))
+}
+func (l *recordingLogger) Info(args ...interface{}) { l.record("INFO: ", args...) }
+func (l *recordingLogger) Infof(format string, args ...interface{}) {
+	l.record("INFO: ", fmt.Sprintf(format, args...))
+}
+func (l *recordingLogger) Warn(args ...interface{}) { l.record("WARN: ", args...) }
+func (l *recordingLogger) Warnf(format string, args ...interface{}) {
+	l.record("WARN: ", fmt.Sprintf(format, args...))
+}
+func (l *recordingLogger) Error(args ...interface{}) { l.record("ERROR: ", args...) }
+func (l *recordingLogger) Errorf(format string, args ...interface{}) {
+	l.record("ERROR: ", fmt.Sprintf(format, args...))
+}
+func (l *recordingLogger) SetLevel(level common.LogLevel) {}
+
+func (l *recordingLogger) contains(prefix, substr string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, line := range l.lines {
+		if strings.HasPrefix(line, prefix) && strings.Contains(line, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clearProviderEnv unsets every provider environment variable for the duration of the test
+func clearProviderEnv(t *testing.T) {
+	t.Helper()
+	for _, envVar := range providerEnvVarNames() {
+		t.Setenv(envVar, "")
+	}
+}
+
+func TestNewClientWithoutProviders(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Strict", func(t *testing.T) {
+		clearProviderEnv(t)
+
+		c, err := NewClient(ctx, WithRequireProvider())
+		if err == nil {
+			c.Close()
+			t.Fatal("Expected NewClient to fail with no providers configured")
+		}
+		if !errors.Is(err, ErrNoProvidersConfigured) {
+			t.Errorf("Expected ErrNoProvidersConfigured, got: %v", err)
+		}
+		for _, envVar := range providerEnvVarNames() {
+			if !strings.Contains(err.Error(), envVar) {
+				t.Errorf("Error %q does not mention %s", err, envVar)
+			}
+		}
+	})
+
+	t.Run("Lenient", func(t *testing.T) {
+		clearProviderEnv(t)
+
+		logger := &recordingLogger{}
+		c, err := NewClient(ctx, WithLogger(logger))
+		if err != nil {
+			t.Fatalf("NewClient failed: %v", err)
+		}
+		defer c.Close()
+
+		if count := c.ProviderCount(); count != 0 {
+			t.Errorf("Expected 0 providers, got %d", count)
+		}
+		if !logger.contains("WARN: ", "OPENAI_API_KEY") {
+			t.Error("Expected a warning listing the provider environment variables")
+		}
+	})
+
+	t.Run("InitializeProviderNamesEnvVar", func(t *testing.T) {
+		clearProviderEnv(t)
+
+		c, err := NewClient(ctx)
+		if err != nil {
+			t.Fatalf("NewClient failed: %v", err)
+		}
+		defer c.Close()
+
+		_, err = c.initializeProvider(ctx, "anthropic")
+		if err == nil {
+			t.Fatal("Expected initializeProvider to fail without ANTHROPIC_API_KEY")
+		}
+		if !strings.Contains(err.Error(), "ANTHROPIC_API_KEY") {
+			t.Errorf("Error %q does not mention ANTHROPIC_API_KEY", err)
+		}
+	})
+}
+
+// withBuiltinProviders replaces the built-in provider table for the duration of the test
+func withBuiltinProviders(t *testing.T, providers ...builtinProvider) {
+	t.Helper()
+	original := builtinProviders
+	builtinProviders = providers
+	t.Cleanup(func() { builtinProviders = original })
+}
+
+func TestNewClientPartialRegistration(t *testing.T) {
+	ctx := context.Background()
+	errDial := errors.New("dial failed")
+
+	healthy := builtinProvider{name: "healthy", envVar: "GOLLM_TEST_HEALTHY", factory: func(ctx context.Context, httpClient *http.Client, credential string) (Provider, error) {
+		return &mockProvider{}, nil
+	}}
+	failing := builtinProvider{name: "failing", envVar: "GOLLM_TEST_FAILING", factory: func(ctx context.Context, httpClient *http.Client, credential string) (Provider, error) {
+		return nil, errDial
+	}}
+	alsoFailing := builtinProvider{name: "alsofailing", envVar: "GOLLM_TEST_ALSO_FAILING", factory: func(ctx context.Context, httpClient *http.Client, credential string) (Provider, error) {
+		return nil, errors.New("bad credentials")
+	}}
+
+	t.Run("KeepsHealthyProviders", func(t *testing.T) {
+		withBuiltinProviders(t, failing, healthy)
+		t.Setenv("GOLLM_TEST_HEALTHY", "1")
+		t.Setenv("GOLLM_TEST_FAILING", "1")
+
+		c, err := NewClient(ctx, WithLogger(&recordingLogger{}))
+		if err != nil {
+			t.Fatalf("NewClient failed: %v", err)
+		}
+		defer c.Close()
+
+		if count := c.ProviderCount(); count != 1 {
+			t.Errorf("Expected 1 registered provider, got %d", count)
+		}
+		if names := c.Providers(); len(names) != 1 || names[0] != "healthy" {
+			t.Errorf("Expected the healthy provider to be registered, got %v", names)
+		}
+		if p, err := c.GetProvider("healthy"); err != nil || p == nil {
+			t.Errorf("Expected GetProvider to return the healthy provider, got %v, %v", p, err)
+		}
+		if _, err := c.GetProvider("failing"); !errors.Is(err, errDial) {
+			t.Errorf("Expected GetProvider to retry the failing provider, got %v", err)
+		}
+		if _, err := c.GetProvider("nonexistent"); !errors.Is(err, ErrUnsupportedProvider) {
+			t.Errorf("Expected ErrUnsupportedProvider, got %v", err)
+		}
+		if c.defaultProvider != "healthy" {
+			t.Errorf("Expected default provider healthy, got %q", c.defaultProvider)
+		}
+		report := c.RegistrationErrors()
+		if len(report) != 1 || !errors.Is(report["failing"], errDial) {
+			t.Errorf("Unexpected registration report: %v", report)
+		}
+	})
+
+	t.Run("StrictFailsOnAnyError", func(t *testing.T) {
+		closed := 0
+		closing := builtinProvider{name: "healthy", envVar: "GOLLM_TEST_HEALTHY", factory: func(ctx context.Context, httpClient *http.Client, credential string) (Provider, error) {
+			return &mockProvider{closeFunc: func() error { closed++; return nil }}, nil
+		}}
+		withBuiltinProviders(t, failing, closing)
+		t.Setenv("GOLLM_TEST_HEALTHY", "1")
+		t.Setenv("GOLLM_TEST_FAILING", "1")
+
+		c, err := NewClient(ctx, WithLogger(&recordingLogger{}), WithRequireProvider())
+		if err == nil {
+			c.Close()
+			t.Fatal("Expected NewClient to fail in strict mode")
+		}
+		if !errors.Is(err, errDial) {
+			t.Errorf("Expected the factory error to be wrapped, got: %v", err)
+		}
+		if closed != 1 {
+			t.Errorf("Expected the registered provider to be closed once, got %d", closed)
+		}
+	})
+
+	t.Run("AllFailedJoinsErrors", func(t *testing.T) {
+		withBuiltinProviders(t, failing, alsoFailing)
+		t.Setenv("GOLLM_TEST_FAILING", "1")
+		t.Setenv("GOLLM_TEST_ALSO_FAILING", "1")
+
+		c, err := NewClient(ctx, WithLogger(&recordingLogger{}))
+		if err == nil {
+			c.Close()
+			t.Fatal("Expected NewClient to fail when every provider failed")
+		}
+		if !errors.Is(err, errDial) || !strings.Contains(err.Error(), "bad credentials") {
+			t.Errorf("Expected both registration errors, got: %v", err)
+		}
+	})
+}
+
+// roundTripperFunc adapts a function to http.RoundTripper
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestTransportWrapper(t *testing.T) {
+	c := &Client{}
+
+	var order []string
+	wrapper := func(name string) func(http.RoundTripper) http.RoundTripper {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				return nil, errors.New("stop")
+			})
+		}
+	}
+	WithTransportWrapper(wrapper("inner"))(c)
+	WithTransportWrapper(wrapper("outer"))(c)
+
+	httpClient := c.httpClient()
+	req, _ := http.NewRequest("GET", "http://example.invalid", nil)
+	httpClient.Transport.RoundTrip(req)
+	if len(order) != 1 || order[0] != "outer" {
+		t.Errorf("Expected the last wrapper to be outermost, got %v", order)
+	}
+}
+
+func TestTransportWrapperGeminiUpload(t *testing.T) {
+	gemini, _ := lookupBuiltinProvider("googlegemini")
+	withBuiltinProviders(t, gemini)
+	t.Setenv("GEMINI_API_KEY", "test-key")
+
+	var paths []string
+	wrapper := func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			paths = append(paths, req.URL.Path)
+			return nil, errors.New("stop")
+		})
+	}
+	c, err := NewClient(context.Background(), WithLogger(&recordingLogger{}), WithTransportWrapper(wrapper))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer c.Close()
+
+	provider, err := c.GetProvider("googlegemini")
+	if err != nil {
+		t.Fatalf("GetProvider failed: %v", err)
+	}
+	provider.(*googlegemini.GoogleGeminiProvider).UploadFile(context.Background(), strings.NewReader("%PDF"), "application/pdf", "doc.pdf")
+	if len(paths) != 1 || paths[0] != "/upload/v1beta/files" {
+		t.Errorf("Expected the wrapper to see the upload, got %v", paths)
+	}
+}
+
+func TestSynthesizeSpeechUnsupported(t *testing.T) {
+	c := newMockClient(t, "mock", &mockProvider{})
+
+	_, err := c.SynthesizeSpeech(context.Background(), models.SpeechInput{Model: "mock/voice", Text: "hi"})
+	if !errors.Is(err, ErrUnsupportedOperation) {
+		t.Errorf("Expected ErrUnsupportedOperation, got %v", err)
+	}
+}
+
+func TestWithProxy(t *testing.T) {
+	clearProviderEnv(t)
+
+	var proxiedHost string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxiedHost = r.Host
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer proxy.Close()
+
+	c, err := NewClient(context.Background(), WithLogger(&recordingLogger{}), WithProxy(proxy.URL), WithRequestTimeout(5*time.Second))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer c.Close()
+
+	httpClient := c.httpClient()
+	if httpClient.Timeout != 5*time.Second {
+		t.Errorf("Expected the request timeout to be kept alongside the proxy, got %v", httpClient.Timeout)
+	}
+
+	resp, err := httpClient.Get("http://api.example.invalid/v1/models")
+	if err != nil {
+		t.Fatalf("Request through proxy failed: %v", err)
+	}
+	resp.Body.Close()
+	if proxiedHost != "api.example.invalid" {
+		t.Errorf("Expected the request to go through the proxy, proxy saw host %q", proxiedHost)
+	}
+
+	for _, invalid := range []string{"ftp://proxy.example.com", "http://", "://bad"} {
+		if _, err := NewClient(context.Background(), WithLogger(&recordingLogger{}), WithProxy(invalid)); err == nil {
+			t.Errorf("Expected NewClient to reject proxy URL %q", invalid)
+		}
+	}
+}
+
+func TestModelPrefixInference(t *testing.T) {
+	ctx := context.Background()
+
+	var gotModel string
+	provider := &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			gotModel = modelName
+			return &models.CompletionResponse{Text: "ok"}, nil
+		},
+	}
+	c := newMockClient(t, "openai", provider, WithModelPrefix("mistral", "openai"), WithModelPrefix("gpt-4o-audio", "anthropic"))
+
+	for _, model := range []string{"gpt-4o-mini", "o1-preview", "Mistral-7B"} {
+		if _, err := c.GenerateCompletion(ctx, models.CompletionInput{Model: model}); err != nil {
+			t.Errorf("GenerateCompletion(%q) failed: %v", model, err)
+		} else if gotModel != model {
+			t.Errorf("Expected model %q to be passed unchanged, got %q", model, gotModel)
+		}
+	}
+
+	tests := map[string]string{
+		"claude-3-5-sonnet": "anthropic",
+		"gemini-1.5-pro":    "googlegemini",
+		"llama3.1:8b":       "ollama",
+	}
+	for model, want := range tests {
+		if got, ok := c.inferProvider(model); !ok || got != want {
+			t.Errorf("inferProvider(%q) = %q, %v; want %q", model, got, ok, want)
+		}
+	}
+
+	// Unknown and ambiguous names keep the format error
+	for _, model := range []string{"command-r", "gpt-4o-audio-preview"} {
+		if _, err := c.GenerateCompletion(ctx, models.CompletionInput{Model: model}); err == nil || !strings.Contains(err.Error(), "invalid provider/model format") {
+			t.Errorf("Expected a format error for %q, got %v", model, err)
+		}
+	}
+}
+
+func TestResponseModel(t *testing.T) {
+	ctx := context.Background()
+
+	var reported string
+	provider := &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			return &models.CompletionResponse{Text: "ok", Model: reported}, nil
+		},
+		stream: func(ctx context.Context, modelName string, input models.CompletionInput) (<-chan models.StreamingCompletionResponse, error) {
+			return streamChunks(
+				models.StreamingCompletionResponse{Text: "ok"},
+				models.StreamingCompletionResponse{Done: true, Model: reported},
+			)(ctx, modelName, input)
+		},
+	}
+	c := newMockClient(t, "openai", provider)
+
+	tests := []struct {
+		requested, reported, want string
+	}{
+		{"openai/gpt-4o", "gpt-4o-2024-08-06", "openai/gpt-4o-2024-08-06"},
+		{"openai/gpt-4o", "", "openai/gpt-4o"},
+		{"gpt-4o-mini", "", "openai/gpt-4o-mini"},
+	}
+	for _, tt := range tests {
+		reported = tt.reported
+		resp, err := c.GenerateCompletion(ctx, models.CompletionInput{Model: tt.requested})
+		if err != nil {
+			t.Fatalf("GenerateCompletion failed: %v", err)
+		}
+		if resp.Model != tt.want {
+			t.Errorf("Expected model %q for %q reported as %q, got %q", tt.want, tt.requested, tt.reported, resp.Model)
+		}
+
+		stream, err := c.GenerateCompletionStream(ctx, models.CompletionInput{Model: tt.requested})
+		if err != nil {
+			t.Fatalf("GenerateCompletionStream failed: %v", err)
+		}
+		for chunk := range stream {
+			if chunk.Done && chunk.Model != tt.want {
+				t.Errorf("Expected model %q on the Done chunk, got %q", tt.want, chunk.Model)
+			} else if !chunk.Done && chunk.Model != "" {
+				t.Errorf("Expected the model only on the Done chunk, got %q", chunk.Model)
+			}
+		}
+	}
+}
+
+// moderatingProvider is a mock provider that also implements Moderator
+type moderatingProvider struct {
+	mockProvider
+}
+
+func (m *moderatingProvider) Moderate(ctx context.Context, text string) (*models.ModerationResult, error) {
+	return &models.ModerationResult{Flagged: text == "bad", Categories: map[string]bool{"harassment": text == "bad"}}, nil
+}
+
+func TestModerate(t *testing.T) {
+	ctx := context.Background()
+
+	c := newMockClient(t, "openai", &moderatingProvider{})
+	result, err := c.Moderate(ctx, "bad")
+	if err != nil {
+		t.Fatalf("Moderate failed: %v", err)
+	}
+	if !result.Flagged || result.Provider != "openai" {
+		t.Errorf("Unexpected moderation result: %+v", result)
+	}
+
+	c = newMockClient(t, "openai", &mockProvider{})
+	if _, err := c.Moderate(ctx, "bad"); !errors.Is(err, ErrUnsupportedOperation) {
+		t.Errorf("Expected ErrUnsupportedOperation, got %v", err)
+	}
+}
+
+func TestWithCredentialsFile(t *testing.T) {
+	ctx := context.Background()
+
+	var received string
+	fromFile := builtinProvider{name: "fromfile", envVar: "GOLLM_TEST_API_KEY", factory: func(ctx context.Context, httpClient *http.Client, credential string) (Provider, error) {
+		received = credential
+		return &mockProvider{}, nil
+	}}
+	withBuiltinProviders(t, fromFile)
+
+	path := filepath.Join(t.TempDir(), "credentials")
+	if err := os.WriteFile(path, []byte(`{"gollm_test_api_key": "key-from-file"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("GOLLM_TEST_API_KEY", "")
+	c, err := NewClient(ctx, WithLogger(&recordingLogger{}), WithCredentialsFile(path))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	c.Close()
+	if received != "key-from-file" {
+		t.Errorf("Expected the key from the credentials file, got %q", received)
+	}
+
+	t.Setenv("GOLLM_TEST_API_KEY", "key-from-env")
+	c, err = NewClient(ctx, WithLogger(&recordingLogger{}), WithCredentialsFile(path))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	c.Close()
+	if received != "key-from-env" {
+		t.Errorf("Expected the environment to take precedence, got %q", received)
+	}
+
+	if _, err := NewClient(ctx, WithLogger(&recordingLogger{}), WithCredentialsFile(path+".missing")); err == nil {
+		t.Error("Expected an error for a missing credentials file")
+	}
+	if err := os.WriteFile(path, []byte(`gollm_test_api_key = unquoted`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewClient(ctx, WithLogger(&recordingLogger{}), WithCredentialsFile(path)); err == nil {
+		t.Error("Expected an error for a malformed credentials file")
+	}
+}
+
+func TestRoleNormalization(t *testing.T) {
+	var sent []models.ChatMessage
+	provider := &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			sent = input.Messages
+			return &models.CompletionResponse{Text: "ok"}, nil
+		},
+	}
+	c := newMockClient(t, "mock", provider)
+
+	input := models.CompletionInput{Model: "mock/model", Messages: []models.ChatMessage{
+		{Role: "System", Content: "Be brief."},
+		{Role: "USER", Content: "Hi"},
+		{Role: "model", Content: "Hello"},
+		{Role: "user", Content: "Bye"},
+	}}
+	if _, err := c.GenerateCompletion(context.Background(), input); err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+	want := []string{models.RoleSystem, models.RoleUser, models.RoleAssistant, models.RoleUser}
+	for i, role := range want {
+		if sent[i].Role != role {
+			t.Errorf("Expected message %d to be sent with role %q, got %q", i, role, sent[i].Role)
+		}
+	}
+
+	input.Messages = []models.ChatMessage{{Role: "narrator", Content: "Once upon a time"}}
+	if _, err := c.GenerateCompletion(context.Background(), input); !errors.Is(err, models.ErrInvalidRole) {
+		t.Errorf("Expected ErrInvalidRole for an unknown role, got %v", err)
+	}
+	if _, err := c.GenerateCompletionStream(context.Background(), input); !errors.Is(err, models.ErrInvalidRole) {
+		t.Errorf("Expected ErrInvalidRole for a stream, got %v", err)
+	}
+}
+
+func TestGenerateText(t *testing.T) {
+	var sent models.CompletionInput
+	provider := &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			sent = input
+			return &models.CompletionResponse{Text: "Paris", Usage: &models.Usage{PromptTokens: 7, CompletionTokens: 1, TotalTokens: 8}}, nil
+		},
+	}
+	c := newMockClient(t, "mock", provider, WithDefaultMaxTokens(50))
+
+	resp, err := c.GenerateText(context.Background(), "mock/model", "Capital of France?")
+	if err != nil {
+		t.Fatalf("GenerateText failed: %v", err)
+	}
+	if len(sent.Messages) != 1 || sent.Messages[0].Role != models.RoleUser || sent.Messages[0].Content != "Capital of France?" {
+		t.Errorf("Expected a single user message, got %+v", sent.Messages)
+	}
+	if sent.MaxTokens != 50 {
+		t.Errorf("Expected the client defaults to apply, got MaxTokens %d", sent.MaxTokens)
+	}
+	if resp.Text != "Paris" || resp.Usage == nil || resp.Usage.TotalTokens != 8 || resp.Model != "mock/model" || resp.Timing == nil {
+		t.Errorf("Expected the full response with usage and metadata, got %+v", resp)
+	}
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/1broseidon/gollm/internal/utils"
+	"github.com/1broseidon/gollm/models"
+)
+
+// maxComputerUseIterations caps the completions made by ComputerUseLoop. Computer use takes
+// many more steps than most tool loops, one action per completion.
+const maxComputerUseIterations = 50
+
+// ComputerUseLoop drives a computer use conversation in session, whose provider options offer
+// the tools with AnthropicOptions.ComputerUseTools. While the latest reply calls tools, action
+// performs them, such as taking a screenshot or running a command, and its output is sent back
+// as the result of each call. The history should end with that reply, returned by Send, or
+// with a user message, which is answered first.
+//
+// The loop ends with the first reply that calls no tools, which is returned, or fails with
+// ErrMaxIterations after 50 completions. An error from action is sent to the model as the
+// result, so that it can try another way, as with RunTools. The history isn't summarized
+// during the loop, as that would separate tool calls from their results.
+func ComputerUseLoop(ctx context.Context, session *ChatSession, action func(calls []models.ToolCall) (string, error)) (*models.CompletionResponse, error) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	n := len(session.history)
+	if n == 0 || session.history[n-1].Role == models.RoleAssistant && len(session.history[n-1].ToolCalls) == 0 {
+		return nil, errors.New("chat session has no tool calls or message to answer")
+	}
+
+	for iteration := 0; iteration < maxComputerUseIterations; iteration++ {
+		n := len(session.history)
+		messages := session.history[:n:n]
+		if last := messages[n-1]; last.Role == models.RoleAssistant {
+			output, err := action(last.ToolCalls)
+			if err != nil {
+				session.client.logger.Warnf("Computer use action failed: %v", err)
+				output = "Error: " + err.Error()
+			}
+			for _, call := range last.ToolCalls {
+				messages = append(messages, models.ToolResultMessage(call.ID, output))
+			}
+		}
+
+		resp, err := session.complete(ctx, messages, utils.EstimatePromptTokens(messages))
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.ToolCalls) == 0 {
+			return resp, nil
+		}
+	}
+	return nil, fmt.Errorf("%w (%d)", ErrMaxIterations, maxComputerUseIterations)
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+	"github.com/1broseidon/gollm/providers/anthropic"
+)
+
+func TestComputerUseLoop(t *testing.T) {
+	replies := []string{
+		`{"type":"tool_use","id":"toolu_1","name":"bash","input":{"command":"ls"}}`,
+		`{"type":"tool_use","id":"toolu_2","name":"computer","input":{"action":"screenshot"}}`,
+		`{"type":"text","text":"The folder holds notes.txt."}`,
+	}
+	var results []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if beta := r.Header.Get("anthropic-beta"); beta != "computer-use-2024-10-22" {
+			t.Errorf("Expected the computer use beta, got %q", beta)
+		}
+		var request struct {
+			Messages []struct {
+				Content json.RawMessage `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Invalid request body: %v", err)
+		}
+		results = append(results, string(request.Messages[len(request.Messages)-1].Content))
+		fmt.Fprintf(w, `{"content":[%s],"usage":{"input_tokens":100,"output_tokens":10}}`, replies[len(results)-1])
+	}))
+	defer server.Close()
+	t.Setenv("ANTHROPIC_API_KEY", "test-key")
+	provider, err := anthropic.NewAnthropicProvider(anthropic.WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create Anthropic provider: %v", err)
+	}
+	c := newMockClient(t, "anthropic", provider)
+
+	session := c.NewChatSession("anthropic/claude-3-5-sonnet-latest")
+	session.SetProviderOptions(models.ProviderOptions{Anthropic: models.AnthropicOptions{
+		ComputerUseTools: []string{models.ComputerUseComputer, models.ComputerUseBash},
+	}})
+	resp, err := session.Send(context.Background(), "What is in the folder?")
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "bash" {
+		t.Fatalf("Expected a bash call, got %+v", resp.ToolCalls)
+	}
+
+	var actions []string
+	final, err := ComputerUseLoop(context.Background(), session, func(calls []models.ToolCall) (string, error) {
+		actions = append(actions, calls[0].Name)
+		if calls[0].Name == "computer" {
+			return "", errors.New("no display")
+		}
+		return "notes.txt", nil
+	})
+	if err != nil {
+		t.Fatalf("ComputerUseLoop failed: %v", err)
+	}
+	if final.Text != "The folder holds notes.txt." || strings.Join(actions, ",") != "bash,computer" {
+		t.Errorf("Expected both actions and the final answer, got %v and %+v", actions, final)
+	}
+	if len(results) != 3 || !strings.Contains(results[1], `"tool_use_id":"toolu_1","content":"notes.txt"`) ||
+		!strings.Contains(results[2], `"tool_use_id":"toolu_2","content":"Error: no display"`) {
+		t.Errorf("Expected the action outputs as tool results, got %q", results)
+	}
+	if history := session.History(); len(history) != 6 || history[5].Content != final.Text {
+		t.Errorf("Expected the calls, results and answer in the history, got %+v", history)
+	}
+
+	if _, err := ComputerUseLoop(context.Background(), session, nil); err == nil {
+		t.Error("Expected an error when the latest reply calls no tools")
+	}
+}
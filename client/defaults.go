@@ -0,0 +1,15 @@
+package client
+
+import "github.com/1broseidon/gollm/models"
+
+// applyDefaults fills in the MaxTokens and Temperature left unset in input with the defaults of
+// WithDefaultMaxTokens and WithDefaultTemperature
+func (c *Client) applyDefaults(input *models.CompletionInput) {
+	if input.MaxTokens == 0 {
+		input.MaxTokens = c.defaultMaxTokens
+	}
+	if input.Temperature == nil && c.defaultTemperature != nil {
+		// A new pointer, so neither the caller's input nor the default can be changed through it
+		input.Temperature = models.Float32(*c.defaultTemperature)
+	}
+}
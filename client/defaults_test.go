@@ -0,0 +1,73 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+func TestDefaultSamplingOptions(t *testing.T) {
+	tests := []struct {
+		name            string
+		input           models.CompletionInput
+		wantMaxTokens   int
+		wantTemperature float32
+	}{
+		{"Unset", models.CompletionInput{}, 300, 0.4},
+		{"Set", models.CompletionInput{MaxTokens: 50, Temperature: models.Float32(0.9)}, 50, 0.9},
+		{"ZeroTemperature", models.CompletionInput{Temperature: models.Float32(0)}, 300, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent []models.CompletionInput
+			provider := &mockProvider{
+				completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+					sent = append(sent, input)
+					return &models.CompletionResponse{Text: "ok"}, nil
+				},
+				stream: func(ctx context.Context, modelName string, input models.CompletionInput) (<-chan models.StreamingCompletionResponse, error) {
+					sent = append(sent, input)
+					return streamChunks(models.StreamingCompletionResponse{Text: "ok", Done: true})(ctx, modelName, input)
+				},
+			}
+			c := newMockClient(t, "mock", provider, WithDefaultMaxTokens(300), WithDefaultTemperature(0.4))
+
+			tt.input.Model = "mock/model"
+			tt.input.Messages = promptOf(3)
+			if _, err := c.GenerateCompletion(context.Background(), tt.input); err != nil {
+				t.Fatalf("GenerateCompletion failed: %v", err)
+			}
+			stream, err := c.GenerateCompletionStream(context.Background(), tt.input)
+			if err != nil {
+				t.Fatalf("GenerateCompletionStream failed: %v", err)
+			}
+			for range stream {
+			}
+
+			for _, input := range sent {
+				if input.MaxTokens != tt.wantMaxTokens || input.Temperature == nil || *input.Temperature != tt.wantTemperature {
+					t.Errorf("Expected MaxTokens %d and temperature %v, got %d and %v", tt.wantMaxTokens, tt.wantTemperature, input.MaxTokens, input.Temperature)
+				}
+			}
+		})
+	}
+}
+
+func TestNoSamplingDefaults(t *testing.T) {
+	var sent models.CompletionInput
+	provider := &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			sent = input
+			return &models.CompletionResponse{Text: "ok"}, nil
+		},
+	}
+	c := newMockClient(t, "mock", provider)
+
+	if _, err := c.GenerateCompletion(context.Background(), models.CompletionInput{Model: "mock/model", Messages: promptOf(3)}); err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+	if sent.MaxTokens != 0 || sent.Temperature != nil {
+		t.Errorf("Expected the provider's defaults to be left, got MaxTokens %d and temperature %v", sent.MaxTokens, sent.Temperature)
+	}
+}
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"errors"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// DryRunText is the text of every response returned in dry-run mode
+const DryRunText = "[DRY RUN]"
+
+// ErrDryRun is returned in dry-run mode by operations that can't be simulated, such as embeddings
+// and speech synthesis
+var ErrDryRun = errors.New("operation not available in dry-run mode")
+
+// dryRunResponse logs the completion request that would be sent to provider and returns the
+// response standing in for it, with the prompt's estimated usage
+func (c *Client) dryRunResponse(operation, provider, model string, input models.CompletionInput) *models.CompletionResponse {
+	name := provider
+	if model != "" {
+		name += "/" + model
+	}
+	usage := c.estimateUsage(nil, model, input.Messages, "")
+	c.logger.Infof("Dry run: %s request to %s not sent: %d messages, about %d prompt tokens, MaxTokens %d, %d tools",
+		operation, name, len(input.Messages), usage.PromptTokens, input.MaxTokens, len(input.Tools))
+	return &models.CompletionResponse{Text: DryRunText, Usage: usage, Model: name}
+}
+
+// dryRunStream returns a stream of the single Done chunk standing in for a streamed response
+func (c *Client) dryRunStream(provider, model string, input models.CompletionInput) <-chan models.StreamingCompletionResponse {
+	resp := c.dryRunResponse(OperationCompletionStream, provider, model, input)
+	stream := make(chan models.StreamingCompletionResponse, 1)
+	stream <- models.StreamingCompletionResponse{Text: resp.Text, Done: true, Usage: resp.Usage, Model: resp.Model}
+	close(stream)
+	return stream
+}
+
+// checkDryRun returns ErrDryRun in dry-run mode, for operations it can't simulate
+func (c *Client) checkDryRun(operation string) error {
+	if !c.dryRun {
+		return nil
+	}
+	c.logger.Infof("Dry run: %s request not sent", operation)
+	return ErrDryRun
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+func TestDryRun(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		t.Errorf("Unexpected request in dry-run mode: %s %s", r.Method, r.URL)
+	}))
+	defer server.Close()
+
+	clearProviderEnv(t)
+	t.Setenv("OLLAMA_BASE_URL", server.URL)
+	ctx := context.Background()
+	logger := &recordingLogger{}
+	c, err := NewClient(ctx, WithDryRun(), WithLogger(logger))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer c.Close()
+
+	input := models.CompletionInput{Model: "ollama/llama3.1", Messages: promptOf(12), MaxTokens: 100}
+	resp, err := c.GenerateCompletion(ctx, input)
+	if err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+	if resp.Text != DryRunText || resp.Model != "ollama/llama3.1" {
+		t.Errorf("Expected the dry-run response, got %+v", resp)
+	}
+	if resp.Usage == nil || resp.Usage.PromptTokens == 0 || resp.Usage.CompletionTokens != 0 || !resp.Usage.Estimated {
+		t.Errorf("Expected the estimated prompt usage, got %+v", resp.Usage)
+	}
+	if !logger.contains("INFO: ", "Dry run: completion request to ollama/llama3.1 not sent") {
+		t.Errorf("Expected the request to be logged at info level, got %q", logger.lines)
+	}
+
+	stream, err := c.GenerateCompletionStream(ctx, input)
+	if err != nil {
+		t.Fatalf("GenerateCompletionStream failed: %v", err)
+	}
+	var chunks []models.StreamingCompletionResponse
+	for chunk := range stream {
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) != 1 || chunks[0].Text != DryRunText || !chunks[0].Done || chunks[0].Usage == nil {
+		t.Errorf("Expected a single Done chunk, got %+v", chunks)
+	}
+
+	tokens, err := c.CountTokens(ctx, "ollama/llama3.1", input.Messages)
+	if err != nil || tokens != resp.Usage.PromptTokens {
+		t.Errorf("Expected CountTokens to match the dry-run usage %d, got %d, %v", resp.Usage.PromptTokens, tokens, err)
+	}
+
+	if _, err := c.GenerateEmbedding(ctx, "hello"); !errors.Is(err, ErrDryRun) {
+		t.Errorf("Expected ErrDryRun for embeddings, got %v", err)
+	}
+
+	if n := calls.Load(); n != 0 {
+		t.Errorf("Expected no HTTP requests, got %d", n)
+	}
+}
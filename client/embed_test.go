@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+func TestEmbed(t *testing.T) {
+	ctx := context.Background()
+	provider := &mockProvider{
+		embedding: func(ctx context.Context, input string) ([]float32, error) {
+			return []float32{3, -4, 0}, nil
+		},
+	}
+	c := newMockClient(t, "mock", provider)
+
+	t.Run("Plain", func(t *testing.T) {
+		resp, err := c.Embed(ctx, models.EmbeddingInput{Text: "hello"})
+		if err != nil {
+			t.Fatalf("Embed failed: %v", err)
+		}
+		if len(resp.Embedding) != 3 || resp.Embedding[0] != 3 || resp.Provider != "mock" {
+			t.Errorf("Unexpected response %+v", resp)
+		}
+		if resp.VectorInt8 != nil || resp.VectorBinary != nil {
+			t.Errorf("Expected no quantized vectors, got %+v", resp)
+		}
+	})
+
+	t.Run("NormalizeInt8", func(t *testing.T) {
+		resp, err := c.Embed(ctx, models.EmbeddingInput{Text: "hello", Provider: "mock", Normalize: true, Quantize: models.QuantizeInt8})
+		if err != nil {
+			t.Fatalf("Embed failed: %v", err)
+		}
+		if math.Abs(float64(resp.Embedding[0])-0.6) > 1e-6 || math.Abs(float64(resp.Embedding[1])+0.8) > 1e-6 {
+			t.Errorf("Expected a unit vector, got %v", resp.Embedding)
+		}
+		// The quantized vector approximates the normalized one
+		if len(resp.VectorInt8) != 3 || resp.VectorInt8[1] != -127 || resp.VectorInt8[0] != 95 || resp.VectorInt8[2] != 0 {
+			t.Errorf("Unexpected int8 vector %v", resp.VectorInt8)
+		}
+		if math.Abs(float64(resp.Scale)-0.8/127) > 1e-6 {
+			t.Errorf("Expected a scale of 0.8/127, got %v", resp.Scale)
+		}
+	})
+
+	t.Run("Binary", func(t *testing.T) {
+		resp, err := c.Embed(ctx, models.EmbeddingInput{Text: "hello", Quantize: models.QuantizeBinary})
+		if err != nil {
+			t.Fatalf("Embed failed: %v", err)
+		}
+		if len(resp.VectorBinary) != 1 || resp.VectorBinary[0] != 0b10000000 {
+			t.Errorf("Unexpected binary vector %08b", resp.VectorBinary)
+		}
+	})
+
+	t.Run("UnknownQuantization", func(t *testing.T) {
+		if _, err := c.Embed(ctx, models.EmbeddingInput{Text: "hello", Quantize: "int4"}); err == nil {
+			t.Error("Expected an error for an unknown quantization")
+		}
+	})
+}
@@ -0,0 +1,150 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// ErrGuardrailRejected is returned when a guardrail rejects a request or its response
+var ErrGuardrailRejected = errors.New("rejected by guardrail")
+
+// Guardrails are policy checks run around GenerateCompletion, GenerateCompletionStream and
+// SendChatMessage. Either field may be nil.
+type Guardrails struct {
+	// Pre runs before the request is sent and may modify input, e.g. to redact it.
+	// Returning an error aborts the request.
+	Pre func(ctx context.Context, input *models.CompletionInput) error
+
+	// Post runs on the response and may modify it. Returning an error discards the response.
+	// For streams the text is held back until the Done chunk arrives, so none reaches the
+	// caller unchecked; Post then runs on the whole text, which is sent as one chunk before
+	// the Done chunk.
+	Post func(ctx context.Context, resp *models.CompletionResponse) error
+}
+
+// preGuardrails runs the Pre guardrails in registration order
+func (c *Client) preGuardrails(ctx context.Context, input *models.CompletionInput) error {
+	for _, g := range c.guardrails {
+		if g.Pre == nil {
+			continue
+		}
+		if err := g.Pre(ctx, input); err != nil {
+			c.logger.Warn("Request rejected by guardrail:", err)
+			return fmt.Errorf("%w: %w", ErrGuardrailRejected, err)
+		}
+	}
+	return nil
+}
+
+// postGuardrails runs the Post guardrails in registration order
+func (c *Client) postGuardrails(ctx context.Context, resp *models.CompletionResponse) error {
+	for _, g := range c.guardrails {
+		if g.Post == nil {
+			continue
+		}
+		if err := g.Post(ctx, resp); err != nil {
+			c.logger.Warn("Response rejected by guardrail:", err)
+			return fmt.Errorf("%w: %w", ErrGuardrailRejected, err)
+		}
+	}
+	return nil
+}
+
+// hasPostGuardrails reports whether any guardrail checks responses
+func (c *Client) hasPostGuardrails() bool {
+	for _, g := range c.guardrails {
+		if g.Post != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// streamAccumulator collects the text of a stream for the Post guardrails
+type streamAccumulator struct {
+	text     strings.Builder
+	thinking strings.Builder
+}
+
+// add records a chunk. Some providers repeat the whole text on the Done chunk, so a Done
+// chunk is only added when it differs from what has been received.
+func (a *streamAccumulator) add(chunk models.StreamingCompletionResponse) {
+	if !chunk.Done || chunk.Text != a.text.String() {
+		a.text.WriteString(chunk.Text)
+	}
+	if !chunk.Done || chunk.ThinkingText != a.thinking.String() {
+		a.thinking.WriteString(chunk.ThinkingText)
+	}
+}
+
+// checkStream holds back the text of a stream's chunk and returns the chunks to pass on in its
+// place. When the Done chunk arrives the Post guardrails run on the accumulated text, and the
+// checked text is returned in a chunk of its own before the Done chunk, whose Text stays a
+// delta. A rejected response is replaced by the Done chunk carrying the error.
+func (c *Client) checkStream(ctx context.Context, acc *streamAccumulator, chunk models.StreamingCompletionResponse) []models.StreamingCompletionResponse {
+	acc.add(chunk)
+	chunk.Text, chunk.ThinkingText = "", ""
+	if !chunk.Done {
+		if len(chunk.ToolCallDeltas) == 0 && chunk.Usage == nil && chunk.FinishReason == "" && !chunk.Resumed {
+			return nil
+		}
+		return []models.StreamingCompletionResponse{chunk}
+	}
+	if chunk.Error != nil {
+		// The provider failed, so the held text is never checked and isn't passed on
+		return []models.StreamingCompletionResponse{chunk}
+	}
+
+	resp := &models.CompletionResponse{
+		Text:         acc.text.String(),
+		ThinkingText: acc.thinking.String(),
+		Usage:        chunk.Usage,
+		Provider:     chunk.Provider,
+	}
+	if err := c.postGuardrails(ctx, resp); err != nil {
+		chunk.Error = err
+		return []models.StreamingCompletionResponse{chunk}
+	}
+	if resp.Text == "" && resp.ThinkingText == "" {
+		return []models.StreamingCompletionResponse{chunk}
+	}
+	checked := models.StreamingCompletionResponse{Text: resp.Text, ThinkingText: resp.ThinkingText, Provider: chunk.Provider}
+	return []models.StreamingCompletionResponse{checked, chunk}
+}
+
+// RegexRedactor returns guardrails that replace every match of patterns with "[REDACTED]",
+// both in the messages sent to the provider and in the response text
+func RegexRedactor(patterns ...*regexp.Regexp) Guardrails {
+	redact := func(text string) string {
+		for _, pattern := range patterns {
+			text = pattern.ReplaceAllString(text, "[REDACTED]")
+		}
+		return text
+	}
+	return Guardrails{
+		Pre: func(ctx context.Context, input *models.CompletionInput) error {
+			messages := make([]models.ChatMessage, len(input.Messages))
+			for i, message := range input.Messages {
+				message.Content = redact(message.Content)
+				messages[i] = message
+			}
+			input.Messages = messages
+			return nil
+		},
+		Post: func(ctx context.Context, resp *models.CompletionResponse) error {
+			resp.Text = redact(resp.Text)
+			return nil
+		},
+	}
+}
+
+// Common patterns for RegexRedactor
+var (
+	EmailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	SSNPattern   = regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)
+)
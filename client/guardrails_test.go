@@ -0,0 +1,221 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+	"github.com/1broseidon/gollm/providers/providertest"
+)
+
+// credentialPattern matches API keys that responses must never contain
+var credentialPattern = regexp.MustCompile(`sk-[A-Za-z0-9]{8,}`)
+
+// rejectCredentials is a Post guardrail refusing responses that contain API keys
+var rejectCredentials = Guardrails{
+	Post: func(ctx context.Context, resp *models.CompletionResponse) error {
+		if credentialPattern.MatchString(resp.Text) {
+			return errors.New("response contains a credential")
+		}
+		return nil
+	},
+}
+
+func TestGuardrailsRedact(t *testing.T) {
+	ctx := context.Background()
+
+	var sent string
+	provider := &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			sent = input.Messages[0].Content
+			return &models.CompletionResponse{Text: "I emailed bob@example.com for you."}, nil
+		},
+		chat: func(ctx context.Context, session models.ProviderChatSession, message string) (*models.CompletionResponse, error) {
+			sent = message
+			return &models.CompletionResponse{Text: "Noted 123-45-6789."}, nil
+		},
+	}
+	c := newMockClient(t, "mock", provider, WithGuardrails(RegexRedactor(EmailPattern, SSNPattern)))
+
+	messages := []models.ChatMessage{{Role: "user", Content: "My email is alice@example.com and my SSN is 123-45-6789."}}
+	resp, err := c.GenerateCompletion(ctx, models.CompletionInput{Model: "mock/model", Messages: messages})
+	if err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+	if sent != "My email is [REDACTED] and my SSN is [REDACTED]." {
+		t.Errorf("Expected PII to be redacted before sending, provider got %q", sent)
+	}
+	if messages[0].Content != "My email is alice@example.com and my SSN is 123-45-6789." {
+		t.Errorf("Expected the caller's messages to be left unchanged, got %q", messages[0].Content)
+	}
+	if resp.Text != "I emailed [REDACTED] for you." {
+		t.Errorf("Expected PII to be redacted from the response, got %q", resp.Text)
+	}
+
+	session, err := c.StartChat("model")
+	if err != nil {
+		t.Fatalf("StartChat failed: %v", err)
+	}
+	resp, err = c.SendChatMessage(ctx, session, "Reach me at carol@example.com")
+	if err != nil {
+		t.Fatalf("SendChatMessage failed: %v", err)
+	}
+	if sent != "Reach me at [REDACTED]" || resp.Text != "Noted [REDACTED]." {
+		t.Errorf("Expected chat messages to be redacted, sent %q and received %q", sent, resp.Text)
+	}
+}
+
+func TestGuardrailsReject(t *testing.T) {
+	ctx := context.Background()
+
+	called := false
+	provider := &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			called = true
+			return &models.CompletionResponse{Text: "Your key is sk-abcdefghijkl"}, nil
+		},
+	}
+	blockTopic := Guardrails{
+		Pre: func(ctx context.Context, input *models.CompletionInput) error {
+			if input.Messages[0].Content == "forbidden" {
+				return errors.New("topic not allowed")
+			}
+			return nil
+		},
+	}
+	c := newMockClient(t, "mock", provider, WithGuardrails(blockTopic), WithGuardrails(rejectCredentials))
+
+	_, err := c.GenerateCompletion(ctx, models.CompletionInput{Model: "mock/model", Messages: []models.ChatMessage{{Role: "user", Content: "forbidden"}}})
+	if !errors.Is(err, ErrGuardrailRejected) {
+		t.Errorf("Expected ErrGuardrailRejected from Pre, got %v", err)
+	}
+	if called {
+		t.Error("Expected a Pre rejection to abort the request")
+	}
+
+	resp, err := c.GenerateCompletion(ctx, models.CompletionInput{Model: "mock/model", Messages: []models.ChatMessage{{Role: "user", Content: "What is my key?"}}})
+	if !errors.Is(err, ErrGuardrailRejected) || resp != nil {
+		t.Errorf("Expected the response to be suppressed with ErrGuardrailRejected, got %v, %v", resp, err)
+	}
+}
+
+func TestGuardrailsStream(t *testing.T) {
+	ctx := context.Background()
+	input := models.CompletionInput{Model: "mock/model", Messages: []models.ChatMessage{{Role: "user", Content: "Hi"}}}
+
+	// collect consumes a stream the way callers do, returning its chunks and their accumulation
+	collect := func(t *testing.T, c *Client) ([]models.StreamingCompletionResponse, *models.StreamAccumulator) {
+		t.Helper()
+		stream, err := c.GenerateCompletionStream(ctx, input)
+		if err != nil {
+			t.Fatalf("GenerateCompletionStream failed: %v", err)
+		}
+		var chunks []models.StreamingCompletionResponse
+		acc := &models.StreamAccumulator{}
+		for chunk := range stream {
+			chunks = append(chunks, chunk)
+			acc.Add(chunk)
+		}
+		return chunks, acc
+	}
+
+	t.Run("Replace", func(t *testing.T) {
+		provider := &mockProvider{stream: streamChunks(
+			models.StreamingCompletionResponse{Text: "Write to dave"},
+			models.StreamingCompletionResponse{Text: "@example.com today."},
+			models.StreamingCompletionResponse{Done: true, FinishReason: "stop"},
+		)}
+		c := newMockClient(t, "mock", provider, WithGuardrails(RegexRedactor(EmailPattern)))
+
+		chunks, acc := collect(t, c)
+		for _, chunk := range chunks {
+			if strings.Contains(chunk.Text, "dave") || strings.Contains(chunk.Text, "example.com") {
+				t.Errorf("Expected no unredacted text to be streamed, got chunk %q", chunk.Text)
+			}
+		}
+		if last := chunks[len(chunks)-1]; !last.Done || last.Error != nil || last.Text != "" {
+			t.Errorf("Expected a Done chunk without text after the checked text, got %+v", last)
+		}
+		if text, _, finishReason := acc.Result(); text != "Write to [REDACTED] today." || finishReason != "stop" || acc.Err() != nil {
+			t.Errorf("Expected the redacted response, got %q, %q, %v", text, finishReason, acc.Err())
+		}
+	})
+
+	t.Run("RepeatedFinalText", func(t *testing.T) {
+		// Some providers repeat the whole text on the Done chunk
+		provider := &mockProvider{stream: streamChunks(
+			models.StreamingCompletionResponse{Text: "a@b.io "},
+			models.StreamingCompletionResponse{Text: "ok"},
+			models.StreamingCompletionResponse{Text: "a@b.io ok", Done: true},
+		)}
+		c := newMockClient(t, "mock", provider, WithGuardrails(RegexRedactor(EmailPattern)))
+
+		_, acc := collect(t, c)
+		if text, _, _ := acc.Result(); text != "[REDACTED] ok" {
+			t.Errorf("Expected the text once, got %q", text)
+		}
+	})
+
+	t.Run("WordChunking", func(t *testing.T) {
+		provider := &mockProvider{stream: streamChunks(
+			models.StreamingCompletionResponse{Text: "Mail erin@exa"},
+			models.StreamingCompletionResponse{Text: "mple.com now"},
+			models.StreamingCompletionResponse{Done: true},
+		)}
+		c := newMockClient(t, "mock", provider, WithGuardrails(RegexRedactor(EmailPattern)), WithStreamChunking(StreamChunkWord))
+
+		chunks, acc := collect(t, c)
+		if text, _, _ := acc.Result(); text != "Mail [REDACTED] now" {
+			t.Errorf("Expected the redacted text, got %q from %+v", text, chunks)
+		}
+	})
+
+	t.Run("Reject", func(t *testing.T) {
+		provider := &mockProvider{stream: streamChunks(
+			models.StreamingCompletionResponse{Text: "sk-abcdef"},
+			models.StreamingCompletionResponse{Text: "ghijkl", Done: true},
+		)}
+		c := newMockClient(t, "mock", provider, WithGuardrails(rejectCredentials))
+
+		chunks, acc := collect(t, c)
+		if text, _, _ := acc.Result(); text != "" || !errors.Is(acc.Err(), ErrGuardrailRejected) {
+			t.Errorf("Expected nothing to be streamed before ErrGuardrailRejected, got %q, %v", text, acc.Err())
+		}
+		if last := chunks[len(chunks)-1]; !last.Done || !errors.Is(last.Error, ErrGuardrailRejected) {
+			t.Errorf("Expected the Done chunk to carry ErrGuardrailRejected, got %+v", last)
+		}
+	})
+}
+
+func TestGuardrailsStreamContract(t *testing.T) {
+	input := models.CompletionInput{Model: "mock/model", Messages: []models.ChatMessage{{Role: "user", Content: "Hi"}}}
+	chunks := []models.StreamingCompletionResponse{
+		{Text: "Write to dave"},
+		{Text: "@example.com or sk-abcdefghijkl."},
+		{Done: true, Usage: &models.Usage{PromptTokens: 5, CompletionTokens: 9, TotalTokens: 14}},
+	}
+	for _, tc := range []struct {
+		name    string
+		options []ClientOption
+		text    string
+	}{
+		{"Redact", []ClientOption{WithGuardrails(RegexRedactor(EmailPattern))}, "Write to [REDACTED] or sk-abcdefghijkl."},
+		{"RedactWords", []ClientOption{WithGuardrails(RegexRedactor(EmailPattern)), WithStreamChunking(StreamChunkWord)}, "Write to [REDACTED] or sk-abcdefghijkl."},
+		{"Reject", []ClientOption{WithGuardrails(rejectCredentials)}, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newMockClient(t, "mock", &mockProvider{stream: streamChunks(chunks...)}, tc.options...)
+			stream, err := c.GenerateCompletionStream(context.Background(), input)
+			if err != nil {
+				t.Fatalf("GenerateCompletionStream failed: %v", err)
+			}
+			received := providertest.CheckStream(t, stream)
+			if text := providertest.Text(received); text != tc.text {
+				t.Errorf("Expected the text %q, got %q", tc.text, text)
+			}
+		})
+	}
+}
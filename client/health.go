@@ -0,0 +1,64 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ProviderHealth is the health of a provider, judged by its most recent request
+type ProviderHealth struct {
+	// Healthy is false when the most recent request failed. Providers that have served no
+	// request yet are healthy.
+	Healthy bool
+	// Latency is the duration of the most recent request; for streams, until the stream ended
+	Latency time.Duration
+	// Err is the error of the most recent request, if it failed
+	Err error
+	// LastRequest is when the most recent request finished, or zero if there was none
+	LastRequest time.Time
+}
+
+// healthMonitor records the outcome of the latest request to each provider
+type healthMonitor struct {
+	mu   sync.Mutex
+	last map[string]ProviderHealth
+}
+
+// record updates the health of provider with the result of a request finished at now.
+// Requests cancelled by the caller or by Close say nothing about the provider and are ignored.
+func (m *healthMonitor) record(provider string, result RequestResult, now time.Time) {
+	if errors.Is(result.Err, context.Canceled) || errors.Is(result.Err, ErrClientClosed) {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.last == nil {
+		m.last = make(map[string]ProviderHealth)
+	}
+	m.last[provider] = ProviderHealth{
+		Healthy:     result.Err == nil,
+		Latency:     result.Duration,
+		Err:         result.Err,
+		LastRequest: now,
+	}
+}
+
+// ProviderHealth returns the health of each registered provider
+func (c *Client) ProviderHealth() map[string]ProviderHealth {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	c.health.mu.Lock()
+	defer c.health.mu.Unlock()
+
+	health := make(map[string]ProviderHealth, len(c.providers))
+	for name := range c.providers {
+		h, ok := c.health.last[name]
+		if !ok {
+			h = ProviderHealth{Healthy: true}
+		}
+		health[name] = h
+	}
+	return health
+}
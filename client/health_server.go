@@ -0,0 +1,167 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HealthServer serves the health of a client's providers over HTTP, for load balancers and
+// orchestrators:
+//
+//   - /health responds 200 if any provider is healthy and 503 otherwise.
+//   - /health/providers responds with a JSON object mapping each provider to its health and the
+//     latency of its most recent request, as reported by Client.ProviderHealth.
+//   - /metrics is served by MetricsHandler, if set, and responds 404 otherwise.
+type HealthServer struct {
+	// MetricsHandler serves /metrics, such as promhttp.Handler() exposing the metrics recorded by
+	// the hooks of the metrics/prometheus module
+	MetricsHandler http.Handler
+
+	addr     string
+	mu       sync.Mutex
+	client   *Client
+	server   *http.Server
+	listener net.Listener
+	stopped  chan struct{}
+}
+
+// NewHealthServer returns a health server that listens on addr, such as ":8081", once started
+func NewHealthServer(addr string) *HealthServer {
+	return &HealthServer{addr: addr}
+}
+
+// Attach reports the health of c's providers. Until a client is attached, /health responds 503.
+func (s *HealthServer) Attach(c *Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.client = c
+}
+
+// Handler returns the handler serving the server's routes, to mount them on another server
+func (s *HealthServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", s.serveHealth)
+	mux.HandleFunc("/health/providers", s.serveProviders)
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		if s.MetricsHandler == nil {
+			http.NotFound(w, r)
+			return
+		}
+		s.MetricsHandler.ServeHTTP(w, r)
+	})
+	return mux
+}
+
+// Start listens on the server's address and serves in the background until Stop is called or
+// ctx is done. It returns an error if the address can't be listened on or the server is running.
+func (s *HealthServer) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.server != nil {
+		return errors.New("health server already started")
+	}
+
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Handler: s.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	stopped := make(chan struct{})
+	s.server, s.listener, s.stopped = server, listener, stopped
+	go server.Serve(listener)
+	go func() {
+		select {
+		case <-ctx.Done():
+			server.Close()
+		case <-stopped:
+		}
+	}()
+	return nil
+}
+
+// Addr returns the address the server listens on, with the port chosen for ":0", or the address
+// given to NewHealthServer if it isn't started
+func (s *HealthServer) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return s.addr
+	}
+	return s.listener.Addr().String()
+}
+
+// Stop closes the listener and any open connections. Stopping a server that isn't started does nothing.
+func (s *HealthServer) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.server == nil {
+		return nil
+	}
+	err := s.server.Close()
+	close(s.stopped)
+	s.server, s.listener, s.stopped = nil, nil, nil
+	return err
+}
+
+// providerHealth returns the health of the attached client's providers, or nil without a client
+func (s *HealthServer) providerHealth() map[string]ProviderHealth {
+	s.mu.Lock()
+	c := s.client
+	s.mu.Unlock()
+	if c == nil {
+		return nil
+	}
+	return c.ProviderHealth()
+}
+
+// serveHealth responds 200 if any provider is healthy and 503 otherwise
+func (s *HealthServer) serveHealth(w http.ResponseWriter, r *http.Request) {
+	status, code := "unavailable", http.StatusServiceUnavailable
+	for _, h := range s.providerHealth() {
+		if h.Healthy {
+			status, code = "ok", http.StatusOK
+			break
+		}
+	}
+	writeJSON(w, code, map[string]string{"status": status})
+}
+
+// providerStatus is the JSON form of a ProviderHealth served by /health/providers
+type providerStatus struct {
+	Healthy     bool       `json:"healthy"`
+	LatencyMs   float64    `json:"latency_ms"`
+	Error       string     `json:"error,omitempty"`
+	LastRequest *time.Time `json:"last_request,omitempty"`
+}
+
+// serveProviders responds with the health of each provider
+func (s *HealthServer) serveProviders(w http.ResponseWriter, r *http.Request) {
+	providers := make(map[string]providerStatus)
+	for name, h := range s.providerHealth() {
+		status := providerStatus{
+			Healthy:   h.Healthy,
+			LatencyMs: float64(h.Latency) / float64(time.Millisecond),
+		}
+		if h.Err != nil {
+			status.Error = h.Err.Error()
+		}
+		if !h.LastRequest.IsZero() {
+			lastRequest := h.LastRequest
+			status.LastRequest = &lastRequest
+		}
+		providers[name] = status
+	}
+	writeJSON(w, http.StatusOK, providers)
+}
+
+// writeJSON writes v as the JSON body of a response with the given status code
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
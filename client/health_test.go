@@ -0,0 +1,179 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// failingProvider returns a mock provider whose completions fail with err
+func failingProvider(err error) *mockProvider {
+	return &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			return nil, err
+		},
+	}
+}
+
+func TestProviderHealth(t *testing.T) {
+	ctx := context.Background()
+	c := newMockClient(t, "up", &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			return &models.CompletionResponse{Text: "ok"}, nil
+		},
+	})
+	c.RegisterProvider("down", failingProvider(errors.New("503 service unavailable")))
+	c.RegisterProvider("cancelled", failingProvider(context.Canceled))
+	c.RegisterProvider("idle", &mockProvider{})
+
+	for _, model := range []string{"up/model", "down/model", "cancelled/model"} {
+		c.GenerateCompletion(ctx, models.CompletionInput{Model: model, Messages: promptOf(3)})
+	}
+
+	health := c.ProviderHealth()
+	if len(health) != 4 {
+		t.Fatalf("Expected the health of 4 providers, got %+v", health)
+	}
+	if h := health["up"]; !h.Healthy || h.Err != nil || h.LastRequest.IsZero() {
+		t.Errorf("Expected up to be healthy after a request, got %+v", h)
+	}
+	if h := health["down"]; h.Healthy || h.Err == nil {
+		t.Errorf("Expected down to be unhealthy with its error, got %+v", h)
+	}
+	// A cancelled request says nothing about the provider
+	for _, name := range []string{"cancelled", "idle"} {
+		if h := health[name]; !h.Healthy || !h.LastRequest.IsZero() {
+			t.Errorf("Expected %s to be healthy without a recorded request, got %+v", name, h)
+		}
+	}
+}
+
+// getHealth requests path from server and returns the response's status code and body
+func getHealth(t *testing.T, server *httptest.Server, path string) (int, []byte) {
+	t.Helper()
+	resp, err := http.Get(server.URL + path)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", path, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read the response to %s: %v", path, err)
+	}
+	return resp.StatusCode, body
+}
+
+func TestHealthServerHealth(t *testing.T) {
+	ctx := context.Background()
+	c := newMockClient(t, "down", failingProvider(errors.New("connection refused")))
+	hs := NewHealthServer(":0")
+	server := httptest.NewServer(hs.Handler())
+	defer server.Close()
+
+	if code, _ := getHealth(t, server, "/health"); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without an attached client, got %d", code)
+	}
+
+	hs.Attach(c)
+	c.GenerateCompletion(ctx, models.CompletionInput{Model: "down/model", Messages: promptOf(3)})
+	code, body := getHealth(t, server, "/health")
+	if code != http.StatusServiceUnavailable || string(body) != `{"status":"unavailable"}`+"\n" {
+		t.Errorf("Expected 503 with every provider unhealthy, got %d %s", code, body)
+	}
+
+	c.RegisterProvider("idle", &mockProvider{})
+	code, body = getHealth(t, server, "/health")
+	if code != http.StatusOK || string(body) != `{"status":"ok"}`+"\n" {
+		t.Errorf("Expected 200 with a healthy provider, got %d %s", code, body)
+	}
+}
+
+func TestHealthServerProviders(t *testing.T) {
+	ctx := context.Background()
+	c := newMockClient(t, "down", failingProvider(errors.New("connection refused")))
+	c.RegisterProvider("idle", &mockProvider{})
+	c.GenerateCompletion(ctx, models.CompletionInput{Model: "down/model", Messages: promptOf(3)})
+
+	hs := NewHealthServer(":0")
+	hs.Attach(c)
+	server := httptest.NewServer(hs.Handler())
+	defer server.Close()
+
+	code, body := getHealth(t, server, "/health/providers")
+	if code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d %s", code, body)
+	}
+	var providers map[string]map[string]interface{}
+	if err := json.Unmarshal(body, &providers); err != nil {
+		t.Fatalf("Expected a JSON object, got %s: %v", body, err)
+	}
+	down := providers["down"]
+	if down["healthy"] != false || down["error"] == nil || down["last_request"] == nil {
+		t.Errorf("Expected down to be unhealthy with its error, got %v", down)
+	}
+	if _, ok := down["latency_ms"].(float64); !ok {
+		t.Errorf("Expected a numeric latency_ms, got %v", down)
+	}
+	if idle := providers["idle"]; idle["healthy"] != true || idle["error"] != nil || idle["last_request"] != nil {
+		t.Errorf("Expected idle to be healthy without a request, got %v", idle)
+	}
+}
+
+func TestHealthServerMetrics(t *testing.T) {
+	hs := NewHealthServer(":0")
+	server := httptest.NewServer(hs.Handler())
+	defer server.Close()
+
+	if code, _ := getHealth(t, server, "/metrics"); code != http.StatusNotFound {
+		t.Errorf("Expected 404 without a metrics handler, got %d", code)
+	}
+
+	hs.MetricsHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		fmt.Fprintln(w, `gollm_requests_total{provider="openai",model="gpt-4o",operation="completion"} 3`)
+	})
+	code, body := getHealth(t, server, "/metrics")
+	if code != http.StatusOK || string(body) != `gollm_requests_total{provider="openai",model="gpt-4o",operation="completion"} 3`+"\n" {
+		t.Errorf("Expected the metrics handler's response, got %d %s", code, body)
+	}
+}
+
+func TestHealthServerStartStop(t *testing.T) {
+	hs := NewHealthServer("127.0.0.1:0")
+	hs.Attach(newMockClient(t, "idle", &mockProvider{}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := hs.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if err := hs.Start(ctx); err == nil {
+		t.Error("Expected starting a running server to fail")
+	}
+
+	url := "http://" + hs.Addr() + "/health"
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET /health failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200, got %d", resp.StatusCode)
+	}
+
+	if err := hs.Stop(); err != nil {
+		t.Errorf("Stop failed: %v", err)
+	}
+	if _, err := http.Get(url); err == nil {
+		t.Error("Expected the stopped server to refuse connections")
+	}
+	if err := hs.Stop(); err != nil {
+		t.Errorf("Expected stopping twice to do nothing, got %v", err)
+	}
+}
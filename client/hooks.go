@@ -0,0 +1,110 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/1broseidon/gollm/internal/clock"
+	"github.com/1broseidon/gollm/models"
+)
+
+// Operation names reported to hooks
+const (
+	OperationCompletion       = "completion"
+	OperationCompletionStream = "completion_stream"
+	OperationEmbedding        = "embedding"
+	OperationChat             = "chat"
+)
+
+// RequestInfo describes a request dispatched to a provider
+type RequestInfo struct {
+	Operation string
+	Provider  string
+	Model     string
+	StartTime time.Time
+
+	// Messages holds the prompt of completion and chat requests
+	Messages []models.ChatMessage
+}
+
+// RequestResult describes the outcome of a request. For streaming completions it is
+// reported once the stream has finished.
+type RequestResult struct {
+	Duration time.Duration
+	Usage    *models.Usage
+	Err      error
+}
+
+// Hooks are callbacks invoked around every provider call made by the client.
+// Either field may be nil.
+type Hooks struct {
+	// BeforeRequest is called before the request is dispatched. The returned context is
+	// passed to the provider and to AfterRequest; return ctx unchanged if nothing is added.
+	BeforeRequest func(ctx context.Context, info RequestInfo) context.Context
+
+	// AfterRequest is called once the request has completed or failed.
+	AfterRequest func(ctx context.Context, info RequestInfo, result RequestResult)
+
+	// OnEvent is called for events reported by middleware such as PromptMetricsMiddleware.
+	OnEvent func(ctx context.Context, event Event)
+}
+
+// EventType identifies the kind of an Event
+type EventType string
+
+// Event types emitted by the client's middleware
+const (
+	// EventPromptTokenCount reports the estimated prompt size before a request is sent
+	EventPromptTokenCount EventType = "prompt_token_count"
+	// EventTokenUsage reports the token usage returned by the provider after a request
+	EventTokenUsage EventType = "token_usage"
+)
+
+// Event is a measurement reported to the OnEvent hooks
+type Event struct {
+	Type    EventType
+	Request RequestInfo
+
+	PromptTokens     int
+	CompletionTokens int
+
+	// PromptCompletionRatio is PromptTokens divided by CompletionTokens, or 0 if no completion
+	// tokens were reported. It is only set for EventTokenUsage.
+	PromptCompletionRatio float64
+}
+
+// beforeRequest runs the BeforeRequest hooks in registration order
+func (c *Client) beforeRequest(ctx context.Context, info RequestInfo) context.Context {
+	for _, h := range c.hooks {
+		if h.BeforeRequest != nil {
+			ctx = h.BeforeRequest(ctx, info)
+		}
+	}
+	return ctx
+}
+
+// afterRequest runs the AfterRequest hooks in registration order
+func (c *Client) afterRequest(ctx context.Context, info RequestInfo, usage *models.Usage, err error) {
+	result := RequestResult{
+		Duration: clock.Since(c.clock, info.StartTime),
+		Usage:    usage,
+		Err:      err,
+	}
+	c.health.record(info.Provider, result, c.clock.Now())
+	for _, h := range c.hooks {
+		if h.AfterRequest != nil {
+			h.AfterRequest(ctx, info, result)
+		}
+	}
+}
+
+// emitEvent logs event at debug level and passes it to the OnEvent hooks
+func (c *Client) emitEvent(ctx context.Context, event Event) {
+	c.logger.Debugf("%s for %s/%s: prompt tokens %d, completion tokens %d",
+		event.Type, event.Request.Provider, event.Request.Model, event.PromptTokens, event.CompletionTokens)
+	for _, h := range c.hooks {
+		if h.OnEvent != nil {
+			h.OnEvent(ctx, event)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+type hookKey struct{}
+
+func TestHooks(t *testing.T) {
+	ctx := context.Background()
+
+	var before []RequestInfo
+	var after []RequestResult
+	var sawValue bool
+	hooks := Hooks{
+		BeforeRequest: func(ctx context.Context, info RequestInfo) context.Context {
+			before = append(before, info)
+			return context.WithValue(ctx, hookKey{}, "traced")
+		},
+		AfterRequest: func(ctx context.Context, info RequestInfo, result RequestResult) {
+			sawValue = ctx.Value(hookKey{}) == "traced"
+			after = append(after, result)
+		},
+	}
+
+	usage := &models.Usage{PromptTokens: 3, CompletionTokens: 4, TotalTokens: 7}
+	provider := &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			if ctx.Value(hookKey{}) != "traced" {
+				return nil, errors.New("provider did not receive the hook context")
+			}
+			return &models.CompletionResponse{Text: "hi", Usage: usage}, nil
+		},
+		stream: streamChunks(
+			models.StreamingCompletionResponse{Text: "a"},
+			models.StreamingCompletionResponse{Done: true, Usage: usage},
+		),
+	}
+	c := newMockClient(t, "mock", provider, WithHooks(hooks))
+
+	t.Run("Completion", func(t *testing.T) {
+		before, after = nil, nil
+		if _, err := c.GenerateCompletion(ctx, models.CompletionInput{Model: "mock/model-a"}); err != nil {
+			t.Fatalf("GenerateCompletion failed: %v", err)
+		}
+		if len(before) != 1 || before[0].Operation != OperationCompletion || before[0].Provider != "mock" || before[0].Model != "model-a" {
+			t.Errorf("Unexpected BeforeRequest calls: %+v", before)
+		}
+		if len(after) != 1 || after[0].Usage != usage || after[0].Err != nil {
+			t.Errorf("Unexpected AfterRequest calls: %+v", after)
+		}
+		if !sawValue {
+			t.Error("AfterRequest did not receive the context returned by BeforeRequest")
+		}
+	})
+
+	t.Run("Stream", func(t *testing.T) {
+		before, after = nil, nil
+		stream, err := c.GenerateCompletionStream(ctx, models.CompletionInput{Model: "mock/model-b"})
+		if err != nil {
+			t.Fatalf("GenerateCompletionStream failed: %v", err)
+		}
+		for range stream {
+		}
+		if len(before) != 1 || before[0].Operation != OperationCompletionStream {
+			t.Errorf("Unexpected BeforeRequest calls: %+v", before)
+		}
+		if len(after) != 1 || after[0].Usage != usage {
+			t.Errorf("Expected AfterRequest with the final stream usage, got %+v", after)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		before, after = nil, nil
+		failing := &mockProvider{}
+		c.RegisterProvider("failing", failing)
+		if _, err := c.GenerateCompletion(ctx, models.CompletionInput{Model: "failing/model"}); err == nil {
+			t.Fatal("Expected GenerateCompletion to fail")
+		}
+		if len(after) != 1 || after[0].Err == nil {
+			t.Errorf("Expected AfterRequest to report the error, got %+v", after)
+		}
+	})
+}
@@ -0,0 +1,188 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/1broseidon/gollm/internal/utils"
+	"github.com/1broseidon/gollm/models"
+)
+
+// ErrContextTooLong is returned when a prompt exceeds the limit set with WithMaxInputTokens
+var ErrContextTooLong = errors.New("prompt exceeds the input token limit")
+
+// ContextTooLongError reports a prompt rejected by WithMaxInputTokens before it was sent.
+// It matches ErrContextTooLong with errors.Is.
+type ContextTooLongError struct {
+	Model  string // The requested model, as "provider/model"
+	Tokens int    // The tokens of the prompt, estimated unless WithTokenizer is set
+	Limit  int    // The tokens allowed for the prompt
+}
+
+func (e *ContextTooLongError) Error() string {
+	return fmt.Sprintf("%v: about %d tokens for %s, which allows %d", ErrContextTooLong, e.Tokens, e.Model, e.Limit)
+}
+
+func (e *ContextTooLongError) Unwrap() error {
+	return ErrContextTooLong
+}
+
+// contextWindows maps model name prefixes to the context windows of the models, in tokens.
+// The longest matching prefix applies.
+var contextWindows = map[string]int{
+	"gpt-3.5-turbo":    16385,
+	"gpt-4":            8192,
+	"gpt-4-turbo":      128000,
+	"gpt-4o":           128000,
+	"o1":               200000,
+	"o1-mini":          128000,
+	"o1-preview":       128000,
+	"o3-mini":          200000,
+	"claude-":          200000,
+	"gemini-1.0-pro":   32760,
+	"gemini-1.5-flash": 1048576,
+	"gemini-1.5-pro":   2097152,
+	"gemini-2.0":       1048576,
+}
+
+// contextWindow returns the context window of model, if it is known
+func contextWindow(model string) (int, bool) {
+	best := ""
+	for prefix := range contextWindows {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return 0, false
+	}
+	return contextWindows[best], true
+}
+
+// ContextLengthReporter is implemented by providers that look up the context window of a model
+// from the model itself, such as Ollama
+type ContextLengthReporter interface {
+	GetContextLength(ctx context.Context, modelName string) (int, error)
+}
+
+// contextWindowOf returns the context window of model, as reported by p if it implements
+// ContextLengthReporter, or else from contextWindows. In dry-run mode p is not asked.
+func (c *Client) contextWindowOf(ctx context.Context, p Provider, provider, model string) (int, bool) {
+	if reporter, ok := p.(ContextLengthReporter); ok && !c.dryRun {
+		window, err := reporter.GetContextLength(ctx, model)
+		if err == nil {
+			return window, true
+		}
+		c.logger.Warnf("Failed to get the context length of %s/%s: %v", provider, model, err)
+	}
+	return contextWindow(model)
+}
+
+// skipInputTokenLimitKey is the context key set by SkipInputTokenLimit
+type skipInputTokenLimitKey struct{}
+
+// SkipInputTokenLimit returns a context whose requests are sent without the check of
+// WithMaxInputTokens, leaving the provider to decide whether the prompt fits
+func SkipInputTokenLimit(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipInputTokenLimitKey{}, true)
+}
+
+// checkInputTokens rejects input if WithMaxInputTokens is set and its prompt is over the limit
+func (c *Client) checkInputTokens(ctx context.Context, p Provider, provider, model string, input models.CompletionInput) error {
+	if !c.limitInputTokens || ctx.Value(skipInputTokenLimitKey{}) != nil {
+		return nil
+	}
+
+	limit := c.maxInputTokens
+	if limit <= 0 {
+		window, ok := c.contextWindowOf(ctx, p, provider, model)
+		if !ok {
+			return nil
+		}
+		// The completion shares the context window with the prompt
+		limit = window - input.MaxTokens
+	}
+
+	tokens, err := c.promptTokens(model, input.Messages)
+	if err != nil {
+		return err
+	}
+	if tokens <= limit {
+		return nil
+	}
+	err = &ContextTooLongError{Model: provider + "/" + model, Tokens: tokens, Limit: limit}
+	c.logger.Warn("Request rejected:", err)
+	return err
+}
+
+// promptTokens counts the prompt tokens of messages offline, with the tokenizer of WithTokenizer
+// or else the built-in estimate
+func (c *Client) promptTokens(model string, messages []models.ChatMessage) (int, error) {
+	if c.tokenizer == nil {
+		return utils.EstimatePromptTokens(messages), nil
+	}
+	tokens, err := countPromptTokens(c.tokenizer, model, messages)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count prompt tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// trimHistory drops the oldest non-system messages of input, if AutoTrim is enabled, until its
+// prompt and MaxTokens fit the context window of model. The last message is always kept, and
+// assistant and tool messages left at the start of the conversation are dropped with the user
+// message before them. It returns the number of messages dropped, or a *ContextTooLongError if
+// the prompt can't be made to fit.
+func (c *Client) trimHistory(ctx context.Context, p Provider, provider, model string, input *models.CompletionInput) (int, error) {
+	if !input.AutoTrim && !c.autoTrim {
+		return 0, nil
+	}
+	window, ok := c.contextWindowOf(ctx, p, provider, model)
+	if !ok {
+		return 0, nil
+	}
+	limit := window - input.MaxTokens
+
+	messages := input.Messages
+	tokens, err := c.promptTokens(model, messages)
+	if err != nil {
+		return 0, err
+	}
+	dropped := 0
+	for tokens > limit {
+		first := firstNonSystem(messages)
+		if first >= len(messages)-1 {
+			err := &ContextTooLongError{Model: provider + "/" + model, Tokens: tokens, Limit: limit}
+			c.logger.Warn("Request rejected:", err)
+			return 0, err
+		}
+		// Copied, so the caller's messages are left as they are
+		messages = append(messages[:first:first], messages[first+1:]...)
+		dropped++
+		for first < len(messages)-1 && messages[first].Role != models.RoleUser && messages[first].Role != models.RoleSystem {
+			messages = append(messages[:first:first], messages[first+1:]...)
+			dropped++
+		}
+		if tokens, err = c.promptTokens(model, messages); err != nil {
+			return 0, err
+		}
+	}
+
+	if dropped > 0 {
+		c.logger.Infof("Dropped the %d oldest messages to fit the %d token context window of %s/%s", dropped, window, provider, model)
+		input.Messages = messages
+	}
+	return dropped, nil
+}
+
+// firstNonSystem returns the index of the first message that isn't a system message
+func firstNonSystem(messages []models.ChatMessage) int {
+	for i, message := range messages {
+		if message.Role != models.RoleSystem {
+			return i
+		}
+	}
+	return len(messages)
+}
@@ -0,0 +1,276 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// promptOf returns a single user message of about n tokens
+func promptOf(n int) []models.ChatMessage {
+	return []models.ChatMessage{{Role: models.RoleUser, Content: strings.Repeat(" word", n)}}
+}
+
+func TestMaxInputTokens(t *testing.T) {
+	ctx := context.Background()
+	calls := 0
+	provider := &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			calls++
+			return &models.CompletionResponse{Text: "ok"}, nil
+		},
+		stream: func(ctx context.Context, modelName string, input models.CompletionInput) (<-chan models.StreamingCompletionResponse, error) {
+			calls++
+			return streamChunks(models.StreamingCompletionResponse{Text: "ok", Done: true})(ctx, modelName, input)
+		},
+	}
+	c := newMockClient(t, "mock", provider, WithMaxInputTokens(100))
+
+	long := models.CompletionInput{Model: "mock/model", Messages: promptOf(200)}
+	_, err := c.GenerateCompletion(ctx, long)
+	if !errors.Is(err, ErrContextTooLong) {
+		t.Fatalf("Expected ErrContextTooLong, got %v", err)
+	}
+	var tooLong *ContextTooLongError
+	if !errors.As(err, &tooLong) || tooLong.Limit != 100 || tooLong.Tokens < 200 || tooLong.Model != "mock/model" {
+		t.Errorf("Expected the counted and allowed tokens, got %+v", tooLong)
+	}
+	if _, err := c.GenerateCompletionStream(ctx, long); !errors.Is(err, ErrContextTooLong) {
+		t.Errorf("Expected ErrContextTooLong for a stream, got %v", err)
+	}
+	if calls != 0 {
+		t.Fatalf("Expected no request for an oversized prompt, got %d", calls)
+	}
+
+	if _, err := c.GenerateCompletion(ctx, models.CompletionInput{Model: "mock/model", Messages: promptOf(50)}); err != nil {
+		t.Errorf("Expected a short prompt to be sent, got %v", err)
+	}
+	if _, err := c.GenerateCompletion(SkipInputTokenLimit(ctx), long); err != nil {
+		t.Errorf("Expected SkipInputTokenLimit to send the prompt, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 requests, got %d", calls)
+	}
+}
+
+func TestMaxInputTokensFromModel(t *testing.T) {
+	ctx := context.Background()
+	provider := &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			return &models.CompletionResponse{Text: "ok"}, nil
+		},
+	}
+	c := newMockClient(t, "mock", provider, WithMaxInputTokens(0))
+
+	tests := []struct {
+		name      string
+		model     string
+		tokens    int
+		maxTokens int
+		wantErr   bool
+	}{
+		{"Fits", "mock/gpt-4", 7000, 0, false},
+		{"OverWindow", "mock/gpt-4", 9000, 0, true},
+		{"OverWithCompletion", "mock/gpt-4", 7000, 2000, true},
+		{"LongerPrefix", "mock/gpt-4o-mini", 9000, 0, false},
+		{"UnknownModel", "mock/llama3.1", 300000, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := models.CompletionInput{Model: tt.model, Messages: promptOf(tt.tokens), MaxTokens: tt.maxTokens}
+			_, err := c.GenerateCompletion(ctx, input)
+			if tt.wantErr != errors.Is(err, ErrContextTooLong) {
+				t.Errorf("Expected ErrContextTooLong %v, got %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("GenerateCompletion failed: %v", err)
+			}
+		})
+	}
+}
+
+// contextLengthProvider reports the context window of its models, like Ollama
+type contextLengthProvider struct {
+	completionOnlyProvider
+	window int
+	err    error
+	models []string
+}
+
+func (p *contextLengthProvider) GetContextLength(ctx context.Context, modelName string) (int, error) {
+	p.models = append(p.models, modelName)
+	return p.window, p.err
+}
+
+func TestContextLengthReporter(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("MaxInputTokens", func(t *testing.T) {
+		p := &contextLengthProvider{completionOnlyProvider: completionOnlyProvider{models.Capabilities{Streaming: true}}, window: 4096}
+		c := newMockClient(t, "ollama", p, WithMaxInputTokens(0))
+		if _, err := c.GenerateCompletion(ctx, models.CompletionInput{Model: "ollama/llama3.1", Messages: promptOf(5000)}); !errors.Is(err, ErrContextTooLong) {
+			t.Errorf("Expected the reported window to reject the prompt, got %v", err)
+		}
+		if _, err := c.GenerateCompletion(ctx, models.CompletionInput{Model: "ollama/llama3.1", Messages: promptOf(3000)}); err != nil {
+			t.Errorf("Expected a prompt within the reported window to be sent, got %v", err)
+		}
+		if len(p.models) != 2 || p.models[0] != "llama3.1" {
+			t.Errorf("Expected the context length of llama3.1 to be requested, got %v", p.models)
+		}
+	})
+
+	t.Run("AutoTrim", func(t *testing.T) {
+		p := &contextLengthProvider{completionOnlyProvider: completionOnlyProvider{models.Capabilities{Streaming: true}}, window: 2048}
+		c := newMockClient(t, "ollama", p, WithAutoTrim(true))
+		resp, err := c.GenerateCompletion(ctx, models.CompletionInput{Model: "ollama/llama3.1", Messages: conversationOf(4, 500)})
+		if err != nil {
+			t.Fatalf("GenerateCompletion failed: %v", err)
+		}
+		if resp.TrimmedMessages == 0 {
+			t.Error("Expected messages to be trimmed to the reported window")
+		}
+	})
+
+	t.Run("FallsBackToKnownModels", func(t *testing.T) {
+		p := &contextLengthProvider{completionOnlyProvider: completionOnlyProvider{models.Capabilities{Streaming: true}}, err: errors.New("model not found")}
+		c := newMockClient(t, "ollama", p, WithMaxInputTokens(0))
+		if _, err := c.GenerateCompletion(ctx, models.CompletionInput{Model: "ollama/gpt-4", Messages: promptOf(9000)}); !errors.Is(err, ErrContextTooLong) {
+			t.Errorf("Expected the known window of gpt-4 to apply, got %v", err)
+		}
+		if !c.logger.(*recordingLogger).contains("WARN", "model not found") {
+			t.Error("Expected a warning about the failed lookup")
+		}
+	})
+}
+
+func TestNoInputTokenLimitByDefault(t *testing.T) {
+	provider := &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			return &models.CompletionResponse{Text: "ok"}, nil
+		},
+	}
+	c := newMockClient(t, "mock", provider)
+	input := models.CompletionInput{Model: "mock/gpt-4", Messages: promptOf(9000)}
+	if _, err := c.GenerateCompletion(context.Background(), input); err != nil {
+		t.Errorf("Expected no limit without WithMaxInputTokens, got %v", err)
+	}
+}
+
+// conversationOf returns a system message followed by turns user and assistant messages of
+// about n tokens each, ending with a user message
+func conversationOf(turns, n int) []models.ChatMessage {
+	messages := []models.ChatMessage{{Role: models.RoleSystem, Content: "Be brief."}}
+	for i := 0; i < turns; i++ {
+		messages = append(messages, promptOf(n)...)
+		messages = append(messages, models.ChatMessage{Role: models.RoleAssistant, Content: strings.Repeat(" word", n)})
+	}
+	return append(messages, promptOf(n)...)
+}
+
+func TestAutoTrim(t *testing.T) {
+	ctx := context.Background()
+	var sent []models.ChatMessage
+	provider := &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			sent = input.Messages
+			return &models.CompletionResponse{Text: "ok"}, nil
+		},
+		stream: func(ctx context.Context, modelName string, input models.CompletionInput) (<-chan models.StreamingCompletionResponse, error) {
+			sent = input.Messages
+			return streamChunks(models.StreamingCompletionResponse{Text: "ok", Done: true})(ctx, modelName, input)
+		},
+	}
+	c := newMockClient(t, "mock", provider)
+
+	// 7 turns of 1000 tokens each way don't fit the 8192 tokens of gpt-4
+	messages := conversationOf(7, 1000)
+	input := models.CompletionInput{Model: "mock/gpt-4", Messages: messages, MaxTokens: 1000, AutoTrim: true}
+	resp, err := c.GenerateCompletion(ctx, input)
+	if err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+	if resp.TrimmedMessages != 8 || len(sent) != len(messages)-8 {
+		t.Fatalf("Expected 8 messages to be dropped, got %d with %d sent", resp.TrimmedMessages, len(sent))
+	}
+	if sent[0].Role != models.RoleSystem || sent[1].Role != models.RoleUser {
+		t.Errorf("Expected the system message followed by a user message, got %s and %s", sent[0].Role, sent[1].Role)
+	}
+	if len(input.Messages) != len(messages) || input.Messages[1].Role != models.RoleUser {
+		t.Error("AutoTrim modified the caller's messages")
+	}
+
+	stream, err := c.GenerateCompletionStream(ctx, input)
+	if err != nil {
+		t.Fatalf("GenerateCompletionStream failed: %v", err)
+	}
+	var last models.StreamingCompletionResponse
+	for chunk := range stream {
+		last = chunk
+	}
+	if last.TrimmedMessages != 8 {
+		t.Errorf("Expected the Done chunk to report 8 dropped messages, got %d", last.TrimmedMessages)
+	}
+
+	input.AutoTrim = false
+	if resp, err := c.GenerateCompletion(ctx, input); err != nil || resp.TrimmedMessages != 0 || len(sent) != len(messages) {
+		t.Errorf("Expected the messages to be sent as they are without AutoTrim, got %d dropped", resp.TrimmedMessages)
+	}
+}
+
+func TestAutoTrimOption(t *testing.T) {
+	ctx := context.Background()
+	var sent []models.ChatMessage
+	provider := &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			sent = input.Messages
+			return &models.CompletionResponse{Text: "ok"}, nil
+		},
+	}
+	c := newMockClient(t, "mock", provider, WithAutoTrim(true))
+
+	tests := []struct {
+		name        string
+		model       string
+		messages    []models.ChatMessage
+		wantTrimmed int
+	}{
+		{"Fits", "mock/gpt-4", conversationOf(2, 1000), 0},
+		{"OverWindow", "mock/gpt-4", conversationOf(5, 1000), 4},
+		{"UnknownModel", "mock/llama3.1", conversationOf(5, 100000), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := c.GenerateCompletion(ctx, models.CompletionInput{Model: tt.model, Messages: tt.messages})
+			if err != nil {
+				t.Fatalf("GenerateCompletion failed: %v", err)
+			}
+			if resp.TrimmedMessages != tt.wantTrimmed || len(sent) != len(tt.messages)-tt.wantTrimmed {
+				t.Errorf("Expected %d messages to be dropped, got %d with %d sent", tt.wantTrimmed, resp.TrimmedMessages, len(sent))
+			}
+		})
+	}
+}
+
+func TestAutoTrimCannotFit(t *testing.T) {
+	calls := 0
+	provider := &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			calls++
+			return &models.CompletionResponse{Text: "ok"}, nil
+		},
+	}
+	c := newMockClient(t, "mock", provider, WithAutoTrim(true))
+
+	messages := append(conversationOf(1, 100), promptOf(9000)...)
+	_, err := c.GenerateCompletion(context.Background(), models.CompletionInput{Model: "mock/gpt-4", Messages: messages})
+	var tooLong *ContextTooLongError
+	if !errors.As(err, &tooLong) || tooLong.Model != "mock/gpt-4" || tooLong.Limit != 8192 {
+		t.Errorf("Expected a ContextTooLongError for a single oversized message, got %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("Expected no request for a prompt that can't fit, got %d", calls)
+	}
+}
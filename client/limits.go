@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"sync"
+)
+
+// Stats is a snapshot of the client's request activity
+type Stats struct {
+	// Providers holds the activity of each provider that has served or is limiting requests
+	Providers map[string]ProviderStats
+}
+
+// ProviderStats describes the requests currently dispatched to a single provider
+type ProviderStats struct {
+	// InFlight is the number of requests the provider is currently serving, including open streams
+	InFlight int
+	// Waiting is the number of requests blocked on the provider's concurrency limit
+	Waiting int
+	// MaxConcurrent is the limit set with WithMaxConcurrent, or 0 if the provider is unlimited
+	MaxConcurrent int
+}
+
+// concurrencyLimiter counts the requests in flight to each provider and bounds them
+// for providers configured with WithMaxConcurrent
+type concurrencyLimiter struct {
+	mu       sync.Mutex
+	sems     map[string]chan struct{}
+	inFlight map[string]int
+	waiting  map[string]int
+}
+
+// lazyInit creates the limiter's maps; callers must hold l.mu
+func (l *concurrencyLimiter) lazyInit() {
+	if l.sems == nil {
+		l.sems = make(map[string]chan struct{})
+		l.inFlight = make(map[string]int)
+		l.waiting = make(map[string]int)
+	}
+}
+
+// setLimit bounds provider to n concurrent requests; n <= 0 removes the limit
+func (l *concurrencyLimiter) setLimit(provider string, n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lazyInit()
+
+	if n <= 0 {
+		delete(l.sems, provider)
+		return
+	}
+	l.sems[provider] = make(chan struct{}, n)
+}
+
+// acquire waits for a free slot for provider, giving up when ctx is done.
+// release must be called exactly once when the request has finished.
+func (l *concurrencyLimiter) acquire(ctx context.Context, provider string) (release func(), err error) {
+	l.mu.Lock()
+	l.lazyInit()
+	sem := l.sems[provider]
+	if sem == nil {
+		l.inFlight[provider]++
+		l.mu.Unlock()
+		return l.releaseFunc(provider, nil), nil
+	}
+	l.waiting[provider]++
+	l.mu.Unlock()
+
+	select {
+	case sem <- struct{}{}:
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.waiting[provider]--
+		l.mu.Unlock()
+		return nil, context.Cause(ctx)
+	}
+
+	l.mu.Lock()
+	l.waiting[provider]--
+	l.inFlight[provider]++
+	l.mu.Unlock()
+	return l.releaseFunc(provider, sem), nil
+}
+
+// releaseFunc returns the function that gives back a slot taken by acquire
+func (l *concurrencyLimiter) releaseFunc(provider string, sem chan struct{}) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			l.inFlight[provider]--
+			l.mu.Unlock()
+			if sem != nil {
+				<-sem
+			}
+		})
+	}
+}
+
+// stats returns a snapshot of the limiter's counters
+func (l *concurrencyLimiter) stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lazyInit()
+
+	stats := Stats{Providers: make(map[string]ProviderStats)}
+	for name, n := range l.inFlight {
+		s := stats.Providers[name]
+		s.InFlight = n
+		stats.Providers[name] = s
+	}
+	for name, n := range l.waiting {
+		s := stats.Providers[name]
+		s.Waiting = n
+		stats.Providers[name] = s
+	}
+	for name, sem := range l.sems {
+		s := stats.Providers[name]
+		s.MaxConcurrent = cap(sem)
+		stats.Providers[name] = s
+	}
+	return stats
+}
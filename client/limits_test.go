@@ -0,0 +1,144 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// waitForStats polls c.Stats until cond holds for provider, failing the test after a second
+func waitForStats(t *testing.T, c *Client, provider string, cond func(ProviderStats) bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond(c.Stats().Providers[provider]) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for stats, got %+v", c.Stats().Providers[provider])
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestMaxConcurrent(t *testing.T) {
+	ctx := context.Background()
+
+	unblock := make(chan struct{})
+	provider := &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			<-unblock
+			return &models.CompletionResponse{Text: "done"}, nil
+		},
+	}
+	c := newMockClient(t, "mock", provider, WithMaxConcurrent("mock", 2))
+
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			_, err := c.GenerateCompletion(ctx, models.CompletionInput{Model: "mock/model"})
+			errs <- err
+		}()
+	}
+
+	waitForStats(t, c, "mock", func(s ProviderStats) bool { return s.InFlight == 2 && s.Waiting == 1 })
+	if got := c.Stats().Providers["mock"].MaxConcurrent; got != 2 {
+		t.Errorf("Expected MaxConcurrent 2, got %d", got)
+	}
+
+	close(unblock)
+	for i := 0; i < 3; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("GenerateCompletion failed: %v", err)
+		}
+	}
+	waitForStats(t, c, "mock", func(s ProviderStats) bool { return s.InFlight == 0 && s.Waiting == 0 })
+}
+
+func TestMaxConcurrentCancelReleasesWaiter(t *testing.T) {
+	ctx := context.Background()
+
+	provider := &mockProvider{stream: streamChunks(models.StreamingCompletionResponse{Text: "hi", Done: true})}
+	c := newMockClient(t, "mock", provider, WithMaxConcurrent("mock", 1))
+
+	// An unread stream holds the only slot
+	stream, err := c.GenerateCompletionStream(ctx, models.CompletionInput{Model: "mock/model"})
+	if err != nil {
+		t.Fatalf("GenerateCompletionStream failed: %v", err)
+	}
+
+	waitCtx, cancel := context.WithCancel(ctx)
+	errs := make(chan error, 1)
+	go func() {
+		_, err := c.GenerateCompletionStream(waitCtx, models.CompletionInput{Model: "mock/model"})
+		errs <- err
+	}()
+
+	waitForStats(t, c, "mock", func(s ProviderStats) bool { return s.InFlight == 1 && s.Waiting == 1 })
+	cancel()
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled for the waiting request, got %v", err)
+	}
+	waitForStats(t, c, "mock", func(s ProviderStats) bool { return s.Waiting == 0 })
+
+	// Finishing the stream frees the slot for the next request
+	for range stream {
+	}
+	waitForStats(t, c, "mock", func(s ProviderStats) bool { return s.InFlight == 0 })
+
+	stream, err = c.GenerateCompletionStream(ctx, models.CompletionInput{Model: "mock/model"})
+	if err != nil {
+		t.Fatalf("GenerateCompletionStream after release failed: %v", err)
+	}
+	for range stream {
+	}
+}
+
+func TestMaxConcurrentQueuedEmbeddingDoesNotBlock(t *testing.T) {
+	ctx := context.Background()
+
+	unblock := make(chan struct{})
+	embedder := &mockProvider{
+		embedding: func(ctx context.Context, input string) ([]float32, error) {
+			<-unblock
+			return []float32{1}, nil
+		},
+	}
+	c := newMockClient(t, "embed", embedder, WithMaxConcurrent("embed", 1))
+	c.RegisterProvider("other", &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			return &models.CompletionResponse{Text: "done"}, nil
+		},
+	})
+
+	// One embedding holds the only slot and a second waits for it
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := c.GenerateEmbedding(ctx, "text")
+			errs <- err
+		}()
+	}
+	waitForStats(t, c, "embed", func(s ProviderStats) bool { return s.InFlight == 1 && s.Waiting == 1 })
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.GenerateCompletion(ctx, models.CompletionInput{Model: "other/model"})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("GenerateCompletion failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected a completion to another provider not to wait for the queued embedding")
+	}
+
+	close(unblock)
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("GenerateEmbedding failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// mergeSource is one of the streams merged by MergeStreams
+type mergeSource struct {
+	name   string
+	stream <-chan models.StreamingCompletionResponse
+}
+
+// MergeStreams streams input from several models at once, given as "provider/model", and
+// interleaves their chunks round-robin: one chunk from each active stream in turn. Each chunk
+// has Provider set to the model it came from. A stream that fails to start contributes a chunk
+// with its error. The Done chunk of each stream is passed on with Done cleared, except for the
+// last stream to finish, whose Done chunk ends the merged stream.
+//
+// Cancel ctx to stop early; otherwise the merged stream must be read to the end.
+func (c *Client) MergeStreams(ctx context.Context, input models.CompletionInput, providers []string) <-chan models.StreamingCompletionResponse {
+	merged := make(chan models.StreamingCompletionResponse, max(input.StreamBufferSize, 0))
+
+	sources := make([]mergeSource, len(providers))
+	for i, name := range providers {
+		sourceInput := input
+		sourceInput.Model = name
+		stream, err := c.GenerateCompletionStream(ctx, sourceInput)
+		if err != nil {
+			failed := make(chan models.StreamingCompletionResponse, 1)
+			failed <- models.StreamingCompletionResponse{Error: err, Done: true}
+			close(failed)
+			stream = failed
+		}
+		sources[i] = mergeSource{name: name, stream: stream}
+	}
+
+	go func() {
+		defer close(merged)
+		for len(sources) > 0 {
+			for i := 0; i < len(sources); {
+				source := sources[i]
+				chunk, ok := <-source.stream
+				finished := !ok || chunk.Done
+				last := finished && len(sources) == 1
+				if finished {
+					go drainStream(source.stream)
+					sources = append(sources[:i], sources[i+1:]...)
+				} else {
+					i++
+				}
+				if !ok && !last {
+					continue
+				}
+
+				chunk.Provider = source.name
+				chunk.Done = last
+				select {
+				case merged <- chunk:
+				case <-ctx.Done():
+					for _, source := range sources {
+						go drainStream(source.stream)
+					}
+					return
+				}
+			}
+		}
+	}()
+	return merged
+}
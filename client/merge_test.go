@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+func TestMergeStreams(t *testing.T) {
+	first := &mockProvider{stream: streamChunks(textChunks("a1", "a2", "a3")...)}
+	second := &mockProvider{stream: streamChunks(textChunks("b1", "b2", "b3")...)}
+	c := newMockClient(t, "first", first)
+	c.RegisterProvider("second", second)
+
+	input := models.CompletionInput{Messages: []models.ChatMessage{{Role: models.RoleUser, Content: "Write a poem"}}}
+	var texts, providers []string
+	var done []bool
+	for chunk := range c.MergeStreams(context.Background(), input, []string{"first/model", "second/model"}) {
+		texts = append(texts, chunk.Text)
+		providers = append(providers, chunk.Provider)
+		done = append(done, chunk.Done)
+	}
+
+	if want := []string{"a1", "b1", "a2", "b2", "a3", "b3"}; !reflect.DeepEqual(texts, want) {
+		t.Errorf("Expected round-robin chunks %v, got %v", want, texts)
+	}
+	if want := []string{"first/model", "second/model", "first/model", "second/model", "first/model", "second/model"}; !reflect.DeepEqual(providers, want) {
+		t.Errorf("Expected the providers %v, got %v", want, providers)
+	}
+	if want := []bool{false, false, false, false, false, true}; !reflect.DeepEqual(done, want) {
+		t.Errorf("Expected only the last chunk to be Done, got %v", done)
+	}
+}
+
+func TestMergeStreamsUneven(t *testing.T) {
+	short := &mockProvider{stream: streamChunks(textChunks("s1")...)}
+	long := &mockProvider{stream: streamChunks(textChunks("l1", "l2", "l3")...)}
+	c := newMockClient(t, "short", short)
+	c.RegisterProvider("long", long)
+
+	var texts []string
+	var errs []error
+	for chunk := range c.MergeStreams(context.Background(), models.CompletionInput{}, []string{"short/model", "missing/model", "long/model"}) {
+		texts = append(texts, chunk.Text)
+		if chunk.Error != nil {
+			errs = append(errs, chunk.Error)
+		}
+	}
+
+	if want := []string{"s1", "", "l1", "l2", "l3"}; !reflect.DeepEqual(texts, want) {
+		t.Errorf("Expected the remaining stream to continue alone, got %v", texts)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], ErrUnsupportedProvider) {
+		t.Errorf("Expected the error of the stream that failed to start, got %v", errs)
+	}
+}
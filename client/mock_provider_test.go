@@ -0,0 +1,99 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/1broseidon/gollm/internal/clock"
+	"github.com/1broseidon/gollm/models"
+)
+
+// mockProvider is a scriptable Provider used by the client tests
+type mockProvider struct {
+	completion func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error)
+	stream     func(ctx context.Context, modelName string, input models.CompletionInput) (<-chan models.StreamingCompletionResponse, error)
+	embedding  func(ctx context.Context, input string) ([]float32, error)
+	chat       func(ctx context.Context, session models.ProviderChatSession, message string) (*models.CompletionResponse, error)
+	closeFunc  func() error
+}
+
+func (m *mockProvider) GenerateCompletion(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+	if m.completion == nil {
+		return nil, errors.New("completion not scripted")
+	}
+	return m.completion(ctx, modelName, input)
+}
+
+func (m *mockProvider) GenerateCompletionStream(ctx context.Context, modelName string, input models.CompletionInput) (<-chan models.StreamingCompletionResponse, error) {
+	if m.stream == nil {
+		return nil, errors.New("stream not scripted")
+	}
+	return m.stream(ctx, modelName, input)
+}
+
+func (m *mockProvider) GenerateEmbedding(ctx context.Context, input string) ([]float32, error) {
+	if m.embedding == nil {
+		return nil, errors.New("embedding not scripted")
+	}
+	return m.embedding(ctx, input)
+}
+
+func (m *mockProvider) SynthesizeSpeech(ctx context.Context, input models.SpeechInput) (*models.SpeechResponse, error) {
+	return nil, models.ErrUnsupportedOperation
+}
+
+// mockSession is the chat session of mockProvider
+type mockSession struct{ model string }
+
+func (s *mockSession) Model() string { return s.model }
+
+func (m *mockProvider) StartChat(modelName string) models.ProviderChatSession {
+	return &mockSession{model: modelName}
+}
+
+func (m *mockProvider) SendChatMessage(ctx context.Context, session models.ProviderChatSession, message string) (*models.CompletionResponse, error) {
+	if m.chat == nil {
+		return nil, errors.New("chat not scripted")
+	}
+	return m.chat(ctx, session, message)
+}
+
+func (m *mockProvider) Close() error {
+	if m.closeFunc == nil {
+		return nil
+	}
+	return m.closeFunc()
+}
+
+// streamChunks returns a stream function that emits the given chunks and closes the channel
+func streamChunks(chunks ...models.StreamingCompletionResponse) func(context.Context, string, models.CompletionInput) (<-chan models.StreamingCompletionResponse, error) {
+	return func(ctx context.Context, modelName string, input models.CompletionInput) (<-chan models.StreamingCompletionResponse, error) {
+		streamChan := make(chan models.StreamingCompletionResponse)
+		go func() {
+			defer close(streamChan)
+			for _, chunk := range chunks {
+				streamChan <- chunk
+			}
+		}()
+		return streamChan, nil
+	}
+}
+
+// newMockClient returns a client with no environment providers and the given mock registered under name
+func newMockClient(t *testing.T, name string, provider Provider, options ...ClientOption) *Client {
+	c := &Client{
+		providers:        make(map[string]Provider),
+		closeGracePeriod: defaultCloseGracePeriod,
+		adaptiveRetries:  defaultAdaptiveRetries,
+		clock:            clock.Real,
+		logger:           &recordingLogger{},
+	}
+	for _, option := range options {
+		option(c)
+	}
+	c.RegisterProvider(name, provider)
+	c.setDefaultProviderIfEmpty(name)
+	t.Cleanup(func() { c.Close() })
+	return c
+}
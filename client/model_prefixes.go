@@ -0,0 +1,45 @@
+package client
+
+import "strings"
+
+// defaultModelPrefixes maps well-known model name prefixes to the provider serving them
+var defaultModelPrefixes = map[string]string{
+	"gpt-":    "openai",
+	"o1-":     "openai",
+	"claude-": "anthropic",
+	"gemini-": "googlegemini",
+	"llama":   "ollama",
+}
+
+// inferProvider returns the provider for a model name without a "provider/" prefix.
+// It reports false when no prefix matches or prefixes of different providers match.
+func (c *Client) inferProvider(model string) (string, bool) {
+	model = strings.ToLower(model)
+
+	provider := ""
+	match := func(prefix, name string) bool {
+		if !strings.HasPrefix(model, strings.ToLower(prefix)) {
+			return true
+		}
+		if provider != "" && provider != name {
+			return false
+		}
+		provider = name
+		return true
+	}
+
+	for prefix, name := range defaultModelPrefixes {
+		if _, overridden := c.modelPrefixes[prefix]; overridden {
+			continue
+		}
+		if !match(prefix, name) {
+			return "", false
+		}
+	}
+	for prefix, name := range c.modelPrefixes {
+		if !match(prefix, name) {
+			return "", false
+		}
+	}
+	return provider, provider != ""
+}
@@ -0,0 +1,139 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/1broseidon/gollm/internal/utils"
+)
+
+// ErrUnknownModel is returned with WithModelValidation for models the provider doesn't list
+var ErrUnknownModel = errors.New("unknown model")
+
+// maxModelSuggestions is the number of close matches suggested for an unknown model
+const maxModelSuggestions = 3
+
+// ModelLister is implemented by providers that can list the models they serve, such as OpenAI,
+// Anthropic and Ollama
+type ModelLister interface {
+	ListModels(ctx context.Context) ([]string, error)
+}
+
+// modelCache holds the models listed by each provider, fetched once per client
+type modelCache struct {
+	mu     sync.Mutex
+	models map[string][]string
+}
+
+// ListModels returns the models served by provider, which must implement ModelLister. The list
+// is fetched on the first call and cached for the lifetime of the client.
+func (c *Client) ListModels(ctx context.Context, provider string) ([]string, error) {
+	p, err := c.initializeProvider(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+	lister, ok := p.(ModelLister)
+	if !ok {
+		return nil, unsupported(provider, "listing models")
+	}
+	return c.listModels(ctx, provider, lister)
+}
+
+// listModels returns the cached models of provider, fetching them from lister if needed.
+// Failures are not cached, so the next call tries again.
+func (c *Client) listModels(ctx context.Context, provider string, lister ModelLister) ([]string, error) {
+	c.models.mu.Lock()
+	names, ok := c.models.models[provider]
+	c.models.mu.Unlock()
+	if ok {
+		return names, nil
+	}
+
+	names, err := lister.ListModels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the models of %s: %w", provider, err)
+	}
+	c.models.mu.Lock()
+	defer c.models.mu.Unlock()
+	if c.models.models == nil {
+		c.models.models = make(map[string][]string)
+	}
+	c.models.models[provider] = names
+	return names, nil
+}
+
+// checkModel returns ErrUnknownModel, with suggestions of close matches, if WithModelValidation
+// is set and model isn't listed by the provider. Providers that can't list their models, and
+// lists that fail to load, let every model through.
+func (c *Client) checkModel(ctx context.Context, p Provider, provider, model string) error {
+	if !c.validateModels || c.dryRun {
+		return nil
+	}
+	lister, ok := p.(ModelLister)
+	if !ok {
+		c.logger.Debugf("Skipping model validation: the %s provider can't list its models", provider)
+		return nil
+	}
+	names, err := c.listModels(ctx, provider, lister)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		c.logger.Warn("Skipping model validation:", err)
+		return nil
+	}
+	if modelListed(names, model) {
+		return nil
+	}
+
+	err = fmt.Errorf("%w %q for provider %s", ErrUnknownModel, model, provider)
+	if suggestions := suggestModels(names, model); len(suggestions) > 0 {
+		err = fmt.Errorf("%w; did you mean %s?", err, strings.Join(suggestions, ", "))
+	}
+	c.logger.Error("Model validation failed:", err)
+	return err
+}
+
+// modelListed reports whether model is one of names. Untagged Ollama models match their
+// ":latest" tag, and "-latest" aliases, such as Anthropic's, match any listed version.
+func modelListed(names []string, model string) bool {
+	alias, isAlias := strings.CutSuffix(model, "-latest")
+	for _, name := range names {
+		if name == model || name == model+":latest" || isAlias && strings.HasPrefix(name, alias+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// suggestModels returns the names closest to model by edit distance, quoted, closest first
+func suggestModels(names []string, model string) []string {
+	type candidate struct {
+		name     string
+		distance int
+	}
+	threshold := max(2, len(model)/3)
+	var candidates []candidate
+	for _, name := range names {
+		distance := min(utils.Levenshtein(model, name), utils.Levenshtein(model, strings.TrimSuffix(name, ":latest")))
+		if distance <= threshold {
+			candidates = append(candidates, candidate{name, distance})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].name < candidates[j].name
+	})
+
+	suggestions := make([]string, 0, maxModelSuggestions)
+	for _, candidate := range candidates[:min(len(candidates), maxModelSuggestions)] {
+		suggestions = append(suggestions, fmt.Sprintf("%q", candidate.name))
+	}
+	return suggestions
+}
@@ -0,0 +1,119 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// listingProvider serves completions and lists models, counting the lists it returns
+type listingProvider struct {
+	completionOnlyProvider
+	models []string
+	err    error
+	lists  int
+}
+
+func (p *listingProvider) ListModels(ctx context.Context) ([]string, error) {
+	p.lists++
+	return p.models, p.err
+}
+
+func TestModelValidation(t *testing.T) {
+	ctx := context.Background()
+	listed := []string{"gpt-4o", "gpt-4-turbo", "gpt-3.5-turbo", "llama3:latest", "claude-3-5-sonnet-20241022"}
+
+	tests := []struct {
+		name        string
+		model       string
+		wantErr     bool
+		suggestions []string
+	}{
+		{"Listed", "gpt-4o", false, nil},
+		{"UntaggedOllamaModel", "llama3", false, nil},
+		{"LatestAlias", "claude-3-5-sonnet-latest", false, nil},
+		{"Typo", "gpt-4-turb", true, []string{`"gpt-4-turbo"`}},
+		{"TypoOfUntaggedModel", "lama3", true, []string{`"llama3:latest"`}},
+		{"NoCloseMatch", "mistral-large", true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &listingProvider{completionOnlyProvider: completionOnlyProvider{models.Capabilities{Streaming: true}}, models: listed}
+			c := newMockClient(t, "mock", p, WithModelValidation())
+
+			_, err := c.GenerateCompletion(ctx, models.CompletionInput{Model: "mock/" + tt.model, Messages: promptOf(1)})
+			_, streamErr := c.GenerateCompletionStream(ctx, models.CompletionInput{Model: "mock/" + tt.model, Messages: promptOf(1)})
+			if !tt.wantErr {
+				if err != nil || streamErr != nil {
+					t.Errorf("Expected %q to be accepted, got %v and %v", tt.model, err, streamErr)
+				}
+			} else {
+				for _, err := range []error{err, streamErr} {
+					if !errors.Is(err, ErrUnknownModel) {
+						t.Fatalf("Expected ErrUnknownModel, got %v", err)
+					}
+					if tt.suggestions == nil && strings.Contains(err.Error(), "did you mean") {
+						t.Errorf("Expected no suggestions, got %v", err)
+					}
+					for _, suggestion := range tt.suggestions {
+						if !strings.Contains(err.Error(), "did you mean "+suggestion) {
+							t.Errorf("Expected the suggestion %s, got %v", suggestion, err)
+						}
+					}
+				}
+			}
+			if p.lists != 1 {
+				t.Errorf("Expected the model list to be fetched once and cached, got %d fetches", p.lists)
+			}
+		})
+	}
+}
+
+func TestModelValidationSkipped(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Disabled", func(t *testing.T) {
+		p := &listingProvider{completionOnlyProvider: completionOnlyProvider{models.Capabilities{Streaming: true}}, models: []string{"gpt-4o"}}
+		c := newMockClient(t, "mock", p)
+		if _, err := c.GenerateCompletion(ctx, models.CompletionInput{Model: "mock/unknown", Messages: promptOf(1)}); err != nil || p.lists != 0 {
+			t.Errorf("Expected no validation without WithModelValidation, got %v after %d fetches", err, p.lists)
+		}
+	})
+
+	t.Run("ListFails", func(t *testing.T) {
+		p := &listingProvider{completionOnlyProvider: completionOnlyProvider{models.Capabilities{Streaming: true}}, err: errors.New("connection refused")}
+		c := newMockClient(t, "mock", p, WithModelValidation())
+		for i := 0; i < 2; i++ {
+			if _, err := c.GenerateCompletion(ctx, models.CompletionInput{Model: "mock/unknown", Messages: promptOf(1)}); err != nil {
+				t.Errorf("Expected the request to go through when the list fails, got %v", err)
+			}
+		}
+		if p.lists != 2 {
+			t.Errorf("Expected failed lists not to be cached, got %d fetches", p.lists)
+		}
+		if !c.logger.(*recordingLogger).contains("WARN", "connection refused") {
+			t.Error("Expected a warning about the failed list")
+		}
+	})
+
+	t.Run("NotListable", func(t *testing.T) {
+		c := newMockClient(t, "mock", &completionOnlyProvider{models.Capabilities{Streaming: true}}, WithModelValidation())
+		if _, err := c.GenerateCompletion(ctx, models.CompletionInput{Model: "mock/anything", Messages: promptOf(1)}); err != nil {
+			t.Errorf("Expected providers that can't list models to be skipped, got %v", err)
+		}
+		if _, err := c.ListModels(ctx, "mock"); !errors.Is(err, ErrUnsupportedOperation) {
+			t.Errorf("Expected ErrUnsupportedOperation from ListModels, got %v", err)
+		}
+	})
+
+	t.Run("DryRun", func(t *testing.T) {
+		p := &listingProvider{completionOnlyProvider: completionOnlyProvider{models.Capabilities{Streaming: true}}, models: []string{"gpt-4o"}}
+		c := newMockClient(t, "mock", p, WithModelValidation(), WithDryRun())
+		if _, err := c.GenerateCompletion(ctx, models.CompletionInput{Model: "mock/unknown", Messages: promptOf(1)}); err != nil || p.lists != 0 {
+			t.Errorf("Expected dry runs not to list models, got %v after %d fetches", err, p.lists)
+		}
+	})
+}
@@ -2,13 +2,39 @@ package client
 
 import (
 	"errors"
+	"io"
+	"net/http"
+	"time"
+
 	"github.com/1broseidon/gollm/common"
+	"github.com/1broseidon/gollm/internal/clock"
 	"github.com/1broseidon/gollm/internal/logging"
+	"github.com/1broseidon/gollm/models"
 )
 
+// defaultRequestTimeout is the HTTP client timeout used when WithRequestTimeout isn't set
+const defaultRequestTimeout = 30 * time.Second
+
 // ErrUnsupportedProvider is returned when an unsupported provider is specified
 var ErrUnsupportedProvider = errors.New("unsupported provider")
 
+// ErrUnsupportedOperation is returned when the selected provider doesn't implement an operation
+var ErrUnsupportedOperation = models.ErrUnsupportedOperation
+
+// ErrContentFiltered is returned when the provider's content policy blocked the prompt or output
+var ErrContentFiltered = models.ErrContentFiltered
+
+// ErrResponseTooLarge is returned when a provider's response exceeds its response body limit
+var ErrResponseTooLarge = models.ErrResponseTooLarge
+
+// ErrClientClosed is returned for requests made after Close, and is reported on the
+// final chunk of streams that were still active when the client was closed
+var ErrClientClosed = errors.New("client closed")
+
+// ErrNoProvidersConfigured is returned by NewClient when WithRequireProvider is set
+// and none of the provider environment variables are present
+var ErrNoProvidersConfigured = errors.New("no providers configured")
+
 // ClientOption is a function type for configuring the Client.
 // It allows for flexible and extensible client configuration.
 type ClientOption func(*Client)
@@ -23,19 +49,341 @@ func WithDefaultProvider(provider string) ClientOption {
 }
 
 // WithLogger sets the logger for the client.
-// The provided logger will be used for all logging operations within the client.
+// The provided logger will be used for all logging operations within the client. A logger with
+// a Level() common.LogLevel method that returns common.DebugLevel turns on request logging, as
+// WithLogLevel(common.DebugLevel) does.
 func WithLogger(logger logging.Logger) ClientOption {
 	return func(c *Client) {
 		c.logger = logger
 	}
 }
 
+// NewDefaultLoggerWithOptions returns the client's default logger writing to out, or os.Stderr if
+// out is nil, with the log package flags and prefix, for use with WithLogger. Pass WithLogLevel
+// after WithLogger to set its level.
+func NewDefaultLoggerWithOptions(out io.Writer, flags int, prefix string) logging.Logger {
+	return logging.NewDefaultLoggerWithOptions(out, flags, prefix)
+}
+
+// NewNopLogger returns a logger that discards everything, for use with WithLogger to silence
+// the client or in tests
+func NewNopLogger() logging.Logger {
+	return logging.NewNopLogger()
+}
+
 // WithLogLevel sets the log level for the client.
 // This option will only take effect if the client's logger supports setting log levels.
+// At common.DebugLevel it also turns on request logging, as WithRequestLogging does.
 func WithLogLevel(level common.LogLevel) ClientOption {
 	return func(c *Client) {
+		c.logLevel = level
 		if logger, ok := c.logger.(interface{ SetLevel(common.LogLevel) }); ok {
 			logger.SetLevel(level)
 		}
 	}
 }
+
+// WithRequireProvider makes NewClient strict: it fails with ErrNoProvidersConfigured when no
+// provider is configured in the environment, and fails if any configured provider can't be initialized,
+// instead of returning a client whose calls to that provider fail later.
+func WithRequireProvider() ClientOption {
+	return func(c *Client) {
+		c.requireProvider = true
+	}
+}
+
+// WithHooks registers callbacks that run around every provider call.
+// It may be given more than once; hooks run in the order they were registered.
+func WithHooks(hooks Hooks) ClientOption {
+	return func(c *Client) {
+		c.hooks = append(c.hooks, hooks)
+	}
+}
+
+// WithTransportWrapper wraps the HTTP transport used by the OpenAI, Anthropic and Ollama providers,
+// for example to add tracing or request logging. Wrappers are applied in the order given, so the
+// last one registered is the outermost. The Gemini provider applies it to its REST API requests,
+// the File API and completions referencing uploaded files; its SDK requests are not affected.
+func WithTransportWrapper(wrap func(http.RoundTripper) http.RoundTripper) ClientOption {
+	return func(c *Client) {
+		c.transportWrappers = append(c.transportWrappers, wrap)
+	}
+}
+
+// WithRequestLogging logs the requests and responses of the OpenAI, Anthropic and Ollama providers
+// at debug level with a RedactingTransport, redacting redactHeaders along with the API key headers.
+// Request logging is on by default when the log level is common.DebugLevel. Like
+// WithTransportWrapper, it applies to Gemini's REST API requests only.
+func WithRequestLogging(redactHeaders []string) ClientOption {
+	return func(c *Client) {
+		c.requestLogging = true
+		c.redactHeaders = append(c.redactHeaders, redactHeaders...)
+	}
+}
+
+// WithDryRun stops the client from calling providers, for tests, CI pipelines and cost estimates.
+// Requests are prepared as usual, then logged at info level instead of being sent.
+// Completions and chat messages return DryRunText with the prompt's estimated usage, and
+// streams a single Done chunk with the same; operations that can't be simulated, such as
+// embeddings, return ErrDryRun. CountTokens uses the tokenizer or an offline estimate.
+func WithDryRun() ClientOption {
+	return func(c *Client) {
+		c.dryRun = true
+	}
+}
+
+// WithModelValidation checks that the model of each completion is listed by its provider before
+// sending the request, returning ErrUnknownModel with the closest matches for typos such as
+// "gpt-4-turb". Each provider's list is fetched once with its ListModels method and cached.
+// Providers that don't implement ModelLister, such as Gemini, are not checked.
+func WithModelValidation() ClientOption {
+	return func(c *Client) {
+		c.validateModels = true
+	}
+}
+
+// WithRequestSigning signs the requests of the OpenAI, Anthropic and Ollama providers for proxies
+// that require HMAC authentication. The signature is the hex HMAC, keyed with secretKey, of the
+// method, URL, hex body hash and Unix timestamp joined by newlines, with the body hashed by the
+// algorithm's hash. Requests carry X-Timestamp and X-Key-ID headers and an Authorization header
+// of the form `Sig keyId="...", algorithm="hmac-sha256", signature="..."`, which replaces the
+// provider's own. Like WithTransportWrapper, it applies to Gemini's REST API requests only.
+func WithRequestSigning(keyID, secretKey string, algorithm SigningAlgorithm) ClientOption {
+	return func(c *Client) {
+		c.transportWrappers = append(c.transportWrappers, func(base http.RoundTripper) http.RoundTripper {
+			return &signingTransport{base: base, keyID: keyID, secretKey: []byte(secretKey), algorithm: algorithm, clock: c.clock}
+		})
+	}
+}
+
+// WithCloseGracePeriod sets how long Close waits for active streams to finish after cancelling
+// them, before closing the providers underneath. The default is 5 seconds.
+func WithCloseGracePeriod(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.closeGracePeriod = d
+	}
+}
+
+// WithMaxConcurrent limits the number of requests, including open streams, that the client
+// dispatches to provider at once. Further requests wait for a free slot until their context is done.
+// n <= 0 leaves the provider unlimited.
+func WithMaxConcurrent(provider string, n int) ClientOption {
+	return func(c *Client) {
+		c.limiter.setLimit(provider, n)
+	}
+}
+
+// WithProxy routes the OpenAI, Anthropic and Ollama providers through an HTTP, HTTPS or SOCKS5 proxy,
+// e.g. "http://proxy.example.com:8080" or "socks5://127.0.0.1:1080". NewClient returns an error if
+// the URL is invalid. Like WithTransportWrapper, it applies to Gemini's REST API requests only.
+func WithProxy(proxyURL string) ClientOption {
+	return func(c *Client) {
+		c.proxy = proxyURL
+	}
+}
+
+// WithRequestTimeout sets the HTTP timeout for each request made by the OpenAI, Anthropic and
+// Ollama providers, including reading a streamed response body. The default is 30 seconds.
+func WithRequestTimeout(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.requestTimeout = d
+	}
+}
+
+// WithPromptMetrics installs PromptMetricsMiddleware, logging the estimated prompt size of every
+// completion and chat request and its actual token usage at debug level and reporting both to
+// the OnEvent hooks registered with WithHooks.
+func WithPromptMetrics() ClientOption {
+	return func(c *Client) {
+		c.hooks = append(c.hooks, PromptMetricsMiddleware(c.emitEvent))
+	}
+}
+
+// WithTransportConfig tunes the HTTP transport of the OpenAI, Anthropic and Ollama providers.
+// By default they share one transport per client, configured with DefaultTransportConfig, so
+// connections are reused across requests and providers.
+func WithTransportConfig(cfg TransportConfig) ClientOption {
+	return func(c *Client) {
+		c.transportConfig = cfg
+	}
+}
+
+// WithPerProviderTransports gives each provider its own transport and connection pool instead
+// of one transport shared by every provider of the client
+func WithPerProviderTransports() ClientOption {
+	return func(c *Client) {
+		c.ownTransports = true
+	}
+}
+
+// WithModelPrefix lets model names starting with prefix be used without a "provider/" prefix,
+// e.g. WithModelPrefix("mistral", "ollama"). It replaces a built-in mapping for the same prefix.
+// Model names matching prefixes of more than one provider still need the "provider/" prefix.
+func WithModelPrefix(prefix, provider string) ClientOption {
+	return func(c *Client) {
+		if c.modelPrefixes == nil {
+			c.modelPrefixes = make(map[string]string)
+		}
+		c.modelPrefixes[prefix] = provider
+	}
+}
+
+// WithStreamProgress calls fn for every chunk received by a streaming completion, before the chunk
+// is sent to the caller. tokensSoFar estimates the completion tokens generated so far and
+// estimatedFraction is tokensSoFar / MaxTokens, or -1 when MaxTokens isn't set. fn runs on the
+// stream's goroutine, so it should return quickly.
+func WithStreamProgress(fn func(chunkIndex int, tokensSoFar int, estimatedFraction float64)) ClientOption {
+	return func(c *Client) {
+		c.streamProgressFunc = fn
+	}
+}
+
+// WithGuardrails registers policy checks run around completion and chat requests, such as
+// RegexRedactor. It may be given more than once; guardrails run in the order they were registered.
+func WithGuardrails(g Guardrails) ClientOption {
+	return func(c *Client) {
+		c.guardrails = append(c.guardrails, g)
+	}
+}
+
+// WithCredentialsFile reads provider credentials from path instead of ~/.config/gollm/credentials.
+// The file is JSON or TOML with the keys openai_api_key, anthropic_api_key, gemini_api_key and
+// ollama_base_url; environment variables take precedence. NewClient returns an error if the file
+// is malformed.
+func WithCredentialsFile(path string) ClientOption {
+	return func(c *Client) {
+		c.credentialsFile = path
+	}
+}
+
+// WithRAGEmbeddingProvider sets the provider GenerateWithContext embeds queries with, e.g. "ollama"
+// when completions come from a provider without embeddings. The default is the default provider.
+// It must be the provider that embedded the documents in the store.
+func WithRAGEmbeddingProvider(name string) ClientOption {
+	return func(c *Client) {
+		c.ragEmbedder = name
+	}
+}
+
+// WithRAGContextTemplate sets the text/template GenerateWithContext renders the retrieved
+// documents with. It is executed with a RAGContext; the default is DefaultRAGContextTemplate.
+func WithRAGContextTemplate(tmpl string) ClientOption {
+	return func(c *Client) {
+		c.ragTemplate = tmpl
+	}
+}
+
+// WithClock sets the time source used for request timing, stream metrics and waits, so they can
+// be tested without real sleeps. The default is the system clock.
+func WithClock(clk clock.Clock) ClientOption {
+	return func(c *Client) {
+		if clk != nil {
+			c.clock = clk
+		}
+	}
+}
+
+// WithMaxInputTokens rejects completions whose prompt is longer than limit tokens with a
+// *ContextTooLongError, before anything is sent. A limit of zero or less uses the context window
+// of the requested model, less its MaxTokens; Ollama reports the context window of its models,
+// and models with an unknown context window are not checked. Prompt tokens are counted with the tokenizer of WithTokenizer,
+// or else estimated offline from the messages, as for WithPromptMetrics. Use SkipInputTokenLimit
+// to send a request unchecked.
+func WithMaxInputTokens(limit int) ClientOption {
+	return func(c *Client) {
+		c.limitInputTokens = true
+		c.maxInputTokens = limit
+	}
+}
+
+// WithTemperaturePolicy sets what happens to a temperature outside the range its provider
+// accepts: 0 to 2 for OpenAI and Gemini, and 0 to 1 for Anthropic. The default,
+// TemperatureClamp, sends the nearest bound instead; TemperatureReject fails the request.
+func WithTemperaturePolicy(policy TemperaturePolicy) ClientOption {
+	return func(c *Client) {
+		c.temperaturePolicy = policy
+	}
+}
+
+// WithStreamChunking re-chunks streaming completions on word, sentence or line boundaries, for
+// consumers such as speech synthesis that need whole units of text. Text after the last boundary
+// is held until the stream completes it, and the rest arrives with the Done chunk. The default,
+// StreamChunkToken, passes chunks on as the provider sends them.
+func WithStreamChunking(mode StreamChunking) ClientOption {
+	return func(c *Client) {
+		c.streamChunking = mode
+	}
+}
+
+// WithTokenizer counts tokens locally with tokenizer, for CountTokens, chat session budgets and
+// WithMaxInputTokens, instead of the built-in estimate or a provider's token counting endpoint.
+// WhitespaceTokenizer is available but inaccurate, and the client warns when it is used.
+func WithTokenizer(tokenizer Tokenizer) ClientOption {
+	return func(c *Client) {
+		c.tokenizer = tokenizer
+	}
+}
+
+// WithAutoTrim drops the oldest non-system messages of every completion whose prompt and
+// MaxTokens don't fit the context window of its model, as CompletionInput.AutoTrim does for a
+// single request. Ollama reports the context window of its models with /api/show; models with
+// an unknown context window are sent as they are.
+func WithAutoTrim(enabled bool) ClientOption {
+	return func(c *Client) {
+		c.autoTrim = enabled
+	}
+}
+
+// WithStreamResume reconnects streaming completions interrupted by a network error up to
+// maxResumes times each, instead of ending them with the error. Providers that accept an
+// assistant prefill, such as Anthropic, continue from the text streamed so far; the others are
+// only resumed before any text was streamed. A chunk with Resumed set marks each reconnection.
+func WithStreamResume(maxResumes int) ClientOption {
+	return func(c *Client) {
+		c.maxStreamResumes = maxResumes
+	}
+}
+
+// WithAdaptiveSampling passes every response of GenerateCompletion to evaluator, and generates
+// it again with the evaluator's adjusted input, such as a higher or lower temperature, while the
+// evaluator asks for a retry, up to the retries of WithAdaptiveRetries. Streams aren't evaluated.
+func WithAdaptiveSampling(evaluator ResponseEvaluator) ClientOption {
+	return func(c *Client) {
+		c.evaluator = evaluator
+	}
+}
+
+// WithAdaptiveRetries sets how many times WithAdaptiveSampling re-issues a completion before
+// returning the last response. The default is 2.
+func WithAdaptiveRetries(n int) ClientOption {
+	return func(c *Client) {
+		c.adaptiveRetries = n
+	}
+}
+
+// WithClientSideStop makes the client enforce CompletionInput.Stop for backends that ignore it:
+// the text is cut before the first stop sequence and FinishReason is set to
+// models.FinishReasonClientStop. Streams hold back the last characters that could begin a stop
+// sequence, and send no more text once one is found, though the stream still ends with its Done
+// chunk.
+func WithClientSideStop(enabled bool) ClientOption {
+	return func(c *Client) {
+		c.clientSideStop = enabled
+	}
+}
+
+// WithDefaultMaxTokens sets the MaxTokens of completions that leave it at zero. A MaxTokens set
+// on the request takes precedence.
+func WithDefaultMaxTokens(maxTokens int) ClientOption {
+	return func(c *Client) {
+		c.defaultMaxTokens = maxTokens
+	}
+}
+
+// WithDefaultTemperature sets the Temperature of completions that leave it nil. A Temperature
+// set on the request, including 0, takes precedence.
+func WithDefaultTemperature(temperature float32) ClientOption {
+	return func(c *Client) {
+		c.defaultTemperature = models.Float32(temperature)
+	}
+}
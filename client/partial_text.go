@@ -0,0 +1,24 @@
+package client
+
+import (
+	"strings"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// partialText accumulates the text of the chunks passed on to the caller of a stream, to set
+// the PartialText of a chunk reporting an error
+type partialText struct {
+	text strings.Builder
+}
+
+// add records the text of chunk, which is about to be passed on, and sets its PartialText if
+// it has an Error. The text of a Done chunk that repeats the whole stream isn't counted twice.
+func (p *partialText) add(chunk *models.StreamingCompletionResponse) {
+	if !chunk.Done || chunk.Text != p.text.String() {
+		p.text.WriteString(chunk.Text)
+	}
+	if chunk.Error != nil {
+		chunk.PartialText = p.text.String()
+	}
+}
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+	"github.com/1broseidon/gollm/providers/openai"
+)
+
+func TestPartialTextOnConnectionReset(t *testing.T) {
+	for _, n := range []int{1, 3} {
+		t.Run(fmt.Sprintf("After%dChunks", n), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				for i := 0; i < n; i++ {
+					fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":\"part %d \"}}]}\n\n", i)
+				}
+				w.(http.Flusher).Flush()
+				// Drop the connection mid-stream
+				conn, _, err := w.(http.Hijacker).Hijack()
+				if err != nil {
+					t.Errorf("Hijack failed: %v", err)
+					return
+				}
+				conn.Close()
+			}))
+			defer server.Close()
+			t.Setenv("OPENAI_API_KEY", "test-key")
+			provider, err := openai.NewOpenAIProvider(openai.WithBaseURL(server.URL))
+			if err != nil {
+				t.Fatalf("Failed to create OpenAI provider: %v", err)
+			}
+			c := newMockClient(t, "openai", provider)
+
+			stream, err := c.GenerateCompletionStream(context.Background(), models.CompletionInput{Model: "openai/gpt-4o", Messages: promptOf(3)})
+			if err != nil {
+				t.Fatalf("GenerateCompletionStream failed: %v", err)
+			}
+			var want strings.Builder
+			var failed *models.StreamingCompletionResponse
+			for chunk := range stream {
+				if chunk.Error != nil {
+					failed = &chunk
+					continue
+				}
+				if chunk.PartialText != "" {
+					t.Errorf("Expected no PartialText without an error, got %q", chunk.PartialText)
+				}
+				want.WriteString(chunk.Text)
+			}
+			if failed == nil {
+				t.Fatal("Expected the stream to report the dropped connection")
+			}
+			if want.String() != expectedParts(n) || failed.PartialText != want.String() {
+				t.Errorf("Expected PartialText %q, got %q", expectedParts(n), failed.PartialText)
+			}
+		})
+	}
+}
+
+// expectedParts returns the text of the first n chunks sent by the server of
+// TestPartialTextOnConnectionReset
+func expectedParts(n int) string {
+	var parts strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&parts, "part %d ", i)
+	}
+	return parts.String()
+}
+
+func TestPartialText(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []models.StreamingCompletionResponse
+		want   string
+	}{
+		{
+			name:   "ErrorChunk",
+			chunks: []models.StreamingCompletionResponse{{Text: "Hello, "}, {Text: "wor"}, {Text: "ld", Error: io.ErrUnexpectedEOF}},
+			want:   "Hello, world",
+		},
+		{
+			name:   "FullTextOnDone",
+			chunks: []models.StreamingCompletionResponse{{Text: "Hello, "}, {Text: "world"}, {Text: "Hello, world", Done: true, Error: errors.New("invalid tool call")}},
+			want:   "Hello, world",
+		},
+		{
+			name:   "BeforeAnyText",
+			chunks: []models.StreamingCompletionResponse{{Error: io.ErrUnexpectedEOF}},
+			want:   "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockClient(t, "mock", &mockProvider{stream: streamChunks(tt.chunks...)})
+
+			stream, err := c.GenerateCompletionStream(context.Background(), models.CompletionInput{Model: "mock/model", Messages: promptOf(3)})
+			if err != nil {
+				t.Fatalf("GenerateCompletionStream failed: %v", err)
+			}
+			var errorChunks int
+			for chunk := range stream {
+				if chunk.Error == nil {
+					continue
+				}
+				errorChunks++
+				if chunk.PartialText != tt.want {
+					t.Errorf("Expected PartialText %q, got %q", tt.want, chunk.PartialText)
+				}
+			}
+			if errorChunks != 1 {
+				t.Errorf("Expected 1 error chunk, got %d", errorChunks)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package client
+
+import (
+	"context"
+
+	"github.com/1broseidon/gollm/internal/utils"
+)
+
+// PromptMetricsMiddleware returns hooks that measure the prompt of every completion and chat request.
+// Before the request is sent it reports an EventPromptTokenCount with an estimated token count, and
+// once the provider returns usage it reports an EventTokenUsage with the actual prompt and completion
+// tokens. Events are passed to emit; WithPromptMetrics installs the middleware with the client's
+// OnEvent hooks as the destination.
+func PromptMetricsMiddleware(emit func(ctx context.Context, event Event)) Hooks {
+	return Hooks{
+		BeforeRequest: func(ctx context.Context, info RequestInfo) context.Context {
+			if len(info.Messages) > 0 {
+				emit(ctx, Event{
+					Type:         EventPromptTokenCount,
+					Request:      info,
+					PromptTokens: utils.EstimatePromptTokens(info.Messages),
+				})
+			}
+			return ctx
+		},
+		AfterRequest: func(ctx context.Context, info RequestInfo, result RequestResult) {
+			if result.Usage == nil {
+				return
+			}
+			event := Event{
+				Type:             EventTokenUsage,
+				Request:          info,
+				PromptTokens:     result.Usage.PromptTokens,
+				CompletionTokens: result.Usage.CompletionTokens,
+			}
+			if event.CompletionTokens > 0 {
+				event.PromptCompletionRatio = float64(event.PromptTokens) / float64(event.CompletionTokens)
+			}
+			emit(ctx, event)
+		},
+	}
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+func TestPromptMetrics(t *testing.T) {
+	ctx := context.Background()
+
+	messages := []models.ChatMessage{
+		{Role: "system", Content: "You are a helpful assistant."},
+		{Role: "user", Content: "Please summarize the following paragraph in two sentences. The city council met on Tuesday " +
+			"to discuss the new budget, and after a long debate they agreed to spend more money on parks, roads and public schools next year."},
+	}
+	// The prompt tokens for messages under cl100k_base, including the chat message framing
+	const actualPromptTokens = 59
+
+	provider := &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			return &models.CompletionResponse{
+				Text:  "The council agreed a new budget.",
+				Usage: &models.Usage{PromptTokens: actualPromptTokens, CompletionTokens: 20, TotalTokens: actualPromptTokens + 20},
+			}, nil
+		},
+	}
+
+	var mu sync.Mutex
+	var events []Event
+	record := Hooks{OnEvent: func(ctx context.Context, event Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}}
+	logger := &recordingLogger{}
+	c := newMockClient(t, "mock", provider, WithHooks(record), WithPromptMetrics(), WithLogger(logger))
+
+	if _, err := c.GenerateCompletion(ctx, models.CompletionInput{Model: "mock/model", Messages: messages}); err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d: %+v", len(events), events)
+	}
+
+	before := events[0]
+	if before.Type != EventPromptTokenCount || before.Request.Model != "model" {
+		t.Errorf("Unexpected first event: %+v", before)
+	}
+	if diff := math.Abs(float64(before.PromptTokens-actualPromptTokens)) / actualPromptTokens; diff > 0.2 {
+		t.Errorf("Estimated %d prompt tokens, more than 20%% off the actual %d", before.PromptTokens, actualPromptTokens)
+	}
+
+	after := events[1]
+	if after.Type != EventTokenUsage || after.PromptTokens != actualPromptTokens || after.CompletionTokens != 20 {
+		t.Errorf("Unexpected usage event: %+v", after)
+	}
+	if want := float64(actualPromptTokens) / 20; after.PromptCompletionRatio != want {
+		t.Errorf("Expected prompt/completion ratio %v, got %v", want, after.PromptCompletionRatio)
+	}
+
+	if !logger.contains("DEBUG: ", string(EventPromptTokenCount)) {
+		t.Error("Expected the prompt token count to be logged at debug level")
+	}
+}
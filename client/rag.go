@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/1broseidon/gollm/models"
+	"github.com/1broseidon/gollm/vectorstore"
+)
+
+// DefaultRAGContextTemplate renders the documents retrieved by GenerateWithContext as a numbered list
+const DefaultRAGContextTemplate = `Relevant context:
+{{range .Documents}}{{.Number}}. {{.Text}}
+{{end}}`
+
+// RAGContext is the data a RAG context template is executed with
+type RAGContext struct {
+	Query     string        // The user message the documents were retrieved for
+	Documents []RAGDocument // The retrieved documents, most relevant first
+}
+
+// RAGDocument is a document retrieved by GenerateWithContext
+type RAGDocument struct {
+	vectorstore.SearchResult
+	Number int // The position of the document in the results, starting at 1
+}
+
+// GenerateWithContext answers input with retrieval-augmented generation. It embeds the last user
+// message, retrieves the topK most similar documents from store and prepends them to the
+// conversation as a system message before calling GenerateCompletion. If the store has no
+// documents, the input is sent unchanged. The query is embedded with the provider set by
+// WithRAGEmbeddingProvider and the context is rendered with the template set by
+// WithRAGContextTemplate.
+func (c *Client) GenerateWithContext(ctx context.Context, input models.CompletionInput, store *vectorstore.VectorStore, topK int) (*models.CompletionResponse, error) {
+	if topK < 1 {
+		return nil, fmt.Errorf("topK must be at least 1, got %d", topK)
+	}
+	query, ok := lastUserMessage(input.Messages)
+	if !ok {
+		return nil, errors.New("no user message to retrieve context for")
+	}
+	tmpl, err := c.ragContextTemplate()
+	if err != nil {
+		return nil, err
+	}
+
+	embedding, err := c.embedQuery(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed the query: %w", err)
+	}
+	results := store.Search(ctx, embedding, topK)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		c.logger.Debug("No documents retrieved; generating without context")
+		return c.GenerateCompletion(ctx, input)
+	}
+
+	data := RAGContext{Query: query, Documents: make([]RAGDocument, len(results))}
+	for i, result := range results {
+		data.Documents[i] = RAGDocument{SearchResult: result, Number: i + 1}
+	}
+	var rendered strings.Builder
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return nil, fmt.Errorf("failed to render the RAG context: %w", err)
+	}
+	c.logger.Debugf("Retrieved %d documents for the query", len(results))
+
+	contextMessage := models.ChatMessage{Role: models.RoleSystem, Content: strings.TrimRight(rendered.String(), "\n")}
+	input.Messages = append([]models.ChatMessage{contextMessage}, input.Messages...)
+	return c.GenerateCompletion(ctx, input)
+}
+
+// embedQuery embeds a query with the RAG embedding provider, or the default provider if none is set
+func (c *Client) embedQuery(ctx context.Context, query string) ([]float32, error) {
+	if c.ragEmbedder == "" {
+		return c.GenerateEmbedding(ctx, query)
+	}
+	p, err := c.initializeProvider(ctx, c.ragEmbedder)
+	if err != nil {
+		return nil, err
+	}
+	return c.generateEmbedding(ctx, c.ragEmbedder, p, query)
+}
+
+// ragContextTemplate parses the template set by WithRAGContextTemplate, or the default
+func (c *Client) ragContextTemplate() (*template.Template, error) {
+	text := c.ragTemplate
+	if text == "" {
+		text = DefaultRAGContextTemplate
+	}
+	tmpl, err := template.New("rag").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RAG context template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// lastUserMessage returns the content of the last user message
+func lastUserMessage(messages []models.ChatMessage) (string, bool) {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == models.RoleUser {
+			return messages[i].Content, true
+		}
+	}
+	return "", false
+}
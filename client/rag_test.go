@@ -0,0 +1,155 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+	"github.com/1broseidon/gollm/vectorstore"
+)
+
+// topicEmbedding embeds text as a vector of the topics it mentions
+func topicEmbedding(ctx context.Context, input string) ([]float32, error) {
+	topics := []string{"cat", "dog", "rocket"}
+	vec := make([]float32, len(topics))
+	for i, topic := range topics {
+		if strings.Contains(strings.ToLower(input), topic) {
+			vec[i] = 1
+		}
+	}
+	return vec, nil
+}
+
+// newRAGStore returns a store of documents embedded with topicEmbedding
+func newRAGStore(t *testing.T) *vectorstore.VectorStore {
+	store := vectorstore.NewVectorStore()
+	for id, text := range map[string]string{
+		"cats":    "Cats sleep for most of the day.",
+		"dogs":    "Dogs descend from wolves.",
+		"rockets": "Rockets carry their own oxidizer.",
+		"pets":    "A cat and a dog can share a home.",
+	} {
+		vec, _ := topicEmbedding(context.Background(), text)
+		store.Insert(context.Background(), id, text, vec)
+	}
+	return store
+}
+
+func TestGenerateWithContext(t *testing.T) {
+	ctx := context.Background()
+
+	var received models.CompletionInput
+	provider := &mockProvider{
+		embedding: topicEmbedding,
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			received = input
+			return &models.CompletionResponse{Text: "ok"}, nil
+		},
+	}
+	c := newMockClient(t, "mock", provider)
+	store := newRAGStore(t)
+
+	messages := []models.ChatMessage{
+		{Role: models.RoleSystem, Content: "Be brief."},
+		{Role: models.RoleUser, Content: "Tell me about rockets."},
+		{Role: models.RoleAssistant, Content: "What about them?"},
+		{Role: models.RoleUser, Content: "How long does a cat sleep?"},
+	}
+	input := models.CompletionInput{Model: "mock/model", Messages: messages}
+	if _, err := c.GenerateWithContext(ctx, input, store, 2); err != nil {
+		t.Fatalf("GenerateWithContext failed: %v", err)
+	}
+
+	// The last user message is the query: the cat document, then the one that also mentions dogs
+	want := "Relevant context:\n1. Cats sleep for most of the day.\n2. A cat and a dog can share a home."
+	if len(received.Messages) != len(messages)+1 {
+		t.Fatalf("Expected the context message before %d messages, got %+v", len(messages), received.Messages)
+	}
+	if got := received.Messages[0]; got.Role != models.RoleSystem || got.Content != want {
+		t.Errorf("Expected the context message %q, got %+v", want, got)
+	}
+	for i, message := range messages {
+		if got := received.Messages[i+1]; got.Role != message.Role || got.Content != message.Content {
+			t.Errorf("Expected message %d to be %+v, got %+v", i, message, received.Messages[i+1])
+		}
+	}
+	if len(input.Messages) != len(messages) || input.Messages[0].Role != models.RoleSystem {
+		t.Error("GenerateWithContext modified the caller's messages")
+	}
+
+	// An empty store adds no context
+	if _, err := c.GenerateWithContext(ctx, input, vectorstore.NewVectorStore(), 2); err != nil {
+		t.Fatalf("GenerateWithContext failed: %v", err)
+	}
+	if len(received.Messages) != len(messages) {
+		t.Errorf("Expected no context message for an empty store, got %+v", received.Messages)
+	}
+}
+
+func TestGenerateWithContextOptions(t *testing.T) {
+	ctx := context.Background()
+
+	var received models.CompletionInput
+	completion := &mockProvider{
+		embedding: func(ctx context.Context, input string) ([]float32, error) {
+			return nil, errors.New("embeddings not supported")
+		},
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			received = input
+			return &models.CompletionResponse{Text: "ok"}, nil
+		},
+	}
+	embedder := &mockProvider{embedding: topicEmbedding}
+	tmpl := `Sources:{{range .Documents}} [{{.ID}} {{printf "%.1f" .Score}}]{{end}} for "{{.Query}}"`
+	c := newMockClient(t, "mock", completion, WithRAGEmbeddingProvider("embedder"), WithRAGContextTemplate(tmpl))
+	c.RegisterProvider("embedder", embedder)
+
+	input := models.CompletionInput{
+		Model:    "mock/model",
+		Messages: []models.ChatMessage{{Role: models.RoleUser, Content: "Rockets?"}},
+	}
+	if _, err := c.GenerateWithContext(ctx, input, newRAGStore(t), 1); err != nil {
+		t.Fatalf("GenerateWithContext failed: %v", err)
+	}
+	want := `Sources: [rockets 1.0] for "Rockets?"`
+	if len(received.Messages) != 2 || received.Messages[0].Content != want {
+		t.Errorf("Expected the context %q, got %+v", want, received.Messages)
+	}
+}
+
+func TestGenerateWithContextErrors(t *testing.T) {
+	ctx := context.Background()
+	provider := &mockProvider{
+		embedding: topicEmbedding,
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			t.Error("Expected no completion")
+			return nil, nil
+		},
+	}
+	store := newRAGStore(t)
+	input := models.CompletionInput{
+		Model:    "mock/model",
+		Messages: []models.ChatMessage{{Role: models.RoleUser, Content: "Cats?"}},
+	}
+
+	c := newMockClient(t, "mock", provider)
+	if _, err := c.GenerateWithContext(ctx, input, store, 0); err == nil {
+		t.Error("Expected an error for topK 0")
+	}
+	system := models.CompletionInput{Model: "mock/model", Messages: []models.ChatMessage{{Role: models.RoleSystem, Content: "Hi"}}}
+	if _, err := c.GenerateWithContext(ctx, system, store, 1); err == nil {
+		t.Error("Expected an error without a user message")
+	}
+
+	c = newMockClient(t, "mock", provider, WithRAGContextTemplate("{{.Missing"))
+	if _, err := c.GenerateWithContext(ctx, input, store, 1); err == nil || !strings.Contains(err.Error(), "template") {
+		t.Errorf("Expected a template error, got %v", err)
+	}
+
+	c = newMockClient(t, "mock", provider, WithRAGEmbeddingProvider("missing"))
+	if _, err := c.GenerateWithContext(ctx, input, store, 1); !errors.Is(err, ErrUnsupportedProvider) {
+		t.Errorf("Expected ErrUnsupportedProvider for an unknown embedding provider, got %v", err)
+	}
+}
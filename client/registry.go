@@ -0,0 +1,46 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// ProviderFactory creates a provider registered with RegisterProviderFactory
+type ProviderFactory func(ctx context.Context) (Provider, error)
+
+var (
+	providerFactoriesMu sync.RWMutex
+	providerFactories   = make(map[string]ProviderFactory)
+)
+
+// RegisterProviderFactory makes name available as a provider to every client. The provider is
+// created with factory on the client's first request for a "name/model" and closed with the
+// client. It is meant to be called from the init function of a provider package:
+//
+//	func init() { client.RegisterProviderFactory("myprovider", New) }
+//
+// It panics if factory is nil, or if name is built in or already registered.
+func RegisterProviderFactory(name string, factory ProviderFactory) {
+	if factory == nil {
+		panic("client: RegisterProviderFactory factory is nil")
+	}
+	if _, ok := lookupBuiltinProvider(name); ok {
+		panic(fmt.Sprintf("client: RegisterProviderFactory called for built-in provider %q", name))
+	}
+
+	providerFactoriesMu.Lock()
+	defer providerFactoriesMu.Unlock()
+	if _, ok := providerFactories[name]; ok {
+		panic(fmt.Sprintf("client: RegisterProviderFactory called twice for provider %q", name))
+	}
+	providerFactories[name] = factory
+}
+
+// lookupProviderFactory returns the factory registered for name, if any
+func lookupProviderFactory(name string) (ProviderFactory, bool) {
+	providerFactoriesMu.RLock()
+	defer providerFactoriesMu.RUnlock()
+	factory, ok := providerFactories[name]
+	return factory, ok
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// unregisterProviderFactories removes the factories registered by a test once it ends, so it
+// can run again
+func unregisterProviderFactories(t *testing.T, names ...string) {
+	t.Cleanup(func() {
+		providerFactoriesMu.Lock()
+		defer providerFactoriesMu.Unlock()
+		for _, name := range names {
+			delete(providerFactories, name)
+		}
+	})
+}
+
+func TestRegisterProviderFactory(t *testing.T) {
+	clearProviderEnv(t)
+	unregisterProviderFactories(t, "custom", "broken")
+	ctx := context.Background()
+
+	created, closed := 0, 0
+	RegisterProviderFactory("custom", func(ctx context.Context) (Provider, error) {
+		created++
+		return &mockProvider{
+			completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+				return &models.CompletionResponse{Text: "hello from " + modelName}, nil
+			},
+			stream: streamChunks(models.StreamingCompletionResponse{Text: "streamed", Done: true}),
+			closeFunc: func() error {
+				closed++
+				return nil
+			},
+		}, nil
+	})
+	RegisterProviderFactory("broken", func(ctx context.Context) (Provider, error) {
+		return nil, errors.New("no endpoint configured")
+	})
+
+	c, err := NewClient(ctx, WithLogger(&recordingLogger{}))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	resp, err := c.GenerateCompletion(ctx, models.CompletionInput{Model: "custom/my-model", Messages: promptOf(3)})
+	if err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+	if resp.Text != "hello from my-model" || resp.Model != "custom/my-model" {
+		t.Errorf("Unexpected response %+v", resp)
+	}
+
+	stream, err := c.GenerateCompletionStream(ctx, models.CompletionInput{Model: "custom/my-model", Messages: promptOf(3)})
+	if err != nil {
+		t.Fatalf("GenerateCompletionStream failed: %v", err)
+	}
+	var text string
+	for chunk := range stream {
+		text += chunk.Text
+	}
+	if text != "streamed" {
+		t.Errorf("Expected the streamed text, got %q", text)
+	}
+	if created != 1 {
+		t.Errorf("Expected the provider to be created once, got %d", created)
+	}
+
+	if _, err := c.GenerateCompletion(ctx, models.CompletionInput{Model: "broken/model", Messages: promptOf(3)}); err == nil || !strings.Contains(err.Error(), "no endpoint configured") {
+		t.Errorf("Expected the factory error, got %v", err)
+	}
+	if _, err := c.GenerateCompletion(ctx, models.CompletionInput{Model: "unknown/model", Messages: promptOf(3)}); !errors.Is(err, ErrUnsupportedProvider) {
+		t.Errorf("Expected ErrUnsupportedProvider for an unregistered provider, got %v", err)
+	}
+
+	c.Close()
+	if closed != 1 {
+		t.Errorf("Expected Close to close the provider once, got %d", closed)
+	}
+}
+
+func TestRegisterProviderFactoryPanics(t *testing.T) {
+	unregisterProviderFactories(t, "twice")
+	factory := func(ctx context.Context) (Provider, error) { return &mockProvider{}, nil }
+	RegisterProviderFactory("twice", factory)
+
+	tests := []struct {
+		name     string
+		provider string
+		factory  ProviderFactory
+	}{
+		{"Nil", "nil-factory", nil},
+		{"BuiltIn", "openai", factory},
+		{"Twice", "twice", factory},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Expected RegisterProviderFactory to panic")
+				}
+			}()
+			RegisterProviderFactory(tt.provider, tt.factory)
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package client
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/1broseidon/gollm/internal/logging"
+)
+
+// maxLoggedBody is the number of bytes of each request and response body RedactingTransport logs
+const maxLoggedBody = 200
+
+// redactedHeaders are the headers carrying credentials, whose values RedactingTransport never logs
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "X-Api-Key", "X-Goog-Api-Key", "Api-Key"}
+
+// RedactingTransport logs each request and response at debug level: the method, URL, headers
+// and the first 200 bytes of the body. The values of the Authorization, Proxy-Authorization,
+// X-Api-Key, X-Goog-Api-Key and Api-Key headers, and of RedactHeaders, are replaced by
+// [REDACTED], as are the values of the URL's query parameters, which may carry keys too.
+// Response bodies are logged once they are closed, so streams are not held back.
+type RedactingTransport struct {
+	// Base is the transport that sends the requests; nil means http.DefaultTransport
+	Base http.RoundTripper
+	// Logger receives the log lines
+	Logger logging.Logger
+	// RedactHeaders names further headers to redact, matched case-insensitively
+	RedactHeaders []string
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *RedactingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	sent := req
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody != nil {
+			if copied, err := req.GetBody(); err == nil {
+				body, _ = io.ReadAll(io.LimitReader(copied, maxLoggedBody))
+				copied.Close()
+			}
+		} else {
+			// Put back the bytes read for the log; RoundTrippers must not modify the caller's request
+			var err error
+			body, err = io.ReadAll(io.LimitReader(req.Body, maxLoggedBody))
+			if err != nil {
+				req.Body.Close()
+				return nil, err
+			}
+			sent = req.Clone(req.Context())
+			sent.Body = &readCloser{Reader: io.MultiReader(bytes.NewReader(body), req.Body), Closer: req.Body}
+		}
+	}
+	loggedURL := redactURL(req.URL)
+	t.Logger.Debugf("HTTP request: %s %s headers: %s body: %q", req.Method, loggedURL, t.formatHeaders(req.Header), body)
+
+	resp, err := base.RoundTrip(sent)
+	if err != nil {
+		// The error of a failed request repeats its URL
+		t.Logger.Debugf("HTTP request %s %s failed: %v", req.Method, loggedURL, strings.ReplaceAll(err.Error(), req.URL.String(), loggedURL))
+		return nil, err
+	}
+	t.Logger.Debugf("HTTP response: %s %s status: %s headers: %s", req.Method, loggedURL, resp.Status, t.formatHeaders(resp.Header))
+	if resp.Body != nil {
+		resp.Body = &loggingBody{ReadCloser: resp.Body, logger: t.Logger, method: req.Method, url: loggedURL}
+	}
+	return resp, nil
+}
+
+// redactURL returns u for the log, with the values of its query parameters and its password
+// replaced by [REDACTED]
+func redactURL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.Redacted()
+	}
+	query, _ := url.ParseQuery(u.RawQuery)
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fields := make([]string, len(names))
+	for i, name := range names {
+		fields[i] = url.QueryEscape(name) + "=[REDACTED]"
+	}
+	redacted := *u
+	redacted.RawQuery = strings.Join(fields, "&")
+	return redacted.Redacted()
+}
+
+// formatHeaders returns the headers sorted by name, with the values of sensitive ones redacted
+func (t *RedactingTransport) formatHeaders(header http.Header) string {
+	redact := make(map[string]bool, len(redactedHeaders)+len(t.RedactHeaders))
+	for _, name := range append(append([]string(nil), redactedHeaders...), t.RedactHeaders...) {
+		redact[http.CanonicalHeaderKey(name)] = true
+	}
+
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fields := make([]string, len(names))
+	for i, name := range names {
+		value := strings.Join(header[name], ", ")
+		if redact[http.CanonicalHeaderKey(name)] {
+			value = "[REDACTED]"
+		}
+		fields[i] = name + ": " + value
+	}
+	return "{" + strings.Join(fields, "; ") + "}"
+}
+
+// readCloser reads from Reader and closes Closer
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// loggingBody keeps the first bytes read from a response body and logs them when it is closed
+type loggingBody struct {
+	io.ReadCloser
+	logger      logging.Logger
+	method, url string
+	head        []byte
+	once        sync.Once
+}
+
+// Read implements io.Reader
+func (b *loggingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if keep := min(n, maxLoggedBody-len(b.head)); keep > 0 {
+		b.head = append(b.head, p[:keep]...)
+	}
+	return n, err
+}
+
+// Close implements io.Closer
+func (b *loggingBody) Close() error {
+	b.once.Do(func() {
+		b.logger.Debugf("HTTP response body: %s %s body: %q", b.method, b.url, b.head)
+	})
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,145 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/1broseidon/gollm/common"
+	"github.com/1broseidon/gollm/internal/clock"
+	"github.com/1broseidon/gollm/models"
+	"github.com/1broseidon/gollm/providers/anthropic"
+	"github.com/1broseidon/gollm/providers/openai"
+)
+
+func TestRequestLoggingRedactsAPIKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		provider func(httpClient *http.Client, baseURL string) (Provider, error)
+	}{
+		{"OpenAI", `{"choices":[{"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4}}`, func(httpClient *http.Client, baseURL string) (Provider, error) {
+			return openai.NewOpenAIProvider(openai.WithAPIKey("sk-secret-key"), openai.WithBaseURL(baseURL), openai.WithHTTPClient(httpClient))
+		}},
+		{"Anthropic", `{"content":[{"type":"text","text":"hi"}],"usage":{"input_tokens":3,"output_tokens":1}}`, func(httpClient *http.Client, baseURL string) (Provider, error) {
+			return anthropic.NewAnthropicProvider(anthropic.WithAPIKey("sk-secret-key"), anthropic.WithBaseURL(baseURL), anthropic.WithHTTPClient(httpClient))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !strings.Contains(r.Header.Get("Authorization")+r.Header.Get("X-Api-Key"), "sk-secret-key") {
+					t.Errorf("Expected the API key to reach the server, got headers %v", r.Header)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				io.WriteString(w, tt.response)
+			}))
+			defer server.Close()
+
+			logger := &recordingLogger{}
+			c := &Client{clock: clock.Real, logger: logger}
+			WithLogLevel(common.DebugLevel)(c)
+			defer c.closeIdleConnections()
+
+			provider, err := tt.provider(c.httpClient(), server.URL)
+			if err != nil {
+				t.Fatalf("Failed to create provider: %v", err)
+			}
+			resp, err := provider.GenerateCompletion(context.Background(), "model", models.CompletionInput{Messages: promptOf(3)})
+			if err != nil {
+				t.Fatalf("GenerateCompletion failed: %v", err)
+			}
+			if resp.Text != "hi" {
+				t.Errorf("Expected the response to pass through the transport, got %q", resp.Text)
+			}
+
+			if !logger.contains("DEBUG: ", "HTTP request: POST "+server.URL) {
+				t.Errorf("Expected the request URL to be logged, got %q", logger.lines)
+			}
+			if !logger.contains("DEBUG: ", "[REDACTED]") || !logger.contains("DEBUG: ", "HTTP response body: POST "+server.URL) {
+				t.Errorf("Expected the redacted headers and the response body to be logged, got %q", logger.lines)
+			}
+			if logger.contains("", "sk-secret-key") {
+				t.Errorf("Expected the API key to be redacted, got %q", logger.lines)
+			}
+		})
+	}
+}
+
+// leveledLogger is a recordingLogger reporting a level, as loggers given with WithLogger may
+type leveledLogger struct {
+	*recordingLogger
+	level common.LogLevel
+}
+
+func (l leveledLogger) Level() common.LogLevel { return l.level }
+
+func TestRequestLoggingOption(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if len(body) != 300 {
+			t.Errorf("Expected the whole body to reach the server, got %d bytes", len(body))
+		}
+		w.Header().Set("X-Session-Token", "session-secret")
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		options []ClientOption
+		level   common.LogLevel // The level reported by the logger, if any
+		logged  bool
+	}{
+		{"Off", nil, 0, false},
+		{"InfoLevel", []ClientOption{WithLogLevel(common.InfoLevel)}, 0, false},
+		{"Option", []ClientOption{WithRequestLogging([]string{"x-session-token"})}, 0, true},
+		{"DebugLogger", nil, common.DebugLevel, true},
+		{"InfoLogger", nil, common.InfoLevel, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := &recordingLogger{}
+			c := &Client{clock: clock.Real, logger: logger}
+			if tt.level != 0 {
+				WithLogger(leveledLogger{logger, tt.level})(c)
+			}
+			for _, option := range tt.options {
+				option(c)
+			}
+			defer c.closeIdleConnections()
+
+			// A reader without GetBody, which the transport must put back together
+			req, _ := http.NewRequest(http.MethodPost, server.URL+"/v1/complete?key=query-secret&alt=sse", io.NopCloser(strings.NewReader(strings.Repeat("a", 300))))
+			req.Header.Set("X-Session-Token", "client-secret")
+			req.Header.Set("Api-Key", "azure-secret")
+			resp, err := c.httpClient().Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			resp.Body.Close()
+
+			if logged := logger.contains("DEBUG: ", "/v1/complete"); logged != tt.logged {
+				t.Fatalf("Expected request logged to be %v, got %q", tt.logged, logger.lines)
+			}
+			if !tt.logged {
+				return
+			}
+			if !logger.contains("DEBUG: ", `body: "`+strings.Repeat("a", maxLoggedBody)+`"`) {
+				t.Errorf("Expected the first %d bytes of the body to be logged, got %q", maxLoggedBody, logger.lines)
+			}
+			if logger.contains("", "azure-secret") {
+				t.Errorf("Expected the Api-Key header to be redacted, got %q", logger.lines)
+			}
+			// The extra header is only named with WithRequestLogging
+			if c.requestLogging && (logger.contains("", "client-secret") || logger.contains("", "session-secret")) {
+				t.Errorf("Expected the extra headers to be redacted, got %q", logger.lines)
+			}
+			if logger.contains("", "query-secret") || !logger.contains("DEBUG: ", "/v1/complete?alt=[REDACTED]&key=[REDACTED]") {
+				t.Errorf("Expected the query parameters to be redacted, got %q", logger.lines)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// Rerank orders input.Documents by their relevance to input.Query with input.Model, given as
+// "provider/model", returning the TopN most relevant, or all of them if TopN is zero. Each result
+// keeps the index of its document in input.Documents. The provider must implement Reranker.
+func (c *Client) Rerank(ctx context.Context, input models.RerankInput) (*models.RerankResponse, error) {
+	if input.Query == "" {
+		return nil, errors.New("rerank query is empty")
+	}
+	if len(input.Documents) == 0 {
+		return nil, errors.New("no documents to rerank")
+	}
+	if input.TopN < 0 {
+		return nil, fmt.Errorf("invalid TopN %d: must not be negative", input.TopN)
+	}
+
+	provider, model := input.Model, ""
+	if strings.Contains(input.Model, "/") {
+		var err error
+		provider, model, err = c.parseProviderModel(input.Model)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse provider/model: %w", err)
+		}
+	}
+	if provider == "" {
+		c.mu.RLock()
+		provider = c.defaultProvider
+		c.mu.RUnlock()
+		if provider == "" {
+			c.logger.Error("No default provider set")
+			return nil, errors.New("no default provider set")
+		}
+	}
+
+	p, err := c.initializeProvider(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+	reranker, ok := p.(Reranker)
+	if !ok || !capabilitiesOf(p).Rerank {
+		return nil, unsupported(provider, "reranking")
+	}
+	if err := c.checkDryRun("rerank"); err != nil {
+		return nil, err
+	}
+
+	release, err := c.limiter.acquire(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	c.logger.Debugf("Reranking %d documents with provider %s", len(input.Documents), provider)
+	input.Model = model
+	resp, err := reranker.Rerank(ctx, input)
+	if err != nil {
+		c.logger.Error("Failed to rerank documents:", err)
+		return nil, err
+	}
+
+	results, err := rankResults(resp.Results, input.Documents, input.TopN)
+	if err != nil {
+		c.logger.Error("Failed to rerank documents:", err)
+		return nil, err
+	}
+	resp.Results = results
+	resp.Provider = provider
+	if resp.Model == "" {
+		resp.Model = model
+	}
+	return resp, nil
+}
+
+// rankResults checks that each result refers to one of documents, fills in its text, and
+// returns the results by descending relevance, keeping the provider's order for equal scores,
+// truncated to topN unless it is zero
+func rankResults(results []models.RerankResult, documents []string, topN int) ([]models.RerankResult, error) {
+	seen := make(map[int]bool, len(results))
+	ranked := make([]models.RerankResult, len(results))
+	for i, result := range results {
+		if result.Index < 0 || result.Index >= len(documents) {
+			return nil, fmt.Errorf("rerank result refers to document %d of %d", result.Index, len(documents))
+		}
+		if seen[result.Index] {
+			return nil, fmt.Errorf("rerank result refers to document %d more than once", result.Index)
+		}
+		seen[result.Index] = true
+		result.Document = documents[result.Index]
+		ranked[i] = result
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].RelevanceScore > ranked[j].RelevanceScore
+	})
+	if topN > 0 && len(ranked) > topN {
+		ranked = ranked[:topN]
+	}
+	return ranked, nil
+}
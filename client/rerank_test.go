@@ -0,0 +1,166 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// cohereReranker reranks documents with a server speaking Cohere's rerank API
+type cohereReranker struct {
+	completionOnlyProvider
+	baseURL string
+}
+
+func (p *cohereReranker) Rerank(ctx context.Context, input models.RerankInput) (*models.RerankResponse, error) {
+	body, err := json.Marshal(map[string]interface{}{"model": input.Model, "query": input.Query, "documents": input.Documents})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/v2/rerank", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rerank failed with status %s", resp.Status)
+	}
+
+	var result struct {
+		Results []struct {
+			Index          int     `json:"index"`
+			RelevanceScore float64 `json:"relevance_score"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	rerankResp := &models.RerankResponse{}
+	for _, r := range result.Results {
+		rerankResp.Results = append(rerankResp.Results, models.RerankResult{Index: r.Index, RelevanceScore: r.RelevanceScore})
+	}
+	return rerankResp, nil
+}
+
+// newRerankServer returns a server responding to rerank requests with results, in the given order
+func newRerankServer(t *testing.T, results string, requests *[]map[string]interface{}) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&request)
+		*requests = append(*requests, request)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":"rerank-1","results":%s}`, results)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRerank(t *testing.T) {
+	ctx := context.Background()
+	documents := []string{"Paris is in France", "Bananas are yellow", "The capital of France is Paris", "France borders Spain"}
+	// Scores are returned out of order, with a tie between documents 0 and 3
+	results := `[{"index":1,"relevance_score":0.01},{"index":0,"relevance_score":0.6},{"index":3,"relevance_score":0.6},{"index":2,"relevance_score":0.98}]`
+
+	tests := []struct {
+		name    string
+		topN    int
+		indices []int
+	}{
+		{"AllDocuments", 0, []int{2, 0, 3, 1}},
+		{"TopN", 2, []int{2, 0}},
+		{"TopNAboveDocuments", 10, []int{2, 0, 3, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests []map[string]interface{}
+			server := newRerankServer(t, results, &requests)
+			c := newMockClient(t, "cohere", &cohereReranker{completionOnlyProvider{models.Capabilities{Rerank: true}}, server.URL})
+
+			resp, err := c.Rerank(ctx, models.RerankInput{Model: "cohere/rerank-v3.5", Query: "What is the capital of France?", Documents: documents, TopN: tt.topN})
+			if err != nil {
+				t.Fatalf("Rerank failed: %v", err)
+			}
+			if resp.Provider != "cohere" || resp.Model != "rerank-v3.5" {
+				t.Errorf("Expected the provider and model to be set, got %q and %q", resp.Provider, resp.Model)
+			}
+			if len(resp.Results) != len(tt.indices) {
+				t.Fatalf("Expected %d results, got %+v", len(tt.indices), resp.Results)
+			}
+			for i, index := range tt.indices {
+				result := resp.Results[i]
+				if result.Index != index || result.Document != documents[index] {
+					t.Errorf("Expected result %d to be document %d, got %+v", i, index, result)
+				}
+				if i > 0 && result.RelevanceScore > resp.Results[i-1].RelevanceScore {
+					t.Errorf("Expected results by descending relevance, got %+v", resp.Results)
+				}
+			}
+
+			if len(requests) != 1 || requests[0]["model"] != "rerank-v3.5" || requests[0]["query"] != "What is the capital of France?" {
+				t.Errorf("Unexpected requests %v", requests)
+			}
+		})
+	}
+}
+
+func TestRerankErrors(t *testing.T) {
+	ctx := context.Background()
+	documents := []string{"a", "b"}
+
+	t.Run("InvalidInput", func(t *testing.T) {
+		var requests []map[string]interface{}
+		server := newRerankServer(t, `[]`, &requests)
+		c := newMockClient(t, "cohere", &cohereReranker{completionOnlyProvider{models.Capabilities{Rerank: true}}, server.URL})
+		for _, input := range []models.RerankInput{
+			{Model: "cohere/rerank-v3.5", Documents: documents},
+			{Model: "cohere/rerank-v3.5", Query: "q"},
+			{Model: "cohere/rerank-v3.5", Query: "q", Documents: documents, TopN: -1},
+		} {
+			if _, err := c.Rerank(ctx, input); err == nil {
+				t.Errorf("Expected an error for %+v", input)
+			}
+		}
+		if len(requests) != 0 {
+			t.Errorf("Expected no requests for invalid input, got %d", len(requests))
+		}
+	})
+
+	t.Run("IndexOutOfRange", func(t *testing.T) {
+		var requests []map[string]interface{}
+		server := newRerankServer(t, `[{"index":0,"relevance_score":0.5},{"index":2,"relevance_score":0.4}]`, &requests)
+		c := newMockClient(t, "cohere", &cohereReranker{completionOnlyProvider{models.Capabilities{Rerank: true}}, server.URL})
+		if _, err := c.Rerank(ctx, models.RerankInput{Model: "cohere/rerank-v3.5", Query: "q", Documents: documents}); err == nil {
+			t.Error("Expected an error for a result referring to a missing document")
+		}
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		c := newMockClient(t, "plain", &completionOnlyProvider{models.Capabilities{Streaming: true}})
+		if _, err := c.Rerank(ctx, models.RerankInput{Model: "plain/model", Query: "q", Documents: documents}); !errors.Is(err, ErrUnsupportedOperation) {
+			t.Errorf("Expected ErrUnsupportedOperation, got %v", err)
+		}
+	})
+
+	t.Run("DryRun", func(t *testing.T) {
+		var requests []map[string]interface{}
+		server := newRerankServer(t, `[]`, &requests)
+		c := newMockClient(t, "cohere", &cohereReranker{completionOnlyProvider{models.Capabilities{Rerank: true}}, server.URL}, WithDryRun())
+		if _, err := c.Rerank(ctx, models.RerankInput{Model: "cohere/rerank-v3.5", Query: "q", Documents: documents}); !errors.Is(err, ErrDryRun) {
+			t.Errorf("Expected ErrDryRun, got %v", err)
+		}
+		if len(requests) != 0 {
+			t.Errorf("Expected no requests in dry-run mode, got %d", len(requests))
+		}
+	})
+}
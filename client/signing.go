@@ -0,0 +1,102 @@
+package client
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/1broseidon/gollm/internal/clock"
+)
+
+// SigningAlgorithm is the HMAC used by WithRequestSigning
+type SigningAlgorithm int
+
+const (
+	// SigningAlgorithmHMACSHA256 signs with HMAC-SHA256 and hashes bodies with SHA-256
+	SigningAlgorithmHMACSHA256 SigningAlgorithm = iota
+	// SigningAlgorithmHMACSHA512 signs with HMAC-SHA512 and hashes bodies with SHA-512
+	SigningAlgorithmHMACSHA512
+)
+
+// String returns the name sent in the Authorization header, e.g. "hmac-sha256"
+func (a SigningAlgorithm) String() string {
+	switch a {
+	case SigningAlgorithmHMACSHA256:
+		return "hmac-sha256"
+	case SigningAlgorithmHMACSHA512:
+		return "hmac-sha512"
+	}
+	return fmt.Sprintf("SigningAlgorithm(%d)", int(a))
+}
+
+// hash returns the hash function of the algorithm
+func (a SigningAlgorithm) hash() (func() hash.Hash, error) {
+	switch a {
+	case SigningAlgorithmHMACSHA256:
+		return sha256.New, nil
+	case SigningAlgorithmHMACSHA512:
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("unsupported signing algorithm %v", a)
+}
+
+// signingTransport signs each request with an HMAC of its method, URL, body hash and timestamp
+type signingTransport struct {
+	base      http.RoundTripper
+	keyID     string
+	secretKey []byte
+	algorithm SigningAlgorithm
+	clock     clock.Clock
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	newHash, err := t.algorithm.hash()
+	if err != nil {
+		closeBody(req)
+		return nil, err
+	}
+
+	var body []byte
+	if req.Body != nil {
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body for signing: %w", err)
+		}
+	}
+	bodyHash := newHash()
+	bodyHash.Write(body)
+
+	timestamp := strconv.FormatInt(t.clock.Now().Unix(), 10)
+	mac := hmac.New(newHash, t.secretKey)
+	io.WriteString(mac, req.Method+"\n"+req.URL.String()+"\n"+hex.EncodeToString(bodyHash.Sum(nil))+"\n"+timestamp)
+
+	// RoundTrippers must not modify the caller's request
+	signed := req.Clone(req.Context())
+	if req.Body != nil {
+		signed.Body = io.NopCloser(bytes.NewReader(body))
+		signed.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	signed.Header.Set("X-Timestamp", timestamp)
+	signed.Header.Set("X-Key-ID", t.keyID)
+	signed.Header.Set("Authorization", fmt.Sprintf(`Sig keyId="%s", algorithm="%s", signature="%s"`,
+		t.keyID, t.algorithm, hex.EncodeToString(mac.Sum(nil))))
+	return t.base.RoundTrip(signed)
+}
+
+// closeBody closes the body of a request that will not be sent, as RoundTrippers must
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
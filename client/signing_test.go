@@ -0,0 +1,98 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/1broseidon/gollm/internal/clock"
+)
+
+// verifySignature checks a signed request the way a proxy holding secretKey would
+func verifySignature(r *http.Request, body []byte, secretKey string, newHash func() hash.Hash, algorithm string) error {
+	bodyHash := newHash()
+	bodyHash.Write(body)
+	url := "http://" + r.Host + r.URL.RequestURI()
+	mac := hmac.New(newHash, []byte(secretKey))
+	io.WriteString(mac, r.Method+"\n"+url+"\n"+hex.EncodeToString(bodyHash.Sum(nil))+"\n"+r.Header.Get("X-Timestamp"))
+
+	want := fmt.Sprintf(`Sig keyId="%s", algorithm="%s", signature="%s"`, r.Header.Get("X-Key-ID"), algorithm, hex.EncodeToString(mac.Sum(nil)))
+	if got := r.Header.Get("Authorization"); !hmac.Equal([]byte(got), []byte(want)) {
+		return fmt.Errorf("expected Authorization %q, got %q", want, got)
+	}
+	return nil
+}
+
+func TestRequestSigning(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm SigningAlgorithm
+		newHash   func() hash.Hash
+	}{
+		{"HMACSHA256", SigningAlgorithmHMACSHA256, sha256.New},
+		{"HMACSHA512", SigningAlgorithmHMACSHA512, sha512.New},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if string(body) != `{"prompt":"hi"}` {
+					t.Errorf("Expected the body to reach the server intact, got %q", body)
+				}
+				if r.Header.Get("X-Key-ID") != "key-1" || r.Header.Get("X-Timestamp") != "1700000000" {
+					t.Errorf("Unexpected signing headers %v", r.Header)
+				}
+				if err := verifySignature(r, body, "s3cret", tt.newHash, tt.algorithm.String()); err != nil {
+					t.Error(err)
+				}
+			}))
+			defer server.Close()
+
+			c := &Client{clock: clock.NewFake(time.Unix(1700000000, 0))}
+			WithRequestSigning("key-1", "s3cret", tt.algorithm)(c)
+			defer c.closeIdleConnections()
+
+			req, _ := http.NewRequest(http.MethodPost, server.URL+"/api/generate?stream=false", strings.NewReader(`{"prompt":"hi"}`))
+			req.Header.Set("Authorization", "Bearer original")
+			resp, err := c.httpClient().Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			resp.Body.Close()
+			if req.Header.Get("Authorization") != "Bearer original" {
+				t.Error("Signing modified the caller's request")
+			}
+		})
+	}
+}
+
+func TestRequestSigningRejectsWrongKey(t *testing.T) {
+	var verifyErr error
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		verifyErr = verifySignature(r, body, "other-secret", sha256.New, "hmac-sha256")
+	}))
+	defer server.Close()
+
+	c := &Client{clock: clock.Real}
+	WithRequestSigning("key-1", "s3cret", SigningAlgorithmHMACSHA256)(c)
+	defer c.closeIdleConnections()
+
+	resp, err := c.httpClient().Get(server.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if verifyErr == nil {
+		t.Error("Expected the signature to fail verification with a different key")
+	}
+}
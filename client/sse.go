@@ -0,0 +1,152 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// sseChunk is the data of a message event written by WriteSSE
+type sseChunk struct {
+	Text     string `json:"text,omitempty"`
+	Thinking string `json:"thinking,omitempty"`
+}
+
+// sseUsage is the usage in a done event written by WriteSSE
+type sseUsage struct {
+	PromptTokens     int  `json:"prompt_tokens"`
+	CompletionTokens int  `json:"completion_tokens"`
+	TotalTokens      int  `json:"total_tokens"`
+	Estimated        bool `json:"estimated,omitempty"`
+}
+
+// sseDone is the data of the done event written by WriteSSE
+type sseDone struct {
+	Model        string        `json:"model,omitempty"`
+	FinishReason string        `json:"finish_reason,omitempty"`
+	Usage        *sseUsage     `json:"usage,omitempty"`
+	ToolCalls    []sseToolCall `json:"tool_calls,omitempty"`
+}
+
+// sseToolCall is a tool call in a done event written by WriteSSE
+type sseToolCall struct {
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// sseError is the data of the error event written by WriteSSE
+type sseError struct {
+	Error string `json:"error"`
+}
+
+// WriteSSE writes stream to w as Server-Sent Events, flushing each one, until the stream ends
+// or ctx, normally the request's context, is done. Each chunk's text is a message event whose
+// data is {"text": "...", "thinking": "..."}. The stream ends with a done event holding the
+// model, finish_reason, usage and any tool_calls, or an error event holding the error message.
+// Create the stream with ctx, so that a client disconnecting cancels the provider request too.
+// WriteSSE returns the stream's error, ctx's error if the client disconnected, or an error
+// writing the response.
+func WriteSSE(ctx context.Context, w http.ResponseWriter, stream <-chan models.StreamingCompletionResponse) error {
+	writeSSEHeaders(w)
+	rc := http.NewResponseController(w)
+	if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// The client stream sends a final chunk on cancellation; read it so the stream
+			// releases its resources without waiting for Close
+			go drainStream(stream)
+			return ctx.Err()
+		case chunk, ok := <-stream:
+			if !ok {
+				return nil
+			}
+			var err error
+			switch {
+			case chunk.Error != nil:
+				err = writeSSEEvent(w, rc, "error", sseError{Error: chunk.Error.Error()})
+			case chunk.Text != "" || chunk.ThinkingText != "":
+				err = writeSSEEvent(w, rc, "", sseChunk{Text: chunk.Text, Thinking: chunk.ThinkingText})
+			}
+			if err != nil {
+				go drainStream(stream)
+				return err
+			}
+			if chunk.Error != nil {
+				return chunk.Error
+			}
+			if chunk.Done {
+				done := sseDone{Model: chunk.Model, FinishReason: chunk.FinishReason}
+				for _, call := range chunk.ToolCalls {
+					arguments := call.Arguments
+					if len(arguments) == 0 {
+						arguments = json.RawMessage("{}")
+					}
+					done.ToolCalls = append(done.ToolCalls, sseToolCall{ID: call.ID, Name: call.Name, Arguments: arguments})
+				}
+				if chunk.Usage != nil {
+					done.Usage = &sseUsage{
+						PromptTokens:     chunk.Usage.PromptTokens,
+						CompletionTokens: chunk.Usage.CompletionTokens,
+						TotalTokens:      chunk.Usage.TotalTokens,
+						Estimated:        chunk.Usage.Estimated,
+					}
+				}
+				return writeSSEEvent(w, rc, "done", done)
+			}
+		}
+	}
+}
+
+// ServeStream streams a completion of input to the client of r as Server-Sent Events, written
+// by WriteSSE. The completion is cancelled if the client disconnects. An error starting the
+// stream, such as an unknown provider, is sent as an error event, since browsers' EventSource
+// can't read the body of an error response. It returns the error sent, if any.
+func (c *Client) ServeStream(w http.ResponseWriter, r *http.Request, input models.CompletionInput) error {
+	stream, err := c.GenerateCompletionStream(r.Context(), input)
+	if err != nil {
+		writeSSEHeaders(w)
+		if writeErr := writeSSEEvent(w, http.NewResponseController(w), "error", sseError{Error: err.Error()}); writeErr != nil {
+			return writeErr
+		}
+		return err
+	}
+	return WriteSSE(r.Context(), w, stream)
+}
+
+// writeSSEHeaders sets the headers of an event stream, unbuffered by proxies such as nginx
+func writeSSEHeaders(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+}
+
+// writeSSEEvent writes data as the JSON data of an event, of the default message type if event
+// is empty, and flushes it
+func writeSSEEvent(w http.ResponseWriter, rc *http.ResponseController, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if event != "" {
+		if _, err := fmt.Fprintf(w, "event: %s\n", event); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(w, "data: %s\n\n", payload); err != nil {
+		return err
+	}
+	if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
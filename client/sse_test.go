@@ -0,0 +1,170 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// sseEvent is an event read from an event stream
+type sseEvent struct {
+	event, data string
+}
+
+// readSSE reads the events of an event stream until it ends
+func readSSE(t *testing.T, scanner *bufio.Scanner) []sseEvent {
+	var events []sseEvent
+	event := sseEvent{event: "message"}
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			events = append(events, event)
+			event = sseEvent{event: "message"}
+		case strings.HasPrefix(line, "event: "):
+			event.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			event.data += strings.TrimPrefix(line, "data: ")
+		default:
+			t.Errorf("Unexpected line %q", line)
+		}
+	}
+	return events
+}
+
+// serveStream starts a server streaming completions of input from c and returns its URL
+func serveStream(t *testing.T, c *Client, input models.CompletionInput, served chan<- error) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served <- c.ServeStream(w, r, input)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestServeStream(t *testing.T) {
+	provider := &mockProvider{stream: streamChunks(
+		models.StreamingCompletionResponse{Text: "Hel"},
+		models.StreamingCompletionResponse{Text: "lo\n\nworld"},
+		models.StreamingCompletionResponse{Done: true, FinishReason: "stop", Usage: &models.Usage{PromptTokens: 3, CompletionTokens: 2, TotalTokens: 5}},
+	)}
+	c := newMockClient(t, "mock", provider)
+	served := make(chan error, 1)
+	url := serveStream(t, c, models.CompletionInput{Model: "mock/model", Messages: promptOf(3)}, served)
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" || resp.Header.Get("Cache-Control") != "no-cache" {
+		t.Errorf("Unexpected headers %v", resp.Header)
+	}
+
+	events := readSSE(t, bufio.NewScanner(resp.Body))
+	want := []sseEvent{
+		{"message", `{"text":"Hel"}`},
+		{"message", `{"text":"lo\n\nworld"}`},
+		{"done", `{"model":"mock/model","finish_reason":"stop","usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}`},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("Expected %d events, got %v", len(want), events)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("Expected event %d to be %v, got %v", i, want[i], events[i])
+		}
+	}
+	if err := <-served; err != nil {
+		t.Errorf("ServeStream failed: %v", err)
+	}
+}
+
+func TestServeStreamErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		model string
+		data  string
+	}{
+		{"StreamError", "mock/model", `{"error":"upstream reset"}`},
+		{"StartError", "unknown/model", `{"error":"unsupported provider`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &mockProvider{stream: streamChunks(
+				models.StreamingCompletionResponse{Text: "partial"},
+				models.StreamingCompletionResponse{Done: true, Error: errors.New("upstream reset")},
+			)}
+			c := newMockClient(t, "mock", provider)
+			served := make(chan error, 1)
+			url := serveStream(t, c, models.CompletionInput{Model: tt.model, Messages: promptOf(3)}, served)
+
+			resp, err := http.Get(url)
+			if err != nil {
+				t.Fatalf("GET failed: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("Expected errors to be sent as events, got status %d", resp.StatusCode)
+			}
+			events := readSSE(t, bufio.NewScanner(resp.Body))
+			last := events[len(events)-1]
+			if last.event != "error" || !strings.HasPrefix(last.data, tt.data) {
+				t.Errorf("Expected an error event %s, got %v", tt.data, events)
+			}
+			if err := <-served; err == nil {
+				t.Error("Expected ServeStream to return the error")
+			}
+		})
+	}
+}
+
+func TestServeStreamClientDisconnect(t *testing.T) {
+	cancelled := make(chan struct{})
+	provider := &mockProvider{
+		stream: func(ctx context.Context, modelName string, input models.CompletionInput) (<-chan models.StreamingCompletionResponse, error) {
+			stream := make(chan models.StreamingCompletionResponse)
+			go func() {
+				defer close(stream)
+				select {
+				case stream <- models.StreamingCompletionResponse{Text: "first"}:
+				case <-ctx.Done():
+				}
+				<-ctx.Done()
+				close(cancelled)
+			}()
+			return stream, nil
+		},
+	}
+	c := newMockClient(t, "mock", provider)
+	served := make(chan error, 1)
+	url := serveStream(t, c, models.CompletionInput{Model: "mock/model", Messages: promptOf(3)}, served)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	if !scanner.Scan() || scanner.Text() != `data: {"text":"first"}` {
+		t.Fatalf("Expected the first chunk to be flushed, got %q", scanner.Text())
+	}
+	cancel()
+	resp.Body.Close()
+
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the provider stream to be cancelled when the client disconnected")
+	}
+	if err := <-served; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected ServeStream to return context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package client
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// stopIndex returns the index of the earliest stop sequence in text, or -1 if there is none
+func stopIndex(text string, stops []string) int {
+	first := -1
+	for _, stop := range stops {
+		if stop == "" {
+			continue
+		}
+		if i := strings.Index(text, stop); i >= 0 && (first < 0 || i < first) {
+			first = i
+		}
+	}
+	return first
+}
+
+// cutAtStop cuts the text of resp before its first stop sequence, for WithClientSideStop
+func cutAtStop(resp *models.CompletionResponse, stops []string) {
+	if i := stopIndex(resp.Text, stops); i >= 0 {
+		resp.Text = resp.Text[:i]
+		resp.FinishReason = models.FinishReasonClientStop
+	}
+}
+
+// stopCutter cuts a stream before the first stop sequence, for WithClientSideStop. The last
+// characters that could begin a stop sequence are held until the next chunk shows whether they do.
+type stopCutter struct {
+	stops   []string
+	hold    int             // The number of bytes held back, one less than the longest stop sequence
+	held    string          // Text received but not passed on yet
+	all     strings.Builder // All the text received
+	sent    strings.Builder // The text passed on
+	stopped bool
+}
+
+// newStopCutter returns the stopCutter for stops, which passes chunks on unchanged unless
+// WithClientSideStop is set
+func (c *Client) newStopCutter(stops []string) stopCutter {
+	if !c.clientSideStop {
+		return stopCutter{}
+	}
+	s := stopCutter{stops: stops}
+	for _, stop := range stops {
+		s.hold = max(s.hold, len(stop)-1)
+	}
+	return s
+}
+
+// add takes the next chunk of the stream and returns the chunks to pass on in its place, which
+// may be none
+func (s *stopCutter) add(chunk models.StreamingCompletionResponse) []models.StreamingCompletionResponse {
+	if len(s.stops) == 0 {
+		return []models.StreamingCompletionResponse{chunk}
+	}
+	if chunk.Done {
+		return s.finish(chunk)
+	}
+
+	chunk.Text = s.cut(chunk.Text)
+	if chunk.Text == "" && chunk.ThinkingText == "" && len(chunk.ToolCallDeltas) == 0 && chunk.Error == nil && chunk.Usage == nil {
+		return nil
+	}
+	return []models.StreamingCompletionResponse{chunk}
+}
+
+// finish returns the held text with the Done chunk. Providers that repeat the whole text on the
+// Done chunk get it cut as well, after a chunk with the held text.
+func (s *stopCutter) finish(done models.StreamingCompletionResponse) []models.StreamingCompletionResponse {
+	var out []models.StreamingCompletionResponse
+	if done.Text != "" && done.Text == s.all.String() {
+		if rest := s.flush(); rest != "" {
+			out = append(out, models.StreamingCompletionResponse{Text: rest, Provider: done.Provider})
+		}
+		done.Text = s.sent.String()
+	} else {
+		done.Text = s.cut(done.Text) + s.flush()
+	}
+	if s.stopped {
+		done.FinishReason = models.FinishReasonClientStop
+	}
+	return append(out, done)
+}
+
+// cut returns the part of text that can be passed on: the text up to a stop sequence, or else
+// all but the bytes held back
+func (s *stopCutter) cut(text string) string {
+	s.all.WriteString(text)
+	if s.stopped {
+		return ""
+	}
+
+	text = s.held + text
+	if i := stopIndex(text, s.stops); i >= 0 {
+		s.stopped = true
+		s.held = ""
+		s.sent.WriteString(text[:i])
+		return text[:i]
+	}
+	n := max(len(text)-s.hold, 0)
+	for n > 0 && n < len(text) && !utf8.RuneStart(text[n]) {
+		n--
+	}
+	s.held = text[n:]
+	s.sent.WriteString(text[:n])
+	return text[:n]
+}
+
+// flush returns the held text at the end of the stream
+func (s *stopCutter) flush() string {
+	rest := s.held
+	s.held = ""
+	s.sent.WriteString(rest)
+	return rest
+}
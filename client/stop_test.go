@@ -0,0 +1,124 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+func TestClientSideStop(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		stops      []string
+		want       string
+		wantReason string
+	}{
+		{"Stopped", "Answer: 42\nQuestion: next", []string{"\nQuestion:"}, "Answer: 42", models.FinishReasonClientStop},
+		{"EarliestStop", "one, two. three", []string{".", ","}, "one", models.FinishReasonClientStop},
+		{"NoStop", "Answer: 42", []string{"END"}, "Answer: 42", ""},
+		{"NoStopSequences", "Answer: 42", nil, "Answer: 42", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &mockProvider{
+				completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+					return &models.CompletionResponse{Text: tt.text}, nil
+				},
+			}
+			c := newMockClient(t, "mock", provider, WithClientSideStop(true))
+
+			resp, err := c.GenerateCompletion(context.Background(), models.CompletionInput{Model: "mock/model", Messages: promptOf(3), Stop: tt.stops})
+			if err != nil {
+				t.Fatalf("GenerateCompletion failed: %v", err)
+			}
+			if resp.Text != tt.want || resp.FinishReason != tt.wantReason {
+				t.Errorf("Expected %q with finish reason %q, got %q with %q", tt.want, tt.wantReason, resp.Text, resp.FinishReason)
+			}
+		})
+	}
+}
+
+func TestClientSideStopDisabled(t *testing.T) {
+	provider := &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			return &models.CompletionResponse{Text: "one END two"}, nil
+		},
+	}
+	c := newMockClient(t, "mock", provider)
+
+	resp, err := c.GenerateCompletion(context.Background(), models.CompletionInput{Model: "mock/model", Messages: promptOf(3), Stop: []string{"END"}})
+	if err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+	if resp.Text != "one END two" || resp.FinishReason != "" {
+		t.Errorf("Expected the text to be left to the provider, got %q with %q", resp.Text, resp.FinishReason)
+	}
+}
+
+func TestClientSideStopStream(t *testing.T) {
+	tests := []struct {
+		name       string
+		chunks     []models.StreamingCompletionResponse
+		wantTexts  []string
+		wantDone   string
+		wantReason string
+	}{
+		{
+			name:       "SplitAcrossChunks",
+			chunks:     textChunks("Hello wor", "ld, ST", "OP here", " more"),
+			wantTexts:  []string{"Hello ", "world,", " ", ""},
+			wantDone:   "",
+			wantReason: models.FinishReasonClientStop,
+		},
+		{
+			name:       "HeldTextReleased",
+			chunks:     textChunks("Hello ST", "ill here", ""),
+			wantTexts:  []string{"Hello", " STill h", "ere"},
+			wantDone:   "ere",
+			wantReason: "",
+		},
+		{
+			name: "FullTextOnDone",
+			chunks: []models.StreamingCompletionResponse{
+				{Text: "Hello ST"},
+				{Text: "OP there"},
+				{Text: "Hello STOP there", Done: true},
+			},
+			wantTexts:  []string{"Hello", " ", "Hello "},
+			wantDone:   "Hello ",
+			wantReason: models.FinishReasonClientStop,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockClient(t, "mock", &mockProvider{stream: streamChunks(tt.chunks...)}, WithClientSideStop(true))
+
+			stream, err := c.GenerateCompletionStream(context.Background(), models.CompletionInput{Model: "mock/model", Messages: promptOf(3), Stop: []string{"STOP"}})
+			if err != nil {
+				t.Fatalf("GenerateCompletionStream failed: %v", err)
+			}
+			var texts []string
+			var done models.StreamingCompletionResponse
+			for chunk := range stream {
+				texts = append(texts, chunk.Text)
+				if chunk.Done {
+					done = chunk
+				}
+			}
+			if len(texts) != len(tt.wantTexts) {
+				t.Fatalf("Expected chunks %q, got %q", tt.wantTexts, texts)
+			}
+			for i := range texts {
+				if texts[i] != tt.wantTexts[i] {
+					t.Errorf("Expected chunks %q, got %q", tt.wantTexts, texts)
+					break
+				}
+			}
+			if done.Text != tt.wantDone || done.FinishReason != tt.wantReason {
+				t.Errorf("Expected a Done chunk with %q and finish reason %q, got %q and %q", tt.wantDone, tt.wantReason, done.Text, done.FinishReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package client
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// StreamChunking selects the boundaries on which streaming completions are re-chunked before
+// they reach the caller
+type StreamChunking int
+
+const (
+	// StreamChunkToken passes the chunks on as the provider sends them. It is the default.
+	StreamChunkToken StreamChunking = iota
+	// StreamChunkWord emits whole words, each chunk ending with whitespace
+	StreamChunkWord
+	// StreamChunkSentence emits whole sentences, ending after a '.', '!' or '?' followed by
+	// whitespace, or after a newline
+	StreamChunkSentence
+	// StreamChunkLine emits whole lines, each chunk ending with a newline
+	StreamChunkLine
+)
+
+// sentenceClosers may follow the end of a sentence before the whitespace, as in `"Stop."`
+const sentenceClosers = `"')]”’`
+
+// streamChunker coalesces the text of stream chunks up to the boundaries of its mode. The text
+// after the last boundary is held until a later chunk completes it or the stream ends.
+type streamChunker struct {
+	mode    StreamChunking
+	pending strings.Builder
+	usage   *models.Usage // The usage of the latest chunk held back, for the next chunk emitted
+	all     strings.Builder
+}
+
+// add takes the next chunk of the stream and returns the chunks to emit in its place, which
+// may be none
+func (s *streamChunker) add(chunk models.StreamingCompletionResponse) []models.StreamingCompletionResponse {
+	if s.mode == StreamChunkToken {
+		return []models.StreamingCompletionResponse{chunk}
+	}
+
+	if chunk.Done {
+		return s.finish(chunk)
+	}
+
+	chunk.Text = s.split(chunk.Text)
+	if chunk.Text == "" && chunk.ThinkingText == "" && len(chunk.ToolCallDeltas) == 0 && chunk.Error == nil {
+		if chunk.Usage != nil {
+			s.usage = chunk.Usage
+		}
+		return nil
+	}
+	if chunk.Usage == nil {
+		chunk.Usage = s.usage
+	}
+	s.usage = nil
+	return []models.StreamingCompletionResponse{chunk}
+}
+
+// finish returns the held text with the Done chunk. Some providers repeat the whole text on
+// the Done chunk; it is kept as it is, after a chunk with the held text.
+func (s *streamChunker) finish(done models.StreamingCompletionResponse) []models.StreamingCompletionResponse {
+	if done.Usage == nil {
+		done.Usage = s.usage
+	}
+	if done.Text == "" || done.Text != s.all.String() {
+		// The Done chunk's text continues the stream: emit its complete units first
+		var out []models.StreamingCompletionResponse
+		if text := s.split(done.Text); text != "" {
+			out = append(out, models.StreamingCompletionResponse{Text: text, Provider: done.Provider})
+		}
+		done.Text = s.pending.String()
+		return append(out, done)
+	}
+	if rest := s.pending.String(); rest != "" {
+		return []models.StreamingCompletionResponse{{Text: rest, Provider: done.Provider}, done}
+	}
+	return []models.StreamingCompletionResponse{done}
+}
+
+// split appends text to the held text and removes and returns the part up to the last boundary
+func (s *streamChunker) split(text string) string {
+	s.all.WriteString(text)
+	s.pending.WriteString(text)
+	held := s.pending.String()
+	n := s.boundary(held)
+	s.pending.Reset()
+	s.pending.WriteString(held[n:])
+	return held[:n]
+}
+
+// boundary returns the length of the longest prefix of text that ends on a boundary of the mode
+func (s *streamChunker) boundary(text string) int {
+	switch s.mode {
+	case StreamChunkWord:
+		return strings.LastIndexFunc(text, unicode.IsSpace) + 1
+	case StreamChunkLine:
+		return strings.LastIndexByte(text, '\n') + 1
+	case StreamChunkSentence:
+		return sentenceBoundary(text)
+	}
+	return len(text)
+}
+
+// sentenceBoundary returns the length of the longest prefix of text ending after a newline or
+// after the whitespace that follows a sentence terminator
+func sentenceBoundary(text string) int {
+	end := 0
+	for i, r := range text {
+		switch {
+		case r == '\n':
+			end = i + 1
+		case unicode.IsSpace(r) && endsSentence(text[:i]):
+			end = i + utf8.RuneLen(r)
+		}
+	}
+	return end
+}
+
+// endsSentence reports whether text ends with a sentence terminator, possibly followed by
+// closing quotes or brackets
+func endsSentence(text string) bool {
+	text = strings.TrimRight(text, sentenceClosers)
+	return strings.HasSuffix(text, ".") || strings.HasSuffix(text, "!") || strings.HasSuffix(text, "?")
+}
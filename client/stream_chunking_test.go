@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// textChunks returns stream chunks with texts, the last one being the Done chunk
+func textChunks(texts ...string) []models.StreamingCompletionResponse {
+	chunks := make([]models.StreamingCompletionResponse, len(texts))
+	for i, text := range texts {
+		chunks[i] = models.StreamingCompletionResponse{Text: text, Done: i == len(texts)-1}
+	}
+	return chunks
+}
+
+func TestStreamChunker(t *testing.T) {
+	tests := []struct {
+		name   string
+		mode   StreamChunking
+		chunks []models.StreamingCompletionResponse
+		want   []string
+	}{
+		{"Token", StreamChunkToken, textChunks("Hel", "lo wo", "rld", ""), []string{"Hel", "lo wo", "rld", ""}},
+		{"Word", StreamChunkWord, textChunks("Hel", "lo wo", "rld", ""), []string{"Hello ", "world"}},
+		{"Sentence", StreamChunkSentence, textChunks("Pi is 3.", "14. It", " is", "! Really?\"", " Yes"), []string{"Pi is 3.14. ", "It is! ", "Really?\" ", "Yes"}},
+		{"SentenceNewline", StreamChunkSentence, textChunks("- one\n- t", "wo", "\n"), []string{"- one\n", "- two\n", ""}},
+		{"Line", StreamChunkLine, textChunks("a. b", "\nc\nd", ""), []string{"a. b\nc\n", "d"}},
+		{"RepeatedDone", StreamChunkWord, textChunks("Hello", " world", "Hello world"), []string{"Hello ", "world", "Hello world"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunker := streamChunker{mode: tt.mode}
+			var got []string
+			for _, chunk := range tt.chunks {
+				for _, out := range chunker.add(chunk) {
+					got = append(got, out.Text)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected chunks %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestStreamChunkerKeepsPayloads(t *testing.T) {
+	chunker := streamChunker{mode: StreamChunkSentence}
+	var out []models.StreamingCompletionResponse
+	for _, chunk := range []models.StreamingCompletionResponse{
+		{Text: "Thinking", Usage: &models.Usage{CompletionTokens: 1}},
+		{ThinkingText: "hmm"},
+		{Text: " done", Usage: &models.Usage{CompletionTokens: 2}},
+		{Done: true},
+	} {
+		out = append(out, chunker.add(chunk)...)
+	}
+	if len(out) != 2 || out[0].ThinkingText != "hmm" || out[0].Text != "" {
+		t.Fatalf("Expected the thinking chunk and the Done chunk, got %+v", out)
+	}
+	if out[0].Usage == nil || out[0].Usage.CompletionTokens != 1 {
+		t.Errorf("Expected the held usage on the next chunk, got %+v", out[0].Usage)
+	}
+	if !out[1].Done || out[1].Text != "Thinking done" || out[1].Usage == nil || out[1].Usage.CompletionTokens != 2 {
+		t.Errorf("Expected the held text and latest usage on the Done chunk, got %+v", out[1])
+	}
+}
+
+func TestWithStreamChunking(t *testing.T) {
+	provider := &mockProvider{
+		stream: streamChunks(textChunks("The sky", " is blue. The", " sea is too.", "")...),
+	}
+	c := newMockClient(t, "mock", provider, WithStreamChunking(StreamChunkSentence))
+
+	stream, err := c.GenerateCompletionStream(context.Background(), models.CompletionInput{Model: "mock/model"})
+	if err != nil {
+		t.Fatalf("GenerateCompletionStream failed: %v", err)
+	}
+	var got []string
+	for chunk := range stream {
+		got = append(got, chunk.Text)
+		if chunk.Done && chunk.Metrics == nil {
+			t.Error("Expected the Done chunk to keep its metrics")
+		}
+	}
+	if want := []string{"The sky is blue. ", "The sea is too."}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected sentences %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"unicode"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// prefillProviders continue a trailing assistant message instead of starting a new one
+var prefillProviders = map[string]bool{
+	"anthropic": true,
+}
+
+// streamResumer reconnects streams interrupted by network errors, as set with WithStreamResume
+type streamResumer struct {
+	provider   string
+	maxResumes int
+	resumes    int
+	text       strings.Builder // The text streamed so far, for the prefill
+	toolCalls  bool            // Whether tool calls were streamed, which can't be resumed
+}
+
+// add records a chunk passed on to the caller
+func (r *streamResumer) add(chunk models.StreamingCompletionResponse) {
+	if !chunk.Done {
+		r.text.WriteString(chunk.Text)
+	}
+	if len(chunk.ToolCallDeltas) > 0 || len(chunk.ToolCalls) > 0 {
+		r.toolCalls = true
+	}
+}
+
+// canResume reports whether a stream that failed with err can be resumed. Once text has been
+// streamed, only providers that take a prefill can continue it.
+func (r *streamResumer) canResume(err error) bool {
+	if r.resumes >= r.maxResumes || r.toolCalls || !isTransientStreamError(err) {
+		return false
+	}
+	return r.text.Len() == 0 || prefillProviders[r.provider]
+}
+
+// input returns input with the text streamed so far appended as an assistant prefill
+func (r *streamResumer) input(input models.CompletionInput) models.CompletionInput {
+	// Anthropic rejects a prefill ending with whitespace
+	prefill := strings.TrimRightFunc(r.text.String(), unicode.IsSpace)
+	if prefill == "" {
+		return input
+	}
+	messages := make([]models.ChatMessage, len(input.Messages), len(input.Messages)+1)
+	copy(messages, input.Messages)
+	input.Messages = append(messages, models.ChatMessage{Role: models.RoleAssistant, Content: prefill})
+	return input
+}
+
+// isTransientStreamError reports whether err is a network failure after which the request may
+// succeed if sent again, rather than a cancellation or an error returned by the provider
+func isTransientStreamError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrClientClosed) {
+		return false
+	}
+	var netErr net.Error
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.As(err, &netErr)
+}
@@ -0,0 +1,169 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// interruptedStreams returns a stream function whose streams each send the next of attempts, a
+// list of chunks, and record the input they were started with
+func interruptedStreams(attempts ...[]models.StreamingCompletionResponse) (func(context.Context, string, models.CompletionInput) (<-chan models.StreamingCompletionResponse, error), *[]models.CompletionInput) {
+	var mu sync.Mutex
+	var inputs []models.CompletionInput
+	return func(ctx context.Context, modelName string, input models.CompletionInput) (<-chan models.StreamingCompletionResponse, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(inputs) == len(attempts) {
+			return nil, errors.New("no more attempts")
+		}
+		chunks := attempts[len(inputs)]
+		inputs = append(inputs, input)
+		return streamChunks(chunks...)(ctx, modelName, input)
+	}, &inputs
+}
+
+// collectStream reads a stream to its end and returns the text and the number of Resumed markers
+func collectStream(t *testing.T, stream <-chan models.StreamingCompletionResponse) (text string, resumed int, err error) {
+	t.Helper()
+	for chunk := range stream {
+		text += chunk.Text
+		if chunk.Resumed {
+			resumed++
+		}
+		if chunk.Error != nil {
+			err = chunk.Error
+		}
+	}
+	return text, resumed, err
+}
+
+func TestStreamResumeWithPrefill(t *testing.T) {
+	stream, inputs := interruptedStreams(
+		[]models.StreamingCompletionResponse{{Text: "Hello, "}, {Error: io.ErrUnexpectedEOF}},
+		[]models.StreamingCompletionResponse{{Text: " wor"}, {Error: io.ErrUnexpectedEOF}},
+		[]models.StreamingCompletionResponse{{Text: "ld!"}, {Done: true}},
+	)
+	c := newMockClient(t, "anthropic", &mockProvider{stream: stream}, WithStreamResume(2))
+
+	prompt := promptOf(3)
+	out, err := c.GenerateCompletionStream(context.Background(), models.CompletionInput{Model: "anthropic/claude-3", Messages: prompt})
+	if err != nil {
+		t.Fatalf("GenerateCompletionStream failed: %v", err)
+	}
+	text, resumed, streamErr := collectStream(t, out)
+	if streamErr != nil || text != "Hello,  world!" || resumed != 2 {
+		t.Fatalf("Expected the resumed text with 2 markers, got %q, %d, %v", text, resumed, streamErr)
+	}
+
+	if len(*inputs) != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", len(*inputs))
+	}
+	for i, want := range []string{"Hello,", "Hello,  wor"} {
+		messages := (*inputs)[i+1].Messages
+		if len(messages) != 2 || messages[1].Role != models.RoleAssistant || messages[1].Content != want {
+			t.Errorf("Expected attempt %d to prefill %q, got %+v", i+2, want, messages)
+		}
+	}
+	if len(prompt) != 1 {
+		t.Error("Resuming modified the caller's messages")
+	}
+}
+
+func TestStreamResume(t *testing.T) {
+	tests := []struct {
+		name        string
+		provider    string
+		maxResumes  int
+		attempts    [][]models.StreamingCompletionResponse
+		wantText    string
+		wantResumed int
+		wantErr     error
+	}{
+		{
+			name:       "Disabled",
+			provider:   "anthropic",
+			maxResumes: 0,
+			attempts: [][]models.StreamingCompletionResponse{
+				{{Text: "Hi"}, {Error: io.ErrUnexpectedEOF}},
+			},
+			wantText: "Hi",
+			wantErr:  io.ErrUnexpectedEOF,
+		},
+		{
+			name:       "BeforeText",
+			provider:   "openai",
+			maxResumes: 1,
+			attempts: [][]models.StreamingCompletionResponse{
+				{{Error: io.ErrUnexpectedEOF}},
+				{{Text: "Hi"}, {Done: true}},
+			},
+			wantText:    "Hi",
+			wantResumed: 1,
+		},
+		{
+			name:       "NoPrefill",
+			provider:   "openai",
+			maxResumes: 1,
+			attempts: [][]models.StreamingCompletionResponse{
+				{{Text: "Hi"}, {Error: io.ErrUnexpectedEOF}},
+				{{Text: "Hi there"}, {Done: true}},
+			},
+			wantText: "Hi",
+			wantErr:  io.ErrUnexpectedEOF,
+		},
+		{
+			name:       "ProviderError",
+			provider:   "anthropic",
+			maxResumes: 1,
+			attempts: [][]models.StreamingCompletionResponse{
+				{{Text: "Hi"}, {Error: models.ErrContentFiltered}},
+				{{Text: " there"}, {Done: true}},
+			},
+			wantText: "Hi",
+			wantErr:  models.ErrContentFiltered,
+		},
+		{
+			name:       "OutOfResumes",
+			provider:   "anthropic",
+			maxResumes: 1,
+			attempts: [][]models.StreamingCompletionResponse{
+				{{Text: "Hi"}, {Error: io.ErrUnexpectedEOF}},
+				{{Text: " there"}, {Error: io.ErrUnexpectedEOF}},
+				{{Text: "!"}, {Done: true}},
+			},
+			wantText:    "Hi there",
+			wantResumed: 1,
+			wantErr:     io.ErrUnexpectedEOF,
+		},
+		{
+			name:       "ReconnectFails",
+			provider:   "anthropic",
+			maxResumes: 3,
+			attempts: [][]models.StreamingCompletionResponse{
+				{{Text: "Hi"}, {Error: io.ErrUnexpectedEOF}},
+			},
+			wantText: "Hi",
+			wantErr:  io.ErrUnexpectedEOF,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream, _ := interruptedStreams(tt.attempts...)
+			c := newMockClient(t, tt.provider, &mockProvider{stream: stream}, WithStreamResume(tt.maxResumes))
+
+			out, err := c.GenerateCompletionStream(context.Background(), models.CompletionInput{Model: tt.provider + "/model", Messages: promptOf(3)})
+			if err != nil {
+				t.Fatalf("GenerateCompletionStream failed: %v", err)
+			}
+			text, resumed, streamErr := collectStream(t, out)
+			if text != tt.wantText || resumed != tt.wantResumed || !errors.Is(streamErr, tt.wantErr) {
+				t.Errorf("Expected %q with %d markers and error %v, got %q, %d, %v", tt.wantText, tt.wantResumed, tt.wantErr, text, resumed, streamErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package client
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/1broseidon/gollm/internal/clock"
+	"github.com/1broseidon/gollm/internal/utils"
+	"github.com/1broseidon/gollm/models"
+)
+
+// defaultCloseGracePeriod is how long Close waits for active streams to finish by default
+const defaultCloseGracePeriod = 5 * time.Second
+
+// streamTracker tracks the streaming completions in flight so that Close can end them
+// before the providers underneath are closed
+type streamTracker struct {
+	mu      sync.Mutex
+	closing bool
+	nextID  uint64
+	cancels map[uint64]context.CancelCauseFunc
+	wg      sync.WaitGroup
+	abandon chan struct{}
+}
+
+// lazyInit creates the tracker's map and channel; callers must hold t.mu
+func (t *streamTracker) lazyInit() {
+	if t.cancels == nil {
+		t.cancels = make(map[uint64]context.CancelCauseFunc)
+		t.abandon = make(chan struct{})
+	}
+}
+
+// start registers a new stream. The returned context is cancelled with ErrClientClosed
+// when the client closes, and done must be called once the stream goroutine exits.
+func (t *streamTracker) start(ctx context.Context) (streamCtx context.Context, done func(), err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lazyInit()
+
+	if t.closing {
+		return nil, nil, ErrClientClosed
+	}
+
+	streamCtx, cancel := context.WithCancelCause(ctx)
+	id := t.nextID
+	t.nextID++
+	t.cancels[id] = cancel
+	t.wg.Add(1)
+
+	done = func() {
+		t.mu.Lock()
+		delete(t.cancels, id)
+		t.mu.Unlock()
+		cancel(nil)
+		t.wg.Done()
+	}
+	return streamCtx, done, nil
+}
+
+// abandoned returns a channel that is closed once the shutdown grace period has expired.
+// Stream goroutines stop waiting on slow consumers when it fires.
+func (t *streamTracker) abandoned() <-chan struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lazyInit()
+	return t.abandon
+}
+
+// shutdown cancels every active stream and waits up to grace for their goroutines to exit.
+// It reports whether all streams finished within the grace period.
+func (t *streamTracker) shutdown(grace time.Duration) bool {
+	t.mu.Lock()
+	t.lazyInit()
+	t.closing = true
+	for _, cancel := range t.cancels {
+		cancel(ErrClientClosed)
+	}
+	t.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(finished)
+	}()
+
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+
+	select {
+	case <-finished:
+		return true
+	case <-timer.C:
+		close(t.abandon)
+		<-finished
+		return false
+	}
+}
+
+// streamProgress tracks how far a streaming completion has got, for WithStreamProgress
+// and the StreamMetrics of the Done chunk
+type streamProgress struct {
+	clock      clock.Clock
+	maxTokens  int
+	chunkIndex int
+	tokens     int
+	start      time.Time
+	firstToken time.Time
+}
+
+// update records a received chunk and returns the arguments for the progress callback
+func (p *streamProgress) update(chunk models.StreamingCompletionResponse) (chunkIndex int, tokensSoFar int, estimatedFraction float64) {
+	switch {
+	case chunk.Usage != nil && chunk.Usage.CompletionTokens > 0:
+		p.tokens = chunk.Usage.CompletionTokens
+	case !chunk.Done:
+		// Final chunks may repeat the whole text, so only deltas are counted
+		p.tokens += utils.EstimateTokens(chunk.Text)
+	}
+
+	if p.firstToken.IsZero() && (chunk.Text != "" || chunk.ThinkingText != "") {
+		p.firstToken = p.clock.Now()
+	}
+
+	chunkIndex = p.chunkIndex
+	p.chunkIndex++
+
+	estimatedFraction = -1
+	if p.maxTokens > 0 {
+		estimatedFraction = math.Min(float64(p.tokens)/float64(p.maxTokens), 1)
+	}
+	return chunkIndex, p.tokens, estimatedFraction
+}
+
+// metrics returns the latency of the stream so far
+func (p *streamProgress) metrics() *models.StreamMetrics {
+	now := p.clock.Now()
+	m := &models.StreamMetrics{TotalDuration: now.Sub(p.start), Chunks: p.chunkIndex}
+	if p.firstToken.IsZero() {
+		return m
+	}
+
+	m.FirstTokenLatency = p.firstToken.Sub(p.start)
+	if generation := now.Sub(p.firstToken); generation > 0 {
+		m.TokensPerSecond = float64(p.tokens) / generation.Seconds()
+	}
+	return m
+}
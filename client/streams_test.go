@@ -0,0 +1,389 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/1broseidon/gollm/internal/utils"
+	"github.com/1broseidon/gollm/models"
+)
+
+// slowStream emits a chunk every interval until its context is cancelled
+func slowStream(interval time.Duration, exited *atomic.Bool) func(context.Context, string, models.CompletionInput) (<-chan models.StreamingCompletionResponse, error) {
+	return func(ctx context.Context, modelName string, input models.CompletionInput) (<-chan models.StreamingCompletionResponse, error) {
+		streamChan := make(chan models.StreamingCompletionResponse)
+		go func() {
+			defer exited.Store(true)
+			defer close(streamChan)
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					streamChan <- models.StreamingCompletionResponse{Error: ctx.Err()}
+					return
+				case <-ticker.C:
+					streamChan <- models.StreamingCompletionResponse{Text: "tick "}
+				}
+			}
+		}()
+		return streamChan, nil
+	}
+}
+
+func TestCloseTerminatesActiveStreams(t *testing.T) {
+	ctx := context.Background()
+
+	var streamExited, closedAfterStream atomic.Bool
+	provider := &mockProvider{stream: slowStream(10*time.Millisecond, &streamExited)}
+	provider.closeFunc = func() error {
+		closedAfterStream.Store(streamExited.Load())
+		return nil
+	}
+	c := newMockClient(t, "mock", provider, WithCloseGracePeriod(time.Second))
+
+	stream, err := c.GenerateCompletionStream(ctx, models.CompletionInput{Model: "mock/slow"})
+	if err != nil {
+		t.Fatalf("GenerateCompletionStream failed: %v", err)
+	}
+	if chunk := <-stream; chunk.Text != "tick " {
+		t.Fatalf("Unexpected first chunk: %+v", chunk)
+	}
+
+	chunks := make(chan models.StreamingCompletionResponse, 100)
+	go func() {
+		defer close(chunks)
+		for chunk := range stream {
+			chunks <- chunk
+		}
+	}()
+
+	start := time.Now()
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Close took %v, longer than the grace period", elapsed)
+	}
+
+	var last models.StreamingCompletionResponse
+	for chunk := range chunks {
+		last = chunk
+	}
+	if !errors.Is(last.Error, ErrClientClosed) || !last.Done {
+		t.Errorf("Expected a final ErrClientClosed chunk, got %+v", last)
+	}
+	if !closedAfterStream.Load() {
+		t.Error("Provider was closed before its stream goroutine exited")
+	}
+
+	if _, err := c.GenerateCompletionStream(ctx, models.CompletionInput{Model: "mock/slow"}); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Expected ErrClientClosed for a stream started after Close, got %v", err)
+	}
+}
+
+func TestCloseAbandonsUnreadStreams(t *testing.T) {
+	ctx := context.Background()
+
+	var streamExited atomic.Bool
+	provider := &mockProvider{stream: slowStream(time.Millisecond, &streamExited)}
+	c := newMockClient(t, "mock", provider, WithCloseGracePeriod(50*time.Millisecond))
+
+	if _, err := c.GenerateCompletionStream(ctx, models.CompletionInput{Model: "mock/slow"}); err != nil {
+		t.Fatalf("GenerateCompletionStream failed: %v", err)
+	}
+
+	// Nobody reads the stream, so Close must give up after the grace period
+	done := make(chan struct{})
+	go func() {
+		c.Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close blocked on a stream nobody was reading")
+	}
+}
+
+func TestCloseTwice(t *testing.T) {
+	var closes atomic.Int32
+	provider := &mockProvider{closeFunc: func() error {
+		closes.Add(1)
+		return errors.New("close failed")
+	}}
+	c := newMockClient(t, "mock", provider)
+
+	if err := c.Close(); err == nil {
+		t.Error("Expected the provider's error from the first Close")
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("Expected nil from a second Close, got %v", err)
+	}
+	if n := closes.Load(); n != 1 {
+		t.Errorf("Expected the provider to be closed once, got %d", n)
+	}
+}
+
+func TestCallerCancellationStillReportedByProvider(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var streamExited atomic.Bool
+	provider := &mockProvider{stream: slowStream(time.Millisecond, &streamExited)}
+	c := newMockClient(t, "mock", provider)
+
+	stream, err := c.GenerateCompletionStream(ctx, models.CompletionInput{Model: "mock/slow"})
+	if err != nil {
+		t.Fatalf("GenerateCompletionStream failed: %v", err)
+	}
+	<-stream
+	cancel()
+
+	var last models.StreamingCompletionResponse
+	for chunk := range stream {
+		last = chunk
+	}
+	if !errors.Is(last.Error, context.Canceled) {
+		t.Errorf("Expected the provider's cancellation error, got %+v", last)
+	}
+}
+
+func TestStreamProgress(t *testing.T) {
+	ctx := context.Background()
+
+	type call struct {
+		chunkIndex int
+		tokens     int
+		fraction   float64
+	}
+	var calls []call
+	progress := WithStreamProgress(func(chunkIndex int, tokensSoFar int, estimatedFraction float64) {
+		calls = append(calls, call{chunkIndex, tokensSoFar, estimatedFraction})
+	})
+
+	chunks := []models.StreamingCompletionResponse{
+		{Text: "The quick "},
+		{Text: "brown fox "},
+		{Text: "jumps over "},
+		{Text: "the lazy dog."},
+		{Done: true, Usage: &models.Usage{CompletionTokens: 10}},
+	}
+	c := newMockClient(t, "mock", &mockProvider{stream: streamChunks(chunks...)}, progress)
+
+	for _, maxTokens := range []int{20, 0} {
+		calls = nil
+		stream, err := c.GenerateCompletionStream(ctx, models.CompletionInput{Model: "mock/model", MaxTokens: maxTokens})
+		if err != nil {
+			t.Fatalf("GenerateCompletionStream failed: %v", err)
+		}
+		received := 0
+		for range stream {
+			received++
+		}
+
+		// The callback runs before each chunk is sent, so every call has happened by now
+		if len(calls) != len(chunks) || received != len(chunks) {
+			t.Fatalf("Expected %d callbacks for %d chunks, got %d", len(chunks), received, len(calls))
+		}
+		for i, got := range calls {
+			if got.chunkIndex != i {
+				t.Errorf("Callback %d has chunkIndex %d", i, got.chunkIndex)
+			}
+			if i > 0 && (got.tokens < calls[i-1].tokens || got.fraction < calls[i-1].fraction) {
+				t.Errorf("Progress went backwards at chunk %d: %+v after %+v", i, got, calls[i-1])
+			}
+			if maxTokens == 0 && got.fraction != -1 {
+				t.Errorf("Expected fraction -1 without MaxTokens, got %v", got.fraction)
+			}
+		}
+		if last := calls[len(calls)-1]; last.tokens != 10 || (maxTokens > 0 && last.fraction != 0.5) {
+			t.Errorf("Expected the final usage to be reported, got %+v", last)
+		}
+	}
+}
+
+func TestStreamMetrics(t *testing.T) {
+	ctx := context.Background()
+
+	const firstTokenDelay = 20 * time.Millisecond
+	provider := &mockProvider{
+		stream: func(ctx context.Context, modelName string, input models.CompletionInput) (<-chan models.StreamingCompletionResponse, error) {
+			streamChan := make(chan models.StreamingCompletionResponse)
+			go func() {
+				defer close(streamChan)
+				time.Sleep(firstTokenDelay)
+				streamChan <- models.StreamingCompletionResponse{Text: "Hello"}
+				time.Sleep(10 * time.Millisecond)
+				streamChan <- models.StreamingCompletionResponse{Text: " world"}
+				streamChan <- models.StreamingCompletionResponse{Done: true, Usage: &models.Usage{CompletionTokens: 2}}
+			}()
+			return streamChan, nil
+		},
+	}
+	c := newMockClient(t, "mock", provider)
+
+	stream, err := c.GenerateCompletionStream(ctx, models.CompletionInput{Model: "mock/model"})
+	if err != nil {
+		t.Fatalf("GenerateCompletionStream failed: %v", err)
+	}
+
+	var last models.StreamingCompletionResponse
+	for chunk := range stream {
+		if !chunk.Done && chunk.Metrics != nil {
+			t.Errorf("Expected metrics only on the Done chunk, got %+v", chunk)
+		}
+		last = chunk
+	}
+
+	m := last.Metrics
+	if m == nil {
+		t.Fatal("Expected metrics on the Done chunk")
+	}
+	if m.FirstTokenLatency < firstTokenDelay || m.TotalDuration < m.FirstTokenLatency+10*time.Millisecond {
+		t.Errorf("Unexpected latencies: %+v", m)
+	}
+	if m.TokensPerSecond <= 0 || m.TokensPerSecond > 2/(10*time.Millisecond).Seconds() {
+		t.Errorf("Unexpected tokens per second: %v", m.TokensPerSecond)
+	}
+}
+
+func TestStreamMetricsSchedule(t *testing.T) {
+	const (
+		firstTokenDelay = 30 * time.Millisecond
+		chunkInterval   = 40 * time.Millisecond
+	)
+	texts := []string{"The quick brown fox", " jumps over", " the lazy dog."}
+	estimated := 0
+	for _, text := range texts {
+		estimated += utils.EstimateTokens(text)
+	}
+
+	tests := []struct {
+		name   string
+		usage  *models.Usage
+		tokens int
+	}{
+		{"ReportedUsage", &models.Usage{CompletionTokens: 40}, 40},
+		{"EstimatedUsage", nil, estimated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// An empty keep-alive chunk, then text chunks at fixed intervals after the first token
+			provider := &mockProvider{
+				stream: func(ctx context.Context, modelName string, input models.CompletionInput) (<-chan models.StreamingCompletionResponse, error) {
+					streamChan := make(chan models.StreamingCompletionResponse)
+					go func() {
+						defer close(streamChan)
+						streamChan <- models.StreamingCompletionResponse{}
+						time.Sleep(firstTokenDelay)
+						for i, text := range texts {
+							if i > 0 {
+								time.Sleep(chunkInterval)
+							}
+							streamChan <- models.StreamingCompletionResponse{Text: text}
+						}
+						streamChan <- models.StreamingCompletionResponse{Done: true, Usage: tt.usage}
+					}()
+					return streamChan, nil
+				},
+			}
+			c := newMockClient(t, "mock", provider)
+
+			stream, err := c.GenerateCompletionStream(context.Background(), models.CompletionInput{Model: "mock/model"})
+			if err != nil {
+				t.Fatalf("GenerateCompletionStream failed: %v", err)
+			}
+			var last models.StreamingCompletionResponse
+			for chunk := range stream {
+				last = chunk
+			}
+
+			m := last.Metrics
+			if m == nil {
+				t.Fatal("Expected metrics on the Done chunk")
+			}
+			if m.Chunks != len(texts)+2 {
+				t.Errorf("Expected %d chunks, got %d", len(texts)+2, m.Chunks)
+			}
+			if m.FirstTokenLatency < firstTokenDelay || m.FirstTokenLatency > firstTokenDelay+time.Second {
+				t.Errorf("Expected the first token after about %v, got %v", firstTokenDelay, m.FirstTokenLatency)
+			}
+
+			// Generation runs from the first token to the Done chunk, at least two intervals
+			generation := m.TotalDuration - m.FirstTokenLatency
+			if generation < 2*chunkInterval {
+				t.Fatalf("Expected generation to take at least %v, got %v", 2*chunkInterval, generation)
+			}
+			want := float64(tt.tokens) / generation.Seconds()
+			if math.Abs(m.TokensPerSecond-want) > want*0.05 {
+				t.Errorf("Expected %.1f tokens per second (%d tokens in %v), got %.1f", want, tt.tokens, generation, m.TokensPerSecond)
+			}
+		})
+	}
+}
+
+func TestStreamBufferSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int
+		buffered bool
+	}{
+		{"Unbuffered", 0, false},
+		{"Buffered", 8, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sent := make(chan struct{})
+			c := newMockClient(t, "mock", &mockProvider{
+				stream: func(ctx context.Context, modelName string, input models.CompletionInput) (<-chan models.StreamingCompletionResponse, error) {
+					streamChan := utils.NewStream(input.StreamBufferSize)
+					go func() {
+						defer close(streamChan)
+						for i := 0; i < 4; i++ {
+							if !utils.SendChunk(ctx, streamChan, models.StreamingCompletionResponse{Text: "x"}) {
+								return
+							}
+						}
+						if utils.SendChunk(ctx, streamChan, models.StreamingCompletionResponse{Done: true}) {
+							close(sent)
+						}
+					}()
+					return streamChan, nil
+				},
+			})
+
+			stream, err := c.GenerateCompletionStream(context.Background(), models.CompletionInput{Model: "mock/model", Messages: promptOf(3), StreamBufferSize: tt.size})
+			if err != nil {
+				t.Fatalf("GenerateCompletionStream failed: %v", err)
+			}
+			if cap(stream) != tt.size {
+				t.Errorf("Expected a stream buffering %d chunks, got %d", tt.size, cap(stream))
+			}
+
+			// Nothing reads the stream yet, so the provider only finishes if the buffers hold every chunk
+			select {
+			case <-sent:
+				if !tt.buffered {
+					t.Error("Expected the unbuffered provider to wait for the consumer")
+				}
+			case <-time.After(50 * time.Millisecond):
+				if tt.buffered {
+					t.Error("Expected the buffered provider to send every chunk before the consumer reads")
+				}
+			}
+
+			var text string
+			for chunk := range stream {
+				text += chunk.Text
+			}
+			if text != "xxxx" {
+				t.Errorf("Expected every chunk to be delivered, got %q", text)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// GenerateOllamaStructured generates a completion with an Ollama model constrained to JSON matching
+// schema, and decodes it into out, which must be a pointer. input.Model is an Ollama model, optionally
+// prefixed with "ollama/". If schema is nil it is inferred from the type of out with JSONSchemaFor.
+func (c *Client) GenerateOllamaStructured(ctx context.Context, input models.CompletionInput, schema json.RawMessage, out interface{}) error {
+	if v := reflect.ValueOf(out); v.Kind() != reflect.Pointer || v.IsNil() {
+		return errors.New("out must be a non-nil pointer")
+	}
+	if schema == nil {
+		var err error
+		if schema, err = JSONSchemaFor(out); err != nil {
+			return err
+		}
+	}
+
+	input.Model = "ollama/" + strings.TrimPrefix(input.Model, "ollama/")
+	input.ProviderOptions.Ollama.Format = schema
+
+	resp, err := c.GenerateCompletion(ctx, input)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(resp.Text), out); err != nil {
+		return fmt.Errorf("failed to decode structured output: %w", err)
+	}
+	return nil
+}
+
+// JSONSchemaFor returns a JSON Schema describing the JSON encoding of v's type. Struct fields are
+// named by their json tags and are required unless tagged omitempty. Recursive types, channels,
+// functions and complex numbers are not supported.
+func JSONSchemaFor(v interface{}) (json.RawMessage, error) {
+	if v == nil {
+		return nil, errors.New("cannot infer a schema from nil")
+	}
+	schema, err := schemaFor(reflect.TypeOf(v), map[reflect.Type]bool{})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(schema)
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaFor builds the schema of t. visiting holds the struct types being built, to detect recursion.
+func schemaFor(t reflect.Type, visiting map[reflect.Type]bool) (map[string]interface{}, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}, nil
+	case t == rawMessageType:
+		return map[string]interface{}{}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}, nil
+	case reflect.Interface:
+		return map[string]interface{}{}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// Byte slices are encoded as base64 strings
+			return map[string]interface{}{"type": "string"}, nil
+		}
+		items, err := schemaFor(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %s", t.Key())
+		}
+		values, err := schemaFor(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		if visiting[t] {
+			return nil, fmt.Errorf("recursive type %s is not supported", t)
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		properties := map[string]interface{}{}
+		required := []string{}
+		if err := addStructFields(t, properties, &required, visiting); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "object", "properties": properties, "required": required}, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+// addStructFields adds the schemas of the fields of struct type t, flattening embedded structs
+// the way encoding/json does
+func addStructFields(t reflect.Type, properties map[string]interface{}, required *[]string, visiting map[reflect.Type]bool) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if err := addStructFields(embedded, properties, required, visiting); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		schema, err := schemaFor(field.Type, visiting)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		properties[name] = schema
+		if !strings.Contains(","+opts+",", ",omitempty,") {
+			*required = append(*required, name)
+		}
+	}
+	return nil
+}
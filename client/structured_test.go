@@ -0,0 +1,107 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+type address struct {
+	City string `json:"city"`
+}
+
+type person struct {
+	address
+	Name     string             `json:"name"`
+	Age      int                `json:"age"`
+	Email    string             `json:"email,omitempty"`
+	Tags     []string           `json:"tags"`
+	Born     time.Time          `json:"born"`
+	Scores   map[string]float64 `json:"scores,omitempty"`
+	Manager  *address           `json:"manager"`
+	Internal string             `json:"-"`
+	secret   string
+}
+
+func TestJSONSchemaFor(t *testing.T) {
+	schema, err := JSONSchemaFor(&person{})
+	if err != nil {
+		t.Fatalf("JSONSchemaFor failed: %v", err)
+	}
+
+	want := `{
+		"type": "object",
+		"properties": {
+			"city": {"type": "string"},
+			"name": {"type": "string"},
+			"age": {"type": "integer"},
+			"email": {"type": "string"},
+			"tags": {"type": "array", "items": {"type": "string"}},
+			"born": {"type": "string", "format": "date-time"},
+			"scores": {"type": "object", "additionalProperties": {"type": "number"}},
+			"manager": {"type": "object", "properties": {"city": {"type": "string"}}, "required": ["city"]}
+		},
+		"required": ["city", "name", "age", "tags", "born", "manager"]
+	}`
+	var got, expected interface{}
+	json.Unmarshal(schema, &got)
+	json.Unmarshal([]byte(want), &expected)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Unexpected schema: %s", schema)
+	}
+
+	type node struct {
+		Children []node `json:"children"`
+	}
+	if _, err := JSONSchemaFor(node{}); err == nil {
+		t.Error("Expected an error for a recursive type")
+	}
+	if _, err := JSONSchemaFor(struct{ C chan int }{}); err == nil {
+		t.Error("Expected an error for a channel field")
+	}
+}
+
+func TestGenerateOllamaStructured(t *testing.T) {
+	var received models.CompletionInput
+	var receivedModel string
+	provider := &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			received, receivedModel = input, modelName
+			return &models.CompletionResponse{Text: `{"city":"London","name":"Ada","age":36,"tags":["math"],"born":"1815-12-10T00:00:00Z","manager":null}`}, nil
+		},
+	}
+	c := newMockClient(t, "ollama", provider)
+
+	input := models.CompletionInput{Model: "llama3.1", Messages: []models.ChatMessage{{Role: "user", Content: "Describe Ada Lovelace"}}}
+	var out person
+	if err := c.GenerateOllamaStructured(context.Background(), input, nil, &out); err != nil {
+		t.Fatalf("GenerateOllamaStructured failed: %v", err)
+	}
+
+	if receivedModel != "llama3.1" {
+		t.Errorf("Expected model llama3.1, got %q", receivedModel)
+	}
+	if want, _ := JSONSchemaFor(&out); string(received.ProviderOptions.Ollama.Format) != string(want) {
+		t.Errorf("Expected the inferred schema to be sent, got %s", received.ProviderOptions.Ollama.Format)
+	}
+	if out.Name != "Ada" || out.Age != 36 || out.City != "London" || out.Born.Year() != 1815 {
+		t.Errorf("Unexpected output: %+v", out)
+	}
+
+	schema := json.RawMessage(`{"type":"object","properties":{"name":{"type":"string"}}}`)
+	input.Model = "ollama/llama3.1"
+	if err := c.GenerateOllamaStructured(context.Background(), input, schema, &out); err != nil {
+		t.Fatalf("GenerateOllamaStructured failed: %v", err)
+	}
+	if receivedModel != "llama3.1" || string(received.ProviderOptions.Ollama.Format) != string(schema) {
+		t.Errorf("Expected the given schema with model llama3.1, got %s with %q", received.ProviderOptions.Ollama.Format, receivedModel)
+	}
+
+	if err := c.GenerateOllamaStructured(context.Background(), input, schema, out); err == nil {
+		t.Error("Expected an error when out is not a pointer")
+	}
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// ErrTemperatureOutOfRange is returned with TemperatureReject for a temperature outside the
+// range of the provider
+var ErrTemperatureOutOfRange = errors.New("temperature out of range")
+
+// TemperaturePolicy selects what happens to a temperature outside the range its provider accepts
+type TemperaturePolicy int
+
+const (
+	// TemperatureClamp moves the temperature to the nearest bound of the range and logs a
+	// warning. It is the default.
+	TemperatureClamp TemperaturePolicy = iota
+	// TemperatureReject fails the request with ErrTemperatureOutOfRange before it is sent
+	TemperatureReject
+)
+
+// temperatureRange is the range of temperatures a provider accepts
+type temperatureRange struct {
+	min, max float32
+}
+
+// temperatureRanges are the temperatures accepted by the built-in providers: 0 to 2 for OpenAI
+// and Gemini, and 0 to 1 for Anthropic. Ollama takes any temperature, so it is not checked.
+var temperatureRanges = map[string]temperatureRange{
+	"openai":       {0, 2},
+	"anthropic":    {0, 1},
+	"googlegemini": {0, 2},
+}
+
+// checkTemperature applies the temperature policy to input for provider, replacing an out of
+// range temperature with the clamped one or returning an error
+func (c *Client) checkTemperature(provider string, input *models.CompletionInput) error {
+	r, ok := temperatureRanges[provider]
+	if !ok || input.Temperature == nil {
+		return nil
+	}
+	t := *input.Temperature
+	if t >= r.min && t <= r.max {
+		return nil
+	}
+
+	if c.temperaturePolicy == TemperatureReject {
+		return fmt.Errorf("%w: %v is outside %v to %v for %s", ErrTemperatureOutOfRange, t, r.min, r.max, provider)
+	}
+	clamped := min(max(t, r.min), r.max)
+	c.logger.Warnf("Temperature %v is outside %v to %v for %s; using %v", t, r.min, r.max, provider, clamped)
+	// A new pointer, as the caller's input shares the old one
+	input.Temperature = models.Float32(clamped)
+	return nil
+}
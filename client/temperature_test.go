@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+func TestTemperatureClamp(t *testing.T) {
+	var sent *float32
+	provider := &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			sent = input.Temperature
+			return &models.CompletionResponse{Text: "ok"}, nil
+		},
+	}
+
+	tests := []struct {
+		name        string
+		provider    string
+		temperature *float32
+		want        *float32
+	}{
+		{"InRange", "anthropic", models.Float32(0.5), models.Float32(0.5)},
+		{"AboveAnthropic", "anthropic", models.Float32(1.5), models.Float32(1)},
+		{"AboveOpenAI", "openai", models.Float32(2.5), models.Float32(2)},
+		{"WithinOpenAI", "openai", models.Float32(1.5), models.Float32(1.5)},
+		{"Negative", "googlegemini", models.Float32(-1), models.Float32(0)},
+		{"Unchecked", "ollama", models.Float32(5), models.Float32(5)},
+		{"Unset", "anthropic", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := &recordingLogger{}
+			c := newMockClient(t, tt.provider, provider, WithLogger(logger))
+			input := models.CompletionInput{Model: tt.provider + "/model", Temperature: tt.temperature}
+			if _, err := c.GenerateCompletion(context.Background(), input); err != nil {
+				t.Fatalf("GenerateCompletion failed: %v", err)
+			}
+			if (sent == nil) != (tt.want == nil) || (sent != nil && *sent != *tt.want) {
+				t.Errorf("Expected temperature %v to be sent, got %v", tt.want, sent)
+			}
+			clamped := tt.want != nil && *tt.want != *tt.temperature
+			if logger.contains("WARN: ", "Temperature") != clamped {
+				t.Errorf("Expected a warning only for a clamped temperature, got %v", logger.lines)
+			}
+			if tt.temperature != nil && input.Temperature != tt.temperature {
+				t.Error("Clamping modified the caller's input")
+			}
+		})
+	}
+}
+
+func TestTemperatureReject(t *testing.T) {
+	provider := &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			return &models.CompletionResponse{Text: "ok"}, nil
+		},
+		stream: streamChunks(models.StreamingCompletionResponse{Text: "ok", Done: true}),
+	}
+	c := newMockClient(t, "anthropic", provider, WithTemperaturePolicy(TemperatureReject))
+
+	input := models.CompletionInput{Model: "anthropic/model", Temperature: models.Float32(1.5)}
+	if _, err := c.GenerateCompletion(context.Background(), input); !errors.Is(err, ErrTemperatureOutOfRange) {
+		t.Errorf("Expected ErrTemperatureOutOfRange, got %v", err)
+	}
+	if _, err := c.GenerateCompletionStream(context.Background(), input); !errors.Is(err, ErrTemperatureOutOfRange) {
+		t.Errorf("Expected ErrTemperatureOutOfRange for a stream, got %v", err)
+	}
+
+	input.Temperature = models.Float32(1)
+	if _, err := c.GenerateCompletion(context.Background(), input); err != nil {
+		t.Errorf("Expected the upper bound to be accepted, got %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"net/http/httptrace"
+	"sync"
+
+	"github.com/1broseidon/gollm/internal/clock"
+	"github.com/1broseidon/gollm/models"
+)
+
+// requestTimer measures the Timing of a request
+type requestTimer struct {
+	clock  clock.Clock
+	mu     sync.Mutex
+	timing models.Timing
+}
+
+// newRequestTimer starts timing a request that has just been received, on clk
+func newRequestTimer(clk clock.Clock) *requestTimer {
+	return &requestTimer{clock: clk, timing: models.Timing{QueuedAt: clk.Now()}}
+}
+
+// start records that the request is being handed to the provider. The returned context traces
+// the HTTP requests the provider makes with it, to observe the first response byte.
+func (t *requestTimer) start(ctx context.Context) context.Context {
+	t.mu.Lock()
+	t.timing.StartedAt = t.clock.Now()
+	t.mu.Unlock()
+
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotFirstResponseByte: t.firstByte,
+	})
+}
+
+// firstByte records the arrival of response data. After a retry, the latest response counts.
+func (t *requestTimer) firstByte() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.timing.FirstByteAt = t.clock.Now()
+}
+
+// firstChunk records the first chunk of a stream as its first byte if the transport didn't report one
+func (t *requestTimer) firstChunk() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.timing.FirstByteAt.IsZero() {
+		t.timing.FirstByteAt = t.clock.Now()
+	}
+}
+
+// finish completes the timing, keeping the server durations the provider reported, if any
+func (t *requestTimer) finish(reported *models.Timing) *models.Timing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	timing := t.timing
+	timing.CompletedAt = t.clock.Now()
+	timing.Duration = timing.CompletedAt.Sub(timing.StartedAt)
+	if reported != nil {
+		timing.ServerDuration = reported.ServerDuration
+		timing.EvalDuration = reported.EvalDuration
+	}
+	return &timing
+}
@@ -0,0 +1,173 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/1broseidon/gollm/internal/clock"
+	"github.com/1broseidon/gollm/models"
+)
+
+const timingDelay = 50 * time.Millisecond
+
+// checkDuration fails the test unless d is at least min and not implausibly longer
+func checkDuration(t *testing.T, name string, d, min time.Duration) {
+	t.Helper()
+	if d < min || d > min+time.Second {
+		t.Errorf("Expected %s of about %v, got %v", name, min, d)
+	}
+}
+
+func TestCompletionTiming(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(timingDelay)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	// The provider makes an HTTP request with the context it is given, as the real providers do
+	provider := &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			req, err := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+			if err != nil {
+				return nil, err
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return nil, err
+			}
+			defer resp.Body.Close()
+			io.ReadAll(resp.Body)
+			time.Sleep(timingDelay)
+			return &models.CompletionResponse{Text: "ok", Timing: &models.Timing{ServerDuration: time.Second}}, nil
+		},
+	}
+	c := newMockClient(t, "mock", provider)
+
+	resp, err := c.GenerateCompletion(context.Background(), models.CompletionInput{Model: "mock/model"})
+	if err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+
+	timing := resp.Timing
+	if timing == nil {
+		t.Fatal("Expected timing on the response")
+	}
+	checkDuration(t, "duration", timing.Duration, 2*timingDelay)
+	if timing.QueuedAt.After(timing.StartedAt) || timing.CompletedAt.Sub(timing.StartedAt) != timing.Duration {
+		t.Errorf("Inconsistent timestamps: %+v", timing)
+	}
+	if timing.FirstByteAt.IsZero() {
+		t.Fatal("Expected the first response byte to be observed")
+	}
+	checkDuration(t, "time to first byte", timing.FirstByteAt.Sub(timing.StartedAt), timingDelay)
+	checkDuration(t, "time after first byte", timing.CompletedAt.Sub(timing.FirstByteAt), timingDelay)
+	if timing.ServerDuration != time.Second {
+		t.Errorf("Expected the provider's server duration to be kept, got %v", timing.ServerDuration)
+	}
+}
+
+func TestCompletionTimingWithClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(start)
+	provider := &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			clk.Advance(2 * time.Second)
+			return &models.CompletionResponse{Text: "ok"}, nil
+		},
+	}
+	var result RequestResult
+	hooks := Hooks{AfterRequest: func(ctx context.Context, info RequestInfo, r RequestResult) { result = r }}
+	c := newMockClient(t, "mock", provider, WithClock(clk), WithHooks(hooks))
+
+	resp, err := c.GenerateCompletion(context.Background(), models.CompletionInput{Model: "mock/model"})
+	if err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+	if timing := resp.Timing; !timing.QueuedAt.Equal(start) || timing.Duration != 2*time.Second {
+		t.Errorf("Expected a 2s request started at the fake time, got %+v", timing)
+	}
+	if result.Duration != 2*time.Second {
+		t.Errorf("Expected the hook to see a 2s request, got %v", result.Duration)
+	}
+}
+
+func TestCompletionTimingQueued(t *testing.T) {
+	provider := &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			time.Sleep(timingDelay)
+			return &models.CompletionResponse{Text: "ok"}, nil
+		},
+	}
+	c := newMockClient(t, "mock", provider, WithMaxConcurrent("mock", 1))
+
+	var wg sync.WaitGroup
+	timings := make([]*models.Timing, 2)
+	for i := range timings {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := c.GenerateCompletion(context.Background(), models.CompletionInput{Model: "mock/model"})
+			if err != nil {
+				t.Errorf("GenerateCompletion failed: %v", err)
+				return
+			}
+			timings[i] = resp.Timing
+		}(i)
+	}
+	wg.Wait()
+	if timings[0] == nil || timings[1] == nil {
+		t.FailNow()
+	}
+
+	// One request waited for the other to release its slot
+	waits := []time.Duration{timings[0].StartedAt.Sub(timings[0].QueuedAt), timings[1].StartedAt.Sub(timings[1].QueuedAt)}
+	if waits[0] < waits[1] {
+		waits[0], waits[1] = waits[1], waits[0]
+	}
+	checkDuration(t, "queue wait", waits[0], timingDelay-10*time.Millisecond)
+	for _, timing := range timings {
+		checkDuration(t, "duration", timing.Duration, timingDelay)
+	}
+}
+
+func TestStreamTiming(t *testing.T) {
+	provider := &mockProvider{
+		stream: func(ctx context.Context, modelName string, input models.CompletionInput) (<-chan models.StreamingCompletionResponse, error) {
+			streamChan := make(chan models.StreamingCompletionResponse)
+			go func() {
+				defer close(streamChan)
+				time.Sleep(timingDelay)
+				streamChan <- models.StreamingCompletionResponse{Text: "Hello"}
+				time.Sleep(timingDelay)
+				streamChan <- models.StreamingCompletionResponse{Done: true, Timing: &models.Timing{EvalDuration: time.Second}}
+			}()
+			return streamChan, nil
+		},
+	}
+	c := newMockClient(t, "mock", provider)
+
+	stream, err := c.GenerateCompletionStream(context.Background(), models.CompletionInput{Model: "mock/model"})
+	if err != nil {
+		t.Fatalf("GenerateCompletionStream failed: %v", err)
+	}
+	var chunks []models.StreamingCompletionResponse
+	for chunk := range stream {
+		chunks = append(chunks, chunk)
+	}
+
+	if len(chunks) != 2 || chunks[0].Timing != nil || chunks[1].Timing == nil {
+		t.Fatalf("Expected timing on the Done chunk only, got %+v", chunks)
+	}
+	timing := chunks[1].Timing
+	checkDuration(t, "duration", timing.Duration, 2*timingDelay)
+	checkDuration(t, "time to first chunk", timing.FirstByteAt.Sub(timing.StartedAt), timingDelay)
+	if timing.EvalDuration != time.Second {
+		t.Errorf("Expected the provider's eval duration to be kept, got %v", timing.EvalDuration)
+	}
+}
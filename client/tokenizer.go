@@ -0,0 +1,40 @@
+package client
+
+import (
+	"strings"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// Tokenizer counts the tokens of text locally, without calling the provider, e.g. by wrapping
+// tiktoken. model is the model name without the provider prefix.
+type Tokenizer interface {
+	Count(model, text string) (int, error)
+}
+
+// WhitespaceTokenizer counts the whitespace-separated words of text. It needs no vocabulary but
+// is inaccurate: real tokenizers split long words, numbers and punctuation into several tokens,
+// so it typically undercounts by a third or more. Prefer a tokenizer for the model's vocabulary.
+type WhitespaceTokenizer struct{}
+
+// Count implements Tokenizer
+func (WhitespaceTokenizer) Count(model, text string) (int, error) {
+	return len(strings.Fields(text)), nil
+}
+
+// countPromptTokens counts the prompt tokens of messages with tokenizer, adding the few tokens
+// chat APIs add to frame each message
+func countPromptTokens(tokenizer Tokenizer, model string, messages []models.ChatMessage) (int, error) {
+	if len(messages) == 0 {
+		return 0, nil
+	}
+	tokens := 3
+	for _, message := range messages {
+		n, err := tokenizer.Count(model, message.Content)
+		if err != nil {
+			return 0, err
+		}
+		tokens += 4 + n
+	}
+	return tokens, nil
+}
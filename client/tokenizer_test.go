@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// charTokenizer counts a token per character, recording the models it was asked about
+type charTokenizer struct {
+	models []string
+}
+
+func (t *charTokenizer) Count(model, text string) (int, error) {
+	t.models = append(t.models, model)
+	if text == "fail" {
+		return 0, errors.New("tokenizer failed")
+	}
+	return len(text), nil
+}
+
+func TestWithTokenizer(t *testing.T) {
+	ctx := context.Background()
+	tokenizer := &charTokenizer{}
+	// A TokenCounter that must not be called while a tokenizer is set
+	provider := countingProvider{&mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			return &models.CompletionResponse{Text: "ok"}, nil
+		},
+	}}
+	c := newMockClient(t, "mock", provider, WithTokenizer(tokenizer), WithMaxInputTokens(50))
+
+	messages := []models.ChatMessage{{Role: models.RoleUser, Content: strings.Repeat("x", 20)}}
+	tokens, err := c.CountTokens(ctx, "mock/gpt-4o", messages)
+	if err != nil {
+		t.Fatalf("CountTokens failed: %v", err)
+	}
+	// 20 characters, plus the framing of the request and the message
+	if tokens != 27 {
+		t.Errorf("Expected 27 tokens from the tokenizer, got %d", tokens)
+	}
+	if len(tokenizer.models) != 1 || tokenizer.models[0] != "gpt-4o" {
+		t.Errorf("Expected the tokenizer to be given the model name, got %v", tokenizer.models)
+	}
+
+	// A few words, but more than 50 tokens for the tokenizer
+	long := models.CompletionInput{Model: "mock/gpt-4o", Messages: []models.ChatMessage{{Role: models.RoleUser, Content: strings.Repeat("x", 60)}}}
+	if _, err := c.GenerateCompletion(ctx, long); !errors.Is(err, ErrContextTooLong) {
+		t.Errorf("Expected the tokenizer's count to exceed the limit, got %v", err)
+	}
+	if _, err := c.GenerateCompletion(ctx, models.CompletionInput{Model: "mock/gpt-4o", Messages: messages}); err != nil {
+		t.Errorf("Expected a short prompt to be sent, got %v", err)
+	}
+
+	failing := []models.ChatMessage{{Role: models.RoleUser, Content: "fail"}}
+	if _, err := c.CountTokens(ctx, "mock/gpt-4o", failing); err == nil {
+		t.Error("Expected the tokenizer's error")
+	}
+}
+
+func TestWhitespaceTokenizer(t *testing.T) {
+	if n, _ := (WhitespaceTokenizer{}).Count("gpt-4o", "  How many\twords\nis this? "); n != 5 {
+		t.Errorf("Expected 5 words, got %d", n)
+	}
+
+	clearProviderEnv(t)
+	logger := &recordingLogger{}
+	c, err := NewClient(context.Background(), WithLogger(logger), WithTokenizer(WhitespaceTokenizer{}))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer c.Close()
+	if !logger.contains("WARN: ", "WhitespaceTokenizer") {
+		t.Errorf("Expected a warning about the inaccurate tokenizer, got %v", logger.lines)
+	}
+}
@@ -0,0 +1,47 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/1broseidon/gollm/internal/utils"
+	"github.com/1broseidon/gollm/models"
+)
+
+// TokenCounter is implemented by providers that count tokens with the model's own tokenizer,
+// such as Google Gemini
+type TokenCounter interface {
+	CountTokens(ctx context.Context, modelName string, content string) (int, error)
+}
+
+// CountTokens counts the prompt tokens of messages for model, given as "provider/model", with
+// the tokenizer set with WithTokenizer. Without one, providers implementing TokenCounter count
+// them exactly; for the others, and in dry-run mode, the count is an offline estimate.
+func (c *Client) CountTokens(ctx context.Context, model string, messages []models.ChatMessage) (int, error) {
+	provider, modelName, err := c.parseProviderModel(model)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse provider/model: %w", err)
+	}
+	if c.tokenizer != nil {
+		return countPromptTokens(c.tokenizer, modelName, messages)
+	}
+	if c.dryRun {
+		return utils.EstimatePromptTokens(messages), nil
+	}
+
+	p, err := c.initializeProvider(ctx, provider)
+	if err != nil {
+		return 0, err
+	}
+
+	counter, ok := p.(TokenCounter)
+	if !ok {
+		return utils.EstimatePromptTokens(messages), nil
+	}
+	contents := make([]string, len(messages))
+	for i, message := range messages {
+		contents[i] = message.Content
+	}
+	return counter.CountTokens(ctx, modelName, strings.Join(contents, "\n"))
+}
@@ -0,0 +1,150 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/1broseidon/gollm/internal/clock"
+	"github.com/1broseidon/gollm/models"
+)
+
+// ErrMaxIterations is returned by RunTools when the model is still calling tools after MaxIterations completions
+var ErrMaxIterations = errors.New("tool loop reached the maximum number of iterations")
+
+// defaultMaxIterations caps the completions made by RunTools unless RunOptions.MaxIterations is set
+const defaultMaxIterations = 10
+
+// ToolFunc executes a tool call. args holds the arguments generated by the model, and the returned
+// string is sent back to the model as the result.
+type ToolFunc func(ctx context.Context, args json.RawMessage) (string, error)
+
+// ToolRegistry maps tool names to the functions executing them
+type ToolRegistry map[string]ToolFunc
+
+// RunOptions configures RunTools
+type RunOptions struct {
+	// MaxIterations caps the number of completions; the default is 10
+	MaxIterations int
+	// ToolTimeout limits each tool execution; zero means no limit beyond ctx
+	ToolTimeout time.Duration
+}
+
+// ToolExecution records a tool call made during RunTools
+type ToolExecution struct {
+	Call     models.ToolCall
+	Output   string // The result sent to the model
+	Err      error  // The error of an unknown tool or a failed execution
+	Duration time.Duration
+}
+
+// RunTools runs a tool-calling conversation: it generates a completion, executes the tool calls
+// in the response with the functions in registry, appends the results and asks again, until the
+// model answers without calling tools. Calls requested together run in parallel. Unknown tools and
+// tool errors are reported to the model as results rather than aborting the loop.
+//
+// If input.Tools is empty, each tool in registry is offered by name with unconstrained arguments.
+// The final response carries the usage of all completions. The executions are returned even when
+// RunTools fails, e.g. with ErrMaxIterations.
+func (c *Client) RunTools(ctx context.Context, input models.CompletionInput, registry ToolRegistry, opts RunOptions) (*models.CompletionResponse, []ToolExecution, error) {
+	maxIterations := opts.MaxIterations
+	if maxIterations <= 0 {
+		maxIterations = defaultMaxIterations
+	}
+	if len(input.Tools) == 0 {
+		input.Tools = registryTools(registry)
+	}
+	// Copy the messages so the caller's slice is never appended to
+	input.Messages = append([]models.ChatMessage(nil), input.Messages...)
+
+	usage := &models.Usage{}
+	var executions []ToolExecution
+	for iteration := 0; iteration < maxIterations; iteration++ {
+		resp, err := c.GenerateCompletion(ctx, input)
+		if err != nil {
+			return nil, executions, err
+		}
+		usage.Add(resp.Usage)
+		if len(resp.ToolCalls) == 0 {
+			resp.Usage = usage
+			return resp, executions, nil
+		}
+
+		results := c.executeTools(ctx, resp.ToolCalls, registry, opts.ToolTimeout)
+		executions = append(executions, results...)
+
+		input.Messages = append(input.Messages, models.ToolCallMessage(resp.Text, resp.ToolCalls))
+		for _, result := range results {
+			input.Messages = append(input.Messages, models.ToolResultMessage(result.Call.ID, result.Output))
+		}
+		// A choice that forces a tool call applies to the first completion only, so the model
+		// can answer once it has the results
+		input.ToolChoice = nil
+	}
+
+	return nil, executions, fmt.Errorf("%w (%d)", ErrMaxIterations, maxIterations)
+}
+
+// executeTools runs calls in parallel and returns their executions in the order of calls
+func (c *Client) executeTools(ctx context.Context, calls []models.ToolCall, registry ToolRegistry, timeout time.Duration) []ToolExecution {
+	results := make([]ToolExecution, len(calls))
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		wg.Add(1)
+		go func(i int, call models.ToolCall) {
+			defer wg.Done()
+			results[i] = c.executeTool(ctx, call, registry, timeout)
+		}(i, call)
+	}
+	wg.Wait()
+	return results
+}
+
+// executeTool runs a single tool call. Failures become the result text so the model can react to them.
+func (c *Client) executeTool(ctx context.Context, call models.ToolCall, registry ToolRegistry, timeout time.Duration) ToolExecution {
+	execution := ToolExecution{Call: call}
+	fn, ok := registry[call.Name]
+	if !ok {
+		execution.Err = fmt.Errorf("unknown tool %q", call.Name)
+		execution.Output = "Error: " + execution.Err.Error()
+		return execution
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	c.logger.Debugf("Executing tool %s", call.Name)
+	start := c.clock.Now()
+	output, err := fn(ctx, call.Arguments)
+	execution.Duration = clock.Since(c.clock, start)
+	if err != nil {
+		c.logger.Warnf("Tool %s failed: %v", call.Name, err)
+		execution.Err = err
+		execution.Output = "Error: " + err.Error()
+		return execution
+	}
+	execution.Output = output
+	return execution
+}
+
+// registryTools describes the tools in registry by name, sorted, with unconstrained arguments
+func registryTools(registry ToolRegistry) []models.Tool {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tools := make([]models.Tool, len(names))
+	for i, name := range names {
+		tools[i] = models.Tool{Name: name, Parameters: json.RawMessage(`{"type":"object"}`)}
+	}
+	return tools
+}
@@ -0,0 +1,148 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+func TestRunTools(t *testing.T) {
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var inputs []models.CompletionInput
+	provider := &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			inputs = append(inputs, input)
+			usage := &models.Usage{PromptTokens: 20, CompletionTokens: 10, TotalTokens: 30}
+			if len(inputs) == 1 {
+				return &models.CompletionResponse{Usage: usage, ToolCalls: []models.ToolCall{
+					{ID: "call_1", Name: "get_weather", Arguments: json.RawMessage(`{"city":"Paris"}`)},
+					{ID: "call_2", Name: "get_weather", Arguments: json.RawMessage(`{"city":"London"}`)},
+					{ID: "call_3", Name: "get_stock_price", Arguments: json.RawMessage(`{"symbol":"ACME"}`)},
+				}}, nil
+			}
+			return &models.CompletionResponse{Text: "Paris is sunny and London is rainy.", Usage: usage}, nil
+		},
+	}
+	c := newMockClient(t, "mock", provider)
+
+	// Each weather call waits for the other, so the test only passes if they run in parallel
+	var started sync.WaitGroup
+	started.Add(2)
+	registry := ToolRegistry{
+		"get_weather": func(ctx context.Context, args json.RawMessage) (string, error) {
+			var params struct{ City string }
+			if err := json.Unmarshal(args, &params); err != nil {
+				return "", err
+			}
+			started.Done()
+			started.Wait()
+			if params.City == "London" {
+				return "", errors.New("weather service unavailable for London")
+			}
+			return "sunny", nil
+		},
+	}
+
+	input := models.CompletionInput{Model: "mock/model", Messages: []models.ChatMessage{{Role: "user", Content: "What's the weather in Paris and London?"}}}
+	resp, executions, err := c.RunTools(ctx, input, registry, RunOptions{ToolTimeout: time.Second})
+	if err != nil {
+		t.Fatalf("RunTools failed: %v", err)
+	}
+
+	if resp.Text != "Paris is sunny and London is rainy." || resp.Usage.TotalTokens != 60 {
+		t.Errorf("Unexpected final response: %+v", resp)
+	}
+	if len(inputs[0].Tools) != 1 || inputs[0].Tools[0].Name != "get_weather" {
+		t.Errorf("Expected the registry's tools to be offered, got %+v", inputs[0].Tools)
+	}
+
+	if len(executions) != 3 {
+		t.Fatalf("Expected 3 executions, got %+v", executions)
+	}
+	if executions[0].Output != "sunny" || executions[0].Err != nil {
+		t.Errorf("Unexpected Paris execution: %+v", executions[0])
+	}
+	if executions[1].Err == nil || !strings.Contains(executions[1].Output, "unavailable for London") {
+		t.Errorf("Expected the London error as the result, got %+v", executions[1])
+	}
+	if executions[2].Err == nil || !strings.Contains(executions[2].Output, `unknown tool "get_stock_price"`) {
+		t.Errorf("Expected an unknown tool result, got %+v", executions[2])
+	}
+
+	messages := inputs[1].Messages
+	if len(messages) != 5 {
+		t.Fatalf("Expected 5 messages in the second round, got %+v", messages)
+	}
+	if messages[1].Role != models.RoleAssistant || len(messages[1].ToolCalls) != 3 {
+		t.Errorf("Expected the assistant's tool calls, got %+v", messages[1])
+	}
+	for i, id := range []string{"call_1", "call_2", "call_3"} {
+		if message := messages[2+i]; message.Role != models.RoleTool || message.ToolCallID != id || message.Content != executions[i].Output {
+			t.Errorf("Unexpected tool result message %d: %+v", i, message)
+		}
+	}
+	if err := models.ValidateMessageOrder(messages); err != nil {
+		t.Errorf("Expected a valid conversation, got %v", err)
+	}
+	if len(input.Messages) != 1 {
+		t.Errorf("Expected the caller's messages to be unchanged, got %+v", input.Messages)
+	}
+}
+
+func TestRunToolsLimits(t *testing.T) {
+	ctx := context.Background()
+	loop := &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			return &models.CompletionResponse{ToolCalls: []models.ToolCall{{ID: "call", Name: "slow", Arguments: json.RawMessage(`{}`)}}}, nil
+		},
+	}
+	c := newMockClient(t, "mock", loop)
+
+	registry := ToolRegistry{
+		"slow": func(ctx context.Context, args json.RawMessage) (string, error) {
+			<-ctx.Done()
+			return "", ctx.Err()
+		},
+	}
+	input := models.CompletionInput{Model: "mock/model", Messages: []models.ChatMessage{{Role: "user", Content: "Go"}}}
+	_, executions, err := c.RunTools(ctx, input, registry, RunOptions{MaxIterations: 2, ToolTimeout: 10 * time.Millisecond})
+	if !errors.Is(err, ErrMaxIterations) {
+		t.Fatalf("Expected ErrMaxIterations, got %v", err)
+	}
+	if len(executions) != 2 {
+		t.Fatalf("Expected 2 executions, got %+v", executions)
+	}
+	if !errors.Is(executions[0].Err, context.DeadlineExceeded) {
+		t.Errorf("Expected the tool to time out, got %+v", executions[0])
+	}
+}
+
+func TestToolMessagesValidatedForToolProviders(t *testing.T) {
+	ctx := context.Background()
+	c := newMockClient(t, "tools", &completionOnlyProvider{models.Capabilities{Streaming: true, Tools: true}})
+	c.RegisterProvider("plain", &completionOnlyProvider{models.Capabilities{Streaming: true}})
+
+	// A tool result without the assistant message that made the call
+	messages := []models.ChatMessage{{Role: models.RoleUser, Content: "Weather in Paris?"}, models.ToolResultMessage("call_1", "sunny")}
+	if _, err := c.GenerateCompletion(ctx, models.CompletionInput{Model: "tools/model", Messages: messages}); !errors.Is(err, models.ErrInvalidMessageOrder) {
+		t.Errorf("Expected ErrInvalidMessageOrder, got %v", err)
+	}
+	if _, err := c.GenerateCompletionStream(ctx, models.CompletionInput{Model: "tools/model", Messages: messages}); !errors.Is(err, models.ErrInvalidMessageOrder) {
+		t.Errorf("Expected ErrInvalidMessageOrder for the stream, got %v", err)
+	}
+
+	// Providers without tools send tool results as text
+	if _, err := c.GenerateCompletion(ctx, models.CompletionInput{Model: "plain/model", Messages: messages}); err != nil {
+		t.Errorf("Expected providers without tools to accept the messages, got %v", err)
+	}
+}
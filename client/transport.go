@@ -0,0 +1,55 @@
+package client
+
+import (
+	"net/http"
+
+	"github.com/1broseidon/gollm/internal/utils"
+)
+
+// TransportConfig tunes the HTTP transport used by the OpenAI, Anthropic and Ollama providers.
+// Start from DefaultTransportConfig and adjust the fields that matter for your workload. It is
+// the same type as the provider packages' transport configs.
+type TransportConfig = utils.TransportConfig
+
+// DefaultTransportConfig returns the transport settings used when WithTransportConfig isn't given
+func DefaultTransportConfig() TransportConfig {
+	return utils.DefaultTransportConfig()
+}
+
+// baseTransport returns the transport for a provider's HTTP client: the client's shared
+// transport, or a new one with WithPerProviderTransports
+func (c *Client) baseTransport() *http.Transport {
+	c.transportMu.Lock()
+	defer c.transportMu.Unlock()
+
+	if len(c.transports) > 0 && !c.ownTransports {
+		return c.transports[0]
+	}
+	transport := c.newTransport()
+	c.transports = append(c.transports, transport)
+	return transport
+}
+
+// newTransport builds a transport from the client's transport config and proxy
+func (c *Client) newTransport() *http.Transport {
+	cfg := c.transportConfig
+	if cfg == (TransportConfig{}) {
+		cfg = DefaultTransportConfig()
+	}
+
+	transport := cfg.Transport()
+	if c.proxyURL != nil {
+		transport.Proxy = http.ProxyURL(c.proxyURL)
+	}
+	return transport
+}
+
+// closeIdleConnections closes the idle connections of every transport the client created
+func (c *Client) closeIdleConnections() {
+	c.transportMu.Lock()
+	defer c.transportMu.Unlock()
+
+	for _, transport := range c.transports {
+		transport.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,86 @@
+package client
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// countingServer returns a test server and a counter of the TCP connections it has accepted
+func countingServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var conns atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"ok":true}`)
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	server.Start()
+	t.Cleanup(server.Close)
+	return server, &conns
+}
+
+// get performs a request and reads the whole body so the connection can be reused
+func get(t *testing.T, httpClient *http.Client, url string) {
+	t.Helper()
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
+
+func TestTransportConnectionReuse(t *testing.T) {
+	t.Run("SharedAcrossProviders", func(t *testing.T) {
+		server, conns := countingServer(t)
+		c := &Client{}
+		defer c.closeIdleConnections()
+
+		// Each built-in provider gets its own http.Client from httpClient
+		first, second := c.httpClient(), c.httpClient()
+		for i := 0; i < 5; i++ {
+			get(t, first, server.URL)
+			get(t, second, server.URL)
+		}
+
+		if n := conns.Load(); n != 1 {
+			t.Errorf("Expected sequential requests to reuse 1 connection, got %d", n)
+		}
+	})
+
+	t.Run("PerProviderTransports", func(t *testing.T) {
+		server, conns := countingServer(t)
+		c := &Client{}
+		WithPerProviderTransports()(c)
+		defer c.closeIdleConnections()
+
+		first, second := c.httpClient(), c.httpClient()
+		for i := 0; i < 5; i++ {
+			get(t, first, server.URL)
+			get(t, second, server.URL)
+		}
+
+		if n := conns.Load(); n != 2 {
+			t.Errorf("Expected one connection per provider, got %d", n)
+		}
+	})
+
+	t.Run("Config", func(t *testing.T) {
+		cfg := TransportConfig{MaxIdleConns: 7, MaxIdleConnsPerHost: 3, ForceAttemptHTTP2: true}
+		c := &Client{}
+		WithTransportConfig(cfg)(c)
+
+		transport := c.baseTransport()
+		if transport.MaxIdleConns != 7 || transport.MaxIdleConnsPerHost != 3 || !transport.ForceAttemptHTTP2 {
+			t.Errorf("Transport doesn't match config: MaxIdleConns=%d MaxIdleConnsPerHost=%d ForceAttemptHTTP2=%v",
+				transport.MaxIdleConns, transport.MaxIdleConnsPerHost, transport.ForceAttemptHTTP2)
+		}
+	})
+}
@@ -0,0 +1,32 @@
+package client
+
+import (
+	"github.com/1broseidon/gollm/internal/utils"
+	"github.com/1broseidon/gollm/models"
+)
+
+// usageMissing reports whether a provider returned no usage: none at all, or only zero counts
+func usageMissing(usage *models.Usage) bool {
+	return usage == nil || (usage.PromptTokens == 0 && usage.CompletionTokens == 0 && usage.TotalTokens == 0)
+}
+
+// estimateUsage returns usage, or an estimate flagged Estimated if the provider didn't report
+// it. The prompt is messages and the completion text; they are counted with the tokenizer set
+// with WithTokenizer, falling back to an offline estimate without one or if it fails.
+func (c *Client) estimateUsage(usage *models.Usage, model string, messages []models.ChatMessage, text string) *models.Usage {
+	if !usageMissing(usage) {
+		return usage
+	}
+
+	prompt, err := c.promptTokens(model, messages)
+	if err != nil {
+		prompt = utils.EstimatePromptTokens(messages)
+	}
+	completion := utils.EstimateTokens(text)
+	if c.tokenizer != nil {
+		if n, err := c.tokenizer.Count(model, text); err == nil {
+			completion = n
+		}
+	}
+	return &models.Usage{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion, Estimated: true}
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+func TestEstimatedUsage(t *testing.T) {
+	ctx := context.Background()
+	reported := &models.Usage{PromptTokens: 7, CompletionTokens: 2, TotalTokens: 9}
+	messages := []models.ChatMessage{{Role: models.RoleUser, Content: strings.Repeat("x", 20)}}
+
+	tests := []struct {
+		name      string
+		usage     *models.Usage
+		tokenizer Tokenizer
+		want      models.Usage
+	}{
+		{"Reported", reported, nil, *reported},
+		// 20 characters plus the framing of the request and the message, and "Hello, world"
+		{"Tokenizer", nil, &charTokenizer{}, models.Usage{PromptTokens: 27, CompletionTokens: 12, TotalTokens: 39, Estimated: true}},
+		{"ZeroCounts", &models.Usage{}, &charTokenizer{}, models.Usage{PromptTokens: 27, CompletionTokens: 12, TotalTokens: 39, Estimated: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &mockProvider{
+				completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+					return &models.CompletionResponse{Text: "Hello, world", Usage: tt.usage}, nil
+				},
+				stream: streamChunks(
+					models.StreamingCompletionResponse{Text: "Hello"},
+					models.StreamingCompletionResponse{Text: ", world", Done: true, Usage: tt.usage},
+				),
+			}
+			var opts []ClientOption
+			if tt.tokenizer != nil {
+				opts = append(opts, WithTokenizer(tt.tokenizer))
+			}
+			c := newMockClient(t, "mock", provider, opts...)
+			input := models.CompletionInput{Model: "mock/gpt-4o", Messages: messages}
+
+			resp, err := c.GenerateCompletion(ctx, input)
+			if err != nil {
+				t.Fatalf("GenerateCompletion failed: %v", err)
+			}
+			if resp.Usage == nil || *resp.Usage != tt.want {
+				t.Errorf("Expected the usage %+v, got %+v", tt.want, resp.Usage)
+			}
+
+			stream, err := c.GenerateCompletionStream(ctx, input)
+			if err != nil {
+				t.Fatalf("GenerateCompletionStream failed: %v", err)
+			}
+			var done models.StreamingCompletionResponse
+			for chunk := range stream {
+				done = chunk
+			}
+			if done.Usage == nil || *done.Usage != tt.want {
+				t.Errorf("Expected the usage %+v on the Done chunk, got %+v", tt.want, done.Usage)
+			}
+		})
+	}
+}
+
+func TestEstimatedUsageWithoutTokenizer(t *testing.T) {
+	provider := &mockProvider{
+		completion: func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+			return &models.CompletionResponse{Text: "Paris is the capital of France."}, nil
+		},
+	}
+	c := newMockClient(t, "mock", provider)
+
+	resp, err := c.GenerateText(context.Background(), "mock/model", "What is the capital of France?")
+	if err != nil {
+		t.Fatalf("GenerateText failed: %v", err)
+	}
+	usage := resp.Usage
+	if usage == nil || !usage.Estimated || usage.PromptTokens == 0 || usage.CompletionTokens == 0 || usage.TotalTokens != usage.PromptTokens+usage.CompletionTokens {
+		t.Errorf("Expected an offline estimate of the usage, got %+v", usage)
+	}
+}
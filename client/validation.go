@@ -0,0 +1,66 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// ValidationExhaustedError is returned by GenerateWithValidation when no attempt passed validation
+type ValidationExhaustedError struct {
+	Attempts   int
+	LastOutput string        // The text of the final attempt
+	Err        error         // The validation error of the final attempt
+	Usage      *models.Usage // The usage of all attempts
+}
+
+func (e *ValidationExhaustedError) Error() string {
+	return fmt.Sprintf("output failed validation after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *ValidationExhaustedError) Unwrap() error {
+	return e.Err
+}
+
+// GenerateWithValidation generates a completion and checks its text with validate. When validation
+// fails, the invalid output and the error are appended to the conversation and the model is asked
+// again, up to maxAttempts completions in total. The first passing response is returned with the
+// usage of all attempts summed. If every attempt fails it returns a *ValidationExhaustedError.
+func (c *Client) GenerateWithValidation(ctx context.Context, input models.CompletionInput, validate func(string) error, maxAttempts int) (*models.CompletionResponse, error) {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	// Copy the messages so the caller's slice is never appended to
+	input.Messages = append([]models.ChatMessage(nil), input.Messages...)
+
+	usage := &models.Usage{}
+	var lastOutput string
+	var lastErr error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		resp, err := c.GenerateCompletion(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		usage.Add(resp.Usage)
+
+		if lastErr = validate(resp.Text); lastErr == nil {
+			resp.Usage = usage
+			return resp, nil
+		}
+		lastOutput = resp.Text
+		c.logger.Debugf("Attempt %d of %d failed validation: %v", attempt, maxAttempts, lastErr)
+
+		input.Messages = append(input.Messages,
+			models.ChatMessage{Role: models.RoleAssistant, Content: resp.Text},
+			models.ChatMessage{Role: models.RoleUser, Content: fmt.Sprintf(
+				"Your previous response was invalid: %v. Reply again with the corrected response only.", lastErr)},
+		)
+	}
+
+	return nil, &ValidationExhaustedError{Attempts: maxAttempts, LastOutput: lastOutput, Err: lastErr, Usage: usage}
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// scriptedCompletions returns a completion func replying with texts in order, recording the inputs
+func scriptedCompletions(texts []string, inputs *[]models.CompletionInput) func(context.Context, string, models.CompletionInput) (*models.CompletionResponse, error) {
+	return func(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+		text := texts[len(*inputs)]
+		*inputs = append(*inputs, input)
+		return &models.CompletionResponse{Text: text, Usage: &models.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}}, nil
+	}
+}
+
+func validJSON(text string) error {
+	var v interface{}
+	return json.Unmarshal([]byte(text), &v)
+}
+
+func TestGenerateWithValidation(t *testing.T) {
+	ctx := context.Background()
+	messages := []models.ChatMessage{{Role: "user", Content: "Reply with a JSON object"}}
+
+	t.Run("Repair", func(t *testing.T) {
+		var inputs []models.CompletionInput
+		provider := &mockProvider{completion: scriptedCompletions([]string{"Sure! {oops", "```json\n{}```", `{"ok": true}`}, &inputs)}
+		c := newMockClient(t, "mock", provider)
+
+		resp, err := c.GenerateWithValidation(ctx, models.CompletionInput{Model: "mock/model", Messages: messages}, validJSON, 3)
+		if err != nil {
+			t.Fatalf("GenerateWithValidation failed: %v", err)
+		}
+		if resp.Text != `{"ok": true}` {
+			t.Errorf("Expected the passing response, got %q", resp.Text)
+		}
+		if resp.Usage.PromptTokens != 30 || resp.Usage.CompletionTokens != 15 || resp.Usage.TotalTokens != 45 {
+			t.Errorf("Expected the usage of all three attempts, got %+v", resp.Usage)
+		}
+
+		if len(inputs) != 3 || len(inputs[2].Messages) != 5 {
+			t.Fatalf("Expected 3 attempts ending with 5 messages, got %+v", inputs)
+		}
+		retry := inputs[1].Messages
+		if retry[1].Role != models.RoleAssistant || retry[1].Content != "Sure! {oops" {
+			t.Errorf("Expected the invalid output as an assistant message, got %+v", retry[1])
+		}
+		if retry[2].Role != models.RoleUser || !strings.Contains(retry[2].Content, "invalid character") {
+			t.Errorf("Expected the validation error as a user message, got %+v", retry[2])
+		}
+		if len(messages) != 1 {
+			t.Errorf("Expected the caller's messages to be unchanged, got %+v", messages)
+		}
+	})
+
+	t.Run("Exhausted", func(t *testing.T) {
+		var inputs []models.CompletionInput
+		provider := &mockProvider{completion: scriptedCompletions([]string{"no", "still no"}, &inputs)}
+		c := newMockClient(t, "mock", provider)
+
+		_, err := c.GenerateWithValidation(ctx, models.CompletionInput{Model: "mock/model", Messages: messages}, validJSON, 2)
+		var exhausted *ValidationExhaustedError
+		if !errors.As(err, &exhausted) {
+			t.Fatalf("Expected a ValidationExhaustedError, got %v", err)
+		}
+		var syntaxErr *json.SyntaxError
+		if exhausted.Attempts != 2 || exhausted.LastOutput != "still no" || exhausted.Usage.TotalTokens != 30 || !errors.As(err, &syntaxErr) {
+			t.Errorf("Unexpected error: %+v", exhausted)
+		}
+	})
+
+	t.Run("Cancelled", func(t *testing.T) {
+		var inputs []models.CompletionInput
+		cctx, cancel := context.WithCancel(ctx)
+		validate := func(text string) error {
+			cancel()
+			return errors.New("invalid")
+		}
+		provider := &mockProvider{completion: scriptedCompletions([]string{"a", "b"}, &inputs)}
+		c := newMockClient(t, "mock", provider)
+
+		if _, err := c.GenerateWithValidation(cctx, models.CompletionInput{Model: "mock/model", Messages: messages}, validate, 2); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+		if len(inputs) != 1 {
+			t.Errorf("Expected no attempt after cancellation, got %d", len(inputs))
+		}
+	})
+}
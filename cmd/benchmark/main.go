@@ -0,0 +1,118 @@
+// Command benchmark compares the completion latency and throughput of the configured providers.
+//
+// Usage:
+//
+//	benchmark [--prompt text] [--iterations n] [--concurrency n] [--max-tokens n] [--model provider/model]... [--format markdown|json]
+//
+// Every provider configured in the environment or ~/.config/gollm/credentials, as by
+// client.NewClient, is sent the same prompt iterations times, with the model of
+// benchmarks.DefaultModels unless --model names another. The results are printed as a Markdown
+// table or as JSON. Install with:
+//
+//	go install github.com/1broseidon/gollm/cmd/benchmark@latest
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/1broseidon/gollm/benchmarks"
+	"github.com/1broseidon/gollm/client"
+)
+
+// errUsage is returned for invalid command lines, after the usage has been printed
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	switch {
+	case errors.Is(err, errUsage):
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "benchmark:", err)
+		os.Exit(1)
+	}
+}
+
+// modelFlags collects the --model flags
+type modelFlags []string
+
+func (f *modelFlags) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *modelFlags) Set(s string) error {
+	if provider, model, ok := strings.Cut(s, "/"); !ok || provider == "" || model == "" {
+		return fmt.Errorf("expected provider/model, got %q", s)
+	}
+	*f = append(*f, s)
+	return nil
+}
+
+// run benchmarks the providers as set by the command line args, writing the report to stdout
+// and diagnostics to stderr
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("benchmark", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	prompt := fs.String("prompt", "Write one sentence about the sea.", "the prompt sent to every provider")
+	iterations := fs.Int("iterations", 10, "the number of requests sent to each provider")
+	concurrency := fs.Int("concurrency", 1, "the number of requests in flight to each provider at once")
+	maxTokens := fs.Int("max-tokens", 0, "the maximum number of tokens to generate (0 for the provider default)")
+	format := fs.String("format", "markdown", "the report format: markdown or json")
+	var modelList modelFlags
+	fs.Var(&modelList, "model", "the model of a provider, as provider/model; may be repeated")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return errUsage
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "benchmark: unexpected arguments %q\n", fs.Args())
+		return errUsage
+	}
+	if *format != "markdown" && *format != "json" {
+		fmt.Fprintf(stderr, "benchmark: unknown format %q; use markdown or json\n", *format)
+		return errUsage
+	}
+	if *iterations < 1 {
+		fmt.Fprintln(stderr, "benchmark: --iterations must be at least 1")
+		return errUsage
+	}
+
+	c, err := client.NewClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if c.ProviderCount() == 0 {
+		return errors.New("no provider is configured; set an API key such as OPENAI_API_KEY")
+	}
+
+	opts := []benchmarks.Option{benchmarks.WithConcurrency(*concurrency), benchmarks.WithMaxTokens(*maxTokens)}
+	for _, m := range modelList {
+		provider, model, _ := strings.Cut(m, "/")
+		opts = append(opts, benchmarks.WithModel(provider, model))
+	}
+	report := benchmarks.RunProviderBenchmark(ctx, c, *prompt, *iterations, opts...)
+
+	if *format == "json" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(stdout, "%s\n", data)
+		return err
+	}
+	return report.WriteMarkdown(stdout)
+}
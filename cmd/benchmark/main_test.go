@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// newOllamaEnv points the client at a fake Ollama server, with no other provider configured, and
+// returns the number of completions it serves
+func newOllamaEnv(t *testing.T) *atomic.Int32 {
+	var completions atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			http.NotFound(w, r)
+			return
+		}
+		var request struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		if request.Model != "tinyllama" {
+			http.Error(w, "model not found", http.StatusNotFound)
+			return
+		}
+		completions.Add(1)
+		w.Write([]byte(`{"message":{"role":"assistant","content":"Waves."},"done":true,"prompt_eval_count":8,"eval_count":3}`))
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	t.Setenv("GEMINI_API_KEY", "")
+	t.Setenv("OLLAMA_BASE_URL", server.URL)
+	return &completions
+}
+
+func TestBenchmark(t *testing.T) {
+	completions := newOllamaEnv(t)
+
+	var stdout, stderr bytes.Buffer
+	err := run(context.Background(), []string{"--iterations", "3", "--concurrency", "2", "--model", "ollama/tinyllama"}, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("run failed: %v (stderr: %s)", err, stderr.String())
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[2], "| ollama | tinyllama |") || !strings.Contains(lines[2], "| 0.0% |") {
+		t.Errorf("Expected a table with the ollama result, got:\n%s", stdout.String())
+	}
+	if n := completions.Load(); n != 3 {
+		t.Errorf("Expected 3 completions, got %d", n)
+	}
+
+	stdout.Reset()
+	if err := run(context.Background(), []string{"--iterations", "2", "--format", "json", "--model", "ollama/tinyllama"}, &stdout, &stderr); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	var report struct {
+		Iterations int `json:"iterations"`
+		Results    []struct {
+			Provider  string  `json:"provider"`
+			ErrorRate float64 `json:"error_rate"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		t.Fatalf("Invalid JSON report: %v\n%s", err, stdout.String())
+	}
+	if report.Iterations != 2 || len(report.Results) != 1 || report.Results[0].Provider != "ollama" || report.Results[0].ErrorRate != 0 {
+		t.Errorf("Unexpected report: %s", stdout.String())
+	}
+}
+
+func TestBenchmarkUsage(t *testing.T) {
+	newOllamaEnv(t)
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"UnknownFormat", []string{"--format", "csv"}, "unknown format"},
+		{"InvalidModel", []string{"--model", "tinyllama"}, "expected provider/model"},
+		{"NoIterations", []string{"--iterations", "0"}, "at least 1"},
+		{"ExtraArguments", []string{"now"}, "unexpected arguments"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if err := run(context.Background(), tt.args, &stdout, &stderr); !errors.Is(err, errUsage) {
+				t.Errorf("Expected errUsage, got %v", err)
+			}
+			if !strings.Contains(stderr.String(), tt.wantErr) {
+				t.Errorf("Expected %q in the diagnostics, got %q", tt.wantErr, stderr.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,359 @@
+// Command gollm runs completions, embeddings and chats from the terminal.
+//
+// Usage:
+//
+//	gollm complete --model openai/gpt-4o --prompt "..." [--stream] [--temperature 0.7] [--max-tokens 200]
+//	gollm embed --model ollama/nomic-embed-text --text "..."
+//	gollm chat --model anthropic/claude-3-5-sonnet-latest [--system "..."] [--stream]
+//
+// complete prints the response text to stdout and the token usage to stderr, and reads the prompt
+// from stdin when --prompt is omitted. embed prints the embedding as a JSON array. chat reads one
+// message per line from stdin and keeps the conversation across turns until EOF or /exit.
+//
+// API keys are read from the environment or ~/.config/gollm/credentials, as by client.NewClient.
+// Install with:
+//
+//	go install github.com/1broseidon/gollm/cmd/gollm@latest
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	"github.com/1broseidon/gollm/client"
+	"github.com/1broseidon/gollm/models"
+)
+
+// errUsage is returned for invalid command lines, after the usage has been printed
+var errUsage = errors.New("invalid usage")
+
+const usage = `Usage:
+  gollm complete --model provider/model [--prompt text] [--stream] [--temperature t] [--top-p p] [--max-tokens n]
+  gollm embed [--model provider[/model]] --text text
+  gollm chat --model provider/model [--system text] [--stream] [--temperature t] [--top-p p] [--max-tokens n]
+
+Run "gollm <command> --help" for the flags of a command.
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	switch {
+	case errors.Is(err, errUsage):
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "gollm:", err)
+		os.Exit(1)
+	}
+}
+
+// run executes the command line args, reading input from stdin and writing results to stdout
+// and diagnostics to stderr
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return errUsage
+	}
+	var err error
+	switch args[0] {
+	case "complete":
+		err = runComplete(ctx, args[1:], stdin, stdout, stderr)
+	case "embed":
+		err = runEmbed(ctx, args[1:], stdout, stderr)
+	case "chat":
+		err = runChat(ctx, args[1:], stdin, stdout, stderr)
+	case "help", "-h", "--help":
+		fmt.Fprint(stdout, usage)
+	default:
+		fmt.Fprintf(stderr, "gollm: unknown command %q\n\n%s", args[0], usage)
+		err = errUsage
+	}
+	// The flags of a command were printed for --help
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	return err
+}
+
+// generationFlags are the flags shared by complete and chat
+type generationFlags struct {
+	model       string
+	stream      bool
+	temperature optionalFloat
+	topP        optionalFloat
+	maxTokens   int
+}
+
+// register adds the flags to fs
+func (f *generationFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.model, "model", "", "the model, as provider/model (e.g. openai/gpt-4o)")
+	fs.BoolVar(&f.stream, "stream", false, "print the response as it is generated")
+	fs.Var(&f.temperature, "temperature", "the sampling temperature (the provider default if omitted)")
+	fs.Var(&f.topP, "top-p", "the nucleus sampling probability (the provider default if omitted)")
+	fs.IntVar(&f.maxTokens, "max-tokens", 0, "the maximum number of tokens to generate (0 for the provider default)")
+}
+
+// input returns a completion input for messages
+func (f *generationFlags) input(messages []models.ChatMessage) models.CompletionInput {
+	return models.CompletionInput{
+		Model:       f.model,
+		Messages:    messages,
+		MaxTokens:   f.maxTokens,
+		Temperature: f.temperature.value,
+		TopP:        f.topP.value,
+		Stream:      f.stream,
+	}
+}
+
+// optionalFloat is a flag that is nil unless given, so that the provider default applies
+type optionalFloat struct {
+	value *float32
+}
+
+func (f *optionalFloat) String() string {
+	if f.value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(float64(*f.value), 'g', -1, 32)
+}
+
+func (f *optionalFloat) Set(s string) error {
+	v, err := strconv.ParseFloat(s, 32)
+	if err != nil {
+		return err
+	}
+	f.value = models.Float32(float32(v))
+	return nil
+}
+
+// parseFlags parses args with fs, printing errors and help to stderr. It returns flag.ErrHelp
+// for --help.
+func parseFlags(fs *flag.FlagSet, args []string, stderr io.Writer) error {
+	fs.SetOutput(stderr)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "gollm %s: unexpected arguments %q\n", fs.Name(), fs.Args())
+		return errUsage
+	}
+	return nil
+}
+
+// requireFlag reports a missing flag
+func requireFlag(fs *flag.FlagSet, name, value string, stderr io.Writer) error {
+	if value != "" {
+		return nil
+	}
+	fmt.Fprintf(stderr, "gollm %s: --%s is required\n", fs.Name(), name)
+	fs.Usage()
+	return errUsage
+}
+
+// runComplete generates a single completion
+func runComplete(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("complete", flag.ContinueOnError)
+	var gen generationFlags
+	gen.register(fs)
+	prompt := fs.String("prompt", "", "the prompt; read from stdin if omitted")
+	if err := parseFlags(fs, args, stderr); err != nil {
+		return err
+	}
+	if err := requireFlag(fs, "model", gen.model, stderr); err != nil {
+		return err
+	}
+
+	if *prompt == "" {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read the prompt: %w", err)
+		}
+		*prompt = strings.TrimSpace(string(data))
+	}
+	if *prompt == "" {
+		return errors.New("empty prompt")
+	}
+
+	c, err := client.NewClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	input := gen.input([]models.ChatMessage{{Role: models.RoleUser, Content: *prompt}})
+	usage, err := generate(ctx, c, input, stdout)
+	if err != nil {
+		return err
+	}
+	printUsage(stderr, usage)
+	return nil
+}
+
+// runEmbed prints the embedding of a text
+func runEmbed(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("embed", flag.ContinueOnError)
+	model := fs.String("model", "", "the provider, optionally with a model (e.g. ollama/nomic-embed-text); the default provider if omitted")
+	text := fs.String("text", "", "the text to embed")
+	if err := parseFlags(fs, args, stderr); err != nil {
+		return err
+	}
+	if err := requireFlag(fs, "text", *text, stderr); err != nil {
+		return err
+	}
+
+	var options []client.ClientOption
+	if *model != "" {
+		provider, modelName, _ := strings.Cut(*model, "/")
+		options = append(options, client.WithDefaultProvider(provider))
+		// The client embeds with the default provider, and Ollama reads its embedding model
+		// from the environment
+		if provider == "ollama" && modelName != "" {
+			os.Setenv("OLLAMA_EMBED_MODEL", modelName)
+		}
+	}
+
+	c, err := client.NewClient(ctx, options...)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	embedding, err := c.GenerateEmbedding(ctx, *text)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(stdout).Encode(embedding)
+}
+
+// runChat runs an interactive chat, reading one user message per line
+func runChat(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("chat", flag.ContinueOnError)
+	var gen generationFlags
+	gen.register(fs)
+	system := fs.String("system", "", "a system prompt for the conversation")
+	if err := parseFlags(fs, args, stderr); err != nil {
+		return err
+	}
+	if err := requireFlag(fs, "model", gen.model, stderr); err != nil {
+		return err
+	}
+
+	c, err := client.NewClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	var history []models.ChatMessage
+	if *system != "" {
+		history = append(history, models.ChatMessage{Role: models.RoleSystem, Content: *system})
+	}
+	session := c.NewChatSession(gen.model, history...)
+	session.SetCompletionOptions(gen.input(nil))
+
+	scanner := bufio.NewScanner(stdin)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for {
+		fmt.Fprint(stderr, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(stderr)
+			return scanner.Err()
+		}
+		line := strings.TrimSpace(scanner.Text())
+		switch line {
+		case "":
+			continue
+		case "/exit", "/quit":
+			return nil
+		}
+
+		usage, err := sendChat(ctx, session, line, gen.stream, stdout)
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			fmt.Fprintln(stderr, "gollm:", err)
+			continue
+		}
+		printUsage(stderr, usage)
+	}
+}
+
+// sendChat sends message in session, streamed if stream is set, and writes the reply followed
+// by a newline to stdout. If the completion fails, the session drops the message so the
+// conversation can continue.
+func sendChat(ctx context.Context, session *client.ChatSession, message string, stream bool, stdout io.Writer) (*models.Usage, error) {
+	if !stream {
+		resp, err := session.Send(ctx, message)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintln(stdout, resp.Text)
+		return resp.Usage, nil
+	}
+
+	chunks, err := session.SendStream(ctx, message)
+	if err != nil {
+		return nil, err
+	}
+	return printStream(chunks, stdout)
+}
+
+// generate runs a completion, streamed if input.Stream is set, and writes its text followed by
+// a newline to stdout. It returns the usage, which may be nil.
+func generate(ctx context.Context, c *client.Client, input models.CompletionInput, stdout io.Writer) (*models.Usage, error) {
+	if !input.Stream {
+		resp, err := c.GenerateCompletion(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintln(stdout, resp.Text)
+		return resp.Usage, nil
+	}
+
+	stream, err := c.GenerateCompletionStream(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return printStream(stream, stdout)
+}
+
+// printStream writes the text of stream followed by a newline to stdout, and returns its usage
+func printStream(stream <-chan models.StreamingCompletionResponse, stdout io.Writer) (*models.Usage, error) {
+	var acc models.StreamAccumulator
+	for chunk := range stream {
+		fmt.Fprint(stdout, acc.Add(chunk))
+	}
+	fmt.Fprintln(stdout)
+	if err := acc.Err(); err != nil {
+		return nil, err
+	}
+	_, usage, _ := acc.Result()
+	return usage, nil
+}
+
+// printUsage writes the token usage, if there is any, marking estimates
+func printUsage(w io.Writer, usage *models.Usage) {
+	if usage == nil {
+		return
+	}
+	label := "usage"
+	if usage.Estimated {
+		label = "estimated usage"
+	}
+	fmt.Fprintf(w, "%s: %d prompt + %d completion = %d tokens\n", label, usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens)
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/1broseidon/gollm/client"
+)
+
+// ollamaRequest is the part of an Ollama request the fake server looks at
+type ollamaRequest struct {
+	Model    string                           `json:"model"`
+	Messages []struct{ Role, Content string } `json:"messages"`
+	Stream   bool                             `json:"stream"`
+	Options  map[string]interface{}           `json:"options"`
+}
+
+// prompt returns the content of the last message of the request
+func (r ollamaRequest) prompt() string {
+	if len(r.Messages) == 0 {
+		return ""
+	}
+	return r.Messages[len(r.Messages)-1].Content
+}
+
+// newOllamaEnv points the client at a fake Ollama server, with no other provider configured, and
+// returns the requests it receives
+func newOllamaEnv(t *testing.T) *[]ollamaRequest {
+	var requests []ollamaRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ollamaRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		requests = append(requests, request)
+		switch {
+		case r.URL.Path == "/api/embed":
+			w.Write([]byte(`{"embeddings":[[0.5,-1,2]]}`))
+		case r.URL.Path != "/api/chat":
+			http.NotFound(w, r)
+		case request.prompt() == "fail":
+			http.Error(w, "boom", http.StatusInternalServerError)
+		case request.Stream:
+			w.Write([]byte(`{"message":{"role":"assistant","content":"Echo: "},"done":false}` + "\n"))
+			w.Write([]byte(`{"message":{"role":"assistant","content":"` + request.prompt() + `"},"done":false}` + "\n"))
+			w.Write([]byte(`{"message":{"role":"assistant","content":""},"done":true,"prompt_eval_count":3,"eval_count":2}` + "\n"))
+		default:
+			w.Write([]byte(`{"message":{"role":"assistant","content":"Echo: ` + request.prompt() + `"},"done":true,"prompt_eval_count":3,"eval_count":2}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	t.Setenv("GEMINI_API_KEY", "")
+	t.Setenv("OLLAMA_EMBED_MODEL", "")
+	t.Setenv("OLLAMA_BASE_URL", server.URL)
+	return &requests
+}
+
+func TestComplete(t *testing.T) {
+	requests := newOllamaEnv(t)
+	tests := []struct {
+		name  string
+		args  []string
+		stdin string
+	}{
+		{"Prompt", []string{"complete", "--model", "ollama/llama3.1", "--prompt", "hello"}, ""},
+		{"Stream", []string{"complete", "--model", "ollama/llama3.1", "--prompt", "hello", "--stream"}, ""},
+		{"Stdin", []string{"complete", "--model", "ollama/llama3.1", "--max-tokens", "20"}, "hello\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if err := run(context.Background(), tt.args, strings.NewReader(tt.stdin), &stdout, &stderr); err != nil {
+				t.Fatalf("run failed: %v (%s)", err, stderr.String())
+			}
+			if stdout.String() != "Echo: hello\n" {
+				t.Errorf("Expected the response on stdout, got %q", stdout.String())
+			}
+			if !strings.Contains(stderr.String(), "3 prompt + 2 completion = 5 tokens") {
+				t.Errorf("Expected the usage on stderr, got %q", stderr.String())
+			}
+			if last := (*requests)[len(*requests)-1]; last.Model != "llama3.1" {
+				t.Errorf("Expected the model llama3.1, got %q", last.Model)
+			}
+		})
+	}
+}
+
+func TestSamplingFlags(t *testing.T) {
+	requests := newOllamaEnv(t)
+	tests := []struct {
+		args []string
+		want map[string]interface{}
+	}{
+		{nil, nil},
+		{[]string{"--temperature", "0", "--top-p", "0.5"}, map[string]interface{}{"temperature": 0.0, "top_p": 0.5}},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		args := append([]string{"complete", "--model", "ollama/llama3.1", "--prompt", "hello"}, tt.args...)
+		if err := run(context.Background(), args, strings.NewReader(""), &stdout, &stderr); err != nil {
+			t.Fatalf("run failed: %v (%s)", err, stderr.String())
+		}
+		if got := (*requests)[len(*requests)-1].Options; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Expected the options %v for %q, got %v", tt.want, tt.args, got)
+		}
+	}
+}
+
+func TestEmbed(t *testing.T) {
+	requests := newOllamaEnv(t)
+	var stdout, stderr bytes.Buffer
+	args := []string{"embed", "--model", "ollama/nomic-embed-text", "--text", "hello"}
+	if err := run(context.Background(), args, strings.NewReader(""), &stdout, &stderr); err != nil {
+		t.Fatalf("run failed: %v (%s)", err, stderr.String())
+	}
+	if stdout.String() != "[0.5,-1,2]\n" {
+		t.Errorf("Expected the embedding as a JSON array, got %q", stdout.String())
+	}
+	if last := (*requests)[len(*requests)-1]; last.Model != "nomic-embed-text" {
+		t.Errorf("Expected the embedding model nomic-embed-text, got %q", last.Model)
+	}
+}
+
+func TestChat(t *testing.T) {
+	requests := newOllamaEnv(t)
+	var stdout, stderr bytes.Buffer
+	stdin := strings.NewReader("hi\n\nfail\nbye\n/exit\nignored\n")
+	args := []string{"chat", "--model", "ollama/llama3.1", "--system", "Be brief."}
+	if err := run(context.Background(), args, stdin, &stdout, &stderr); err != nil {
+		t.Fatalf("run failed: %v (%s)", err, stderr.String())
+	}
+
+	if stdout.String() != "Echo: hi\nEcho: bye\n" {
+		t.Errorf("Expected a reply per message, got %q", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "gollm: API request failed") {
+		t.Errorf("Expected the failed turn to be reported, got %q", stderr.String())
+	}
+	if len(*requests) != 3 {
+		t.Errorf("Expected 3 requests, stopping at /exit, got %d", len(*requests))
+	}
+}
+
+func TestChatSession(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		newOllamaEnv(t)
+		c, err := client.NewClient(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		session := c.NewChatSession("ollama/llama3.1")
+
+		var stdout bytes.Buffer
+		if _, err := sendChat(context.Background(), session, "hi", stream, &stdout); err != nil {
+			t.Fatalf("sendChat failed: %v", err)
+		}
+		if _, err := sendChat(context.Background(), session, "fail", stream, &stdout); err == nil {
+			t.Fatal("Expected an error")
+		}
+		if _, err := sendChat(context.Background(), session, "bye", stream, &stdout); err != nil {
+			t.Fatalf("sendChat failed: %v", err)
+		}
+
+		want := []string{"user: hi", "assistant: Echo: hi", "user: bye", "assistant: Echo: bye"}
+		history := session.History()
+		if len(history) != len(want) {
+			t.Fatalf("Expected %d messages with stream %v, got %+v", len(want), stream, history)
+		}
+		for i, message := range history {
+			if got := message.Role + ": " + message.Content; got != want[i] {
+				t.Errorf("Expected message %d to be %q with stream %v, got %q", i, want[i], stream, got)
+			}
+		}
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	newOllamaEnv(t)
+	tests := [][]string{
+		nil,
+		{"unknown"},
+		{"complete", "--prompt", "hello"},
+		{"complete", "--model", "ollama/llama3.1", "extra"},
+		{"embed"},
+		{"chat", "--bogus"},
+	}
+	for _, args := range tests {
+		var stdout, stderr bytes.Buffer
+		if err := run(context.Background(), args, strings.NewReader(""), &stdout, &stderr); !errors.Is(err, errUsage) {
+			t.Errorf("Expected a usage error for %q, got %v", args, err)
+		}
+		if stderr.Len() == 0 {
+			t.Errorf("Expected usage on stderr for %q", args)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := run(context.Background(), []string{"complete", "--help"}, strings.NewReader(""), &stdout, &stderr); err != nil {
+		t.Errorf("Expected --help to succeed, got %v", err)
+	}
+	if !strings.Contains(stderr.String(), "-max-tokens") {
+		t.Errorf("Expected the flags of complete, got %q", stderr.String())
+	}
+}
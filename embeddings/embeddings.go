@@ -0,0 +1,72 @@
+// Package embeddings provides vector utilities for working with the output of the embedding API,
+// such as normalizing vectors from different providers and reducing their dimensions.
+package embeddings
+
+import (
+	"fmt"
+	"math"
+)
+
+// Normalize returns v scaled to unit length (L2 norm), so the dot product of normalized vectors
+// is their cosine similarity. A zero vector is returned as a zero vector. v is not modified.
+func Normalize(v []float32) []float32 {
+	result := make([]float32, len(v))
+	norm := math.Sqrt(dot(v, v))
+	if norm == 0 {
+		return result
+	}
+	for i, x := range v {
+		result[i] = float32(float64(x) / norm)
+	}
+	return result
+}
+
+// CosineSimilarity returns the cosine of the angle between a and b, from -1 to 1. It returns 0
+// if either vector is zero. It panics if the vectors have different dimensions.
+func CosineSimilarity(a, b []float32) float32 {
+	if len(a) != len(b) {
+		panic(fmt.Sprintf("embeddings: vectors have different dimensions %d and %d", len(a), len(b)))
+	}
+	norms := math.Sqrt(dot(a, a) * dot(b, b))
+	if norms == 0 {
+		return 0
+	}
+	return float32(dot(a, b) / norms)
+}
+
+// MeanPool returns the element-wise mean of vecs, e.g. to embed a document from the embeddings
+// of its chunks. It returns nil for no vectors and panics if the vectors have different dimensions.
+func MeanPool(vecs [][]float32) []float32 {
+	if len(vecs) == 0 {
+		return nil
+	}
+	sum := make([]float64, len(vecs[0]))
+	for _, v := range vecs {
+		if len(v) != len(sum) {
+			panic(fmt.Sprintf("embeddings: vectors have different dimensions %d and %d", len(sum), len(v)))
+		}
+		for i, x := range v {
+			sum[i] += float64(x)
+		}
+	}
+	result := make([]float32, len(sum))
+	for i, s := range sum {
+		result[i] = float32(s / float64(len(vecs)))
+	}
+	return result
+}
+
+// dot returns the dot product of a and b, accumulated in float64
+func dot(a, b []float32) float64 {
+	b = b[:len(a)]
+	var s0, s1 float64
+	i := 0
+	for ; i+2 <= len(a); i += 2 {
+		s0 += float64(a[i]) * float64(b[i])
+		s1 += float64(a[i+1]) * float64(b[i+1])
+	}
+	if i < len(a) {
+		s0 += float64(a[i]) * float64(b[i])
+	}
+	return s0 + s1
+}
@@ -0,0 +1,257 @@
+package embeddings
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// approxEqual reports whether a and b agree to within tolerance
+func approxEqual(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		v    []float32
+		want []float32
+	}{
+		{"Axis", []float32{3, 0}, []float32{1, 0}},
+		{"Pythagorean", []float32{3, 4}, []float32{0.6, 0.8}},
+		{"Negative", []float32{0, -2, 0}, []float32{0, -1, 0}},
+		{"Zero", []float32{0, 0, 0}, []float32{0, 0, 0}},
+		{"Empty", []float32{}, []float32{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := append([]float32(nil), tt.v...)
+			got := Normalize(tt.v)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected %v, got %v", tt.want, got)
+			}
+			for i := range got {
+				if !approxEqual(float64(got[i]), float64(tt.want[i]), 1e-6) {
+					t.Errorf("Expected %v, got %v", tt.want, got)
+					break
+				}
+			}
+			for i := range original {
+				if tt.v[i] != original[i] {
+					t.Errorf("Normalize modified its input: %v", tt.v)
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizePreservesCosineSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []float32
+		want float64
+	}{
+		{"Identical", []float32{1, 2, 3}, []float32{1, 2, 3}, 1},
+		{"Scaled", []float32{1, 2, 3}, []float32{10, 20, 30}, 1},
+		{"Orthogonal", []float32{1, 0}, []float32{0, 5}, 0},
+		{"Opposite", []float32{1, -2}, []float32{-3, 6}, -1},
+		{"Angled", []float32{1, 0}, []float32{1, 1}, 1 / math.Sqrt2},
+		{"Zero", []float32{0, 0}, []float32{1, 1}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := float64(CosineSimilarity(tt.a, tt.b))
+			na, nb := Normalize(tt.a), Normalize(tt.b)
+			after := float64(CosineSimilarity(na, nb))
+			if !approxEqual(before, tt.want, 1e-6) || !approxEqual(after, tt.want, 1e-6) {
+				t.Errorf("Expected cosine similarity %v, got %v before and %v after normalizing", tt.want, before, after)
+			}
+			// For normalized vectors the dot product is the cosine similarity
+			if got := dot(na, nb); !approxEqual(got, tt.want, 1e-6) {
+				t.Errorf("Expected the dot product of normalized vectors to be %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	// Provider embeddings of different norms rank the same once normalized
+	rng := rand.New(rand.NewSource(7))
+	query := randomVector(rng, 64)
+	for i := 0; i < 100; i++ {
+		doc := randomVector(rng, 64)
+		for k := range doc {
+			doc[k] *= float32(1 + i)
+		}
+		before, after := CosineSimilarity(query, doc), dot(Normalize(query), Normalize(doc))
+		if !approxEqual(float64(before), after, 1e-5) {
+			t.Fatalf("Cosine similarity changed from %v to %v after normalizing", before, after)
+		}
+	}
+}
+
+func TestMeanPool(t *testing.T) {
+	got := MeanPool([][]float32{{1, 2, 3}, {3, 4, 5}, {2, 0, 1}})
+	want := []float32{2, 2, 3}
+	for i := range want {
+		if !approxEqual(float64(got[i]), float64(want[i]), 1e-6) {
+			t.Fatalf("Expected %v, got %v", want, got)
+		}
+	}
+	if MeanPool(nil) != nil {
+		t.Error("Expected nil for no vectors")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic for vectors of different dimensions")
+		}
+	}()
+	MeanPool([][]float32{{1, 2}, {1}})
+}
+
+func TestPCA(t *testing.T) {
+	// Points on a plane in 10 dimensions, offset from the origin
+	rng := rand.New(rand.NewSource(3))
+	u, w, offset := randomVector(rng, 10), randomVector(rng, 10), randomVector(rng, 10)
+	u, w = Normalize(u), Normalize(w)
+	point := func(a, b float64) []float32 {
+		p := make([]float32, 10)
+		for k := range p {
+			p[k] = offset[k] + float32(a)*u[k] + float32(b)*w[k]
+		}
+		return p
+	}
+	corpus := make([][]float32, 200)
+	for i := range corpus {
+		// Most of the variance lies along the first coordinate
+		corpus[i] = point(rng.NormFloat64()*5, rng.NormFloat64())
+	}
+
+	reduced, transform, err := PCA(corpus, 2)
+	if err != nil {
+		t.Fatalf("PCA failed: %v", err)
+	}
+	if len(reduced) != len(corpus) || len(reduced[0]) != 2 {
+		t.Fatalf("Expected %d vectors of dimension 2, got %d of %d", len(corpus), len(reduced), len(reduced[0]))
+	}
+
+	// The components are orthonormal and sorted by variance
+	c := transform.Components
+	if !approxEqual(dot(c[0], c[0]), 1, 1e-5) || !approxEqual(dot(c[1], c[1]), 1, 1e-5) || !approxEqual(dot(c[0], c[1]), 0, 1e-5) {
+		t.Errorf("Expected orthonormal components, got %v", c)
+	}
+	if transform.Variance[0] < transform.Variance[1] {
+		t.Errorf("Expected decreasing variance, got %v", transform.Variance)
+	}
+	if !approxEqual(math.Abs(dot(c[0], u)), 1, 0.05) {
+		t.Errorf("Expected the first component along the direction of most variance, got cosine %v", dot(c[0], u))
+	}
+
+	// The plane is kept exactly, so distances between points are preserved, including for
+	// new points projected with the transform
+	query := point(1.5, -2)
+	projected := transform.Apply(query)
+	for i := 0; i < 20; i++ {
+		original := distance(query, corpus[i])
+		if got := distance(projected, reduced[i]); !approxEqual(got, original, 1e-3*(1+original)) {
+			t.Fatalf("Expected distance %v after projection, got %v", original, got)
+		}
+	}
+}
+
+func TestPCAErrors(t *testing.T) {
+	corpus := [][]float32{{1, 2, 3}, {4, 5, 6}}
+	tests := []struct {
+		name   string
+		corpus [][]float32
+		dims   int
+	}{
+		{"Empty", nil, 1},
+		{"ZeroDims", corpus, 0},
+		{"MoreDimsThanVectors", corpus, 3},
+		{"MoreDimsThanDimensions", [][]float32{{1}, {2}, {3}}, 2},
+		{"MixedDimensions", [][]float32{{1, 2}, {1}}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := PCA(tt.corpus, tt.dims); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+// randomVector returns a vector of normally distributed values
+func randomVector(rng *rand.Rand, dims int) []float32 {
+	v := make([]float32, dims)
+	for k := range v {
+		v[k] = float32(rng.NormFloat64())
+	}
+	return v
+}
+
+// distance returns the Euclidean distance between a and b
+func distance(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		d := float64(a[i]) - float64(b[i])
+		sum += d * d
+	}
+	return math.Sqrt(sum)
+}
+
+// benchmarkCorpus returns 10,000 random vectors of 1536 dimensions, the size of OpenAI's
+// text-embedding-3-small output
+func benchmarkCorpus() [][]float32 {
+	rng := rand.New(rand.NewSource(1))
+	corpus := make([][]float32, 10000)
+	for i := range corpus {
+		corpus[i] = randomVector(rng, 1536)
+	}
+	return corpus
+}
+
+func BenchmarkNormalize(b *testing.B) {
+	corpus := benchmarkCorpus()
+	b.SetBytes(int64(len(corpus) * len(corpus[0]) * 4))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, v := range corpus {
+			Normalize(v)
+		}
+	}
+}
+
+func BenchmarkMeanPool(b *testing.B) {
+	corpus := benchmarkCorpus()
+	b.SetBytes(int64(len(corpus) * len(corpus[0]) * 4))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		MeanPool(corpus)
+	}
+}
+
+func BenchmarkPCA(b *testing.B) {
+	corpus := benchmarkCorpus()
+	b.SetBytes(int64(len(corpus) * len(corpus[0]) * 4))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := PCA(corpus, 64); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPCAApply(b *testing.B) {
+	corpus := benchmarkCorpus()
+	_, transform, err := PCA(corpus[:1000], 64)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(corpus) * len(corpus[0]) * 4))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, v := range corpus {
+			transform.Apply(v)
+		}
+	}
+}
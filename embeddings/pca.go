@@ -0,0 +1,323 @@
+package embeddings
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// PCATransform projects vectors onto the principal components of the corpus it was fitted to
+type PCATransform struct {
+	// Mean is the mean of the corpus, subtracted from vectors before they are projected
+	Mean []float32
+	// Components are the principal components as unit vectors, in order of decreasing variance
+	Components [][]float32
+	// Variance is the variance of the corpus along each component
+	Variance []float64
+}
+
+// Apply projects v onto the components, reducing it to len(t.Components) dimensions.
+// It panics if v doesn't have the dimension of the corpus.
+func (t *PCATransform) Apply(v []float32) []float32 {
+	if len(v) != len(t.Mean) {
+		panic(fmt.Sprintf("embeddings: vector has dimension %d, the transform expects %d", len(v), len(t.Mean)))
+	}
+	centered := make([]float32, len(v))
+	for j, x := range v {
+		centered[j] = x - t.Mean[j]
+	}
+	result := make([]float32, len(t.Components))
+	for i, component := range t.Components {
+		result[i] = float32(dot(centered, component))
+	}
+	return result
+}
+
+// Parameters of the randomized SVD used by PCA
+const (
+	pcaOversampling    = 10 // Extra directions sampled beyond dims, for accuracy
+	pcaPowerIterations = 1  // Power iterations, which sharpen the separation of the components
+	pcaSeed            = 1  // Seed of the random projection, so results are reproducible
+)
+
+// PCA reduces corpus to dims dimensions by principal component analysis. It returns the reduced
+// vectors and the transform, which projects new vectors, such as queries, into the same space.
+//
+// The components are found with a randomized SVD of the centered corpus, which only needs a few
+// passes over the vectors and so scales to large corpora of high-dimensional embeddings. The
+// leading components are accurate; components beyond the rank of the corpus are zero vectors.
+func PCA(corpus [][]float32, dims int) ([][]float32, *PCATransform, error) {
+	if len(corpus) == 0 {
+		return nil, nil, errors.New("embeddings: empty corpus")
+	}
+	n, d := len(corpus), len(corpus[0])
+	for i, v := range corpus {
+		if len(v) != d {
+			return nil, nil, fmt.Errorf("embeddings: vector %d has dimension %d, expected %d", i, len(v), d)
+		}
+	}
+	if dims < 1 || dims > d || dims > n {
+		return nil, nil, fmt.Errorf("embeddings: dims must be between 1 and %d, got %d", min(n, d), dims)
+	}
+
+	m := newCenteredMatrix(corpus)
+	l := min(dims+pcaOversampling, min(n, d))
+
+	// Sample the range of the corpus with random directions, refined by power iterations
+	rng := rand.New(rand.NewSource(pcaSeed))
+	omega := make([][]float64, l)
+	for j := range omega {
+		omega[j] = make([]float64, d)
+		for k := range omega[j] {
+			omega[j][k] = rng.NormFloat64()
+		}
+	}
+	q := m.mul(omega)
+	orthonormalize(q)
+	for i := 0; i < pcaPowerIterations; i++ {
+		z := m.mulT(q)
+		orthonormalize(z)
+		q = m.mul(z)
+		orthonormalize(q)
+	}
+
+	// The rows of B = QᵀX span the leading components. Its right singular vectors, from the
+	// eigenvectors of the small matrix BBᵀ, are the principal components.
+	b := m.mulT(q)
+	gram := make([][]float64, l)
+	for i := range gram {
+		gram[i] = make([]float64, l)
+		for j := 0; j <= i; j++ {
+			gram[i][j] = dot64(b[i], b[j])
+			gram[j][i] = gram[i][j]
+		}
+	}
+	values, vectors := symmetricEigen(gram)
+
+	t := &PCATransform{
+		Mean:       make([]float32, d),
+		Components: make([][]float32, dims),
+		Variance:   make([]float64, dims),
+	}
+	for k, mean := range m.mean {
+		t.Mean[k] = float32(mean)
+	}
+	for c := 0; c < dims; c++ {
+		t.Components[c] = make([]float32, d)
+		sigma := math.Sqrt(math.Max(values[c], 0))
+		if sigma <= 1e-9*math.Sqrt(math.Max(values[0], 0)) {
+			continue
+		}
+		for k := 0; k < d; k++ {
+			var sum float64
+			for i := range b {
+				sum += vectors[c][i] * b[i][k]
+			}
+			t.Components[c][k] = float32(sum / sigma)
+		}
+		if n > 1 {
+			t.Variance[c] = values[c] / float64(n-1)
+		}
+	}
+
+	reduced := make([][]float32, n)
+	for i, v := range corpus {
+		reduced[i] = t.Apply(v)
+	}
+	return reduced, t, nil
+}
+
+// centeredMatrix is the corpus with its mean subtracted, computed on the fly so the corpus
+// isn't copied
+type centeredMatrix struct {
+	rows [][]float32
+	mean []float64
+}
+
+// newCenteredMatrix computes the mean of rows
+func newCenteredMatrix(rows [][]float32) *centeredMatrix {
+	mean := make([]float64, len(rows[0]))
+	for _, row := range rows {
+		for k, x := range row {
+			mean[k] += float64(x)
+		}
+	}
+	for k := range mean {
+		mean[k] /= float64(len(rows))
+	}
+	return &centeredMatrix{rows: rows, mean: mean}
+}
+
+// mul returns X·c for each vector c of cols, which have the dimension of the rows
+func (m *centeredMatrix) mul(cols [][]float64) [][]float64 {
+	result := make([][]float64, len(cols))
+	meanDots := make([]float64, len(cols))
+	for j, col := range cols {
+		result[j] = make([]float64, len(m.rows))
+		meanDots[j] = dot64(m.mean, col)
+	}
+	row64 := make([]float64, len(m.mean))
+	for i, row := range m.rows {
+		for k, x := range row {
+			row64[k] = float64(x)
+		}
+		for j, col := range cols {
+			result[j][i] = dot64(row64, col) - meanDots[j]
+		}
+	}
+	return result
+}
+
+// mulT returns Xᵀ·c for each vector c of cols, which have one element per row
+func (m *centeredMatrix) mulT(cols [][]float64) [][]float64 {
+	result := make([][]float64, len(cols))
+	for j := range cols {
+		result[j] = make([]float64, len(m.mean))
+	}
+	row64 := make([]float64, len(m.mean))
+	for i, row := range m.rows {
+		for k, x := range row {
+			row64[k] = float64(x)
+		}
+		for j, col := range cols {
+			if w := col[i]; w != 0 {
+				axpy(result[j], w, row64)
+			}
+		}
+	}
+	for j, col := range cols {
+		var weight float64
+		for _, w := range col {
+			weight += w
+		}
+		for k, mean := range m.mean {
+			result[j][k] -= weight * mean
+		}
+	}
+	return result
+}
+
+// orthonormalize makes cols orthonormal in place by modified Gram-Schmidt, applied twice for
+// numerical stability. Vectors that are linearly dependent on earlier ones become zero.
+func orthonormalize(cols [][]float64) {
+	for j, col := range cols {
+		original := math.Sqrt(dot64(col, col))
+		for pass := 0; pass < 2; pass++ {
+			for _, prev := range cols[:j] {
+				proj := dot64(prev, col)
+				for k := range col {
+					col[k] -= proj * prev[k]
+				}
+			}
+		}
+		norm := math.Sqrt(dot64(col, col))
+		if norm <= 1e-10*original || norm == 0 {
+			for k := range col {
+				col[k] = 0
+			}
+			continue
+		}
+		for k := range col {
+			col[k] /= norm
+		}
+	}
+}
+
+// symmetricEigen returns the eigenvalues of the symmetric matrix a in decreasing order, and the
+// matching unit eigenvectors, using the cyclic Jacobi method. a is modified.
+func symmetricEigen(a [][]float64) ([]float64, [][]float64) {
+	n := len(a)
+	v := make([][]float64, n)
+	for i := range v {
+		v[i] = make([]float64, n)
+		v[i][i] = 1
+	}
+
+	for sweep := 0; sweep < 100; sweep++ {
+		var off, total float64
+		for i := range a {
+			for j := range a[i] {
+				total += a[i][j] * a[i][j]
+				if i != j {
+					off += a[i][j] * a[i][j]
+				}
+			}
+		}
+		if off <= 1e-24*total {
+			break
+		}
+
+		for p := 0; p < n-1; p++ {
+			for q := p + 1; q < n; q++ {
+				if a[p][q] == 0 {
+					continue
+				}
+				// Rotate rows and columns p and q to zero a[p][q]
+				theta := (a[q][q] - a[p][p]) / (2 * a[p][q])
+				t := 1 / (math.Abs(theta) + math.Sqrt(theta*theta+1))
+				if theta < 0 {
+					t = -t
+				}
+				c := 1 / math.Sqrt(t*t+1)
+				s := t * c
+				for k := 0; k < n; k++ {
+					akp, akq := a[k][p], a[k][q]
+					a[k][p], a[k][q] = c*akp-s*akq, s*akp+c*akq
+				}
+				for k := 0; k < n; k++ {
+					apk, aqk := a[p][k], a[q][k]
+					a[p][k], a[q][k] = c*apk-s*aqk, s*apk+c*aqk
+				}
+				for k := 0; k < n; k++ {
+					vkp, vkq := v[k][p], v[k][q]
+					v[k][p], v[k][q] = c*vkp-s*vkq, s*vkp+c*vkq
+				}
+			}
+		}
+	}
+
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return a[order[i]][order[i]] > a[order[j]][order[j]] })
+
+	values := make([]float64, n)
+	vectors := make([][]float64, n)
+	for i, idx := range order {
+		values[i] = a[idx][idx]
+		vectors[i] = make([]float64, n)
+		for k := 0; k < n; k++ {
+			vectors[i][k] = v[k][idx]
+		}
+	}
+	return values, vectors
+}
+
+// dot64 returns the dot product of a and b. The loop is unrolled with independent sums, which
+// roughly doubles its speed on long vectors.
+func dot64(a, b []float64) float64 {
+	b = b[:len(a)]
+	var s0, s1, s2, s3 float64
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		s0 += a[i] * b[i]
+		s1 += a[i+1] * b[i+1]
+		s2 += a[i+2] * b[i+2]
+		s3 += a[i+3] * b[i+3]
+	}
+	for ; i < len(a); i++ {
+		s0 += a[i] * b[i]
+	}
+	return s0 + s1 + s2 + s3
+}
+
+// axpy adds w·x to y
+func axpy(y []float64, w float64, x []float64) {
+	x = x[:len(y)]
+	for i := range y {
+		y[i] += w * x[i]
+	}
+}
@@ -0,0 +1,109 @@
+package embeddings
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// QuantizeInt8 quantizes v symmetrically to int8, returning the per-vector scale such that
+// v[i] ≈ float32(q[i]) * scale. The scale is the largest absolute component divided by 127, so
+// each component is off by at most half the scale, plus float32 rounding, and zero stays zero. Storage is a quarter of
+// float32. A zero vector has a scale of zero.
+func QuantizeInt8(v []float32) ([]int8, float32) {
+	q := make([]int8, len(v))
+	var maxAbs float64
+	for _, x := range v {
+		maxAbs = math.Max(maxAbs, math.Abs(float64(x)))
+	}
+	if maxAbs == 0 {
+		return q, 0
+	}
+	scale := maxAbs / 127
+	for i, x := range v {
+		q[i] = int8(math.Round(float64(x) / scale))
+	}
+	return q, float32(scale)
+}
+
+// DequantizeInt8 returns the float32 vector approximated by q and scale
+func DequantizeInt8(q []int8, scale float32) []float32 {
+	v := make([]float32, len(q))
+	for i, x := range q {
+		v[i] = float32(x) * scale
+	}
+	return v
+}
+
+// CosineSimilarityInt8 returns the cosine similarity of two int8 quantized vectors. Scales don't
+// affect the angle, so they aren't needed. The result differs from the cosine of the original
+// vectors by about the quantization error relative to the vectors' magnitudes: below 0.01 for
+// typical embeddings of a few hundred dimensions. It panics if the vectors have different dimensions.
+func CosineSimilarityInt8(a, b []int8) float32 {
+	if len(a) != len(b) {
+		panic(fmt.Sprintf("embeddings: vectors have different dimensions %d and %d", len(a), len(b)))
+	}
+	var ab, aa, bb int64
+	for i := range a {
+		x, y := int64(a[i]), int64(b[i])
+		ab += x * y
+		aa += x * x
+		bb += y * y
+	}
+	if aa == 0 || bb == 0 {
+		return 0
+	}
+	return float32(float64(ab) / math.Sqrt(float64(aa)*float64(bb)))
+}
+
+// DotInt8 returns the dot product of the vectors approximated by two int8 quantized vectors and
+// their scales. It panics if the vectors have different dimensions.
+func DotInt8(a []int8, scaleA float32, b []int8, scaleB float32) float32 {
+	if len(a) != len(b) {
+		panic(fmt.Sprintf("embeddings: vectors have different dimensions %d and %d", len(a), len(b)))
+	}
+	var sum int64
+	for i := range a {
+		sum += int64(a[i]) * int64(b[i])
+	}
+	return float32(float64(sum) * float64(scaleA) * float64(scaleB))
+}
+
+// QuantizeBinary keeps the sign of each component of v as one bit, set for positive components,
+// packed eight to a byte with the first component in the most significant bit. Storage is 1/32
+// of float32. Binary vectors only support a coarse first pass, such as shortlisting candidates
+// to rescore with the full vectors.
+func QuantizeBinary(v []float32) []byte {
+	b := make([]byte, (len(v)+7)/8)
+	for i, x := range v {
+		if x > 0 {
+			b[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return b
+}
+
+// HammingDistance returns the number of bits that differ between two binary quantized vectors.
+// It panics if the vectors have different lengths.
+func HammingDistance(a, b []byte) int {
+	if len(a) != len(b) {
+		panic(fmt.Sprintf("embeddings: binary vectors have different lengths %d and %d", len(a), len(b)))
+	}
+	distance := 0
+	for i := range a {
+		distance += bits.OnesCount8(a[i] ^ b[i])
+	}
+	return distance
+}
+
+// BinarySimilarity estimates the cosine similarity of the vectors of dims dimensions behind two
+// binary quantized vectors as cos(π × differing bits / dims), from -1 to 1. The estimate is
+// close for vectors whose components are centred on zero, as with most embedding models, and
+// its error shrinks with the square root of dims. Rank with it, but don't compare it to
+// thresholds set for float vectors.
+func BinarySimilarity(a, b []byte, dims int) float32 {
+	if dims <= 0 {
+		return 0
+	}
+	return float32(math.Cos(math.Pi * float64(HammingDistance(a, b)) / float64(dims)))
+}
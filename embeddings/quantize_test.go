@@ -0,0 +1,154 @@
+package embeddings
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestQuantizeInt8(t *testing.T) {
+	tests := []struct {
+		name      string
+		v         []float32
+		want      []int8
+		wantScale float32
+	}{
+		{"Symmetric", []float32{1.27, -1.27, 0, 0.635}, []int8{127, -127, 0, 64}, 0.01},
+		{"LargestNegative", []float32{-2.54, 1}, []int8{-127, 50}, 0.02},
+		{"Zero", []float32{0, 0}, []int8{0, 0}, 0},
+		{"Empty", []float32{}, []int8{}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, scale := QuantizeInt8(tt.v)
+			if !approxEqual(float64(scale), float64(tt.wantScale), 1e-6) {
+				t.Errorf("Expected scale %v, got %v", tt.wantScale, scale)
+			}
+			if len(q) != len(tt.want) {
+				t.Fatalf("Expected %v, got %v", tt.want, q)
+			}
+			for i := range q {
+				if q[i] != tt.want[i] {
+					t.Errorf("Expected %v, got %v", tt.want, q)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestQuantizeInt8ErrorBound(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for n := 0; n < 100; n++ {
+		v := randomVector(rng, 384)
+		q, scale := QuantizeInt8(v)
+		restored := DequantizeInt8(q, scale)
+		for i := range v {
+			if err := math.Abs(float64(v[i] - restored[i])); err > float64(scale)*(0.5+1e-5) { // Half the scale, plus float32 rounding
+				t.Fatalf("Component %d is off by %v, more than half the scale %v", i, err, scale)
+			}
+		}
+		if !approxEqual(float64(DotInt8(q, scale, q, scale)), dot(v, v), 0.01*dot(v, v)) {
+			t.Errorf("Expected DotInt8 to approximate the squared norm %v, got %v", dot(v, v), DotInt8(q, scale, q, scale))
+		}
+	}
+}
+
+func TestQuantizeBinary(t *testing.T) {
+	v := []float32{0.5, -0.1, 0, 2, -3, 0.1, 0.2, -0.2, 1}
+	got := QuantizeBinary(v)
+	want := []byte{0b10010110, 0b10000000}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Expected %08b, got %08b", want, got)
+	}
+
+	if d := HammingDistance(got, QuantizeBinary([]float32{-0.5, -0.1, 0, 2, -3, 0.1, 0.2, -0.2, -1})); d != 2 {
+		t.Errorf("Expected a Hamming distance of 2, got %d", d)
+	}
+	if s := BinarySimilarity(got, got, len(v)); s != 1 {
+		t.Errorf("Expected identical vectors to have a similarity of 1, got %v", s)
+	}
+	negated := QuantizeBinary([]float32{-1, 1, 1, -1, 1, -1, -1, 1, -1})
+	if s := BinarySimilarity(got, negated, len(v)); s != -1 {
+		t.Errorf("Expected opposite vectors to have a similarity of -1, got %v", s)
+	}
+}
+
+func TestCosineSimilarityInt8(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for n := 0; n < 100; n++ {
+		a, b := randomVector(rng, 384), randomVector(rng, 384)
+		qa, _ := QuantizeInt8(a)
+		qb, _ := QuantizeInt8(b)
+		if got, want := CosineSimilarityInt8(qa, qb), CosineSimilarity(a, b); !approxEqual(float64(got), float64(want), 0.01) {
+			t.Errorf("Expected a cosine similarity of about %v, got %v", want, got)
+		}
+	}
+	if s := CosineSimilarityInt8([]int8{0, 0}, []int8{1, 1}); s != 0 {
+		t.Errorf("Expected 0 for a zero vector, got %v", s)
+	}
+}
+
+// rankingFixture returns a query and documents of dims dimensions whose cosine similarity to the
+// query decreases with their index, by mixing the query with growing amounts of noise
+func rankingFixture(rng *rand.Rand, documents, dims int) ([]float32, [][]float32) {
+	query := randomVector(rng, dims)
+	docs := make([][]float32, documents)
+	for i := range docs {
+		noise := randomVector(rng, dims)
+		weight := float32(i) / float32(documents) * 2
+		doc := make([]float32, dims)
+		for j := range doc {
+			doc[j] = query[j] + weight*noise[j]
+		}
+		docs[i] = doc
+	}
+	return query, docs
+}
+
+// rank returns the indices of documents ordered by descending score
+func rank(documents int, score func(i int) float32) []int {
+	indices := make([]int, documents)
+	scores := make([]float32, documents)
+	for i := range indices {
+		indices[i], scores[i] = i, score(i)
+	}
+	sort.SliceStable(indices, func(i, j int) bool { return scores[indices[i]] > scores[indices[j]] })
+	return indices
+}
+
+func TestQuantizedRankings(t *testing.T) {
+	const documents, dims = 40, 768
+	rng := rand.New(rand.NewSource(3))
+	query, docs := rankingFixture(rng, documents, dims)
+
+	want := rank(documents, func(i int) float32 { return CosineSimilarity(query, docs[i]) })
+
+	qQuery, _ := QuantizeInt8(query)
+	int8Ranking := rank(documents, func(i int) float32 {
+		qDoc, _ := QuantizeInt8(docs[i])
+		return CosineSimilarityInt8(qQuery, qDoc)
+	})
+	for i := range want {
+		if int8Ranking[i] != want[i] {
+			t.Errorf("Expected int8 quantization to keep the ranking %v, got %v", want, int8Ranking)
+			break
+		}
+	}
+
+	bQuery := QuantizeBinary(query)
+	binaryRanking := rank(documents, func(i int) float32 {
+		return BinarySimilarity(bQuery, QuantizeBinary(docs[i]), dims)
+	})
+	// Binary vectors shortlist: the top 5 by float similarity are within the binary top 10
+	shortlist := make(map[int]bool)
+	for _, i := range binaryRanking[:10] {
+		shortlist[i] = true
+	}
+	for _, i := range want[:5] {
+		if !shortlist[i] {
+			t.Errorf("Expected document %d to be in the binary top 10 %v", i, binaryRanking[:10])
+		}
+	}
+}
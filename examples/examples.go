@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"strings"
 	"time"
 
 	"github.com/1broseidon/gollm/client"
@@ -25,7 +24,7 @@ func main() {
 
 	switch os.Args[1] {
 	case "logging":
-		c, err := client.NewClient(ctx, client.WithLogLevel(common.InfoLevel))
+		c, err := newClient(ctx, client.WithLogLevel(common.InfoLevel))
 		if err != nil {
 			log.Fatalf("Failed to create gollm client: %v", err)
 		}
@@ -36,36 +35,43 @@ func main() {
 		anthropicExample(ctx, c)
 		ollamaExample(ctx, c)
 	case "openai":
-		c, err := client.NewClient(ctx, client.WithDefaultProvider("openai"), client.WithLogLevel(common.InfoLevel))
+		c, err := newClient(ctx, client.WithDefaultProvider("openai"), client.WithLogLevel(common.InfoLevel))
 		if err != nil {
 			log.Fatalf("Failed to create gollm client: %v", err)
 		}
 		defer c.Close()
 		openAIExample(ctx, c)
 	case "gemini":
-		c, err := client.NewClient(ctx, client.WithDefaultProvider("googlegemini"), client.WithLogLevel(common.InfoLevel))
+		c, err := newClient(ctx, client.WithDefaultProvider("googlegemini"), client.WithLogLevel(common.InfoLevel))
 		if err != nil {
 			log.Fatalf("Failed to create gollm client: %v", err)
 		}
 		defer c.Close()
 		geminiExample(ctx, c)
 	case "anthropic":
-		c, err := client.NewClient(ctx, client.WithDefaultProvider("anthropic"), client.WithLogLevel(common.InfoLevel))
+		c, err := newClient(ctx, client.WithDefaultProvider("anthropic"), client.WithLogLevel(common.InfoLevel))
 		if err != nil {
 			log.Fatalf("Failed to create gollm client: %v", err)
 		}
 		defer c.Close()
 		fmt.Println("Client created successfully")
 		anthropicExample(ctx, c)
+	case "text":
+		c, err := newClient(ctx, client.WithLogLevel(common.InfoLevel))
+		if err != nil {
+			log.Fatalf("Failed to create gollm client: %v", err)
+		}
+		defer c.Close()
+		generateTextExample(ctx, c)
 	case "ollama":
-		c, err := client.NewClient(ctx, client.WithDefaultProvider("ollama"), client.WithLogLevel(common.InfoLevel))
+		c, err := newClient(ctx, client.WithDefaultProvider("ollama"), client.WithLogLevel(common.InfoLevel))
 		if err != nil {
 			log.Fatalf("Failed to create gollm client: %v", err)
 		}
 		defer c.Close()
 		ollamaExample(ctx, c)
 	case "all":
-		c, err := client.NewClient(ctx, client.WithLogLevel(common.InfoLevel))
+		c, err := newClient(ctx, client.WithLogLevel(common.InfoLevel))
 		if err != nil {
 			log.Fatalf("Failed to create gollm client: %v", err)
 		}
@@ -75,10 +81,16 @@ func main() {
 		anthropicExample(ctx, c)
 		ollamaExample(ctx, c)
 	default:
-		fmt.Println("Invalid provider. Available options: logging, openai, gemini, anthropic, ollama, all")
+		fmt.Println("Invalid provider. Available options: logging, openai, gemini, anthropic, ollama, text, all")
 	}
 }
 
+// newClient creates a client with the sampling defaults shared by the examples
+func newClient(ctx context.Context, options ...client.ClientOption) (*client.Client, error) {
+	options = append([]client.ClientOption{client.WithDefaultMaxTokens(200), client.WithDefaultTemperature(0.7)}, options...)
+	return client.NewClient(ctx, options...)
+}
+
 func showAvailableModels() {
 	fmt.Println("Available options:")
 	fmt.Println("- logging (runs all examples with logging enabled)")
@@ -86,6 +98,7 @@ func showAvailableModels() {
 	fmt.Println("- gemini")
 	fmt.Println("- anthropic")
 	fmt.Println("- ollama")
+	fmt.Println("- text (a single prompt with GenerateText)")
 	fmt.Println("- all (runs all examples)")
 	fmt.Println("\nUsage: go run examples.go <option>")
 }
@@ -97,9 +110,7 @@ func openAIExample(ctx context.Context, c *client.Client) {
 		Messages: []models.ChatMessage{
 			{Role: "user", Content: "Tell me a short story about a robot and a human. Max 50 words."},
 		},
-		MaxTokens:   200,
-		Temperature: 0.7,
-		Stream:      true,
+		Stream: true,
 	}
 	fmt.Println("Calling GenerateCompletionStream")
 	streamChan, err := c.GenerateCompletionStream(ctx, openAIInput)
@@ -170,8 +181,6 @@ func geminiExample(ctx context.Context, c *client.Client) {
 		Messages: []models.ChatMessage{
 			{Role: "user", Content: "Briefly explain the concept of machine learning. Max 50 words."},
 		},
-		MaxTokens:   200,
-		Temperature: 0.7,
 	}
 
 	geminiInput.Stream = true
@@ -182,35 +191,26 @@ func geminiExample(ctx context.Context, c *client.Client) {
 	}
 
 	fmt.Println("\nGoogle Gemini Response:")
-	var geminiResponseText string
-	var geminiUsage *models.Usage
-
+	var acc models.StreamAccumulator
 	for chunk := range streamChan {
-		if chunk.Error != nil {
-			log.Printf("Error in streaming: %v", chunk.Error)
-			return
-		}
-		fmt.Print(chunk.Text)
-		geminiResponseText += chunk.Text
-		if chunk.Usage != nil {
-			geminiUsage = chunk.Usage
-		}
-		if chunk.Done {
-			break
-		}
+		fmt.Print(acc.Add(chunk))
+	}
+	if err := acc.Err(); err != nil {
+		log.Printf("Error in streaming: %v", err)
+		return
 	}
+	_, geminiUsage, _ := acc.Result()
 
 	if geminiUsage != nil {
-		fmt.Printf("\n\nToken Usage:\nInput Tokens: %d\nOutput Tokens: %d\nTotal Tokens: %d\n",
-			geminiUsage.PromptTokens, geminiUsage.CompletionTokens, geminiUsage.TotalTokens)
+		// Gemini streams report no usage, so the client estimates it
+		label := "Token Usage"
+		if geminiUsage.Estimated {
+			label = "Approximate Token Usage"
+		}
+		fmt.Printf("\n\n%s:\nInput Tokens: %d\nOutput Tokens: %d\nTotal Tokens: %d\n",
+			label, geminiUsage.PromptTokens, geminiUsage.CompletionTokens, geminiUsage.TotalTokens)
 	} else {
-		// Calculate approximate token usage
-		inputTokens := len(strings.Split(geminiInput.Messages[0].Content, " "))
-		outputTokens := len(strings.Split(geminiResponseText, " "))
-		totalTokens := inputTokens + outputTokens
-
-		fmt.Printf("\n\nApproximate Token Usage:\nInput Tokens: %d\nOutput Tokens: %d\nTotal Tokens: %d\n",
-			inputTokens, outputTokens, totalTokens)
+		fmt.Println("Token Usage information is missing")
 	}
 }
 
@@ -227,8 +227,6 @@ func anthropicExample(ctx context.Context, c *client.Client) {
 		Messages: []models.ChatMessage{
 			{Role: "user", Content: "Explain the concept of quantum computing in simple terms. Max 50 words."},
 		},
-		MaxTokens:   200,
-		Temperature: 0.7,
 	}
 
 	fmt.Println("Calling GenerateCompletionStream")
@@ -317,8 +315,6 @@ func ollamaExample(ctx context.Context, c *client.Client) {
 		Messages: []models.ChatMessage{
 			{Role: "user", Content: "Explain the concept of quantum entanglement in simple terms. Max 50 words."},
 		},
-		MaxTokens:   200,
-		Temperature: 0.7,
 	}
 
 	ollamaInput.Stream = true
@@ -329,23 +325,15 @@ func ollamaExample(ctx context.Context, c *client.Client) {
 	}
 
 	fmt.Println("\nOllama Llama3.1 Response:")
-	var ollamaResponseText string
-	var ollamaUsage *models.Usage
-
+	var acc models.StreamAccumulator
 	for chunk := range streamChan {
-		if chunk.Error != nil {
-			log.Printf("Error in streaming: %v", chunk.Error)
-			return
-		}
-		fmt.Print(chunk.Text)
-		ollamaResponseText += chunk.Text
-		if chunk.Usage != nil {
-			ollamaUsage = chunk.Usage
-		}
-		if chunk.Done {
-			break
-		}
+		fmt.Print(acc.Add(chunk))
+	}
+	if err := acc.Err(); err != nil {
+		log.Printf("Error in streaming: %v", err)
+		return
 	}
+	_, ollamaUsage, _ := acc.Result()
 
 	if ollamaUsage != nil {
 		fmt.Printf("\n\nToken Usage:\nInput Tokens: %d\nOutput Tokens: %d\nTotal Tokens: %d\n",
@@ -354,3 +342,22 @@ func ollamaExample(ctx context.Context, c *client.Client) {
 		fmt.Println("Token Usage information is missing")
 	}
 }
+
+func generateTextExample(ctx context.Context, c *client.Client) {
+	if os.Getenv("OPENAI_API_KEY") == "" {
+		fmt.Println("\nSkipping GenerateText example: OPENAI_API_KEY not set")
+		return
+	}
+
+	resp, err := c.GenerateText(ctx, "openai/gpt-4o-mini", "Name three uses of a paperclip. Max 30 words.")
+	if err != nil {
+		log.Printf("Failed to generate text: %v", err)
+		return
+	}
+
+	fmt.Printf("\n%s Response:\n%s\n", resp.Model, resp.Text)
+	if resp.Usage != nil {
+		fmt.Printf("\nToken Usage:\nInput Tokens: %d\nOutput Tokens: %d\nTotal Tokens: %d\n",
+			resp.Usage.PromptTokens, resp.Usage.CompletionTokens, resp.Usage.TotalTokens)
+	}
+}
@@ -4,6 +4,7 @@ go 1.21
 
 require (
 	github.com/google/generative-ai-go v0.5.0
+	github.com/gorilla/websocket v1.5.3
 	google.golang.org/api v0.155.0
 )
 
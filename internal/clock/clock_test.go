@@ -0,0 +1,51 @@
+package clock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRealSleep(t *testing.T) {
+	start := Real.Now()
+	if err := Real.Sleep(context.Background(), 10*time.Millisecond); err != nil {
+		t.Fatalf("Sleep failed: %v", err)
+	}
+	if elapsed := Since(Real, start); elapsed < 10*time.Millisecond {
+		t.Errorf("Expected Sleep to wait at least 10ms, waited %v", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Real.Sleep(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestFake(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+
+	if err := f.Sleep(context.Background(), time.Second); err != nil {
+		t.Fatalf("Sleep failed: %v", err)
+	}
+	f.Advance(time.Minute)
+	f.Sleep(context.Background(), 2*time.Second)
+	if got := Since(f, start); got != time.Minute+3*time.Second {
+		t.Errorf("Expected 1m3s to have elapsed, got %v", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := f.Sleep(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	sleeps := f.Sleeps()
+	if len(sleeps) != 2 || sleeps[0] != time.Second || sleeps[1] != 2*time.Second {
+		t.Errorf("Expected the sleeps [1s 2s], got %v", sleeps)
+	}
+	if !f.Now().Equal(start.Add(time.Minute + 3*time.Second)) {
+		t.Errorf("Expected a cancelled Sleep not to advance the clock, got %v", f.Now())
+	}
+}
@@ -0,0 +1,161 @@
+// Package credentials reads provider credentials from the environment and from a credentials file.
+package credentials
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultPath returns the default credentials file, ~/.config/gollm/credentials, or "" if the
+// home directory is unknown
+func DefaultPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "gollm", "credentials")
+}
+
+// File holds the values of a credentials file, keyed by the lowercase name of the environment
+// variable they stand in for, e.g. "openai_api_key" for OPENAI_API_KEY
+type File map[string]string
+
+// Load reads a credentials file in JSON or TOML format. Only top-level string values are read.
+// A missing file yields an empty File and no error.
+func Load(path string) (File, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return File{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file File
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		file, err = parseJSON(trimmed)
+	} else {
+		file, err = parseTOML(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid credentials file %s: %w", path, err)
+	}
+	return file, nil
+}
+
+// Lookup returns the environment variable envVar if it is set, and otherwise its value in f
+func (f File) Lookup(envVar string) string {
+	if value := os.Getenv(envVar); value != "" {
+		return value
+	}
+	return f[strings.ToLower(envVar)]
+}
+
+// Lookup returns the environment variable envVar if it is set, and otherwise its value in the
+// default credentials file. An unreadable file is treated as empty.
+func Lookup(envVar string) string {
+	if value := os.Getenv(envVar); value != "" {
+		return value
+	}
+	path := DefaultPath()
+	if path == "" {
+		return ""
+	}
+	file, _ := Load(path)
+	return file.Lookup(envVar)
+}
+
+// parseJSON reads the string fields of a JSON object
+func parseJSON(data []byte) (File, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	file := File{}
+	for key, value := range fields {
+		if s, ok := value.(string); ok {
+			file[key] = s
+		}
+	}
+	return file, nil
+}
+
+// parseTOML reads the top-level string keys of a TOML document, such as
+//
+//	openai_api_key = "sk-..."
+//
+// Keys inside tables are ignored.
+func parseTOML(data []byte) (File, error) {
+	file := File{}
+	inTable := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "["):
+			inTable = true
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNumber)
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"`)
+		parsed, err := parseTOMLString(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		if !inTable {
+			file[key] = parsed
+		}
+	}
+	return file, scanner.Err()
+}
+
+// parseTOMLString parses a basic ("...") or literal ('...') string, followed by an optional comment
+func parseTOMLString(value string) (string, error) {
+	if value == "" {
+		return "", errors.New("missing value")
+	}
+	var s, rest string
+	switch value[0] {
+	case '"':
+		end := 1
+		for ; end < len(value) && value[end] != '"'; end++ {
+			if value[end] == '\\' {
+				end++
+			}
+		}
+		if end >= len(value) {
+			return "", errors.New("unterminated string")
+		}
+		unquoted, err := strconv.Unquote(value[:end+1])
+		if err != nil {
+			return "", err
+		}
+		s, rest = unquoted, value[end+1:]
+	case '\'':
+		end := strings.IndexByte(value[1:], '\'')
+		if end < 0 {
+			return "", errors.New("unterminated string")
+		}
+		s, rest = value[1:end+1], value[end+2:]
+	default:
+		return "", errors.New("only string values are supported")
+	}
+	if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", fmt.Errorf("unexpected %q after value", rest)
+	}
+	return s, nil
+}
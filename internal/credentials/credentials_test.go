@@ -0,0 +1,86 @@
+package credentials
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFile writes content to a file in a temporary directory and returns its path
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "credentials")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("OLLAMA_BASE_URL", "")
+	t.Setenv("ANTHROPIC_API_KEY", "")
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"JSON", `{"openai_api_key": "sk-json", "ollama_base_url": "http://localhost:11434", "timeout": 30}`},
+		{"TOML", `# gollm credentials
+openai_api_key = "sk-json"  # personal key
+ollama_base_url = 'http://localhost:11434'
+
+[profiles.work]
+openai_api_key = "sk-work"
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := Load(writeFile(t, tt.content))
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if got := file.Lookup("OPENAI_API_KEY"); got != "sk-json" {
+				t.Errorf("Expected sk-json, got %q", got)
+			}
+			if got := file.Lookup("OLLAMA_BASE_URL"); got != "http://localhost:11434" {
+				t.Errorf("Expected the Ollama URL, got %q", got)
+			}
+			if got := file.Lookup("ANTHROPIC_API_KEY"); got != "" {
+				t.Errorf("Expected no Anthropic key, got %q", got)
+			}
+		})
+	}
+
+	for _, content := range []string{`{"openai_api_key": `, `openai_api_key = sk-bare`, `openai_api_key = "open`, `just a line`} {
+		if _, err := Load(writeFile(t, content)); err == nil {
+			t.Errorf("Expected an error for %q", content)
+		}
+	}
+
+	file, err := Load(filepath.Join(t.TempDir(), "missing"))
+	if err != nil || len(file) != 0 {
+		t.Errorf("Expected an empty file for a missing path, got %v, %v", file, err)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.MkdirAll(filepath.Join(home, ".config", "gollm"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(DefaultPath(), []byte(`anthropic_api_key = "sk-ant-file"`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	if got := Lookup("ANTHROPIC_API_KEY"); got != "sk-ant-file" {
+		t.Errorf("Expected the key from the default file, got %q", got)
+	}
+
+	t.Setenv("ANTHROPIC_API_KEY", "sk-ant-env")
+	if got := Lookup("ANTHROPIC_API_KEY"); got != "sk-ant-env" {
+		t.Errorf("Expected the environment to take precedence, got %q", got)
+	}
+}
@@ -1,6 +1,7 @@
 package logging
 
 import (
+	"io"
 	"log"
 	"os"
 	"sync"
@@ -27,8 +28,17 @@ type defaultLogger struct {
 }
 
 func NewDefaultLogger() Logger {
+	return NewDefaultLoggerWithOptions(os.Stderr, log.LstdFlags, "")
+}
+
+// NewDefaultLoggerWithOptions returns the default logger writing to out, or os.Stderr if out is
+// nil, with the prefix and flags of the standard log package, such as log.Lmicroseconds
+func NewDefaultLoggerWithOptions(out io.Writer, flags int, prefix string) Logger {
+	if out == nil {
+		out = os.Stderr
+	}
 	return &defaultLogger{
-		logger: log.New(os.Stderr, "", log.LstdFlags),
+		logger: log.New(out, prefix, flags),
 		level:  common.DisabledLevel,
 	}
 }
@@ -71,3 +81,28 @@ func (l *defaultLogger) SetLevel(level common.LogLevel) {
 	defer l.mu.Unlock()
 	l.level = level
 }
+
+// Level returns the level set with SetLevel
+func (l *defaultLogger) Level() common.LogLevel {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.level
+}
+
+// nopLogger discards everything
+type nopLogger struct{}
+
+// NewNopLogger returns a logger that discards everything, whatever its level
+func NewNopLogger() Logger {
+	return nopLogger{}
+}
+
+func (nopLogger) Debug(args ...interface{})                 {}
+func (nopLogger) Debugf(format string, args ...interface{}) {}
+func (nopLogger) Info(args ...interface{})                  {}
+func (nopLogger) Infof(format string, args ...interface{})  {}
+func (nopLogger) Warn(args ...interface{})                  {}
+func (nopLogger) Warnf(format string, args ...interface{})  {}
+func (nopLogger) Error(args ...interface{})                 {}
+func (nopLogger) Errorf(format string, args ...interface{}) {}
+func (nopLogger) SetLevel(level common.LogLevel)            {}
@@ -0,0 +1,28 @@
+package logging
+
+import (
+	"bytes"
+	"log"
+	"regexp"
+	"testing"
+
+	"github.com/1broseidon/gollm/common"
+)
+
+func TestNewDefaultLoggerWithOptions(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewDefaultLoggerWithOptions(&buf, log.Ltime|log.Lmicroseconds|log.Lmsgprefix, "gollm ")
+	logger.SetLevel(common.WarnLevel)
+	logger.Info("dropped")
+	logger.Warnf("retrying in %ds", 2)
+	logger.Error("request failed")
+
+	want := regexp.MustCompile(`^\d\d:\d\d:\d\d\.\d{6} gollm WARN: retrying in 2s\n` +
+		`\d\d:\d\d:\d\d\.\d{6} gollm ERROR: request failed\n$`)
+	if !want.Match(buf.Bytes()) {
+		t.Errorf("Unexpected log output:\n%s", buf.String())
+	}
+	if level := logger.(interface{ Level() common.LogLevel }).Level(); level != common.WarnLevel {
+		t.Errorf("Expected the level set, got %v", level)
+	}
+}
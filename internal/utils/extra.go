@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// MarshalWithExtra marshals body, which must marshal to a JSON object, and adds the fields of
+// extra that it doesn't already contain
+func MarshalWithExtra(body interface{}, extra map[string]interface{}) ([]byte, error) {
+	data, err := json.Marshal(body)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for key, value := range extra {
+		if _, ok := fields[key]; ok {
+			continue
+		}
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extra field %q: %w", key, err)
+		}
+		fields[key] = raw
+	}
+	return json.Marshal(fields)
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalWithExtra(t *testing.T) {
+	body := struct {
+		Model       string   `json:"model"`
+		Temperature *float32 `json:"temperature,omitempty"`
+	}{Model: "gpt-4o"}
+	extra := map[string]interface{}{
+		"model":            "overridden",
+		"service_tier":     "flex",
+		"reasoning_effort": map[string]string{"level": "low"},
+	}
+
+	data, err := MarshalWithExtra(body, extra)
+	if err != nil {
+		t.Fatalf("MarshalWithExtra failed: %v", err)
+	}
+	var got map[string]interface{}
+	json.Unmarshal(data, &got)
+	want := map[string]interface{}{
+		"model":            "gpt-4o",
+		"service_tier":     "flex",
+		"reasoning_effort": map[string]interface{}{"level": "low"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	if data, err := MarshalWithExtra(body, nil); err != nil || string(data) != `{"model":"gpt-4o"}` {
+		t.Errorf("Expected the body unchanged without extra fields, got %s, %v", data, err)
+	}
+	if _, err := MarshalWithExtra(body, map[string]interface{}{"bad": make(chan int)}); err == nil {
+		t.Error("Expected an error for an extra field that can't be marshalled")
+	}
+}
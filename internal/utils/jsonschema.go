@@ -0,0 +1,214 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"unicode/utf8"
+)
+
+// ValidateJSON checks that data is JSON matching schema. schema is either the JSON string "json",
+// which accepts any JSON value, or a JSON Schema object. Only the keywords that describe the shape
+// of a value are checked: type, enum, const, properties, required, additionalProperties, items,
+// minimum, maximum, minLength, maxLength, minItems and maxItems. Others, such as format or $ref,
+// are ignored.
+func ValidateJSON(schema, data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	if decoder.More() {
+		return errors.New("invalid JSON: unexpected data after the top-level value")
+	}
+
+	var s interface{}
+	if err := json.Unmarshal(schema, &s); err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+	if s == "json" {
+		return nil
+	}
+	return validateValue(s, value, "$")
+}
+
+// validateValue checks value against schema s, naming it path in errors
+func validateValue(s, value interface{}, path string) error {
+	switch s := s.(type) {
+	case bool:
+		if !s {
+			return fmt.Errorf("%s: no value is allowed", path)
+		}
+		return nil
+	case map[string]interface{}:
+		return validateObjectSchema(s, value, path)
+	}
+	return fmt.Errorf("%s: invalid schema %v", path, s)
+}
+
+// validateObjectSchema checks value against the keywords of schema s
+func validateObjectSchema(s map[string]interface{}, value interface{}, path string) error {
+	if t, ok := s["type"]; ok && !matchesType(t, value) {
+		return fmt.Errorf("%s: expected type %v, got %s", path, t, jsonType(value))
+	}
+	if c, ok := s["const"]; ok && !jsonEqual(c, value) {
+		return fmt.Errorf("%s: expected %v", path, c)
+	}
+	if enum, ok := s["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			if jsonEqual(e, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: %v is not one of %v", path, value, enum)
+		}
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		return validateObject(s, value, path)
+	case []interface{}:
+		if err := checkLength(s, "minItems", "maxItems", len(value), path, "items"); err != nil {
+			return err
+		}
+		if items, ok := s["items"]; ok {
+			for i, item := range value {
+				if err := validateValue(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		return checkLength(s, "minLength", "maxLength", utf8.RuneCountInString(value), path, "characters")
+	case json.Number:
+		n, err := value.Float64()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if minimum, ok := number(s["minimum"]); ok && n < minimum {
+			return fmt.Errorf("%s: %v is less than the minimum %v", path, value, minimum)
+		}
+		if maximum, ok := number(s["maximum"]); ok && n > maximum {
+			return fmt.Errorf("%s: %v is greater than the maximum %v", path, value, maximum)
+		}
+	}
+	return nil
+}
+
+// validateObject checks the properties of an object value against schema s
+func validateObject(s map[string]interface{}, value map[string]interface{}, path string) error {
+	if required, ok := s["required"].([]interface{}); ok {
+		for _, name := range required {
+			if name, ok := name.(string); ok {
+				if _, present := value[name]; !present {
+					return fmt.Errorf("%s: missing required property %q", path, name)
+				}
+			}
+		}
+	}
+
+	properties, _ := s["properties"].(map[string]interface{})
+	// Properties are checked in order, so that the first error is the same every time
+	names := make([]string, 0, len(value))
+	for name := range value {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		propertyPath := path + "." + name
+		if property, ok := properties[name]; ok {
+			if err := validateValue(property, value[name], propertyPath); err != nil {
+				return err
+			}
+		} else if additional, ok := s["additionalProperties"]; ok {
+			if additional == false {
+				return fmt.Errorf("%s: unexpected property %q", path, name)
+			}
+			if err := validateValue(additional, value[name], propertyPath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkLength checks n against the minKey and maxKey bounds of s
+func checkLength(s map[string]interface{}, minKey, maxKey string, n int, path, unit string) error {
+	if minimum, ok := number(s[minKey]); ok && float64(n) < minimum {
+		return fmt.Errorf("%s: expected at least %v %s, got %d", path, minimum, unit, n)
+	}
+	if maximum, ok := number(s[maxKey]); ok && float64(n) > maximum {
+		return fmt.Errorf("%s: expected at most %v %s, got %d", path, maximum, unit, n)
+	}
+	return nil
+}
+
+// matchesType reports whether value has the type t, a type name or a list of them
+func matchesType(t, value interface{}) bool {
+	switch t := t.(type) {
+	case string:
+		actual := jsonType(value)
+		if t == "number" && actual == "integer" {
+			return true
+		}
+		return t == actual
+	case []interface{}:
+		for _, name := range t {
+			if matchesType(name, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jsonType returns the JSON Schema type of a decoded value
+func jsonType(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := value.Int64(); err == nil {
+			return "integer"
+		}
+		if f, err := value.Float64(); err == nil && f == float64(int64(f)) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// number returns a schema keyword's value as a float64, if it is a number
+func number(v interface{}) (float64, bool) {
+	n, ok := v.(float64)
+	return n, ok
+}
+
+// jsonEqual reports whether a schema value, decoded with float64 numbers, equals a decoded value
+func jsonEqual(schemaValue, value interface{}) bool {
+	a, errA := json.Marshal(schemaValue)
+	b, errB := json.Marshal(value)
+	if errA != nil || errB != nil {
+		return false
+	}
+	var x, y interface{}
+	json.Unmarshal(a, &x)
+	json.Unmarshal(b, &y)
+	return reflect.DeepEqual(x, y)
+}
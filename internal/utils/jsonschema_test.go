@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateJSON(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string", "minLength": 1},
+			"age": {"type": "integer", "minimum": 0, "maximum": 150},
+			"score": {"type": "number"},
+			"role": {"enum": ["admin", "user"]},
+			"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2},
+			"manager": {"type": ["object", "null"]}
+		},
+		"required": ["name", "age"],
+		"additionalProperties": false
+	}`
+
+	tests := []struct {
+		name    string
+		schema  string
+		data    string
+		wantErr string
+	}{
+		{"Valid", schema, `{"name":"Ada","age":36,"score":9.5,"role":"admin","tags":["math"],"manager":null}`, ""},
+		{"IntegerAsNumber", schema, `{"name":"Ada","age":36,"score":9}`, ""},
+		{"WholeFloatAsInteger", schema, `{"name":"Ada","age":36.0}`, ""},
+		{"AnyJSON", `"json"`, `[1, "two", null]`, ""},
+		{"BooleanSchema", `{"properties":{"x":true}}`, `{"x":[1]}`, ""},
+		{"NotJSON", `"json"`, `{"name":`, "invalid JSON"},
+		{"TrailingData", schema, `{"name":"Ada","age":36} {}`, "invalid JSON"},
+		{"WrongType", schema, `{"name":"Ada","age":"36"}`, "$.age: expected type integer, got string"},
+		{"Fraction", schema, `{"name":"Ada","age":36.5}`, "$.age: expected type integer, got number"},
+		{"Missing", schema, `{"name":"Ada"}`, `$: missing required property "age"`},
+		{"Additional", schema, `{"name":"Ada","age":36,"city":"London"}`, `$: unexpected property "city"`},
+		{"Enum", schema, `{"name":"Ada","age":36,"role":"guest"}`, "$.role: guest is not one of [admin user]"},
+		{"Maximum", schema, `{"name":"Ada","age":200}`, "$.age: 200 is greater than the maximum 150"},
+		{"MinLength", schema, `{"name":"","age":36}`, "$.name: expected at least 1 characters, got 0"},
+		{"Items", schema, `{"name":"Ada","age":36,"tags":[1]}`, "$.tags[0]: expected type string, got integer"},
+		{"MaxItems", schema, `{"name":"Ada","age":36,"tags":["a","b","c"]}`, "$.tags: expected at most 2 items, got 3"},
+		{"TopLevel", schema, `[]`, "$: expected type object, got array"},
+		{"InvalidSchema", `{`, `{}`, "invalid schema"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateJSON([]byte(tt.schema), []byte(tt.data))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected %s to be valid, got %v", tt.data, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package utils
+
+import "testing"
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"gpt-4o", "gpt-4o", 0},
+		{"gpt-4-turb", "gpt-4-turbo", 1},
+		{"kitten", "sitting", 3},
+		{"claude", "cluade", 2},
+		{"héllo", "hello", 1},
+	}
+	for _, tt := range tests {
+		if got := Levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("Levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
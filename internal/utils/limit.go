@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// LimitBody returns body limited to limit bytes. Reading past the limit fails with
+// models.ErrResponseTooLarge rather than silently truncating the body. A limit of zero or less
+// returns body unchanged.
+func LimitBody(body io.ReadCloser, limit int64) io.ReadCloser {
+	if limit <= 0 {
+		return body
+	}
+	return &limitedBody{ReadCloser: body, limit: limit, remaining: limit}
+}
+
+// limitedBody is a response body that errors once more than limit bytes are read
+type limitedBody struct {
+	io.ReadCloser
+	limit     int64
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		// The limit is reached; the body is too large if anything is left to read
+		var probe [1]byte
+		for {
+			n, err := b.ReadCloser.Read(probe[:])
+			if n > 0 {
+				return 0, fmt.Errorf("%w: more than %d bytes", models.ErrResponseTooLarge, b.limit)
+			}
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
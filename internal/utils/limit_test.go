@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+func TestLimitBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		limit   int64
+		wantErr bool
+	}{
+		{"UnderLimit", "hello", 10, false},
+		{"AtLimit", "hello", 5, false},
+		{"OverLimit", "hello!", 5, true},
+		{"Unlimited", strings.Repeat("x", 1000), 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := io.ReadAll(LimitBody(io.NopCloser(strings.NewReader(tt.body)), tt.limit))
+			if tt.wantErr {
+				if !errors.Is(err, models.ErrResponseTooLarge) {
+					t.Errorf("Expected ErrResponseTooLarge, got %v", err)
+				}
+				return
+			}
+			if err != nil || string(data) != tt.body {
+				t.Errorf("Expected %q, got %q (%v)", tt.body, data, err)
+			}
+		})
+	}
+}
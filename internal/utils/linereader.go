@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"bufio"
+	"io"
+	"sync"
+)
+
+// maxPooledLineSize is the largest line buffer returned to the pool; buffers grown by
+// unusually long lines are left to the garbage collector
+const maxPooledLineSize = 64 << 10
+
+// lineReaderPool holds LineReaders released by finished streams
+var lineReaderPool = sync.Pool{
+	New: func() interface{} {
+		return &LineReader{reader: bufio.NewReaderSize(nil, 4096)}
+	},
+}
+
+// LineReader reads newline-terminated lines from a stream into a reused buffer. LineReaders come
+// from a pool: get one with NewLineReader, use it from a single goroutine, and Release it when done.
+type LineReader struct {
+	reader *bufio.Reader
+	line   []byte
+}
+
+// NewLineReader returns a pooled LineReader reading from r
+func NewLineReader(r io.Reader) *LineReader {
+	l := lineReaderPool.Get().(*LineReader)
+	l.reader.Reset(r)
+	l.line = l.line[:0]
+	return l
+}
+
+// ReadLine reads up to and including the next '\n', like bufio.Reader.ReadBytes. The returned
+// slice is only valid until the next call to ReadLine or Release.
+func (l *LineReader) ReadLine() ([]byte, error) {
+	l.line = l.line[:0]
+	for {
+		fragment, err := l.reader.ReadSlice('\n')
+		l.line = append(l.line, fragment...)
+		if err != bufio.ErrBufferFull {
+			return l.line, err
+		}
+	}
+}
+
+// Release drops the underlying stream and returns l to the pool. l must not be used afterwards.
+func (l *LineReader) Release() {
+	l.reader.Reset(nil)
+	if cap(l.line) > maxPooledLineSize {
+		l.line = nil
+	}
+	l.line = l.line[:0]
+	lineReaderPool.Put(l)
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// sseBody returns a stream of n OpenAI-style server-sent events
+func sseBody(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"token %d\"},\"finish_reason\":null}]}\n\n", i)
+	}
+	b.WriteString("data: [DONE]\n\n")
+	return b.String()
+}
+
+// readAllLines reads every line of r with a pooled LineReader
+func readAllLines(r io.Reader) ([]string, error) {
+	reader := NewLineReader(r)
+	defer reader.Release()
+	var lines []string
+	for {
+		line, err := reader.ReadLine()
+		if len(line) > 0 {
+			lines = append(lines, string(line))
+		}
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func TestLineReader(t *testing.T) {
+	long := strings.Repeat("x", 10000)
+	input := "first\n" + long + "\n\nlast without newline"
+
+	lines, err := readAllLines(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ReadLine failed: %v", err)
+	}
+	want := []string{"first\n", long + "\n", "\n", "last without newline"}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected lines %q, got %q", want, lines)
+	}
+
+	// A reader released mid-stream must not leak buffered data into the next stream
+	reader := NewLineReader(strings.NewReader("one\ntwo\nthree\n"))
+	reader.ReadLine()
+	reader.Release()
+	if lines, _ := readAllLines(strings.NewReader("fresh\n")); len(lines) != 1 || lines[0] != "fresh\n" {
+		t.Errorf("Expected only the new stream's line, got %q", lines)
+	}
+}
+
+func TestLineReaderConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := strings.Repeat(fmt.Sprintf("stream %d line\n", i), 200)
+			lines, err := readAllLines(strings.NewReader(body))
+			if err != nil {
+				t.Errorf("ReadLine failed: %v", err)
+				return
+			}
+			if strings.Join(lines, "") != body {
+				t.Errorf("Stream %d read another stream's data", i)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func BenchmarkStreamLines(b *testing.B) {
+	body := []byte(sseBody(100))
+
+	b.Run("Pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(body)))
+		for i := 0; i < b.N; i++ {
+			reader := NewLineReader(bytes.NewReader(body))
+			for {
+				if _, err := reader.ReadLine(); err != nil {
+					break
+				}
+			}
+			reader.Release()
+		}
+	})
+
+	// ReadBytes is how the stream parsers read lines before the pool was introduced
+	b.Run("ReadBytes", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(body)))
+		for i := 0; i < b.N; i++ {
+			reader := bufio.NewReader(bytes.NewReader(body))
+			for {
+				if _, err := reader.ReadBytes('\n'); err != nil {
+					break
+				}
+			}
+		}
+	})
+}
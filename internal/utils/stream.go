@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"context"
+	"io"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// NewStream returns the channel of a stream buffering size chunks, as set by
+// CompletionInput.StreamBufferSize. A size of zero or less is unbuffered.
+func NewStream(size int) chan models.StreamingCompletionResponse {
+	return make(chan models.StreamingCompletionResponse, max(size, 0))
+}
+
+// SendChunk sends chunk on streamChan unless ctx is done first, and reports whether it was sent.
+// Provider stream goroutines return when it fails, so a cancelled stream doesn't leave them
+// blocked on a consumer that has gone.
+func SendChunk(ctx context.Context, streamChan chan<- models.StreamingCompletionResponse, chunk models.StreamingCompletionResponse) bool {
+	select {
+	case streamChan <- chunk:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// SendError ends a stream with err, on the Done chunk as the streaming contract requires
+func SendError(ctx context.Context, streamChan chan<- models.StreamingCompletionResponse, err error) {
+	SendChunk(ctx, streamChan, models.StreamingCompletionResponse{Error: err, Done: true})
+}
+
+// StreamError returns the error to end a stream with when reading it failed with err: an EOF
+// before the provider's end marker means the stream was cut off
+func StreamError(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
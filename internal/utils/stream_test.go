@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"context"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+func TestSendChunk(t *testing.T) {
+	streamChan := make(chan models.StreamingCompletionResponse, 1)
+	if !SendChunk(context.Background(), streamChan, models.StreamingCompletionResponse{Text: "hi"}) {
+		t.Fatal("Expected the chunk to be sent")
+	}
+	if chunk := <-streamChan; chunk.Text != "hi" {
+		t.Errorf("Expected the chunk sent, got %+v", chunk)
+	}
+
+	// Nobody reads the unbuffered channel; the cancelled context must unblock the send
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if SendChunk(ctx, make(chan models.StreamingCompletionResponse), models.StreamingCompletionResponse{Text: "hi"}) {
+		t.Error("Expected the send to fail once the context is done")
+	}
+}
+
+func TestNewStream(t *testing.T) {
+	for size, want := range map[int]int{-1: 0, 0: 0, 16: 16} {
+		if got := cap(NewStream(size)); got != want {
+			t.Errorf("NewStream(%d): expected capacity %d, got %d", size, want, got)
+		}
+	}
+}
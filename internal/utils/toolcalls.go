@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// ToolCallAccumulator assembles the tool calls of a stream from their fragments. The zero value
+// is ready to use.
+type ToolCallAccumulator struct {
+	calls map[int]*partialToolCall
+}
+
+// partialToolCall is a tool call whose fragments are still arriving
+type partialToolCall struct {
+	id        string
+	name      string
+	arguments strings.Builder
+}
+
+// Add records a fragment of a tool call
+func (a *ToolCallAccumulator) Add(delta models.ToolCallDelta) {
+	if a.calls == nil {
+		a.calls = make(map[int]*partialToolCall)
+	}
+	call, ok := a.calls[delta.Index]
+	if !ok {
+		call = &partialToolCall{}
+		a.calls[delta.Index] = call
+	}
+	if delta.ID != "" {
+		call.id = delta.ID
+	}
+	if delta.Name != "" {
+		call.name = delta.Name
+	}
+	call.arguments.WriteString(delta.Arguments)
+}
+
+// ToolCalls returns the assembled calls in index order, or nil if there are none. Calls without
+// arguments get an empty object. It returns an error if a call's arguments are not valid JSON,
+// e.g. because the stream was cut short.
+func (a *ToolCallAccumulator) ToolCalls() ([]models.ToolCall, error) {
+	if len(a.calls) == 0 {
+		return nil, nil
+	}
+	indexes := make([]int, 0, len(a.calls))
+	for index := range a.calls {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	calls := make([]models.ToolCall, len(indexes))
+	for i, index := range indexes {
+		call := a.calls[index]
+		arguments := call.arguments.String()
+		if strings.TrimSpace(arguments) == "" {
+			arguments = "{}"
+		}
+		if !json.Valid([]byte(arguments)) {
+			return nil, fmt.Errorf("tool call %s to %s has invalid JSON arguments: %s", call.id, call.name, arguments)
+		}
+		calls[i] = models.ToolCall{ID: call.id, Name: call.name, Arguments: json.RawMessage(arguments)}
+	}
+	return calls, nil
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+func TestToolCallAccumulator(t *testing.T) {
+	var acc ToolCallAccumulator
+	if calls, err := acc.ToolCalls(); calls != nil || err != nil {
+		t.Errorf("Expected no calls from an empty accumulator, got %v (%v)", calls, err)
+	}
+
+	// Two interleaved calls, and one without arguments
+	for _, delta := range []models.ToolCallDelta{
+		{Index: 2, ID: "call_b", Name: "get_time"},
+		{Index: 0, ID: "call_a", Name: "get_weather"},
+		{Index: 2, Arguments: `{"tz":`},
+		{Index: 0, Arguments: `{"city":`},
+		{Index: 2, Arguments: `"UTC"}`},
+		{Index: 0, Arguments: `"Paris"}`},
+		{Index: 5, ID: "call_c", Name: "now"},
+	} {
+		acc.Add(delta)
+	}
+	calls, err := acc.ToolCalls()
+	if err != nil {
+		t.Fatalf("ToolCalls failed: %v", err)
+	}
+	want := []models.ToolCall{
+		{ID: "call_a", Name: "get_weather", Arguments: []byte(`{"city":"Paris"}`)},
+		{ID: "call_b", Name: "get_time", Arguments: []byte(`{"tz":"UTC"}`)},
+		{ID: "call_c", Name: "now", Arguments: []byte(`{}`)},
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("Expected %+v, got %+v", want, calls)
+	}
+
+	// Arguments cut short are reported rather than passed on
+	acc.Add(models.ToolCallDelta{Index: 7, ID: "call_d", Name: "search", Arguments: `{"query": "go`})
+	if _, err := acc.ToolCalls(); err == nil {
+		t.Error("Expected an error for truncated arguments")
+	}
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"net/http"
+	"time"
+)
+
+// TransportConfig tunes the HTTP transport of the OpenAI, Anthropic and Ollama providers. The
+// client and each provider package export it under their own name.
+type TransportConfig struct {
+	// MaxIdleConns caps the idle connections kept across all hosts; 0 means no limit
+	MaxIdleConns int
+	// MaxIdleConnsPerHost caps the idle connections kept per host. Set it to at least the
+	// number of concurrent requests to a provider to avoid reconnecting under load.
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout is how long an idle connection is kept before it is closed; 0 means forever
+	IdleConnTimeout time.Duration
+	// ForceAttemptHTTP2 negotiates HTTP/2 where the server supports it
+	ForceAttemptHTTP2 bool
+	// DisableCompression stops the transport from asking for gzip responses
+	DisableCompression bool
+}
+
+// DefaultTransportConfig returns the transport settings used unless others are given: 100 idle
+// connections, at most 32 of them per host, kept for 90 seconds
+func DefaultTransportConfig() TransportConfig {
+	return TransportConfig{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 32,
+		IdleConnTimeout:     90 * time.Second,
+		ForceAttemptHTTP2:   true,
+	}
+}
+
+// Transport returns a clone of http.DefaultTransport with the settings of cfg
+func (cfg TransportConfig) Transport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = cfg.MaxIdleConns
+	transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	transport.IdleConnTimeout = cfg.IdleConnTimeout
+	transport.ForceAttemptHTTP2 = cfg.ForceAttemptHTTP2
+	transport.DisableCompression = cfg.DisableCompression
+	return transport
+}
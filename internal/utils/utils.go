@@ -1,3 +1,62 @@
 package utils
 
-// TODO: Implement utility functions
+import (
+	"unicode"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// EstimateTokens approximates the number of tokens a BPE tokenizer such as OpenAI's cl100k_base
+// produces for text, without loading a vocabulary. It is intended for English prose, where it is
+// close enough to warn about growing prompts, not for enforcing exact context limits.
+func EstimateTokens(text string) int {
+	runes := []rune(text)
+	tokens := 0
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		j := i + 1
+		switch {
+		case r <= unicode.MaxASCII && unicode.IsLetter(r):
+			for j < len(runes) && runes[j] <= unicode.MaxASCII && unicode.IsLetter(runes[j]) {
+				j++
+			}
+			// Common words are a single token; long ones split into chunks of about six letters
+			if n := j - i; n <= 10 {
+				tokens++
+			} else {
+				tokens += 1 + (n-4)/6
+			}
+		case unicode.IsDigit(r):
+			for j < len(runes) && unicode.IsDigit(runes[j]) {
+				j++
+			}
+			// Numbers are split into groups of up to three digits
+			tokens += (j - i + 2) / 3
+		case r == '\n':
+			for j < len(runes) && runes[j] == '\n' {
+				j++
+			}
+			tokens++
+		case unicode.IsSpace(r):
+			// Spaces are merged into the token that follows them
+		default:
+			// Punctuation, symbols and non-Latin characters
+			tokens++
+		}
+		i = j
+	}
+	return tokens
+}
+
+// EstimatePromptTokens approximates the prompt tokens of a chat request, including the few
+// tokens chat APIs add to frame each message
+func EstimatePromptTokens(messages []models.ChatMessage) int {
+	if len(messages) == 0 {
+		return 0
+	}
+	tokens := 3
+	for _, message := range messages {
+		tokens += 4 + EstimateTokens(message.Content)
+	}
+	return tokens
+}
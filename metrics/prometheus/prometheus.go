@@ -0,0 +1,109 @@
+// Package prometheus records gollm request metrics with Prometheus.
+//
+// It lives in its own module so that applications which don't use Prometheus
+// never pull in the client_golang dependency.
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/1broseidon/gollm/client"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// collector holds the Prometheus metrics updated by the hooks
+type collector struct {
+	requests *prom.CounterVec
+	errors   *prom.CounterVec
+	latency  *prom.HistogramVec
+	tokens   *prom.CounterVec
+}
+
+// NewHooks registers the gollm metrics with reg and returns hooks that update them.
+// Pass the result to client.WithHooks. Use prom.DefaultRegisterer to expose the
+// metrics through the default /metrics handler.
+func NewHooks(reg prom.Registerer) (client.Hooks, error) {
+	c := newCollector()
+	if err := c.register(reg); err != nil {
+		return client.Hooks{}, err
+	}
+
+	return client.Hooks{
+		BeforeRequest: c.beforeRequest,
+		AfterRequest:  c.afterRequest,
+	}, nil
+}
+
+// newCollector creates the unregistered gollm metrics
+func newCollector() *collector {
+	return &collector{
+		requests: prom.NewCounterVec(prom.CounterOpts{
+			Name: "gollm_requests_total",
+			Help: "Number of requests sent to LLM providers.",
+		}, []string{"provider", "model", "operation"}),
+		errors: prom.NewCounterVec(prom.CounterOpts{
+			Name: "gollm_request_errors_total",
+			Help: "Number of failed requests to LLM providers, by error type.",
+		}, []string{"provider", "model", "operation", "type"}),
+		latency: prom.NewHistogramVec(prom.HistogramOpts{
+			Name:    "gollm_request_duration_seconds",
+			Help:    "Latency of requests to LLM providers. Streaming requests are measured until the stream ends.",
+			Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+		}, []string{"provider", "model", "operation"}),
+		tokens: prom.NewCounterVec(prom.CounterOpts{
+			Name: "gollm_tokens_total",
+			Help: "Number of tokens reported by LLM providers, by token type.",
+		}, []string{"provider", "model", "type"}),
+	}
+}
+
+// register adds every metric to reg
+func (c *collector) register(reg prom.Registerer) error {
+	for _, metric := range []prom.Collector{c.requests, c.errors, c.latency, c.tokens} {
+		if err := reg.Register(metric); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *collector) beforeRequest(ctx context.Context, info client.RequestInfo) context.Context {
+	c.requests.WithLabelValues(info.Provider, info.Model, info.Operation).Inc()
+	return ctx
+}
+
+func (c *collector) afterRequest(ctx context.Context, info client.RequestInfo, result client.RequestResult) {
+	c.latency.WithLabelValues(info.Provider, info.Model, info.Operation).Observe(result.Duration.Seconds())
+
+	if result.Err != nil {
+		c.errors.WithLabelValues(info.Provider, info.Model, info.Operation, errorType(result.Err)).Inc()
+	}
+
+	if result.Usage != nil {
+		c.tokens.WithLabelValues(info.Provider, info.Model, "prompt").Add(float64(result.Usage.PromptTokens))
+		c.tokens.WithLabelValues(info.Provider, info.Model, "completion").Add(float64(result.Usage.CompletionTokens))
+	}
+}
+
+// errorType classifies an error into a low-cardinality label value
+func errorType(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &netErr):
+		return "network"
+	case errors.Is(err, client.ErrUnsupportedProvider):
+		return "unsupported_provider"
+	case errors.Is(err, client.ErrContentFiltered):
+		return "content_filtered"
+	default:
+		return "provider"
+	}
+}
@@ -0,0 +1,69 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/1broseidon/gollm/client"
+	"github.com/1broseidon/gollm/models"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHooks(t *testing.T) {
+	reg := prom.NewRegistry()
+	c := newCollector()
+	if err := c.register(reg); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+
+	ctx := context.Background()
+	info := client.RequestInfo{Operation: client.OperationCompletion, Provider: "openai", Model: "gpt-4o", StartTime: time.Now()}
+
+	ctx = c.beforeRequest(ctx, info)
+	c.afterRequest(ctx, info, client.RequestResult{
+		Duration: 250 * time.Millisecond,
+		Usage:    &models.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+	})
+
+	ctx = c.beforeRequest(ctx, info)
+	c.afterRequest(ctx, info, client.RequestResult{Err: context.DeadlineExceeded})
+
+	if got := testutil.ToFloat64(c.requests.WithLabelValues("openai", "gpt-4o", client.OperationCompletion)); got != 2 {
+		t.Errorf("Expected 2 requests, got %v", got)
+	}
+	if got := testutil.ToFloat64(c.errors.WithLabelValues("openai", "gpt-4o", client.OperationCompletion, "timeout")); got != 1 {
+		t.Errorf("Expected 1 timeout error, got %v", got)
+	}
+	if got := testutil.ToFloat64(c.tokens.WithLabelValues("openai", "gpt-4o", "prompt")); got != 10 {
+		t.Errorf("Expected 10 prompt tokens, got %v", got)
+	}
+	if got := testutil.ToFloat64(c.tokens.WithLabelValues("openai", "gpt-4o", "completion")); got != 5 {
+		t.Errorf("Expected 5 completion tokens, got %v", got)
+	}
+
+	if _, err := NewHooks(reg); err == nil {
+		t.Error("Expected registering the metrics twice on one registry to fail")
+	}
+}
+
+func TestErrorType(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{context.Canceled, "canceled"},
+		{context.DeadlineExceeded, "timeout"},
+		{client.ErrUnsupportedProvider, "unsupported_provider"},
+		{fmt.Errorf("wrapped: %w", client.ErrContentFiltered), "content_filtered"},
+		{errors.New("API request failed with status code: 500"), "provider"},
+	}
+	for _, tt := range tests {
+		if got := errorType(tt.err); got != tt.want {
+			t.Errorf("errorType(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package models
+
+// Capabilities lists what a provider supports beyond completions, which every provider serves.
+// Providers report them with a Capabilities method; the client checks them before dispatching
+// an operation and returns ErrUnsupportedOperation for those a provider lacks.
+type Capabilities struct {
+	Streaming       bool // Streaming completions
+	Tools           bool // Tool calling with CompletionInput.Tools
+	Vision          bool // Image ContentParts in messages
+	Embeddings      bool // GenerateEmbedding
+	Chat            bool // Provider-managed chat sessions, with StartChat and SendChatMessage
+	Speech          bool // SynthesizeSpeech
+	ImageGeneration bool // GenerateImage
+	Rerank          bool // Rerank
+}
+
+// ProviderChatSession is a chat session kept by a provider, which holds its history. It is
+// returned by the provider's StartChat and passed back to its SendChatMessage.
+type ProviderChatSession interface {
+	// Model returns the model the session talks to
+	Model() string
+}
@@ -0,0 +1,30 @@
+package models
+
+// Citation location types, which tell what Citation.Start and Citation.End count
+const (
+	// CitationCharLocation locates a passage of a text document by character, from 0
+	CitationCharLocation = "char_location"
+	// CitationPageLocation locates a passage of a PDF document by page, from 1
+	CitationPageLocation = "page_location"
+	// CitationContentBlockLocation locates a passage of a document by content block, from 0
+	CitationContentBlockLocation = "content_block_location"
+)
+
+// Citation is a passage of a document that part of a response relies on
+type Citation struct {
+	Type      string // CitationCharLocation, CitationPageLocation or CitationContentBlockLocation
+	CitedText string // The text of the passage
+
+	// DocumentIndex is the index of the cited document among the documents of the request,
+	// counted in order across all messages
+	DocumentIndex int
+	// DocumentTitle is the ContentPart.Title of the cited document
+	DocumentTitle string
+
+	// Start and End locate the passage in the document, End excluded, in the units of Type
+	Start, End int
+
+	// TextStart and TextEnd are the byte offsets of the part of the response text that relies
+	// on the passage, TextEnd excluded
+	TextStart, TextEnd int
+}
@@ -1,26 +1,140 @@
 package models
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // CompletionInput represents the input for a completion request.
 type CompletionInput struct {
-	Model       string
-	Messages    []ChatMessage
-	MaxTokens   int
-	Temperature float32
-	Stream      bool
-	Provider    string // Specifies the provider explicitly
+	Model    string
+	Messages []ChatMessage
+	Stream   bool
+	Provider string // Specifies the provider explicitly
+
+	// MaxTokens limits the tokens generated. Zero leaves the provider's default.
+	MaxTokens int
+	// Temperature sets the sampling temperature. Nil leaves the provider's default, while
+	// Float32(0) asks for deterministic sampling.
+	Temperature *float32
+	// TopP sets nucleus sampling. Nil leaves the provider's default.
+	TopP *float32
+	// Stop holds sequences at which the model stops generating, without including them
+	Stop []string
+
+	// Tools are the functions the model may call. The OpenAI and Anthropic providers support tools.
+	Tools []Tool
+	// ToolChoice controls whether and which tools the model calls. Nil means ToolChoiceAuto.
+	ToolChoice *ToolChoice
+
+	// StreamBufferSize is the number of chunks a stream buffers, in the provider and in the client
+	// each, so that a consumer slower than the provider doesn't stall its connection. A full
+	// buffer blocks as an unbuffered stream does, without dropping chunks. Zero, the default,
+	// leaves streams unbuffered; larger buffers hold more chunks in memory.
+	StreamBufferSize int
+
+	// AutoTrim drops the oldest non-system messages when the prompt and MaxTokens don't fit the
+	// model's context window, as with the client option WithAutoTrim
+	AutoTrim bool
+
+	// ProviderOptions holds settings that only apply to a specific provider
+	ProviderOptions ProviderOptions
+
+	// Extra holds fields added to the JSON body sent by the OpenAI, Anthropic and Ollama
+	// providers, for API parameters the library doesn't model yet. They are specific to the
+	// provider and sent unvalidated. Fields the provider sets itself take precedence; Gemini
+	// ignores Extra.
+	Extra map[string]interface{}
+}
+
+// Float32 returns a pointer to v, for the optional fields of CompletionInput
+func Float32(v float32) *float32 {
+	return &v
 }
 
 // ChatMessage represents a message in a chat conversation.
 type ChatMessage struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+
+	// ToolCalls are the tool calls requested in an assistant message
+	ToolCalls []ToolCall `json:"-"`
+	// ToolCallID identifies the call a tool message answers
+	ToolCallID string `json:"-"`
+	// IsError marks a tool message as the error of a failed call. Anthropic is told so; the
+	// other providers send the content alone.
+	IsError bool `json:"-"`
+
+	// ContentParts hold images, documents and text sent before Content, for models that take
+	// multimodal input. The Anthropic and Gemini providers support them; the others send
+	// Content alone.
+	ContentParts []ContentPart `json:"-"`
 }
 
 // CompletionResponse represents the response from a completion request.
 type CompletionResponse struct {
-	Text     string
-	Usage    *Usage
-	Provider string // Indicates which provider generated the response
+	Text         string
+	ThinkingText string // The model's reasoning, for providers and models that expose it
+	Usage        *Usage
+	Provider     string // Indicates which provider generated the response
+
+	// Model is the model that served the request, e.g. "openai/gpt-4o-2024-08-06" when
+	// "openai/gpt-4o" was requested. Providers report the resolved model when their API
+	// returns it and the requested one otherwise; the client adds the provider prefix.
+	Model string
+
+	// ToolCalls are the tools the model asked to call instead of, or along with, answering
+	ToolCalls []ToolCall
+
+	// Logprobs holds the log probability of each generated token when requested with
+	// OpenAIOptions.Logprobs. Only the OpenAI provider returns it for now.
+	Logprobs []TokenLogprob
+
+	// Citations are the passages of the documents sent with ContentPart.Citations that Text
+	// relies on. Only the Anthropic provider returns them.
+	Citations []Citation
+
+	// Timing records when the request was queued, sent and answered. It is set by the client.
+	Timing *Timing
+
+	// TrimmedMessages is the number of the oldest messages dropped by AutoTrim to fit the
+	// context window. It is set by the client.
+	TrimmedMessages int
+
+	// FinishReason is why generation ended, when known. Only the client sets it for now, to
+	// FinishReasonClientStop.
+	FinishReason string
+}
+
+// FinishReasonClientStop is the FinishReason of a response cut at a stop sequence by the client,
+// as enabled with the client option WithClientSideStop
+const FinishReasonClientStop = "stop_sequence_client"
+
+// Timing describes the latency of a request as measured by the client, plus the server-side
+// durations reported by providers that expose them
+type Timing struct {
+	QueuedAt    time.Time     // When the client received the request, before waiting for a concurrency slot
+	StartedAt   time.Time     // When the request was handed to the provider
+	FirstByteAt time.Time     // When the first byte of the HTTP response arrived; zero if it wasn't observed
+	CompletedAt time.Time     // When the response, or the Done chunk of a stream, was received
+	Duration    time.Duration // From StartedAt to CompletedAt
+
+	ServerDuration time.Duration // Total time the server spent on the request (Ollama's total_duration)
+	EvalDuration   time.Duration // Time the server spent generating tokens (Ollama's eval_duration)
+}
+
+// TokenLogprob is the log probability of a generated token
+type TokenLogprob struct {
+	Token   string  `json:"token"`
+	Logprob float64 `json:"logprob"`
+	// TopLogprobs holds the most likely tokens at this position, when requested
+	TopLogprobs []TopLogprob `json:"top_logprobs"`
+}
+
+// TopLogprob is an alternative token and its log probability
+type TopLogprob struct {
+	Token   string  `json:"token"`
+	Logprob float64 `json:"logprob"`
 }
 
 // Usage represents the token usage information for a completion request.
@@ -28,15 +142,115 @@ type Usage struct {
 	PromptTokens     int
 	CompletionTokens int
 	TotalTokens      int
+
+	// Details breaks the counts down by kind, for providers that report it; nil otherwise
+	Details *UsageDetails
+
+	// Estimated is set when the provider reported no usage and the client estimated the
+	// counts, with the tokenizer set with WithTokenizer or an offline approximation
+	Estimated bool
+}
+
+// Add adds the token counts of other, which may be nil, to u. Details are added when either
+// usage has them; u gets a new Details, so that one shared with another Usage is not modified.
+func (u *Usage) Add(other *Usage) {
+	if other == nil {
+		return
+	}
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.TotalTokens += other.TotalTokens
+	u.Estimated = u.Estimated || other.Estimated
+	if other.Details != nil {
+		var details UsageDetails
+		if u.Details != nil {
+			details = *u.Details
+		}
+		details.ReasoningTokens += other.Details.ReasoningTokens
+		details.CacheReadTokens += other.Details.CacheReadTokens
+		details.CacheWriteTokens += other.Details.CacheWriteTokens
+		details.AudioTokens += other.Details.AudioTokens
+		u.Details = &details
+	}
+}
+
+// UsageDetails breaks down the token counts of a Usage by kind. Counts a provider doesn't
+// report are zero.
+type UsageDetails struct {
+	// ReasoningTokens are the completion tokens a reasoning model, such as OpenAI's o-series,
+	// spent on reasoning it doesn't return. They are included in CompletionTokens.
+	ReasoningTokens int
+	// CacheReadTokens are the prompt tokens read from the provider's prompt cache. OpenAI
+	// includes them in PromptTokens, so that CacheReadTokens / PromptTokens is its cache hit
+	// rate; Anthropic counts them separately.
+	CacheReadTokens int
+	// CacheWriteTokens are the prompt tokens written to the prompt cache. Anthropic counts them
+	// separately from PromptTokens.
+	CacheWriteTokens int
+	// AudioTokens are the prompt and completion tokens of audio input and output, included in
+	// PromptTokens and CompletionTokens
+	AudioTokens int
+}
+
+// IsZero reports whether all the counts of d are zero, as for providers that report none of them
+func (d UsageDetails) IsZero() bool {
+	return d == UsageDetails{}
 }
 
 // StreamingCompletionResponse represents a chunk of a streaming completion response.
+//
+// Provider streams follow this contract, checked by the providertest package:
+//   - Exactly one chunk has Done set, and it is the last chunk; the channel is closed after it.
+//   - Text and ThinkingText are deltas on every chunk, the Done chunk included, so the
+//     response is their concatenation.
+//   - Usage and ToolCalls are only set on the Done chunk.
+//   - A chunk with an Error ends the stream, so it is the Done chunk. A stream cut off before
+//     the provider's end marker ends with io.ErrUnexpectedEOF.
+//
+// When the stream's context is cancelled the provider stops sending and closes the channel,
+// possibly without a Done chunk; streams returned by the client then end with one reporting it.
+// A consumer that stops reading before the channel closes must cancel the context: until then the
+// provider's goroutine waits to send the next chunk and holds its HTTP response open.
 type StreamingCompletionResponse struct {
-	Text     string
-	Done     bool
-	Error    error
-	Usage    *Usage
-	Provider string // Indicates which provider generated the response
+	Text           string
+	ThinkingText   string // Reasoning emitted in this chunk, kept separate from Text
+	Done           bool
+	Error          error
+	Usage          *Usage
+	Provider       string          // Indicates which provider generated the response
+	Metrics        *StreamMetrics  // Set on the Done chunk of streams returned by the client
+	ToolCalls      []ToolCall      // The assembled tool calls, set on the Done chunk when the model called tools
+	ToolCallDeltas []ToolCallDelta // The tool call fragments received in this chunk
+	Timing         *Timing         // Set on the Done chunk of streams returned by the client
+	Model          string          // The model that served the request, as in CompletionResponse; set on the Done chunk
+
+	// TrimmedMessages is the number of messages dropped by AutoTrim, as in CompletionResponse;
+	// set on the Done chunk
+	TrimmedMessages int
+
+	// Resumed marks a chunk without text sent by the client when it reconnected an interrupted
+	// stream, as enabled with WithStreamResume. The chunks after it continue the response.
+	Resumed bool
+
+	// FinishReason is why generation ended, as in CompletionResponse; set on the Done chunk
+	FinishReason string
+
+	// Citations are the cited passages of documents, as in CompletionResponse; set on the Done
+	// chunk. Their TextStart and TextEnd index the concatenated Text of the stream.
+	Citations []Citation
+
+	// PartialText is set by the client on a chunk with an Error to all the text streamed before
+	// the error, including this chunk's, so callers can keep or discard what was generated
+	PartialText string
+}
+
+// StreamMetrics describes the latency and throughput of a streaming completion. When the provider
+// reports no usage, TokensPerSecond is based on a token estimate of the streamed text.
+type StreamMetrics struct {
+	FirstTokenLatency time.Duration // Time from sending the request to the first text chunk
+	TotalDuration     time.Duration // Time from sending the request to the Done chunk
+	TokensPerSecond   float64       // Completion tokens per second after the first token
+	Chunks            int           // Number of chunks received, including the Done chunk
 }
 
 // ProviderOptions represents additional options specific to each provider.
@@ -49,20 +263,83 @@ type ProviderOptions struct {
 
 // OpenAIOptions represents OpenAI-specific options.
 type OpenAIOptions struct {
-	// TODO: Add OpenAI-specific fields here
+	// Logprobs returns the log probability of each generated token in CompletionResponse.Logprobs.
+	// It applies to non-streaming completions.
+	Logprobs bool
+	// TopLogprobs also returns the given number (0-20) of most likely tokens at each position.
+	// It implies Logprobs.
+	TopLogprobs int
+	// ParallelToolCalls sets whether the model may call several tools in one response. Nil
+	// leaves the API default, which allows parallel calls. It only applies when tools are given.
+	ParallelToolCalls *bool
+	// ReasoningEffort sets how much reasoning o-series models do before answering:
+	// ReasoningEffortLow, ReasoningEffortMedium or ReasoningEffortHigh. When set, Temperature
+	// and TopP, which these models don't support, are not sent, and MaxTokens is sent as
+	// max_completion_tokens, which counts the reasoning tokens too. The reasoning tokens are
+	// reported in Usage.Details.ReasoningTokens.
+	ReasoningEffort string
 }
 
+// Reasoning efforts for OpenAIOptions.ReasoningEffort
+const (
+	ReasoningEffortLow    = "low"
+	ReasoningEffortMedium = "medium"
+	ReasoningEffortHigh   = "high"
+)
+
 // GoogleGeminiOptions represents Google Gemini-specific options.
 type GoogleGeminiOptions struct {
-	// TODO: Add Google Gemini-specific fields here
+	// ThinkingBudget enables thinking mode when set: the reasoning is returned in ThinkingText
+	// rather than Text. The genai SDK version in use has no thinking configuration, so the
+	// value isn't sent and doesn't cap the reasoning tokens; the model decides how long it
+	// thinks. Thinking is only available for gemini-2.0-flash-thinking-exp.
+	ThinkingBudget *int32
 }
 
 // AnthropicOptions represents Anthropic-specific options.
 type AnthropicOptions struct {
-	// TODO: Add Anthropic-specific fields here
+	// ThinkingBudget enables extended thinking (Claude 3.7 and later) with the given number of
+	// budget tokens. It must be below MaxTokens. The reasoning is returned in ThinkingText.
+	ThinkingBudget int
+
+	// StrictMessageOrder sends the messages as given, so that a conversation the API rejects
+	// fails with its error. By default consecutive messages of the same role are merged and a
+	// placeholder user turn is inserted before a leading assistant message, as the API requires
+	// alternating roles starting with the user.
+	StrictMessageOrder bool
+
+	// ComputerUseTools offers the built-in tools of the computer use beta, ComputerUseComputer,
+	// ComputerUseTextEditor and ComputerUseBash, alongside the request's Tools. Their calls
+	// are returned in ToolCalls, named "computer", "str_replace_editor" and "bash".
+	ComputerUseTools []string
+
+	// ComputerDisplayWidth and ComputerDisplayHeight are the size of the screen, in pixels,
+	// controlled with the computer tool. The default is 1024 by 768.
+	ComputerDisplayWidth  int
+	ComputerDisplayHeight int
 }
 
+// The built-in tools of Anthropic's computer use beta, for AnthropicOptions.ComputerUseTools
+const (
+	ComputerUseComputer   = "computer"
+	ComputerUseTextEditor = "text_editor"
+	ComputerUseBash       = "bash"
+)
+
 // OllamaOptions represents Ollama-specific options.
 type OllamaOptions struct {
-	// TODO: Add Ollama-specific fields here
+	// Format constrains the output, sent as the request's "format" field. It is either
+	// OllamaFormatJSON, for any JSON value, or a JSON Schema the output must match, which
+	// requires Ollama 0.5 or later. A bare word that isn't JSON, such as json, is sent as a
+	// JSON string, so json.RawMessage("json") works like OllamaFormatJSON.
+	Format json.RawMessage
+
+	// ValidateFormat checks that the output is JSON matching Format, as not every model follows
+	// it reliably. Responses that don't fail with ErrFormatMismatch; streams report it on the
+	// Done chunk. Only the keywords describing the shape of values are checked, such as type,
+	// properties, required, items and enum.
+	ValidateFormat bool
 }
+
+// OllamaFormatJSON is the Ollama format for output that is any JSON value
+var OllamaFormatJSON = json.RawMessage(`"json"`)
@@ -0,0 +1,67 @@
+package models
+
+import "encoding/base64"
+
+// Content part types
+const (
+	ContentPartText     = "text"
+	ContentPartImage    = "image"
+	ContentPartDocument = "document"
+)
+
+// ContentPart is a part of a multimodal message: text, an image or a document. The Anthropic
+// and Gemini providers support documents.
+type ContentPart struct {
+	Type string // ContentPartText, ContentPartImage or ContentPartDocument
+	Text string
+
+	// MimeType is the media type of an image or document, e.g. "image/png" or "application/pdf"
+	MimeType string
+	// Data is the base64-encoded image or document
+	Data string
+	// ImageURL is the URL of an image, sent instead of Data. Providers that can't pass a URL
+	// on download the image, and take its type from the response if MimeType is empty.
+	ImageURL string
+	// FileURI is the URI of a file uploaded to the provider, such as with the Gemini File API,
+	// sent instead of Data
+	FileURI string
+
+	// Title is the title of a document, returned in the citations of it
+	Title string
+	// Citations asks the model to cite the passages of a document its answer relies on, returned
+	// in CompletionResponse.Citations. Only the Anthropic provider supports it.
+	Citations bool
+}
+
+// NewTextPart returns a text content part
+func NewTextPart(text string) ContentPart {
+	return ContentPart{Type: ContentPartText, Text: text}
+}
+
+// NewImageBytePart returns an image content part holding data, an image of type mimeType,
+// base64-encoded
+func NewImageBytePart(data []byte, mimeType string) ContentPart {
+	return ContentPart{Type: ContentPartImage, MimeType: mimeType, Data: base64.StdEncoding.EncodeToString(data)}
+}
+
+// NewDocumentPart returns a document content part holding data, a document of type mimeType such
+// as "application/pdf", base64-encoded
+func NewDocumentPart(data []byte, mimeType string) ContentPart {
+	return ContentPart{Type: ContentPartDocument, MimeType: mimeType, Data: base64.StdEncoding.EncodeToString(data)}
+}
+
+// NewTextDocumentPart returns a plain text document content part holding text
+func NewTextDocumentPart(text string) ContentPart {
+	return NewDocumentPart([]byte(text), "text/plain")
+}
+
+// NewFilePart returns a document content part for a file of type mimeType uploaded to the
+// provider, such as with GoogleGeminiProvider.UploadFile
+func NewFilePart(fileURI, mimeType string) ContentPart {
+	return ContentPart{Type: ContentPartDocument, MimeType: mimeType, FileURI: fileURI}
+}
+
+// NewImageURLPart returns an image content part for the image at url
+func NewImageURLPart(url string) ContentPart {
+	return ContentPart{Type: ContentPartImage, ImageURL: url}
+}
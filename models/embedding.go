@@ -1,15 +1,30 @@
 package models
 
+// Quantization is a compact encoding of the vector of an EmbeddingResponse
+type Quantization string
+
+const (
+	// QuantizeInt8 sets EmbeddingResponse.VectorInt8 and Scale, a quarter of the size of float32
+	QuantizeInt8 Quantization = "int8"
+	// QuantizeBinary sets EmbeddingResponse.VectorBinary, 1/32 of the size of float32
+	QuantizeBinary Quantization = "binary"
+)
+
 // EmbeddingInput represents the input for an embedding request.
 type EmbeddingInput struct {
-	Model    string
-	Text     string
-	Provider string
+	Model     string
+	Text      string
+	Provider  string
+	Normalize bool         // Scale the embedding to unit length, so dot products are cosine similarities
+	Quantize  Quantization // Also return the embedding quantized, after any normalization
 }
 
 // EmbeddingResponse represents the response from an embedding request.
 type EmbeddingResponse struct {
-	Embedding []float32
-	Usage     *Usage
-	Provider  string
+	Embedding    []float32
+	VectorInt8   []int8  // With QuantizeInt8, Embedding[i] ≈ float32(VectorInt8[i]) * Scale
+	Scale        float32 // The scale of VectorInt8
+	VectorBinary []byte  // With QuantizeBinary, the sign bits of Embedding, eight to a byte
+	Usage        *Usage
+	Provider     string
 }
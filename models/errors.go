@@ -0,0 +1,20 @@
+package models
+
+import "errors"
+
+// ErrUnsupportedOperation is returned by providers for operations they don't implement.
+var ErrUnsupportedOperation = errors.New("operation not supported by provider")
+
+// ErrContentFiltered is returned when a provider refuses a request or stops generating because
+// the prompt or output was flagged by its content policy.
+var ErrContentFiltered = errors.New("content filtered by provider")
+
+// ErrFormatMismatch is returned when structured output doesn't match the requested format
+var ErrFormatMismatch = errors.New("output does not match the requested format")
+
+// ErrResponseTooLarge is returned when a response body exceeds the provider's response body limit
+var ErrResponseTooLarge = errors.New("response body too large")
+
+// DefaultResponseBodyLimit is the largest non-streaming response body, in bytes, that the HTTP
+// providers read unless configured otherwise
+const DefaultResponseBodyLimit = 32 << 20
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// rawMessageType is the type of the JSON fields of CompletionInput, such as Tool.Parameters
+var rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+
+// CompletionInputHash returns a hex SHA-256 hash of input that is the same for inputs with the
+// same content, for use as a cache or deduplication key. Map keys and the keys of JSON fields
+// are sorted, JSON whitespace is ignored, and unset fields, nil and empty slices are treated
+// alike. Fields without a JSON encoding, such as ChatMessage.ToolCalls, are included.
+func CompletionInputHash(input CompletionInput) string {
+	sum := sha256.Sum256(canonicalCompletionInput(input))
+	return hex.EncodeToString(sum[:])
+}
+
+// CompletionInputEqual reports whether a and b have the same content, in the sense of
+// CompletionInputHash
+func CompletionInputEqual(a, b CompletionInput) bool {
+	return bytes.Equal(canonicalCompletionInput(a), canonicalCompletionInput(b))
+}
+
+// canonicalCompletionInput returns input as JSON with sorted keys and without unset fields
+func canonicalCompletionInput(input CompletionInput) []byte {
+	// The canonical value holds only maps, slices and JSON scalars, which always marshal
+	data, _ := json.Marshal(canonicalValue(reflect.ValueOf(input)))
+	return data
+}
+
+// canonicalValue converts v into a tree of maps, slices and scalars that encoding/json marshals
+// with sorted keys. Struct fields are keyed by their Go names; zero fields are left out.
+func canonicalValue(v reflect.Value) interface{} {
+	if v.Type() == rawMessageType {
+		return canonicalJSON(v.Bytes())
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Kind() == reflect.Interface {
+			// Values of any type, as in Extra, are compared by their JSON encoding
+			data, err := json.Marshal(v.Interface())
+			if err != nil {
+				return fmt.Sprintf("%#v", v.Interface())
+			}
+			return canonicalJSON(data)
+		}
+		return canonicalValue(v.Elem())
+	case reflect.Struct:
+		fields := make(map[string]interface{})
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() || v.Field(i).IsZero() || isEmpty(v.Field(i)) {
+				continue
+			}
+			fields[field.Name] = canonicalValue(v.Field(i))
+		}
+		return fields
+	case reflect.Slice, reflect.Array:
+		if v.Len() == 0 {
+			return nil
+		}
+		elements := make([]interface{}, v.Len())
+		for i := range elements {
+			elements[i] = canonicalValue(v.Index(i))
+		}
+		return elements
+	case reflect.Map:
+		entries := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			entries[fmt.Sprint(iter.Key().Interface())] = canonicalValue(iter.Value())
+		}
+		return entries
+	}
+	return v.Interface()
+}
+
+// isEmpty reports whether v is an empty slice or map, which is treated like a nil one
+func isEmpty(v reflect.Value) bool {
+	return (v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.Len() == 0
+}
+
+// canonicalJSON decodes data so that it marshals again with sorted keys and no whitespace.
+// Numbers keep their text; invalid JSON is kept as a string.
+func canonicalJSON(data []byte) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return string(data)
+	}
+	return value
+}
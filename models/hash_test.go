@@ -0,0 +1,131 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+// jsonObject writes fields as a JSON object with its keys in the given order, separated by sep
+func jsonObject(fields map[string]int16, keys []string, sep string) json.RawMessage {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		name, _ := json.Marshal(key)
+		parts[i] = fmt.Sprintf("%s:%s%d", name, sep, fields[key])
+	}
+	return json.RawMessage("{" + sep + strings.Join(parts, ","+sep) + sep + "}")
+}
+
+// sortedKeys returns the keys of fields in ascending and in descending order
+func sortedKeys(fields map[string]int16) (ascending, descending []string) {
+	for key := range fields {
+		ascending = append(ascending, key)
+	}
+	sort.Strings(ascending)
+	for i := len(ascending) - 1; i >= 0; i-- {
+		descending = append(descending, ascending[i])
+	}
+	return ascending, descending
+}
+
+func TestCompletionInputHashIgnoresKeyOrder(t *testing.T) {
+	property := func(content string, fields map[string]int16, temperature float32) bool {
+		ascending, descending := sortedKeys(fields)
+		extraA, extraB := make(map[string]interface{}), make(map[string]interface{})
+		for _, key := range ascending {
+			extraA[key] = fields[key]
+		}
+		for _, key := range descending {
+			extraB[key] = float64(fields[key])
+		}
+
+		a := CompletionInput{
+			Model:       "openai/gpt-4o",
+			Messages:    []ChatMessage{{Role: RoleUser, Content: content}},
+			Temperature: Float32(temperature),
+			Tools:       []Tool{{Name: "search", Parameters: jsonObject(fields, ascending, "")}},
+			Extra:       extraA,
+		}
+		b := CompletionInput{
+			Extra:       extraB,
+			Tools:       []Tool{{Name: "search", Parameters: jsonObject(fields, descending, "\n  ")}},
+			Temperature: Float32(temperature),
+			Messages:    []ChatMessage{{Content: content, Role: RoleUser}},
+			Model:       "openai/gpt-4o",
+		}
+		return CompletionInputHash(a) == CompletionInputHash(b) && CompletionInputEqual(a, b)
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCompletionInputHashDistinguishesContent(t *testing.T) {
+	property := func(a, b string) bool {
+		inputA := CompletionInput{Model: "openai/gpt-4o", Messages: []ChatMessage{{Role: RoleUser, Content: a}}}
+		inputB := CompletionInput{Model: "openai/gpt-4o", Messages: []ChatMessage{{Role: RoleUser, Content: b}}}
+		return (a == b) == (CompletionInputHash(inputA) == CompletionInputHash(inputB))
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCompletionInputEqual(t *testing.T) {
+	base := CompletionInput{
+		Model:    "anthropic/claude-3-5-sonnet",
+		Messages: []ChatMessage{{Role: RoleUser, Content: "Hi"}},
+	}
+	tests := []struct {
+		name  string
+		other func(CompletionInput) CompletionInput
+		equal bool
+	}{
+		{"Same", func(in CompletionInput) CompletionInput { return in }, true},
+		{"EmptySlices", func(in CompletionInput) CompletionInput {
+			in.Tools, in.Stop, in.Extra = []Tool{}, []string{}, map[string]interface{}{}
+			return in
+		}, true},
+		{"TemperatureValue", func(in CompletionInput) CompletionInput {
+			in.Temperature = Float32(0.5)
+			return in
+		}, false},
+		{"ZeroTemperature", func(in CompletionInput) CompletionInput {
+			in.Temperature = Float32(0)
+			return in
+		}, false},
+		{"ToolCallID", func(in CompletionInput) CompletionInput {
+			in.Messages = []ChatMessage{{Role: RoleUser, Content: "Hi", ToolCallID: "call_1"}}
+			return in
+		}, false},
+		{"MessageOrder", func(in CompletionInput) CompletionInput {
+			in.Messages = append([]ChatMessage{{Role: RoleSystem, Content: "Be brief."}}, in.Messages...)
+			return in
+		}, false},
+		{"ProviderOptions", func(in CompletionInput) CompletionInput {
+			in.ProviderOptions.Anthropic.StrictMessageOrder = true
+			return in
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			other := tt.other(base)
+			if got := CompletionInputEqual(base, other); got != tt.equal {
+				t.Errorf("Expected equal %v, got %v", tt.equal, got)
+			}
+			if got := CompletionInputHash(base) == CompletionInputHash(other); got != tt.equal {
+				t.Errorf("Expected equal hashes %v, got %v", tt.equal, got)
+			}
+		})
+	}
+
+	// Equal temperatures behind different pointers
+	a, b := base, base
+	a.Temperature, b.Temperature = Float32(0.7), Float32(0.7)
+	if !CompletionInputEqual(a, b) {
+		t.Error("Expected inputs with equal temperatures to be equal")
+	}
+}
@@ -0,0 +1,188 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Message roles
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool" // The result of a tool call, answering the preceding assistant message
+)
+
+// SystemMessageSeparator separates system messages combined by JoinSystemMessages
+const SystemMessageSeparator = "\n\n"
+
+// ErrInvalidRole is returned by NormalizeRole for a role that is none of the message roles or
+// their aliases
+var ErrInvalidRole = errors.New("invalid message role")
+
+// roleAliases maps lowercase roles to the message roles, including the names other APIs and
+// libraries use for them
+var roleAliases = map[string]string{
+	"system":      RoleSystem,
+	"developer":   RoleSystem,
+	"user":        RoleUser,
+	"human":       RoleUser,
+	"assistant":   RoleAssistant,
+	"model":       RoleAssistant,
+	"ai":          RoleAssistant,
+	"tool":        RoleTool,
+	"function":    RoleTool,
+	"tool_result": RoleTool,
+}
+
+// NormalizeRole returns the message role for role, matched case-insensitively and accepting
+// aliases such as "model" for RoleAssistant or "function" for RoleTool
+func NormalizeRole(role string) (string, error) {
+	if normalized, ok := roleAliases[strings.ToLower(strings.TrimSpace(role))]; ok {
+		return normalized, nil
+	}
+	return "", fmt.Errorf("%w %q; use %q, %q, %q or %q", ErrInvalidRole, role, RoleSystem, RoleUser, RoleAssistant, RoleTool)
+}
+
+// NormalizeRoles returns messages with every role replaced by its message role, as by
+// NormalizeRole. messages is copied only if a role changes.
+func NormalizeRoles(messages []ChatMessage) ([]ChatMessage, error) {
+	normalized := messages
+	copied := false
+	for i, message := range messages {
+		role, err := NormalizeRole(message.Role)
+		if err != nil {
+			return nil, fmt.Errorf("message %d: %w", i, err)
+		}
+		if role == message.Role {
+			continue
+		}
+		if !copied {
+			normalized = append([]ChatMessage(nil), messages...)
+			copied = true
+		}
+		normalized[i].Role = role
+	}
+	return normalized, nil
+}
+
+// ErrInvalidMessageOrder is returned by ValidateMessageOrder and ValidateToolMessages for
+// conversations providers would reject
+var ErrInvalidMessageOrder = errors.New("invalid message order")
+
+// JoinSystemMessages combines the content of every system message, wherever it appears, into one
+// string separated by SystemMessageSeparator, and returns the remaining messages in order.
+// It is used by providers that accept a single system prompt.
+func JoinSystemMessages(messages []ChatMessage) (system string, rest []ChatMessage) {
+	var systemParts []string
+	for _, message := range messages {
+		if message.Role == RoleSystem {
+			systemParts = append(systemParts, message.Content)
+			continue
+		}
+		rest = append(rest, message)
+	}
+	return strings.Join(systemParts, SystemMessageSeparator), rest
+}
+
+// ValidateMessageOrder checks that messages form a conversation every provider accepts: system
+// messages may appear anywhere, and the others must start with a user message and alternate
+// between user and assistant. Tool messages may follow an assistant message or another tool message.
+func ValidateMessageOrder(messages []ChatMessage) error {
+	previous := ""
+	for i, message := range messages {
+		switch message.Role {
+		case RoleSystem:
+			continue
+		case RoleUser, RoleAssistant, RoleTool:
+		default:
+			return fmt.Errorf("%w: message %d has role %q; use %q, %q, %q or %q",
+				ErrInvalidMessageOrder, i, message.Role, RoleSystem, RoleUser, RoleAssistant, RoleTool)
+		}
+
+		switch {
+		case message.Role == RoleTool && previous != RoleAssistant && previous != RoleTool:
+			return fmt.Errorf("%w: message %d is a tool message that doesn't follow an assistant message; add the assistant message with the tool call before it",
+				ErrInvalidMessageOrder, i)
+		case message.Role == RoleTool:
+		case previous == "" && message.Role == RoleAssistant:
+			return fmt.Errorf("%w: message %d is the first non-system message and has role %q; start the conversation with a user message",
+				ErrInvalidMessageOrder, i, message.Role)
+		case message.Role == previous && message.Role == RoleUser:
+			return fmt.Errorf("%w: message %d is a user message following another user message; combine them or add the assistant response between them",
+				ErrInvalidMessageOrder, i)
+		case message.Role == previous:
+			return fmt.Errorf("%w: message %d is an assistant message following another assistant message; combine them or add the user message between them",
+				ErrInvalidMessageOrder, i)
+		}
+		previous = message.Role
+	}
+
+	if previous == "" {
+		return fmt.Errorf("%w: no user message; add at least one message with role %q", ErrInvalidMessageOrder, RoleUser)
+	}
+	return nil
+}
+
+// ValidateToolMessages checks that tool messages are shaped as the providers that call tools
+// require: every tool message answers a call of the assistant message before it, matched by its
+// ToolCallID or, without one, to the next unanswered call; no call is answered twice; and every
+// call is answered before the next user or assistant message. System messages are skipped.
+func ValidateToolMessages(messages []ChatMessage) error {
+	pending := -1 // The index of the assistant message whose calls are being answered
+	var answered []bool
+
+	// unanswered reports a call of the pending assistant message with no result before message i
+	unanswered := func(i int) error {
+		for j, done := range answered {
+			if !done {
+				call := messages[pending].ToolCalls[j]
+				return fmt.Errorf("%w: message %d calls tool %q with ID %q, but no tool message answers it before message %d; add its result with ToolResultMessage",
+					ErrInvalidMessageOrder, pending, call.Name, call.ID, i)
+			}
+		}
+		return nil
+	}
+
+	for i, message := range messages {
+		switch message.Role {
+		case RoleSystem:
+			continue
+		case RoleTool:
+			if pending < 0 {
+				return fmt.Errorf("%w: message %d is a tool result that doesn't follow an assistant message with tool calls; add the assistant message with the call, made with ToolCallMessage, before it",
+					ErrInvalidMessageOrder, i)
+			}
+			calls := messages[pending].ToolCalls
+			index := -1
+			for j, call := range calls {
+				if message.ToolCallID == "" && !answered[j] || message.ToolCallID != "" && call.ID == message.ToolCallID {
+					index = j
+					break
+				}
+			}
+			switch {
+			case index < 0 && message.ToolCallID == "":
+				return fmt.Errorf("%w: message %d is a tool result, but every call of message %d is already answered",
+					ErrInvalidMessageOrder, i, pending)
+			case index < 0:
+				return fmt.Errorf("%w: message %d answers tool call %q, which message %d didn't make",
+					ErrInvalidMessageOrder, i, message.ToolCallID, pending)
+			case answered[index]:
+				return fmt.Errorf("%w: message %d answers tool call %q a second time",
+					ErrInvalidMessageOrder, i, message.ToolCallID)
+			}
+			answered[index] = true
+		default:
+			if err := unanswered(i); err != nil {
+				return err
+			}
+			pending, answered = -1, nil
+			if message.Role == RoleAssistant && len(message.ToolCalls) > 0 {
+				pending, answered = i, make([]bool, len(message.ToolCalls))
+			}
+		}
+	}
+	return unanswered(len(messages))
+}
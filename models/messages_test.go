@@ -0,0 +1,145 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestJoinSystemMessages(t *testing.T) {
+	messages := []ChatMessage{
+		{Role: RoleSystem, Content: "Use the search tool for facts."},
+		{Role: RoleUser, Content: "Hi"},
+		{Role: RoleSystem, Content: "Answer as a pirate."},
+		{Role: RoleAssistant, Content: "Ahoy"},
+	}
+
+	system, rest := JoinSystemMessages(messages)
+	if want := "Use the search tool for facts.\n\nAnswer as a pirate."; system != want {
+		t.Errorf("Expected system %q, got %q", want, system)
+	}
+	if len(rest) != 2 || rest[0].Role != RoleUser || rest[1].Role != RoleAssistant {
+		t.Errorf("Unexpected remaining messages: %+v", rest)
+	}
+}
+
+func TestValidateMessageOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []ChatMessage
+		valid    bool
+	}{
+		{"SingleUser", []ChatMessage{{Role: RoleUser}}, true},
+		{"SystemAnywhere", []ChatMessage{{Role: RoleSystem}, {Role: RoleUser}, {Role: RoleSystem}, {Role: RoleAssistant}, {Role: RoleUser}}, true},
+		{"Empty", nil, false},
+		{"OnlySystem", []ChatMessage{{Role: RoleSystem}}, false},
+		{"StartsWithAssistant", []ChatMessage{{Role: RoleSystem}, {Role: RoleAssistant}, {Role: RoleUser}}, false},
+		{"ConsecutiveUser", []ChatMessage{{Role: RoleUser}, {Role: RoleSystem}, {Role: RoleUser}}, false},
+		{"ConsecutiveAssistant", []ChatMessage{{Role: RoleUser}, {Role: RoleAssistant}, {Role: RoleAssistant}}, false},
+		{"ToolResults", []ChatMessage{{Role: RoleUser}, {Role: RoleAssistant}, {Role: RoleTool}, {Role: RoleTool}, {Role: RoleAssistant}}, true},
+		{"ToolAfterUser", []ChatMessage{{Role: RoleUser}, {Role: RoleTool}}, false},
+		{"UnknownRole", []ChatMessage{{Role: "function"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateMessageOrder(tt.messages)
+			if tt.valid && err != nil {
+				t.Errorf("Expected valid messages, got %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidMessageOrder) {
+				t.Errorf("Expected ErrInvalidMessageOrder, got %v", err)
+			}
+		})
+	}
+}
+
+func TestNormalizeRole(t *testing.T) {
+	tests := []struct {
+		role string
+		want string
+	}{
+		{"user", RoleUser},
+		{"System", RoleSystem},
+		{" ASSISTANT ", RoleAssistant},
+		{"model", RoleAssistant},
+		{"Human", RoleUser},
+		{"developer", RoleSystem},
+		{"function", RoleTool},
+		{"tool_result", RoleTool},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeRole(tt.role)
+		if err != nil || got != tt.want {
+			t.Errorf("NormalizeRole(%q) = %q, %v; expected %q", tt.role, got, err, tt.want)
+		}
+	}
+
+	_, err := NormalizeRole("narrator")
+	if !errors.Is(err, ErrInvalidRole) {
+		t.Fatalf("Expected ErrInvalidRole, got %v", err)
+	}
+	for _, role := range []string{RoleSystem, RoleUser, RoleAssistant, RoleTool} {
+		if !strings.Contains(err.Error(), role) {
+			t.Errorf("Expected the error to list %q, got %v", role, err)
+		}
+	}
+}
+
+func TestNormalizeRoles(t *testing.T) {
+	canonical := []ChatMessage{{Role: RoleSystem}, {Role: RoleUser}}
+	if got, err := NormalizeRoles(canonical); err != nil || &got[0] != &canonical[0] {
+		t.Errorf("Expected canonical messages to be returned as they are, got %v", err)
+	}
+
+	messages := []ChatMessage{{Role: "System", Content: "Be brief."}, {Role: "User", Content: "Hi"}}
+	got, err := NormalizeRoles(messages)
+	if err != nil {
+		t.Fatalf("NormalizeRoles failed: %v", err)
+	}
+	if got[0].Role != RoleSystem || got[1].Role != RoleUser || got[1].Content != "Hi" {
+		t.Errorf("Unexpected normalized messages %+v", got)
+	}
+	if messages[0].Role != "System" {
+		t.Error("NormalizeRoles modified the caller's messages")
+	}
+
+	if _, err := NormalizeRoles([]ChatMessage{{Role: RoleUser}, {Role: "bot"}}); !errors.Is(err, ErrInvalidRole) || !strings.Contains(err.Error(), "message 1") {
+		t.Errorf("Expected ErrInvalidRole for message 1, got %v", err)
+	}
+}
+
+func TestValidateToolMessages(t *testing.T) {
+	calls := []ToolCall{{ID: "call_1", Name: "get_weather"}, {ID: "call_2", Name: "get_time"}}
+	user := ChatMessage{Role: RoleUser, Content: "Weather and time in Paris?"}
+	answer := ChatMessage{Role: RoleAssistant, Content: "Sunny, 3pm."}
+	tests := []struct {
+		name     string
+		messages []ChatMessage
+		valid    bool
+	}{
+		{"NoTools", []ChatMessage{user, answer}, true},
+		{"Answered", []ChatMessage{user, ToolCallMessage("", calls), ToolResultMessage("call_1", "sunny"), ToolResultMessage("call_2", "3pm"), answer}, true},
+		{"AnsweredOutOfOrder", []ChatMessage{user, ToolCallMessage("", calls), ToolResultMessage("call_2", "3pm"), ToolResultMessage("call_1", "sunny")}, true},
+		{"AnsweredInOrderWithoutIDs", []ChatMessage{user, ToolCallMessage("", calls), ToolResultMessage("", "sunny"), ToolResultMessage("", "3pm")}, true},
+		{"SystemBetween", []ChatMessage{user, ToolCallMessage("", calls[:1]), {Role: RoleSystem}, ToolResultMessage("call_1", "sunny")}, true},
+		{"ResultWithoutCall", []ChatMessage{user, ToolResultMessage("call_1", "sunny")}, false},
+		{"ResultAfterPlainAssistant", []ChatMessage{user, answer, ToolResultMessage("call_1", "sunny")}, false},
+		{"UnknownID", []ChatMessage{user, ToolCallMessage("", calls[:1]), ToolResultMessage("call_9", "sunny")}, false},
+		{"AnsweredTwice", []ChatMessage{user, ToolCallMessage("", calls), ToolResultMessage("call_1", "sunny"), ToolResultMessage("call_1", "rain"), ToolResultMessage("call_2", "3pm")}, false},
+		{"ExtraResultWithoutID", []ChatMessage{user, ToolCallMessage("", calls[:1]), ToolResultMessage("", "sunny"), ToolResultMessage("", "3pm")}, false},
+		{"UnansweredBeforeUser", []ChatMessage{user, ToolCallMessage("", calls), ToolResultMessage("call_1", "sunny"), user}, false},
+		{"UnansweredAtEnd", []ChatMessage{user, ToolCallMessage("", calls)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateToolMessages(tt.messages)
+			if tt.valid && err != nil {
+				t.Errorf("Expected valid messages, got %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidMessageOrder) {
+				t.Errorf("Expected ErrInvalidMessageOrder, got %v", err)
+			}
+		})
+	}
+}
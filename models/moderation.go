@@ -0,0 +1,23 @@
+package models
+
+import "sort"
+
+// ModerationResult represents a provider's content policy assessment of a text.
+type ModerationResult struct {
+	Flagged        bool               // Whether any category was flagged
+	Categories     map[string]bool    // Whether each category, e.g. "harassment" or "violence", was flagged
+	CategoryScores map[string]float64 // The model's confidence for each category, from 0 to 1
+	Provider       string
+}
+
+// FlaggedCategories returns the names of the flagged categories in sorted order.
+func (r *ModerationResult) FlaggedCategories() []string {
+	var flagged []string
+	for category, isFlagged := range r.Categories {
+		if isFlagged {
+			flagged = append(flagged, category)
+		}
+	}
+	sort.Strings(flagged)
+	return flagged
+}
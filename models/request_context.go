@@ -0,0 +1,84 @@
+package models
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// ContextKey is the type of the context keys holding per-request options
+type ContextKey string
+
+// Context keys for per-request options. Use the With functions below to set them, so that values
+// set by earlier middleware are merged rather than replaced.
+const (
+	ContextKeyRequestHeaders ContextKey = "gollm.request_headers"
+	ContextKeyRequestTimeout ContextKey = "gollm.request_timeout"
+	ContextKeyRequestID      ContextKey = "gollm.request_id"
+)
+
+// RequestIDHeader is the HTTP header carrying the request ID set with WithRequestID
+const RequestIDHeader = "X-Request-ID"
+
+// WithRequestHeaders returns a context whose provider requests carry headers in addition to any
+// headers already in ctx. Later values for the same header replace earlier ones.
+func WithRequestHeaders(ctx context.Context, headers http.Header) context.Context {
+	merged := RequestHeaders(ctx).Clone()
+	if merged == nil {
+		merged = http.Header{}
+	}
+	for name, values := range headers {
+		merged[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+	}
+	return context.WithValue(ctx, ContextKeyRequestHeaders, merged)
+}
+
+// RequestHeaders returns the headers set with WithRequestHeaders, or nil. It must not be modified.
+func RequestHeaders(ctx context.Context) http.Header {
+	headers, _ := ctx.Value(ContextKeyRequestHeaders).(http.Header)
+	return headers
+}
+
+// WithRequestTimeout returns a context whose provider requests, including reading a streamed
+// response, are limited to d. It overrides the timeout of the provider's HTTP client when shorter.
+func WithRequestTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, ContextKeyRequestTimeout, d)
+}
+
+// RequestTimeout returns the timeout set with WithRequestTimeout
+func RequestTimeout(ctx context.Context) (time.Duration, bool) {
+	d, ok := ctx.Value(ContextKeyRequestTimeout).(time.Duration)
+	return d, ok && d > 0
+}
+
+// WithRequestID returns a context whose provider requests carry id in the X-Request-ID header
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ContextKeyRequestID, id)
+}
+
+// RequestID returns the request ID set with WithRequestID, or ""
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(ContextKeyRequestID).(string)
+	return id
+}
+
+// ApplyRequestTimeout returns ctx limited by the timeout set with WithRequestTimeout, if any.
+// Providers call it before building a request, and call cancel once the response is consumed.
+func ApplyRequestTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if d, ok := RequestTimeout(ctx); ok {
+		return context.WithTimeout(ctx, d)
+	}
+	return ctx, func() {}
+}
+
+// ApplyRequestHeaders adds the headers and request ID from the request's context to req.
+// Providers call it after setting their own headers, so context headers take precedence.
+func ApplyRequestHeaders(req *http.Request) {
+	ctx := req.Context()
+	for name, values := range RequestHeaders(ctx) {
+		req.Header[name] = append([]string(nil), values...)
+	}
+	if id := RequestID(ctx); id != "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
+}
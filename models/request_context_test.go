@@ -0,0 +1,47 @@
+package models
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRequestContext(t *testing.T) {
+	ctx := context.Background()
+	ctx = WithRequestHeaders(ctx, http.Header{"X-Tenant": {"acme"}, "x-trace": {"1"}})
+	ctx = WithRequestHeaders(ctx, http.Header{"X-Trace": {"2"}})
+	ctx = WithRequestID(ctx, "req-42")
+
+	req, err := http.NewRequestWithContext(ctx, "POST", "http://example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer key")
+	ApplyRequestHeaders(req)
+
+	if got := req.Header.Get("X-Tenant"); got != "acme" {
+		t.Errorf("Expected X-Tenant from the first middleware, got %q", got)
+	}
+	if got := req.Header.Values("X-Trace"); len(got) != 1 || got[0] != "2" {
+		t.Errorf("Expected the later X-Trace to replace the earlier one, got %q", got)
+	}
+	if got := req.Header.Get(RequestIDHeader); got != "req-42" {
+		t.Errorf("Expected the request ID header, got %q", got)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer key" {
+		t.Errorf("Expected the provider's headers to be kept, got %q", got)
+	}
+
+	if _, ok := RequestTimeout(ctx); ok {
+		t.Error("Expected no timeout")
+	}
+	if _, cancel := ApplyRequestTimeout(ctx); cancel == nil {
+		t.Error("Expected a cancel func without a timeout")
+	}
+	timeoutCtx, cancel := ApplyRequestTimeout(WithRequestTimeout(ctx, time.Minute))
+	defer cancel()
+	if deadline, ok := timeoutCtx.Deadline(); !ok || time.Until(deadline) > time.Minute {
+		t.Errorf("Expected a deadline within a minute, got %v", deadline)
+	}
+}
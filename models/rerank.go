@@ -0,0 +1,23 @@
+package models
+
+// RerankInput represents the input for a rerank request.
+type RerankInput struct {
+	Model     string // In "provider/model" form when given to the client
+	Query     string
+	Documents []string
+	TopN      int // The number of results to return; zero returns every document
+}
+
+// RerankResult is a document scored against the query of a rerank request.
+type RerankResult struct {
+	Index          int    // The document's index in RerankInput.Documents
+	Document       string // The document's text
+	RelevanceScore float64
+}
+
+// RerankResponse represents the response from a rerank request.
+type RerankResponse struct {
+	Results  []RerankResult // Ordered from the most to the least relevant
+	Model    string
+	Provider string
+}
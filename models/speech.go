@@ -0,0 +1,17 @@
+package models
+
+// SpeechInput represents the input for a text-to-speech request.
+type SpeechInput struct {
+	Text           string
+	Model          string  // Defaults to "tts-1" for OpenAI
+	Voice          string  // alloy, echo, fable, onyx, nova or shimmer
+	ResponseFormat string  // mp3, opus, aac or flac; the provider default is used when empty
+	Speed          float32 // The provider default is used when zero
+}
+
+// SpeechResponse represents the audio returned by a text-to-speech request.
+type SpeechResponse struct {
+	Audio       []byte
+	ContentType string
+	Provider    string
+}
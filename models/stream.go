@@ -0,0 +1,52 @@
+package models
+
+import "strings"
+
+// StreamAccumulator collects the chunks of a stream into its response, for callers that print
+// or forward chunks as they arrive and need the whole text and usage at the end:
+//
+//	var acc models.StreamAccumulator
+//	for chunk := range stream {
+//		fmt.Print(acc.Add(chunk))
+//	}
+//	text, usage, finishReason := acc.Result()
+//
+// Chunks follow the streaming contract documented on StreamingCompletionResponse, but some
+// providers outside this module repeat the whole text on the Done chunk; a Done chunk whose
+// Text equals the text so far is taken as such a repeat and not added again.
+// The zero value is ready to use.
+type StreamAccumulator struct {
+	text         strings.Builder
+	usage        *Usage
+	finishReason string
+	err          error
+}
+
+// Add adds chunk to the response and returns the text it contributes
+func (a *StreamAccumulator) Add(chunk StreamingCompletionResponse) string {
+	if chunk.Usage != nil {
+		a.usage = chunk.Usage
+	}
+	if chunk.FinishReason != "" {
+		a.finishReason = chunk.FinishReason
+	}
+	if chunk.Error != nil {
+		a.err = chunk.Error
+	}
+	if chunk.Done && chunk.Text != "" && chunk.Text == a.text.String() {
+		return ""
+	}
+	a.text.WriteString(chunk.Text)
+	return chunk.Text
+}
+
+// Result returns the text of the chunks added so far, and the usage and finish reason of the
+// last chunks reporting them
+func (a *StreamAccumulator) Result() (text string, usage *Usage, finishReason string) {
+	return a.text.String(), a.usage, a.finishReason
+}
+
+// Err returns the Error of the last chunk that had one, which ended the stream
+func (a *StreamAccumulator) Err() error {
+	return a.err
+}
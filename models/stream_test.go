@@ -0,0 +1,85 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStreamAccumulator(t *testing.T) {
+	usage := &Usage{PromptTokens: 5, CompletionTokens: 3, TotalTokens: 8}
+	failure := errors.New("connection reset")
+	tests := []struct {
+		name         string
+		chunks       []StreamingCompletionResponse
+		text         string
+		added        string
+		usage        *Usage
+		finishReason string
+		err          error
+	}{
+		{
+			name:   "Empty",
+			chunks: nil,
+		},
+		{
+			name: "Deltas",
+			chunks: []StreamingCompletionResponse{
+				{Text: "Hello"},
+				{Text: ", "},
+				{Text: "world", Done: true, Usage: usage, FinishReason: "stop"},
+			},
+			text: "Hello, world", added: "Hello, world", usage: usage, finishReason: "stop",
+		},
+		{
+			name: "EmptyDone",
+			chunks: []StreamingCompletionResponse{
+				{Text: "Hello"},
+				{Done: true, Usage: usage},
+			},
+			text: "Hello", added: "Hello", usage: usage,
+		},
+		{
+			name: "FullTextOnDone",
+			chunks: []StreamingCompletionResponse{
+				{Text: "Hello"},
+				{Text: " world"},
+				{Text: "Hello world", Done: true, FinishReason: "length"},
+			},
+			text: "Hello world", added: "Hello world", finishReason: "length",
+		},
+		{
+			name: "OnlyDone",
+			chunks: []StreamingCompletionResponse{
+				{Text: "Hi", Done: true},
+			},
+			text: "Hi", added: "Hi",
+		},
+		{
+			name: "Error",
+			chunks: []StreamingCompletionResponse{
+				{Text: "Once"},
+				{Text: " upon", Done: true, Error: failure},
+			},
+			text: "Once upon", added: "Once upon", err: failure,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var acc StreamAccumulator
+			var added string
+			for _, chunk := range tt.chunks {
+				added += acc.Add(chunk)
+			}
+			text, usage, finishReason := acc.Result()
+			if text != tt.text || added != tt.added {
+				t.Errorf("Expected text %q and added text %q, got %q and %q", tt.text, tt.added, text, added)
+			}
+			if usage != tt.usage || finishReason != tt.finishReason {
+				t.Errorf("Expected usage %+v and finish reason %q, got %+v and %q", tt.usage, tt.finishReason, usage, finishReason)
+			}
+			if !errors.Is(acc.Err(), tt.err) {
+				t.Errorf("Expected error %v, got %v", tt.err, acc.Err())
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Tool describes a function the model may call
+type Tool struct {
+	Name        string
+	Description string
+	// Parameters is a JSON Schema object describing the function's arguments
+	Parameters json.RawMessage
+}
+
+// ToolCall is a request from the model to call a tool
+type ToolCall struct {
+	ID        string          // Identifies the call; echo it in the ToolCallID of the result message
+	Name      string          // The name of the tool
+	Arguments json.RawMessage // The arguments as a JSON object, as generated by the model
+}
+
+// ToolCallMessage returns the assistant message in which the model made calls, with the text it
+// generated alongside them. Send it back before the results of the calls.
+func ToolCallMessage(text string, calls []ToolCall) ChatMessage {
+	return ChatMessage{Role: RoleAssistant, Content: text, ToolCalls: calls}
+}
+
+// ToolResultMessage returns the tool message answering the call with the given ID with content,
+// the tool's output. It must follow the assistant message that made the call, together with the
+// results of that message's other calls. Each provider converts it to its own format, such as
+// a tool_result block in a user message for Anthropic.
+func ToolResultMessage(id, content string) ChatMessage {
+	return ChatMessage{Role: RoleTool, Content: content, ToolCallID: id}
+}
+
+// ToolResult is the outcome of a tool call, returned by a client.ToolExecutor
+type ToolResult struct {
+	ToolCallID string // The ID of the call answered
+	Content    string // The tool's output, or the error message if IsError is set
+	IsError    bool
+}
+
+// Message returns the tool message sending the result to the model
+func (r ToolResult) Message() ChatMessage {
+	message := ToolResultMessage(r.ToolCallID, r.Content)
+	message.IsError = r.IsError
+	return message
+}
+
+// ToolCallDelta is a fragment of a tool call in a stream. The ID and Name arrive with the first
+// fragment of a call, and Arguments carries the next piece of its JSON arguments. Index tells
+// apart calls made in parallel, whose fragments may interleave.
+type ToolCallDelta struct {
+	Index     int
+	ID        string
+	Name      string
+	Arguments string
+}
+
+// Tool choice types
+const (
+	ToolChoiceAuto = "auto" // The model decides whether to call a tool
+	ToolChoiceAny  = "any"  // The model must call at least one tool
+	ToolChoiceNone = "none" // The model must not call a tool
+	ToolChoiceTool = "tool" // The model must call the tool named by ToolChoice.Name
+)
+
+// ToolChoice controls whether and which tools the model calls. When tools are offered and no
+// choice is set, providers use ToolChoiceAuto.
+//
+// OpenAI calls ToolChoiceAny "required". Google Gemini is not supported, as the genai SDK version
+// this module uses has no tool configuration.
+type ToolChoice struct {
+	Type string // One of the ToolChoice constants
+	Name string // The tool to call, for ToolChoiceTool
+}
+
+// Validate reports whether the choice has a known type, and a name when one is required
+func (c ToolChoice) Validate() error {
+	switch c.Type {
+	case ToolChoiceAuto, ToolChoiceAny, ToolChoiceNone:
+		return nil
+	case ToolChoiceTool:
+		if c.Name == "" {
+			return errors.New("tool choice \"tool\" requires a tool name")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown tool choice type %q", c.Type)
+	}
+}
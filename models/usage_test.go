@@ -0,0 +1,70 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUsageAdd(t *testing.T) {
+	tests := []struct {
+		name  string
+		u     Usage
+		other *Usage
+		want  Usage
+	}{
+		{
+			"NilOther",
+			Usage{PromptTokens: 5, TotalTokens: 5},
+			nil,
+			Usage{PromptTokens: 5, TotalTokens: 5},
+		},
+		{
+			"WithoutDetails",
+			Usage{PromptTokens: 5, CompletionTokens: 2, TotalTokens: 7},
+			&Usage{PromptTokens: 3, CompletionTokens: 1, TotalTokens: 4, Estimated: true},
+			Usage{PromptTokens: 8, CompletionTokens: 3, TotalTokens: 11, Estimated: true},
+		},
+		{
+			"DetailsOnOther",
+			Usage{PromptTokens: 5, TotalTokens: 5},
+			&Usage{PromptTokens: 10, TotalTokens: 10, Details: &UsageDetails{CacheReadTokens: 8}},
+			Usage{PromptTokens: 15, TotalTokens: 15, Details: &UsageDetails{CacheReadTokens: 8}},
+		},
+		{
+			"DetailsOnReceiver",
+			Usage{CompletionTokens: 20, TotalTokens: 20, Details: &UsageDetails{ReasoningTokens: 12}},
+			&Usage{CompletionTokens: 4, TotalTokens: 4},
+			Usage{CompletionTokens: 24, TotalTokens: 24, Details: &UsageDetails{ReasoningTokens: 12}},
+		},
+		{
+			"DetailsOnBoth",
+			Usage{PromptTokens: 30, TotalTokens: 30, Details: &UsageDetails{ReasoningTokens: 1, CacheReadTokens: 2, CacheWriteTokens: 3, AudioTokens: 4}},
+			&Usage{PromptTokens: 40, TotalTokens: 40, Details: &UsageDetails{ReasoningTokens: 10, CacheReadTokens: 20, CacheWriteTokens: 30, AudioTokens: 40}},
+			Usage{PromptTokens: 70, TotalTokens: 70, Details: &UsageDetails{ReasoningTokens: 11, CacheReadTokens: 22, CacheWriteTokens: 33, AudioTokens: 44}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.u.Add(tt.other)
+			if !reflect.DeepEqual(tt.u, tt.want) {
+				t.Errorf("Expected %+v, got %+v", tt.want, tt.u)
+			}
+		})
+	}
+}
+
+func TestUsageAddCopiesDetails(t *testing.T) {
+	shared := &UsageDetails{CacheReadTokens: 8}
+	first := Usage{Details: shared}
+	second := Usage{Details: shared}
+
+	var total Usage
+	total.Add(&first)
+	total.Add(&second)
+	if total.Details == shared || total.Details.CacheReadTokens != 16 {
+		t.Errorf("Expected a new Details with 16 cache reads, got %+v", total.Details)
+	}
+	if shared.CacheReadTokens != 8 {
+		t.Errorf("Expected the shared Details unchanged, got %+v", shared)
+	}
+}
@@ -1,7 +1,6 @@
 package anthropic
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -9,154 +8,366 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
+	"net/url"
+	"strings"
 	"time"
 
+	"github.com/1broseidon/gollm/internal/credentials"
+	"github.com/1broseidon/gollm/internal/utils"
 	"github.com/1broseidon/gollm/models"
 )
 
+// defaultBaseURL is the Anthropic API endpoint used unless WithBaseURL is given
+const defaultBaseURL = "https://api.anthropic.com"
+
+// DefaultMaxTokens is sent as max_tokens, which the Messages API requires, when
+// CompletionInput.MaxTokens is zero. With extended thinking, the thinking budget is added to it.
+const DefaultMaxTokens = 1024
+
 // AnthropicProvider implements the Anthropic-specific functionality
 type AnthropicProvider struct {
-	apiKey string
-	client *http.Client
+	apiKey          string
+	baseURL         string
+	client          *http.Client
+	transportConfig AnthropicTransportConfig
+	bodyLimit       int64
 }
 
-// NewAnthropicProvider creates a new Anthropic provider
-func NewAnthropicProvider() (*AnthropicProvider, error) {
-	apiKey := os.Getenv("ANTHROPIC_API_KEY")
-	if apiKey == "" {
-		return nil, fmt.Errorf("ANTHROPIC_API_KEY environment variable is not set")
+// AnthropicOption configures an AnthropicProvider
+type AnthropicOption func(*AnthropicProvider)
+
+// WithHTTPClient sets the HTTP client used for API requests
+func WithHTTPClient(client *http.Client) AnthropicOption {
+	return func(p *AnthropicProvider) {
+		p.client = client
 	}
+}
 
-	return &AnthropicProvider{
-		apiKey: apiKey,
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-	}, nil
+// WithAPIKey sets the API key, overriding ANTHROPIC_API_KEY and the credentials file
+func WithAPIKey(apiKey string) AnthropicOption {
+	return func(p *AnthropicProvider) {
+		p.apiKey = apiKey
+	}
+}
+
+// WithBaseURL sets the API endpoint, e.g. for a proxy or a test server
+func WithBaseURL(baseURL string) AnthropicOption {
+	return func(p *AnthropicProvider) {
+		p.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithResponseBodyLimit caps the size of non-streaming response bodies at limit bytes, failing
+// with models.ErrResponseTooLarge beyond it. The default is models.DefaultResponseBodyLimit;
+// zero or less disables the limit. Batch results are decoded one line at a time and aren't limited.
+func WithResponseBodyLimit(limit int64) AnthropicOption {
+	return func(p *AnthropicProvider) {
+		p.bodyLimit = limit
+	}
+}
+
+// NewAnthropicProvider creates a new Anthropic provider. Unless WithAPIKey is given, the API key is
+// read from ANTHROPIC_API_KEY, or from anthropic_api_key in ~/.config/gollm/credentials.
+func NewAnthropicProvider(opts ...AnthropicOption) (*AnthropicProvider, error) {
+	p := &AnthropicProvider{
+		baseURL:         defaultBaseURL,
+		transportConfig: DefaultAnthropicTransportConfig(),
+		bodyLimit:       models.DefaultResponseBodyLimit,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.client == nil {
+		p.client = &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: p.transportConfig.Transport(),
+		}
+	}
+
+	if p.apiKey == "" {
+		p.apiKey = credentials.Lookup("ANTHROPIC_API_KEY")
+	}
+	if p.apiKey == "" {
+		return nil, fmt.Errorf("ANTHROPIC_API_KEY is not set in the environment or the credentials file")
+	}
+
+	return p, nil
 }
 
 // GenerateCompletion generates a completion using the specified Anthropic model
 func (p *AnthropicProvider) GenerateCompletion(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
-	url := "https://api.anthropic.com/v1/messages"
+	ctx, cancel := models.ApplyRequestTimeout(ctx)
+	defer cancel()
 
-	requestBody := struct {
-		Model     string               `json:"model"`
-		Messages  []models.ChatMessage `json:"messages"`
-		MaxTokens int                  `json:"max_tokens"`
-	}{
-		Model:     modelName,
-		Messages:  input.Messages,
-		MaxTokens: input.MaxTokens,
-	}
+	url := p.baseURL + "/v1/messages"
 
-	jsonBody, err := json.Marshal(requestBody)
+	request, err := newMessageRequest(modelName, input)
 	if err != nil {
 		return nil, err
 	}
-
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonBody))
+	jsonBody, err := utils.MarshalWithExtra(request, input.Extra)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-api-key", p.apiKey)
-	req.Header.Set("anthropic-version", "2023-06-01")
+	req, err := p.newRequest(ctx, "POST", url, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, err
+	}
+	setBetaHeader(req, input.ProviderOptions.Anthropic)
 
 	resp, err := p.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	resp.Body = utils.LimitBody(resp.Body, p.bodyLimit)
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
 		return nil, fmt.Errorf("API request failed with status code: %d, body: %s", resp.StatusCode, string(bodyBytes))
 	}
 
-	var result struct {
-		Content []struct {
-			Text string `json:"text"`
-		} `json:"content"`
-		Usage struct {
-			InputTokens  int `json:"input_tokens"`
-			OutputTokens int `json:"output_tokens"`
-		} `json:"usage"`
-	}
-
+	var result message
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, err
 	}
+	if result.Model == "" {
+		result.Model = modelName
+	}
+
+	return result.completionResponse()
+}
 
-	if len(result.Content) == 0 {
+// messageRequest is the body of a non-streaming Messages API request
+type messageRequest struct {
+	Model         string           `json:"model"`
+	System        string           `json:"system,omitempty"`
+	Messages      []apiMessage     `json:"messages"`
+	MaxTokens     int              `json:"max_tokens"`
+	Temperature   *float32         `json:"temperature,omitempty"`
+	TopP          *float32         `json:"top_p,omitempty"`
+	StopSequences []string         `json:"stop_sequences,omitempty"`
+	Thinking      *thinkingConfig  `json:"thinking,omitempty"`
+	Tools         []toolDefinition `json:"tools,omitempty"`
+	ToolChoice    *toolChoice      `json:"tool_choice,omitempty"`
+}
+
+// newMessageRequest builds the Messages API request for input. The API takes a single system
+// prompt, so system messages are combined into it.
+func newMessageRequest(modelName string, input models.CompletionInput) (messageRequest, error) {
+	choice, err := newToolChoice(input.ToolChoice, input.Tools)
+	if err != nil {
+		return messageRequest{}, err
+	}
+	if err := validateContentParts(input.Messages); err != nil {
+		return messageRequest{}, err
+	}
+	tools, err := newTools(input)
+	if err != nil {
+		return messageRequest{}, err
+	}
+	system, messages := models.JoinSystemMessages(input.Messages)
+	return messageRequest{
+		Model:         modelName,
+		System:        system,
+		Messages:      requestMessages(messages, input.ProviderOptions.Anthropic),
+		MaxTokens:     maxTokens(input),
+		Temperature:   input.Temperature,
+		TopP:          input.TopP,
+		StopSequences: input.Stop,
+		Thinking:      newThinkingConfig(input.ProviderOptions.Anthropic),
+		Tools:         tools,
+		ToolChoice:    choice,
+	}, nil
+}
+
+// message is a response from the Messages API
+type message struct {
+	Model   string `json:"model"` // The model that generated the message
+	Content []struct {
+		Type      string          `json:"type"`
+		Text      string          `json:"text"`
+		Thinking  string          `json:"thinking"`
+		ID        string          `json:"id"`
+		Name      string          `json:"name"`
+		Input     json.RawMessage `json:"input"`
+		Citations []citation      `json:"citations"`
+	} `json:"content"`
+	Usage struct {
+		InputTokens              int `json:"input_tokens"`
+		OutputTokens             int `json:"output_tokens"`
+		CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+		CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	} `json:"usage"`
+}
+
+// completionResponse converts the message, separating thinking blocks from the text. The
+// citations of each text block are located in the joined text.
+func (m *message) completionResponse() (*models.CompletionResponse, error) {
+	if len(m.Content) == 0 {
 		return nil, errors.New("no content in response")
 	}
 
-	response := &models.CompletionResponse{
-		Text: result.Content[0].Text,
-		Usage: &models.Usage{
-			PromptTokens:     result.Usage.InputTokens,
-			CompletionTokens: result.Usage.OutputTokens,
-			TotalTokens:      result.Usage.InputTokens + result.Usage.OutputTokens,
-		},
+	var text, thinkingText strings.Builder
+	var toolCalls []models.ToolCall
+	var citations []models.Citation
+	for _, block := range m.Content {
+		switch block.Type {
+		case "thinking":
+			thinkingText.WriteString(block.Thinking)
+		case "tool_use":
+			toolCalls = append(toolCalls, models.ToolCall{ID: block.ID, Name: block.Name, Arguments: block.Input})
+		case "text", "":
+			start := text.Len()
+			text.WriteString(block.Text)
+			for _, c := range block.Citations {
+				citations = append(citations, c.model(start, text.Len()))
+			}
+		}
 	}
 
-	return response, nil
+	usage := &models.Usage{
+		PromptTokens:     m.Usage.InputTokens,
+		CompletionTokens: m.Usage.OutputTokens,
+		TotalTokens:      m.Usage.InputTokens + m.Usage.OutputTokens,
+		Details:          usageDetails(models.UsageDetails{CacheReadTokens: m.Usage.CacheReadInputTokens, CacheWriteTokens: m.Usage.CacheCreationInputTokens}),
+	}
+	return &models.CompletionResponse{
+		Text:         text.String(),
+		ThinkingText: thinkingText.String(),
+		ToolCalls:    toolCalls,
+		Citations:    citations,
+		Model:        m.Model,
+		Usage:        usage,
+	}, nil
+}
+
+// usageDetails returns the Details of a usage with the cache counts of details, or nil if
+// there are none
+func usageDetails(details models.UsageDetails) *models.UsageDetails {
+	if details.IsZero() {
+		return nil
+	}
+	return &details
+}
+
+// updateStreamUsage copies the token counts present in the usage of a stream event. message_start
+// reports the input and cache counts; message_delta reports the output count and, in newer API
+// versions, the cumulative input and cache counts too. Absent counts are left unchanged.
+func updateStreamUsage(accumulated *models.Usage, usage map[string]interface{}) {
+	// The details are copied, so those of a usage already passed on are left unchanged
+	var details models.UsageDetails
+	if accumulated.Details != nil {
+		details = *accumulated.Details
+	}
+	fields := map[string]*int{
+		"input_tokens":                &accumulated.PromptTokens,
+		"output_tokens":               &accumulated.CompletionTokens,
+		"cache_read_input_tokens":     &details.CacheReadTokens,
+		"cache_creation_input_tokens": &details.CacheWriteTokens,
+	}
+	for name, count := range fields {
+		if value, ok := usage[name].(float64); ok {
+			*count = int(value)
+		}
+	}
+	accumulated.TotalTokens = accumulated.PromptTokens + accumulated.CompletionTokens
+	accumulated.Details = usageDetails(details)
 }
 
 // GenerateCompletionStream generates a streaming completion using the specified Anthropic model
 func (p *AnthropicProvider) GenerateCompletionStream(ctx context.Context, modelName string, input models.CompletionInput) (<-chan models.StreamingCompletionResponse, error) {
-	url := "https://api.anthropic.com/v1/messages"
+	url := p.baseURL + "/v1/messages"
 
+	choice, err := newToolChoice(input.ToolChoice, input.Tools)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateContentParts(input.Messages); err != nil {
+		return nil, err
+	}
+	tools, err := newTools(input)
+	if err != nil {
+		return nil, err
+	}
+
+	system, messages := models.JoinSystemMessages(input.Messages)
 	requestBody := map[string]interface{}{
 		"model":      modelName,
-		"messages":   input.Messages,
-		"max_tokens": input.MaxTokens,
+		"messages":   requestMessages(messages, input.ProviderOptions.Anthropic),
+		"max_tokens": maxTokens(input),
 		"stream":     true,
 	}
+	if system != "" {
+		requestBody["system"] = system
+	}
+	if input.Temperature != nil {
+		requestBody["temperature"] = *input.Temperature
+	}
+	if input.TopP != nil {
+		requestBody["top_p"] = *input.TopP
+	}
+	if len(input.Stop) > 0 {
+		requestBody["stop_sequences"] = input.Stop
+	}
+	if tools != nil {
+		requestBody["tools"] = tools
+		if choice != nil {
+			requestBody["tool_choice"] = choice
+		}
+	}
+	if thinking := newThinkingConfig(input.ProviderOptions.Anthropic); thinking != nil {
+		requestBody["thinking"] = thinking
+	}
 
-	jsonBody, err := json.Marshal(requestBody)
+	jsonBody, err := utils.MarshalWithExtra(requestBody, input.Extra)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonBody))
+	requestCtx, cancel := models.ApplyRequestTimeout(ctx)
+	req, err := p.newRequest(requestCtx, "POST", url, bytes.NewBuffer(jsonBody))
 	if err != nil {
+		cancel()
 		return nil, err
 	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-api-key", p.apiKey)
-	req.Header.Set("anthropic-version", "2023-06-01")
+	setBetaHeader(req, input.ProviderOptions.Anthropic)
 
 	resp, err := p.client.Do(req)
 	if err != nil {
+		cancel()
 		return nil, err
 	}
 
 	if resp.StatusCode != http.StatusOK {
 		resp.Body.Close()
+		cancel()
 		return nil, fmt.Errorf("API request failed with status code: %d", resp.StatusCode)
 	}
 
-	streamChan := make(chan models.StreamingCompletionResponse)
+	streamChan := utils.NewStream(input.StreamBufferSize)
 
 	go func() {
 		defer resp.Body.Close()
 		defer close(streamChan)
+		defer cancel()
 
-		reader := bufio.NewReader(resp.Body)
-		var accumulatedText string
+		reader := utils.NewLineReader(resp.Body)
+		defer reader.Release()
 		var accumulatedUsage models.Usage
+		var toolCalls utils.ToolCallAccumulator
+		servedModel := modelName
+		// The citations of a text block arrive before its text, so they are located once it stops
+		var citations []models.Citation
+		var blockCitations []citation
+		textLength, blockStart := 0, 0
 
 		for {
-			line, err := reader.ReadBytes('\n')
+			line, err := reader.ReadLine()
 			if err != nil {
-				if err == io.EOF {
-					return
-				}
-				streamChan <- models.StreamingCompletionResponse{Error: err}
+				// The stream ends with message_stop, so even an EOF means it was cut off
+				utils.SendError(ctx, streamChan, utils.StreamError(err))
 				return
 			}
 
@@ -172,8 +383,8 @@ func (p *AnthropicProvider) GenerateCompletionStream(ctx context.Context, modelN
 			data := bytes.TrimPrefix(line, []byte("data: "))
 			var event map[string]interface{}
 			if err := json.Unmarshal(data, &event); err != nil {
-				streamChan <- models.StreamingCompletionResponse{Error: err}
-				continue
+				utils.SendError(ctx, streamChan, err)
+				return
 			}
 
 			eventType, ok := event["type"].(string)
@@ -187,46 +398,99 @@ func (p *AnthropicProvider) GenerateCompletionStream(ctx context.Context, modelN
 				if !ok {
 					continue
 				}
-				usage, ok := message["usage"].(map[string]interface{})
-				if !ok {
-					continue
+				if model, ok := message["model"].(string); ok && model != "" {
+					servedModel = model
 				}
-				inputTokens, ok := usage["input_tokens"].(float64)
-				if !ok {
+				if usage, ok := message["usage"].(map[string]interface{}); ok {
+					updateStreamUsage(&accumulatedUsage, usage)
+				}
+
+			case "content_block_start":
+				block, ok := event["content_block"].(map[string]interface{})
+				if ok && block["type"] == "text" {
+					blockStart, blockCitations = textLength, nil
+				}
+				// A tool_use block starts a tool call; its arguments follow as input_json_delta fragments
+				if !ok || block["type"] != "tool_use" {
 					continue
 				}
-				accumulatedUsage.PromptTokens = int(inputTokens)
+				index, _ := event["index"].(float64)
+				id, _ := block["id"].(string)
+				name, _ := block["name"].(string)
+				delta := models.ToolCallDelta{Index: int(index), ID: id, Name: name}
+				toolCalls.Add(delta)
+				if !utils.SendChunk(ctx, streamChan, models.StreamingCompletionResponse{ToolCallDeltas: []models.ToolCallDelta{delta}}) {
+					return
+				}
 
 			case "content_block_delta":
 				delta, ok := event["delta"].(map[string]interface{})
 				if !ok {
 					continue
 				}
-				text, ok := delta["text"].(string)
-				if !ok {
+				if partialJSON, ok := delta["partial_json"].(string); ok {
+					index, _ := event["index"].(float64)
+					toolDelta := models.ToolCallDelta{Index: int(index), Arguments: partialJSON}
+					toolCalls.Add(toolDelta)
+					if !utils.SendChunk(ctx, streamChan, models.StreamingCompletionResponse{ToolCallDeltas: []models.ToolCallDelta{toolDelta}}) {
+						return
+					}
 					continue
 				}
-				accumulatedText += text
-				streamChan <- models.StreamingCompletionResponse{Text: text}
-
-			case "message_delta":
-				usage, ok := event["usage"].(map[string]interface{})
-				if !ok {
+				if delta["type"] == "citations_delta" {
+					var citationEvent struct {
+						Delta struct {
+							Citation citation `json:"citation"`
+						} `json:"delta"`
+					}
+					if err := json.Unmarshal(data, &citationEvent); err != nil {
+						utils.SendError(ctx, streamChan, err)
+						return
+					}
+					blockCitations = append(blockCitations, citationEvent.Delta.Citation)
 					continue
 				}
-				outputTokens, ok := usage["output_tokens"].(float64)
+				if thinking, ok := delta["thinking"].(string); ok {
+					if !utils.SendChunk(ctx, streamChan, models.StreamingCompletionResponse{ThinkingText: thinking}) {
+						return
+					}
+					continue
+				}
+				text, ok := delta["text"].(string)
 				if !ok {
 					continue
 				}
-				accumulatedUsage.CompletionTokens = int(outputTokens)
-				accumulatedUsage.TotalTokens = accumulatedUsage.PromptTokens + accumulatedUsage.CompletionTokens
+				textLength += len(text)
+				if !utils.SendChunk(ctx, streamChan, models.StreamingCompletionResponse{Text: text}) {
+					return
+				}
 
-			case "message_stop":
-				streamChan <- models.StreamingCompletionResponse{
-					Text:  accumulatedText,
-					Done:  true,
-					Usage: &accumulatedUsage,
+			case "content_block_stop":
+				for _, c := range blockCitations {
+					citations = append(citations, c.model(blockStart, textLength))
 				}
+				blockCitations = nil
+
+			case "message_delta":
+				if usage, ok := event["usage"].(map[string]interface{}); ok {
+					updateStreamUsage(&accumulatedUsage, usage)
+				}
+
+			case "message_stop":
+				calls, err := toolCalls.ToolCalls()
+				utils.SendChunk(ctx, streamChan, models.StreamingCompletionResponse{
+					Done:      true,
+					Usage:     &accumulatedUsage,
+					ToolCalls: calls,
+					Citations: citations,
+					Model:     servedModel,
+					Error:     err,
+				})
+				return
+
+			case "error":
+				// The API reports errors after the stream started, such as overloaded_error, as an event
+				utils.SendError(ctx, streamChan, streamEventError(event))
 				return
 			}
 		}
@@ -235,22 +499,120 @@ func (p *AnthropicProvider) GenerateCompletionStream(ctx context.Context, modelN
 	return streamChan, nil
 }
 
-// Close closes the Anthropic provider (no-op in this case)
-func (p *AnthropicProvider) Close() error {
-	return nil
+// streamEventError returns the error reported by an error event of a stream
+func streamEventError(event map[string]interface{}) error {
+	details, _ := event["error"].(map[string]interface{})
+	errorType, _ := details["type"].(string)
+	message, _ := details["message"].(string)
+	return fmt.Errorf("stream error: %s: %s", errorType, message)
 }
 
-// GenerateEmbedding generates an embedding using the Anthropic model (not implemented)
-func (p *AnthropicProvider) GenerateEmbedding(ctx context.Context, input string) ([]float32, error) {
-	return nil, errors.New("embedding generation not implemented for Anthropic provider")
+// newRequest creates an API request with the authentication headers and the headers from ctx set
+func (p *AnthropicProvider) newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", p.apiKey)
+	req.Header.Set("anthropic-version", "2023-06-01")
+	models.ApplyRequestHeaders(req)
+	return req, nil
+}
+
+// doJSON sends body as JSON, if non-nil, and decodes the response into out, if non-nil
+func (p *AnthropicProvider) doJSON(ctx context.Context, method, url string, body, out interface{}) error {
+	ctx, cancel := models.ApplyRequestTimeout(ctx)
+	defer cancel()
+
+	var reqBody io.Reader
+	if body != nil {
+		jsonBody, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(jsonBody)
+	}
+
+	req, err := p.newRequest(ctx, method, url, reqBody)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	resp.Body = utils.LimitBody(resp.Body, p.bodyLimit)
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("API request failed with status code: %d, body: %s", resp.StatusCode, string(bodyBytes))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// thinkingConfig is the request field that enables extended thinking
+type thinkingConfig struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens"`
+}
+
+// maxTokens returns input.MaxTokens, or DefaultMaxTokens plus any thinking budget when it is zero
+func maxTokens(input models.CompletionInput) int {
+	if input.MaxTokens != 0 {
+		return input.MaxTokens
+	}
+	return DefaultMaxTokens + max(input.ProviderOptions.Anthropic.ThinkingBudget, 0)
 }
 
-// StartChat starts a new chat session (not implemented)
-func (p *AnthropicProvider) StartChat(modelName string) interface{} {
+// newThinkingConfig returns the thinking request field, or nil when extended thinking is off
+func newThinkingConfig(opts models.AnthropicOptions) *thinkingConfig {
+	if opts.ThinkingBudget <= 0 {
+		return nil
+	}
+	return &thinkingConfig{Type: "enabled", BudgetTokens: opts.ThinkingBudget}
+}
+
+// Close closes the Anthropic provider (no-op in this case)
+func (p *AnthropicProvider) Close() error {
 	return nil
 }
 
-// SendChatMessage sends a message to an existing chat session (not implemented)
-func (p *AnthropicProvider) SendChatMessage(ctx context.Context, session interface{}, message string) (*models.CompletionResponse, error) {
-	return nil, errors.New("chat functionality not implemented for Anthropic provider")
+// Capabilities reports what the Anthropic provider supports beyond completions
+func (p *AnthropicProvider) Capabilities() models.Capabilities {
+	return models.Capabilities{Streaming: true, Tools: true, Vision: true}
+}
+
+// ListModels returns the IDs of the models available to the API key, from /v1/models.
+// Aliases such as "claude-3-5-sonnet-latest" are accepted by the API but not listed.
+func (p *AnthropicProvider) ListModels(ctx context.Context) ([]string, error) {
+	var ids []string
+	afterID := ""
+	for {
+		endpoint := p.baseURL + "/v1/models?limit=1000"
+		if afterID != "" {
+			endpoint += "&after_id=" + url.QueryEscape(afterID)
+		}
+		var page struct {
+			Data []struct {
+				ID string `json:"id"`
+			} `json:"data"`
+			HasMore bool   `json:"has_more"`
+			LastID  string `json:"last_id"`
+		}
+		if err := p.doJSON(ctx, http.MethodGet, endpoint, nil, &page); err != nil {
+			return nil, err
+		}
+		for _, model := range page.Data {
+			ids = append(ids, model.ID)
+		}
+		if !page.HasMore || page.LastID == "" {
+			return ids, nil
+		}
+		afterID = page.LastID
+	}
 }
@@ -2,10 +2,17 @@ package anthropic
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"reflect"
 	"testing"
 
 	"github.com/1broseidon/gollm/models"
+	"github.com/1broseidon/gollm/providers/providertest"
 )
 
 func TestAnthropicProvider(t *testing.T) {
@@ -28,7 +35,7 @@ func TestAnthropicProvider(t *testing.T) {
 				{Role: "user", Content: "Explain the concept of quantum computing in simple terms."},
 			},
 			MaxTokens:   100,
-			Temperature: 0.7,
+			Temperature: models.Float32(0.7),
 		}
 
 		response, err := provider.GenerateCompletion(ctx, "claude-3-haiku-20240307", input)
@@ -62,7 +69,7 @@ func TestAnthropicProvider(t *testing.T) {
 				{Role: "user", Content: "Explain the concept of artificial intelligence in simple terms."},
 			},
 			MaxTokens:   100,
-			Temperature: 0.7,
+			Temperature: models.Float32(0.7),
 			Stream:      true,
 		}
 
@@ -104,3 +111,801 @@ func TestAnthropicProvider(t *testing.T) {
 		}
 	})
 }
+
+// newTestProvider returns a provider pointed at a mock server running handler
+func newTestProvider(t *testing.T, handler http.HandlerFunc) *AnthropicProvider {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	t.Setenv("ANTHROPIC_API_KEY", "test-key")
+
+	provider, err := NewAnthropicProvider(WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create Anthropic provider: %v", err)
+	}
+	return provider
+}
+
+func TestAnthropicThinking(t *testing.T) {
+	ctx := context.Background()
+	input := models.CompletionInput{
+		Messages:  []models.ChatMessage{{Role: "user", Content: "Is 97 prime?"}},
+		MaxTokens: 2048,
+		ProviderOptions: models.ProviderOptions{
+			Anthropic: models.AnthropicOptions{ThinkingBudget: 1024},
+		},
+	}
+
+	// checkThinkingRequest asserts the request enables extended thinking with the budget
+	checkThinkingRequest := func(t *testing.T, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Invalid request body: %v", err)
+			return
+		}
+		thinking, ok := body["thinking"].(map[string]interface{})
+		if !ok || thinking["type"] != "enabled" || thinking["budget_tokens"] != float64(1024) {
+			t.Errorf("Unexpected thinking field: %v", body["thinking"])
+		}
+	}
+
+	t.Run("GenerateCompletion", func(t *testing.T) {
+		provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+			checkThinkingRequest(t, r)
+			fmt.Fprint(w, `{
+				"content": [
+					{"type": "thinking", "thinking": "97 has no divisors up to 9.", "signature": "abc"},
+					{"type": "text", "text": "Yes, 97 is prime."}
+				],
+				"usage": {"input_tokens": 12, "output_tokens": 40}
+			}`)
+		})
+
+		response, err := provider.GenerateCompletion(ctx, "claude-3-7-sonnet-20250219", input)
+		if err != nil {
+			t.Fatalf("GenerateCompletion failed: %v", err)
+		}
+		if response.Text != "Yes, 97 is prime." {
+			t.Errorf("Unexpected text: %q", response.Text)
+		}
+		if response.ThinkingText != "97 has no divisors up to 9." {
+			t.Errorf("Unexpected thinking text: %q", response.ThinkingText)
+		}
+	})
+
+	t.Run("GenerateCompletionStream", func(t *testing.T) {
+		provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+			checkThinkingRequest(t, r)
+			events := []string{
+				`{"type": "message_start", "message": {"usage": {"input_tokens": 12}}}`,
+				`{"type": "content_block_start", "index": 0, "content_block": {"type": "thinking", "thinking": ""}}`,
+				`{"type": "content_block_delta", "index": 0, "delta": {"type": "thinking_delta", "thinking": "97 has no "}}`,
+				`{"type": "content_block_delta", "index": 0, "delta": {"type": "thinking_delta", "thinking": "small divisors."}}`,
+				`{"type": "content_block_delta", "index": 0, "delta": {"type": "signature_delta", "signature": "abc"}}`,
+				`{"type": "content_block_delta", "index": 1, "delta": {"type": "text_delta", "text": "Yes."}}`,
+				`{"type": "message_delta", "usage": {"output_tokens": 40}}`,
+				`{"type": "message_stop"}`,
+			}
+			for _, event := range events {
+				fmt.Fprintf(w, "data: %s\n\n", event)
+			}
+		})
+
+		streamChan, err := provider.GenerateCompletionStream(ctx, "claude-3-7-sonnet-20250219", input)
+		if err != nil {
+			t.Fatalf("GenerateCompletionStream failed: %v", err)
+		}
+
+		var text, thinking string
+		var last models.StreamingCompletionResponse
+		for chunk := range streamChan {
+			if chunk.Error != nil {
+				t.Fatalf("Error in streaming: %v", chunk.Error)
+			}
+			last = chunk
+			text += chunk.Text
+			thinking += chunk.ThinkingText
+		}
+
+		if text != "Yes." {
+			t.Errorf("Unexpected streamed text: %q", text)
+		}
+		if thinking != "97 has no small divisors." {
+			t.Errorf("Unexpected streamed thinking text: %q", thinking)
+		}
+		if !last.Done || last.Text != "" || last.ThinkingText != "" {
+			t.Errorf("Expected a final chunk that doesn't repeat the text, got %+v", last)
+		}
+	})
+}
+
+func TestAnthropicBatch(t *testing.T) {
+	ctx := context.Background()
+
+	var created struct {
+		Requests []struct {
+			CustomID string         `json:"custom_id"`
+			Params   messageRequest `json:"params"`
+		} `json:"requests"`
+	}
+	status := "in_progress"
+	canceled := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/messages/batches", func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&created); err != nil {
+			t.Errorf("Invalid batch request: %v", err)
+		}
+		fmt.Fprint(w, `{"id":"msgbatch_1","processing_status":"in_progress","request_counts":{"processing":2}}`)
+	})
+	mux.HandleFunc("/v1/messages/batches/msgbatch_1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"id":"msgbatch_1","processing_status":%q,"request_counts":{"processing":0,"succeeded":1,"errored":1}}`, status)
+	})
+	mux.HandleFunc("/v1/messages/batches/msgbatch_1/results", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"custom_id":"request-0","result":{"type":"succeeded","message":{"content":[{"type":"text","text":"Paris"}],"usage":{"input_tokens":10,"output_tokens":2}}}}`)
+		fmt.Fprintln(w, `{"custom_id":"request-1","result":{"type":"errored","error":{"type":"error","error":{"type":"invalid_request_error","message":"max_tokens is required"}}}}`)
+	})
+	mux.HandleFunc("/v1/messages/batches/msgbatch_1/cancel", func(w http.ResponseWriter, r *http.Request) {
+		canceled = r.Method == "POST"
+		fmt.Fprint(w, `{"id":"msgbatch_1","processing_status":"canceling"}`)
+	})
+	provider := newTestProvider(t, mux.ServeHTTP)
+
+	inputs := []models.CompletionInput{
+		{Model: "claude-3-5-haiku-latest", Messages: []models.ChatMessage{{Role: "user", Content: "Capital of France?"}}, MaxTokens: 10},
+		{Model: "claude-3-5-haiku-latest", Messages: []models.ChatMessage{{Role: "user", Content: "Capital of Spain?"}}},
+	}
+	batchID, err := provider.CreateBatch(ctx, inputs)
+	if err != nil {
+		t.Fatalf("CreateBatch failed: %v", err)
+	}
+	if batchID != "msgbatch_1" {
+		t.Errorf("Unexpected batch ID: %q", batchID)
+	}
+	if len(created.Requests) != 2 || created.Requests[1].CustomID != BatchCustomID(1) || created.Requests[0].Params.MaxTokens != 10 {
+		t.Errorf("Unexpected batch requests: %+v", created.Requests)
+	}
+
+	if _, err := provider.GetBatchResults(ctx, batchID); err == nil {
+		t.Error("Expected an error for results of a batch that hasn't ended")
+	}
+
+	status = "ended"
+	batchStatus, err := provider.GetBatchStatus(ctx, batchID)
+	if err != nil {
+		t.Fatalf("GetBatchStatus failed: %v", err)
+	}
+	if batchStatus.ProcessingStatus != "ended" || batchStatus.RequestCounts["succeeded"] != 1 {
+		t.Errorf("Unexpected batch status: %+v", batchStatus)
+	}
+
+	results, err := provider.GetBatchResults(ctx, batchID)
+	if err != nil {
+		t.Fatalf("GetBatchResults failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	if results[0].CustomID != "request-0" || results[0].Error != nil || results[0].Result.Text != "Paris" {
+		t.Errorf("Unexpected first result: %+v", results[0])
+	}
+	if results[1].Result != nil || results[1].Error == nil {
+		t.Errorf("Expected the second result to be an error, got %+v", results[1])
+	}
+
+	if err := provider.CancelBatch(ctx, batchID); err != nil {
+		t.Fatalf("CancelBatch failed: %v", err)
+	}
+	if !canceled {
+		t.Error("Expected a cancel request")
+	}
+
+	if _, err := provider.CreateBatch(ctx, nil); err == nil {
+		t.Error("Expected an error for an empty batch")
+	}
+}
+
+func TestAnthropicListModels(t *testing.T) {
+	var afterIDs []string
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/v1/models" {
+			http.NotFound(w, r)
+			return
+		}
+		afterID := r.URL.Query().Get("after_id")
+		afterIDs = append(afterIDs, afterID)
+		if afterID == "" {
+			fmt.Fprint(w, `{"data":[{"id":"claude-3-5-sonnet-20241022","type":"model"}],"has_more":true,"last_id":"claude-3-5-sonnet-20241022"}`)
+			return
+		}
+		fmt.Fprint(w, `{"data":[{"id":"claude-3-5-haiku-20241022","type":"model"}],"has_more":false,"last_id":"claude-3-5-haiku-20241022"}`)
+	})
+
+	names, err := provider.ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("ListModels failed: %v", err)
+	}
+	if len(names) != 2 || names[0] != "claude-3-5-sonnet-20241022" || names[1] != "claude-3-5-haiku-20241022" {
+		t.Errorf("Unexpected models %v", names)
+	}
+	if len(afterIDs) != 2 || afterIDs[1] != "claude-3-5-sonnet-20241022" {
+		t.Errorf("Expected the second page to follow the first, got pages after %q", afterIDs)
+	}
+}
+
+func TestAnthropicSystemMessages(t *testing.T) {
+	var requestBody messageRequest
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			t.Errorf("Invalid request body: %v", err)
+		}
+		fmt.Fprint(w, `{"content":[{"type":"text","text":"Arr"}],"usage":{"input_tokens":20,"output_tokens":1}}`)
+	})
+
+	input := models.CompletionInput{
+		Messages: []models.ChatMessage{
+			{Role: "system", Content: "You can call tools."},
+			{Role: "system", Content: "You are a pirate."},
+			{Role: "user", Content: "Hello"},
+		},
+		MaxTokens: 10,
+	}
+	if _, err := provider.GenerateCompletion(context.Background(), "claude-3-5-haiku-latest", input); err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+
+	if requestBody.System != "You can call tools.\n\nYou are a pirate." {
+		t.Errorf("Expected combined system prompt, got %q", requestBody.System)
+	}
+	if len(requestBody.Messages) != 1 || requestBody.Messages[0].Role != "user" {
+		t.Errorf("Expected only the user message in messages, got %+v", requestBody.Messages)
+	}
+}
+
+func TestAnthropicToolCalls(t *testing.T) {
+	var requestBody struct {
+		Messages   []map[string]interface{} `json:"messages"`
+		Tools      []toolDefinition         `json:"tools"`
+		ToolChoice *toolChoice              `json:"tool_choice"`
+	}
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			t.Errorf("Invalid request body: %v", err)
+		}
+		fmt.Fprint(w, `{"content":[
+			{"type":"text","text":"Checking London."},
+			{"type":"tool_use","id":"toolu_3","name":"get_weather","input":{"city":"London"}}
+		],"usage":{"input_tokens":40,"output_tokens":12}}`)
+	})
+
+	input := models.CompletionInput{
+		Messages: []models.ChatMessage{
+			{Role: "user", Content: "Weather in Paris and Rome?"},
+			{Role: "assistant", ToolCalls: []models.ToolCall{
+				{ID: "toolu_1", Name: "get_weather", Arguments: json.RawMessage(`{"city":"Paris"}`)},
+				{ID: "toolu_2", Name: "get_weather", Arguments: json.RawMessage(`{"city":"Rome"}`)},
+			}},
+			{Role: "tool", Content: "sunny", ToolCallID: "toolu_1"},
+			{Role: "tool", Content: "rainy", ToolCallID: "toolu_2"},
+		},
+		Tools:     []models.Tool{{Name: "get_weather", Description: "Current weather"}},
+		MaxTokens: 100,
+	}
+	response, err := provider.GenerateCompletion(context.Background(), "claude-3-5-haiku-latest", input)
+	if err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+
+	if response.Text != "Checking London." || len(response.ToolCalls) != 1 {
+		t.Fatalf("Unexpected response: %+v", response)
+	}
+	if call := response.ToolCalls[0]; call.ID != "toolu_3" || call.Name != "get_weather" || string(call.Arguments) != `{"city":"London"}` {
+		t.Errorf("Unexpected tool call: %+v", call)
+	}
+
+	if len(requestBody.Tools) != 1 || string(requestBody.Tools[0].InputSchema) != `{"type":"object"}` {
+		t.Errorf("Expected the tool with a default input schema, got %+v", requestBody.Tools)
+	}
+	if requestBody.ToolChoice == nil || requestBody.ToolChoice.Type != "auto" {
+		t.Errorf("Expected the default tool choice auto, got %+v", requestBody.ToolChoice)
+	}
+	if len(requestBody.Messages) != 3 {
+		t.Fatalf("Expected the tool results merged into one message, got %+v", requestBody.Messages)
+	}
+	if uses, _ := requestBody.Messages[1]["content"].([]interface{}); len(uses) != 2 || uses[0].(map[string]interface{})["type"] != "tool_use" {
+		t.Errorf("Expected tool_use blocks in the assistant message, got %+v", requestBody.Messages[1])
+	}
+	results, _ := requestBody.Messages[2]["content"].([]interface{})
+	if requestBody.Messages[2]["role"] != "user" || len(results) != 2 || results[1].(map[string]interface{})["tool_use_id"] != "toolu_2" {
+		t.Errorf("Expected tool_result blocks in a user message, got %+v", requestBody.Messages[2])
+	}
+	if requestBody.Messages[0]["content"] != "Weather in Paris and Rome?" {
+		t.Errorf("Expected plain messages to keep string content, got %+v", requestBody.Messages[0])
+	}
+}
+
+func TestAnthropicToolChoice(t *testing.T) {
+	tools := []models.Tool{{Name: "get_weather"}}
+	tests := []struct {
+		name   string
+		tools  []models.Tool
+		choice *models.ToolChoice
+		want   string
+	}{
+		{"NoTools", nil, &models.ToolChoice{Type: models.ToolChoiceAny}, `null`},
+		{"Default", tools, nil, `{"type":"auto"}`},
+		{"Any", tools, &models.ToolChoice{Type: models.ToolChoiceAny}, `{"type":"any"}`},
+		{"None", tools, &models.ToolChoice{Type: models.ToolChoiceNone}, `{"type":"none"}`},
+		{"Tool", tools, &models.ToolChoice{Type: models.ToolChoiceTool, Name: "get_weather"}, `{"type":"tool","name":"get_weather"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			choice, err := newToolChoice(tt.choice, tt.tools)
+			if err != nil {
+				t.Fatalf("newToolChoice failed: %v", err)
+			}
+			if got, _ := json.Marshal(choice); string(got) != tt.want {
+				t.Errorf("Expected tool_choice %s, got %s", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := newToolChoice(&models.ToolChoice{Type: "function"}, tools); err == nil {
+		t.Error("Expected an error for an unknown tool choice type")
+	}
+}
+
+func TestAnthropicStreamToolCalls(t *testing.T) {
+	events := []string{
+		`{"type":"message_start","message":{"usage":{"input_tokens":30}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Checking."}}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":""}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"city\": \"Par"}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"is\"}"}}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":15}}`,
+		`{"type":"message_stop"}`,
+	}
+	var requestBody map[string]interface{}
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			t.Errorf("Invalid request body: %v", err)
+		}
+		for _, event := range events {
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", event)
+		}
+	})
+
+	input := models.CompletionInput{
+		Messages:   []models.ChatMessage{{Role: "user", Content: "Weather in Paris?"}},
+		Tools:      []models.Tool{{Name: "get_weather"}},
+		ToolChoice: &models.ToolChoice{Type: models.ToolChoiceAny},
+		MaxTokens:  100,
+	}
+	stream, err := provider.GenerateCompletionStream(context.Background(), "claude-3-5-haiku-latest", input)
+	if err != nil {
+		t.Fatalf("GenerateCompletionStream failed: %v", err)
+	}
+
+	var arguments, text string
+	var done models.StreamingCompletionResponse
+	for chunk := range stream {
+		text += chunk.Text
+		for _, delta := range chunk.ToolCallDeltas {
+			if delta.Index != 1 {
+				t.Errorf("Expected deltas of the tool_use block, got %+v", delta)
+			}
+			arguments += delta.Arguments
+		}
+		if chunk.Done {
+			done = chunk
+		}
+	}
+
+	if arguments != `{"city": "Paris"}` {
+		t.Errorf("Expected the argument fragments in the deltas, got %q", arguments)
+	}
+	if done.Error != nil || text != "Checking." || len(done.ToolCalls) != 1 {
+		t.Fatalf("Unexpected Done chunk: %+v", done)
+	}
+	if call := done.ToolCalls[0]; call.ID != "toolu_1" || call.Name != "get_weather" || string(call.Arguments) != `{"city": "Paris"}` {
+		t.Errorf("Unexpected tool call: %+v", call)
+	}
+	if choice, _ := requestBody["tool_choice"].(map[string]interface{}); choice["type"] != "any" || requestBody["tools"] == nil {
+		t.Errorf("Expected tools and tool_choice in the stream request, got %+v", requestBody)
+	}
+}
+
+func TestAnthropicResponseModel(t *testing.T) {
+	input := models.CompletionInput{Messages: []models.ChatMessage{{Role: "user", Content: "Hi"}}, MaxTokens: 10}
+	tests := []struct {
+		name  string
+		model string // The model in the response, if any
+		want  string
+	}{
+		{"Resolved", `"model":"claude-3-5-haiku-20241022",`, "claude-3-5-haiku-20241022"},
+		{"Fallback", ``, "claude-3-5-haiku-latest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+				var request struct {
+					Stream bool `json:"stream"`
+				}
+				json.NewDecoder(r.Body).Decode(&request)
+				if request.Stream {
+					fmt.Fprintf(w, "data: {\"type\":\"message_start\",\"message\":{%s\"usage\":{\"input_tokens\":1}}}\n\n", tt.model)
+					fmt.Fprint(w, "data: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hi\"}}\n\n")
+					fmt.Fprint(w, "data: {\"type\":\"message_stop\"}\n\n")
+					return
+				}
+				fmt.Fprintf(w, `{%s"content":[{"type":"text","text":"Hi"}],"usage":{"input_tokens":1,"output_tokens":1}}`, tt.model)
+			})
+
+			resp, err := provider.GenerateCompletion(context.Background(), "claude-3-5-haiku-latest", input)
+			if err != nil {
+				t.Fatalf("GenerateCompletion failed: %v", err)
+			}
+			if resp.Model != tt.want {
+				t.Errorf("Expected model %q, got %q", tt.want, resp.Model)
+			}
+
+			stream, err := provider.GenerateCompletionStream(context.Background(), "claude-3-5-haiku-latest", input)
+			if err != nil {
+				t.Fatalf("GenerateCompletionStream failed: %v", err)
+			}
+			var last models.StreamingCompletionResponse
+			for chunk := range stream {
+				last = chunk
+			}
+			if !last.Done || last.Model != tt.want {
+				t.Errorf("Expected a Done chunk with model %q, got %+v", tt.want, last)
+			}
+		})
+	}
+}
+
+func TestAnthropicCacheUsage(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []string
+		want   models.Usage
+	}{
+		{
+			name: "Legacy",
+			events: []string{
+				`{"type":"message_start","message":{"usage":{"input_tokens":12,"output_tokens":1}}}`,
+				`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":7}}`,
+			},
+			want: models.Usage{PromptTokens: 12, CompletionTokens: 7, TotalTokens: 19},
+		},
+		{
+			name: "Cache",
+			events: []string{
+				`{"type":"message_start","message":{"usage":{"input_tokens":12,"cache_creation_input_tokens":0,"cache_read_input_tokens":2048,"output_tokens":1}}}`,
+				`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":7}}`,
+			},
+			want: models.Usage{PromptTokens: 12, CompletionTokens: 7, TotalTokens: 19, Details: &models.UsageDetails{CacheReadTokens: 2048}},
+		},
+		{
+			// Newer API versions repeat the cumulative counts on message_delta
+			name: "CumulativeDelta",
+			events: []string{
+				`{"type":"message_start","message":{"usage":{"input_tokens":12,"output_tokens":1}}}`,
+				`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"input_tokens":12,"cache_creation_input_tokens":1024,"cache_read_input_tokens":0,"output_tokens":7,"server_tool_use":{"web_search_requests":1}}}`,
+			},
+			want: models.Usage{PromptTokens: 12, CompletionTokens: 7, TotalTokens: 19, Details: &models.UsageDetails{CacheWriteTokens: 1024}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+				for _, event := range tt.events {
+					fmt.Fprintf(w, "data: %s\n\n", event)
+				}
+				fmt.Fprint(w, "data: {\"type\":\"message_stop\"}\n\n")
+			})
+			input := models.CompletionInput{Messages: []models.ChatMessage{{Role: "user", Content: "Hi"}}, MaxTokens: 10}
+			stream, err := provider.GenerateCompletionStream(context.Background(), "claude-3-5-haiku-latest", input)
+			if err != nil {
+				t.Fatalf("GenerateCompletionStream failed: %v", err)
+			}
+			var last models.StreamingCompletionResponse
+			for chunk := range stream {
+				last = chunk
+			}
+			if last.Usage == nil || !reflect.DeepEqual(*last.Usage, tt.want) {
+				t.Errorf("Expected usage %+v, got %+v", tt.want, last.Usage)
+			}
+		})
+	}
+
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"content":[{"type":"text","text":"Hi"}],"usage":{"input_tokens":12,"output_tokens":7,"cache_creation_input_tokens":1024,"cache_read_input_tokens":2048}}`))
+	})
+	input := models.CompletionInput{Messages: []models.ChatMessage{{Role: "user", Content: "Hi"}}, MaxTokens: 10}
+	resp, err := provider.GenerateCompletion(context.Background(), "claude-3-5-haiku-latest", input)
+	if err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+	want := models.Usage{PromptTokens: 12, CompletionTokens: 7, TotalTokens: 19,
+		Details: &models.UsageDetails{CacheReadTokens: 2048, CacheWriteTokens: 1024}}
+	if !reflect.DeepEqual(*resp.Usage, want) {
+		t.Errorf("Expected usage %+v, got %+v", want, *resp.Usage)
+	}
+}
+
+func TestAnthropicDefaultMaxTokens(t *testing.T) {
+	var requests []map[string]interface{}
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		requests = append(requests, body)
+		if body["stream"] == true {
+			fmt.Fprint(w, "data: {\"type\":\"message_stop\"}\n\n")
+			return
+		}
+		w.Write([]byte(`{"content":[{"type":"text","text":"Hi"}],"usage":{"input_tokens":1,"output_tokens":1}}`))
+	})
+
+	tests := []struct {
+		name  string
+		input models.CompletionInput
+		want  float64
+	}{
+		{"Default", models.CompletionInput{}, DefaultMaxTokens},
+		{"Explicit", models.CompletionInput{MaxTokens: 50}, 50},
+		{"Thinking", models.CompletionInput{ProviderOptions: models.ProviderOptions{Anthropic: models.AnthropicOptions{ThinkingBudget: 2048}}}, DefaultMaxTokens + 2048},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = nil
+			tt.input.Messages = []models.ChatMessage{{Role: "user", Content: "Hi"}}
+			if _, err := provider.GenerateCompletion(context.Background(), "claude-3-5-haiku-latest", tt.input); err != nil {
+				t.Fatalf("GenerateCompletion failed: %v", err)
+			}
+			stream, err := provider.GenerateCompletionStream(context.Background(), "claude-3-5-haiku-latest", tt.input)
+			if err != nil {
+				t.Fatalf("GenerateCompletionStream failed: %v", err)
+			}
+			for range stream {
+			}
+
+			if len(requests) != 2 {
+				t.Fatalf("Expected 2 requests, got %d", len(requests))
+			}
+			for _, request := range requests {
+				if request["max_tokens"] != tt.want {
+					t.Errorf("Expected max_tokens %v, got %v", tt.want, request["max_tokens"])
+				}
+			}
+		})
+	}
+}
+
+func TestAnthropicSamplingOptions(t *testing.T) {
+	var requests []map[string]interface{}
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		requests = append(requests, body)
+		if body["stream"] == true {
+			fmt.Fprint(w, "data: {\"type\":\"message_stop\"}\n\n")
+			return
+		}
+		w.Write([]byte(`{"content":[{"type":"text","text":"Hi"}],"usage":{"input_tokens":1,"output_tokens":1}}`))
+	})
+
+	tests := []struct {
+		name  string
+		input models.CompletionInput
+		want  map[string]interface{} // The values sent; nil if left out
+	}{
+		{"Unset", models.CompletionInput{}, map[string]interface{}{"temperature": nil, "top_p": nil, "stop_sequences": nil}},
+		{"Set", models.CompletionInput{Temperature: models.Float32(0.5), TopP: models.Float32(0.9)}, map[string]interface{}{"temperature": 0.5, "top_p": 0.9}},
+		{"ZeroTemperature", models.CompletionInput{Temperature: models.Float32(0)}, map[string]interface{}{"temperature": 0.0}},
+		{"Stop", models.CompletionInput{Stop: []string{"END"}}, map[string]interface{}{"stop_sequences": []interface{}{"END"}}},
+		{
+			"Extra",
+			models.CompletionInput{Temperature: models.Float32(0.5), Extra: map[string]interface{}{"top_k": 40, "temperature": 1, "max_tokens": 5}},
+			map[string]interface{}{"top_k": 40.0, "temperature": 0.5, "max_tokens": float64(DefaultMaxTokens)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = nil
+			tt.input.Messages = []models.ChatMessage{{Role: "user", Content: "Hi"}}
+			if _, err := provider.GenerateCompletion(context.Background(), "claude-3-5-haiku-latest", tt.input); err != nil {
+				t.Fatalf("GenerateCompletion failed: %v", err)
+			}
+			stream, err := provider.GenerateCompletionStream(context.Background(), "claude-3-5-haiku-latest", tt.input)
+			if err != nil {
+				t.Fatalf("GenerateCompletionStream failed: %v", err)
+			}
+			for range stream {
+			}
+
+			if len(requests) != 2 {
+				t.Fatalf("Expected 2 requests, got %d", len(requests))
+			}
+			for i, request := range requests {
+				for key, want := range tt.want {
+					if got, ok := request[key]; !reflect.DeepEqual(got, want) || (want == nil && ok) {
+						t.Errorf("Request %d: expected %s %v, got %v", i, key, want, got)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestNewMessagesRoles(t *testing.T) {
+	messages := newMessages([]models.ChatMessage{
+		{Role: models.RoleUser, Content: "Weather in Paris?"},
+		{Role: models.RoleAssistant, ToolCalls: []models.ToolCall{{ID: "toolu_1", Name: "weather"}}},
+		{Role: models.RoleTool, Content: "sunny", ToolCallID: "toolu_1"},
+		{Role: models.RoleAssistant, Content: "Sunny."},
+	})
+
+	want := []string{"user", "assistant", "user", "assistant"}
+	if len(messages) != len(want) {
+		t.Fatalf("Expected %d messages, got %+v", len(want), messages)
+	}
+	for i, role := range want {
+		if messages[i].Role != role {
+			t.Errorf("Expected message %d to have role %q, got %q", i, role, messages[i].Role)
+		}
+	}
+}
+
+// assertAlternating fails unless messages start with a user message and alternate roles
+func assertAlternating(t *testing.T, messages []apiMessage) {
+	t.Helper()
+	for i, message := range messages {
+		want := "user"
+		if i%2 == 1 {
+			want = "assistant"
+		}
+		if message.Role != want {
+			t.Errorf("Expected message %d to be from %s, got %+v", i, want, messages)
+			return
+		}
+	}
+}
+
+func TestMessageAlternation(t *testing.T) {
+	user := func(content string) models.ChatMessage { return models.ChatMessage{Role: models.RoleUser, Content: content} }
+	assistant := func(content string) models.ChatMessage {
+		return models.ChatMessage{Role: models.RoleAssistant, Content: content}
+	}
+
+	tests := []struct {
+		name     string
+		messages []models.ChatMessage
+		want     []apiMessage
+	}{
+		{
+			"Valid",
+			[]models.ChatMessage{user("Hi"), assistant("Hello"), user("Bye")},
+			[]apiMessage{{Role: "user", Content: "Hi"}, {Role: "assistant", Content: "Hello"}, {Role: "user", Content: "Bye"}},
+		},
+		{
+			"ConsecutiveUser",
+			[]models.ChatMessage{user("Hi"), user("Are you there?")},
+			[]apiMessage{{Role: "user", Content: "Hi\nAre you there?"}},
+		},
+		{
+			"ConsecutiveAssistant",
+			[]models.ChatMessage{user("Hi"), assistant("Hello"), assistant("How can I help?"), user("Bye")},
+			[]apiMessage{{Role: "user", Content: "Hi"}, {Role: "assistant", Content: "Hello\nHow can I help?"}, {Role: "user", Content: "Bye"}},
+		},
+		{
+			"LeadingAssistant",
+			[]models.ChatMessage{assistant("How can I help?"), user("Hi")},
+			[]apiMessage{{Role: "user", Content: placeholderUserTurn}, {Role: "assistant", Content: "How can I help?"}, {Role: "user", Content: "Hi"}},
+		},
+		{
+			"ToolResultsThenUser",
+			[]models.ChatMessage{
+				user("Weather?"),
+				{Role: models.RoleAssistant, ToolCalls: []models.ToolCall{{ID: "toolu_1", Name: "weather"}}},
+				{Role: models.RoleTool, Content: "sunny", ToolCallID: "toolu_1"},
+				user("Thanks"),
+			},
+			[]apiMessage{
+				{Role: "user", Content: "Weather?"},
+				{Role: "assistant", Content: []contentBlock{{Type: "tool_use", ID: "toolu_1", Name: "weather", Input: json.RawMessage("{}")}}},
+				{Role: "user", Content: []contentBlock{{Type: "tool_result", ToolUseID: "toolu_1", Content: "sunny"}, {Type: "text", Text: "Thanks"}}},
+			},
+		},
+		{
+			"FailedToolResult",
+			[]models.ChatMessage{
+				user("Weather?"),
+				{Role: models.RoleAssistant, ToolCalls: []models.ToolCall{{ID: "toolu_1", Name: "weather"}}},
+				models.ToolResult{ToolCallID: "toolu_1", Content: "service unavailable", IsError: true}.Message(),
+			},
+			[]apiMessage{
+				{Role: "user", Content: "Weather?"},
+				{Role: "assistant", Content: []contentBlock{{Type: "tool_use", ID: "toolu_1", Name: "weather", Input: json.RawMessage("{}")}}},
+				{Role: "user", Content: []contentBlock{{Type: "tool_result", ToolUseID: "toolu_1", Content: "service unavailable", IsError: true}}},
+			},
+		},
+		{
+			"EmptyMerged",
+			[]models.ChatMessage{user(""), user("Hi")},
+			[]apiMessage{{Role: "user", Content: "Hi"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := requestMessages(tt.messages, models.AnthropicOptions{})
+			assertAlternating(t, got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestStrictMessageOrder(t *testing.T) {
+	var sent messageRequest
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&sent)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"content":[{"type":"text","text":"ok"}]}`)
+	})
+
+	input := models.CompletionInput{Messages: []models.ChatMessage{
+		{Role: models.RoleSystem, Content: "Be brief."},
+		{Role: models.RoleAssistant, Content: "Hello"},
+		{Role: models.RoleUser, Content: "Hi"},
+		{Role: models.RoleUser, Content: "Bye"},
+	}}
+	if _, err := provider.GenerateCompletion(context.Background(), "claude-3-5-sonnet-20241022", input); err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+	if len(sent.Messages) != 3 || sent.System != "Be brief." {
+		t.Errorf("Expected the placeholder turn, the assistant and the merged user messages, got %+v", sent)
+	}
+
+	input.ProviderOptions.Anthropic.StrictMessageOrder = true
+	if _, err := provider.GenerateCompletion(context.Background(), "claude-3-5-sonnet-20241022", input); err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+	if len(sent.Messages) != 3 || sent.Messages[0].Role != "assistant" || sent.Messages[2].Content != "Bye" {
+		t.Errorf("Expected the messages as given with StrictMessageOrder, got %+v", sent.Messages)
+	}
+}
+
+func TestAnthropicStreamContract(t *testing.T) {
+	fixtures := []providertest.Fixture{
+		{File: "stream_text.sse", Text: "Hello, world", Usage: &models.Usage{PromptTokens: 25, CompletionTokens: 4, TotalTokens: 29}},
+		{File: "stream_thinking.sse", Text: "Yes.", Usage: &models.Usage{PromptTokens: 30, CompletionTokens: 40, TotalTokens: 70}},
+		{File: "stream_tool_use.sse", Text: "Checking.", ToolCalls: 1},
+		{File: "stream_error_event.sse", Text: "Hello", Err: providertest.AnyError},
+		{File: "stream_truncated.sse", Text: "Once upon", Err: io.ErrUnexpectedEOF},
+		{File: "stream_malformed.sse", Text: "Hello", Err: providertest.AnyError},
+	}
+	providertest.RunStreamFixtures(t, fixtures, func(t *testing.T, baseURL string) (<-chan models.StreamingCompletionResponse, error) {
+		provider, err := NewAnthropicProvider(WithAPIKey("test-key"), WithBaseURL(baseURL))
+		if err != nil {
+			return nil, err
+		}
+		input := models.CompletionInput{Messages: []models.ChatMessage{{Role: "user", Content: "Say hello"}}, MaxTokens: 100}
+		return provider.GenerateCompletionStream(context.Background(), "claude-3-5-haiku-latest", input)
+	})
+}
+
+func TestAnthropicStreamAbandoned(t *testing.T) {
+	event := []byte("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hello\"}}\n\n")
+	providertest.CheckAbandoned(t, event, func(ctx context.Context, baseURL string) (<-chan models.StreamingCompletionResponse, error) {
+		provider, err := NewAnthropicProvider(WithAPIKey("test-key"), WithBaseURL(baseURL))
+		if err != nil {
+			return nil, err
+		}
+		input := models.CompletionInput{Messages: []models.ChatMessage{{Role: "user", Content: "Say hello"}}, MaxTokens: 100}
+		return provider.GenerateCompletionStream(ctx, "claude-3-5-haiku-latest", input)
+	})
+}
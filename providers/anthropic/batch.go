@@ -0,0 +1,196 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/1broseidon/gollm/internal/utils"
+	"github.com/1broseidon/gollm/models"
+)
+
+// maxBatchRequests is the largest number of requests the Message Batches API accepts in one batch
+const maxBatchRequests = 10000
+
+// BatchStatus describes the progress of a message batch
+type BatchStatus struct {
+	ID string
+	// ProcessingStatus is "in_progress", "canceling" or "ended"
+	ProcessingStatus string
+	// RequestCounts holds the number of requests that are "processing", "succeeded",
+	// "errored", "canceled" and "expired"
+	RequestCounts map[string]int
+}
+
+// BatchResult is the outcome of one request in a message batch.
+// Exactly one of Result and Error is set.
+type BatchResult struct {
+	// CustomID identifies the request: "request-N" for the Nth input given to CreateBatch
+	CustomID string
+	Result   *models.CompletionResponse
+	Error    error
+}
+
+// batchResponse is a message batch as returned by the API
+type batchResponse struct {
+	ID               string         `json:"id"`
+	ProcessingStatus string         `json:"processing_status"`
+	RequestCounts    map[string]int `json:"request_counts"`
+	ResultsURL       string         `json:"results_url"`
+}
+
+// BatchCustomID returns the custom ID CreateBatch assigns to the input at index
+func BatchCustomID(index int) string {
+	return fmt.Sprintf("request-%d", index)
+}
+
+// CreateBatch submits inputs to the Message Batches API, which processes them asynchronously at a
+// lower cost, and returns the batch ID. Each input's Model is the Anthropic model name. Results are
+// identified by BatchCustomID of the input's index.
+func (p *AnthropicProvider) CreateBatch(ctx context.Context, inputs []models.CompletionInput) (string, error) {
+	if len(inputs) == 0 {
+		return "", errors.New("batch has no requests")
+	}
+	if len(inputs) > maxBatchRequests {
+		return "", fmt.Errorf("batch has %d requests, the limit is %d", len(inputs), maxBatchRequests)
+	}
+
+	type batchRequest struct {
+		CustomID string          `json:"custom_id"`
+		Params   json.RawMessage `json:"params"`
+	}
+	requests := make([]batchRequest, len(inputs))
+	for i, input := range inputs {
+		if input.Model == "" {
+			return "", fmt.Errorf("batch request %d has no model", i)
+		}
+		request, err := newMessageRequest(input.Model, input)
+		if err != nil {
+			return "", fmt.Errorf("batch request %d: %w", i, err)
+		}
+		params, err := utils.MarshalWithExtra(request, input.Extra)
+		if err != nil {
+			return "", fmt.Errorf("batch request %d: %w", i, err)
+		}
+		requests[i] = batchRequest{CustomID: BatchCustomID(i), Params: params}
+	}
+
+	var batch batchResponse
+	body := map[string]interface{}{"requests": requests}
+	if err := p.doJSON(ctx, "POST", p.baseURL+"/v1/messages/batches", body, &batch); err != nil {
+		return "", err
+	}
+	return batch.ID, nil
+}
+
+// GetBatchStatus returns the processing status of a message batch
+func (p *AnthropicProvider) GetBatchStatus(ctx context.Context, batchID string) (*BatchStatus, error) {
+	batch, err := p.getBatch(ctx, batchID)
+	if err != nil {
+		return nil, err
+	}
+	return &BatchStatus{
+		ID:               batch.ID,
+		ProcessingStatus: batch.ProcessingStatus,
+		RequestCounts:    batch.RequestCounts,
+	}, nil
+}
+
+// GetBatchResults returns the results of a message batch. The batch must have ended.
+func (p *AnthropicProvider) GetBatchResults(ctx context.Context, batchID string) ([]BatchResult, error) {
+	ctx, cancel := models.ApplyRequestTimeout(ctx)
+	defer cancel()
+
+	batch, err := p.getBatch(ctx, batchID)
+	if err != nil {
+		return nil, err
+	}
+	if batch.ProcessingStatus != "ended" {
+		return nil, fmt.Errorf("batch %s has not ended, processing status: %s", batchID, batch.ProcessingStatus)
+	}
+
+	resultsURL := batch.ResultsURL
+	if resultsURL == "" {
+		resultsURL = p.batchURL(batchID) + "/results"
+	}
+
+	req, err := p.newRequest(ctx, "GET", resultsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(utils.LimitBody(resp.Body, p.bodyLimit))
+		return nil, fmt.Errorf("API request failed with status code: %d, body: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	// Results are returned as JSON Lines, one object per request
+	var results []BatchResult
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var line struct {
+			CustomID string `json:"custom_id"`
+			Result   struct {
+				Type    string   `json:"type"`
+				Message *message `json:"message"`
+				Error   struct {
+					Error struct {
+						Type    string `json:"type"`
+						Message string `json:"message"`
+					} `json:"error"`
+				} `json:"error"`
+			} `json:"result"`
+		}
+		if err := decoder.Decode(&line); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("failed to decode batch results: %w", err)
+		}
+
+		result := BatchResult{CustomID: line.CustomID}
+		switch line.Result.Type {
+		case "succeeded":
+			if line.Result.Message == nil {
+				result.Error = errors.New("no message in result")
+				break
+			}
+			result.Result, result.Error = line.Result.Message.completionResponse()
+		case "errored":
+			result.Error = fmt.Errorf("%s: %s", line.Result.Error.Error.Type, line.Result.Error.Error.Message)
+		default:
+			result.Error = fmt.Errorf("request %s", line.Result.Type)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// CancelBatch asks the API to stop processing a message batch. Requests that have already
+// been processed keep their results.
+func (p *AnthropicProvider) CancelBatch(ctx context.Context, batchID string) error {
+	return p.doJSON(ctx, "POST", p.batchURL(batchID)+"/cancel", nil, nil)
+}
+
+// getBatch fetches a message batch
+func (p *AnthropicProvider) getBatch(ctx context.Context, batchID string) (*batchResponse, error) {
+	var batch batchResponse
+	if err := p.doJSON(ctx, "GET", p.batchURL(batchID), nil, &batch); err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// batchURL returns the API URL of a message batch
+func (p *AnthropicProvider) batchURL(batchID string) string {
+	return p.baseURL + "/v1/messages/batches/" + url.PathEscape(batchID)
+}
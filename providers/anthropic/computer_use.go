@@ -0,0 +1,54 @@
+package anthropic
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// computerUseBeta is the anthropic-beta header value enabling the computer use tools
+const computerUseBeta = "computer-use-2024-10-22"
+
+// computerUseDefinitions are the definitions of the computer use tools, by the names of
+// AnthropicOptions.ComputerUseTools. The computer tool also needs the display size.
+var computerUseDefinitions = map[string]toolDefinition{
+	models.ComputerUseComputer:   {Type: "computer_20241022", Name: "computer"},
+	models.ComputerUseTextEditor: {Type: "text_editor_20241022", Name: "str_replace_editor"},
+	models.ComputerUseBash:       {Type: "bash_20241022", Name: "bash"},
+}
+
+// newTools returns the tools of input: its Tools followed by the computer use tools of its
+// Anthropic options
+func newTools(input models.CompletionInput) ([]toolDefinition, error) {
+	tools := newToolDefinitions(input.Tools)
+	opts := input.ProviderOptions.Anthropic
+	for _, name := range opts.ComputerUseTools {
+		tool, ok := computerUseDefinitions[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown computer use tool %q", name)
+		}
+		if name == models.ComputerUseComputer {
+			tool.DisplayWidth, tool.DisplayHeight = opts.ComputerDisplayWidth, opts.ComputerDisplayHeight
+			if tool.DisplayWidth <= 0 || tool.DisplayHeight <= 0 {
+				tool.DisplayWidth, tool.DisplayHeight = 1024, 768
+			}
+		}
+		tools = append(tools, tool)
+	}
+	return tools, nil
+}
+
+// setBetaHeader enables the computer use beta on req when opts offers its tools, keeping any
+// beta already set from the request context
+func setBetaHeader(req *http.Request, opts models.AnthropicOptions) {
+	if len(opts.ComputerUseTools) == 0 {
+		return
+	}
+	betas := computerUseBeta
+	if existing := req.Header.Get("anthropic-beta"); existing != "" && !strings.Contains(existing, computerUseBeta) {
+		betas = existing + "," + computerUseBeta
+	}
+	req.Header.Set("anthropic-beta", betas)
+}
@@ -0,0 +1,124 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+func TestAnthropicComputerUse(t *testing.T) {
+	var betas []string
+	var tools []map[string]interface{}
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		betas = append(betas, r.Header.Get("anthropic-beta"))
+		var request struct {
+			Stream bool                     `json:"stream"`
+			Tools  []map[string]interface{} `json:"tools"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Invalid request body: %v", err)
+		}
+		tools = request.Tools
+		if request.Stream {
+			for _, event := range []string{
+				`{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_2","name":"bash","input":{}}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"command\":\"ls\"}"}}`,
+				`{"type":"content_block_stop","index":0}`,
+				`{"type":"message_stop"}`,
+			} {
+				fmt.Fprintf(w, "data: %s\n\n", event)
+			}
+			return
+		}
+		fmt.Fprint(w, `{"content":[
+			{"type":"text","text":"I'll take a screenshot."},
+			{"type":"tool_use","id":"toolu_1","name":"computer","input":{"action":"screenshot"}}
+		],"usage":{"input_tokens":2000,"output_tokens":50}}`)
+	})
+
+	input := models.CompletionInput{
+		Messages:  []models.ChatMessage{{Role: "user", Content: "Open the browser"}},
+		MaxTokens: 1024,
+		Tools:     []models.Tool{{Name: "notify", Description: "Notify the user"}},
+		ProviderOptions: models.ProviderOptions{Anthropic: models.AnthropicOptions{
+			ComputerUseTools:      []string{models.ComputerUseComputer, models.ComputerUseTextEditor, models.ComputerUseBash},
+			ComputerDisplayWidth:  1280,
+			ComputerDisplayHeight: 800,
+		}},
+	}
+	resp, err := provider.GenerateCompletion(context.Background(), "claude-3-5-sonnet-latest", input)
+	if err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+
+	want := []map[string]interface{}{
+		{"name": "notify", "description": "Notify the user", "input_schema": map[string]interface{}{"type": "object"}},
+		{"type": "computer_20241022", "name": "computer", "display_width_px": float64(1280), "display_height_px": float64(800)},
+		{"type": "text_editor_20241022", "name": "str_replace_editor"},
+		{"type": "bash_20241022", "name": "bash"},
+	}
+	if !reflect.DeepEqual(tools, want) {
+		t.Errorf("Expected the tools %v, got %v", want, tools)
+	}
+	calls := []models.ToolCall{{ID: "toolu_1", Name: "computer", Arguments: json.RawMessage(`{"action":"screenshot"}`)}}
+	if resp.Text != "I'll take a screenshot." || !reflect.DeepEqual(resp.ToolCalls, calls) {
+		t.Errorf("Expected the computer tool call %+v, got %+v", calls, resp)
+	}
+
+	stream, err := provider.GenerateCompletionStream(context.Background(), "claude-3-5-sonnet-latest", input)
+	if err != nil {
+		t.Fatalf("GenerateCompletionStream failed: %v", err)
+	}
+	var done models.StreamingCompletionResponse
+	for chunk := range stream {
+		done = chunk
+	}
+	calls = []models.ToolCall{{ID: "toolu_2", Name: "bash", Arguments: json.RawMessage(`{"command":"ls"}`)}}
+	if !reflect.DeepEqual(done.ToolCalls, calls) {
+		t.Errorf("Expected the bash tool call %+v on the Done chunk, got %+v", calls, done.ToolCalls)
+	}
+	if !reflect.DeepEqual(betas, []string{computerUseBeta, computerUseBeta}) {
+		t.Errorf("Expected the computer use beta header on both requests, got %q", betas)
+	}
+
+	// Requests without computer use tools have no beta header or built-in tools
+	betas = nil
+	input.ProviderOptions = models.ProviderOptions{}
+	if _, err := provider.GenerateCompletion(context.Background(), "claude-3-5-sonnet-latest", input); err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+	if betas[0] != "" || len(tools) != 1 {
+		t.Errorf("Expected no beta header and one tool, got %q and %v", betas[0], tools)
+	}
+}
+
+func TestAnthropicComputerUseDefaults(t *testing.T) {
+	input := models.CompletionInput{ProviderOptions: models.ProviderOptions{Anthropic: models.AnthropicOptions{
+		ComputerUseTools: []string{models.ComputerUseComputer},
+	}}}
+	tools, err := newTools(input)
+	if err != nil {
+		t.Fatalf("newTools failed: %v", err)
+	}
+	if len(tools) != 1 || tools[0].DisplayWidth != 1024 || tools[0].DisplayHeight != 768 {
+		t.Errorf("Expected a 1024x768 display, got %+v", tools)
+	}
+
+	input.ProviderOptions.Anthropic.ComputerUseTools = []string{"browser"}
+	if _, err := newTools(input); err == nil || !strings.Contains(err.Error(), `unknown computer use tool "browser"`) {
+		t.Errorf("Expected an unknown tool error, got %v", err)
+	}
+
+	req, _ := http.NewRequest("POST", "https://api.anthropic.com/v1/messages", nil)
+	req.Header.Set("anthropic-beta", "pdfs-2024-09-25")
+	setBetaHeader(req, models.AnthropicOptions{ComputerUseTools: []string{models.ComputerUseBash}})
+	if got := req.Header.Get("anthropic-beta"); got != "pdfs-2024-09-25,"+computerUseBeta {
+		t.Errorf("Expected the betas to be combined, got %q", got)
+	}
+}
@@ -0,0 +1,93 @@
+package anthropic
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// MaxDocumentSize is the largest PDF document, in bytes, the API accepts
+const MaxDocumentSize = 32 << 20
+
+// documentTypes are the media types of the documents the API accepts
+var documentTypes = []string{"application/pdf", "text/plain"}
+
+// citationConfig enables the citations of a document block
+type citationConfig struct {
+	Enabled bool `json:"enabled"`
+}
+
+// documentBlock converts a document content part, checked by validateDocument, to a document
+// block. Plain text is sent as text rather than base64, as the API requires.
+func documentBlock(part models.ContentPart) contentBlock {
+	block := contentBlock{Type: "document", Title: part.Title}
+	if part.MimeType == "text/plain" {
+		text, _ := base64.StdEncoding.DecodeString(part.Data)
+		block.Source = &blockSource{Type: "text", MediaType: part.MimeType, Data: string(text)}
+	} else {
+		block.Source = &blockSource{Type: "base64", MediaType: part.MimeType, Data: part.Data}
+	}
+	if part.Citations {
+		block.Citations = &citationConfig{Enabled: true}
+	}
+	return block
+}
+
+// validateDocument checks that a document content part is a PDF or plain text document the API
+// accepts
+func validateDocument(part models.ContentPart) error {
+	if part.FileURI != "" {
+		return errors.New("files uploaded to another provider are not supported; send the document data")
+	}
+	switch part.MimeType {
+	case "application/pdf":
+		if size := imageSize(part.Data); size > MaxDocumentSize {
+			return fmt.Errorf("document of %d bytes is larger than the %d byte limit", size, MaxDocumentSize)
+		}
+	case "text/plain":
+		if _, err := base64.StdEncoding.DecodeString(part.Data); err != nil {
+			return fmt.Errorf("invalid base64 document data: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported document type %q; use %s", part.MimeType, strings.Join(documentTypes, ", "))
+	}
+	return nil
+}
+
+// citation is a citation of a text block of a response
+type citation struct {
+	Type            string `json:"type"`
+	CitedText       string `json:"cited_text"`
+	DocumentIndex   int    `json:"document_index"`
+	DocumentTitle   string `json:"document_title"`
+	StartCharIndex  int    `json:"start_char_index"`
+	EndCharIndex    int    `json:"end_char_index"`
+	StartPageNumber int    `json:"start_page_number"`
+	EndPageNumber   int    `json:"end_page_number"`
+	StartBlockIndex int    `json:"start_block_index"`
+	EndBlockIndex   int    `json:"end_block_index"`
+}
+
+// model converts the citation of the text block spanning textStart to textEnd of the response
+func (c citation) model(textStart, textEnd int) models.Citation {
+	result := models.Citation{
+		Type:          c.Type,
+		CitedText:     c.CitedText,
+		DocumentIndex: c.DocumentIndex,
+		DocumentTitle: c.DocumentTitle,
+		TextStart:     textStart,
+		TextEnd:       textEnd,
+	}
+	switch c.Type {
+	case models.CitationCharLocation:
+		result.Start, result.End = c.StartCharIndex, c.EndCharIndex
+	case models.CitationPageLocation:
+		result.Start, result.End = c.StartPageNumber, c.EndPageNumber
+	case models.CitationContentBlockLocation:
+		result.Start, result.End = c.StartBlockIndex, c.EndBlockIndex
+	}
+	return result
+}
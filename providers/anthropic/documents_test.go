@@ -0,0 +1,172 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+func TestAnthropicDocuments(t *testing.T) {
+	var requestBody struct {
+		Messages []struct {
+			Content []map[string]interface{} `json:"content"`
+		} `json:"messages"`
+	}
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			t.Errorf("Invalid request body: %v", err)
+		}
+		fmt.Fprint(w, `{"content":[
+			{"type":"text","text":"According to the documents, "},
+			{"type":"text","text":"the grass is green","citations":[
+				{"type":"char_location","cited_text":"The grass is green.","document_index":0,"document_title":"Facts","start_char_index":0,"end_char_index":19}
+			]},
+			{"type":"text","text":" and revenue grew.","citations":[
+				{"type":"page_location","cited_text":"Revenue grew 10%.","document_index":1,"document_title":"Report","start_page_number":2,"end_page_number":3}
+			]}
+		],"usage":{"input_tokens":600,"output_tokens":20}}`)
+	})
+
+	text := models.NewTextDocumentPart("The grass is green. The sky is blue.")
+	text.Title, text.Citations = "Facts", true
+	pdf := []byte("%PDF-1.7 fake document")
+	report := models.NewDocumentPart(pdf, "application/pdf")
+	report.Title, report.Citations = "Report", true
+	input := models.CompletionInput{
+		Messages: []models.ChatMessage{{
+			Role:         "user",
+			Content:      "What color is the grass, and how did revenue change?",
+			ContentParts: []models.ContentPart{text, report},
+		}},
+		MaxTokens: 100,
+	}
+	resp, err := provider.GenerateCompletion(context.Background(), "claude-3-5-sonnet-latest", input)
+	if err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+
+	want := []map[string]interface{}{
+		{
+			"type":      "document",
+			"title":     "Facts",
+			"source":    map[string]interface{}{"type": "text", "media_type": "text/plain", "data": "The grass is green. The sky is blue."},
+			"citations": map[string]interface{}{"enabled": true},
+		},
+		{
+			"type":      "document",
+			"title":     "Report",
+			"source":    map[string]interface{}{"type": "base64", "media_type": "application/pdf", "data": base64.StdEncoding.EncodeToString(pdf)},
+			"citations": map[string]interface{}{"enabled": true},
+		},
+		{"type": "text", "text": "What color is the grass, and how did revenue change?"},
+	}
+	if len(requestBody.Messages) != 1 || !reflect.DeepEqual(requestBody.Messages[0].Content, want) {
+		t.Errorf("Expected the document and text blocks %v, got %+v", want, requestBody.Messages)
+	}
+
+	if resp.Text != "According to the documents, the grass is green and revenue grew." {
+		t.Errorf("Expected the text blocks joined, got %q", resp.Text)
+	}
+	wantCitations := []models.Citation{
+		{Type: models.CitationCharLocation, CitedText: "The grass is green.", DocumentIndex: 0, DocumentTitle: "Facts", Start: 0, End: 19, TextStart: 28, TextEnd: 46},
+		{Type: models.CitationPageLocation, CitedText: "Revenue grew 10%.", DocumentIndex: 1, DocumentTitle: "Report", Start: 2, End: 3, TextStart: 46, TextEnd: 64},
+	}
+	if !reflect.DeepEqual(resp.Citations, wantCitations) {
+		t.Fatalf("Expected citations %+v, got %+v", wantCitations, resp.Citations)
+	}
+	if cited := resp.Text[wantCitations[0].TextStart:wantCitations[0].TextEnd]; cited != "the grass is green" {
+		t.Errorf("Expected the citation to locate its text block, got %q", cited)
+	}
+}
+
+func TestAnthropicStreamCitations(t *testing.T) {
+	events := []string{
+		`{"type":"message_start","message":{"usage":{"input_tokens":300}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"The documents say "}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"text","text":"","citations":[]}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"citations_delta","citation":{"type":"char_location","cited_text":"The sky is blue.","document_index":0,"document_title":"Facts","start_char_index":20,"end_char_index":36}}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"the sky "}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"is blue"}}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"content_block_start","index":2,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":2,"delta":{"type":"text_delta","text":"."}}`,
+		`{"type":"content_block_stop","index":2}`,
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":12}}`,
+		`{"type":"message_stop"}`,
+	}
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		for _, event := range events {
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", event)
+		}
+	})
+
+	document := models.NewTextDocumentPart("The grass is green. The sky is blue.")
+	document.Title, document.Citations = "Facts", true
+	input := models.CompletionInput{
+		Messages:  []models.ChatMessage{{Role: "user", Content: "What color is the sky?", ContentParts: []models.ContentPart{document}}},
+		MaxTokens: 100,
+	}
+	stream, err := provider.GenerateCompletionStream(context.Background(), "claude-3-5-haiku-latest", input)
+	if err != nil {
+		t.Fatalf("GenerateCompletionStream failed: %v", err)
+	}
+
+	var text string
+	var done models.StreamingCompletionResponse
+	for chunk := range stream {
+		text += chunk.Text
+		if chunk.Done {
+			done = chunk
+		} else if chunk.Citations != nil {
+			t.Errorf("Expected citations on the Done chunk only, got %+v", chunk)
+		}
+	}
+
+	want := []models.Citation{
+		{Type: models.CitationCharLocation, CitedText: "The sky is blue.", DocumentTitle: "Facts", Start: 20, End: 36, TextStart: 18, TextEnd: 33},
+	}
+	if done.Error != nil || !reflect.DeepEqual(done.Citations, want) {
+		t.Fatalf("Expected citations %+v on the Done chunk, got %+v", want, done)
+	}
+	if cited := text[want[0].TextStart:want[0].TextEnd]; cited != "the sky is blue" {
+		t.Errorf("Expected the citation to locate its text block, got %q", cited)
+	}
+}
+
+func TestAnthropicDocumentValidation(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected an invalid document to be rejected before the request is sent")
+	})
+
+	tests := []struct {
+		name    string
+		part    models.ContentPart
+		wantErr string
+	}{
+		{"TooLarge", models.NewDocumentPart(bytes.Repeat([]byte{0xff}, MaxDocumentSize+1), "application/pdf"), "larger than"},
+		{"UnsupportedType", models.NewDocumentPart([]byte("<html>"), "text/html"), "unsupported document type"},
+		{"InvalidText", models.ContentPart{Type: models.ContentPartDocument, MimeType: "text/plain", Data: "not base64!"}, "invalid base64"},
+		{"UploadedFile", models.NewFilePart("https://generativelanguage.googleapis.com/v1beta/files/abc", "application/pdf"), "uploaded to another provider"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := models.CompletionInput{
+				Messages:  []models.ChatMessage{{Role: "user", Content: "Summarize this", ContentParts: []models.ContentPart{tt.part}}},
+				MaxTokens: 10,
+			}
+			if _, err := provider.GenerateCompletion(context.Background(), "claude-3-5-sonnet-latest", input); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
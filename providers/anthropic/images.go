@@ -0,0 +1,87 @@
+package anthropic
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// MaxImageSize is the largest image, in bytes, the API accepts
+const MaxImageSize = 5 << 20
+
+// imageTypes are the media types of the images the API accepts
+var imageTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+
+// blockSource is the data or the URL of an image or document block
+type blockSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+// partBlocks converts the content parts of a message to text, image and document blocks
+func partBlocks(parts []models.ContentPart) []contentBlock {
+	blocks := make([]contentBlock, 0, len(parts)+1)
+	for _, part := range parts {
+		switch {
+		case part.Type == models.ContentPartDocument:
+			blocks = append(blocks, documentBlock(part))
+		case part.Type != models.ContentPartImage:
+			blocks = append(blocks, contentBlock{Type: "text", Text: part.Text})
+		case part.ImageURL != "":
+			blocks = append(blocks, contentBlock{Type: "image", Source: &blockSource{Type: "url", URL: part.ImageURL}})
+		default:
+			blocks = append(blocks, contentBlock{Type: "image", Source: &blockSource{Type: "base64", MediaType: part.MimeType, Data: part.Data}})
+		}
+	}
+	return blocks
+}
+
+// validateContentParts checks that the content parts of messages are text, images or documents
+// the API accepts, so that a request with an oversized or unsupported image fails before it is sent
+func validateContentParts(messages []models.ChatMessage) error {
+	for i, message := range messages {
+		for j, part := range message.ContentParts {
+			switch part.Type {
+			case models.ContentPartText:
+			case models.ContentPartImage:
+				if part.ImageURL != "" {
+					// The API fetches the image and checks it
+					continue
+				}
+				if !isImageType(part.MimeType) {
+					return fmt.Errorf("message %d part %d: unsupported image type %q; use %s", i, j, part.MimeType, strings.Join(imageTypes, ", "))
+				}
+				if size := imageSize(part.Data); size > MaxImageSize {
+					return fmt.Errorf("message %d part %d: image of %d bytes is larger than the %d byte limit", i, j, size, MaxImageSize)
+				}
+			case models.ContentPartDocument:
+				if err := validateDocument(part); err != nil {
+					return fmt.Errorf("message %d part %d: %w", i, j, err)
+				}
+			default:
+				return fmt.Errorf("message %d part %d: unsupported content part type %q", i, j, part.Type)
+			}
+		}
+	}
+	return nil
+}
+
+// isImageType reports whether the API accepts images of mimeType
+func isImageType(mimeType string) bool {
+	for _, t := range imageTypes {
+		if mimeType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// imageSize returns the size of the image encoded in data, without decoding it
+func imageSize(data string) int {
+	padding := len(data) - len(strings.TrimRight(data, "="))
+	return base64.StdEncoding.DecodedLen(len(data)) - padding
+}
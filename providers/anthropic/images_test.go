@@ -0,0 +1,119 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+func TestAnthropicImages(t *testing.T) {
+	var requestBody struct {
+		Messages []struct {
+			Role    string                   `json:"role"`
+			Content []map[string]interface{} `json:"content"`
+		} `json:"messages"`
+	}
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			t.Errorf("Invalid request body: %v", err)
+		}
+		fmt.Fprint(w, `{"content":[{"type":"text","text":"A red square"}],"usage":{"input_tokens":40,"output_tokens":3}}`)
+	})
+
+	image := []byte("\x89PNG\r\n\x1a\nfake image data")
+	for _, mimeType := range []string{"image/jpeg", "image/png", "image/gif", "image/webp"} {
+		t.Run(mimeType, func(t *testing.T) {
+			input := models.CompletionInput{
+				Messages: []models.ChatMessage{{
+					Role:         "user",
+					Content:      "What is in this image?",
+					ContentParts: []models.ContentPart{models.NewImageBytePart(image, mimeType)},
+				}},
+				MaxTokens: 10,
+			}
+			if _, err := provider.GenerateCompletion(context.Background(), "claude-3-5-sonnet-latest", input); err != nil {
+				t.Fatalf("GenerateCompletion failed: %v", err)
+			}
+
+			want := []map[string]interface{}{
+				{"type": "image", "source": map[string]interface{}{
+					"type":       "base64",
+					"media_type": mimeType,
+					"data":       base64.StdEncoding.EncodeToString(image),
+				}},
+				{"type": "text", "text": "What is in this image?"},
+			}
+			if len(requestBody.Messages) != 1 || !reflect.DeepEqual(requestBody.Messages[0].Content, want) {
+				t.Errorf("Expected the image and text blocks %v, got %+v", want, requestBody.Messages)
+			}
+		})
+	}
+}
+
+func TestAnthropicImageURL(t *testing.T) {
+	var requestBody struct {
+		Messages []struct {
+			Content []map[string]interface{} `json:"content"`
+		} `json:"messages"`
+	}
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&requestBody)
+		fmt.Fprint(w, `{"content":[{"type":"text","text":"A chart"}],"usage":{"input_tokens":40,"output_tokens":2}}`)
+	})
+
+	input := models.CompletionInput{
+		Messages:  []models.ChatMessage{{Role: "user", ContentParts: []models.ContentPart{models.NewImageURLPart("https://example.com/chart.png")}}},
+		MaxTokens: 10,
+	}
+	if _, err := provider.GenerateCompletion(context.Background(), "claude-3-5-sonnet-latest", input); err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+	want := []map[string]interface{}{
+		{"type": "image", "source": map[string]interface{}{"type": "url", "url": "https://example.com/chart.png"}},
+	}
+	if len(requestBody.Messages) != 1 || !reflect.DeepEqual(requestBody.Messages[0].Content, want) {
+		t.Errorf("Expected the image URL block %v, got %+v", want, requestBody.Messages)
+	}
+}
+
+func TestAnthropicImageValidation(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected an invalid image to be rejected before the request is sent")
+	})
+
+	tests := []struct {
+		name    string
+		part    models.ContentPart
+		wantErr string
+	}{
+		{"TooLarge", models.NewImageBytePart(bytes.Repeat([]byte{0xff}, MaxImageSize+1), "image/png"), "larger than"},
+		{"UnsupportedType", models.NewImageBytePart([]byte("BM"), "image/bmp"), "unsupported image type"},
+		{"UnsupportedPart", models.ContentPart{Type: "audio"}, "unsupported content part type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := models.CompletionInput{
+				Messages:  []models.ChatMessage{{Role: "user", Content: "Describe this", ContentParts: []models.ContentPart{tt.part}}},
+				MaxTokens: 10,
+			}
+			if _, err := provider.GenerateCompletion(context.Background(), "claude-3-5-sonnet-latest", input); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected an error containing %q, got %v", tt.wantErr, err)
+			}
+			if _, err := provider.GenerateCompletionStream(context.Background(), "claude-3-5-sonnet-latest", input); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected the stream to fail with %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	if size := imageSize(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, MaxImageSize))); size != MaxImageSize {
+		t.Errorf("Expected an image at the limit to be %d bytes, got %d", MaxImageSize, size)
+	}
+}
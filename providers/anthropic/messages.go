@@ -0,0 +1,65 @@
+package anthropic
+
+import "github.com/1broseidon/gollm/models"
+
+// placeholderUserTurn is the user message inserted before a conversation that starts with the
+// assistant, as the API requires the first message to be from the user
+const placeholderUserTurn = "(continue)"
+
+// requestMessages converts messages, which exclude system messages, to the API format. Unless
+// options.StrictMessageOrder is set, they are made to alternate as the API requires.
+func requestMessages(messages []models.ChatMessage, options models.AnthropicOptions) []apiMessage {
+	result := newMessages(messages)
+	if options.StrictMessageOrder {
+		return result
+	}
+	return alternate(result)
+}
+
+// alternate merges consecutive messages of the same role and inserts a placeholder user turn
+// before a leading assistant message
+func alternate(messages []apiMessage) []apiMessage {
+	var result []apiMessage
+	for _, message := range messages {
+		if len(result) == 0 && message.Role == apiRole(models.RoleAssistant) {
+			result = append(result, apiMessage{Role: apiRole(models.RoleUser), Content: placeholderUserTurn})
+		}
+		if last := len(result) - 1; last >= 0 && result[last].Role == message.Role {
+			result[last].Content = mergeContent(result[last].Content, message.Content)
+			continue
+		}
+		result = append(result, message)
+	}
+	return result
+}
+
+// mergeContent joins the content of two messages: text with a newline, and content with blocks
+// as the blocks of both
+func mergeContent(first, second interface{}) interface{} {
+	firstText, firstIsText := first.(string)
+	secondText, secondIsText := second.(string)
+	if firstIsText && secondIsText {
+		switch {
+		case firstText == "":
+			return secondText
+		case secondText == "":
+			return firstText
+		}
+		return firstText + "\n" + secondText
+	}
+	return append(contentBlocks(first), contentBlocks(second)...)
+}
+
+// contentBlocks returns the content of a message as blocks
+func contentBlocks(content interface{}) []contentBlock {
+	switch content := content.(type) {
+	case []contentBlock:
+		// Copied, as the merged blocks are appended to it
+		return append([]contentBlock(nil), content...)
+	case string:
+		if content != "" {
+			return []contentBlock{{Type: "text", Text: content}}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package anthropic
+
+import (
+	"encoding/json"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// apiMessage is a message in the Messages API format. Content is a string, or content blocks
+// for messages carrying tool calls or results.
+type apiMessage struct {
+	Role    string      `json:"role"`
+	Content interface{} `json:"content"`
+}
+
+// contentBlock is a text, image, tool_use or tool_result block of a message
+type contentBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	Source    *blockSource    `json:"source,omitempty"`
+	Title     string          `json:"title,omitempty"`
+	Citations *citationConfig `json:"citations,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
+}
+
+// toolDefinition is a tool offered to the model. Built-in tools, such as those of computer
+// use, have a Type and no input schema.
+type toolDefinition struct {
+	Type          string          `json:"type,omitempty"`
+	Name          string          `json:"name"`
+	Description   string          `json:"description,omitempty"`
+	InputSchema   json.RawMessage `json:"input_schema,omitempty"`
+	DisplayWidth  int             `json:"display_width_px,omitempty"`
+	DisplayHeight int             `json:"display_height_px,omitempty"`
+}
+
+// toolChoice is the tool_choice of a request
+type toolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+// roles maps message roles to the roles of the API. System messages are sent as the system
+// prompt instead, and tool results are sent in user messages.
+var roles = map[string]string{
+	models.RoleUser:      "user",
+	models.RoleAssistant: "assistant",
+	models.RoleTool:      "user",
+}
+
+// apiRole returns the API role for a message role, or the role itself if it has no mapping
+func apiRole(role string) string {
+	if mapped, ok := roles[role]; ok {
+		return mapped
+	}
+	return role
+}
+
+// newMessages converts messages to the API format. Tool calls become tool_use blocks of the
+// assistant message, and consecutive tool results are sent as tool_result blocks of one user
+// message, as the API has no tool role.
+func newMessages(messages []models.ChatMessage) []apiMessage {
+	var result []apiMessage
+	for _, message := range messages {
+		switch {
+		case message.Role == models.RoleTool:
+			block := contentBlock{Type: "tool_result", ToolUseID: message.ToolCallID, Content: message.Content, IsError: message.IsError}
+			if last := len(result) - 1; last >= 0 && isToolResults(result[last]) {
+				result[last].Content = append(result[last].Content.([]contentBlock), block)
+				continue
+			}
+			result = append(result, apiMessage{Role: apiRole(models.RoleTool), Content: []contentBlock{block}})
+		case len(message.ToolCalls) > 0:
+			var blocks []contentBlock
+			if message.Content != "" {
+				blocks = append(blocks, contentBlock{Type: "text", Text: message.Content})
+			}
+			for _, call := range message.ToolCalls {
+				input := call.Arguments
+				if len(input) == 0 {
+					input = json.RawMessage("{}")
+				}
+				blocks = append(blocks, contentBlock{Type: "tool_use", ID: call.ID, Name: call.Name, Input: input})
+			}
+			result = append(result, apiMessage{Role: apiRole(message.Role), Content: blocks})
+		case len(message.ContentParts) > 0:
+			blocks := partBlocks(message.ContentParts)
+			if message.Content != "" {
+				blocks = append(blocks, contentBlock{Type: "text", Text: message.Content})
+			}
+			result = append(result, apiMessage{Role: apiRole(message.Role), Content: blocks})
+		default:
+			result = append(result, apiMessage{Role: apiRole(message.Role), Content: message.Content})
+		}
+	}
+	return result
+}
+
+// isToolResults reports whether message is a user message of tool_result blocks
+func isToolResults(message apiMessage) bool {
+	blocks, ok := message.Content.([]contentBlock)
+	return ok && message.Role == "user" && len(blocks) > 0 && blocks[0].Type == "tool_result"
+}
+
+// newToolDefinitions converts tools to the API format. The API requires an input schema, so
+// tools without parameters accept any object.
+func newToolDefinitions(tools []models.Tool) []toolDefinition {
+	if len(tools) == 0 {
+		return nil
+	}
+	result := make([]toolDefinition, len(tools))
+	for i, tool := range tools {
+		schema := tool.Parameters
+		if len(schema) == 0 {
+			schema = json.RawMessage(`{"type":"object"}`)
+		}
+		result[i] = toolDefinition{Name: tool.Name, Description: tool.Description, InputSchema: schema}
+	}
+	return result
+}
+
+// newToolChoice converts choice to the API's tool_choice. It returns nil when no tools are offered.
+func newToolChoice(choice *models.ToolChoice, tools []models.Tool) (*toolChoice, error) {
+	if choice != nil {
+		if err := choice.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if len(tools) == 0 {
+		return nil, nil
+	}
+	if choice == nil {
+		return &toolChoice{Type: models.ToolChoiceAuto}, nil
+	}
+	result := &toolChoice{Type: choice.Type}
+	if choice.Type == models.ToolChoiceTool {
+		result.Name = choice.Name
+	}
+	return result, nil
+}
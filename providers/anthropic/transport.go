@@ -0,0 +1,21 @@
+package anthropic
+
+import "github.com/1broseidon/gollm/internal/utils"
+
+// AnthropicTransportConfig sets the connection pool of the HTTP client the provider creates. It is
+// the same type as client.TransportConfig.
+type AnthropicTransportConfig = utils.TransportConfig
+
+// DefaultAnthropicTransportConfig returns the transport settings used unless
+// WithAnthropicTransportConfig is given, the same as client.DefaultTransportConfig
+func DefaultAnthropicTransportConfig() AnthropicTransportConfig {
+	return utils.DefaultTransportConfig()
+}
+
+// WithAnthropicTransportConfig sets the connection pool of the provider's HTTP client. It has no
+// effect with WithHTTPClient, whose client is used as it is.
+func WithAnthropicTransportConfig(cfg AnthropicTransportConfig) AnthropicOption {
+	return func(p *AnthropicProvider) {
+		p.transportConfig = cfg
+	}
+}
@@ -0,0 +1,28 @@
+package anthropic
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAnthropicTransportConfig(t *testing.T) {
+	provider, err := NewAnthropicProvider(WithAPIKey("test-key"))
+	if err != nil {
+		t.Fatalf("Failed to create Anthropic provider: %v", err)
+	}
+	transport, ok := provider.client.Transport.(*http.Transport)
+	if !ok || transport.MaxIdleConnsPerHost != 32 || transport.IdleConnTimeout != 90*time.Second {
+		t.Errorf("Expected the default connection pool, got %+v", provider.client.Transport)
+	}
+
+	cfg := AnthropicTransportConfig{MaxIdleConns: 10, MaxIdleConnsPerHost: 5, IdleConnTimeout: time.Minute, DisableCompression: true}
+	provider, err = NewAnthropicProvider(WithAPIKey("test-key"), WithAnthropicTransportConfig(cfg))
+	if err != nil {
+		t.Fatalf("Failed to create Anthropic provider: %v", err)
+	}
+	transport = provider.client.Transport.(*http.Transport)
+	if transport.MaxIdleConns != 10 || transport.MaxIdleConnsPerHost != 5 || transport.IdleConnTimeout != time.Minute || !transport.DisableCompression {
+		t.Errorf("Expected the transport config to be applied, got %+v", transport)
+	}
+}
@@ -0,0 +1,266 @@
+package googlegemini
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// defaultFilesBaseURL is the REST API endpoint used unless WithFilesBaseURL is given
+const defaultFilesBaseURL = "https://generativelanguage.googleapis.com"
+
+// MaxInlineSize is the largest image or document, in bytes, sent inline in a request. Larger
+// parts are uploaded with the File API and sent by URI.
+const MaxInlineSize = 20 << 20
+
+// uploadExpiryMargin is how long before its expiry an uploaded file stops being reused, so that
+// it doesn't expire while a request is in flight
+const uploadExpiryMargin = time.Hour
+
+// FileMetadata describes a file uploaded with the File API
+type FileMetadata struct {
+	Name           string    `json:"name"` // The resource name, "files/<id>"
+	DisplayName    string    `json:"displayName"`
+	MimeType       string    `json:"mimeType"`
+	SizeBytes      int64     `json:"sizeBytes,string"`
+	CreateTime     time.Time `json:"createTime"`
+	UpdateTime     time.Time `json:"updateTime"`
+	ExpirationTime time.Time `json:"expirationTime"` // Files are deleted after 48 hours
+	SHA256Hash     string    `json:"sha256Hash"`
+	URI            string    `json:"uri"`
+	State          string    `json:"state"` // PROCESSING, ACTIVE or FAILED
+}
+
+// uploadedFile is a file uploaded for a large content part, reused while it hasn't expired
+type uploadedFile struct {
+	uri     string
+	expires time.Time
+}
+
+// UploadFile uploads the content of r, of type mimeType, with the File API and returns its URI,
+// to reference in messages with models.NewFilePart. Files are kept for 48 hours.
+func (p *GoogleGeminiProvider) UploadFile(ctx context.Context, r io.Reader, mimeType, displayName string) (string, error) {
+	file, err := p.uploadFile(ctx, r, mimeType, displayName)
+	if err != nil {
+		return "", err
+	}
+	return file.URI, nil
+}
+
+// uploadFile uploads the content of r with the resumable upload protocol, in a single request
+func (p *GoogleGeminiProvider) uploadFile(ctx context.Context, r io.Reader, mimeType, displayName string) (*FileMetadata, error) {
+	metadata, err := json.Marshal(map[string]interface{}{"file": map[string]string{"display_name": displayName}})
+	if err != nil {
+		return nil, err
+	}
+	req, err := p.newAPIRequest(ctx, http.MethodPost, p.filesBaseURL+"/upload/v1beta/files", bytes.NewReader(metadata))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Goog-Upload-Protocol", "resumable")
+	req.Header.Set("X-Goog-Upload-Command", "start")
+	req.Header.Set("X-Goog-Upload-Header-Content-Type", mimeType)
+	resp, err := p.doAPI(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start upload: %w", err)
+	}
+	resp.Body.Close()
+	uploadURL := resp.Header.Get("X-Goog-Upload-URL")
+	if uploadURL == "" {
+		return nil, errors.New("failed to start upload: no upload URL in response")
+	}
+
+	req, err = p.newAPIRequest(ctx, http.MethodPost, uploadURL, r)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Goog-Upload-Command", "upload, finalize")
+	req.Header.Set("X-Goog-Upload-Offset", "0")
+	resp, err = p.doAPI(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		File FileMetadata `json:"file"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if result.File.URI == "" {
+		return nil, errors.New("failed to upload file: no URI in response")
+	}
+	return &result.File, nil
+}
+
+// GetFileMetadata returns the metadata of an uploaded file, given by URI or by resource name
+func (p *GoogleGeminiProvider) GetFileMetadata(ctx context.Context, fileURI string) (*FileMetadata, error) {
+	req, err := p.newAPIRequest(ctx, http.MethodGet, p.fileURL(fileURI), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.doAPI(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var file FileMetadata
+	if err := json.NewDecoder(resp.Body).Decode(&file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// DeleteFile deletes an uploaded file, given by URI or by resource name, before it expires
+func (p *GoogleGeminiProvider) DeleteFile(ctx context.Context, fileURI string) error {
+	req, err := p.newAPIRequest(ctx, http.MethodDelete, p.fileURL(fileURI), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.doAPI(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	p.uploadsMu.Lock()
+	defer p.uploadsMu.Unlock()
+	for hash, file := range p.uploads {
+		if p.fileURL(file.uri) == p.fileURL(fileURI) {
+			delete(p.uploads, hash)
+		}
+	}
+	return nil
+}
+
+// fileURL returns the File API URL of a file given by URI, such as
+// https://generativelanguage.googleapis.com/v1beta/files/abc, or by resource name, "files/abc"
+func (p *GoogleGeminiProvider) fileURL(fileURI string) string {
+	name := fileURI
+	if i := strings.Index(fileURI, "/files/"); i >= 0 {
+		name = fileURI[i+1:]
+	}
+	return p.filesBaseURL + "/v1beta/" + name
+}
+
+// newAPIRequest creates a REST API request authenticated with the API key
+func (p *GoogleGeminiProvider) newAPIRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Goog-Api-Key", p.apiKey)
+	models.ApplyRequestHeaders(req)
+	return req, nil
+}
+
+// doAPI sends a REST API request, returning an error for any status but 200
+func (p *GoogleGeminiProvider) doAPI(req *http.Request) (*http.Response, error) {
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("Gemini API request failed with status code: %d, body: %s", resp.StatusCode, body)
+	}
+	return resp, nil
+}
+
+// uploadLargeParts returns messages with their images and documents larger than MaxInlineSize
+// replaced by files uploaded with the File API. Files are reused for the same content until
+// they are about to expire.
+func (p *GoogleGeminiProvider) uploadLargeParts(ctx context.Context, messages []models.ChatMessage) ([]models.ChatMessage, error) {
+	var result []models.ChatMessage
+	for i, message := range messages {
+		var parts []models.ContentPart
+		for j, part := range message.ContentParts {
+			if part.FileURI != "" || base64.StdEncoding.DecodedLen(len(part.Data)) <= MaxInlineSize {
+				continue
+			}
+			data, err := base64.StdEncoding.DecodeString(part.Data)
+			if err != nil {
+				return nil, fmt.Errorf("message %d part %d: invalid %s data: %w", i, j, part.Type, err)
+			}
+			if len(data) <= MaxInlineSize {
+				continue
+			}
+			uri, err := p.uploadPart(ctx, data, part.MimeType, fmt.Sprintf("message-%d-part-%d", i, j))
+			if err != nil {
+				return nil, fmt.Errorf("message %d part %d: %w", i, j, err)
+			}
+
+			// Copied, so the caller's messages are left as they are
+			if parts == nil {
+				parts = append([]models.ContentPart(nil), message.ContentParts...)
+			}
+			parts[j].Data, parts[j].FileURI = "", uri
+		}
+		if parts == nil {
+			continue
+		}
+		if result == nil {
+			result = append([]models.ChatMessage(nil), messages...)
+		}
+		result[i].ContentParts = parts
+	}
+	if result == nil {
+		return messages, nil
+	}
+	return result, nil
+}
+
+// uploadPart uploads data, unless the same content was uploaded before and hasn't expired, and
+// returns its URI
+func (p *GoogleGeminiProvider) uploadPart(ctx context.Context, data []byte, mimeType, displayName string) (string, error) {
+	hash := uploadHash(data)
+	p.uploadsMu.Lock()
+	file, ok := p.uploads[hash]
+	p.uploadsMu.Unlock()
+	if ok && (file.expires.IsZero() || time.Now().Add(uploadExpiryMargin).Before(file.expires)) {
+		return file.uri, nil
+	}
+
+	uploaded, err := p.uploadFile(ctx, bytes.NewReader(data), mimeType, displayName)
+	if err != nil {
+		return "", err
+	}
+	p.uploadsMu.Lock()
+	defer p.uploadsMu.Unlock()
+	if p.uploads == nil {
+		p.uploads = make(map[string]uploadedFile)
+	}
+	p.uploads[hash] = uploadedFile{uri: uploaded.URI, expires: uploaded.ExpirationTime}
+	return uploaded.URI, nil
+}
+
+// uploadHash returns the key of data in the cache of uploaded files
+func uploadHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// prepareMessages downloads the images of messages given by URL and uploads the parts too large
+// to send inline
+func (p *GoogleGeminiProvider) prepareMessages(ctx context.Context, messages []models.ChatMessage) ([]models.ChatMessage, error) {
+	messages, err := fetchImages(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	return p.uploadLargeParts(ctx, messages)
+}
@@ -0,0 +1,265 @@
+package googlegemini
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// fileServer is a fake File API that keeps uploaded files in memory
+type fileServer struct {
+	*httptest.Server
+	t          *testing.T
+	mu         sync.Mutex
+	files      map[string][]byte // Keyed by resource name
+	mimeTypes  map[string]string
+	uploads    int
+	expiration time.Time
+	requests   []restRequest // The generateContent requests received
+}
+
+func newFileServer(t *testing.T) *fileServer {
+	s := &fileServer{t: t, files: map[string][]byte{}, mimeTypes: map[string]string{}, expiration: time.Now().Add(48 * time.Hour)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fileServer) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Goog-Api-Key") != "test-key" {
+		http.Error(w, `{"error":{"code":403}}`, http.StatusForbidden)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/upload/v1beta/files":
+		var metadata struct {
+			File struct {
+				DisplayName string `json:"display_name"`
+			} `json:"file"`
+		}
+		json.NewDecoder(r.Body).Decode(&metadata)
+		if r.Header.Get("X-Goog-Upload-Protocol") != "resumable" || r.Header.Get("X-Goog-Upload-Command") != "start" || metadata.File.DisplayName == "" {
+			s.t.Errorf("Unexpected upload start: %v %+v", r.Header, metadata)
+		}
+		s.uploads++
+		name := fmt.Sprintf("files/file-%d", s.uploads)
+		s.mimeTypes[name] = r.Header.Get("X-Goog-Upload-Header-Content-Type")
+		w.Header().Set("X-Goog-Upload-URL", s.URL+"/upload/session/"+name)
+	case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/upload/session/"):
+		if r.Header.Get("X-Goog-Upload-Command") != "upload, finalize" || r.Header.Get("X-Goog-Upload-Offset") != "0" {
+			s.t.Errorf("Unexpected upload headers: %v", r.Header)
+		}
+		name := strings.TrimPrefix(r.URL.Path, "/upload/session/")
+		data, _ := io.ReadAll(r.Body)
+		s.files[name] = data
+		json.NewEncoder(w).Encode(map[string]interface{}{"file": s.metadata(name)})
+	case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/v1beta/models/"):
+		var request restRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		s.requests = append(s.requests, request)
+		if strings.HasSuffix(r.URL.Path, ":streamGenerateContent") && r.URL.Query().Get("alt") == "sse" {
+			fmt.Fprint(w, "data: {\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":\"A quarterly\"}]}}]}\r\n\r\n")
+			fmt.Fprint(w, "data: {\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":\" report.\"}]}}]}")
+			return
+		}
+		if r.URL.Path != "/v1beta/models/gemini-1.5-flash:generateContent" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"candidates":[{"content":{"role":"model","parts":[{"text":"A quarterly report."}]}}],"usageMetadata":{"promptTokenCount":300,"candidatesTokenCount":4,"totalTokenCount":304},"modelVersion":"gemini-1.5-flash-002"}`)
+	case strings.HasPrefix(r.URL.Path, "/v1beta/"):
+		name := strings.TrimPrefix(r.URL.Path, "/v1beta/")
+		if _, ok := s.files[name]; !ok {
+			http.Error(w, `{"error":{"code":404,"status":"NOT_FOUND"}}`, http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(s.files, name)
+			w.Write([]byte(`{}`))
+			return
+		}
+		json.NewEncoder(w).Encode(s.metadata(name))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// metadata returns the JSON metadata of the file with the given resource name
+func (s *fileServer) metadata(name string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":           name,
+		"displayName":    "doc",
+		"mimeType":       s.mimeTypes[name],
+		"sizeBytes":      fmt.Sprint(len(s.files[name])),
+		"createTime":     "2024-11-01T10:00:00.000000Z",
+		"expirationTime": s.expiration.UTC().Format(time.RFC3339Nano),
+		"uri":            "https://generativelanguage.googleapis.com/v1beta/" + name,
+		"state":          "ACTIVE",
+	}
+}
+
+// newFileProvider returns a provider using the fake File API
+func newFileProvider(t *testing.T, server *fileServer) *GoogleGeminiProvider {
+	provider, err := NewGoogleGeminiProvider(context.Background(), WithAPIKey("test-key"), WithFilesBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create Google Gemini provider: %v", err)
+	}
+	t.Cleanup(func() { provider.Close() })
+	return provider
+}
+
+func TestGoogleGeminiFiles(t *testing.T) {
+	ctx := context.Background()
+	server := newFileServer(t)
+	provider := newFileProvider(t, server)
+
+	uri, err := provider.UploadFile(ctx, bytes.NewReader([]byte("%PDF-1.7 report")), "application/pdf", "report.pdf")
+	if err != nil {
+		t.Fatalf("UploadFile failed: %v", err)
+	}
+	if uri != "https://generativelanguage.googleapis.com/v1beta/files/file-1" {
+		t.Errorf("Unexpected URI %q", uri)
+	}
+
+	metadata, err := provider.GetFileMetadata(ctx, uri)
+	if err != nil {
+		t.Fatalf("GetFileMetadata failed: %v", err)
+	}
+	if metadata.Name != "files/file-1" || metadata.MimeType != "application/pdf" || metadata.SizeBytes != 15 || metadata.State != "ACTIVE" || metadata.ExpirationTime.IsZero() {
+		t.Errorf("Unexpected metadata %+v", metadata)
+	}
+
+	if err := provider.DeleteFile(ctx, uri); err != nil {
+		t.Fatalf("DeleteFile failed: %v", err)
+	}
+	if _, err := provider.GetFileMetadata(ctx, "files/file-1"); err == nil {
+		t.Error("Expected an error for a deleted file")
+	}
+	if err := provider.DeleteFile(ctx, uri); err == nil {
+		t.Error("Expected an error deleting a missing file")
+	}
+}
+
+func TestUploadLargeParts(t *testing.T) {
+	ctx := context.Background()
+	server := newFileServer(t)
+	provider := newFileProvider(t, server)
+
+	large := bytes.Repeat([]byte("a"), MaxInlineSize+1)
+	small := []byte("%PDF-1.7 small")
+	messages := []models.ChatMessage{{Role: models.RoleUser, Content: "Summarize these", ContentParts: []models.ContentPart{
+		models.NewDocumentPart(small, "application/pdf"),
+		models.NewDocumentPart(large, "application/pdf"),
+	}}}
+
+	prepared, err := provider.prepareMessages(ctx, messages)
+	if err != nil {
+		t.Fatalf("prepareMessages failed: %v", err)
+	}
+	parts := prepared[0].ContentParts
+	if parts[0].FileURI != "" || parts[0].Data == "" {
+		t.Errorf("Expected the small document to stay inline, got %+v", parts[0])
+	}
+	if parts[1].FileURI != "https://generativelanguage.googleapis.com/v1beta/files/file-1" || parts[1].Data != "" {
+		t.Errorf("Expected the large document to be uploaded, got URI %q", parts[1].FileURI)
+	}
+	if messages[0].ContentParts[1].FileURI != "" {
+		t.Error("Expected the caller's messages to be left as they are")
+	}
+	if !bytes.Equal(server.files["files/file-1"], large) {
+		t.Error("Expected the uploaded file to hold the document")
+	}
+
+	if !hasFileParts(prepared) {
+		t.Error("Expected the messages to be sent through the REST API")
+	}
+	apiParts := restParts(prepared[0])
+	if apiParts[1].FileData == nil || apiParts[1].FileData.FileURI != parts[1].FileURI || apiParts[1].FileData.MimeType != "application/pdf" {
+		t.Errorf("Expected the uploaded document to be sent by URI, got %+v", apiParts[1])
+	}
+	if apiParts[0].InlineData == nil || apiParts[0].InlineData.Data != base64.StdEncoding.EncodeToString(small) {
+		t.Errorf("Expected the small document to be sent inline, got %+v", apiParts[0])
+	}
+
+	// The same content is uploaded once while the file hasn't expired
+	if _, err := provider.prepareMessages(ctx, messages); err != nil {
+		t.Fatalf("prepareMessages failed: %v", err)
+	}
+	if server.uploads != 1 {
+		t.Errorf("Expected unchanged documents not to be uploaded again, got %d uploads", server.uploads)
+	}
+
+	// Files about to expire, or deleted, are uploaded again
+	provider.uploads[uploadHash(large)] = uploadedFile{uri: parts[1].FileURI, expires: time.Now().Add(time.Minute)}
+	if _, err := provider.prepareMessages(ctx, messages); err != nil {
+		t.Fatalf("prepareMessages failed: %v", err)
+	}
+	if err := provider.DeleteFile(ctx, "files/file-2"); err != nil {
+		t.Fatalf("DeleteFile failed: %v", err)
+	}
+	if _, err := provider.prepareMessages(ctx, messages); err != nil {
+		t.Fatalf("prepareMessages failed: %v", err)
+	}
+	if server.uploads != 3 {
+		t.Errorf("Expected expiring and deleted files to be uploaded again, got %d uploads", server.uploads)
+	}
+}
+
+func TestGoogleGeminiFileCompletion(t *testing.T) {
+	ctx := context.Background()
+	server := newFileServer(t)
+	provider := newFileProvider(t, server)
+	const uri = "https://generativelanguage.googleapis.com/v1beta/files/report"
+	input := models.CompletionInput{
+		Messages: []models.ChatMessage{
+			{Role: models.RoleSystem, Content: "Be brief."},
+			{Role: models.RoleUser, Content: "What is this?", ContentParts: []models.ContentPart{models.NewFilePart(uri, "application/pdf")}},
+		},
+		MaxTokens: 100,
+	}
+
+	resp, err := provider.GenerateCompletion(ctx, "gemini-1.5-flash", input)
+	if err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+	if resp.Text != "A quarterly report." || resp.Model != "gemini-1.5-flash-002" || resp.Usage.PromptTokens != 300 || resp.Usage.TotalTokens != 304 {
+		t.Errorf("Unexpected response %+v", resp)
+	}
+	request := server.requests[0]
+	if request.SystemInstruction == nil || request.SystemInstruction.Parts[0].Text != "Be brief." || request.GenerationConfig.MaxOutputTokens != 100 {
+		t.Errorf("Expected the system instruction and generation config to be sent, got %+v", request)
+	}
+	if len(request.Contents) != 1 || request.Contents[0].Role != "user" || request.Contents[0].Parts[0].FileData == nil ||
+		request.Contents[0].Parts[0].FileData.FileURI != uri || request.Contents[0].Parts[1].Text != "What is this?" {
+		t.Errorf("Expected the file to be referenced by URI, got %+v", request.Contents)
+	}
+
+	stream, err := provider.GenerateCompletionStream(ctx, "gemini-1.5-flash", input)
+	if err != nil {
+		t.Fatalf("GenerateCompletionStream failed: %v", err)
+	}
+	var acc models.StreamAccumulator
+	for chunk := range stream {
+		acc.Add(chunk)
+	}
+	if text, _, _ := acc.Result(); text != "A quarterly report." || acc.Err() != nil {
+		t.Errorf("Expected the streamed text, got %q, %v", text, acc.Err())
+	}
+	if len(server.requests) != 2 || server.requests[1].Contents[0].Parts[0].FileData == nil {
+		t.Errorf("Expected the stream to reference the file, got %+v", server.requests)
+	}
+}
@@ -4,25 +4,89 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
+	"net/http"
 	"strings"
+	"sync"
 
+	"github.com/1broseidon/gollm/internal/credentials"
+	"github.com/1broseidon/gollm/internal/utils"
 	"github.com/1broseidon/gollm/models"
 	"github.com/google/generative-ai-go/genai"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
 
+// ThinkingDelimiter prefixes the text parts that carry a thinking model's reasoning
+const ThinkingDelimiter = "Thinking:"
+
+// thinkingModelPrefix identifies the models that support thinking mode
+const thinkingModelPrefix = "gemini-2.0-flash-thinking-exp"
+
+// SupportsThinking reports whether the model supports thinking mode
+func SupportsThinking(modelName string) bool {
+	return strings.HasPrefix(modelName, thinkingModelPrefix)
+}
+
 // GoogleGeminiProvider implements the Google Gemini-specific functionality
 type GoogleGeminiProvider struct {
-	client *genai.Client
+	client    *genai.Client
+	closeOnce sync.Once
+
+	// The File API and file references aren't in the SDK, so the REST API is called directly
+	apiKey       string
+	filesBaseURL string
+	httpClient   *http.Client
+	uploadsMu    sync.Mutex
+	uploads      map[string]uploadedFile // Keyed by the SHA-256 of the content
+}
+
+// googleGeminiConfig holds the settings applied by GoogleGeminiOptions
+type googleGeminiConfig struct {
+	apiKey       string
+	filesBaseURL string
+	httpClient   *http.Client
+}
+
+// GoogleGeminiOption configures a GoogleGeminiProvider
+type GoogleGeminiOption func(*googleGeminiConfig)
+
+// WithAPIKey sets the API key, overriding GEMINI_API_KEY and the credentials file
+func WithAPIKey(apiKey string) GoogleGeminiOption {
+	return func(cfg *googleGeminiConfig) {
+		cfg.apiKey = apiKey
+	}
 }
 
-// NewGoogleGeminiProvider creates a new Google Gemini provider
-func NewGoogleGeminiProvider(ctx context.Context) (*GoogleGeminiProvider, error) {
-	apiKey, found := os.LookupEnv("GEMINI_API_KEY")
-	if !found {
-		return nil, fmt.Errorf("GEMINI_API_KEY environment variable is not set")
+// WithFilesBaseURL sets the URL of the REST API, which defaults to
+// https://generativelanguage.googleapis.com. It serves the File API and the completions whose
+// messages reference uploaded files; other completions use the SDK's endpoint.
+func WithFilesBaseURL(baseURL string) GoogleGeminiOption {
+	return func(cfg *googleGeminiConfig) {
+		cfg.filesBaseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for REST API requests
+func WithHTTPClient(client *http.Client) GoogleGeminiOption {
+	return func(cfg *googleGeminiConfig) {
+		cfg.httpClient = client
+	}
+}
+
+// NewGoogleGeminiProvider creates a new Google Gemini provider. Unless WithAPIKey is given, the API
+// key is read from GEMINI_API_KEY, or from gemini_api_key in ~/.config/gollm/credentials.
+func NewGoogleGeminiProvider(ctx context.Context, opts ...GoogleGeminiOption) (*GoogleGeminiProvider, error) {
+	cfg := googleGeminiConfig{filesBaseURL: defaultFilesBaseURL, httpClient: http.DefaultClient}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	apiKey := cfg.apiKey
+	if apiKey == "" {
+		apiKey = credentials.Lookup("GEMINI_API_KEY")
+	}
+	if apiKey == "" {
+		return nil, fmt.Errorf("GEMINI_API_KEY is not set in the environment or the credentials file")
 	}
 
 	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
@@ -31,38 +95,56 @@ func NewGoogleGeminiProvider(ctx context.Context) (*GoogleGeminiProvider, error)
 	}
 
 	return &GoogleGeminiProvider{
-		client: client,
+		client:       client,
+		apiKey:       apiKey,
+		filesBaseURL: cfg.filesBaseURL,
+		httpClient:   cfg.httpClient,
 	}, nil
 }
 
-// Close closes the Google Gemini client
+// Close closes the Google Gemini client. Subsequent calls do nothing and return nil.
 func (p *GoogleGeminiProvider) Close() error {
-	return p.client.Close()
+	var err error
+	p.closeOnce.Do(func() { err = p.client.Close() })
+	return err
 }
 
 // GenerateCompletion generates a completion using the specified Google Gemini model
 func (p *GoogleGeminiProvider) GenerateCompletion(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+	// The SDK doesn't expose per-request headers, so only the context's timeout applies
+	ctx, cancel := models.ApplyRequestTimeout(ctx)
+	defer cancel()
+
 	model := p.client.GenerativeModel(modelName)
-	model.SetTemperature(float32(input.Temperature))
-	p.SetMaxOutputTokens(model, input.MaxTokens)
+	p.setGenerationConfig(model, input)
+	// The genai SDK version in use has no ThinkingConfig, so the budget can't be forwarded yet;
+	// thinking models reason regardless, and the option only enables parsing of their thoughts.
+	// It has no Tools or ToolConfig either, so input.Tools and input.ToolChoice are ignored.
 
-	prompt := genai.Text(input.Messages[len(input.Messages)-1].Content)
-	resp, err := model.GenerateContent(ctx, prompt)
+	messages, err := p.prepareMessages(ctx, input.Messages)
+	if err != nil {
+		return nil, err
+	}
+	if hasFileParts(messages) {
+		return p.generateContentREST(ctx, modelName, input, messages)
+	}
+	chat := model.StartChat()
+	chat.History = chatHistory(messages)
+	resp, err := chat.SendMessage(ctx, promptParts(messages)...)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(resp.Candidates) == 0 {
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
 		return nil, errors.New("no content generated")
 	}
 
-	generatedText, ok := resp.Candidates[0].Content.Parts[0].(genai.Text)
-	if !ok {
-		return nil, errors.New("unexpected content type in response")
+	thinking := input.ProviderOptions.GoogleGemini.ThinkingBudget != nil
+	generatedString, thinkingText, err := splitThinking(resp.Candidates[0].Content.Parts, thinking)
+	if err != nil {
+		return nil, err
 	}
 
-	generatedString := string(generatedText)
-
 	inputTokenCount, err := p.CountTokens(ctx, modelName, input.Messages[len(input.Messages)-1].Content)
 	if err != nil {
 		return nil, err
@@ -73,8 +155,11 @@ func (p *GoogleGeminiProvider) GenerateCompletion(ctx context.Context, modelName
 		return nil, err
 	}
 
+	// The genai SDK version in use doesn't report the model version, so the requested model is echoed
 	return &models.CompletionResponse{
-		Text: generatedString,
+		Text:         generatedString,
+		ThinkingText: thinkingText,
+		Model:        modelName,
 		Usage: &models.Usage{
 			PromptTokens:     inputTokenCount,
 			CompletionTokens: outputTokenCount,
@@ -86,47 +171,130 @@ func (p *GoogleGeminiProvider) GenerateCompletion(ctx context.Context, modelName
 // GenerateCompletionStream generates a streaming completion using the specified Google Gemini model
 func (p *GoogleGeminiProvider) GenerateCompletionStream(ctx context.Context, modelName string, input models.CompletionInput) (<-chan models.StreamingCompletionResponse, error) {
 	model := p.client.GenerativeModel(modelName)
-	model.SetTemperature(float32(input.Temperature))
-	p.SetMaxOutputTokens(model, input.MaxTokens)
+	p.setGenerationConfig(model, input)
 
-	prompt := genai.Text(input.Messages[len(input.Messages)-1].Content)
-	iter := model.GenerateContentStream(ctx, prompt)
+	requestCtx, cancel := models.ApplyRequestTimeout(ctx)
+	messages, err := p.prepareMessages(requestCtx, input.Messages)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if hasFileParts(messages) {
+		return p.streamContentREST(ctx, requestCtx, cancel, modelName, input, messages)
+	}
+	chat := model.StartChat()
+	chat.History = chatHistory(messages)
+	iter := chat.SendMessageStream(requestCtx, promptParts(messages)...)
+	thinking := input.ProviderOptions.GoogleGemini.ThinkingBudget != nil
 
-	streamChan := make(chan models.StreamingCompletionResponse)
+	streamChan := utils.NewStream(input.StreamBufferSize)
 
 	go func() {
 		defer close(streamChan)
-
-		for {
-			resp, err := iter.Next()
-			if err == iterator.Done {
-				streamChan <- models.StreamingCompletionResponse{Done: true}
-				return
-			}
-			if err != nil {
-				streamChan <- models.StreamingCompletionResponse{Error: err}
-				return
-			}
-
-			if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
-				continue
-			}
-
-			text, ok := resp.Candidates[0].Content.Parts[0].(genai.Text)
-			if !ok {
-				streamChan <- models.StreamingCompletionResponse{Error: errors.New("unexpected content type in response")}
-				return
-			}
-
-			streamChan <- models.StreamingCompletionResponse{
-				Text: string(text),
-			}
-		}
+		defer cancel()
+		sendStream(ctx, streamChan, iter.Next, modelName, thinking)
 	}()
 
 	return streamChan, nil
 }
 
+// sendStream sends the responses returned by next as chunks on streamChan, until next returns
+// iterator.Done or an error
+func sendStream(ctx context.Context, streamChan chan<- models.StreamingCompletionResponse, next func() (*genai.GenerateContentResponse, error), modelName string, thinking bool) {
+	for {
+		resp, err := next()
+		if err == iterator.Done {
+			// The genai SDK version in use reports no usage for streams
+			utils.SendChunk(ctx, streamChan, models.StreamingCompletionResponse{Done: true, Model: modelName})
+			return
+		}
+		if err != nil {
+			utils.SendError(ctx, streamChan, err)
+			return
+		}
+
+		if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil || len(resp.Candidates[0].Content.Parts) == 0 {
+			continue
+		}
+
+		text, thinkingText, err := splitThinking(resp.Candidates[0].Content.Parts, thinking)
+		if err != nil {
+			utils.SendError(ctx, streamChan, err)
+			return
+		}
+
+		if !utils.SendChunk(ctx, streamChan, models.StreamingCompletionResponse{
+			Text:         text,
+			ThinkingText: thinkingText,
+		}) {
+			return
+		}
+	}
+}
+
+// promptParts returns the prompt for a completion: the last non-system message, preceded by the
+// system messages combined into one instruction. The genai SDK version in use has no
+// SystemInstruction, so the instruction is sent as the first part of the prompt.
+func promptParts(messages []models.ChatMessage) []genai.Part {
+	system, rest := models.JoinSystemMessages(messages)
+	var parts []genai.Part
+	if system != "" {
+		parts = append(parts, genai.Text(system))
+	}
+	if len(rest) > 0 {
+		parts = append(parts, messageParts(rest[len(rest)-1])...)
+	}
+	return parts
+}
+
+// roles maps message roles to the roles of the API. The genai SDK version in use has no tools,
+// so tool results are sent as user messages.
+var roles = map[string]string{
+	models.RoleUser:      "user",
+	models.RoleAssistant: "model",
+	models.RoleTool:      "user",
+}
+
+// apiRole returns the API role for a message role, or the role itself if it has no mapping
+func apiRole(role string) string {
+	if mapped, ok := roles[role]; ok {
+		return mapped
+	}
+	return role
+}
+
+// chatHistory returns the non-system messages before the last one, which promptParts sends, as
+// the history of the chat
+func chatHistory(messages []models.ChatMessage) []*genai.Content {
+	_, rest := models.JoinSystemMessages(messages)
+	if len(rest) <= 1 {
+		return nil
+	}
+	history := make([]*genai.Content, len(rest)-1)
+	for i, message := range rest[:len(rest)-1] {
+		history[i] = &genai.Content{Role: apiRole(message.Role), Parts: messageParts(message)}
+	}
+	return history
+}
+
+// splitThinking concatenates the text parts of a response. When thinking is enabled, parts
+// starting with ThinkingDelimiter are returned separately, with the delimiter removed.
+func splitThinking(parts []genai.Part, thinking bool) (text string, thinkingText string, err error) {
+	var textBuilder, thinkingBuilder strings.Builder
+	for _, part := range parts {
+		partText, ok := part.(genai.Text)
+		if !ok {
+			return "", "", errors.New("unexpected content type in response")
+		}
+		if thinking && strings.HasPrefix(string(partText), ThinkingDelimiter) {
+			thinkingBuilder.WriteString(strings.TrimLeft(strings.TrimPrefix(string(partText), ThinkingDelimiter), " "))
+			continue
+		}
+		textBuilder.WriteString(string(partText))
+	}
+	return textBuilder.String(), thinkingBuilder.String(), nil
+}
+
 // CountTokens counts the number of tokens in the given content
 func (p *GoogleGeminiProvider) CountTokens(ctx context.Context, modelName string, content string) (int, error) {
 	model := p.client.GenerativeModel(modelName)
@@ -152,25 +320,50 @@ func (p *GoogleGeminiProvider) SetMaxOutputTokens(model *genai.GenerativeModel,
 	}
 }
 
-// GenerateEmbedding generates an embedding using the Google Gemini model
-func (p *GoogleGeminiProvider) GenerateEmbedding(ctx context.Context, input string) ([]float32, error) {
-	// TODO: Implement embedding generation
-	return nil, errors.New("embedding generation not implemented")
+// setGenerationConfig applies the sampling options of input to the model, leaving the unset
+// ones at the model's defaults
+func (p *GoogleGeminiProvider) setGenerationConfig(model *genai.GenerativeModel, input models.CompletionInput) {
+	p.SetMaxOutputTokens(model, input.MaxTokens)
+	if input.Temperature != nil {
+		model.SetTemperature(*input.Temperature)
+	}
+	if input.TopP != nil {
+		model.SetTopP(*input.TopP)
+	}
+	if len(input.Stop) > 0 {
+		model.StopSequences = input.Stop
+	}
+}
+
+// Capabilities reports what the Google Gemini provider supports beyond completions
+func (p *GoogleGeminiProvider) Capabilities() models.Capabilities {
+	return models.Capabilities{Streaming: true, Vision: true, Chat: true}
+}
+
+// ChatSession is a chat session kept by the SDK, started with StartChat
+type ChatSession struct {
+	session *genai.ChatSession
+	model   string
+}
+
+// Model returns the model the session talks to
+func (s *ChatSession) Model() string {
+	return s.model
 }
 
-// StartChat starts a new chat session
-func (p *GoogleGeminiProvider) StartChat(modelName string) interface{} {
+// StartChat starts a new chat session with the model
+func (p *GoogleGeminiProvider) StartChat(modelName string) models.ProviderChatSession {
 	model := p.client.GenerativeModel(modelName)
-	return model.StartChat()
+	return &ChatSession{session: model.StartChat(), model: modelName}
 }
 
-// SendChatMessage sends a message to an existing chat session
-func (p *GoogleGeminiProvider) SendChatMessage(ctx context.Context, session interface{}, message string) (*models.CompletionResponse, error) {
-	chatSession, ok := session.(*genai.ChatSession)
+// SendChatMessage sends a message to a chat session started with StartChat
+func (p *GoogleGeminiProvider) SendChatMessage(ctx context.Context, session models.ProviderChatSession, message string) (*models.CompletionResponse, error) {
+	chatSession, ok := session.(*ChatSession)
 	if !ok {
 		return nil, errors.New("invalid chat session type")
 	}
-	resp, err := chatSession.SendMessage(ctx, genai.Text(message))
+	resp, err := chatSession.session.SendMessage(ctx, genai.Text(message))
 	if err != nil {
 		return nil, err
 	}
@@ -190,7 +383,8 @@ func (p *GoogleGeminiProvider) SendChatMessage(ctx context.Context, session inte
 	completionTokens := int32(len(strings.Split(generatedText, " "))) // Rough estimate
 
 	return &models.CompletionResponse{
-		Text: generatedText,
+		Text:  generatedText,
+		Model: chatSession.model,
 		Usage: &models.Usage{
 			PromptTokens:     int(promptTokens),
 			CompletionTokens: int(completionTokens),
@@ -2,10 +2,18 @@ package googlegemini
 
 import (
 	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/1broseidon/gollm/models"
+	"github.com/1broseidon/gollm/providers/providertest"
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/iterator"
 )
 
 func TestGoogleGeminiProvider(t *testing.T) {
@@ -28,7 +36,7 @@ func TestGoogleGeminiProvider(t *testing.T) {
 				{Role: "user", Content: "Explain the concept of quantum computing in simple terms."},
 			},
 			MaxTokens:   100,
-			Temperature: 0.7,
+			Temperature: models.Float32(0.7),
 		}
 
 		response, err := provider.GenerateCompletion(ctx, "gemini-1.5-pro", input)
@@ -39,6 +47,9 @@ func TestGoogleGeminiProvider(t *testing.T) {
 		if response.Text == "" {
 			t.Error("Generated text is empty")
 		}
+		if response.Model != "gemini-1.5-pro" {
+			t.Errorf("Expected the requested model, got %q", response.Model)
+		}
 
 		if response.Usage == nil {
 			t.Error("Usage information is missing")
@@ -61,7 +72,7 @@ func TestGoogleGeminiProvider(t *testing.T) {
 				{Role: "user", Content: "Explain the concept of neural networks in simple terms."},
 			},
 			MaxTokens:   100,
-			Temperature: 0.7,
+			Temperature: models.Float32(0.7),
 			Stream:      true,
 		}
 
@@ -77,6 +88,9 @@ func TestGoogleGeminiProvider(t *testing.T) {
 			}
 			fullText += chunk.Text
 			if chunk.Done {
+				if chunk.Model != "gemini-1.5-pro" {
+					t.Errorf("Expected the requested model on the Done chunk, got %q", chunk.Model)
+				}
 				break
 			}
 		}
@@ -113,3 +127,348 @@ func TestGoogleGeminiProvider(t *testing.T) {
 		}
 	})
 }
+
+func TestSplitThinking(t *testing.T) {
+	parts := []genai.Part{
+		genai.Text(ThinkingDelimiter + " The user wants a haiku."),
+		genai.Text("Autumn moonlight—"),
+		genai.Text(" a worm digs silently"),
+	}
+
+	text, thinking, err := splitThinking(parts, true)
+	if err != nil {
+		t.Fatalf("splitThinking failed: %v", err)
+	}
+	if text != "Autumn moonlight— a worm digs silently" {
+		t.Errorf("Unexpected text: %q", text)
+	}
+	if thinking != "The user wants a haiku." {
+		t.Errorf("Unexpected thinking text: %q", thinking)
+	}
+
+	text, thinking, err = splitThinking(parts, false)
+	if err != nil {
+		t.Fatalf("splitThinking failed: %v", err)
+	}
+	if thinking != "" || text != ThinkingDelimiter+" The user wants a haiku.Autumn moonlight— a worm digs silently" {
+		t.Errorf("Expected thinking parts to stay in the text when thinking is disabled, got text=%q thinking=%q", text, thinking)
+	}
+}
+
+func TestGoogleGeminiThinking(t *testing.T) {
+	// Skip the test if GEMINI_API_KEY is not set
+	if os.Getenv("GEMINI_API_KEY") == "" {
+		t.Skip("GEMINI_API_KEY not set, skipping Google Gemini thinking test")
+	}
+
+	ctx := context.Background()
+
+	provider, err := NewGoogleGeminiProvider(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create Google Gemini provider: %v", err)
+	}
+	defer provider.Close()
+
+	budget := int32(1024)
+	input := models.CompletionInput{
+		Messages: []models.ChatMessage{
+			{Role: "user", Content: "How many prime numbers are there between 10 and 30?"},
+		},
+		MaxTokens: 2048,
+		ProviderOptions: models.ProviderOptions{
+			GoogleGemini: models.GoogleGeminiOptions{ThinkingBudget: &budget},
+		},
+	}
+
+	response, err := provider.GenerateCompletion(ctx, "gemini-2.0-flash-thinking-exp", input)
+	if err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+
+	if response.Text == "" {
+		t.Error("Generated text is empty")
+	}
+}
+
+func TestSetGenerationConfig(t *testing.T) {
+	p := &GoogleGeminiProvider{}
+
+	unset := &genai.GenerativeModel{}
+	p.setGenerationConfig(unset, models.CompletionInput{})
+	if unset.Temperature != nil || unset.TopP != nil || unset.MaxOutputTokens != nil || unset.StopSequences != nil {
+		t.Errorf("Expected the model defaults to be kept, got %+v", unset.GenerationConfig)
+	}
+
+	set := &genai.GenerativeModel{}
+	p.setGenerationConfig(set, models.CompletionInput{MaxTokens: 100, Temperature: models.Float32(0), TopP: models.Float32(0.9), Stop: []string{"END"}})
+	if set.Temperature == nil || *set.Temperature != 0 {
+		t.Errorf("Expected temperature 0 to be set, got %v", set.Temperature)
+	}
+	if set.TopP == nil || *set.TopP != 0.9 || set.MaxOutputTokens == nil || *set.MaxOutputTokens != 100 {
+		t.Errorf("Expected top_p 0.9 and 100 output tokens, got %+v", set.GenerationConfig)
+	}
+	if len(set.StopSequences) != 1 || set.StopSequences[0] != "END" {
+		t.Errorf("Expected the stop sequence to be set, got %v", set.StopSequences)
+	}
+}
+
+func TestPromptParts(t *testing.T) {
+	parts := promptParts([]models.ChatMessage{
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: "Earlier question"},
+		{Role: "assistant", Content: "Earlier answer"},
+		{Role: "system", Content: "Use metric units."},
+		{Role: "user", Content: "How far is the moon?"},
+	})
+
+	if len(parts) != 2 {
+		t.Fatalf("Expected the system instruction and the prompt, got %d parts", len(parts))
+	}
+	if parts[0] != genai.Text("Be brief.\n\nUse metric units.") || parts[1] != genai.Text("How far is the moon?") {
+		t.Errorf("Unexpected prompt parts: %v", parts)
+	}
+}
+
+func TestChatHistory(t *testing.T) {
+	history := chatHistory([]models.ChatMessage{
+		{Role: models.RoleSystem, Content: "Be brief."},
+		{Role: models.RoleUser, Content: "Weather in Paris?"},
+		{Role: models.RoleAssistant, Content: "Let me check."},
+		{Role: models.RoleTool, Content: "sunny"},
+		{Role: models.RoleAssistant, Content: "Sunny."},
+		{Role: models.RoleUser, Content: "And tomorrow?"},
+	})
+
+	tests := []struct {
+		role string
+		text string
+	}{
+		{"user", "Weather in Paris?"},
+		{"model", "Let me check."},
+		{"user", "sunny"},
+		{"model", "Sunny."},
+	}
+	if len(history) != len(tests) {
+		t.Fatalf("Expected %d history entries without the system and last messages, got %d", len(tests), len(history))
+	}
+	for i, tt := range tests {
+		if history[i].Role != tt.role || len(history[i].Parts) != 1 || history[i].Parts[0] != genai.Text(tt.text) {
+			t.Errorf("Expected entry %d to be %q from %q, got %+v", i, tt.text, tt.role, history[i])
+		}
+	}
+
+	if history := chatHistory([]models.ChatMessage{{Role: models.RoleUser, Content: "Hi"}}); history != nil {
+		t.Errorf("Expected no history for a single message, got %v", history)
+	}
+}
+
+func TestGoogleGeminiCloseTwice(t *testing.T) {
+	provider, err := NewGoogleGeminiProvider(context.Background(), WithAPIKey("test-key"))
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	if err := provider.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := provider.Close(); err != nil {
+		t.Errorf("Expected nil from a second Close, got %v", err)
+	}
+}
+
+// recordedStream returns a next function replaying responses, then err
+func recordedStream(err error, responses ...*genai.GenerateContentResponse) func() (*genai.GenerateContentResponse, error) {
+	return func() (*genai.GenerateContentResponse, error) {
+		if len(responses) == 0 {
+			return nil, err
+		}
+		resp := responses[0]
+		responses = responses[1:]
+		return resp, nil
+	}
+}
+
+// textResponse returns a streamed response with the given text parts
+func textResponse(parts ...string) *genai.GenerateContentResponse {
+	content := &genai.Content{Role: "model"}
+	for _, part := range parts {
+		content.Parts = append(content.Parts, genai.Text(part))
+	}
+	return &genai.GenerateContentResponse{Candidates: []*genai.Candidate{{Content: content}}}
+}
+
+func TestGoogleGeminiStreamContract(t *testing.T) {
+	failure := errors.New("connection reset")
+	tests := []struct {
+		name     string
+		next     func() (*genai.GenerateContentResponse, error)
+		text     string
+		thinking string
+		err      error
+	}{
+		{"Text", recordedStream(iterator.Done, textResponse("Hello"), &genai.GenerateContentResponse{}, textResponse(", world")), "Hello, world", "", nil},
+		{"Thinking", recordedStream(iterator.Done, textResponse(ThinkingDelimiter+" 97 is prime.", "Yes.")), "Yes.", "97 is prime.", nil},
+		{"Error", recordedStream(failure, textResponse("Hello")), "Hello", "", failure},
+		{"UnexpectedPart", recordedStream(iterator.Done, &genai.GenerateContentResponse{Candidates: []*genai.Candidate{{Content: &genai.Content{Parts: []genai.Part{genai.Blob{}}}}}}), "", "", providertest.AnyError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := make(chan models.StreamingCompletionResponse)
+			go func() {
+				defer close(stream)
+				sendStream(context.Background(), stream, tt.next, "gemini-1.5-flash", true)
+			}()
+			chunks := providertest.CheckStream(t, stream)
+			if len(chunks) == 0 {
+				return
+			}
+
+			var thinking string
+			for _, chunk := range chunks {
+				thinking += chunk.ThinkingText
+			}
+			if text := providertest.Text(chunks); text != tt.text || thinking != tt.thinking {
+				t.Errorf("Expected text %q and thinking %q, got %q and %q", tt.text, tt.thinking, text, thinking)
+			}
+			done := chunks[len(chunks)-1]
+			switch {
+			case tt.err == providertest.AnyError:
+				if done.Error == nil {
+					t.Error("Expected the stream to end with an error")
+				}
+			case !errors.Is(done.Error, tt.err):
+				t.Errorf("Expected the stream to end with %v, got %v", tt.err, done.Error)
+			}
+		})
+	}
+}
+
+func TestGoogleGeminiVision(t *testing.T) {
+	if os.Getenv("GEMINI_API_KEY") == "" {
+		t.Skip("GEMINI_API_KEY not set, skipping Google Gemini vision test")
+	}
+	image, err := os.ReadFile("testdata/red.png")
+	if err != nil {
+		t.Fatalf("Failed to read the image: %v", err)
+	}
+
+	ctx := context.Background()
+	provider, err := NewGoogleGeminiProvider(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create Google Gemini provider: %v", err)
+	}
+	defer provider.Close()
+
+	input := models.CompletionInput{
+		Messages: []models.ChatMessage{{
+			Role: models.RoleUser,
+			ContentParts: []models.ContentPart{
+				models.NewTextPart("What color is this image? Answer in one word."),
+				models.NewImageBytePart(image, "image/png"),
+			},
+		}},
+		MaxTokens: 10,
+	}
+	resp, err := provider.GenerateCompletion(ctx, "gemini-1.5-flash", input)
+	if err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+	if resp.Text == "" {
+		t.Error("Generated text is empty")
+	}
+}
+
+func TestFetchImages(t *testing.T) {
+	image, err := os.ReadFile("testdata/red.png")
+	if err != nil {
+		t.Fatalf("Failed to read the image: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/red.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(image)
+		case "/untyped":
+			w.Write(image)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	messages := []models.ChatMessage{
+		{Role: models.RoleSystem, Content: "Be brief."},
+		{Role: models.RoleUser, Content: "Which is brighter?", ContentParts: []models.ContentPart{
+			models.NewTextPart("First:"),
+			models.NewImageURLPart(server.URL + "/red.png"),
+			models.NewTextPart("Second:"),
+			models.NewImageURLPart(server.URL + "/untyped"),
+			models.NewImageBytePart([]byte("GIF89a"), "image/gif"),
+		}},
+	}
+	fetched, err := fetchImages(context.Background(), messages)
+	if err != nil {
+		t.Fatalf("fetchImages failed: %v", err)
+	}
+	if messages[1].ContentParts[1].ImageURL == "" {
+		t.Error("fetchImages modified the caller's messages")
+	}
+
+	parts := promptParts(fetched)
+	want := []genai.Part{
+		genai.Text("Be brief."),
+		genai.Text("First:"),
+		genai.Blob{MIMEType: "image/png", Data: image},
+		genai.Text("Second:"),
+		genai.Blob{MIMEType: "image/png", Data: image},
+		genai.Blob{MIMEType: "image/gif", Data: []byte("GIF89a")},
+		genai.Text("Which is brighter?"),
+	}
+	if !reflect.DeepEqual(parts, want) {
+		t.Errorf("Expected the parts in order with the downloaded images, got %v", parts)
+	}
+
+	tests := []struct {
+		name string
+		part models.ContentPart
+	}{
+		{"NotFound", models.NewImageURLPart(server.URL + "/missing.png")},
+		{"InvalidData", models.ContentPart{Type: models.ContentPartImage, MimeType: "image/png", Data: "not base64!"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages := []models.ChatMessage{{Role: models.RoleUser, ContentParts: []models.ContentPart{tt.part}}}
+			if _, err := fetchImages(context.Background(), messages); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	messages = []models.ChatMessage{{Role: models.RoleUser, ContentParts: []models.ContentPart{models.NewImageURLPart(server.URL + "/red.png")}}}
+	if _, err := fetchImages(ctx, messages); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the download to respect the context, got %v", err)
+	}
+}
+
+func TestGoogleGeminiStreamAbandoned(t *testing.T) {
+	// The SDK ends its request when the context is cancelled; sendStream must stop sending too
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := make(chan models.StreamingCompletionResponse)
+	returned := make(chan struct{})
+	go func() {
+		defer close(returned)
+		sendStream(ctx, stream, func() (*genai.GenerateContentResponse, error) {
+			return textResponse("Hello"), nil
+		}, "gemini-1.5-flash", false)
+	}()
+
+	<-stream
+	cancel()
+	select {
+	case <-returned:
+	case <-time.After(providertest.StreamTimeout):
+		t.Fatal("sendStream kept running after the context was cancelled")
+	}
+}
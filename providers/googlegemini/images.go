@@ -0,0 +1,106 @@
+package googlegemini
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/1broseidon/gollm/internal/utils"
+	"github.com/1broseidon/gollm/models"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// MaxImageSize is the largest image, in bytes, downloaded for an image URL part
+const MaxImageSize = 20 << 20
+
+// fetchImages returns messages with their image URL parts replaced by the downloaded images,
+// and checks that the other image and document parts hold valid base64 data. The SDK sends images inline, so
+// URLs can't be passed on.
+func fetchImages(ctx context.Context, messages []models.ChatMessage) ([]models.ChatMessage, error) {
+	var result []models.ChatMessage
+	for i, message := range messages {
+		if len(message.ContentParts) == 0 {
+			continue
+		}
+		if result == nil {
+			// Copied, so the caller's messages are left as they are
+			result = append([]models.ChatMessage(nil), messages...)
+		}
+		parts := make([]models.ContentPart, len(message.ContentParts))
+		for j, part := range message.ContentParts {
+			if part.Type == models.ContentPartImage && part.ImageURL != "" {
+				data, mimeType, err := downloadImage(ctx, part.ImageURL)
+				if err != nil {
+					return nil, fmt.Errorf("message %d part %d: %w", i, j, err)
+				}
+				if part.MimeType == "" {
+					part.MimeType = mimeType
+				}
+				part.Data, part.ImageURL = base64.StdEncoding.EncodeToString(data), ""
+			} else if (part.Type == models.ContentPartImage || part.Type == models.ContentPartDocument) && part.FileURI == "" {
+				if _, err := base64.StdEncoding.DecodeString(part.Data); err != nil {
+					return nil, fmt.Errorf("message %d part %d: invalid %s data: %w", i, j, part.Type, err)
+				}
+			}
+			parts[j] = part
+		}
+		result[i].ContentParts = parts
+	}
+	if result == nil {
+		return messages, nil
+	}
+	return result, nil
+}
+
+// downloadImage fetches the image at url and returns it with its media type, taken from the
+// Content-Type header or else detected from the data
+func downloadImage(ctx context.Context, url string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download image: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("failed to download image: status code %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(utils.LimitBody(resp.Body, MaxImageSize))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download image: %w", err)
+	}
+	mimeType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mimeType == "application/octet-stream" {
+		mimeType = http.DetectContentType(data)
+	}
+	return data, mimeType, nil
+}
+
+// messageParts returns the parts of a message: its content parts in order, then its text. The
+// image and document data must have been checked by fetchImages. Messages with file parts are
+// sent by restParts instead.
+func messageParts(message models.ChatMessage) []genai.Part {
+	if len(message.ContentParts) == 0 {
+		return []genai.Part{genai.Text(message.Content)}
+	}
+	parts := make([]genai.Part, 0, len(message.ContentParts)+1)
+	for _, part := range message.ContentParts {
+		switch {
+		case part.Type == models.ContentPartImage || part.Type == models.ContentPartDocument:
+			data, _ := base64.StdEncoding.DecodeString(part.Data)
+			parts = append(parts, genai.Blob{MIMEType: part.MimeType, Data: data})
+		default:
+			parts = append(parts, genai.Text(part.Text))
+		}
+	}
+	if message.Content != "" {
+		parts = append(parts, genai.Text(message.Content))
+	}
+	return parts
+}
@@ -0,0 +1,252 @@
+package googlegemini
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/1broseidon/gollm/internal/utils"
+	"github.com/1broseidon/gollm/models"
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/iterator"
+)
+
+// restPart is a part of a REST API message: text, inline data or an uploaded file
+type restPart struct {
+	Text       string    `json:"text,omitempty"`
+	InlineData *restBlob `json:"inlineData,omitempty"`
+	FileData   *restFile `json:"fileData,omitempty"`
+}
+
+// restBlob holds base64 encoded inline data
+type restBlob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+// restFile references a file uploaded with the File API
+type restFile struct {
+	MimeType string `json:"mimeType,omitempty"`
+	FileURI  string `json:"fileUri"`
+}
+
+// restContent is a REST API message
+type restContent struct {
+	Role  string     `json:"role,omitempty"`
+	Parts []restPart `json:"parts"`
+}
+
+// restGenerationConfig holds the sampling options of a REST API request
+type restGenerationConfig struct {
+	MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
+	Temperature     *float32 `json:"temperature,omitempty"`
+	TopP            *float32 `json:"topP,omitempty"`
+	StopSequences   []string `json:"stopSequences,omitempty"`
+}
+
+// restRequest is the body of a generateContent request
+type restRequest struct {
+	SystemInstruction *restContent         `json:"systemInstruction,omitempty"`
+	Contents          []restContent        `json:"contents"`
+	GenerationConfig  restGenerationConfig `json:"generationConfig"`
+}
+
+// restResponse is a generateContent response, or a chunk of a streamed one
+type restResponse struct {
+	Candidates []struct {
+		Content *restContent `json:"content"`
+	} `json:"candidates"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+		TotalTokenCount      int `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+	ModelVersion string `json:"modelVersion"`
+}
+
+// hasFileParts reports whether any message references an uploaded file
+func hasFileParts(messages []models.ChatMessage) bool {
+	for _, message := range messages {
+		for _, part := range message.ContentParts {
+			if part.FileURI != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// newRESTRequest returns the generateContent request for input, with messages prepared by
+// prepareMessages
+func newRESTRequest(input models.CompletionInput, messages []models.ChatMessage) restRequest {
+	system, rest := models.JoinSystemMessages(messages)
+	request := restRequest{
+		Contents: make([]restContent, len(rest)),
+		GenerationConfig: restGenerationConfig{
+			MaxOutputTokens: input.MaxTokens,
+			Temperature:     input.Temperature,
+			TopP:            input.TopP,
+			StopSequences:   input.Stop,
+		},
+	}
+	if system != "" {
+		request.SystemInstruction = &restContent{Parts: []restPart{{Text: system}}}
+	}
+	for i, message := range rest {
+		request.Contents[i] = restContent{Role: apiRole(message.Role), Parts: restParts(message)}
+	}
+	return request
+}
+
+// restParts returns the parts of a message in the REST API's form, ordered as by messageParts
+func restParts(message models.ChatMessage) []restPart {
+	if len(message.ContentParts) == 0 {
+		return []restPart{{Text: message.Content}}
+	}
+	parts := make([]restPart, 0, len(message.ContentParts)+1)
+	for _, part := range message.ContentParts {
+		switch {
+		case part.FileURI != "":
+			parts = append(parts, restPart{FileData: &restFile{MimeType: part.MimeType, FileURI: part.FileURI}})
+		case part.Type == models.ContentPartImage || part.Type == models.ContentPartDocument:
+			parts = append(parts, restPart{InlineData: &restBlob{MimeType: part.MimeType, Data: part.Data}})
+		default:
+			parts = append(parts, restPart{Text: part.Text})
+		}
+	}
+	if message.Content != "" {
+		parts = append(parts, restPart{Text: message.Content})
+	}
+	return parts
+}
+
+// genaiResponse converts the response to the SDK's form, for splitThinking and sendStream
+func (r *restResponse) genaiResponse() *genai.GenerateContentResponse {
+	resp := &genai.GenerateContentResponse{}
+	for _, candidate := range r.Candidates {
+		content := &genai.Content{}
+		if candidate.Content != nil {
+			content.Role = candidate.Content.Role
+			for _, part := range candidate.Content.Parts {
+				if part.InlineData != nil {
+					data, _ := base64.StdEncoding.DecodeString(part.InlineData.Data)
+					content.Parts = append(content.Parts, genai.Blob{MIMEType: part.InlineData.MimeType, Data: data})
+					continue
+				}
+				content.Parts = append(content.Parts, genai.Text(part.Text))
+			}
+		}
+		resp.Candidates = append(resp.Candidates, &genai.Candidate{Content: content})
+	}
+	return resp
+}
+
+// postContent sends a generateContent request, or a streamGenerateContent one if stream is set,
+// to the REST API
+func (p *GoogleGeminiProvider) postContent(ctx context.Context, modelName string, request restRequest, stream bool) (*http.Response, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := p.filesBaseURL + "/v1beta/models/" + url.PathEscape(modelName) + ":generateContent"
+	if stream {
+		endpoint = p.filesBaseURL + "/v1beta/models/" + url.PathEscape(modelName) + ":streamGenerateContent?alt=sse"
+	}
+	req, err := p.newAPIRequest(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return p.doAPI(req)
+}
+
+// generateContentREST generates a completion for messages through the REST API. The genai SDK
+// version in use can't reference uploaded files, so completions whose messages hold file parts
+// are sent there, like the File API requests.
+func (p *GoogleGeminiProvider) generateContentREST(ctx context.Context, modelName string, input models.CompletionInput, messages []models.ChatMessage) (*models.CompletionResponse, error) {
+	resp, err := p.postContent(ctx, modelName, newRESTRequest(input, messages), false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result restResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	content := result.genaiResponse()
+	if len(content.Candidates) == 0 || len(content.Candidates[0].Content.Parts) == 0 {
+		return nil, errors.New("no content generated")
+	}
+
+	thinking := input.ProviderOptions.GoogleGemini.ThinkingBudget != nil
+	text, thinkingText, err := splitThinking(content.Candidates[0].Content.Parts, thinking)
+	if err != nil {
+		return nil, err
+	}
+
+	servedModel := modelName
+	if result.ModelVersion != "" {
+		servedModel = result.ModelVersion
+	}
+	return &models.CompletionResponse{
+		Text:         text,
+		ThinkingText: thinkingText,
+		Model:        servedModel,
+		Usage: &models.Usage{
+			PromptTokens:     result.UsageMetadata.PromptTokenCount,
+			CompletionTokens: result.UsageMetadata.CandidatesTokenCount,
+			TotalTokens:      result.UsageMetadata.TotalTokenCount,
+		},
+	}, nil
+}
+
+// streamContentREST generates a streaming completion for messages through the REST API, whose
+// server-sent events are sent as chunks by sendStream
+func (p *GoogleGeminiProvider) streamContentREST(ctx, requestCtx context.Context, cancel context.CancelFunc, modelName string, input models.CompletionInput, messages []models.ChatMessage) (<-chan models.StreamingCompletionResponse, error) {
+	resp, err := p.postContent(requestCtx, modelName, newRESTRequest(input, messages), true)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	thinking := input.ProviderOptions.GoogleGemini.ThinkingBudget != nil
+
+	streamChan := utils.NewStream(input.StreamBufferSize)
+
+	go func() {
+		defer resp.Body.Close()
+		defer close(streamChan)
+		defer cancel()
+
+		reader := utils.NewLineReader(resp.Body)
+		defer reader.Release()
+		next := func() (*genai.GenerateContentResponse, error) {
+			for {
+				line, err := reader.ReadLine()
+				if err != nil && (err != io.EOF || len(line) == 0) {
+					if err == io.EOF {
+						return nil, iterator.Done
+					}
+					return nil, err
+				}
+				data, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data:"))
+				if !ok {
+					continue
+				}
+				var chunk restResponse
+				if err := json.Unmarshal(data, &chunk); err != nil {
+					return nil, err
+				}
+				return chunk.genaiResponse(), nil
+			}
+		}
+		sendStream(ctx, streamChan, next, modelName, thinking)
+	}()
+
+	return streamChan, nil
+}
@@ -1,7 +1,6 @@
 package ollama
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -10,50 +9,109 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/1broseidon/gollm/internal/credentials"
+	"github.com/1broseidon/gollm/internal/utils"
 	"github.com/1broseidon/gollm/models"
 )
 
+// defaultEmbeddingModel is used for embeddings when OLLAMA_EMBED_MODEL is not set
+const defaultEmbeddingModel = "nomic-embed-text"
+
+// embedV2MinVersion is the first Ollama release that serves the batch /api/embed endpoint
+var embedV2MinVersion = [3]int{0, 3, 0}
+
 // OllamaProvider implements the Ollama-specific functionality
 type OllamaProvider struct {
-	baseURL string
-	client  *http.Client
+	baseURL         string
+	embedModel      string
+	client          *http.Client
+	transportConfig OllamaTransportConfig
+	bodyLimit       int64
+
+	embedV2Mu    sync.Mutex
+	embedV2      bool
+	embedV2Known bool // Whether embedV2 was detected or set with OLLAMA_EMBED_V2
+
+	contextLengthsMu sync.Mutex
+	contextLengths   map[string]int
 }
 
-// NewOllamaProvider creates a new Ollama provider
-func NewOllamaProvider() (*OllamaProvider, error) {
-	baseURL := os.Getenv("OLLAMA_BASE_URL")
-	if baseURL == "" {
-		return nil, fmt.Errorf("OLLAMA_BASE_URL environment variable is not set")
+// OllamaOption configures an OllamaProvider
+type OllamaOption func(*OllamaProvider)
+
+// WithHTTPClient sets the HTTP client used for API requests
+func WithHTTPClient(client *http.Client) OllamaOption {
+	return func(p *OllamaProvider) {
+		p.client = client
 	}
+}
 
-	return &OllamaProvider{
-		baseURL: baseURL,
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-	}, nil
+// WithBaseURL sets the URL of the Ollama server, overriding OLLAMA_BASE_URL and the credentials file
+func WithBaseURL(baseURL string) OllamaOption {
+	return func(p *OllamaProvider) {
+		p.baseURL = baseURL
+	}
 }
 
-// GenerateCompletion generates a completion using the specified Ollama model
-func (p *OllamaProvider) GenerateCompletion(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
-	url := fmt.Sprintf("%s/api/generate", strings.TrimSuffix(p.baseURL, "/"))
+// WithResponseBodyLimit caps the size of non-streaming response bodies at limit bytes, failing
+// with models.ErrResponseTooLarge beyond it. The default is models.DefaultResponseBodyLimit;
+// zero or less disables the limit.
+func WithResponseBodyLimit(limit int64) OllamaOption {
+	return func(p *OllamaProvider) {
+		p.bodyLimit = limit
+	}
+}
 
-	requestBody := map[string]interface{}{
-		"model":  modelName,
-		"prompt": input.Messages[len(input.Messages)-1].Content,
-		"stream": false,
+// NewOllamaProvider creates a new Ollama provider. Unless WithBaseURL is given, the server URL is
+// read from OLLAMA_BASE_URL, or from ollama_base_url in ~/.config/gollm/credentials.
+func NewOllamaProvider(opts ...OllamaOption) (*OllamaProvider, error) {
+	embedModel := os.Getenv("OLLAMA_EMBED_MODEL")
+	if embedModel == "" {
+		embedModel = defaultEmbeddingModel
 	}
 
-	if input.MaxTokens > 0 {
-		requestBody["options"] = map[string]interface{}{
-			"num_predict": input.MaxTokens,
+	p := &OllamaProvider{
+		embedModel:      embedModel,
+		transportConfig: DefaultOllamaTransportConfig(),
+		bodyLimit:       models.DefaultResponseBodyLimit,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.client == nil {
+		p.client = &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: p.transportConfig.Transport(),
 		}
 	}
 
-	jsonBody, err := json.Marshal(requestBody)
+	if p.baseURL == "" {
+		p.baseURL = credentials.Lookup("OLLAMA_BASE_URL")
+	}
+	if p.baseURL == "" {
+		return nil, fmt.Errorf("OLLAMA_BASE_URL is not set in the environment or the credentials file")
+	}
+
+	return p, nil
+}
+
+// GenerateCompletion generates a completion using the specified Ollama model
+func (p *OllamaProvider) GenerateCompletion(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
+	ctx, cancel := models.ApplyRequestTimeout(ctx)
+	defer cancel()
+
+	url := fmt.Sprintf("%s/api/chat", strings.TrimSuffix(p.baseURL, "/"))
+
+	requestBody, err := newChatRequest(modelName, input, false)
+	if err != nil {
+		return nil, err
+	}
+	jsonBody, err := utils.MarshalWithExtra(requestBody, input.Extra)
 	if err != nil {
 		return nil, err
 	}
@@ -64,12 +122,14 @@ func (p *OllamaProvider) GenerateCompletion(ctx context.Context, modelName strin
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	models.ApplyRequestHeaders(req)
 
 	resp, err := p.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	resp.Body = utils.LimitBody(resp.Body, p.bodyLimit)
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
@@ -81,11 +141,15 @@ func (p *OllamaProvider) GenerateCompletion(ctx context.Context, modelName strin
 		return nil, err
 	}
 
-	response, ok := result["response"].(string)
+	response, ok := messageContent(result)
 	if !ok {
 		return nil, errors.New("invalid response format")
 	}
 
+	if err := validateFormat(input.ProviderOptions.Ollama, response); err != nil {
+		return nil, err
+	}
+
 	promptEvalCount, _ := result["prompt_eval_count"].(float64)
 	evalCount, _ := result["eval_count"].(float64)
 
@@ -96,118 +160,556 @@ func (p *OllamaProvider) GenerateCompletion(ctx context.Context, modelName strin
 			CompletionTokens: int(evalCount),
 			TotalTokens:      int(promptEvalCount + evalCount),
 		},
+		Timing: serverTiming(result),
+		Model:  responseModel(result, modelName),
 	}, nil
 }
 
-// GenerateCompletionStream generates a streaming completion using the specified Ollama model
-func (p *OllamaProvider) GenerateCompletionStream(ctx context.Context, modelName string, input models.CompletionInput) (<-chan models.StreamingCompletionResponse, error) {
-	url := fmt.Sprintf("%s/api/generate", strings.TrimSuffix(p.baseURL, "/"))
+// roles maps message roles to the roles of the API
+var roles = map[string]string{
+	models.RoleSystem:    "system",
+	models.RoleUser:      "user",
+	models.RoleAssistant: "assistant",
+	models.RoleTool:      "tool",
+}
+
+// apiRole returns the API role for a message role, or the role itself if it has no mapping
+func apiRole(role string) string {
+	if mapped, ok := roles[role]; ok {
+		return mapped
+	}
+	return role
+}
+
+// chatMessage is a message of an /api/chat request
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
 
+// newChatMessages converts messages to the API format, with their roles mapped by apiRole
+func newChatMessages(messages []models.ChatMessage) []chatMessage {
+	result := make([]chatMessage, len(messages))
+	for i, message := range messages {
+		result[i] = chatMessage{Role: apiRole(message.Role), Content: message.Content}
+	}
+	return result
+}
+
+// newChatRequest returns the body of an /api/chat request for input
+func newChatRequest(modelName string, input models.CompletionInput, stream bool) (map[string]interface{}, error) {
+	if len(input.Messages) == 0 {
+		return nil, errors.New("no messages provided for completion")
+	}
 	requestBody := map[string]interface{}{
-		"model":  modelName,
-		"prompt": input.Messages[len(input.Messages)-1].Content,
-		"stream": true,
+		"model":    modelName,
+		"messages": newChatMessages(input.Messages),
+		"stream":   stream,
+	}
+	if options := generateOptions(input); len(options) > 0 {
+		requestBody["options"] = options
 	}
+	if format := requestFormat(input.ProviderOptions.Ollama.Format); len(format) > 0 {
+		requestBody["format"] = format
+	}
+	return requestBody, nil
+}
 
-	if input.MaxTokens > 0 {
-		requestBody["options"] = map[string]interface{}{
-			"num_predict": input.MaxTokens,
-		}
+// messageContent returns the text of the message in a chat response, or of its delta in a
+// streamed one
+func messageContent(result map[string]interface{}) (string, bool) {
+	message, ok := result["message"].(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	content, ok := message["content"].(string)
+	return content, ok
+}
+
+// requestFormat returns the format field for format. A bare word that isn't valid JSON, such as
+// json, is sent as a JSON string.
+func requestFormat(format json.RawMessage) json.RawMessage {
+	if len(format) == 0 || json.Valid(format) {
+		return format
+	}
+	quoted, _ := json.Marshal(string(format))
+	return quoted
+}
+
+// validateFormat checks text against the format requested in options, if ValidateFormat is set
+func validateFormat(options models.OllamaOptions, text string) error {
+	format := requestFormat(options.Format)
+	if !options.ValidateFormat || len(format) == 0 {
+		return nil
+	}
+	if err := utils.ValidateJSON(format, []byte(text)); err != nil {
+		return fmt.Errorf("%w: %v", models.ErrFormatMismatch, err)
+	}
+	return nil
+}
+
+// responseModel returns the model named in a response, or the requested model if there is none
+func responseModel(result map[string]interface{}, requested string) string {
+	if model, ok := result["model"].(string); ok && model != "" {
+		return model
+	}
+	return requested
+}
+
+// serverTiming converts the nanosecond durations Ollama reports on a final response, or returns
+// nil if there are none
+func serverTiming(result map[string]interface{}) *models.Timing {
+	totalDuration, _ := result["total_duration"].(float64)
+	evalDuration, _ := result["eval_duration"].(float64)
+	if totalDuration == 0 && evalDuration == 0 {
+		return nil
+	}
+	return &models.Timing{
+		ServerDuration: time.Duration(totalDuration),
+		EvalDuration:   time.Duration(evalDuration),
 	}
+}
+
+// GenerateCompletionStream generates a streaming completion using the specified Ollama model
+func (p *OllamaProvider) GenerateCompletionStream(ctx context.Context, modelName string, input models.CompletionInput) (<-chan models.StreamingCompletionResponse, error) {
+	url := fmt.Sprintf("%s/api/chat", strings.TrimSuffix(p.baseURL, "/"))
 
-	jsonBody, err := json.Marshal(requestBody)
+	requestBody, err := newChatRequest(modelName, input, true)
+	if err != nil {
+		return nil, err
+	}
+	jsonBody, err := utils.MarshalWithExtra(requestBody, input.Extra)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonBody))
+	requestCtx, cancel := models.ApplyRequestTimeout(ctx)
+	req, err := http.NewRequestWithContext(requestCtx, "POST", url, bytes.NewBuffer(jsonBody))
 	if err != nil {
+		cancel()
 		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	models.ApplyRequestHeaders(req)
 
 	resp, err := p.client.Do(req)
 	if err != nil {
+		cancel()
 		return nil, err
 	}
 
 	if resp.StatusCode != http.StatusOK {
 		resp.Body.Close()
+		cancel()
 		return nil, fmt.Errorf("API request failed with status code: %d", resp.StatusCode)
 	}
 
-	streamChan := make(chan models.StreamingCompletionResponse)
+	streamChan := utils.NewStream(input.StreamBufferSize)
 
 	go func() {
 		defer resp.Body.Close()
 		defer close(streamChan)
+		defer cancel()
 
-		reader := bufio.NewReader(resp.Body)
-		var accumulatedUsage models.Usage
+		reader := utils.NewLineReader(resp.Body)
+		defer reader.Release()
+		// The text is only kept to validate it against the format once the stream is done
+		var text strings.Builder
+		validate := input.ProviderOptions.Ollama.ValidateFormat
 
 		for {
-			line, err := reader.ReadBytes('\n')
+			line, err := reader.ReadLine()
 			if err != nil {
-				if err == io.EOF {
-					return
-				}
-				streamChan <- models.StreamingCompletionResponse{Error: err}
+				// The stream ends with a done response, so even an EOF means it was cut off
+				utils.SendError(ctx, streamChan, utils.StreamError(err))
 				return
 			}
 
 			var result map[string]interface{}
 			if err := json.Unmarshal(line, &result); err != nil {
-				streamChan <- models.StreamingCompletionResponse{Error: err}
-				continue
+				utils.SendError(ctx, streamChan, err)
+				return
+			}
+			if message, ok := result["error"].(string); ok {
+				// Errors after the response started, such as the model running out of memory
+				utils.SendError(ctx, streamChan, errors.New(message))
+				return
 			}
 
-			response, ok := result["response"].(string)
-			if ok {
-				streamResponse := models.StreamingCompletionResponse{Text: response}
+			response, ok := messageContent(result)
+			if !ok {
+				continue
+			}
+			streamResponse := models.StreamingCompletionResponse{Text: response}
+			if validate {
+				text.WriteString(response)
+			}
 
+			if done, _ := result["done"].(bool); done {
 				promptEvalCount, _ := result["prompt_eval_count"].(float64)
 				evalCount, _ := result["eval_count"].(float64)
-
-				accumulatedUsage.PromptTokens = int(promptEvalCount)
-				accumulatedUsage.CompletionTokens = int(evalCount)
-				accumulatedUsage.TotalTokens = accumulatedUsage.PromptTokens + accumulatedUsage.CompletionTokens
-
-				streamResponse.Usage = &accumulatedUsage
-
-				done, ok := result["done"].(bool)
-				if ok {
-					streamResponse.Done = done
+				streamResponse.Done = true
+				streamResponse.Usage = &models.Usage{
+					PromptTokens:     int(promptEvalCount),
+					CompletionTokens: int(evalCount),
+					TotalTokens:      int(promptEvalCount + evalCount),
 				}
-
-				streamChan <- streamResponse
-
-				if streamResponse.Done {
-					return
+				streamResponse.Timing = serverTiming(result)
+				streamResponse.Model = responseModel(result, modelName)
+				if validate {
+					streamResponse.Error = validateFormat(input.ProviderOptions.Ollama, text.String())
 				}
 			}
+
+			if !utils.SendChunk(ctx, streamChan, streamResponse) || streamResponse.Done {
+				return
+			}
 		}
 	}()
 
 	return streamChan, nil
 }
 
+// generateOptions returns the model options of a chat request, leaving out the unset ones
+// so the model's defaults apply
+func generateOptions(input models.CompletionInput) map[string]interface{} {
+	options := make(map[string]interface{})
+	if input.MaxTokens > 0 {
+		options["num_predict"] = input.MaxTokens
+	}
+	if input.Temperature != nil {
+		options["temperature"] = *input.Temperature
+	}
+	if input.TopP != nil {
+		options["top_p"] = *input.TopP
+	}
+	if len(input.Stop) > 0 {
+		options["stop"] = input.Stop
+	}
+	return options
+}
+
 // Close closes the Ollama provider (no-op in this case)
 func (p *OllamaProvider) Close() error {
 	return nil
 }
 
-// GenerateEmbedding generates an embedding using the Ollama model (not implemented)
+// GenerateEmbedding generates an embedding for the input using the model named by OLLAMA_EMBED_MODEL
 func (p *OllamaProvider) GenerateEmbedding(ctx context.Context, input string) ([]float32, error) {
-	return nil, errors.New("embedding generation not implemented for Ollama provider")
+	embeddings, err := p.GenerateBatchEmbeddings(ctx, []string{input})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
 }
 
-// StartChat starts a new chat session (not implemented)
-func (p *OllamaProvider) StartChat(modelName string) interface{} {
-	return nil
+// GenerateBatchEmbeddings generates one embedding per input. Servers that support /api/embed
+// receive all inputs in a single request; older servers are called once per input via /api/embeddings.
+func (p *OllamaProvider) GenerateBatchEmbeddings(ctx context.Context, inputs []string) ([][]float32, error) {
+	ctx, cancel := models.ApplyRequestTimeout(ctx)
+	defer cancel()
+
+	if len(inputs) == 0 {
+		return nil, errors.New("no inputs provided for embedding")
+	}
+
+	if !p.useEmbedV2(ctx) {
+		embeddings := make([][]float32, 0, len(inputs))
+		for _, input := range inputs {
+			embedding, err := p.generateLegacyEmbedding(ctx, input)
+			if err != nil {
+				return nil, err
+			}
+			embeddings = append(embeddings, embedding)
+		}
+		return embeddings, nil
+	}
+
+	requestBody := map[string]interface{}{
+		"model": p.embedModel,
+		"input": inputs,
+	}
+
+	var result struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := p.postJSON(ctx, "/api/embed", requestBody, &result); err != nil {
+		return nil, err
+	}
+
+	if len(result.Embeddings) != len(inputs) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(inputs), len(result.Embeddings))
+	}
+
+	return result.Embeddings, nil
+}
+
+// generateLegacyEmbedding generates a single embedding using the pre-0.3 /api/embeddings endpoint
+func (p *OllamaProvider) generateLegacyEmbedding(ctx context.Context, input string) ([]float32, error) {
+	requestBody := map[string]interface{}{
+		"model":  p.embedModel,
+		"prompt": input,
+	}
+
+	var result struct {
+		Embedding []float32 `json:"embedding"`
+	}
+	if err := p.postJSON(ctx, "/api/embeddings", requestBody, &result); err != nil {
+		return nil, err
+	}
+
+	if len(result.Embedding) == 0 {
+		return nil, errors.New("no embedding in response")
+	}
+
+	return result.Embedding, nil
+}
+
+// useEmbedV2 reports whether the server supports /api/embed. OLLAMA_EMBED_V2 forces the choice;
+// otherwise the server version is queried and cached once it is known.
+func (p *OllamaProvider) useEmbedV2(ctx context.Context) bool {
+	p.embedV2Mu.Lock()
+	defer p.embedV2Mu.Unlock()
+
+	if p.embedV2Known {
+		return p.embedV2
+	}
+	if value := os.Getenv("OLLAMA_EMBED_V2"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		p.embedV2, p.embedV2Known = err != nil || enabled, true
+		return p.embedV2
+	}
+
+	version, err := p.serverVersion(ctx)
+	if err != nil {
+		// Assume a current server for this call, and query the version again on the next
+		return true
+	}
+	// A version that can't be parsed is assumed to be current
+	parsed, err := parseVersion(version)
+	p.embedV2, p.embedV2Known = err != nil || !versionLess(parsed, embedV2MinVersion), true
+	return p.embedV2
+}
+
+// ListModels returns the names of the models pulled to the server, such as "llama3:latest",
+// from /api/tags
+func (p *OllamaProvider) ListModels(ctx context.Context) ([]string, error) {
+	ctx, cancel := models.ApplyRequestTimeout(ctx)
+	defer cancel()
+
+	url := fmt.Sprintf("%s/api/tags", strings.TrimSuffix(p.baseURL, "/"))
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	models.ApplyRequestHeaders(req)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	resp.Body = utils.LimitBody(resp.Body, p.bodyLimit)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API request failed with status code: %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(result.Models))
+	for i, model := range result.Models {
+		names[i] = model.Name
+	}
+	return names, nil
+}
+
+// GetContextLength returns the context length the model was trained with, in tokens, from the
+// model_info of /api/show, such as llama.context_length. Lengths are cached by model name.
+func (p *OllamaProvider) GetContextLength(ctx context.Context, modelName string) (int, error) {
+	p.contextLengthsMu.Lock()
+	length, ok := p.contextLengths[modelName]
+	p.contextLengthsMu.Unlock()
+	if ok {
+		return length, nil
+	}
+
+	ctx, cancel := models.ApplyRequestTimeout(ctx)
+	defer cancel()
+
+	jsonBody, err := json.Marshal(map[string]string{"model": modelName})
+	if err != nil {
+		return 0, err
+	}
+	url := fmt.Sprintf("%s/api/show", strings.TrimSuffix(p.baseURL, "/"))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	models.ApplyRequestHeaders(req)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	resp.Body = utils.LimitBody(resp.Body, p.bodyLimit)
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return 0, fmt.Errorf("API request failed with status code: %d, body: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var result struct {
+		ModelInfo map[string]interface{} `json:"model_info"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, err
+	}
+	length, err = contextLength(result.ModelInfo)
+	if err != nil {
+		return 0, fmt.Errorf("model %s: %w", modelName, err)
+	}
+
+	p.contextLengthsMu.Lock()
+	defer p.contextLengthsMu.Unlock()
+	if p.contextLengths == nil {
+		p.contextLengths = make(map[string]int)
+	}
+	p.contextLengths[modelName] = length
+	return length, nil
+}
+
+// contextLength returns the <architecture>.context_length entry of a model's model_info, or the
+// only *.context_length entry if the architecture isn't given
+func contextLength(modelInfo map[string]interface{}) (int, error) {
+	key := ""
+	if architecture, ok := modelInfo["general.architecture"].(string); ok {
+		key = architecture + ".context_length"
+	} else {
+		for k := range modelInfo {
+			if strings.HasSuffix(k, ".context_length") {
+				if key != "" {
+					return 0, errors.New("model_info has several context lengths and no architecture")
+				}
+				key = k
+			}
+		}
+	}
+
+	length, ok := modelInfo[key].(float64)
+	if !ok || length <= 0 {
+		return 0, errors.New("model_info has no context length")
+	}
+	return int(length), nil
+}
+
+// serverVersion fetches the Ollama version from /api/version
+func (p *OllamaProvider) serverVersion(ctx context.Context) (string, error) {
+	url := fmt.Sprintf("%s/api/version", strings.TrimSuffix(p.baseURL, "/"))
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	models.ApplyRequestHeaders(req)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	resp.Body = utils.LimitBody(resp.Body, p.bodyLimit)
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("API request failed with status code: %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Version string `json:"version"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+
+	return result.Version, nil
+}
+
+// parseVersion parses a "major.minor.patch" version string, ignoring any pre-release suffix
+func parseVersion(s string) ([3]int, error) {
+	var version [3]int
+
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return version, fmt.Errorf("invalid version: %q", s)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return version, fmt.Errorf("invalid version: %q", s)
+		}
+		version[i] = n
+	}
+
+	return version, nil
+}
+
+// versionLess reports whether version a is older than version b
+func versionLess(a, b [3]int) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
+
+// postJSON sends a JSON request to the given API path and decodes the JSON response into out
+func (p *OllamaProvider) postJSON(ctx context.Context, path string, body interface{}, out interface{}) error {
+	url := fmt.Sprintf("%s%s", strings.TrimSuffix(p.baseURL, "/"), path)
+
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	models.ApplyRequestHeaders(req)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	resp.Body = utils.LimitBody(resp.Body, p.bodyLimit)
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("API request failed with status code: %d, body: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
 }
 
-// SendChatMessage sends a message to an existing chat session (not implemented)
-func (p *OllamaProvider) SendChatMessage(ctx context.Context, session interface{}, message string) (*models.CompletionResponse, error) {
-	return nil, errors.New("chat functionality not implemented for Ollama provider")
+// Capabilities reports what the Ollama provider supports beyond completions
+func (p *OllamaProvider) Capabilities() models.Capabilities {
+	return models.Capabilities{Streaming: true, Embeddings: true}
 }
@@ -2,10 +2,21 @@ package ollama
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/1broseidon/gollm/models"
+	"github.com/1broseidon/gollm/providers/providertest"
 )
 
 func TestOllamaProvider(t *testing.T) {
@@ -28,7 +39,7 @@ func TestOllamaProvider(t *testing.T) {
 				{Role: "user", Content: "Explain the concept of quantum computing in simple terms."},
 			},
 			MaxTokens:   100,
-			Temperature: 0.7,
+			Temperature: models.Float32(0.7),
 		}
 
 		response, err := provider.GenerateCompletion(ctx, "llama3.1:latest", input)
@@ -62,7 +73,7 @@ func TestOllamaProvider(t *testing.T) {
 				{Role: "user", Content: "Explain the concept of artificial intelligence in simple terms."},
 			},
 			MaxTokens:   100,
-			Temperature: 0.7,
+			Temperature: models.Float32(0.7),
 			Stream:      true,
 		}
 
@@ -104,3 +115,645 @@ func TestOllamaProvider(t *testing.T) {
 		}
 	})
 }
+
+// newEmbeddingServer starts a mock Ollama server reporting the given version and serving both embedding endpoints
+func newEmbeddingServer(t *testing.T, version string, hits map[string]int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits[r.URL.Path]++
+		switch r.URL.Path {
+		case "/api/version":
+			json.NewEncoder(w).Encode(map[string]string{"version": version})
+		case "/api/embed":
+			var req struct {
+				Model string   `json:"model"`
+				Input []string `json:"input"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			embeddings := make([][]float32, len(req.Input))
+			for i := range req.Input {
+				embeddings[i] = []float32{float32(i), 0.5}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"embeddings": embeddings})
+		case "/api/embeddings":
+			var req struct {
+				Model  string `json:"model"`
+				Prompt string `json:"prompt"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Prompt == "" {
+				http.Error(w, "missing prompt", http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"embedding": []float32{float32(len(req.Prompt)), 0.25}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOllamaEmbeddings(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("BatchEmbedV2", func(t *testing.T) {
+		hits := map[string]int{}
+		server := newEmbeddingServer(t, "0.3.12", hits)
+		t.Setenv("OLLAMA_BASE_URL", server.URL)
+		t.Setenv("OLLAMA_EMBED_V2", "")
+
+		provider, err := NewOllamaProvider()
+		if err != nil {
+			t.Fatalf("Failed to create Ollama provider: %v", err)
+		}
+
+		embeddings, err := provider.GenerateBatchEmbeddings(ctx, []string{"first", "second", "third"})
+		if err != nil {
+			t.Fatalf("GenerateBatchEmbeddings failed: %v", err)
+		}
+		if len(embeddings) != 3 {
+			t.Fatalf("Expected 3 embeddings, got %d", len(embeddings))
+		}
+		if embeddings[2][0] != 2 {
+			t.Errorf("Embeddings returned out of order: %v", embeddings)
+		}
+		if hits["/api/embed"] != 1 {
+			t.Errorf("Expected a single /api/embed request, got %d", hits["/api/embed"])
+		}
+
+		embedding, err := provider.GenerateEmbedding(ctx, "single")
+		if err != nil {
+			t.Fatalf("GenerateEmbedding failed: %v", err)
+		}
+		if len(embedding) != 2 {
+			t.Errorf("Expected embedding of length 2, got %d", len(embedding))
+		}
+		if hits["/api/version"] != 1 {
+			t.Errorf("Expected the server version to be queried once, got %d", hits["/api/version"])
+		}
+	})
+
+	t.Run("LegacyVersionDetected", func(t *testing.T) {
+		hits := map[string]int{}
+		server := newEmbeddingServer(t, "0.2.8", hits)
+		t.Setenv("OLLAMA_BASE_URL", server.URL)
+		t.Setenv("OLLAMA_EMBED_V2", "")
+
+		provider, err := NewOllamaProvider()
+		if err != nil {
+			t.Fatalf("Failed to create Ollama provider: %v", err)
+		}
+
+		embeddings, err := provider.GenerateBatchEmbeddings(ctx, []string{"a", "bb"})
+		if err != nil {
+			t.Fatalf("GenerateBatchEmbeddings failed: %v", err)
+		}
+		if len(embeddings) != 2 || embeddings[1][0] != 2 {
+			t.Errorf("Unexpected legacy embeddings: %v", embeddings)
+		}
+		if hits["/api/embeddings"] != 2 || hits["/api/embed"] != 0 {
+			t.Errorf("Expected two legacy requests, got hits %v", hits)
+		}
+	})
+
+	t.Run("EnvOverride", func(t *testing.T) {
+		hits := map[string]int{}
+		server := newEmbeddingServer(t, "0.3.12", hits)
+		t.Setenv("OLLAMA_BASE_URL", server.URL)
+		t.Setenv("OLLAMA_EMBED_V2", "false")
+
+		provider, err := NewOllamaProvider()
+		if err != nil {
+			t.Fatalf("Failed to create Ollama provider: %v", err)
+		}
+
+		if _, err := provider.GenerateEmbedding(ctx, "hello"); err != nil {
+			t.Fatalf("GenerateEmbedding failed: %v", err)
+		}
+		if hits["/api/version"] != 0 || hits["/api/embeddings"] != 1 {
+			t.Errorf("Expected OLLAMA_EMBED_V2=false to force the legacy endpoint, got hits %v", hits)
+		}
+	})
+
+	t.Run("FailedDetectionRetried", func(t *testing.T) {
+		hits := map[string]int{}
+		legacy := newEmbeddingServer(t, "0.2.8", hits)
+		proxy := httputil.NewSingleHostReverseProxy(mustParseURL(t, legacy.URL))
+		versionQueries := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/api/version" {
+				if versionQueries++; versionQueries == 1 {
+					http.Error(w, "bad gateway", http.StatusBadGateway)
+					return
+				}
+			}
+			proxy.ServeHTTP(w, r)
+		}))
+		defer server.Close()
+		t.Setenv("OLLAMA_EMBED_V2", "")
+
+		provider, err := NewOllamaProvider(WithBaseURL(server.URL))
+		if err != nil {
+			t.Fatalf("Failed to create Ollama provider: %v", err)
+		}
+
+		for i := 0; i < 3; i++ {
+			if _, err := provider.GenerateEmbedding(ctx, "hello"); err != nil {
+				t.Fatalf("GenerateEmbedding %d failed: %v", i, err)
+			}
+		}
+		// The failed query falls back to /api/embed once; the legacy server is then detected
+		if versionQueries != 2 || hits["/api/embed"] != 1 || hits["/api/embeddings"] != 2 {
+			t.Errorf("Expected the version to be queried again after a failure, got %d queries and hits %v", versionQueries, hits)
+		}
+	})
+}
+
+// mustParseURL parses rawURL, failing the test if it is invalid
+func mustParseURL(t *testing.T, rawURL string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("Invalid URL %q: %v", rawURL, err)
+	}
+	return u
+}
+
+func TestOllamaListModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/tags" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"models":[{"name":"llama3:latest","model":"llama3:latest"},{"name":"mistral:7b","model":"mistral:7b"}]}`))
+	}))
+	defer server.Close()
+
+	provider, err := NewOllamaProvider(WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create Ollama provider: %v", err)
+	}
+	names, err := provider.ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("ListModels failed: %v", err)
+	}
+	if len(names) != 2 || names[0] != "llama3:latest" || names[1] != "mistral:7b" {
+		t.Errorf("Unexpected models %v", names)
+	}
+}
+
+func TestOllamaGetContextLength(t *testing.T) {
+	ctx := context.Background()
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/show" {
+			http.NotFound(w, r)
+			return
+		}
+		var request struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		requested = append(requested, request.Model)
+		switch request.Model {
+		case "llama3.1":
+			w.Write([]byte(`{"modelfile":"FROM llama3.1","parameters":"stop \"<|eot_id|>\"","details":{"family":"llama"},"model_info":{"general.architecture":"llama","general.parameter_count":8030261248,"llama.context_length":131072,"llama.embedding_length":4096}}`))
+		case "phi3":
+			w.Write([]byte(`{"model_info":{"phi3.context_length":4096,"phi3.block_count":32}}`))
+		case "broken":
+			w.Write([]byte(`{"model_info":{"general.architecture":"llama"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"model not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	provider, err := NewOllamaProvider(WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create Ollama provider: %v", err)
+	}
+
+	tests := []struct {
+		model   string
+		want    int
+		wantErr bool
+	}{
+		{"llama3.1", 131072, false},
+		{"phi3", 4096, false},
+		{"broken", 0, true},
+		{"missing", 0, true},
+	}
+	for _, tt := range tests {
+		length, err := provider.GetContextLength(ctx, tt.model)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("GetContextLength(%q) returned error %v, want error %v", tt.model, err, tt.wantErr)
+		}
+		if length != tt.want {
+			t.Errorf("GetContextLength(%q) = %d, want %d", tt.model, length, tt.want)
+		}
+	}
+
+	// Lengths are cached by model; failures are not
+	provider.GetContextLength(ctx, "llama3.1")
+	provider.GetContextLength(ctx, "missing")
+	if count := strings.Count(strings.Join(requested, " "), "llama3.1"); count != 1 {
+		t.Errorf("Expected llama3.1 to be requested once, got %d", count)
+	}
+	if count := strings.Count(strings.Join(requested, " "), "missing"); count != 2 {
+		t.Errorf("Expected failed lookups to be retried, got %d requests", count)
+	}
+}
+
+func TestOllamaJSONSchema(t *testing.T) {
+	schema := json.RawMessage(`{"type":"object","properties":{"name":{"type":"string"},"age":{"type":"integer"}},"required":["name","age"]}`)
+
+	var requests []map[string]json.RawMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Invalid request body: %v", err)
+		}
+		requests = append(requests, body)
+		if string(body["stream"]) == "true" {
+			w.Write([]byte(`{"message":{"role":"assistant","content":"{\"name\":\"Ada\",\"age\":36}"},"done":true}` + "\n"))
+			return
+		}
+		w.Write([]byte(`{"message":{"role":"assistant","content":"{\"name\":\"Ada\",\"age\":36}"},"done":true,"prompt_eval_count":12,"eval_count":9}`))
+	}))
+	defer server.Close()
+	t.Setenv("OLLAMA_BASE_URL", server.URL)
+
+	provider, err := NewOllamaProvider()
+	if err != nil {
+		t.Fatalf("Failed to create Ollama provider: %v", err)
+	}
+
+	ctx := context.Background()
+	input := models.CompletionInput{
+		Messages:        []models.ChatMessage{{Role: "user", Content: "Describe Ada Lovelace"}},
+		ProviderOptions: models.ProviderOptions{Ollama: models.OllamaOptions{Format: schema}},
+	}
+	if _, err := provider.GenerateCompletion(ctx, "llama3.1", input); err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+	stream, err := provider.GenerateCompletionStream(ctx, "llama3.1", input)
+	if err != nil {
+		t.Fatalf("GenerateCompletionStream failed: %v", err)
+	}
+	for range stream {
+	}
+
+	input.ProviderOptions.Ollama.Format = nil
+	if _, err := provider.GenerateCompletion(ctx, "llama3.1", input); err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+
+	if len(requests) != 3 {
+		t.Fatalf("Expected 3 requests, got %d", len(requests))
+	}
+	for _, body := range requests[:2] {
+		var format, want interface{}
+		json.Unmarshal(body["format"], &format)
+		json.Unmarshal(schema, &want)
+		if !reflect.DeepEqual(format, want) {
+			t.Errorf("Expected the schema as the format field, got %s", body["format"])
+		}
+	}
+	if _, ok := requests[2]["format"]; ok {
+		t.Errorf("Expected no format field without a schema, got %s", requests[2]["format"])
+	}
+}
+
+func TestOllamaFormat(t *testing.T) {
+	var formats []string
+	reply := `{\"name\":\"Ada\",\"age\":36}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]json.RawMessage
+		json.NewDecoder(r.Body).Decode(&body)
+		formats = append(formats, string(body["format"]))
+		if string(body["stream"]) == "true" {
+			w.Write([]byte(`{"message":{"role":"assistant","content":"` + reply[:10] + `"},"done":false}` + "\n"))
+			w.Write([]byte(`{"message":{"role":"assistant","content":"` + reply[10:] + `"},"done":true}` + "\n"))
+			return
+		}
+		w.Write([]byte(`{"message":{"role":"assistant","content":"` + reply + `"},"done":true}`))
+	}))
+	defer server.Close()
+	t.Setenv("OLLAMA_BASE_URL", server.URL)
+
+	provider, err := NewOllamaProvider()
+	if err != nil {
+		t.Fatalf("Failed to create Ollama provider: %v", err)
+	}
+	ctx := context.Background()
+	messages := []models.ChatMessage{{Role: "user", Content: "Describe Ada Lovelace"}}
+
+	matching := json.RawMessage(`{"type":"object","properties":{"name":{"type":"string"},"age":{"type":"integer"}},"required":["name","age"]}`)
+	mismatched := json.RawMessage(`{"type":"object","properties":{"age":{"type":"string"}},"required":["name","born"]}`)
+
+	tests := []struct {
+		name     string
+		options  models.OllamaOptions
+		sent     string
+		mismatch bool
+	}{
+		{"JSON", models.OllamaOptions{Format: models.OllamaFormatJSON, ValidateFormat: true}, `"json"`, false},
+		{"Schema", models.OllamaOptions{Format: matching, ValidateFormat: true}, string(matching), false},
+		{"BareJSON", models.OllamaOptions{Format: json.RawMessage("json"), ValidateFormat: true}, `"json"`, false},
+		{"Mismatch", models.OllamaOptions{Format: mismatched, ValidateFormat: true}, string(mismatched), true},
+		{"MismatchUnvalidated", models.OllamaOptions{Format: mismatched}, string(mismatched), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formats = nil
+			input := models.CompletionInput{Messages: messages, ProviderOptions: models.ProviderOptions{Ollama: tt.options}}
+
+			resp, err := provider.GenerateCompletion(ctx, "llama3.1", input)
+			if tt.mismatch {
+				if !errors.Is(err, models.ErrFormatMismatch) {
+					t.Errorf("Expected ErrFormatMismatch, got %v", err)
+				}
+			} else if err != nil || resp.Text != `{"name":"Ada","age":36}` {
+				t.Errorf("Expected the structured output, got %+v, %v", resp, err)
+			}
+
+			stream, err := provider.GenerateCompletionStream(ctx, "llama3.1", input)
+			if err != nil {
+				t.Fatalf("GenerateCompletionStream failed: %v", err)
+			}
+			var doneErr error
+			for chunk := range stream {
+				if chunk.Done {
+					doneErr = chunk.Error
+				}
+			}
+			if tt.mismatch != errors.Is(doneErr, models.ErrFormatMismatch) {
+				t.Errorf("Expected a mismatch on the Done chunk: %v, got %v", tt.mismatch, doneErr)
+			}
+
+			if len(formats) != 2 || formats[0] != tt.sent || formats[1] != tt.sent {
+				t.Errorf("Expected the format %s to be sent, got %v", tt.sent, formats)
+			}
+		})
+	}
+}
+
+func TestOllamaServerTiming(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["stream"] == true {
+			w.Write([]byte(`{"message":{"role":"assistant","content":"Hi"},"done":false}` + "\n"))
+			w.Write([]byte(`{"message":{"role":"assistant","content":""},"done":true,"total_duration":5000000000,"eval_duration":3000000000}` + "\n"))
+			return
+		}
+		w.Write([]byte(`{"message":{"role":"assistant","content":"Hi"},"done":true,"prompt_eval_count":2,"eval_count":1,"total_duration":5000000000,"eval_duration":3000000000}`))
+	}))
+	defer server.Close()
+
+	provider, err := NewOllamaProvider(WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create Ollama provider: %v", err)
+	}
+
+	ctx := context.Background()
+	input := models.CompletionInput{Messages: []models.ChatMessage{{Role: "user", Content: "Hi"}}}
+	response, err := provider.GenerateCompletion(ctx, "llama3.1", input)
+	if err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+	if response.Timing == nil || response.Timing.ServerDuration != 5*time.Second || response.Timing.EvalDuration != 3*time.Second {
+		t.Errorf("Expected the server durations, got %+v", response.Timing)
+	}
+
+	stream, err := provider.GenerateCompletionStream(ctx, "llama3.1", input)
+	if err != nil {
+		t.Fatalf("GenerateCompletionStream failed: %v", err)
+	}
+	var chunks []models.StreamingCompletionResponse
+	for chunk := range stream {
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) != 2 || chunks[0].Timing != nil {
+		t.Fatalf("Expected server durations on the Done chunk only, got %+v", chunks)
+	}
+	if timing := chunks[1].Timing; timing == nil || timing.ServerDuration != 5*time.Second || timing.EvalDuration != 3*time.Second {
+		t.Errorf("Expected the server durations on the Done chunk, got %+v", timing)
+	}
+}
+
+func TestOllamaResponseModel(t *testing.T) {
+	tests := []struct {
+		name  string
+		model string // The model in the response, if any
+		want  string
+	}{
+		{"Echoed", `"model":"llama3.1:latest",`, "llama3.1:latest"},
+		{"Fallback", ``, "llama3.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body map[string]interface{}
+				json.NewDecoder(r.Body).Decode(&body)
+				if body["stream"] == true {
+					w.Write([]byte(`{` + tt.model + `"message":{"role":"assistant","content":"Hi"},"done":false}` + "\n"))
+					w.Write([]byte(`{` + tt.model + `"message":{"role":"assistant","content":""},"done":true}` + "\n"))
+					return
+				}
+				w.Write([]byte(`{` + tt.model + `"message":{"role":"assistant","content":"Hi"},"done":true}`))
+			}))
+			defer server.Close()
+
+			provider, err := NewOllamaProvider(WithBaseURL(server.URL))
+			if err != nil {
+				t.Fatalf("Failed to create Ollama provider: %v", err)
+			}
+			ctx := context.Background()
+			input := models.CompletionInput{Messages: []models.ChatMessage{{Role: "user", Content: "Hi"}}}
+
+			response, err := provider.GenerateCompletion(ctx, "llama3.1", input)
+			if err != nil {
+				t.Fatalf("GenerateCompletion failed: %v", err)
+			}
+			if response.Model != tt.want {
+				t.Errorf("Expected model %q, got %q", tt.want, response.Model)
+			}
+
+			stream, err := provider.GenerateCompletionStream(ctx, "llama3.1", input)
+			if err != nil {
+				t.Fatalf("GenerateCompletionStream failed: %v", err)
+			}
+			var last models.StreamingCompletionResponse
+			for chunk := range stream {
+				last = chunk
+			}
+			if !last.Done || last.Model != tt.want {
+				t.Errorf("Expected a Done chunk with model %q, got %+v", tt.want, last)
+			}
+		})
+	}
+}
+
+func TestOllamaSamplingOptions(t *testing.T) {
+	var requests []map[string]json.RawMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Invalid request body: %v", err)
+		}
+		requests = append(requests, body)
+		w.Write([]byte(`{"message":{"role":"assistant","content":"Hi"},"done":true,"prompt_eval_count":1,"eval_count":1}` + "\n"))
+	}))
+	defer server.Close()
+	t.Setenv("OLLAMA_BASE_URL", server.URL)
+
+	provider, err := NewOllamaProvider()
+	if err != nil {
+		t.Fatalf("Failed to create Ollama provider: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		input models.CompletionInput
+		want  string // The options sent, if any
+	}{
+		{"Unset", models.CompletionInput{}, ``},
+		{"Set", models.CompletionInput{MaxTokens: 20, Temperature: models.Float32(0.5), TopP: models.Float32(0.9)}, `{"num_predict":20,"temperature":0.5,"top_p":0.9}`},
+		{"ZeroTemperature", models.CompletionInput{Temperature: models.Float32(0)}, `{"temperature":0}`},
+		{"Stop", models.CompletionInput{Stop: []string{"END"}}, `{"stop":["END"]}`},
+		// Options the provider sets take precedence over Extra
+		{"Extra", models.CompletionInput{TopP: models.Float32(0.9), Extra: map[string]interface{}{"options": map[string]int{"num_ctx": 8192}}}, `{"top_p":0.9}`},
+		{"ExtraOnly", models.CompletionInput{Extra: map[string]interface{}{"options": map[string]int{"num_ctx": 8192}, "keep_alive": "5m"}}, `{"num_ctx":8192}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = nil
+			tt.input.Messages = []models.ChatMessage{{Role: "user", Content: "Hi"}}
+			if _, err := provider.GenerateCompletion(context.Background(), "llama3.1", tt.input); err != nil {
+				t.Fatalf("GenerateCompletion failed: %v", err)
+			}
+			stream, err := provider.GenerateCompletionStream(context.Background(), "llama3.1", tt.input)
+			if err != nil {
+				t.Fatalf("GenerateCompletionStream failed: %v", err)
+			}
+			for range stream {
+			}
+
+			for i, body := range requests {
+				if string(body["options"]) != tt.want {
+					t.Errorf("Request %d: expected the options %s, got %s", i, tt.want, body["options"])
+				}
+			}
+		})
+	}
+}
+
+func TestNewChatMessagesRoles(t *testing.T) {
+	messages := newChatMessages([]models.ChatMessage{
+		{Role: models.RoleSystem, Content: "Be brief."},
+		{Role: models.RoleUser, Content: "Weather in Paris?"},
+		{Role: models.RoleAssistant, Content: "Let me check."},
+		{Role: models.RoleTool, Content: "sunny"},
+		{Role: models.RoleAssistant, Content: "Sunny."},
+	})
+
+	want := []chatMessage{
+		{"system", "Be brief."},
+		{"user", "Weather in Paris?"},
+		{"assistant", "Let me check."},
+		{"tool", "sunny"},
+		{"assistant", "Sunny."},
+	}
+	if !reflect.DeepEqual(messages, want) {
+		t.Errorf("Expected the messages %+v, got %+v", want, messages)
+	}
+}
+
+func TestOllamaChatRequest(t *testing.T) {
+	var paths []string
+	var sent [][]chatMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []chatMessage `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Invalid request body: %v", err)
+		}
+		paths = append(paths, r.URL.Path)
+		sent = append(sent, body.Messages)
+		w.Write([]byte(`{"message":{"role":"assistant","content":"Paris."},"done":true}` + "\n"))
+	}))
+	defer server.Close()
+
+	provider, err := NewOllamaProvider(WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create Ollama provider: %v", err)
+	}
+	ctx := context.Background()
+	input := models.CompletionInput{Messages: []models.ChatMessage{
+		{Role: models.RoleSystem, Content: "Be brief."},
+		{Role: models.RoleUser, Content: "Capital of France?"},
+		{Role: models.RoleAssistant, Content: "Paris."},
+		{Role: models.RoleUser, Content: "And of Italy?"},
+	}}
+	if _, err := provider.GenerateCompletion(ctx, "llama3.1", input); err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+	stream, err := provider.GenerateCompletionStream(ctx, "llama3.1", input)
+	if err != nil {
+		t.Fatalf("GenerateCompletionStream failed: %v", err)
+	}
+	for range stream {
+	}
+
+	want := newChatMessages(input.Messages)
+	for i := range sent {
+		if paths[i] != "/api/chat" || !reflect.DeepEqual(sent[i], want) {
+			t.Errorf("Request %d: expected the whole conversation at /api/chat, got %+v at %s", i, sent[i], paths[i])
+		}
+	}
+
+	if _, err := provider.GenerateCompletion(ctx, "llama3.1", models.CompletionInput{}); err == nil {
+		t.Error("Expected an error for a completion without messages")
+	}
+	if _, err := provider.GenerateCompletionStream(ctx, "llama3.1", models.CompletionInput{}); err == nil {
+		t.Error("Expected an error for a stream without messages")
+	}
+	if len(sent) != 2 {
+		t.Errorf("Expected no request without messages, got %d requests", len(sent))
+	}
+}
+
+func TestOllamaStreamContract(t *testing.T) {
+	fixtures := []providertest.Fixture{
+		{File: "stream_text.ndjson", Text: "Hello, world", Usage: &models.Usage{PromptTokens: 12, CompletionTokens: 3, TotalTokens: 15}},
+		{File: "stream_error.ndjson", Text: "Hello", Err: providertest.AnyError},
+		{File: "stream_truncated.ndjson", Text: "Once upon", Err: io.ErrUnexpectedEOF},
+		{File: "stream_malformed.ndjson", Text: "Hello", Err: providertest.AnyError},
+	}
+	providertest.RunStreamFixtures(t, fixtures, func(t *testing.T, baseURL string) (<-chan models.StreamingCompletionResponse, error) {
+		provider, err := NewOllamaProvider(WithBaseURL(baseURL))
+		if err != nil {
+			return nil, err
+		}
+		input := models.CompletionInput{Messages: []models.ChatMessage{{Role: "user", Content: "Say hello"}}}
+		return provider.GenerateCompletionStream(context.Background(), "llama3.1", input)
+	})
+}
+
+func TestOllamaStreamAbandoned(t *testing.T) {
+	event := []byte(`{"model":"llama3.1","message":{"role":"assistant","content":"Hello"},"done":false}` + "\n")
+	providertest.CheckAbandoned(t, event, func(ctx context.Context, baseURL string) (<-chan models.StreamingCompletionResponse, error) {
+		provider, err := NewOllamaProvider(WithBaseURL(baseURL))
+		if err != nil {
+			return nil, err
+		}
+		input := models.CompletionInput{Messages: []models.ChatMessage{{Role: "user", Content: "Say hello"}}}
+		return provider.GenerateCompletionStream(ctx, "llama3.1", input)
+	})
+}
@@ -0,0 +1,21 @@
+package ollama
+
+import "github.com/1broseidon/gollm/internal/utils"
+
+// OllamaTransportConfig sets the connection pool of the HTTP client the provider creates. It is
+// the same type as client.TransportConfig.
+type OllamaTransportConfig = utils.TransportConfig
+
+// DefaultOllamaTransportConfig returns the transport settings used unless
+// WithOllamaTransportConfig is given, the same as client.DefaultTransportConfig
+func DefaultOllamaTransportConfig() OllamaTransportConfig {
+	return utils.DefaultTransportConfig()
+}
+
+// WithOllamaTransportConfig sets the connection pool of the provider's HTTP client. It has no
+// effect with WithHTTPClient, whose client is used as it is.
+func WithOllamaTransportConfig(cfg OllamaTransportConfig) OllamaOption {
+	return func(p *OllamaProvider) {
+		p.transportConfig = cfg
+	}
+}
@@ -0,0 +1,103 @@
+package ollama
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// newCountingServer returns an Ollama server answering every generate request, and the number
+// of connections opened to it
+func newCountingServer(tb testing.TB) (*httptest.Server, *int32) {
+	var conns int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"message":{"role":"assistant","content":"Hi"},"done":true}`))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.Start()
+	tb.Cleanup(server.Close)
+	return server, &conns
+}
+
+// generateSequentially sends n completions one after another
+func generateSequentially(tb testing.TB, provider *OllamaProvider, n int) {
+	input := models.CompletionInput{Messages: []models.ChatMessage{{Role: models.RoleUser, Content: "Hi"}}}
+	for i := 0; i < n; i++ {
+		if _, err := provider.GenerateCompletion(context.Background(), "llama3.1", input); err != nil {
+			tb.Fatalf("GenerateCompletion failed: %v", err)
+		}
+	}
+}
+
+func TestOllamaConnectionReuse(t *testing.T) {
+	server, conns := newCountingServer(t)
+	provider, err := NewOllamaProvider(WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create Ollama provider: %v", err)
+	}
+
+	generateSequentially(t, provider, 100)
+	if n := atomic.LoadInt32(conns); n != 1 {
+		t.Errorf("Expected 100 sequential requests to share 1 connection, got %d", n)
+	}
+}
+
+func TestOllamaTransportConfig(t *testing.T) {
+	cfg := OllamaTransportConfig{MaxIdleConns: 10, MaxIdleConnsPerHost: 5, IdleConnTimeout: time.Minute, DisableCompression: true}
+	provider, err := NewOllamaProvider(WithBaseURL("http://localhost:11434"), WithOllamaTransportConfig(cfg))
+	if err != nil {
+		t.Fatalf("Failed to create Ollama provider: %v", err)
+	}
+	transport, ok := provider.client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("Expected an *http.Transport, got %T", provider.client.Transport)
+	}
+	if transport.MaxIdleConns != 10 || transport.MaxIdleConnsPerHost != 5 || transport.IdleConnTimeout != time.Minute || !transport.DisableCompression {
+		t.Errorf("Expected the transport config to be applied, got %+v", transport)
+	}
+
+	// A client given with WithHTTPClient is used as it is
+	client := &http.Client{}
+	provider, err = NewOllamaProvider(WithBaseURL("http://localhost:11434"), WithOllamaTransportConfig(cfg), WithHTTPClient(client))
+	if err != nil {
+		t.Fatalf("Failed to create Ollama provider: %v", err)
+	}
+	if provider.client != client || client.Transport != nil {
+		t.Error("Expected WithHTTPClient to take precedence over the transport config")
+	}
+}
+
+// BenchmarkOllamaConnectionReuse compares 100 sequential requests over pooled connections with
+// the same requests opening a connection each
+func BenchmarkOllamaConnectionReuse(b *testing.B) {
+	server, _ := newCountingServer(b)
+	noKeepAlive := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	for _, bm := range []struct {
+		name    string
+		options []OllamaOption
+	}{
+		{"Pooled", nil},
+		{"NewConnections", []OllamaOption{WithHTTPClient(noKeepAlive)}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			provider, err := NewOllamaProvider(append([]OllamaOption{WithBaseURL(server.URL)}, bm.options...)...)
+			if err != nil {
+				b.Fatalf("Failed to create Ollama provider: %v", err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				generateSequentially(b, provider, 100)
+			}
+		})
+	}
+}
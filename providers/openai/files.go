@@ -0,0 +1,197 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+
+	"github.com/1broseidon/gollm/internal/utils"
+	"github.com/1broseidon/gollm/models"
+)
+
+// ErrFileNotFound is returned by the Files API methods for a file ID the API doesn't know
+var ErrFileNotFound = errors.New("file not found")
+
+// The purposes of uploaded files, which the API checks the files against
+const (
+	FilePurposeBatch      = "batch"
+	FilePurposeFineTune   = "fine-tune"
+	FilePurposeAssistants = "assistants"
+	FilePurposeVision     = "vision"
+)
+
+// FileInfo describes a file stored with the Files API
+type FileInfo struct {
+	ID        string `json:"id"`
+	Filename  string `json:"filename"`
+	Purpose   string `json:"purpose"`
+	Bytes     int64  `json:"bytes"`
+	CreatedAt int64  `json:"created_at"` // Unix seconds
+}
+
+// filesPageSize is the number of files ListFiles asks for per page, the API's maximum
+const filesPageSize = 10000
+
+// UploadFile uploads the contents of r as the file name, for use with purpose, such as
+// FilePurposeBatch for a JSONL file of batch requests. The multipart body is streamed as r is
+// read, so large files aren't held in memory; the request must still finish within the HTTP
+// client's timeout.
+func (p *OpenAIProvider) UploadFile(ctx context.Context, name, purpose string, r io.Reader) (FileInfo, error) {
+	ctx, cancel := models.ApplyRequestTimeout(ctx)
+	defer cancel()
+
+	body, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	go func() {
+		writer.CloseWithError(writeUpload(form, name, purpose, r))
+	}()
+
+	req, err := p.newRequest(ctx, "POST", p.baseURL+"/v1/files", body)
+	if err != nil {
+		body.CloseWithError(err)
+		return FileInfo{}, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	var info FileInfo
+	err = p.doFiles(req, "", &info)
+	return info, err
+}
+
+// writeUpload writes the multipart form of an upload: the purpose, then the file
+func writeUpload(form *multipart.Writer, name, purpose string, r io.Reader) error {
+	if err := form.WriteField("purpose", purpose); err != nil {
+		return err
+	}
+	part, err := form.CreateFormFile("file", name)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, r); err != nil {
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return form.Close()
+}
+
+// ListFiles returns the uploaded files, newest first, with the given purpose or, if it is
+// empty, all of them
+func (p *OpenAIProvider) ListFiles(ctx context.Context, purpose string) ([]FileInfo, error) {
+	ctx, cancel := models.ApplyRequestTimeout(ctx)
+	defer cancel()
+
+	var files []FileInfo
+	after := ""
+	for {
+		query := url.Values{"limit": {fmt.Sprint(filesPageSize)}}
+		if purpose != "" {
+			query.Set("purpose", purpose)
+		}
+		if after != "" {
+			query.Set("after", after)
+		}
+		req, err := p.newRequest(ctx, "GET", p.baseURL+"/v1/files?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var page struct {
+			Data    []FileInfo `json:"data"`
+			HasMore bool       `json:"has_more"`
+		}
+		if err := p.doFiles(req, "", &page); err != nil {
+			return nil, err
+		}
+		files = append(files, page.Data...)
+		if !page.HasMore || len(page.Data) == 0 {
+			return files, nil
+		}
+		after = page.Data[len(page.Data)-1].ID
+	}
+}
+
+// GetFileContent returns the contents of the file with the given ID, streamed from the API.
+// The caller must close it. Unlike other responses it isn't capped by WithResponseBodyLimit,
+// as files can be large.
+func (p *OpenAIProvider) GetFileContent(ctx context.Context, id string) (io.ReadCloser, error) {
+	ctx, cancel := models.ApplyRequestTimeout(ctx)
+
+	req, err := p.newRequest(ctx, "GET", p.baseURL+"/v1/files/"+url.PathEscape(id)+"/content", nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer cancel()
+		defer resp.Body.Close()
+		resp.Body = utils.LimitBody(resp.Body, p.bodyLimit)
+		return nil, fileError(resp, id)
+	}
+	return &cancelBody{ReadCloser: resp.Body, cancel: cancel}, nil
+}
+
+// cancelBody is a response body that releases the request's context once closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// DeleteFile deletes the file with the given ID
+func (p *OpenAIProvider) DeleteFile(ctx context.Context, id string) error {
+	ctx, cancel := models.ApplyRequestTimeout(ctx)
+	defer cancel()
+
+	req, err := p.newRequest(ctx, "DELETE", p.baseURL+"/v1/files/"+url.PathEscape(id), nil)
+	if err != nil {
+		return err
+	}
+	var result struct {
+		Deleted bool `json:"deleted"`
+	}
+	if err := p.doFiles(req, id, &result); err != nil {
+		return err
+	}
+	if !result.Deleted {
+		return fmt.Errorf("file %s was not deleted", id)
+	}
+	return nil
+}
+
+// doFiles sends a Files API request and decodes the response into out. id is the file the
+// request is about, if any, reported by ErrFileNotFound.
+func (p *OpenAIProvider) doFiles(req *http.Request, id string, out interface{}) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	resp.Body = utils.LimitBody(resp.Body, p.bodyLimit)
+
+	if resp.StatusCode != http.StatusOK {
+		return fileError(resp, id)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// fileError returns the error of a failed Files API response about the file id, if any
+func fileError(resp *http.Response, id string) error {
+	bodyBytes, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusNotFound && id != "" {
+		return fmt.Errorf("%w: %s", ErrFileNotFound, id)
+	}
+	return fmt.Errorf("OpenAI API request failed: %w", fmt.Errorf("status code: %d, body: %s", resp.StatusCode, string(bodyBytes)))
+}
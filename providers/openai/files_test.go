@@ -0,0 +1,191 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestOpenAIUploadFile(t *testing.T) {
+	type part struct{ form, filename, content string }
+	var parts []part
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/files" || r.Header.Get("Authorization") != "Bearer test-key" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+		}
+		reader, err := r.MultipartReader()
+		if err != nil {
+			t.Fatalf("Expected a multipart body: %v", err)
+		}
+		for {
+			p, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Invalid multipart body: %v", err)
+			}
+			content, _ := io.ReadAll(p)
+			parts = append(parts, part{p.FormName(), p.FileName(), string(content)})
+		}
+		fmt.Fprint(w, `{"id":"file-abc","object":"file","bytes":38,"created_at":1730000000,"filename":"requests.jsonl","purpose":"batch"}`)
+	})
+
+	requests := `{"custom_id":"1"}` + "\n" + `{"custom_id":"2"}` + "\n"
+	info, err := provider.UploadFile(context.Background(), "requests.jsonl", FilePurposeBatch, strings.NewReader(requests))
+	if err != nil {
+		t.Fatalf("UploadFile failed: %v", err)
+	}
+	want := []part{{"purpose", "", "batch"}, {"file", "requests.jsonl", requests}}
+	if !reflect.DeepEqual(parts, want) {
+		t.Errorf("Expected the parts %+v, got %+v", want, parts)
+	}
+	if info != (FileInfo{ID: "file-abc", Filename: "requests.jsonl", Purpose: "batch", Bytes: 38, CreatedAt: 1730000000}) {
+		t.Errorf("Unexpected file info: %+v", info)
+	}
+}
+
+// countingReader generates size bytes without holding them, counting the bytes read
+type countingReader struct {
+	size int64
+	read atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	remaining := r.size - r.read.Load()
+	if remaining <= 0 {
+		return 0, io.EOF
+	}
+	n := int(min(int64(len(p)), remaining))
+	for i := range p[:n] {
+		p[i] = 'x'
+	}
+	r.read.Add(int64(n))
+	return n, nil
+}
+
+func TestOpenAIUploadFileStreams(t *testing.T) {
+	const size = 50 << 20
+	source := &countingReader{size: size}
+	var received, readBeforeFirstByte int64
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		reader, err := r.MultipartReader()
+		if err != nil {
+			t.Fatalf("Expected a multipart body: %v", err)
+		}
+		for {
+			p, err := reader.NextPart()
+			if err != nil {
+				break
+			}
+			if p.FormName() != "file" {
+				continue
+			}
+			first := make([]byte, 1)
+			if _, err := io.ReadFull(p, first); err != nil {
+				t.Fatalf("Failed to read the file: %v", err)
+			}
+			readBeforeFirstByte = source.read.Load()
+			n, _ := io.Copy(io.Discard, p)
+			received = n + 1
+		}
+		fmt.Fprintf(w, `{"id":"file-big","bytes":%d}`, received)
+	})
+
+	info, err := provider.UploadFile(context.Background(), "big.jsonl", FilePurposeBatch, source)
+	if err != nil {
+		t.Fatalf("UploadFile failed: %v", err)
+	}
+	if received != size || info.Bytes != size {
+		t.Errorf("Expected %d bytes to be uploaded, got %d", size, received)
+	}
+	// Had the body been buffered, the whole file would have been read before it was sent
+	if readBeforeFirstByte >= size {
+		t.Errorf("Expected the upload to stream, but the whole file was read before the server got its first byte")
+	}
+}
+
+func TestOpenAIUploadFileReadError(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		fmt.Fprint(w, `{"id":"file-abc"}`)
+	})
+	failure := errors.New("disk error")
+	source := io.MultiReader(strings.NewReader("partial"), &failingReader{err: failure})
+	if _, err := provider.UploadFile(context.Background(), "requests.jsonl", FilePurposeBatch, source); !errors.Is(err, failure) {
+		t.Errorf("Expected the read error, got %v", err)
+	}
+}
+
+// failingReader fails every read with err
+type failingReader struct{ err error }
+
+func (r *failingReader) Read(p []byte) (int, error) { return 0, r.err }
+
+func TestOpenAIListFiles(t *testing.T) {
+	var queries []string
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		if r.URL.Query().Get("after") == "" {
+			fmt.Fprint(w, `{"data":[{"id":"file-2","purpose":"batch"},{"id":"file-1","purpose":"batch"}],"has_more":true}`)
+			return
+		}
+		fmt.Fprint(w, `{"data":[{"id":"file-0","purpose":"batch"}],"has_more":false}`)
+	})
+
+	files, err := provider.ListFiles(context.Background(), FilePurposeBatch)
+	if err != nil {
+		t.Fatalf("ListFiles failed: %v", err)
+	}
+	if len(files) != 3 || files[0].ID != "file-2" || files[2].ID != "file-0" {
+		t.Errorf("Expected the files of both pages, got %+v", files)
+	}
+	want := []string{"limit=10000&purpose=batch", "after=file-1&limit=10000&purpose=batch"}
+	if !reflect.DeepEqual(queries, want) {
+		t.Errorf("Expected the queries %q, got %q", want, queries)
+	}
+}
+
+func TestOpenAIFileContentAndDelete(t *testing.T) {
+	var deleted []string
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/v1/files/file-abc/content":
+			fmt.Fprint(w, `{"custom_id":"1","response":{"status_code":200}}`+"\n")
+		case r.Method == "DELETE" && r.URL.Path == "/v1/files/file-abc":
+			deleted = append(deleted, "file-abc")
+			fmt.Fprint(w, `{"id":"file-abc","object":"file","deleted":true}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":{"message":"No such File object","type":"invalid_request_error"}}`)
+		}
+	})
+	ctx := context.Background()
+
+	content, err := provider.GetFileContent(ctx, "file-abc")
+	if err != nil {
+		t.Fatalf("GetFileContent failed: %v", err)
+	}
+	data, err := io.ReadAll(content)
+	content.Close()
+	if err != nil || string(data) != `{"custom_id":"1","response":{"status_code":200}}`+"\n" {
+		t.Errorf("Unexpected file content %q, %v", data, err)
+	}
+
+	if err := provider.DeleteFile(ctx, "file-abc"); err != nil || len(deleted) != 1 {
+		t.Errorf("Expected the file to be deleted, got %v", err)
+	}
+
+	if _, err := provider.GetFileContent(ctx, "file-missing"); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("Expected ErrFileNotFound, got %v", err)
+	}
+	if err := provider.DeleteFile(ctx, "file-missing"); !errors.Is(err, ErrFileNotFound) || !strings.Contains(err.Error(), "file-missing") {
+		t.Errorf("Expected ErrFileNotFound naming the file, got %v", err)
+	}
+}
@@ -1,7 +1,6 @@
 package openai
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -9,9 +8,12 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
+	"net/url"
+	"strings"
 	"time"
 
+	"github.com/1broseidon/gollm/internal/credentials"
+	"github.com/1broseidon/gollm/internal/utils"
 	"github.com/1broseidon/gollm/models"
 )
 
@@ -19,76 +21,215 @@ type StreamOptions struct {
 	IncludeUsage bool `json:"include_usage,omitempty"`
 }
 
+// defaultBaseURL is the OpenAI API endpoint used unless WithBaseURL is given
+const defaultBaseURL = "https://api.openai.com"
+
 // OpenAIProvider implements the OpenAI-specific functionality
 type OpenAIProvider struct {
-	apiKey string
-	client *http.Client
+	apiKey          string
+	baseURL         string
+	headers         http.Header
+	query           url.Values
+	client          *http.Client
+	transportConfig OpenAITransportConfig
+	streamDecoding  StreamDecoding
+	bodyLimit       int64
 }
 
-// NewOpenAIProvider creates a new OpenAI provider
-func NewOpenAIProvider() (*OpenAIProvider, error) {
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		return nil, fmt.Errorf("OPENAI_API_KEY environment variable is not set")
+// OpenAIOption configures an OpenAIProvider
+type OpenAIOption func(*OpenAIProvider)
+
+// WithHTTPClient sets the HTTP client used for API requests
+func WithHTTPClient(client *http.Client) OpenAIOption {
+	return func(p *OpenAIProvider) {
+		p.client = client
 	}
+}
 
-	return &OpenAIProvider{
-		apiKey: apiKey,
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-	}, nil
+// WithAPIKey sets the API key, overriding OPENAI_API_KEY and the credentials file
+func WithAPIKey(apiKey string) OpenAIOption {
+	return func(p *OpenAIProvider) {
+		p.apiKey = apiKey
+	}
+}
+
+// WithBaseURL sets the API endpoint, e.g. for a proxy or a test server
+func WithBaseURL(baseURL string) OpenAIOption {
+	return func(p *OpenAIProvider) {
+		p.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithHeader sets a header sent with every request, such as the HTTP-Referer and X-Title headers
+// OpenRouter reads, or a gateway's routing header. It takes precedence over the provider's own
+// headers, including Authorization, and headers set on the request context take precedence over it.
+func WithHeader(name, value string) OpenAIOption {
+	return func(p *OpenAIProvider) {
+		if p.headers == nil {
+			p.headers = make(http.Header)
+		}
+		p.headers.Set(name, value)
+	}
+}
+
+// WithQueryParam adds a query parameter to the URL of every request, for gateways that route or
+// authenticate requests by query
+func WithQueryParam(name, value string) OpenAIOption {
+	return func(p *OpenAIProvider) {
+		if p.query == nil {
+			p.query = make(url.Values)
+		}
+		p.query.Add(name, value)
+	}
+}
+
+// WithAPIVersion sets the api-version query parameter required by Azure OpenAI and the gateways
+// that follow its API
+func WithAPIVersion(version string) OpenAIOption {
+	return func(p *OpenAIProvider) {
+		if p.query == nil {
+			p.query = make(url.Values)
+		}
+		p.query.Set("api-version", version)
+	}
+}
+
+// WithResponseBodyLimit caps the size of non-streaming response bodies at limit bytes, failing
+// with models.ErrResponseTooLarge beyond it. The default is models.DefaultResponseBodyLimit;
+// zero or less disables the limit.
+func WithResponseBodyLimit(limit int64) OpenAIOption {
+	return func(p *OpenAIProvider) {
+		p.bodyLimit = limit
+	}
+}
+
+// NewOpenAIProvider creates a new OpenAI provider. Unless WithAPIKey is given, the API key is read
+// from OPENAI_API_KEY, or from openai_api_key in ~/.config/gollm/credentials.
+func NewOpenAIProvider(opts ...OpenAIOption) (*OpenAIProvider, error) {
+	p := &OpenAIProvider{
+		baseURL:         defaultBaseURL,
+		transportConfig: DefaultOpenAITransportConfig(),
+		bodyLimit:       models.DefaultResponseBodyLimit,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.client == nil {
+		p.client = &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: p.transportConfig.Transport(),
+		}
+	}
+
+	if p.apiKey == "" {
+		p.apiKey = credentials.Lookup("OPENAI_API_KEY")
+	}
+	if p.apiKey == "" {
+		return nil, fmt.Errorf("OPENAI_API_KEY is not set in the environment or the credentials file")
+	}
+
+	return p, nil
+}
+
+// newRequest creates an API request to endpoint with the query parameters and headers set with
+// the options, the authentication headers and the headers from ctx
+func (p *OpenAIProvider) newRequest(ctx context.Context, method, endpoint string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.query) > 0 {
+		query := req.URL.Query()
+		for name, values := range p.query {
+			query[name] = append(query[name], values...)
+		}
+		req.URL.RawQuery = query.Encode()
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	for name, values := range p.headers {
+		req.Header[name] = append([]string(nil), values...)
+	}
+	models.ApplyRequestHeaders(req)
+	return req, nil
 }
 
 // GenerateCompletion generates a completion using the specified OpenAI model
 func (p *OpenAIProvider) GenerateCompletion(ctx context.Context, modelName string, input models.CompletionInput) (*models.CompletionResponse, error) {
-	url := "https://api.openai.com/v1/chat/completions"
+	ctx, cancel := models.ApplyRequestTimeout(ctx)
+	defer cancel()
+
+	url := p.baseURL + "/v1/chat/completions"
+
+	toolChoice, err := newToolChoice(input.ToolChoice, input.Tools)
+	if err != nil {
+		return nil, err
+	}
 
 	requestBody := struct {
-		Model       string               `json:"model"`
-		Messages    []models.ChatMessage `json:"messages"`
-		MaxTokens   int                  `json:"max_tokens"`
-		Temperature float32              `json:"temperature"`
+		Model       string           `json:"model"`
+		Messages    []chatMessage    `json:"messages"`
+		MaxTokens   int              `json:"max_tokens,omitempty"`
+		MaxComplete int              `json:"max_completion_tokens,omitempty"`
+		Temperature *float32         `json:"temperature,omitempty"`
+		TopP        *float32         `json:"top_p,omitempty"`
+		Stop        []string         `json:"stop,omitempty"`
+		Tools       []toolDefinition `json:"tools,omitempty"`
+		ToolChoice  interface{}      `json:"tool_choice,omitempty"`
+		Parallel    *bool            `json:"parallel_tool_calls,omitempty"`
+		Logprobs    bool             `json:"logprobs,omitempty"`
+		TopLogprobs int              `json:"top_logprobs,omitempty"`
+		Reasoning   string           `json:"reasoning_effort,omitempty"`
 	}{
 		Model:       modelName,
-		Messages:    input.Messages,
-		MaxTokens:   input.MaxTokens,
-		Temperature: input.Temperature,
+		Messages:    newChatMessages(input.Messages),
+		Tools:       newToolDefinitions(input.Tools),
+		ToolChoice:  toolChoice,
+		Parallel:    parallelToolCalls(input),
+		Temperature: temperature(input),
+		TopP:        topP(input),
+		Stop:        input.Stop,
+		Logprobs:    input.ProviderOptions.OpenAI.Logprobs || input.ProviderOptions.OpenAI.TopLogprobs > 0,
+		TopLogprobs: input.ProviderOptions.OpenAI.TopLogprobs,
+		Reasoning:   input.ProviderOptions.OpenAI.ReasoningEffort,
 	}
+	requestBody.MaxTokens, requestBody.MaxComplete = maxTokens(input)
 
-	jsonBody, err := json.Marshal(requestBody)
+	jsonBody, err := utils.MarshalWithExtra(requestBody, input.Extra)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonBody))
+	req, err := p.newRequest(ctx, "POST", url, bytes.NewBuffer(jsonBody))
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+p.apiKey)
-
 	resp, err := p.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	resp.Body = utils.LimitBody(resp.Body, p.bodyLimit)
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
+		if isContentFilterError(bodyBytes) {
+			return nil, fmt.Errorf("%w: %s", models.ErrContentFiltered, string(bodyBytes))
+		}
 		return nil, fmt.Errorf("OpenAI API request failed: %w", fmt.Errorf("status code: %d, body: %s", resp.StatusCode, string(bodyBytes)))
 	}
 
-	// Log the response body for debugging
-	bodyBytes, _ := io.ReadAll(resp.Body)
-	fmt.Printf("OpenAI API Response: %s\n", string(bodyBytes))
-
-	// Create a new reader with the body bytes
-	resp.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+	// The body is kept to decode the tool calls and logprobs from it too
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
 
 	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	if err := json.Unmarshal(bodyBytes, &result); err != nil {
 		return nil, err
 	}
 
@@ -102,13 +243,19 @@ func (p *OpenAIProvider) GenerateCompletion(ctx context.Context, modelName strin
 		return nil, errors.New("invalid choice format")
 	}
 
+	if finishReason, _ := choice["finish_reason"].(string); finishReason == finishReasonContentFilter {
+		return nil, fmt.Errorf("%w: completion stopped by the content filter", models.ErrContentFiltered)
+	}
+
 	message, ok := choice["message"].(map[string]interface{})
 	if !ok {
 		return nil, errors.New("invalid message format")
 	}
 
+	// The content is null when the model only calls tools
+	_, hasToolCalls := message["tool_calls"]
 	content, ok := message["content"].(string)
-	if !ok {
+	if !ok && !(hasToolCalls && message["content"] == nil) {
 		return nil, errors.New("invalid content format")
 	}
 
@@ -133,70 +280,195 @@ func (p *OpenAIProvider) GenerateCompletion(ctx context.Context, modelName strin
 	}
 
 	response := &models.CompletionResponse{
-		Text: content,
+		Text:  content,
+		Model: modelName,
 		Usage: &models.Usage{
 			PromptTokens:     int(promptTokens),
 			CompletionTokens: int(completionTokens),
 			TotalTokens:      int(totalTokens),
+			Details:          usageDetails(usage),
 		},
 	}
 
+	if model, ok := result["model"].(string); ok && model != "" {
+		response.Model = model
+	}
+
+	if hasToolCalls {
+		if response.ToolCalls, err = parseToolCalls(bodyBytes); err != nil {
+			return nil, fmt.Errorf("invalid tool_calls format: %w", err)
+		}
+	}
+
+	if requestBody.Logprobs {
+		var logprobsResult struct {
+			Choices []struct {
+				Logprobs *struct {
+					Content []models.TokenLogprob `json:"content"`
+				} `json:"logprobs"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal(bodyBytes, &logprobsResult); err != nil {
+			return nil, fmt.Errorf("invalid logprobs format: %w", err)
+		}
+		if logprobs := logprobsResult.Choices[0].Logprobs; logprobs != nil {
+			response.Logprobs = logprobs.Content
+		}
+	}
+
 	return response, nil
 }
 
+// temperature returns the temperature to send, which is left out with a reasoning effort as
+// o-series models reject it
+func temperature(input models.CompletionInput) *float32 {
+	if input.ProviderOptions.OpenAI.ReasoningEffort != "" {
+		return nil
+	}
+	return input.Temperature
+}
+
+// topP returns the top_p to send, which is left out with a reasoning effort like the temperature
+func topP(input models.CompletionInput) *float32 {
+	if input.ProviderOptions.OpenAI.ReasoningEffort != "" {
+		return nil
+	}
+	return input.TopP
+}
+
+// maxTokens returns the output token limit to send as max_tokens or, with a reasoning effort,
+// as max_completion_tokens, since o-series models reject max_tokens
+func maxTokens(input models.CompletionInput) (maxTokens, maxCompletionTokens int) {
+	if input.ProviderOptions.OpenAI.ReasoningEffort != "" {
+		return 0, input.MaxTokens
+	}
+	return input.MaxTokens, 0
+}
+
+// usageDetails returns the counts of the details objects of usage, or nil if it reports none
+func usageDetails(usage map[string]interface{}) *models.UsageDetails {
+	details := models.UsageDetails{
+		ReasoningTokens: detailCount(usage, "completion_tokens_details", "reasoning_tokens"),
+		CacheReadTokens: detailCount(usage, "prompt_tokens_details", "cached_tokens"),
+		AudioTokens:     detailCount(usage, "prompt_tokens_details", "audio_tokens") + detailCount(usage, "completion_tokens_details", "audio_tokens"),
+	}
+	if details.IsZero() {
+		return nil
+	}
+	return &details
+}
+
+// detailCount returns the count named key in the details object of usage, such as
+// completion_tokens_details, or 0 if it isn't reported
+func detailCount(usage map[string]interface{}, details, key string) int {
+	object, _ := usage[details].(map[string]interface{})
+	count, _ := object[key].(float64)
+	return int(count)
+}
+
 // GenerateCompletionStream generates a streaming completion using the specified OpenAI model
 func (p *OpenAIProvider) GenerateCompletionStream(ctx context.Context, modelName string, input models.CompletionInput) (<-chan models.StreamingCompletionResponse, error) {
-	url := "https://api.openai.com/v1/chat/completions"
+	url := p.baseURL + "/v1/chat/completions"
+
+	toolChoice, err := newToolChoice(input.ToolChoice, input.Tools)
+	if err != nil {
+		return nil, err
+	}
 
 	requestBody := map[string]interface{}{
-		"model":       modelName,
-		"messages":    input.Messages,
-		"max_tokens":  input.MaxTokens,
-		"temperature": input.Temperature,
-		"stream":      true,
+		"model":    modelName,
+		"messages": newChatMessages(input.Messages),
+		"stream":   true,
 		"stream_options": map[string]bool{
 			"include_usage": true,
 		},
 	}
+	// Unset sampling options are left out, so OpenAI applies the model's defaults
+	if limit, completionLimit := maxTokens(input); limit > 0 {
+		requestBody["max_tokens"] = limit
+	} else if completionLimit > 0 {
+		requestBody["max_completion_tokens"] = completionLimit
+	}
+	if temperature := temperature(input); temperature != nil {
+		requestBody["temperature"] = *temperature
+	}
+	if effort := input.ProviderOptions.OpenAI.ReasoningEffort; effort != "" {
+		requestBody["reasoning_effort"] = effort
+	}
+	if topP := topP(input); topP != nil {
+		requestBody["top_p"] = *topP
+	}
+	if len(input.Stop) > 0 {
+		requestBody["stop"] = input.Stop
+	}
+	if tools := newToolDefinitions(input.Tools); tools != nil {
+		requestBody["tools"] = tools
+		requestBody["tool_choice"] = toolChoice
+	}
+	if parallel := parallelToolCalls(input); parallel != nil {
+		requestBody["parallel_tool_calls"] = *parallel
+	}
 
-	jsonBody, err := json.Marshal(requestBody)
+	jsonBody, err := utils.MarshalWithExtra(requestBody, input.Extra)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonBody))
+	requestCtx, cancel := models.ApplyRequestTimeout(ctx)
+	req, err := p.newRequest(requestCtx, "POST", url, bytes.NewBuffer(jsonBody))
 	if err != nil {
+		cancel()
 		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+p.apiKey)
-
 	resp, err := p.client.Do(req)
 	if err != nil {
+		cancel()
 		return nil, err
 	}
 
 	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(utils.LimitBody(resp.Body, p.bodyLimit))
 		resp.Body.Close()
+		cancel()
+		if isContentFilterError(bodyBytes) {
+			return nil, fmt.Errorf("%w: %s", models.ErrContentFiltered, string(bodyBytes))
+		}
 		return nil, fmt.Errorf("API request failed with status code: %d", resp.StatusCode)
 	}
 
-	streamChan := make(chan models.StreamingCompletionResponse)
+	streamChan := utils.NewStream(input.StreamBufferSize)
 
 	go func() {
 		defer resp.Body.Close()
 		defer close(streamChan)
+		defer cancel()
 
-		reader := bufio.NewReader(resp.Body)
+		reader := utils.NewLineReader(resp.Body)
+		defer reader.Release()
 		var accumulatedUsage models.Usage
+		var toolCalls utils.ToolCallAccumulator
+		servedModel := modelName
+		// finished is set by the chunk with a finish_reason. The usage follows in a chunk of its
+		// own, so the Done chunk is sent on that chunk or on [DONE], whichever comes first.
+		finished := false
+		// done completes the final chunk with the usage, the model and the assembled tool calls
+		done := func(response models.StreamingCompletionResponse) models.StreamingCompletionResponse {
+			response.Done = true
+			response.Model = servedModel
+			response.Usage = &accumulatedUsage
+			response.ToolCalls, response.Error = toolCalls.ToolCalls()
+			return response
+		}
 		for {
-			line, err := reader.ReadBytes('\n')
+			line, err := reader.ReadLine()
 			if err != nil {
-				if err == io.EOF {
+				if err == io.EOF && finished {
+					// Some OpenAI-compatible servers end the stream without [DONE]
+					utils.SendChunk(ctx, streamChan, done(models.StreamingCompletionResponse{}))
 					return
 				}
-				streamChan <- models.StreamingCompletionResponse{Error: err}
+				utils.SendError(ctx, streamChan, utils.StreamError(err))
 				return
 			}
 
@@ -210,128 +482,263 @@ func (p *OpenAIProvider) GenerateCompletionStream(ctx context.Context, modelName
 
 			data := bytes.TrimPrefix(line, []byte("data: "))
 			if bytes.Equal(bytes.TrimSpace(data), []byte("[DONE]")) {
-				streamChan <- models.StreamingCompletionResponse{Done: true, Usage: &accumulatedUsage}
+				utils.SendChunk(ctx, streamChan, done(models.StreamingCompletionResponse{}))
 				return
 			}
 
-			var result map[string]interface{}
-			if err := json.Unmarshal(data, &result); err != nil {
-				// Skip this error for "[DONE]" message
-				if !bytes.Equal(bytes.TrimSpace(data), []byte("[DONE]")) {
-					fmt.Printf("Error unmarshaling JSON: %v\nData: %s\n", err, string(data))
-					streamChan <- models.StreamingCompletionResponse{Error: fmt.Errorf("error unmarshaling JSON: %v", err)}
-				}
-				continue
+			chunk, err := decodeStreamChunk(data, p.streamDecoding)
+			if err != nil {
+				utils.SendError(ctx, streamChan, fmt.Errorf("failed to decode stream chunk: %w", err))
+				return
 			}
-
-			choices, ok := result["choices"].([]interface{})
-			if !ok {
-				// This might be the final usage chunk
-				usage, ok := result["usage"].(map[string]interface{})
-				if ok {
-					promptTokens, _ := usage["prompt_tokens"].(float64)
-					completionTokens, _ := usage["completion_tokens"].(float64)
-					totalTokens, _ := usage["total_tokens"].(float64)
-
-					accumulatedUsage = models.Usage{
-						PromptTokens:     int(promptTokens),
-						CompletionTokens: int(completionTokens),
-						TotalTokens:      int(totalTokens),
-					}
-					streamChan <- models.StreamingCompletionResponse{Done: true, Usage: &accumulatedUsage}
-					return
-				}
-				continue
+			if chunk.Model != "" {
+				servedModel = chunk.Model
 			}
 
-			if len(choices) == 0 {
+			if len(chunk.Choices) == 0 {
 				// This might be the final usage chunk
-				usage, ok := result["usage"].(map[string]interface{})
-				if ok {
-					promptTokens, _ := usage["prompt_tokens"].(float64)
-					completionTokens, _ := usage["completion_tokens"].(float64)
-					totalTokens, _ := usage["total_tokens"].(float64)
-
-					accumulatedUsage = models.Usage{
-						PromptTokens:     int(promptTokens),
-						CompletionTokens: int(completionTokens),
-						TotalTokens:      int(totalTokens),
-					}
-					streamChan <- models.StreamingCompletionResponse{Done: true, Usage: &accumulatedUsage}
+				if chunk.Usage != nil {
+					accumulatedUsage = chunk.Usage.usage()
+					utils.SendChunk(ctx, streamChan, done(models.StreamingCompletionResponse{}))
 					return
 				}
 				continue
 			}
 
-			choice, ok := choices[0].(map[string]interface{})
-			if !ok {
-				err := fmt.Errorf("invalid choice format")
-				fmt.Println(err)
-				streamChan <- models.StreamingCompletionResponse{Error: err}
-				continue
-			}
-
-			delta, ok := choice["delta"].(map[string]interface{})
-			if !ok {
-				err := fmt.Errorf("invalid delta format")
-				fmt.Println(err)
-				streamChan <- models.StreamingCompletionResponse{Error: err}
-				continue
+			choice := chunk.Choices[0]
+			if choice.Delta == nil {
+				utils.SendError(ctx, streamChan, fmt.Errorf("invalid delta format"))
+				return
 			}
 
-			content, ok := delta["content"].(string)
-			if ok {
-				response := models.StreamingCompletionResponse{Text: content}
-
-				// Check if this is the last chunk
-				finishReason, ok := choice["finish_reason"].(string)
-				if ok && finishReason != "" {
-					response.Done = true
-					response.Usage = &accumulatedUsage
-				}
-
-				// Update usage metadata if available
-				if usageMetadata, ok := result["usageMetadata"].(map[string]interface{}); ok {
-					promptTokenCount, _ := usageMetadata["promptTokenCount"].(float64)
-					candidatesTokenCount, _ := usageMetadata["candidatesTokenCount"].(float64)
-					totalTokenCount, _ := usageMetadata["totalTokenCount"].(float64)
-
-					accumulatedUsage = models.Usage{
-						PromptTokens:     int(promptTokenCount),
-						CompletionTokens: int(candidatesTokenCount),
-						TotalTokens:      int(totalTokenCount),
-					}
-					response.Usage = &accumulatedUsage
+			// Usage metadata is reported on the Done chunk
+			if usageMetadata := chunk.UsageMetadata; usageMetadata != nil {
+				accumulatedUsage = models.Usage{
+					PromptTokens:     usageMetadata.PromptTokenCount,
+					CompletionTokens: usageMetadata.CandidatesTokenCount,
+					TotalTokens:      usageMetadata.TotalTokenCount,
 				}
+			}
 
-				streamChan <- response
+			deltas := newToolCallDeltas(choice.Delta.ToolCalls)
+			for _, delta := range deltas {
+				toolCalls.Add(delta)
+			}
 
-				if response.Done {
+			var content string
+			if choice.Delta.Content != nil {
+				content = *choice.Delta.Content
+			}
+			if choice.FinishReason != nil && *choice.FinishReason != "" {
+				finished = true
+				if *choice.FinishReason == finishReasonContentFilter {
+					utils.SendChunk(ctx, streamChan, models.StreamingCompletionResponse{
+						Text:  content,
+						Error: fmt.Errorf("%w: completion stopped by the content filter", models.ErrContentFiltered),
+						Done:  true,
+						Model: servedModel,
+					})
 					return
 				}
 			}
+			if choice.Delta.Content == nil && len(deltas) == 0 {
+				continue
+			}
+
+			if !utils.SendChunk(ctx, streamChan, models.StreamingCompletionResponse{Text: content, ToolCallDeltas: deltas}) {
+				return
+			}
 		}
 	}()
 
 	return streamChan, nil
 }
 
+// finishReasonContentFilter is the finish_reason of a completion cut short by the content filter
+const finishReasonContentFilter = "content_filter"
+
+// isContentFilterError reports whether an API error response is a content policy refusal
+func isContentFilterError(body []byte) bool {
+	var apiError struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &apiError); err != nil {
+		return false
+	}
+	switch apiError.Error.Code {
+	case "content_filter", "content_policy_violation":
+		return true
+	}
+	return false
+}
+
+// defaultSpeechModel is used when SpeechInput.Model is empty
+const defaultSpeechModel = "tts-1"
+
+// defaultSpeechVoice is used when SpeechInput.Voice is empty
+const defaultSpeechVoice = "alloy"
+
+// SynthesizeSpeech converts text to audio using the /v1/audio/speech endpoint
+func (p *OpenAIProvider) SynthesizeSpeech(ctx context.Context, input models.SpeechInput) (*models.SpeechResponse, error) {
+	ctx, cancel := models.ApplyRequestTimeout(ctx)
+	defer cancel()
+
+	if input.Text == "" {
+		return nil, errors.New("no text provided for speech synthesis")
+	}
+
+	url := p.baseURL + "/v1/audio/speech"
+
+	requestBody := struct {
+		Model          string  `json:"model"`
+		Input          string  `json:"input"`
+		Voice          string  `json:"voice"`
+		ResponseFormat string  `json:"response_format,omitempty"`
+		Speed          float32 `json:"speed,omitempty"`
+	}{
+		Model:          input.Model,
+		Input:          input.Text,
+		Voice:          input.Voice,
+		ResponseFormat: input.ResponseFormat,
+		Speed:          input.Speed,
+	}
+	if requestBody.Model == "" {
+		requestBody.Model = defaultSpeechModel
+	}
+	if requestBody.Voice == "" {
+		requestBody.Voice = defaultSpeechVoice
+	}
+
+	jsonBody, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := p.newRequest(ctx, "POST", url, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	resp.Body = utils.LimitBody(resp.Body, p.bodyLimit)
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("OpenAI API request failed: %w", fmt.Errorf("status code: %d, body: %s", resp.StatusCode, string(bodyBytes)))
+	}
+
+	audio, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.SpeechResponse{
+		Audio:       audio,
+		ContentType: resp.Header.Get("Content-Type"),
+	}, nil
+}
+
 // Close closes the OpenAI provider (no-op in this case)
 func (p *OpenAIProvider) Close() error {
 	return nil
 }
 
-// GenerateEmbedding generates an embedding using the OpenAI model (not implemented)
-func (p *OpenAIProvider) GenerateEmbedding(ctx context.Context, input string) ([]float32, error) {
-	return nil, errors.New("embedding generation not implemented for OpenAI provider")
+// Capabilities reports what the OpenAI provider supports beyond completions
+func (p *OpenAIProvider) Capabilities() models.Capabilities {
+	return models.Capabilities{Streaming: true, Tools: true, Speech: true}
 }
 
-// StartChat starts a new chat session (not implemented)
-func (p *OpenAIProvider) StartChat(modelName string) interface{} {
-	return nil
+// ListModels returns the IDs of the models available to the API key, from /v1/models
+func (p *OpenAIProvider) ListModels(ctx context.Context) ([]string, error) {
+	ctx, cancel := models.ApplyRequestTimeout(ctx)
+	defer cancel()
+
+	url := p.baseURL + "/v1/models"
+	req, err := p.newRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	resp.Body = utils.LimitBody(resp.Body, p.bodyLimit)
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("OpenAI API request failed: %w", fmt.Errorf("status code: %d, body: %s", resp.StatusCode, string(bodyBytes)))
+	}
+
+	var result struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(result.Data))
+	for i, model := range result.Data {
+		ids[i] = model.ID
+	}
+	return ids, nil
 }
 
-// SendChatMessage sends a message to an existing chat session (not implemented)
-func (p *OpenAIProvider) SendChatMessage(ctx context.Context, session interface{}, message string) (*models.CompletionResponse, error) {
-	return nil, errors.New("chat functionality not implemented for OpenAI provider")
+// Moderate classifies text against OpenAI's content policy using the /v1/moderations endpoint
+func (p *OpenAIProvider) Moderate(ctx context.Context, text string) (*models.ModerationResult, error) {
+	ctx, cancel := models.ApplyRequestTimeout(ctx)
+	defer cancel()
+
+	url := p.baseURL + "/v1/moderations"
+
+	jsonBody, err := json.Marshal(map[string]string{"input": text})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := p.newRequest(ctx, "POST", url, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	resp.Body = utils.LimitBody(resp.Body, p.bodyLimit)
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("OpenAI API request failed: %w", fmt.Errorf("status code: %d, body: %s", resp.StatusCode, string(bodyBytes)))
+	}
+
+	var result struct {
+		Results []struct {
+			Flagged        bool               `json:"flagged"`
+			Categories     map[string]bool    `json:"categories"`
+			CategoryScores map[string]float64 `json:"category_scores"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if len(result.Results) == 0 {
+		return nil, errors.New("no moderation results in response")
+	}
+
+	return &models.ModerationResult{
+		Flagged:        result.Results[0].Flagged,
+		Categories:     result.Results[0].Categories,
+		CategoryScores: result.Results[0].CategoryScores,
+	}, nil
 }
@@ -1,9 +1,20 @@
 package openai
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/1broseidon/gollm/models"
 )
@@ -28,7 +39,7 @@ func TestOpenAIProvider(t *testing.T) {
 				{Role: "user", Content: "Explain the concept of machine learning in simple terms."},
 			},
 			MaxTokens:   100,
-			Temperature: 0.7,
+			Temperature: models.Float32(0.7),
 		}
 
 		response, err := provider.GenerateCompletion(ctx, "gpt-3.5-turbo", input)
@@ -62,7 +73,7 @@ func TestOpenAIProvider(t *testing.T) {
 				{Role: "user", Content: "Explain the concept of artificial intelligence in simple terms."},
 			},
 			MaxTokens:   100,
-			Temperature: 0.7,
+			Temperature: models.Float32(0.7),
 			Stream:      true,
 		}
 
@@ -105,3 +116,692 @@ func TestOpenAIProvider(t *testing.T) {
 		}
 	})
 }
+
+// newTestProvider returns a provider pointed at a mock server running handler
+func newTestProvider(t *testing.T, handler http.HandlerFunc, opts ...OpenAIOption) *OpenAIProvider {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	t.Setenv("OPENAI_API_KEY", "test-key")
+
+	provider, err := NewOpenAIProvider(append([]OpenAIOption{WithBaseURL(server.URL)}, opts...)...)
+	if err != nil {
+		t.Fatalf("Failed to create OpenAI provider: %v", err)
+	}
+	return provider
+}
+
+func TestOpenAIGatewayOptions(t *testing.T) {
+	var requests []*http.Request
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		switch {
+		case r.URL.Path == "/v1/models":
+			fmt.Fprint(w, `{"data":[{"id":"openai/gpt-4o"}]}`)
+		case strings.Contains(readBody(t, r), `"stream":true`):
+			fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Hi\"},\"finish_reason\":\"stop\"}]}\n\ndata: [DONE]\n\n")
+		default:
+			fmt.Fprint(w, `{"choices":[{"message":{"content":"Hi"}}],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`)
+		}
+	},
+		WithHeader("HTTP-Referer", "https://example.com"),
+		WithHeader("X-Title", "gollm tests"),
+		WithQueryParam("route", "fallback"),
+		WithAPIVersion("2024-10-21"),
+	)
+
+	ctx := models.WithRequestHeaders(context.Background(), http.Header{"X-Title": {"from context"}})
+	input := models.CompletionInput{Messages: []models.ChatMessage{{Role: "user", Content: "Hi"}}}
+	if _, err := provider.GenerateCompletion(ctx, "openai/gpt-4o", input); err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+	stream, err := provider.GenerateCompletionStream(ctx, "openai/gpt-4o", input)
+	if err != nil {
+		t.Fatalf("GenerateCompletionStream failed: %v", err)
+	}
+	for range stream {
+	}
+	if _, err := provider.ListModels(ctx); err != nil {
+		t.Fatalf("ListModels failed: %v", err)
+	}
+
+	if len(requests) != 3 {
+		t.Fatalf("Expected 3 requests, got %d", len(requests))
+	}
+	for _, r := range requests {
+		if query := r.URL.Query(); query.Get("api-version") != "2024-10-21" || query.Get("route") != "fallback" {
+			t.Errorf("%s: expected the query parameters, got %q", r.URL.Path, r.URL.RawQuery)
+		}
+		if referer := r.Header.Get("HTTP-Referer"); referer != "https://example.com" {
+			t.Errorf("%s: expected the HTTP-Referer header, got %q", r.URL.Path, referer)
+		}
+		if title := r.Header.Get("X-Title"); title != "from context" {
+			t.Errorf("%s: expected the context header to take precedence, got %q", r.URL.Path, title)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer test-key" {
+			t.Errorf("%s: expected the API key, got %q", r.URL.Path, auth)
+		}
+	}
+}
+
+// readBody returns the body of r, which it consumes
+func readBody(t *testing.T, r *http.Request) string {
+	t.Helper()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Errorf("Failed to read the request body: %v", err)
+	}
+	return string(body)
+}
+
+func TestOpenAISynthesizeSpeech(t *testing.T) {
+	ctx := context.Background()
+	audio := []byte("ID3\x03\x00fake-mp3-bytes")
+
+	var requestBody map[string]interface{}
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/audio/speech" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			t.Errorf("Unexpected Authorization header: %q", r.Header.Get("Authorization"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			t.Errorf("Invalid request body: %v", err)
+		}
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.Write(audio)
+	})
+
+	response, err := provider.SynthesizeSpeech(ctx, models.SpeechInput{Text: "Hello there", Voice: "nova", Speed: 1.25})
+	if err != nil {
+		t.Fatalf("SynthesizeSpeech failed: %v", err)
+	}
+
+	if !bytes.Equal(response.Audio, audio) {
+		t.Errorf("Unexpected audio bytes: %q", response.Audio)
+	}
+	if response.ContentType != "audio/mpeg" {
+		t.Errorf("Unexpected content type: %q", response.ContentType)
+	}
+	if requestBody["model"] != "tts-1" || requestBody["input"] != "Hello there" || requestBody["voice"] != "nova" || requestBody["speed"] != 1.25 {
+		t.Errorf("Unexpected request body: %v", requestBody)
+	}
+	if _, ok := requestBody["response_format"]; ok {
+		t.Error("response_format should be omitted when not set")
+	}
+
+	if _, err := provider.SynthesizeSpeech(ctx, models.SpeechInput{}); err == nil {
+		t.Error("Expected an error for empty text")
+	}
+}
+
+func TestOpenAILogprobs(t *testing.T) {
+	ctx := context.Background()
+
+	var requestBody map[string]interface{}
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			t.Errorf("Invalid request body: %v", err)
+		}
+		w.Write([]byte(`{
+			"choices": [{
+				"message": {"role": "assistant", "content": "Yes"},
+				"logprobs": {"content": [{
+					"token": "Yes",
+					"logprob": -0.0123,
+					"top_logprobs": [{"token": "Yes", "logprob": -0.0123}, {"token": "No", "logprob": -4.5}]
+				}]}
+			}],
+			"usage": {"prompt_tokens": 12, "completion_tokens": 1, "total_tokens": 13}
+		}`))
+	})
+
+	input := models.CompletionInput{
+		Messages:  []models.ChatMessage{{Role: "user", Content: "Is the sky blue? Answer Yes or No."}},
+		MaxTokens: 1,
+	}
+	input.ProviderOptions.OpenAI.TopLogprobs = 2
+
+	response, err := provider.GenerateCompletion(ctx, "gpt-4o-mini", input)
+	if err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+
+	if requestBody["logprobs"] != true || requestBody["top_logprobs"] != float64(2) {
+		t.Errorf("Expected logprobs to be requested, got %v", requestBody)
+	}
+	if len(response.Logprobs) != 1 {
+		t.Fatalf("Expected 1 token logprob, got %+v", response.Logprobs)
+	}
+	logprob := response.Logprobs[0]
+	if logprob.Token != "Yes" || logprob.Logprob != -0.0123 || len(logprob.TopLogprobs) != 2 || logprob.TopLogprobs[1].Token != "No" {
+		t.Errorf("Unexpected logprob: %+v", logprob)
+	}
+}
+
+func TestOpenAIContentFilter(t *testing.T) {
+	ctx := context.Background()
+	input := models.CompletionInput{Messages: []models.ChatMessage{{Role: "user", Content: "Something disallowed"}}, MaxTokens: 50}
+
+	t.Run("FinishReason", func(t *testing.T) {
+		provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"I can"},"finish_reason":"content_filter"}],
+				"usage":{"prompt_tokens":5,"completion_tokens":2,"total_tokens":7}}`))
+		})
+		if _, err := provider.GenerateCompletion(ctx, "gpt-4o-mini", input); !errors.Is(err, models.ErrContentFiltered) {
+			t.Errorf("Expected ErrContentFiltered, got %v", err)
+		}
+	})
+
+	t.Run("ErrorResponse", func(t *testing.T) {
+		provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"message":"The response was filtered","type":"invalid_request_error","code":"content_filter"}}`))
+		})
+		if _, err := provider.GenerateCompletion(ctx, "gpt-4o-mini", input); !errors.Is(err, models.ErrContentFiltered) {
+			t.Errorf("Expected ErrContentFiltered, got %v", err)
+		}
+		if _, err := provider.GenerateCompletionStream(ctx, "gpt-4o-mini", input); !errors.Is(err, models.ErrContentFiltered) {
+			t.Errorf("Expected ErrContentFiltered from the stream, got %v", err)
+		}
+	})
+
+	t.Run("Stream", func(t *testing.T) {
+		provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"I can\"},\"finish_reason\":null}]}\n\n" +
+				"data: {\"choices\":[{\"delta\":{},\"finish_reason\":\"content_filter\"}]}\n\n" +
+				"data: [DONE]\n\n"))
+		})
+		stream, err := provider.GenerateCompletionStream(ctx, "gpt-4o-mini", input)
+		if err != nil {
+			t.Fatalf("GenerateCompletionStream failed: %v", err)
+		}
+
+		var last models.StreamingCompletionResponse
+		for chunk := range stream {
+			last = chunk
+		}
+		if !last.Done || !errors.Is(last.Error, models.ErrContentFiltered) {
+			t.Errorf("Expected the stream to end with ErrContentFiltered, got %+v", last)
+		}
+	})
+}
+
+func TestOpenAIModerate(t *testing.T) {
+	var requestBody map[string]string
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/moderations" {
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			t.Errorf("Invalid request body: %v", err)
+		}
+		w.Write([]byte(`{"id":"modr-1","model":"omni-moderation-latest","results":[{
+			"flagged": true,
+			"categories": {"harassment": true, "violence": true, "self-harm": false},
+			"category_scores": {"harassment": 0.91, "violence": 0.62, "self-harm": 0.001}
+		}]}`))
+	})
+
+	result, err := provider.Moderate(context.Background(), "some hostile text")
+	if err != nil {
+		t.Fatalf("Moderate failed: %v", err)
+	}
+
+	if requestBody["input"] != "some hostile text" {
+		t.Errorf("Unexpected request body: %v", requestBody)
+	}
+	if !result.Flagged || result.CategoryScores["harassment"] != 0.91 {
+		t.Errorf("Unexpected moderation result: %+v", result)
+	}
+	if flagged := result.FlaggedCategories(); len(flagged) != 2 || flagged[0] != "harassment" || flagged[1] != "violence" {
+		t.Errorf("Unexpected flagged categories: %v", flagged)
+	}
+}
+
+func TestOpenAIListModels(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/v1/models" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"object":"list","data":[{"id":"gpt-4o","object":"model"},{"id":"gpt-4-turbo","object":"model"}]}`))
+	})
+
+	names, err := provider.ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("ListModels failed: %v", err)
+	}
+	if len(names) != 2 || names[0] != "gpt-4o" || names[1] != "gpt-4-turbo" {
+		t.Errorf("Unexpected models %v", names)
+	}
+}
+
+func TestOpenAICredentialsFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("OPENAI_API_KEY", "")
+
+	if _, err := NewOpenAIProvider(); err == nil {
+		t.Fatal("Expected an error without an API key")
+	}
+
+	dir := filepath.Join(home, ".config", "gollm")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "credentials"), []byte(`openai_api_key = "sk-from-file"`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	provider, err := NewOpenAIProvider()
+	if err != nil {
+		t.Fatalf("NewOpenAIProvider failed: %v", err)
+	}
+	if provider.apiKey != "sk-from-file" {
+		t.Errorf("Expected the key from the credentials file, got %q", provider.apiKey)
+	}
+}
+
+func TestOpenAIToolCalls(t *testing.T) {
+	var requestBody struct {
+		Messages []map[string]interface{} `json:"messages"`
+		Tools    []map[string]interface{} `json:"tools"`
+	}
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			t.Errorf("Invalid request body: %v", err)
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":null,"tool_calls":[
+			{"id":"call_2","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"London\"}"}}
+		]},"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":40,"completion_tokens":12,"total_tokens":52}}`))
+	})
+
+	input := models.CompletionInput{
+		Messages: []models.ChatMessage{
+			{Role: "user", Content: "Weather in Paris and London?"},
+			{Role: "assistant", ToolCalls: []models.ToolCall{{ID: "call_1", Name: "get_weather", Arguments: json.RawMessage(`{"city":"Paris"}`)}}},
+			{Role: "tool", Content: "sunny", ToolCallID: "call_1"},
+		},
+		Tools: []models.Tool{{Name: "get_weather", Description: "Current weather", Parameters: json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}}}`)}},
+	}
+	response, err := provider.GenerateCompletion(context.Background(), "gpt-4o-mini", input)
+	if err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+
+	if len(response.ToolCalls) != 1 {
+		t.Fatalf("Expected 1 tool call, got %+v", response.ToolCalls)
+	}
+	if call := response.ToolCalls[0]; call.ID != "call_2" || call.Name != "get_weather" || string(call.Arguments) != `{"city":"London"}` {
+		t.Errorf("Unexpected tool call: %+v", call)
+	}
+
+	if len(requestBody.Tools) != 1 || requestBody.Tools[0]["type"] != "function" {
+		t.Errorf("Expected the tool definition in the request, got %+v", requestBody.Tools)
+	}
+	calls, _ := requestBody.Messages[1]["tool_calls"].([]interface{})
+	if len(calls) != 1 || calls[0].(map[string]interface{})["function"].(map[string]interface{})["arguments"] != `{"city":"Paris"}` {
+		t.Errorf("Expected the assistant's tool call with string arguments, got %+v", requestBody.Messages[1])
+	}
+	if requestBody.Messages[2]["tool_call_id"] != "call_1" || requestBody.Messages[2]["role"] != "tool" {
+		t.Errorf("Expected the tool result message, got %+v", requestBody.Messages[2])
+	}
+	if _, ok := requestBody.Messages[0]["tool_calls"]; ok {
+		t.Errorf("Expected no tool_calls on the user message, got %+v", requestBody.Messages[0])
+	}
+}
+
+func TestOpenAIToolChoice(t *testing.T) {
+	tools := []models.Tool{{Name: "get_weather"}}
+	tests := []struct {
+		name   string
+		tools  []models.Tool
+		choice *models.ToolChoice
+		want   string
+	}{
+		{"NoTools", nil, nil, ``},
+		{"Default", tools, nil, `"auto"`},
+		{"Auto", tools, &models.ToolChoice{Type: models.ToolChoiceAuto}, `"auto"`},
+		{"Any", tools, &models.ToolChoice{Type: models.ToolChoiceAny}, `"required"`},
+		{"None", tools, &models.ToolChoice{Type: models.ToolChoiceNone}, `"none"`},
+		{"Tool", tools, &models.ToolChoice{Type: models.ToolChoiceTool, Name: "get_weather"}, `{"type":"function","function":{"name":"get_weather"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requestBody struct {
+				ToolChoice json.RawMessage `json:"tool_choice"`
+			}
+			provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+				if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+					t.Errorf("Invalid request body: %v", err)
+				}
+				w.Write([]byte(`{"choices":[{"message":{"content":"Hi"}}],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`))
+			})
+
+			input := models.CompletionInput{Messages: []models.ChatMessage{{Role: "user", Content: "Hi"}}, Tools: tt.tools, ToolChoice: tt.choice}
+			if _, err := provider.GenerateCompletion(context.Background(), "gpt-4o-mini", input); err != nil {
+				t.Fatalf("GenerateCompletion failed: %v", err)
+			}
+			if string(requestBody.ToolChoice) != tt.want {
+				t.Errorf("Expected tool_choice %s, got %s", tt.want, requestBody.ToolChoice)
+			}
+		})
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+			t.Error("Expected no request for an invalid tool choice")
+		})
+		input := models.CompletionInput{Tools: tools, ToolChoice: &models.ToolChoice{Type: models.ToolChoiceTool}}
+		if _, err := provider.GenerateCompletion(context.Background(), "gpt-4o-mini", input); err == nil {
+			t.Error("Expected an error for a tool choice without a name")
+		}
+	})
+}
+
+func TestOpenAIResponseBodyLimit(t *testing.T) {
+	body := `{"choices":[{"message":{"content":"` + strings.Repeat("a", 200) + `"}}],"usage":{"prompt_tokens":1,"completion_tokens":50,"total_tokens":51}}`
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}
+	input := models.CompletionInput{Messages: []models.ChatMessage{{Role: "user", Content: "Hi"}}}
+
+	provider := newTestProvider(t, handler, WithResponseBodyLimit(100))
+	if _, err := provider.GenerateCompletion(context.Background(), "gpt-4o-mini", input); !errors.Is(err, models.ErrResponseTooLarge) {
+		t.Errorf("Expected ErrResponseTooLarge, got %v", err)
+	}
+
+	provider = newTestProvider(t, handler, WithResponseBodyLimit(int64(len(body))))
+	if _, err := provider.GenerateCompletion(context.Background(), "gpt-4o-mini", input); err != nil {
+		t.Errorf("Expected a response within the limit, got %v", err)
+	}
+}
+
+func TestOpenAIRequestContext(t *testing.T) {
+	var headers http.Header
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+		if r.Header.Get("X-Slow") != "" {
+			// Reading the body lets the server notice when the client gives up
+			io.Copy(io.Discard, r.Body)
+			select {
+			case <-r.Context().Done():
+			case <-time.After(2 * time.Second):
+			}
+			return
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}],
+			"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`))
+	})
+	input := models.CompletionInput{Messages: []models.ChatMessage{{Role: "user", Content: "Hello"}}, MaxTokens: 5}
+
+	ctx := models.WithRequestHeaders(context.Background(), http.Header{"X-Tenant": {"acme"}})
+	ctx = models.WithRequestID(ctx, "req-42")
+	if _, err := provider.GenerateCompletion(ctx, "gpt-4o-mini", input); err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+	if headers.Get("X-Tenant") != "acme" || headers.Get("X-Request-ID") != "req-42" || headers.Get("Authorization") != "Bearer test-key" {
+		t.Errorf("Expected the context headers alongside the provider's, got %v", headers)
+	}
+
+	ctx = models.WithRequestHeaders(context.Background(), http.Header{"X-Slow": {"1"}})
+	ctx = models.WithRequestTimeout(ctx, 20*time.Millisecond)
+	start := time.Now()
+	if _, err := provider.GenerateCompletionStream(ctx, "gpt-4o-mini", input); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the context timeout to apply, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Timeout took %v", elapsed)
+	}
+}
+
+func TestOpenAISamplingOptions(t *testing.T) {
+	tests := []struct {
+		name  string
+		input models.CompletionInput
+		want  map[string]string // The values sent for the options; "" if left out
+	}{
+		{"Unset", models.CompletionInput{}, map[string]string{"max_tokens": "", "temperature": "", "top_p": "", "stop": ""}},
+		{
+			"Set",
+			models.CompletionInput{MaxTokens: 200, Temperature: models.Float32(0.5), TopP: models.Float32(0.9)},
+			map[string]string{"max_tokens": "200", "max_completion_tokens": "", "temperature": "0.5", "top_p": "0.9"},
+		},
+		{"ZeroTemperature", models.CompletionInput{Temperature: models.Float32(0)}, map[string]string{"temperature": "0"}},
+		{"Stop", models.CompletionInput{Stop: []string{"\n\n", "END"}}, map[string]string{"stop": `["\n\n","END"]`}},
+		{
+			"Extra",
+			models.CompletionInput{Temperature: models.Float32(0.5), Extra: map[string]interface{}{"service_tier": "flex", "model": "gpt-3.5-turbo", "temperature": 1, "top_p": 0.8}},
+			map[string]string{"service_tier": `"flex"`, "model": `"gpt-4o"`, "temperature": "0.5", "top_p": "0.8"},
+		},
+		{
+			"ReasoningEffort",
+			models.CompletionInput{
+				MaxTokens:       2000,
+				Temperature:     models.Float32(0.5),
+				TopP:            models.Float32(0.9),
+				ProviderOptions: models.ProviderOptions{OpenAI: models.OpenAIOptions{ReasoningEffort: models.ReasoningEffortHigh}},
+			},
+			map[string]string{"reasoning_effort": `"high"`, "temperature": "", "top_p": "", "max_tokens": "", "max_completion_tokens": "2000"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests []map[string]json.RawMessage
+			provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+				var request map[string]json.RawMessage
+				if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+					t.Errorf("Invalid request body: %v", err)
+				}
+				requests = append(requests, request)
+				if _, ok := request["stream"]; ok {
+					w.Write([]byte(`data: {"choices":[{"delta":{"content":"Hi"},"finish_reason":"stop"}]}` + "\n\n"))
+					w.Write([]byte("data: [DONE]\n\n"))
+					return
+				}
+				w.Write([]byte(`{"choices":[{"message":{"content":"Hi"}}],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`))
+			})
+
+			input := tt.input
+			input.Messages = []models.ChatMessage{{Role: "user", Content: "Hi"}}
+			if _, err := provider.GenerateCompletion(context.Background(), "gpt-4o", input); err != nil {
+				t.Fatalf("GenerateCompletion failed: %v", err)
+			}
+			stream, err := provider.GenerateCompletionStream(context.Background(), "gpt-4o", input)
+			if err != nil {
+				t.Fatalf("GenerateCompletionStream failed: %v", err)
+			}
+			for range stream {
+			}
+
+			if len(requests) != 2 {
+				t.Fatalf("Expected 2 requests, got %d", len(requests))
+			}
+			for i, request := range requests {
+				for key, want := range tt.want {
+					got, ok := request[key]
+					switch {
+					case want == "" && ok:
+						t.Errorf("Request %d: expected no %s, got %s", i, key, got)
+					case want != "" && string(got) != want:
+						t.Errorf("Request %d: expected %s %s, got %s", i, key, want, got)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestOpenAIReasoningTokens(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Invalid request body: %v", err)
+		}
+		if request["reasoning_effort"] != models.ReasoningEffortLow {
+			t.Errorf("Expected reasoning_effort %q, got %v", models.ReasoningEffortLow, request["reasoning_effort"])
+		}
+		usage := `{"prompt_tokens":20,"completion_tokens":300,"total_tokens":320,"completion_tokens_details":{"reasoning_tokens":256}}`
+		if request["stream"] == true {
+			fmt.Fprint(w, `data: {"model":"o3-mini","choices":[{"delta":{"content":"4"},"finish_reason":"stop"}]}`+"\n\n")
+			fmt.Fprintf(w, "data: {\"choices\":[],\"usage\":%s}\n\n", usage)
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+		fmt.Fprintf(w, `{"model":"o3-mini","choices":[{"message":{"content":"4"}}],"usage":%s}`, usage)
+	})
+	input := models.CompletionInput{
+		Messages:        []models.ChatMessage{{Role: "user", Content: "What is 2+2?"}},
+		ProviderOptions: models.ProviderOptions{OpenAI: models.OpenAIOptions{ReasoningEffort: models.ReasoningEffortLow}},
+	}
+	want := models.Usage{PromptTokens: 20, CompletionTokens: 300, TotalTokens: 320, Details: &models.UsageDetails{ReasoningTokens: 256}}
+
+	resp, err := provider.GenerateCompletion(context.Background(), "o3-mini", input)
+	if err != nil {
+		t.Fatalf("GenerateCompletion failed: %v", err)
+	}
+	if resp.Usage == nil || !reflect.DeepEqual(*resp.Usage, want) {
+		t.Errorf("Expected usage %+v, got %+v", want, resp.Usage)
+	}
+
+	for _, decoding := range []StreamDecoding{StreamDecodingTyped, StreamDecodingGeneric} {
+		provider.streamDecoding = decoding
+		stream, err := provider.GenerateCompletionStream(context.Background(), "o3-mini", input)
+		if err != nil {
+			t.Fatalf("GenerateCompletionStream failed: %v", err)
+		}
+		var done models.StreamingCompletionResponse
+		for chunk := range stream {
+			if chunk.Done {
+				done = chunk
+			}
+		}
+		if done.Usage == nil || !reflect.DeepEqual(*done.Usage, want) {
+			t.Errorf("Expected usage %+v on the Done chunk with decoding %d, got %+v", want, decoding, done.Usage)
+		}
+	}
+}
+
+func TestOpenAIUsageDetails(t *testing.T) {
+	tests := []struct {
+		name  string
+		usage string
+		want  models.Usage
+	}{
+		{
+			"WithDetails",
+			`{"prompt_tokens":2006,"completion_tokens":3,"total_tokens":2009,"prompt_tokens_details":{"cached_tokens":1920,"audio_tokens":0}}`,
+			models.Usage{PromptTokens: 2006, CompletionTokens: 3, TotalTokens: 2009, Details: &models.UsageDetails{CacheReadTokens: 1920}},
+		},
+		{
+			"Audio",
+			`{"prompt_tokens":60,"completion_tokens":90,"total_tokens":150,"prompt_tokens_details":{"cached_tokens":0,"audio_tokens":40},"completion_tokens_details":{"reasoning_tokens":0,"audio_tokens":70}}`,
+			models.Usage{PromptTokens: 60, CompletionTokens: 90, TotalTokens: 150, Details: &models.UsageDetails{AudioTokens: 110}},
+		},
+		{
+			"WithoutDetails",
+			`{"prompt_tokens":9,"completion_tokens":3,"total_tokens":12}`,
+			models.Usage{PromptTokens: 9, CompletionTokens: 3, TotalTokens: 12},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, `{"model":"gpt-4o-mini","choices":[{"message":{"content":"Hello again"}}],"usage":%s}`, tt.usage)
+			})
+			input := models.CompletionInput{Messages: []models.ChatMessage{{Role: "user", Content: "Say hello"}}}
+			resp, err := provider.GenerateCompletion(context.Background(), "gpt-4o-mini", input)
+			if err != nil {
+				t.Fatalf("GenerateCompletion failed: %v", err)
+			}
+			if resp.Usage == nil || !reflect.DeepEqual(*resp.Usage, tt.want) {
+				t.Errorf("Expected usage %+v, got %+v", tt.want, resp.Usage)
+			}
+		})
+	}
+}
+
+func TestOpenAIResponseModel(t *testing.T) {
+	input := models.CompletionInput{Messages: []models.ChatMessage{{Role: "user", Content: "Hi"}}}
+	tests := []struct {
+		name  string
+		model string // The model in the response, if any
+		want  string
+	}{
+		{"Resolved", `"model":"gpt-4o-2024-08-06",`, "gpt-4o-2024-08-06"},
+		{"Fallback", ``, "gpt-4o"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+				var request struct {
+					Stream bool `json:"stream"`
+				}
+				json.NewDecoder(r.Body).Decode(&request)
+				if request.Stream {
+					w.Write([]byte(`data: {` + tt.model + `"choices":[{"delta":{"content":"Hi"},"finish_reason":null}]}` + "\n\n"))
+					w.Write([]byte(`data: {` + tt.model + `"choices":[{"delta":{},"finish_reason":"stop"}]}` + "\n\n"))
+					w.Write([]byte("data: [DONE]\n\n"))
+					return
+				}
+				w.Write([]byte(`{` + tt.model + `"choices":[{"message":{"content":"Hi"}}],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`))
+			})
+
+			resp, err := provider.GenerateCompletion(context.Background(), "gpt-4o", input)
+			if err != nil {
+				t.Fatalf("GenerateCompletion failed: %v", err)
+			}
+			if resp.Model != tt.want {
+				t.Errorf("Expected model %q, got %q", tt.want, resp.Model)
+			}
+
+			for _, decoding := range []StreamDecoding{StreamDecodingTyped, StreamDecodingGeneric} {
+				provider.streamDecoding = decoding
+				stream, err := provider.GenerateCompletionStream(context.Background(), "gpt-4o", input)
+				if err != nil {
+					t.Fatalf("GenerateCompletionStream failed: %v", err)
+				}
+				var last models.StreamingCompletionResponse
+				for chunk := range stream {
+					last = chunk
+				}
+				if !last.Done || last.Model != tt.want {
+					t.Errorf("Expected a Done chunk with model %q, got %+v", tt.want, last)
+				}
+			}
+		})
+	}
+}
+
+func TestNewChatMessagesRoles(t *testing.T) {
+	messages := newChatMessages([]models.ChatMessage{
+		{Role: models.RoleSystem, Content: "Be brief."},
+		{Role: models.RoleUser, Content: "Weather in Paris and Rome?"},
+		{Role: models.RoleAssistant, ToolCalls: []models.ToolCall{{ID: "call_1", Name: "weather"}, {ID: "call_2", Name: "weather"}}},
+		{Role: models.RoleTool, Content: "sunny"},
+		{Role: models.RoleTool, Content: "rainy", ToolCallID: "call_2"},
+		{Role: models.RoleAssistant, Content: "Sunny, then rainy."},
+	})
+
+	tests := []struct {
+		role       string
+		toolCallID string
+	}{
+		{"system", ""},
+		{"user", ""},
+		{"assistant", ""},
+		{"tool", "call_1"},
+		{"tool", "call_2"},
+		{"assistant", ""},
+	}
+	for i, tt := range tests {
+		if messages[i].Role != tt.role || messages[i].ToolCallID != tt.toolCallID {
+			t.Errorf("Expected message %d to have role %q and tool_call_id %q, got %q and %q",
+				i, tt.role, tt.toolCallID, messages[i].Role, messages[i].ToolCallID)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package openai
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// DefaultRealtimeModel is the model OpenRealtimeSession uses when RealtimeConfig.Model is empty
+const DefaultRealtimeModel = "gpt-4o-realtime-preview"
+
+// realtimeHandshakeTimeout bounds the WebSocket handshake when ctx has no earlier deadline
+const realtimeHandshakeTimeout = 30 * time.Second
+
+// RealtimeConfig configures a Realtime API session
+type RealtimeConfig struct {
+	Model        string // The realtime model; DefaultRealtimeModel if empty
+	Voice        string // The voice of audio responses, e.g. "alloy"; the API default if empty
+	Instructions string // The system instructions of the session
+}
+
+// RealtimeEventType is the kind of a RealtimeEvent
+type RealtimeEventType string
+
+const (
+	// RealtimeSpeechStarted is sent when the server detects the start of speech in the input audio
+	RealtimeSpeechStarted RealtimeEventType = "speech.started"
+	// RealtimeSpeechStopped is sent when the server detects the end of speech in the input audio
+	RealtimeSpeechStopped RealtimeEventType = "speech.stopped"
+	// RealtimeTextDelta carries a fragment of a text response in Text
+	RealtimeTextDelta RealtimeEventType = "response.text.delta"
+	// RealtimeAudioDelta carries a fragment of an audio response in Audio
+	RealtimeAudioDelta RealtimeEventType = "response.audio.delta"
+	// RealtimeError reports an error from the API or the connection in Err
+	RealtimeError RealtimeEventType = "error"
+)
+
+// RealtimeEvent is an event of a Realtime API session. Only the field for its Type is set.
+type RealtimeEvent struct {
+	Type  RealtimeEventType
+	Text  string // The text of a RealtimeTextDelta
+	Audio []byte // The PCM16 audio of a RealtimeAudioDelta
+	Err   error  // The error of a RealtimeError
+}
+
+// RealtimeSession is a WebSocket connection to the Realtime API. Audio is 16-bit PCM at 24kHz,
+// mono and little-endian, in both directions; the server detects turns in the input audio and
+// responds on its own. Send and Close are safe for concurrent use.
+type RealtimeSession struct {
+	conn      *websocket.Conn
+	events    chan RealtimeEvent
+	writeMu   sync.Mutex
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// realtimeServerEvent is the part of a server event the session decodes
+type realtimeServerEvent struct {
+	Type  string `json:"type"`
+	Delta string `json:"delta"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// OpenRealtimeSession connects to the Realtime API and configures the session with config.
+// ctx bounds the connection handshake; the session lasts until Close or until the server ends it.
+func (p *OpenAIProvider) OpenRealtimeSession(ctx context.Context, config RealtimeConfig) (*RealtimeSession, error) {
+	model := config.Model
+	if model == "" {
+		model = DefaultRealtimeModel
+	}
+	endpoint, err := realtimeURL(p.baseURL, model)
+	if err != nil {
+		return nil, err
+	}
+
+	// The query parameters, option and context headers are applied as for HTTP requests
+	req, err := p.newRequest(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if req.Header.Get("OpenAI-Beta") == "" {
+		req.Header.Set("OpenAI-Beta", "realtime=v1")
+	}
+
+	conn, resp, err := p.realtimeDialer().DialContext(ctx, req.URL.String(), req.Header)
+	if err != nil {
+		if resp != nil {
+			defer resp.Body.Close()
+			bodyBytes, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+			return nil, fmt.Errorf("OpenAI Realtime API connection failed: %w", fmt.Errorf("status code: %d, body: %s", resp.StatusCode, string(bodyBytes)))
+		}
+		return nil, fmt.Errorf("OpenAI Realtime API connection failed: %w", err)
+	}
+
+	s := &RealtimeSession{
+		conn:   conn,
+		events: make(chan RealtimeEvent),
+		done:   make(chan struct{}),
+	}
+	session := map[string]interface{}{
+		"modalities":   []string{"text", "audio"},
+		"instructions": config.Instructions,
+	}
+	if config.Voice != "" {
+		session["voice"] = config.Voice
+	}
+	if err := s.write(map[string]interface{}{"type": "session.update", "session": session}); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to configure the realtime session: %w", err)
+	}
+
+	go s.readEvents()
+	return s, nil
+}
+
+// realtimeDialer returns a WebSocket dialer using the proxy, TLS and dial settings of the
+// provider's HTTP transport, when it is an *http.Transport
+func (p *OpenAIProvider) realtimeDialer() *websocket.Dialer {
+	dialer := &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: realtimeHandshakeTimeout,
+	}
+	if transport, ok := p.client.Transport.(*http.Transport); ok {
+		dialer.Proxy = transport.Proxy
+		dialer.TLSClientConfig = transport.TLSClientConfig
+		dialer.NetDialContext = transport.DialContext
+	}
+	return dialer
+}
+
+// realtimeURL returns the WebSocket endpoint of the Realtime API for model
+func realtimeURL(baseURL, model string) (string, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL: %w", err)
+	}
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	case "http":
+		u.Scheme = "ws"
+	}
+	u.Path += "/v1/realtime"
+	u.RawQuery = url.Values{"model": {model}}.Encode()
+	return u.String(), nil
+}
+
+// Send appends audio, 16-bit PCM at 24kHz, to the session's input. It returns net.ErrClosed
+// after Close.
+func (s *RealtimeSession) Send(audio []byte) error {
+	return s.write(map[string]string{
+		"type":  "input_audio_buffer.append",
+		"audio": base64.StdEncoding.EncodeToString(audio),
+	})
+}
+
+// Receive returns the events of the session. The channel is closed when the session ends; if
+// the server or the network ended it, the last event is a RealtimeError.
+func (s *RealtimeSession) Receive() <-chan RealtimeEvent {
+	return s.events
+}
+
+// Close ends the session. Later calls return nil.
+func (s *RealtimeSession) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.writeMu.Lock()
+		message := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+		s.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+		s.writeMu.Unlock()
+		err = s.conn.Close()
+	})
+	return err
+}
+
+// write sends a client event
+func (s *RealtimeSession) write(event interface{}) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	select {
+	case <-s.done:
+		return net.ErrClosed
+	default:
+	}
+	return s.conn.WriteJSON(event)
+}
+
+// readEvents forwards server events to the events channel until the connection ends
+func (s *RealtimeSession) readEvents() {
+	defer close(s.events)
+	for {
+		_, data, err := s.conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				s.emit(RealtimeEvent{Type: RealtimeError, Err: fmt.Errorf("realtime connection ended: %w", err)})
+			}
+			return
+		}
+
+		event, ok := parseRealtimeEvent(data)
+		if ok && !s.emit(event) {
+			return
+		}
+	}
+}
+
+// emit sends event to the consumer, reporting false if the session was closed
+func (s *RealtimeSession) emit(event RealtimeEvent) bool {
+	// Close is checked first, as the connection error it causes must not be reported
+	select {
+	case <-s.done:
+		return false
+	default:
+	}
+	select {
+	case s.events <- event:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// parseRealtimeEvent converts a server event. It reports false for the events a RealtimeEvent
+// doesn't cover, such as session and conversation updates.
+func parseRealtimeEvent(data []byte) (RealtimeEvent, bool) {
+	var event realtimeServerEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return RealtimeEvent{Type: RealtimeError, Err: fmt.Errorf("invalid realtime event: %w", err)}, true
+	}
+
+	switch event.Type {
+	case "input_audio_buffer.speech_started":
+		return RealtimeEvent{Type: RealtimeSpeechStarted}, true
+	case "input_audio_buffer.speech_stopped":
+		return RealtimeEvent{Type: RealtimeSpeechStopped}, true
+	case "response.text.delta":
+		return RealtimeEvent{Type: RealtimeTextDelta, Text: event.Delta}, true
+	case "response.audio.delta":
+		audio, err := base64.StdEncoding.DecodeString(event.Delta)
+		if err != nil {
+			return RealtimeEvent{Type: RealtimeError, Err: fmt.Errorf("invalid audio delta: %w", err)}, true
+		}
+		return RealtimeEvent{Type: RealtimeAudioDelta, Audio: audio}, true
+	case "error":
+		message := "unknown error"
+		if event.Error != nil {
+			message = event.Error.Message
+		}
+		return RealtimeEvent{Type: RealtimeError, Err: fmt.Errorf("OpenAI Realtime API error: %s", message)}, true
+	}
+	return RealtimeEvent{}, false
+}
@@ -0,0 +1,168 @@
+package openai
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// newRealtimeServer returns a provider connected to a fake Realtime API, which runs serve with
+// each accepted connection
+func newRealtimeServer(t *testing.T, serve func(conn *websocket.Conn, r *http.Request)) *OpenAIProvider {
+	upgrader := websocket.Upgrader{}
+	return newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		serve(conn, r)
+	})
+}
+
+func TestRealtimeSession(t *testing.T) {
+	received := make(chan []map[string]string, 1)
+	provider := newRealtimeServer(t, func(conn *websocket.Conn, r *http.Request) {
+		if r.URL.Path != "/v1/realtime" || r.URL.Query().Get("model") != "gpt-4o-realtime-preview-2024-12-17" {
+			t.Errorf("Unexpected endpoint %s", r.URL)
+		}
+		if r.Header.Get("Authorization") != "Bearer test-key" || r.Header.Get("OpenAI-Beta") != "realtime=v1" {
+			t.Errorf("Unexpected headers %v", r.Header)
+		}
+
+		// The session update, then one audio append
+		var update struct {
+			Type    string                 `json:"type"`
+			Session map[string]interface{} `json:"session"`
+		}
+		conn.ReadJSON(&update)
+		if update.Type != "session.update" || update.Session["voice"] != "alloy" || update.Session["instructions"] != "Be brief." {
+			t.Errorf("Unexpected session update %+v", update)
+		}
+		var appended map[string]string
+		conn.ReadJSON(&appended)
+		received <- []map[string]string{appended}
+
+		for _, event := range []string{
+			`{"type":"session.updated","session":{}}`,
+			`{"type":"input_audio_buffer.speech_started","audio_start_ms":100}`,
+			`{"type":"input_audio_buffer.speech_stopped","audio_end_ms":900}`,
+			`{"type":"response.text.delta","delta":"Hel"}`,
+			`{"type":"response.text.delta","delta":"lo"}`,
+			`{"type":"response.audio.delta","delta":"` + base64.StdEncoding.EncodeToString([]byte{1, 2, 3, 4}) + `"}`,
+			`{"type":"error","error":{"type":"invalid_request_error","message":"bad event"}}`,
+		} {
+			conn.WriteMessage(websocket.TextMessage, []byte(event))
+		}
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		conn.ReadMessage()
+	})
+
+	config := RealtimeConfig{Model: "gpt-4o-realtime-preview-2024-12-17", Voice: "alloy", Instructions: "Be brief."}
+	session, err := provider.OpenRealtimeSession(context.Background(), config)
+	if err != nil {
+		t.Fatalf("OpenRealtimeSession failed: %v", err)
+	}
+	defer session.Close()
+
+	if err := session.Send([]byte{0xff, 0x00, 0x10}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	appended := (<-received)[0]
+	if appended["type"] != "input_audio_buffer.append" || appended["audio"] != base64.StdEncoding.EncodeToString([]byte{0xff, 0x00, 0x10}) {
+		t.Errorf("Unexpected audio event %v", appended)
+	}
+
+	var events []RealtimeEvent
+	for event := range session.Receive() {
+		events = append(events, event)
+	}
+	want := []RealtimeEvent{
+		{Type: RealtimeSpeechStarted},
+		{Type: RealtimeSpeechStopped},
+		{Type: RealtimeTextDelta, Text: "Hel"},
+		{Type: RealtimeTextDelta, Text: "lo"},
+		{Type: RealtimeAudioDelta, Audio: []byte{1, 2, 3, 4}},
+	}
+	if len(events) != len(want)+1 {
+		t.Fatalf("Expected %d events, got %+v", len(want)+1, events)
+	}
+	if !reflect.DeepEqual(events[:len(want)], want) {
+		t.Errorf("Expected the events %+v, got %+v", want, events[:len(want)])
+	}
+	if last := events[len(want)]; last.Type != RealtimeError || last.Err == nil || !strings.Contains(last.Err.Error(), "bad event") {
+		t.Errorf("Expected the API error, got %+v", last)
+	}
+
+	if err := session.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	if err := session.Close(); err != nil {
+		t.Errorf("Expected a second Close to return nil, got %v", err)
+	}
+	if err := session.Send([]byte{0}); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Expected net.ErrClosed after Close, got %v", err)
+	}
+}
+
+func TestRealtimeSessionDropped(t *testing.T) {
+	provider := newRealtimeServer(t, func(conn *websocket.Conn, r *http.Request) {
+		conn.ReadMessage()
+		// Drop the connection without a close handshake
+	})
+	session, err := provider.OpenRealtimeSession(context.Background(), RealtimeConfig{})
+	if err != nil {
+		t.Fatalf("OpenRealtimeSession failed: %v", err)
+	}
+	defer session.Close()
+
+	var last RealtimeEvent
+	for event := range session.Receive() {
+		last = event
+	}
+	if last.Type != RealtimeError || last.Err == nil {
+		t.Errorf("Expected a connection error as the last event, got %+v", last)
+	}
+}
+
+func TestRealtimeSessionRejected(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"message":"invalid api key"}}`, http.StatusUnauthorized)
+	})
+	_, err := provider.OpenRealtimeSession(context.Background(), RealtimeConfig{})
+	if err == nil || !strings.Contains(err.Error(), "status code: 401") || !strings.Contains(err.Error(), "invalid api key") {
+		t.Errorf("Expected the handshake failure with its status, got %v", err)
+	}
+}
+
+func TestRealtimeSessionClosedByClient(t *testing.T) {
+	provider := newRealtimeServer(t, func(conn *websocket.Conn, r *http.Request) {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+			// Keep sending, so the reader is blocked on an unconsumed event
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"response.text.delta","delta":"x"}`))
+		}
+	})
+	session, err := provider.OpenRealtimeSession(context.Background(), RealtimeConfig{})
+	if err != nil {
+		t.Fatalf("OpenRealtimeSession failed: %v", err)
+	}
+	<-session.Receive()
+	session.Close()
+
+	// The channel is closed without an error event, even though events were not consumed
+	for event := range session.Receive() {
+		if event.Type == RealtimeError {
+			t.Errorf("Expected no error after Close, got %v", event.Err)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package openai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// StreamDecoding selects how the chunks of a streaming completion are decoded
+type StreamDecoding int
+
+const (
+	// StreamDecodingTyped decodes each chunk straight into typed structs. It is the default,
+	// and allocates far less per chunk than StreamDecodingGeneric.
+	StreamDecodingTyped StreamDecoding = iota
+	// StreamDecodingGeneric decodes each chunk into a map[string]interface{} first, as earlier
+	// versions did. It is kept for compatibility with servers whose chunks don't follow the schema.
+	StreamDecodingGeneric
+)
+
+// WithStreamDecoding sets how the chunks of streaming completions are decoded
+func WithStreamDecoding(decoding StreamDecoding) OpenAIOption {
+	return func(p *OpenAIProvider) {
+		p.streamDecoding = decoding
+	}
+}
+
+// streamChunk is one data event of a streaming chat completion
+type streamChunk struct {
+	Model   string         `json:"model"`
+	Choices []streamChoice `json:"choices"`
+	Usage   *streamUsage   `json:"usage"`
+	// UsageMetadata is reported by Gemini's OpenAI-compatible endpoint
+	UsageMetadata *streamUsageMetadata `json:"usageMetadata"`
+}
+
+// streamChoice is a choice in a streamChunk. Delta.Content and FinishReason are nil when absent or null.
+type streamChoice struct {
+	Delta        *streamDelta `json:"delta"`
+	FinishReason *string      `json:"finish_reason"`
+}
+
+// streamDelta is the text and tool call fragments a streamChoice adds to the completion
+type streamDelta struct {
+	Content   *string               `json:"content"`
+	ToolCalls []streamToolCallDelta `json:"tool_calls"`
+}
+
+// streamToolCallDelta is a fragment of a tool call. The ID and name arrive with the first
+// fragment of a call; the arguments are split across fragments. Parallel calls are told
+// apart by Index and their fragments may interleave.
+type streamToolCallDelta struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// streamUsage is the token usage reported on the final chunk of a stream
+type streamUsage struct {
+	PromptTokens        int `json:"prompt_tokens"`
+	CompletionTokens    int `json:"completion_tokens"`
+	TotalTokens         int `json:"total_tokens"`
+	PromptTokensDetails struct {
+		CachedTokens int `json:"cached_tokens"`
+		AudioTokens  int `json:"audio_tokens"`
+	} `json:"prompt_tokens_details"`
+	CompletionTokensDetails struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+		AudioTokens     int `json:"audio_tokens"`
+	} `json:"completion_tokens_details"`
+}
+
+// usage converts the stream usage, leaving Details nil if it reports none
+func (u *streamUsage) usage() models.Usage {
+	usage := models.Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+	}
+	details := models.UsageDetails{
+		ReasoningTokens: u.CompletionTokensDetails.ReasoningTokens,
+		CacheReadTokens: u.PromptTokensDetails.CachedTokens,
+		AudioTokens:     u.PromptTokensDetails.AudioTokens + u.CompletionTokensDetails.AudioTokens,
+	}
+	if !details.IsZero() {
+		usage.Details = &details
+	}
+	return usage
+}
+
+// streamUsageMetadata is the token usage in Gemini's format
+type streamUsageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
+}
+
+// decodeStreamChunk decodes the data of a stream event with the given decoding
+func decodeStreamChunk(data []byte, decoding StreamDecoding) (*streamChunk, error) {
+	if decoding == StreamDecodingGeneric {
+		return decodeGenericStreamChunk(data)
+	}
+	var chunk streamChunk
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return nil, fmt.Errorf("error unmarshaling JSON: %v", err)
+	}
+	return &chunk, nil
+}
+
+// decodeGenericStreamChunk decodes a stream event through a map, ignoring fields of unexpected types
+func decodeGenericStreamChunk(data []byte) (*streamChunk, error) {
+	var result map[string]interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("error unmarshaling JSON: %v", err)
+	}
+
+	chunk := &streamChunk{}
+	chunk.Model, _ = result["model"].(string)
+	if usage, ok := result["usage"].(map[string]interface{}); ok {
+		promptTokens, _ := usage["prompt_tokens"].(float64)
+		completionTokens, _ := usage["completion_tokens"].(float64)
+		totalTokens, _ := usage["total_tokens"].(float64)
+		chunk.Usage = &streamUsage{
+			PromptTokens:     int(promptTokens),
+			CompletionTokens: int(completionTokens),
+			TotalTokens:      int(totalTokens),
+		}
+		chunk.Usage.PromptTokensDetails.CachedTokens = detailCount(usage, "prompt_tokens_details", "cached_tokens")
+		chunk.Usage.PromptTokensDetails.AudioTokens = detailCount(usage, "prompt_tokens_details", "audio_tokens")
+		chunk.Usage.CompletionTokensDetails.ReasoningTokens = detailCount(usage, "completion_tokens_details", "reasoning_tokens")
+		chunk.Usage.CompletionTokensDetails.AudioTokens = detailCount(usage, "completion_tokens_details", "audio_tokens")
+	}
+	if usageMetadata, ok := result["usageMetadata"].(map[string]interface{}); ok {
+		promptTokenCount, _ := usageMetadata["promptTokenCount"].(float64)
+		candidatesTokenCount, _ := usageMetadata["candidatesTokenCount"].(float64)
+		totalTokenCount, _ := usageMetadata["totalTokenCount"].(float64)
+		chunk.UsageMetadata = &streamUsageMetadata{
+			PromptTokenCount:     int(promptTokenCount),
+			CandidatesTokenCount: int(candidatesTokenCount),
+			TotalTokenCount:      int(totalTokenCount),
+		}
+	}
+
+	choices, ok := result["choices"].([]interface{})
+	if !ok || len(choices) == 0 {
+		return chunk, nil
+	}
+	choiceMap, ok := choices[0].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid choice format")
+	}
+
+	var choice streamChoice
+	if finishReason, ok := choiceMap["finish_reason"].(string); ok {
+		choice.FinishReason = &finishReason
+	}
+	if delta, ok := choiceMap["delta"].(map[string]interface{}); ok {
+		choice.Delta = &streamDelta{}
+		if content, ok := delta["content"].(string); ok {
+			choice.Delta.Content = &content
+		}
+		toolCalls, _ := delta["tool_calls"].([]interface{})
+		for _, toolCall := range toolCalls {
+			toolCallMap, ok := toolCall.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			var fragment streamToolCallDelta
+			index, _ := toolCallMap["index"].(float64)
+			fragment.Index = int(index)
+			fragment.ID, _ = toolCallMap["id"].(string)
+			if function, ok := toolCallMap["function"].(map[string]interface{}); ok {
+				fragment.Function.Name, _ = function["name"].(string)
+				fragment.Function.Arguments, _ = function["arguments"].(string)
+			}
+			choice.Delta.ToolCalls = append(choice.Delta.ToolCalls, fragment)
+		}
+	}
+	chunk.Choices = []streamChoice{choice}
+	return chunk, nil
+}
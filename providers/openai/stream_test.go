@@ -0,0 +1,220 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+	"github.com/1broseidon/gollm/providers/providertest"
+)
+
+// streamChunkSamples are stream events covering the fields the stream parser reads
+var streamChunkSamples = []string{
+	`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"gpt-4o-mini","choices":[{"index":0,"delta":{"role":"assistant","content":""},"logprobs":null,"finish_reason":null}]}`,
+	`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"gpt-4o-mini","choices":[{"index":0,"delta":{"content":"Hello"},"logprobs":null,"finish_reason":null}]}`,
+	`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"gpt-4o-mini","choices":[{"index":0,"delta":{},"logprobs":null,"finish_reason":"stop"}]}`,
+	`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"gpt-4o-mini","choices":[],"usage":{"prompt_tokens":9,"completion_tokens":2,"total_tokens":11}}`,
+	`{"choices":[{"delta":{"content":"Hi"},"finish_reason":"stop"}],"usageMetadata":{"promptTokenCount":3,"candidatesTokenCount":1,"totalTokenCount":4}}`,
+	`{"choices":[{"finish_reason":null}]}`,
+	`{"model":"o3-mini","choices":[],"usage":{"prompt_tokens":9,"completion_tokens":300,"total_tokens":309,"completion_tokens_details":{"reasoning_tokens":256}}}`,
+	`{"choices":[{"delta":{"content":null,"tool_calls":[{"index":1,"id":"call_b","type":"function","function":{"name":"get_time","arguments":"{\"tz"}}]},"finish_reason":null}]}`,
+}
+
+func TestDecodeStreamChunk(t *testing.T) {
+	for _, sample := range streamChunkSamples {
+		typed, err := decodeStreamChunk([]byte(sample), StreamDecodingTyped)
+		if err != nil {
+			t.Fatalf("Typed decoding of %s failed: %v", sample, err)
+		}
+		generic, err := decodeStreamChunk([]byte(sample), StreamDecodingGeneric)
+		if err != nil {
+			t.Fatalf("Generic decoding of %s failed: %v", sample, err)
+		}
+		if len(typed.Choices) == 0 {
+			// The generic decoder doesn't distinguish a missing choices array from an empty one
+			typed.Choices = nil
+		}
+		if !reflect.DeepEqual(typed, generic) {
+			t.Errorf("Decodings of %s differ:\ntyped:   %+v\ngeneric: %+v", sample, typed, generic)
+		}
+	}
+
+	for _, decoding := range []StreamDecoding{StreamDecodingTyped, StreamDecodingGeneric} {
+		if _, err := decodeStreamChunk([]byte(`{"choices":`), decoding); err == nil {
+			t.Errorf("Expected an error for malformed JSON with decoding %d", decoding)
+		}
+	}
+}
+
+func TestOpenAIStreamDecoding(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		for _, sample := range streamChunkSamples[:4] {
+			w.Write([]byte("data: " + sample + "\n\n"))
+		}
+		w.Write([]byte("data: [DONE]\n\n"))
+	}
+	input := models.CompletionInput{Messages: []models.ChatMessage{{Role: "user", Content: "Say hello"}}, MaxTokens: 10}
+
+	collect := func(decoding StreamDecoding) []models.StreamingCompletionResponse {
+		provider := newTestProvider(t, handler, WithStreamDecoding(decoding))
+		stream, err := provider.GenerateCompletionStream(context.Background(), "gpt-4o-mini", input)
+		if err != nil {
+			t.Fatalf("GenerateCompletionStream failed: %v", err)
+		}
+		var chunks []models.StreamingCompletionResponse
+		for chunk := range stream {
+			chunks = append(chunks, chunk)
+		}
+		return chunks
+	}
+
+	typed := collect(StreamDecodingTyped)
+	if len(typed) != 3 || typed[1].Text != "Hello" || !typed[2].Done {
+		t.Fatalf("Unexpected chunks: %+v", typed)
+	}
+	if generic := collect(StreamDecodingGeneric); !reflect.DeepEqual(typed, generic) {
+		t.Errorf("Decodings produced different chunks:\ntyped:   %+v\ngeneric: %+v", typed, generic)
+	}
+}
+
+func TestOpenAIStreamParallelToolCalls(t *testing.T) {
+	// Two calls whose argument fragments interleave, as with parallel_tool_calls
+	events := []string{
+		`{"choices":[{"delta":{"role":"assistant","content":null,"tool_calls":[{"index":0,"id":"call_a","type":"function","function":{"name":"get_weather","arguments":""}}]},"finish_reason":null}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":1,"id":"call_b","type":"function","function":{"name":"get_time","arguments":""}}]},"finish_reason":null}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]},"finish_reason":null}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":1,"function":{"arguments":"{\"tz\":\"UTC\"}"}}]},"finish_reason":null}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]},"finish_reason":null}]}`,
+		`{"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+		`{"choices":[],"usage":{"prompt_tokens":30,"completion_tokens":20,"total_tokens":50}}`,
+	}
+	var requestBody map[string]interface{}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			t.Errorf("Invalid request body: %v", err)
+		}
+		for _, event := range events {
+			w.Write([]byte("data: " + event + "\n\n"))
+		}
+		w.Write([]byte("data: [DONE]\n\n"))
+	}
+
+	parallel := true
+	input := models.CompletionInput{
+		Messages:        []models.ChatMessage{{Role: "user", Content: "Weather and time in Paris?"}},
+		Tools:           []models.Tool{{Name: "get_weather"}, {Name: "get_time"}},
+		ProviderOptions: models.ProviderOptions{OpenAI: models.OpenAIOptions{ParallelToolCalls: &parallel}},
+	}
+	want := []models.ToolCall{
+		{ID: "call_a", Name: "get_weather", Arguments: json.RawMessage(`{"city":"Paris"}`)},
+		{ID: "call_b", Name: "get_time", Arguments: json.RawMessage(`{"tz":"UTC"}`)},
+	}
+
+	for _, decoding := range []StreamDecoding{StreamDecodingTyped, StreamDecodingGeneric} {
+		provider := newTestProvider(t, handler, WithStreamDecoding(decoding))
+		stream, err := provider.GenerateCompletionStream(context.Background(), "gpt-4o-mini", input)
+		if err != nil {
+			t.Fatalf("GenerateCompletionStream failed: %v", err)
+		}
+		var chunks []models.StreamingCompletionResponse
+		for chunk := range stream {
+			chunks = append(chunks, chunk)
+		}
+
+		if len(chunks) != 6 || !chunks[5].Done {
+			t.Fatalf("Expected 5 delta chunks and a Done chunk with decoding %d, got %+v", decoding, chunks)
+		}
+		arguments := map[int]string{}
+		for _, chunk := range chunks[:5] {
+			if len(chunk.ToolCallDeltas) != 1 {
+				t.Fatalf("Expected one tool call delta per chunk, got %+v", chunk)
+			}
+			arguments[chunk.ToolCallDeltas[0].Index] += chunk.ToolCallDeltas[0].Arguments
+		}
+		if arguments[0] != `{"city":"Paris"}` || arguments[1] != `{"tz":"UTC"}` {
+			t.Errorf("Expected the deltas to carry the argument fragments, got %v", arguments)
+		}
+		if !reflect.DeepEqual(chunks[5].ToolCalls, want) {
+			t.Errorf("Expected tool calls %+v with decoding %d, got %+v", want, decoding, chunks[5].ToolCalls)
+		}
+		if requestBody["parallel_tool_calls"] != true || requestBody["tool_choice"] != "auto" {
+			t.Errorf("Expected parallel_tool_calls and tool_choice in the request, got %+v", requestBody)
+		}
+	}
+}
+
+func TestStreamDecodingAllocs(t *testing.T) {
+	data := []byte(streamChunkSamples[1])
+	allocs := func(decoding StreamDecoding) float64 {
+		return testing.AllocsPerRun(100, func() {
+			decodeStreamChunk(data, decoding)
+		})
+	}
+	if typed, generic := allocs(StreamDecodingTyped), allocs(StreamDecodingGeneric); typed*4 > generic {
+		t.Errorf("Expected typed decoding to allocate at most a quarter as often as generic decoding, got %v and %v allocs per chunk", typed, generic)
+	}
+}
+
+func BenchmarkOpenAIStreamParse(b *testing.B) {
+	data := []byte(streamChunkSamples[1])
+	for _, bm := range []struct {
+		name     string
+		decoding StreamDecoding
+	}{
+		{"Typed", StreamDecodingTyped},
+		{"Generic", StreamDecodingGeneric},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				if _, err := decodeStreamChunk(data, bm.decoding); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenAIStreamContract(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "test-key")
+	fixtures := []providertest.Fixture{
+		{File: "stream_text.sse", Text: "Hello, world", Usage: &models.Usage{PromptTokens: 9, CompletionTokens: 3, TotalTokens: 12}},
+		{File: "stream_cached_tokens.sse", Text: "Hello again", Usage: &models.Usage{PromptTokens: 2006, CompletionTokens: 3, TotalTokens: 2009, Details: &models.UsageDetails{CacheReadTokens: 1920}}},
+		{File: "stream_finish_with_content.sse", Text: "Hello!", Usage: &models.Usage{PromptTokens: 9, CompletionTokens: 2, TotalTokens: 11}},
+		{File: "stream_tool_calls.sse", ToolCalls: 2, Usage: &models.Usage{PromptTokens: 30, CompletionTokens: 20, TotalTokens: 50}},
+		{File: "stream_no_done_marker.sse", Text: "Hi"},
+		{File: "stream_truncated.sse", Text: "Once upon a time", Err: io.ErrUnexpectedEOF},
+		{File: "stream_malformed.sse", Text: "Hello", Err: providertest.AnyError},
+		{File: "stream_content_filter.sse", Text: "I can", Err: models.ErrContentFiltered},
+	}
+	for _, decoding := range []StreamDecoding{StreamDecodingTyped, StreamDecodingGeneric} {
+		t.Run(fmt.Sprintf("Decoding%d", decoding), func(t *testing.T) {
+			providertest.RunStreamFixtures(t, fixtures, func(t *testing.T, baseURL string) (<-chan models.StreamingCompletionResponse, error) {
+				provider, err := NewOpenAIProvider(WithBaseURL(baseURL), WithStreamDecoding(decoding))
+				if err != nil {
+					return nil, err
+				}
+				input := models.CompletionInput{Messages: []models.ChatMessage{{Role: "user", Content: "Say hello"}}}
+				return provider.GenerateCompletionStream(context.Background(), "gpt-4o-mini", input)
+			})
+		})
+	}
+}
+
+func TestOpenAIStreamAbandoned(t *testing.T) {
+	event := []byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hello\"},\"finish_reason\":null}]}\n\n")
+	providertest.CheckAbandoned(t, event, func(ctx context.Context, baseURL string) (<-chan models.StreamingCompletionResponse, error) {
+		provider, err := NewOpenAIProvider(WithAPIKey("test-key"), WithBaseURL(baseURL))
+		if err != nil {
+			return nil, err
+		}
+		input := models.CompletionInput{Messages: []models.ChatMessage{{Role: "user", Content: "Say hello"}}}
+		return provider.GenerateCompletionStream(ctx, "gpt-4o-mini", input)
+	})
+}
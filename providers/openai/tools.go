@@ -0,0 +1,176 @@
+package openai
+
+import (
+	"encoding/json"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// chatMessage is a message in the Chat Completions API format
+type chatMessage struct {
+	Role       string         `json:"role"`
+	Content    string         `json:"content"`
+	ToolCalls  []wireToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+}
+
+// wireToolCall is a tool call in the Chat Completions API format, with the arguments as a string
+type wireToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// toolDefinition is a function tool offered to the model
+type toolDefinition struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description,omitempty"`
+		Parameters  json.RawMessage `json:"parameters,omitempty"`
+	} `json:"function"`
+}
+
+// roles maps message roles to the roles of the API
+var roles = map[string]string{
+	models.RoleSystem:    "system",
+	models.RoleUser:      "user",
+	models.RoleAssistant: "assistant",
+	models.RoleTool:      "tool",
+}
+
+// apiRole returns the API role for a message role, or the role itself if it has no mapping
+func apiRole(role string) string {
+	if mapped, ok := roles[role]; ok {
+		return mapped
+	}
+	return role
+}
+
+// newChatMessages converts messages, including tool calls and results, to the API format.
+// The API requires tool_call_id on tool messages, so a tool message without a ToolCallID
+// answers the call of the preceding assistant message at its position.
+func newChatMessages(messages []models.ChatMessage) []chatMessage {
+	result := make([]chatMessage, len(messages))
+	var calls []models.ToolCall
+	answered := 0
+	for i, message := range messages {
+		result[i] = chatMessage{Role: apiRole(message.Role), Content: message.Content, ToolCallID: message.ToolCallID}
+		switch message.Role {
+		case models.RoleAssistant:
+			calls, answered = message.ToolCalls, 0
+		case models.RoleTool:
+			if message.ToolCallID == "" && answered < len(calls) {
+				result[i].ToolCallID = calls[answered].ID
+			}
+			answered++
+		}
+		for _, call := range message.ToolCalls {
+			wire := wireToolCall{ID: call.ID, Type: "function"}
+			wire.Function.Name = call.Name
+			wire.Function.Arguments = string(call.Arguments)
+			result[i].ToolCalls = append(result[i].ToolCalls, wire)
+		}
+	}
+	return result
+}
+
+// newToolDefinitions converts tools to the API format
+func newToolDefinitions(tools []models.Tool) []toolDefinition {
+	if len(tools) == 0 {
+		return nil
+	}
+	result := make([]toolDefinition, len(tools))
+	for i, tool := range tools {
+		result[i].Type = "function"
+		result[i].Function.Name = tool.Name
+		result[i].Function.Description = tool.Description
+		result[i].Function.Parameters = tool.Parameters
+	}
+	return result
+}
+
+// parseToolCalls returns the tool calls of the first choice of a completion response
+func parseToolCalls(body []byte) ([]models.ToolCall, error) {
+	var result struct {
+		Choices []struct {
+			Message struct {
+				ToolCalls []wireToolCall `json:"tool_calls"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil || len(result.Choices) == 0 {
+		return nil, err
+	}
+
+	var calls []models.ToolCall
+	for _, wire := range result.Choices[0].Message.ToolCalls {
+		arguments := wire.Function.Arguments
+		if arguments == "" {
+			arguments = "{}"
+		}
+		calls = append(calls, models.ToolCall{ID: wire.ID, Name: wire.Function.Name, Arguments: json.RawMessage(arguments)})
+	}
+	return calls, nil
+}
+
+// newToolChoice converts choice to the API's tool_choice, which is a string for the modes and an
+// object naming a function to force a call to it. It returns nil when no tools are offered, as the
+// API rejects a tool_choice without tools.
+func newToolChoice(choice *models.ToolChoice, tools []models.Tool) (interface{}, error) {
+	if choice != nil {
+		if err := choice.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if len(tools) == 0 {
+		return nil, nil
+	}
+	if choice == nil {
+		return models.ToolChoiceAuto, nil
+	}
+
+	switch choice.Type {
+	case models.ToolChoiceAny:
+		return "required", nil
+	case models.ToolChoiceTool:
+		named := struct {
+			Type     string `json:"type"`
+			Function struct {
+				Name string `json:"name"`
+			} `json:"function"`
+		}{Type: "function"}
+		named.Function.Name = choice.Name
+		return named, nil
+	default:
+		return choice.Type, nil
+	}
+}
+
+// parallelToolCalls returns the parallel_tool_calls field, which the API only accepts with tools
+func parallelToolCalls(input models.CompletionInput) *bool {
+	if len(input.Tools) == 0 {
+		return nil
+	}
+	return input.ProviderOptions.OpenAI.ParallelToolCalls
+}
+
+// newToolCallDeltas converts the tool call fragments of a stream delta
+func newToolCallDeltas(fragments []streamToolCallDelta) []models.ToolCallDelta {
+	if len(fragments) == 0 {
+		return nil
+	}
+	deltas := make([]models.ToolCallDelta, len(fragments))
+	for i, fragment := range fragments {
+		deltas[i] = models.ToolCallDelta{
+			Index:     fragment.Index,
+			ID:        fragment.ID,
+			Name:      fragment.Function.Name,
+			Arguments: fragment.Function.Arguments,
+		}
+	}
+	return deltas
+}
@@ -0,0 +1,21 @@
+package openai
+
+import "github.com/1broseidon/gollm/internal/utils"
+
+// OpenAITransportConfig sets the connection pool of the HTTP client the provider creates. It is
+// the same type as client.TransportConfig.
+type OpenAITransportConfig = utils.TransportConfig
+
+// DefaultOpenAITransportConfig returns the transport settings used unless
+// WithOpenAITransportConfig is given, the same as client.DefaultTransportConfig
+func DefaultOpenAITransportConfig() OpenAITransportConfig {
+	return utils.DefaultTransportConfig()
+}
+
+// WithOpenAITransportConfig sets the connection pool of the provider's HTTP client. It has no
+// effect with WithHTTPClient, whose client is used as it is.
+func WithOpenAITransportConfig(cfg OpenAITransportConfig) OpenAIOption {
+	return func(p *OpenAIProvider) {
+		p.transportConfig = cfg
+	}
+}
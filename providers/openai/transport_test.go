@@ -0,0 +1,28 @@
+package openai
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestOpenAITransportConfig(t *testing.T) {
+	provider, err := NewOpenAIProvider(WithAPIKey("test-key"))
+	if err != nil {
+		t.Fatalf("Failed to create OpenAI provider: %v", err)
+	}
+	transport, ok := provider.client.Transport.(*http.Transport)
+	if !ok || transport.MaxIdleConnsPerHost != 32 || transport.IdleConnTimeout != 90*time.Second {
+		t.Errorf("Expected the default connection pool, got %+v", provider.client.Transport)
+	}
+
+	cfg := OpenAITransportConfig{MaxIdleConns: 10, MaxIdleConnsPerHost: 5, IdleConnTimeout: time.Minute, DisableCompression: true}
+	provider, err = NewOpenAIProvider(WithAPIKey("test-key"), WithOpenAITransportConfig(cfg))
+	if err != nil {
+		t.Fatalf("Failed to create OpenAI provider: %v", err)
+	}
+	transport = provider.client.Transport.(*http.Transport)
+	if transport.MaxIdleConns != 10 || transport.MaxIdleConnsPerHost != 5 || transport.IdleConnTimeout != time.Minute || !transport.DisableCompression {
+		t.Errorf("Expected the transport config to be applied, got %+v", transport)
+	}
+}
@@ -0,0 +1,227 @@
+// Package providertest checks that provider streams follow the streaming contract documented on
+// models.StreamingCompletionResponse. Provider tests run it against recorded responses with
+// RunStreamFixtures, or against any stream with CheckStream, and check that abandoned streams
+// don't leak with CheckAbandoned.
+package providertest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+// StreamTimeout is how long CheckStream waits for each chunk, and for the stream to close after
+// its Done chunk
+var StreamTimeout = 5 * time.Second
+
+// Validate checks chunks, all the chunks of a stream in order, against the streaming contract and
+// returns the first violation
+func Validate(chunks []models.StreamingCompletionResponse) error {
+	if len(chunks) == 0 {
+		return errors.New("the stream closed without a Done chunk")
+	}
+	for i, chunk := range chunks {
+		last := i == len(chunks)-1
+		switch {
+		case chunk.Done && !last:
+			return fmt.Errorf("chunk %d: Done chunk followed by %d more", i, len(chunks)-1-i)
+		case chunk.Error != nil && !chunk.Done:
+			return fmt.Errorf("chunk %d: Error chunk without Done: %v", i, chunk.Error)
+		case chunk.Usage != nil && !chunk.Done:
+			return fmt.Errorf("chunk %d: Usage on a chunk before Done", i)
+		case len(chunk.ToolCalls) > 0 && !chunk.Done:
+			return fmt.Errorf("chunk %d: ToolCalls on a chunk before Done", i)
+		}
+	}
+	if !chunks[len(chunks)-1].Done {
+		return errors.New("the stream closed without a Done chunk")
+	}
+	return nil
+}
+
+// CheckStream reads stream until it is closed, reports violations of the streaming contract with
+// t.Errorf and returns the chunks received. A stream that stalls for StreamTimeout is a violation.
+func CheckStream(t testing.TB, stream <-chan models.StreamingCompletionResponse) []models.StreamingCompletionResponse {
+	t.Helper()
+	var chunks []models.StreamingCompletionResponse
+	timer := time.NewTimer(StreamTimeout)
+	defer timer.Stop()
+	for {
+		select {
+		case chunk, ok := <-stream:
+			if !ok {
+				if err := Validate(chunks); err != nil {
+					t.Errorf("Stream contract violated: %v", err)
+				}
+				return chunks
+			}
+			chunks = append(chunks, chunk)
+			timer.Reset(StreamTimeout)
+		case <-timer.C:
+			if n := len(chunks); n > 0 && chunks[n-1].Done {
+				t.Errorf("Stream contract violated: the stream was not closed after its Done chunk")
+			} else {
+				t.Errorf("Stream contract violated: no chunk for %v after %d chunks", StreamTimeout, n)
+			}
+			return chunks
+		}
+	}
+}
+
+// Text returns the text of a stream: the concatenated Text of its chunks
+func Text(chunks []models.StreamingCompletionResponse) string {
+	var text strings.Builder
+	for _, chunk := range chunks {
+		text.WriteString(chunk.Text)
+	}
+	return text.String()
+}
+
+// Fixture is a recorded streaming response body and the stream a provider should make of it
+type Fixture struct {
+	// File is the file in the testdata directory holding the response body; it names the subtest
+	File string
+	// Text is the expected text of the stream
+	Text string
+	// Usage is the expected Usage of the Done chunk, if set
+	Usage *models.Usage
+	// ToolCalls is the expected number of tool calls on the Done chunk
+	ToolCalls int
+	// Err is the expected Error of the Done chunk: nil, or an error it wraps; AnyError matches any
+	Err error
+}
+
+// AnyError is a Fixture.Err matching any error
+var AnyError = errors.New("any error")
+
+// RunStreamFixtures serves the body of each fixture from a test server and checks the stream that
+// open returns for the server's base URL, against the contract and the fixture's expectations
+func RunStreamFixtures(t *testing.T, fixtures []Fixture, open func(t *testing.T, baseURL string) (<-chan models.StreamingCompletionResponse, error)) {
+	for _, fixture := range fixtures {
+		fixture := fixture
+		t.Run(strings.TrimSuffix(fixture.File, filepath.Ext(fixture.File)), func(t *testing.T) {
+			body, err := os.ReadFile(filepath.Join("testdata", fixture.File))
+			if err != nil {
+				t.Fatalf("Failed to read fixture: %v", err)
+			}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write(body)
+			}))
+			defer server.Close()
+
+			stream, err := open(t, server.URL)
+			if err != nil {
+				t.Fatalf("Failed to open the stream: %v", err)
+			}
+			chunks := CheckStream(t, stream)
+			if len(chunks) == 0 {
+				return
+			}
+			done := chunks[len(chunks)-1]
+
+			if text := Text(chunks); text != fixture.Text {
+				t.Errorf("Expected the text %q, got %q", fixture.Text, text)
+			}
+			if fixture.Usage != nil && (done.Usage == nil || !reflect.DeepEqual(*done.Usage, *fixture.Usage)) {
+				t.Errorf("Expected the usage %+v on the Done chunk, got %+v", *fixture.Usage, done.Usage)
+			}
+			if len(done.ToolCalls) != fixture.ToolCalls {
+				t.Errorf("Expected %d tool calls on the Done chunk, got %+v", fixture.ToolCalls, done.ToolCalls)
+			}
+			switch {
+			case fixture.Err == nil && done.Error != nil:
+				t.Errorf("Expected no error, got %v", done.Error)
+			case fixture.Err == AnyError && done.Error == nil:
+				t.Error("Expected the stream to end with an error")
+			case fixture.Err != nil && fixture.Err != AnyError && !errors.Is(done.Error, fixture.Err):
+				t.Errorf("Expected the stream to end with %v, got %v", fixture.Err, done.Error)
+			}
+		})
+	}
+}
+
+// CheckAbandoned serves event, one event of the provider's streaming format, over and over until
+// the request ends, and opens a stream on the server's base URL with open. It reads the first
+// chunk, then stops reading and cancels the context, as a consumer abandoning the stream must.
+// It fails t unless the provider then ends the HTTP request and its stream goroutine exits,
+// without anything reading the rest of the stream.
+func CheckAbandoned(t *testing.T, event []byte, open func(ctx context.Context, baseURL string) (<-chan models.StreamingCompletionResponse, error)) {
+	t.Helper()
+	ended := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(ended)
+		ticker := time.NewTicker(5 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			if _, err := w.Write(event); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+			select {
+			case <-ticker.C:
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	before := streamGoroutines()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := open(ctx, server.URL)
+	if err != nil {
+		t.Fatalf("Failed to open the stream: %v", err)
+	}
+	select {
+	case <-stream:
+	case <-time.After(StreamTimeout):
+		t.Fatalf("No chunk for %v", StreamTimeout)
+	}
+	cancel()
+
+	select {
+	case <-ended:
+	case <-time.After(StreamTimeout):
+		t.Fatalf("The HTTP request was still open %v after the context was cancelled", StreamTimeout)
+	}
+	deadline := time.Now().Add(StreamTimeout)
+	for streamGoroutines() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("The stream goroutine was still running %v after the context was cancelled", StreamTimeout)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// streamGoroutines returns the number of goroutines started by a GenerateCompletionStream method
+func streamGoroutines() int {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	count := 0
+	for _, stack := range bytes.Split(buf, []byte("\n\n")) {
+		if bytes.Contains(stack, []byte("GenerateCompletionStream.func")) {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,54 @@
+package providertest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/1broseidon/gollm/models"
+)
+
+func TestValidate(t *testing.T) {
+	usage := &models.Usage{PromptTokens: 3, CompletionTokens: 2, TotalTokens: 5}
+	failure := errors.New("connection reset")
+	tests := []struct {
+		name    string
+		chunks  []models.StreamingCompletionResponse
+		wantErr string
+	}{
+		{"Valid", []models.StreamingCompletionResponse{{Text: "Hi"}, {Text: "!", Done: true, Usage: usage}}, ""},
+		{"ErrorOnDone", []models.StreamingCompletionResponse{{Text: "Hi"}, {Error: failure, Done: true}}, ""},
+		{"Empty", nil, "without a Done chunk"},
+		{"NoDone", []models.StreamingCompletionResponse{{Text: "Hi"}}, "without a Done chunk"},
+		{"TwoDone", []models.StreamingCompletionResponse{{Text: "Hi", Done: true}, {Done: true, Usage: usage}}, "Done chunk followed by 1 more"},
+		{"ErrorWithoutDone", []models.StreamingCompletionResponse{{Text: "Hi"}, {Error: failure}, {Done: true}}, "Error chunk without Done"},
+		{"EarlyUsage", []models.StreamingCompletionResponse{{Text: "Hi", Usage: usage}, {Done: true, Usage: usage}}, "Usage on a chunk before Done"},
+		{"EarlyToolCalls", []models.StreamingCompletionResponse{{ToolCalls: []models.ToolCall{{Name: "f"}}}, {Done: true}}, "ToolCalls on a chunk before Done"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.chunks)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected a valid stream, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCheckStream(t *testing.T) {
+	stream := make(chan models.StreamingCompletionResponse, 2)
+	stream <- models.StreamingCompletionResponse{Text: "Hello"}
+	stream <- models.StreamingCompletionResponse{Text: ", world", Done: true}
+	close(stream)
+
+	chunks := CheckStream(t, stream)
+	if len(chunks) != 2 || Text(chunks) != "Hello, world" {
+		t.Errorf("Unexpected chunks %+v", chunks)
+	}
+}
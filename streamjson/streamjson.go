@@ -0,0 +1,311 @@
+// Package streamjson parses JSON while it is being streamed, so fields can be used before the
+// model has finished generating the document.
+package streamjson
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// ErrIncomplete is returned by Complete when the stream ended before the JSON document did
+var ErrIncomplete = errors.New("incomplete JSON")
+
+// Accumulator incrementally scans streamed text for a JSON document. Text before the document,
+// such as a markdown fence or a preamble, and anything after it is ignored, and trailing commas
+// are dropped. Each Add only scans the new text.
+type Accumulator struct {
+	started bool // The opening bracket of the document has been seen
+	done    bool // The closing bracket of the document has been seen
+	err     error
+
+	// Before the document starts, backticks counts the backticks of a markdown fence and
+	// fenceLine is set until the end of the fence's line, so a language tag is skipped
+	backticks int
+	fenceLine bool
+
+	out   []byte  // The document scanned so far, compacted, without a pending string or literal
+	stack []frame // The open objects and arrays
+
+	inString    bool
+	stringIsKey bool
+	escape      bool
+	str         []byte // The raw contents of the current string
+	token       []byte // The current number or literal
+	comma       bool   // A comma is pending until the next value shows it isn't trailing
+
+	// safeLen and safeClose mark the last point where out[:safeLen] closed by safeClose is valid
+	safeLen   int
+	safeClose []byte
+}
+
+// frame is an open object or array
+type frame struct {
+	close   byte // The closing bracket
+	wantKey bool // In an object, the next string is a key
+}
+
+// NewAccumulator returns an empty Accumulator
+func NewAccumulator() *Accumulator {
+	return &Accumulator{}
+}
+
+// Add feeds the next piece of streamed text, e.g. a chunk's Text
+func (a *Accumulator) Add(text string) {
+	for i := 0; i < len(text); i++ {
+		if a.done || a.err != nil {
+			return
+		}
+		a.scan(text[i])
+	}
+}
+
+// Current returns a best-effort parse of the object received so far. Containers that are still
+// open are closed and a string value that is still being generated is included as far as it goes,
+// while keys without a value and numbers or literals that may be cut short are left out.
+// It returns false until the opening brace has been received, or if the text is not a JSON object.
+func (a *Accumulator) Current() (map[string]any, bool) {
+	if !a.started || a.err != nil {
+		return nil, false
+	}
+
+	var doc []byte
+	if a.inString && !a.stringIsKey {
+		doc = append(doc, a.out...)
+		doc = append(doc, '"')
+		doc = append(doc, trimPartialString(a.str, a.escape)...)
+		doc = append(doc, '"')
+		doc = append(doc, closers(a.stack)...)
+	} else {
+		doc = append(doc, a.out[:a.safeLen]...)
+		doc = append(doc, a.safeClose...)
+	}
+
+	var result map[string]any
+	if err := json.Unmarshal(doc, &result); err != nil || result == nil {
+		return nil, false
+	}
+	return result, true
+}
+
+// Complete returns the whole document once the stream has ended. It returns ErrIncomplete if
+// the document was cut short, or an error if it is not valid JSON.
+func (a *Accumulator) Complete() (json.RawMessage, error) {
+	switch {
+	case a.err != nil:
+		return nil, a.err
+	case !a.started:
+		return nil, fmt.Errorf("%w: no JSON object or array found", ErrIncomplete)
+	case !a.done:
+		return nil, fmt.Errorf("%w: %d unclosed brackets", ErrIncomplete, len(a.stack))
+	case !json.Valid(a.out):
+		return nil, errors.New("invalid JSON")
+	}
+	return append(json.RawMessage(nil), a.out...), nil
+}
+
+// scan processes the next byte of the stream
+func (a *Accumulator) scan(b byte) {
+	if !a.started {
+		a.scanPreamble(b)
+		return
+	}
+
+	if a.inString {
+		a.scanString(b)
+		return
+	}
+
+	if len(a.token) > 0 && !isTokenByte(b) {
+		a.endToken()
+		if a.err != nil || a.done {
+			return
+		}
+	}
+
+	switch {
+	case b == ' ' || b == '\t' || b == '\n' || b == '\r':
+	case b == '"':
+		a.beginValue()
+		a.inString = true
+		a.stringIsKey = a.top().wantKey
+		a.str = a.str[:0]
+	case b == '{' || b == '[':
+		a.beginValue()
+		a.open(b)
+	case b == '}' || b == ']':
+		// A pending comma before a closing bracket is a trailing comma and is dropped
+		a.comma = false
+		if len(a.stack) == 0 || a.top().close != b {
+			a.err = fmt.Errorf("invalid JSON: unexpected %q", b)
+			return
+		}
+		a.out = append(a.out, b)
+		a.stack = a.stack[:len(a.stack)-1]
+		a.endValue()
+	case b == ',':
+		a.comma = true
+		if top := a.top(); top.close == '}' {
+			top.wantKey = true
+		}
+	case b == ':':
+		a.out = append(a.out, b)
+	case isTokenByte(b):
+		if len(a.token) == 0 {
+			a.beginValue()
+		}
+		a.token = append(a.token, b)
+	default:
+		a.err = fmt.Errorf("invalid JSON: unexpected %q", b)
+	}
+}
+
+// scanPreamble skips text up to the opening bracket of the document, including the language
+// tag of a markdown fence such as "```json"
+func (a *Accumulator) scanPreamble(b byte) {
+	switch {
+	case b == '`':
+		a.backticks++
+		if a.backticks == 3 {
+			a.fenceLine = true
+		}
+		return
+	case b == '\n':
+		a.fenceLine = false
+	case (b == '{' || b == '[') && !a.fenceLine:
+		a.started = true
+		a.open(b)
+	}
+	a.backticks = 0
+}
+
+// scanString processes a byte within a string
+func (a *Accumulator) scanString(b byte) {
+	switch {
+	case a.escape:
+		a.escape = false
+		a.str = append(a.str, b)
+	case b == '\\':
+		a.escape = true
+		a.str = append(a.str, b)
+	case b == '"':
+		a.inString = false
+		a.out = append(a.out, '"')
+		a.out = append(a.out, a.str...)
+		a.out = append(a.out, '"')
+		if a.stringIsKey {
+			a.top().wantKey = false
+			return
+		}
+		a.endValue()
+	case b < 0x20:
+		// Models sometimes emit raw newlines and tabs in strings; escape them leniently
+		a.str = append(a.str, fmt.Sprintf(`\u%04x`, b)...)
+	default:
+		a.str = append(a.str, b)
+	}
+}
+
+// beginValue writes a pending comma, as a value or key follows it
+func (a *Accumulator) beginValue() {
+	if a.comma {
+		a.out = append(a.out, ',')
+		a.comma = false
+	}
+}
+
+// endToken completes the current number or literal
+func (a *Accumulator) endToken() {
+	if !json.Valid(a.token) {
+		a.err = fmt.Errorf("invalid JSON: unexpected %q", a.token)
+		return
+	}
+	a.out = append(a.out, a.token...)
+	a.token = a.token[:0]
+	a.endValue()
+}
+
+// open starts an object or array
+func (a *Accumulator) open(b byte) {
+	a.out = append(a.out, b)
+	if b == '{' {
+		a.stack = append(a.stack, frame{close: '}', wantKey: true})
+	} else {
+		a.stack = append(a.stack, frame{close: ']'})
+	}
+	a.markSafe()
+}
+
+// endValue records that a complete value has been written
+func (a *Accumulator) endValue() {
+	if len(a.stack) == 0 {
+		a.done = true
+	}
+	a.markSafe()
+}
+
+// markSafe records the current end of out as a point where the document can be closed
+func (a *Accumulator) markSafe() {
+	a.safeLen = len(a.out)
+	a.safeClose = append(a.safeClose[:0], closers(a.stack)...)
+}
+
+// top returns the innermost open container. Outside the document it returns an empty frame.
+func (a *Accumulator) top() *frame {
+	if len(a.stack) == 0 {
+		return &frame{}
+	}
+	return &a.stack[len(a.stack)-1]
+}
+
+// closers returns the brackets that close stack, innermost first
+func closers(stack []frame) []byte {
+	result := make([]byte, len(stack))
+	for i, f := range stack {
+		result[len(stack)-1-i] = f.close
+	}
+	return result
+}
+
+// isTokenByte reports whether b can be part of a number or a literal
+func isTokenByte(b byte) bool {
+	return b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b == '-' || b == '+' || b == '.'
+}
+
+// trimPartialString removes an escape sequence or UTF-8 character that was cut off at the end of
+// the raw contents of a string
+func trimPartialString(s []byte, escape bool) []byte {
+	if escape {
+		return s[:len(s)-1]
+	}
+	// An unfinished \uXXXX escape
+	for i := len(s) - 1; i >= 0 && i >= len(s)-5; i-- {
+		if s[i] == 'u' && i > 0 && s[i-1] == '\\' && oddBackslashes(s[:i]) {
+			if len(s)-i-1 < 4 {
+				return s[:i-1]
+			}
+			break
+		}
+	}
+	// An unfinished multi-byte character
+	for i := len(s) - 1; i >= 0 && i >= len(s)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(s[i]) {
+			if !utf8.FullRune(s[i:]) {
+				return s[:i]
+			}
+			break
+		}
+	}
+	return s
+}
+
+// oddBackslashes reports whether s ends with an odd number of backslashes, so its last
+// backslash starts an escape sequence
+func oddBackslashes(s []byte) bool {
+	n := 0
+	for i := len(s) - 1; i >= 0 && s[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
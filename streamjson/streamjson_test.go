@@ -0,0 +1,139 @@
+package streamjson
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestAccumulatorByteByByte(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"Flat", `{"title": "Hello", "count": 3, "ok": true, "none": null}`, `{"title":"Hello","count":3,"ok":true,"none":null}`},
+		{"Nested", `{"a": {"b": [1, 2.5, {"c": "d"}], "e": []}, "f": [[-1e3]]}`, `{"a":{"b":[1,2.5,{"c":"d"}],"e":[]},"f":[[-1e3]]}`},
+		{"Fenced", "```json\n{\"items\": [\"x\", \"y\"]}\n```", `{"items":["x","y"]}`},
+		{"Preamble", "Here is the result:\n{\"a\": 1}\nLet me know if you need more.", `{"a":1}`},
+		{"TrailingCommas", `{"a": [1, 2,], "b": {"c": 3,},}`, `{"a":[1,2],"b":{"c":3}}`},
+		{"Escapes", `{"q": "say \"hi\"\\", "u": "caf\u00e9 ☕", "brace": "}]"}`, `{"q":"say \"hi\"\\","u":"caf\u00e9 ☕","brace":"}]"}`},
+		{"RawNewline", "{\"body\": \"line one\nline two\"}", `{"body":"line one\u000aline two"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acc := NewAccumulator()
+			seen := map[string]bool{}
+			for i := 0; i < len(tt.input); i++ {
+				acc.Add(tt.input[i : i+1])
+				current, ok := acc.Current()
+				if !ok {
+					continue
+				}
+				// Keys never disappear once they have been parsed
+				for key := range seen {
+					if _, present := current[key]; !present {
+						t.Fatalf("Key %q disappeared after %q: %v", key, tt.input[:i+1], current)
+					}
+				}
+				for key := range current {
+					seen[key] = true
+				}
+			}
+
+			got, err := acc.Complete()
+			if err != nil {
+				t.Fatalf("Complete failed: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+
+			var want map[string]any
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if current, ok := acc.Current(); !ok || !reflect.DeepEqual(current, want) {
+				t.Errorf("Expected Current %v, got %v (%v)", want, current, ok)
+			}
+		})
+	}
+}
+
+func TestAccumulatorCurrent(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string // The expected Current as JSON, or "" if it should return false
+	}{
+		{"Empty", ``, ``},
+		{"FenceOnly", "```json\n", ``},
+		{"Open", `{`, `{}`},
+		{"PartialKey", `{"tit`, `{}`},
+		{"KeyWithoutValue", `{"title":`, `{}`},
+		{"PartialString", `{"title": "Hel`, `{"title":"Hel"}`},
+		{"SecondField", `{"title": "Hello", "body": "Once upon`, `{"title":"Hello","body":"Once upon"}`},
+		{"PartialNumber", `{"a": "x", "n": 12`, `{"a":"x"}`},
+		{"PartialLiteral", `{"a": [1, 2, {"b": tr`, `{"a":[1,2,{}]}`},
+		{"TrailingComma", `{"a": 1,`, `{"a":1}`},
+		{"NestedOpen", `{"a": {"b": [{"c": "d"`, `{"a":{"b":[{"c":"d"}]}}`},
+		{"PartialEscape", `{"a": "x\`, `{"a":"x"}`},
+		{"PartialUnicodeEscape", `{"a": "caf\u00`, `{"a":"caf"}`},
+		{"PartialRune", "{\"a\": \"caf\xc3", `{"a":"caf"}`},
+		{"Array", `[1, 2`, ``},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acc := NewAccumulator()
+			acc.Add(tt.input)
+			current, ok := acc.Current()
+			if tt.want == "" {
+				if ok {
+					t.Errorf("Expected no result, got %v", current)
+				}
+				return
+			}
+
+			var want map[string]any
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !ok || !reflect.DeepEqual(current, want) {
+				t.Errorf("Expected %v, got %v (%v)", want, current, ok)
+			}
+		})
+	}
+}
+
+func TestAccumulatorComplete(t *testing.T) {
+	acc := NewAccumulator()
+	acc.Add(`{"a": [1, 2`)
+	if _, err := acc.Complete(); !errors.Is(err, ErrIncomplete) {
+		t.Errorf("Expected ErrIncomplete for a truncated document, got %v", err)
+	}
+
+	acc = NewAccumulator()
+	acc.Add("no json here")
+	if _, err := acc.Complete(); !errors.Is(err, ErrIncomplete) {
+		t.Errorf("Expected ErrIncomplete without a document, got %v", err)
+	}
+
+	acc = NewAccumulator()
+	acc.Add(`[{"a": 1}, "b"]`)
+	if got, err := acc.Complete(); err != nil || string(got) != `[{"a":1},"b"]` {
+		t.Errorf("Expected the array, got %s (%v)", got, err)
+	}
+
+	acc = NewAccumulator()
+	acc.Add(`{"a": 1]`)
+	if _, err := acc.Complete(); err == nil || errors.Is(err, ErrIncomplete) {
+		t.Errorf("Expected a syntax error for mismatched brackets, got %v", err)
+	}
+
+	acc = NewAccumulator()
+	acc.Add(`{"a": nope}`)
+	if _, err := acc.Complete(); err == nil {
+		t.Error("Expected a syntax error for an invalid literal")
+	}
+}
@@ -0,0 +1,155 @@
+// Package textsplit splits long documents into overlapping chunks for embedding or summarization.
+package textsplit
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/1broseidon/gollm/internal/utils"
+)
+
+// TokenCounter returns the number of tokens in text. Wrap a provider's token counting API in a
+// closure to get exact counts, or use EstimateTokens to count offline.
+type TokenCounter func(text string) int
+
+// EstimateTokens is an offline TokenCounter approximating the tokenizers of OpenAI-style models
+func EstimateTokens(text string) int {
+	return utils.EstimateTokens(text)
+}
+
+// separators are tried in order, so text is split at paragraph boundaries first, then at lines,
+// sentences and words, and between characters only as a last resort
+var separators = []string{"\n\n", "\n", ". ", " ", ""}
+
+// SplitByTokens splits text into chunks of at most chunkTokens tokens as measured by counter,
+// preferring paragraph and sentence boundaries. Consecutive chunks share up to overlapTokens
+// tokens of text. A nil counter uses EstimateTokens. chunkTokens is at least 1, and overlapTokens
+// is limited to less than chunkTokens.
+func SplitByTokens(text string, counter TokenCounter, chunkTokens, overlapTokens int) []string {
+	if counter == nil {
+		counter = EstimateTokens
+	}
+	return newSplitter(counter, chunkTokens, overlapTokens).splitText(text)
+}
+
+// SplitRecursive splits text into chunks of at most chunkSize characters (runes), preferring
+// paragraph and sentence boundaries. Consecutive chunks share up to overlap characters of text.
+// chunkSize is at least 1, and overlap is limited to less than chunkSize.
+func SplitRecursive(text string, chunkSize, overlap int) []string {
+	return newSplitter(utf8.RuneCountInString, chunkSize, overlap).splitText(text)
+}
+
+// splitter is a recursive splitter measuring chunks with length
+type splitter struct {
+	length    func(string) int
+	chunkSize int
+	overlap   int
+}
+
+// newSplitter returns a splitter with chunkSize and overlap clamped to usable values
+func newSplitter(length func(string) int, chunkSize, overlap int) splitter {
+	if chunkSize < 1 {
+		chunkSize = 1
+	}
+	if overlap >= chunkSize {
+		overlap = chunkSize - 1
+	}
+	if overlap < 0 {
+		overlap = 0
+	}
+	return splitter{length: length, chunkSize: chunkSize, overlap: overlap}
+}
+
+// span is a chunk of the text being split, as byte offsets
+type span struct {
+	start, end int
+}
+
+// splitText splits text and drops chunks that contain only whitespace
+func (s splitter) splitText(text string) []string {
+	var chunks []string
+	for _, sp := range s.spans(text) {
+		chunks = append(chunks, text[sp.start:sp.end])
+	}
+	return chunks
+}
+
+// spans returns the chunks of text as spans, in order, skipping those that contain only whitespace
+func (s splitter) spans(text string) []span {
+	var spans []span
+	for _, sp := range s.split(text, span{0, len(text)}, separators) {
+		if strings.TrimSpace(text[sp.start:sp.end]) != "" {
+			spans = append(spans, sp)
+		}
+	}
+	return spans
+}
+
+// split splits the part of text within sp at the first separator it contains, recursing with
+// the remaining separators into pieces that are still too long
+func (s splitter) split(text string, sp span, seps []string) []span {
+	part := text[sp.start:sp.end]
+	sep, rest := "", []string(nil)
+	for i, candidate := range seps {
+		if candidate == "" || strings.Contains(part, candidate) {
+			sep, rest = candidate, seps[i+1:]
+			break
+		}
+	}
+
+	var chunks, pending []span
+	for _, piece := range splitAfter(part, sep, sp.start) {
+		if s.length(text[piece.start:piece.end]) <= s.chunkSize {
+			pending = append(pending, piece)
+			continue
+		}
+		chunks = append(chunks, s.merge(text, pending)...)
+		pending = nil
+		if len(rest) == 0 {
+			// A single character longer than a chunk can't be split any further
+			chunks = append(chunks, piece)
+			continue
+		}
+		chunks = append(chunks, s.split(text, piece, rest)...)
+	}
+	return append(chunks, s.merge(text, pending)...)
+}
+
+// merge joins runs of adjacent pieces into chunks no longer than chunkSize, starting each
+// chunk with the trailing pieces of the previous one that fit within the overlap
+func (s splitter) merge(text string, pieces []span) []span {
+	var chunks []span
+	first := 0
+	for i, piece := range pieces {
+		if i > first && s.length(text[pieces[first].start:piece.end]) > s.chunkSize {
+			chunks = append(chunks, span{pieces[first].start, pieces[i-1].end})
+			for first < i && (s.length(text[pieces[first].start:pieces[i-1].end]) > s.overlap ||
+				s.length(text[pieces[first].start:piece.end]) > s.chunkSize) {
+				first++
+			}
+		}
+	}
+	if first < len(pieces) {
+		chunks = append(chunks, span{pieces[first].start, pieces[len(pieces)-1].end})
+	}
+	return chunks
+}
+
+// splitAfter splits part after each occurrence of sep, keeping the separator with the piece before
+// it, and returns the pieces as spans of the whole text, where part begins at offset. An empty sep
+// splits part into runes, never inside a multi-byte character.
+func splitAfter(part, sep string, offset int) []span {
+	var pieces []span
+	for start := 0; start < len(part); {
+		end := len(part)
+		if sep == "" {
+			_, size := utf8.DecodeRuneInString(part[start:])
+			end = start + size
+		} else if i := strings.Index(part[start:], sep); i >= 0 {
+			end = start + i + len(sep)
+		}
+		pieces = append(pieces, span{offset + start, offset + end})
+		start = end
+	}
+	return pieces
+}
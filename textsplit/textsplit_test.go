@@ -0,0 +1,120 @@
+package textsplit
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// randomText builds a document of words, sentences and paragraphs that mixes ASCII with multi-byte runes
+func randomText(rng *rand.Rand) string {
+	words := []string{"the", "council", "approved", "budget", "naïve", "café", "日本語", "テキスト", "🙂", "internationalization", "42", "x"}
+	var b strings.Builder
+	for paragraph := rng.Intn(5); paragraph >= 0; paragraph-- {
+		for sentence := rng.Intn(6); sentence >= 0; sentence-- {
+			for word := rng.Intn(15); word >= 0; word-- {
+				b.WriteString(words[rng.Intn(len(words))])
+				b.WriteString(" ")
+			}
+			b.WriteString(". ")
+			if rng.Intn(4) == 0 {
+				b.WriteString("\n")
+			}
+		}
+		b.WriteString("\n\n")
+	}
+	if rng.Intn(3) == 0 {
+		// A long run without separators forces splitting between characters
+		b.WriteString(strings.Repeat("é日a", rng.Intn(50)))
+	}
+	return b.String()
+}
+
+// checkSpans verifies the chunks are valid, fit the size, advance through text and together
+// cover every non-whitespace character of text
+func checkSpans(t *testing.T, text string, s splitter) {
+	t.Helper()
+	start, covered := -1, 0
+	for i, sp := range s.spans(text) {
+		chunk := text[sp.start:sp.end]
+		if strings.TrimSpace(chunk) == "" {
+			t.Fatalf("Chunk %d is empty", i)
+		}
+		if !utf8.ValidString(chunk) {
+			t.Fatalf("Chunk %d cuts a multi-byte character: %q", i, chunk)
+		}
+		if n := s.length(chunk); n > s.chunkSize && utf8.RuneCountInString(chunk) > 1 {
+			t.Fatalf("Chunk %d has length %d, more than %d: %q", i, n, s.chunkSize, chunk)
+		}
+		if sp.start <= start {
+			t.Fatalf("Chunk %d doesn't start after the previous chunk", i)
+		}
+		if sp.start > covered && strings.TrimSpace(text[covered:sp.start]) != "" {
+			t.Fatalf("Text before chunk %d is missing: %q", i, text[covered:sp.start])
+		}
+		start, covered = sp.start, max(covered, sp.end)
+	}
+	if rest := text[covered:]; strings.TrimSpace(rest) != "" {
+		t.Fatalf("Text after the last chunk is missing: %q", rest)
+	}
+}
+
+func TestSplitProperties(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	runeCount := func(s string) int { return utf8.RuneCountInString(s) }
+
+	for i := 0; i < 300; i++ {
+		text := randomText(rng)
+		size := 1 + rng.Intn(80)
+		overlap := rng.Intn(size + 10) // may exceed size, which must be clamped
+
+		checkSpans(t, text, newSplitter(runeCount, size, overlap))
+		checkSpans(t, text, newSplitter(EstimateTokens, size, overlap))
+	}
+}
+
+func TestSplitWithoutOverlapReassembles(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	stripSpace := func(s string) string { return strings.Join(strings.Fields(s), "") }
+
+	for i := 0; i < 100; i++ {
+		text := randomText(rng)
+		chunks := SplitByTokens(text, nil, 1+rng.Intn(40), 0)
+		if got, want := stripSpace(strings.Join(chunks, "")), stripSpace(text); got != want {
+			t.Fatalf("Chunks don't reassemble the text:\ngot  %q\nwant %q", got, want)
+		}
+	}
+}
+
+func TestSplitPrefersBoundaries(t *testing.T) {
+	text := "First paragraph has one sentence.\n\nSecond paragraph. It has two sentences."
+
+	chunks := SplitRecursive(text, 40, 0)
+	want := []string{"First paragraph has one sentence.\n\n", "Second paragraph. It has two sentences."}
+	if len(chunks) != len(want) {
+		t.Fatalf("Expected %d chunks, got %q", len(want), chunks)
+	}
+	for i := range want {
+		if chunks[i] != want[i] {
+			t.Errorf("Chunk %d = %q, want %q", i, chunks[i], want[i])
+		}
+	}
+
+	chunks = SplitRecursive("Second paragraph. It has two sentences.", 25, 0)
+	if len(chunks) != 2 || chunks[0] != "Second paragraph. " {
+		t.Errorf("Expected a split at the sentence boundary, got %q", chunks)
+	}
+}
+
+func TestSplitEdgeCases(t *testing.T) {
+	if chunks := SplitRecursive("", 10, 2); len(chunks) != 0 {
+		t.Errorf("Expected no chunks for empty text, got %q", chunks)
+	}
+	if chunks := SplitRecursive(" \n\n \n", 10, 2); len(chunks) != 0 {
+		t.Errorf("Expected no chunks for whitespace, got %q", chunks)
+	}
+	if chunks := SplitRecursive("日本語", 0, 5); len(chunks) != 3 || chunks[0] != "日" {
+		t.Errorf("Expected one rune per chunk for a non-positive size, got %q", chunks)
+	}
+}
@@ -0,0 +1,96 @@
+// Package otel adds OpenTelemetry tracing to a gollm client.
+//
+// It lives in its own module so that applications which don't use OpenTelemetry
+// never pull in its dependencies.
+package otel
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/1broseidon/gollm/client"
+	otelapi "go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Attribute keys follow the OpenTelemetry semantic conventions for generative AI
+const (
+	attrSystem       = "gen_ai.system"
+	attrOperation    = "gen_ai.operation.name"
+	attrRequestModel = "gen_ai.request.model"
+	attrInputTokens  = "gen_ai.usage.input_tokens"
+	attrOutputTokens = "gen_ai.usage.output_tokens"
+)
+
+// WithTracing returns a client option that records a span for every completion, embedding
+// and chat call, and propagates the span context into the outbound HTTP requests of the
+// OpenAI, Anthropic and Ollama providers using the global propagator.
+func WithTracing(tracer trace.Tracer) client.ClientOption {
+	hooks := NewHooks(tracer)
+	return func(c *client.Client) {
+		client.WithHooks(hooks)(c)
+		client.WithTransportWrapper(NewTransport)(c)
+	}
+}
+
+// NewHooks returns client hooks that start a span before each provider call and end it
+// once the call, or the stream, has finished
+func NewHooks(tracer trace.Tracer) client.Hooks {
+	return client.Hooks{
+		BeforeRequest: func(ctx context.Context, info client.RequestInfo) context.Context {
+			name := info.Operation
+			if info.Model != "" {
+				name += " " + info.Model
+			}
+			ctx, _ = tracer.Start(ctx, name,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithTimestamp(info.StartTime),
+				trace.WithAttributes(
+					attribute.String(attrSystem, info.Provider),
+					attribute.String(attrOperation, info.Operation),
+					attribute.String(attrRequestModel, info.Model),
+				),
+			)
+			return ctx
+		},
+		AfterRequest: func(ctx context.Context, info client.RequestInfo, result client.RequestResult) {
+			span := trace.SpanFromContext(ctx)
+			if result.Usage != nil {
+				span.SetAttributes(
+					attribute.Int(attrInputTokens, result.Usage.PromptTokens),
+					attribute.Int(attrOutputTokens, result.Usage.CompletionTokens),
+				)
+			}
+			if result.Err != nil {
+				span.RecordError(result.Err)
+				span.SetStatus(codes.Error, result.Err.Error())
+			}
+			span.End()
+		},
+	}
+}
+
+// transport injects the trace context of each request into its headers
+type transport struct {
+	base http.RoundTripper
+}
+
+// NewTransport wraps base so that outbound requests carry the trace context of their
+// request context, using the globally registered propagator
+func NewTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the caller's request
+	req = req.Clone(req.Context())
+	otelapi.GetTextMapPropagator().Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,134 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/1broseidon/gollm/client"
+	"github.com/1broseidon/gollm/models"
+	otelapi "go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordingSpan captures what the hooks record on a span
+type recordingSpan struct {
+	trace.Span
+	name       string
+	attributes map[attribute.Key]attribute.Value
+	status     codes.Code
+	errs       []error
+	ended      bool
+	sc         trace.SpanContext
+}
+
+func (s *recordingSpan) SetAttributes(kv ...attribute.KeyValue) {
+	for _, attr := range kv {
+		s.attributes[attr.Key] = attr.Value
+	}
+}
+func (s *recordingSpan) RecordError(err error, opts ...trace.EventOption) {
+	s.errs = append(s.errs, err)
+}
+func (s *recordingSpan) SetStatus(code codes.Code, description string) { s.status = code }
+func (s *recordingSpan) End(opts ...trace.SpanEndOption)               { s.ended = true }
+func (s *recordingSpan) SpanContext() trace.SpanContext                { return s.sc }
+func (s *recordingSpan) IsRecording() bool                             { return !s.ended }
+
+// recordingTracer hands out recordingSpans
+type recordingTracer struct {
+	trace.Tracer
+	spans []*recordingSpan
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	cfg := trace.NewSpanStartConfig(opts...)
+	span := &recordingSpan{
+		name:       name,
+		attributes: map[attribute.Key]attribute.Value{},
+		sc: trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    trace.TraceID{1, 2, 3},
+			SpanID:     trace.SpanID{4, 5, 6},
+			TraceFlags: trace.FlagsSampled,
+		}),
+	}
+	span.SetAttributes(cfg.Attributes()...)
+	t.spans = append(t.spans, span)
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+func TestHooks(t *testing.T) {
+	tracer := &recordingTracer{}
+	hooks := NewHooks(tracer)
+	info := client.RequestInfo{Operation: client.OperationCompletion, Provider: "openai", Model: "gpt-4o", StartTime: time.Now()}
+
+	ctx := hooks.BeforeRequest(context.Background(), info)
+	hooks.AfterRequest(ctx, info, client.RequestResult{Usage: &models.Usage{PromptTokens: 12, CompletionTokens: 8}})
+
+	ctx = hooks.BeforeRequest(context.Background(), info)
+	hooks.AfterRequest(ctx, info, client.RequestResult{Err: errors.New("boom")})
+
+	if len(tracer.spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(tracer.spans))
+	}
+
+	ok := tracer.spans[0]
+	if !ok.ended || ok.name != "completion gpt-4o" {
+		t.Errorf("Unexpected span: ended=%v name=%q", ok.ended, ok.name)
+	}
+	if ok.attributes[attrSystem].AsString() != "openai" || ok.attributes[attrRequestModel].AsString() != "gpt-4o" {
+		t.Errorf("Missing provider/model attributes: %v", ok.attributes)
+	}
+	if ok.attributes[attrInputTokens].AsInt64() != 12 || ok.attributes[attrOutputTokens].AsInt64() != 8 {
+		t.Errorf("Missing token usage attributes: %v", ok.attributes)
+	}
+	if ok.status == codes.Error {
+		t.Error("Successful span marked as error")
+	}
+
+	failed := tracer.spans[1]
+	if !failed.ended || failed.status != codes.Error || len(failed.errs) != 1 {
+		t.Errorf("Expected failed span to record the error, got status=%v errs=%v", failed.status, failed.errs)
+	}
+}
+
+func TestTransportPropagatesTraceContext(t *testing.T) {
+	original := otelapi.GetTextMapPropagator()
+	otelapi.SetTextMapPropagator(propagation.TraceContext{})
+	defer otelapi.SetTextMapPropagator(original)
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer server.Close()
+
+	tracer := &recordingTracer{}
+	ctx, span := tracer.Start(context.Background(), "completion")
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+	if err != nil {
+		t.Fatalf("NewRequest failed: %v", err)
+	}
+	httpClient := &http.Client{Transport: NewTransport(nil)}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	want := "00-01020300000000000000000000000000-0405060000000000-01"
+	if traceparent != want {
+		t.Errorf("traceparent = %q, want %q", traceparent, want)
+	}
+	if req.Header.Get("traceparent") != "" {
+		t.Error("Transport modified the caller's request")
+	}
+}
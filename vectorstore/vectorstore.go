@@ -0,0 +1,204 @@
+// Package vectorstore stores embeddings in memory and searches them by cosine similarity, for
+// retrieval-augmented generation without an external database.
+package vectorstore
+
+import (
+	"container/heap"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/1broseidon/gollm/embeddings"
+)
+
+// SearchResult is a document found by Search
+type SearchResult struct {
+	ID    string
+	Text  string
+	Score float32 // Cosine similarity to the query, from -1 to 1
+}
+
+// document is a stored document. Its vector is normalized, so its dot product with a normalized
+// query is their cosine similarity.
+type document struct {
+	ID   string
+	Text string
+	Vec  []float32
+}
+
+// VectorStore is an in-memory store of embeddings, kept in a slice sorted by ID. Search compares
+// the query with every document, which is fast enough for tens of thousands of documents.
+// It is safe for concurrent use.
+type VectorStore struct {
+	mu   sync.RWMutex
+	docs []document
+	dims int
+}
+
+// NewVectorStore returns an empty VectorStore
+func NewVectorStore() *VectorStore {
+	return &VectorStore{}
+}
+
+// Insert adds a document, replacing any document with the same ID. The first vector sets the
+// dimension of the store; Insert panics if a later vector has a different dimension.
+func (s *VectorStore) Insert(ctx context.Context, id string, text string, vec []float32) {
+	doc := document{ID: id, Text: text, Vec: embeddings.Normalize(vec)}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.docs) == 0 {
+		s.dims = len(vec)
+	} else if len(vec) != s.dims {
+		panic(fmt.Sprintf("vectorstore: vector has dimension %d, the store has %d", len(vec), s.dims))
+	}
+
+	i, found := s.find(id)
+	if found {
+		s.docs[i] = doc
+		return
+	}
+	s.docs = append(s.docs, document{})
+	copy(s.docs[i+1:], s.docs[i:])
+	s.docs[i] = doc
+}
+
+// Delete removes the document with the given ID. It reports whether the document existed.
+func (s *VectorStore) Delete(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, found := s.find(id)
+	if !found {
+		return false
+	}
+	s.docs = append(s.docs[:i], s.docs[i+1:]...)
+	return true
+}
+
+// Len returns the number of documents in the store
+func (s *VectorStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.docs)
+}
+
+// Search returns the topK documents most similar to query, most similar first. Documents with
+// equal scores are ordered by ID. It returns nil if topK is not positive, the query has the
+// wrong dimension, or ctx is cancelled during the search.
+func (s *VectorStore) Search(ctx context.Context, query []float32, topK int) []SearchResult {
+	if topK <= 0 {
+		return nil
+	}
+	query = embeddings.Normalize(query)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.docs) == 0 || len(query) != s.dims {
+		return nil
+	}
+
+	best := make(resultHeap, 0, min(topK, len(s.docs)))
+	for i := range s.docs {
+		if i%1024 == 0 && ctx.Err() != nil {
+			return nil
+		}
+		doc := &s.docs[i]
+		result := SearchResult{ID: doc.ID, Text: doc.Text, Score: dot(query, doc.Vec)}
+		if len(best) < topK {
+			heap.Push(&best, result)
+		} else if less(best[0], result) {
+			best[0] = result
+			heap.Fix(&best, 0)
+		}
+	}
+
+	results := make([]SearchResult, len(best))
+	for i := len(results) - 1; i >= 0; i-- {
+		results[i] = heap.Pop(&best).(SearchResult)
+	}
+	return results
+}
+
+// snapshot is the gob encoding of a store
+type snapshot struct {
+	Dims int
+	Docs []document
+}
+
+// Save writes the documents to w in gob encoding. The vectors are saved normalized.
+func (s *VectorStore) Save(w io.Writer) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if err := gob.NewEncoder(w).Encode(snapshot{Dims: s.dims, Docs: s.docs}); err != nil {
+		return fmt.Errorf("error saving vector store: %w", err)
+	}
+	return nil
+}
+
+// Load replaces the documents of the store with those written by Save
+func (s *VectorStore) Load(r io.Reader) error {
+	var snap snapshot
+	if err := gob.NewDecoder(r).Decode(&snap); err != nil {
+		return fmt.Errorf("error loading vector store: %w", err)
+	}
+	for _, doc := range snap.Docs {
+		if len(doc.Vec) != snap.Dims {
+			return fmt.Errorf("error loading vector store: document %q has dimension %d, expected %d", doc.ID, len(doc.Vec), snap.Dims)
+		}
+	}
+	sort.Slice(snap.Docs, func(i, j int) bool { return snap.Docs[i].ID < snap.Docs[j].ID })
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.docs, s.dims = snap.Docs, snap.Dims
+	return nil
+}
+
+// find returns the index of the document with the given ID, or where it would be inserted
+func (s *VectorStore) find(id string) (int, bool) {
+	i := sort.Search(len(s.docs), func(i int) bool { return s.docs[i].ID >= id })
+	return i, i < len(s.docs) && s.docs[i].ID == id
+}
+
+// less reports whether a ranks below b
+func less(a, b SearchResult) bool {
+	if a.Score != b.Score {
+		return a.Score < b.Score
+	}
+	return a.ID > b.ID
+}
+
+// resultHeap is a min-heap of the best results so far, with the lowest ranked on top
+type resultHeap []SearchResult
+
+func (h resultHeap) Len() int            { return len(h) }
+func (h resultHeap) Less(i, j int) bool  { return less(h[i], h[j]) }
+func (h resultHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *resultHeap) Push(x interface{}) { *h = append(*h, x.(SearchResult)) }
+func (h *resultHeap) Pop() interface{} {
+	old := *h
+	result := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return result
+}
+
+// dot returns the dot product of a and b. The loop is unrolled with independent sums, which lets
+// the compiler keep them in registers and pipeline the multiplications.
+func dot(a, b []float32) float32 {
+	b = b[:len(a)]
+	var s0, s1, s2, s3 float32
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		s0 += a[i] * b[i]
+		s1 += a[i+1] * b[i+1]
+		s2 += a[i+2] * b[i+2]
+		s3 += a[i+3] * b[i+3]
+	}
+	for ; i < len(a); i++ {
+		s0 += a[i] * b[i]
+	}
+	return s0 + s1 + s2 + s3
+}
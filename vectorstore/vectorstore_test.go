@@ -0,0 +1,220 @@
+package vectorstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/1broseidon/gollm/embeddings"
+)
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	s := NewVectorStore()
+	s.Insert(ctx, "x", "along x", []float32{2, 0, 0})
+	s.Insert(ctx, "xy", "between x and y", []float32{1, 1, 0})
+	s.Insert(ctx, "y", "along y", []float32{0, 3, 0})
+	s.Insert(ctx, "-x", "opposite x", []float32{-1, 0, 0})
+
+	results := s.Search(ctx, []float32{5, 0, 0}, 3)
+	want := []SearchResult{
+		{ID: "x", Text: "along x", Score: 1},
+		{ID: "xy", Text: "between x and y", Score: 1 / math.Sqrt2},
+		{ID: "y", Text: "along y", Score: 0},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("Expected %d results, got %v", len(want), results)
+	}
+	for i := range want {
+		if results[i].ID != want[i].ID || results[i].Text != want[i].Text || math.Abs(float64(results[i].Score-want[i].Score)) > 1e-6 {
+			t.Errorf("Expected result %d to be %+v, got %+v", i, want[i], results[i])
+		}
+	}
+
+	if got := s.Search(ctx, []float32{1, 0, 0}, 10); len(got) != 4 || got[3].ID != "-x" {
+		t.Errorf("Expected all documents with the opposite last, got %v", got)
+	}
+	if got := s.Search(ctx, []float32{1, 0, 0}, 0); got != nil {
+		t.Errorf("Expected no results for topK 0, got %v", got)
+	}
+	if got := s.Search(ctx, []float32{1, 0}, 1); got != nil {
+		t.Errorf("Expected no results for a query of the wrong dimension, got %v", got)
+	}
+	if got := NewVectorStore().Search(ctx, []float32{1, 0, 0}, 1); got != nil {
+		t.Errorf("Expected no results from an empty store, got %v", got)
+	}
+}
+
+func TestSearchTies(t *testing.T) {
+	ctx := context.Background()
+	s := NewVectorStore()
+	for _, id := range []string{"d", "b", "a", "c"} {
+		s.Insert(ctx, id, "", []float32{1, 1})
+	}
+	results := s.Search(ctx, []float32{1, 1}, 3)
+	for i, id := range []string{"a", "b", "c"} {
+		if results[i].ID != id {
+			t.Fatalf("Expected equal scores ordered by ID, got %v", results)
+		}
+	}
+}
+
+func TestSearchCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := NewVectorStore()
+	s.Insert(ctx, "a", "", []float32{1})
+	cancel()
+	if got := s.Search(ctx, []float32{1}, 1); got != nil {
+		t.Errorf("Expected no results after cancellation, got %v", got)
+	}
+}
+
+func TestInsertAndDelete(t *testing.T) {
+	ctx := context.Background()
+	s := NewVectorStore()
+	s.Insert(ctx, "b", "first", []float32{1, 0})
+	s.Insert(ctx, "a", "", []float32{0, 1})
+	s.Insert(ctx, "b", "replaced", []float32{0, 1})
+	if s.Len() != 2 {
+		t.Fatalf("Expected 2 documents, got %d", s.Len())
+	}
+	if got := s.Search(ctx, []float32{0, 1}, 2); got[1].ID != "b" || got[1].Text != "replaced" || got[1].Score < 0.999 {
+		t.Errorf("Expected the replaced document, got %v", got)
+	}
+
+	if !s.Delete("a") {
+		t.Error("Expected Delete to find the document")
+	}
+	if s.Delete("a") {
+		t.Error("Expected Delete to report a missing document")
+	}
+	if got := s.Search(ctx, []float32{0, 1}, 2); s.Len() != 1 || len(got) != 1 || got[0].ID != "b" {
+		t.Errorf("Expected only b to remain, got %v", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic for a vector of a different dimension")
+		}
+	}()
+	s.Insert(ctx, "c", "", []float32{1, 2, 3})
+}
+
+func TestSaveLoad(t *testing.T) {
+	ctx := context.Background()
+	rng := rand.New(rand.NewSource(1))
+	s := NewVectorStore()
+	for i := 0; i < 100; i++ {
+		s.Insert(ctx, fmt.Sprint(i), fmt.Sprintf("document %d", i), randomVector(rng, 16))
+	}
+
+	var buf bytes.Buffer
+	if err := s.Save(&buf); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded := NewVectorStore()
+	loaded.Insert(ctx, "stale", "", []float32{1})
+	if err := loaded.Load(&buf); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.Len() != s.Len() {
+		t.Fatalf("Expected %d documents, got %d", s.Len(), loaded.Len())
+	}
+
+	query := randomVector(rng, 16)
+	want, got := s.Search(ctx, query, 10), loaded.Search(ctx, query, 10)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Expected the same results after loading, got %v and %v", want, got)
+		}
+	}
+
+	if err := loaded.Load(bytes.NewReader([]byte("not gob"))); err == nil {
+		t.Error("Expected an error for invalid data")
+	}
+}
+
+// TestSearchMatchesBruteForce compares Search with sorting every cosine similarity
+func TestSearchMatchesBruteForce(t *testing.T) {
+	ctx := context.Background()
+	rng := rand.New(rand.NewSource(2))
+	s := NewVectorStore()
+	vecs := make(map[string][]float32)
+	for i := 0; i < 500; i++ {
+		id := fmt.Sprintf("doc-%d", i)
+		vecs[id] = randomVector(rng, 37)
+		s.Insert(ctx, id, "", vecs[id])
+	}
+
+	query := randomVector(rng, 37)
+	var all []SearchResult
+	for id, vec := range vecs {
+		all = append(all, SearchResult{ID: id, Score: embeddings.CosineSimilarity(query, vec)})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Score > all[j].Score })
+
+	for i, result := range s.Search(ctx, query, 20) {
+		if result.ID != all[i].ID || math.Abs(float64(result.Score-all[i].Score)) > 1e-5 {
+			t.Fatalf("Expected result %d to be %+v, got %+v", i, all[i], result)
+		}
+	}
+}
+
+// randomVector returns a vector of normally distributed values
+func randomVector(rng *rand.Rand, dims int) []float32 {
+	v := make([]float32, dims)
+	for k := range v {
+		v[k] = float32(rng.NormFloat64())
+	}
+	return v
+}
+
+// benchmarkStore returns a store of 10,000 random documents of 1536 dimensions, the size of
+// OpenAI's text-embedding-3-small output
+func benchmarkStore() *VectorStore {
+	rng := rand.New(rand.NewSource(1))
+	s := NewVectorStore()
+	for i := 0; i < 10000; i++ {
+		s.Insert(context.Background(), fmt.Sprint(i), "", randomVector(rng, 1536))
+	}
+	return s
+}
+
+func BenchmarkInsert(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	vecs := make([][]float32, 10000)
+	for i := range vecs {
+		vecs[i] = randomVector(rng, 1536)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s := NewVectorStore()
+		for j, vec := range vecs {
+			s.Insert(context.Background(), fmt.Sprint(j), "", vec)
+		}
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+	s := benchmarkStore()
+	query := randomVector(rand.New(rand.NewSource(2)), 1536)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Search(context.Background(), query, 10)
+	}
+}
+
+func BenchmarkSave(b *testing.B) {
+	s := benchmarkStore()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var buf bytes.Buffer
+		if err := s.Save(&buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}